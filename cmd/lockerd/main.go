@@ -26,6 +26,7 @@ import (
 	"github.com/piprate/metalocker/cmd"
 	"github.com/piprate/metalocker/node"
 	"github.com/piprate/metalocker/utils"
+	"github.com/piprate/metalocker/utils/jsonw"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
@@ -65,6 +66,17 @@ func main() {
 			Usage:  "initialize a new server configuration",
 			Action: InitialiseCommand,
 		},
+		{
+			Name:   "sweep",
+			Usage:  "run one lease expiry and blob garbage collection sweep",
+			Action: SweepCommand,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "if true, only report what would be purged",
+				},
+			},
+		},
 	}
 
 	app.Action = RunServer
@@ -88,10 +100,7 @@ func InitialiseCommand(c *cli.Context) error {
 
 var cfg = koanf.New(".")
 
-func RunServer(c *cli.Context) error {
-
-	// read configuration
-
+func loadConfig(c *cli.Context) (string, error) {
 	configName := c.String("config")
 	configDir := utils.AbsPathify(cmd.GetMetaLockerConfigDir())
 
@@ -101,6 +110,54 @@ func RunServer(c *cli.Context) error {
 		),
 		yaml.Parser(),
 	)
+	if err != nil {
+		return "", err
+	}
+
+	return configDir, nil
+}
+
+func SweepCommand(c *cli.Context) error {
+	configDir, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		if err = cfg.Set("sweeper.dryRun", true); err != nil {
+			return err
+		}
+	} else if !cfg.Exists("sweeper") {
+		if err = cfg.Set("sweeper.dryRun", false); err != nil {
+			return err
+		}
+	}
+
+	srv := node.NewMetaLockerServer(configDir)
+	if err = srv.InitServices(c.Context, cfg, c.Bool("debug")); err != nil {
+		return err
+	}
+	defer srv.Ledger.Close()
+
+	report, err := srv.Sweeper.Sweep(c.Context)
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	b, err := jsonw.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+	println(string(b))
+
+	return nil
+}
+
+func RunServer(c *cli.Context) error {
+
+	// read configuration
+
+	configDir, err := loadConfig(c)
 	if err != nil {
 		return err
 	}
@@ -20,11 +20,16 @@ package main
 
 import (
 	_ "github.com/piprate/metalocker/index/bolt"
+	_ "github.com/piprate/metalocker/index/sqlstore"
 
 	_ "github.com/piprate/metalocker/ledger/local"
+	_ "github.com/piprate/metalocker/ledger/postgres"
+
+	_ "github.com/piprate/metalocker/sdk/apibase/boltthrottle"
 
 	_ "github.com/piprate/metalocker/storage/memory"
 
 	_ "github.com/piprate/metalocker/vaults/fs"
 	_ "github.com/piprate/metalocker/vaults/memory"
+	_ "github.com/piprate/metalocker/vaults/s3"
 )
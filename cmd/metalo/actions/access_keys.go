@@ -17,10 +17,13 @@ package actions
 import (
 	"os"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
 	"github.com/piprate/json-gold/ld"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/expiry"
 	"github.com/urfave/cli/v2"
 )
 
@@ -30,7 +33,26 @@ func GenerateAccessKey(c *cli.Context) error {
 		return err
 	}
 
-	ak, err := dataWallet.CreateAccessKey(c.Context, dataWallet.Account().AccessLevel, time.Hour*24*365)
+	expiresAt, err := expiry.FromDateErr(time.Now().UTC(), c.String("expiration"))
+	if err != nil {
+		return cli.Exit(err.Error(), InvalidParameter)
+	}
+
+	var opts []model.AccessKeyOption
+	if !expiresAt.IsZero() {
+		opts = append(opts, model.WithExpiry(expiresAt))
+	}
+	if c.Bool("read-only") {
+		opts = append(opts, model.WithPermission(model.AccessKeyPermissionReadOnly))
+	}
+	if lockers := c.StringSlice("locker"); len(lockers) > 0 {
+		opts = append(opts, model.WithLockerScope(lockers...))
+	}
+	if identities := c.StringSlice("identity"); len(identities) > 0 {
+		opts = append(opts, model.WithIdentityScope(identities...))
+	}
+
+	ak, err := dataWallet.CreateAccessKey(c.Context, dataWallet.Account().AccessLevel, 0, opts...)
 	if err != nil {
 		return err
 	}
@@ -64,11 +86,28 @@ func ListAccessKeys(c *cli.Context) error {
 
 	data := make([][]string, 0)
 	for _, key := range accessKeys {
-		data = append(data, []string{key.ID})
+		expires := "never"
+		if key.ExpiresAt != nil {
+			expires = key.ExpiresAt.Format(time.RFC3339)
+			if key.IsExpired(time.Now()) {
+				expires += " (expired)"
+			}
+		}
+		permission := string(model.AccessKeyPermissionReadWrite)
+		if key.IsReadOnly() {
+			permission = string(model.AccessKeyPermissionReadOnly)
+		}
+		data = append(data, []string{
+			key.ID,
+			expires,
+			permission,
+			strings.Join(key.Lockers, ", "),
+			strings.Join(key.Identities, ", "),
+		})
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ID"})
+	table.SetHeader([]string{"ID", "Expires", "Permission", "Lockers", "Identities"})
 	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
 	table.SetCenterSeparator("|")
 	table.AppendBulk(data) // Add Bulk Data
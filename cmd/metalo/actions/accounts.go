@@ -22,6 +22,7 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/btcsuite/btcd/btcutil/base58"
@@ -32,6 +33,7 @@ import (
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/model/account"
 	"github.com/piprate/metalocker/remote"
+	"github.com/piprate/metalocker/remote/caller"
 	"github.com/piprate/metalocker/storage"
 	"github.com/piprate/metalocker/utils/fingerprint"
 	"github.com/piprate/metalocker/utils/jsonw"
@@ -199,7 +201,7 @@ func NewIdentity(c *cli.Context) error {
 		return cli.Exit("please specify --type parameter", InvalidParameter)
 	}
 
-	did, err := model.GenerateDID()
+	did, err := model.GenerateDID(model.WithMethod(c.String("method")), model.WithWebHost(c.String("web-host")))
 	if err != nil {
 		return cli.Exit(err, OperationFailed)
 	}
@@ -300,7 +302,12 @@ func ImportLocker(c *cli.Context) error {
 		break
 	}
 
-	_, err = dataWallet.AddLocker(c.Context, locker.Perspective(us))
+	if _, err = dataWallet.GetLocker(c.Context, locker.ID); err == nil {
+		// the locker is already registered with the account. Import new participants, if any.
+		_, err = dataWallet.UpdateLocker(c.Context, locker.Perspective(us))
+	} else if errors.Is(err, storage.ErrLockerNotFound) {
+		_, err = dataWallet.AddLocker(c.Context, locker.Perspective(us))
+	}
 	if err != nil {
 		log.Err(err).Msg("Locker import failed")
 		return cli.Exit(err, OperationFailed)
@@ -308,29 +315,58 @@ func ImportLocker(c *cli.Context) error {
 	return err
 }
 
+// parseParticipant parses a locker participant definition in <DID>:<VerKey> format.
+func parseParticipant(val string) (*model.DID, error) {
+	idx := strings.LastIndex(val, ":")
+	if idx <= 0 || idx == len(val)-1 {
+		return nil, fmt.Errorf("invalid participant definition (expected <DID>:<VerKey>): %s", val)
+	}
+	return model.NewDID(val[:idx], val[idx+1:], ""), nil
+}
+
 func CreateLocker(c *cli.Context) error {
 
 	var err error
 
 	issuesFound := false
-	for _, param := range []string{"us", "our-verkey", "them", "their-verkey", "name"} {
+	for _, param := range []string{"us", "our-verkey", "name"} {
 		if c.String(param) == "" {
 			_, _ = fmt.Fprintf(os.Stderr, "Please specify %s parameter.\n", param)
 			issuesFound = true
 		}
 	}
+	if (c.String("them") == "") != (c.String("their-verkey") == "") {
+		_, _ = fmt.Fprintln(os.Stderr, "Please specify both them and their-verkey parameters.")
+		issuesFound = true
+	}
+	if c.String("them") == "" && len(c.StringSlice("participant")) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "Please specify them or participant parameter.")
+		issuesFound = true
+	}
 	if issuesFound {
 		return cli.Exit("invalid parameter(s)", InvalidParameter)
 	}
 
 	did1 := c.String("us")
 	verKey1 := c.String("our-verkey")
-	did2 := c.String("them")
-	verKey2 := c.String("their-verkey")
 	name := c.String("name")
 	months := c.Int("ttl")
 	addToAccount := c.Bool("add")
 
+	parties := []model.PartyOption{
+		model.Us(model.NewDID(did1, verKey1, ""), nil),
+	}
+	if did2 := c.String("them"); did2 != "" {
+		parties = append(parties, model.Them(model.NewDID(did2, c.String("their-verkey"), ""), nil))
+	}
+	for _, val := range c.StringSlice("participant") {
+		did, err := parseParticipant(val)
+		if err != nil {
+			return cli.Exit(err, InvalidParameter)
+		}
+		parties = append(parties, model.Them(did, nil))
+	}
+
 	mlc := CreateHTTPCaller(c)
 
 	controls, err := mlc.GetServerControls(c.Context)
@@ -338,13 +374,8 @@ func CreateLocker(c *cli.Context) error {
 		return err
 	}
 
-	id1 := model.NewDID(did1, verKey1, "")
-	id2 := model.NewDID(did2, verKey2, "")
-
 	expiryTime := time.Now().AddDate(0, months, 0).UTC()
-	locker, err := model.GenerateLocker(model.AccessLevelHosted, name, &expiryTime, controls.TopBlock,
-		model.Us(id1, nil),
-		model.Them(id2, nil))
+	locker, err := model.GenerateLocker(model.AccessLevelHosted, name, &expiryTime, controls.TopBlock, parties...)
 	if err != nil {
 		return err
 	}
@@ -370,6 +401,97 @@ func CreateLocker(c *cli.Context) error {
 	return nil
 }
 
+func AddLockerParticipant(c *cli.Context) error {
+	if c.Args().Len() != 2 {
+		return cli.Exit("please specify locker ID and participant (<DID>:<VerKey>)", InvalidParameter)
+	}
+
+	did, err := parseParticipant(c.Args().Get(1))
+	if err != nil {
+		return cli.Exit(err, InvalidParameter)
+	}
+
+	dataWallet, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	locker, err := dataWallet.GetLocker(c.Context, c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	locker, err = locker.AddParticipant(c.Context, did, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to add locker participant")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	// print the updated locker definition, so that it can be passed
+	// to all participants (see 'metalo locker import').
+	ld.PrintDocument("", locker.Raw())
+
+	return nil
+}
+
+func RotateLockerKeys(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify locker ID", InvalidParameter)
+	}
+
+	dataWallet, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	locker, err := dataWallet.GetLocker(c.Context, c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	locker, err = locker.RotateKeys(c.Context, nil)
+	if err != nil {
+		log.Err(err).Msg("Failed to rotate locker keys")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	// print the updated locker definition, so that it can be passed
+	// to all participants (see 'metalo locker import').
+	ld.PrintDocument("", locker.Raw())
+
+	return nil
+}
+
+func SealLocker(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify locker ID", InvalidParameter)
+	}
+
+	dataWallet, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	locker, err := dataWallet.GetLocker(c.Context, c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	if err = locker.Seal(c.Context); err != nil {
+		log.Err(err).Msg("Failed to seal locker")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	locker, err = dataWallet.GetLocker(c.Context, locker.ID())
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	fmt.Printf("Locker %s sealed at block %d\n", locker.ID(), locker.Raw().LastBlock)
+
+	return nil
+}
+
 func NewAsset(c *cli.Context) error {
 
 	filePath := ""
@@ -506,24 +628,35 @@ func RecoverAccount(c *cli.Context) error {
 	recoveryPhrase := ReadCredential(c.String("rec-phrase"), "Enter recovery phrase: ", true)
 	newPassphrase := ReadCredential(c.String("new-password"), "Enter new account password: ", true)
 
-	recoveryCode, err := mlc.GetAccountRecoveryCode(c.Context, userID)
-	if err != nil {
-		return fmt.Errorf("failed to get recovery code from MetaLocker: %w", err)
-	}
-
 	cryptoKey, _, privKey, err := account.GenerateKeysFromRecoveryPhrase(recoveryPhrase)
 	if err != nil {
 		log.Err(err).Msg("Error generating keys from recovery phrase")
 		return err
 	}
 
-	acct, err := mlc.RecoverAccount(c.Context, userID, privKey, recoveryCode, newPassphrase)
+	return completeAccountRecovery(c, mlc, userID, cryptoKey, privKey, newPassphrase)
+}
+
+// completeAccountRecovery resets the account's password using the keys derived
+// from its recovery phrase and re-encrypts the data wallet with the new password.
+func completeAccountRecovery(c *cli.Context, mlc *caller.MetaLockerHTTPCaller, userID string, cryptoKey *model.AESKey,
+	privKey ed25519.PrivateKey, newPassphrase string) error {
+
+	recoveryCode, err := mlc.GetAccountRecoveryCode(c.Context, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get recovery code from MetaLocker: %w", err)
+	}
+
+	acct, err := recoverAccount(c, mlc, userID, privKey, recoveryCode, newPassphrase)
 	if err != nil {
 		log.Err(err).Msg("Failed to recover account")
 		return fmt.Errorf("failed to recover account: %w", err)
 	}
 
-	err = mlc.LoginWithCredentials(c.Context, userID, newPassphrase)
+	// if MFA is enabled, the code used for recovery can't be reused for login
+	_ = c.Set("otp", "")
+
+	err = LoginWithCredentials(c, mlc, userID, newPassphrase)
 	if err != nil {
 		return err
 	}
@@ -587,7 +720,7 @@ func RecoverAccountSecondLevel(c *cli.Context) error {
 
 	privKey := ed25519.PrivateKey(pkBytes)
 
-	acct, err := mlc.RecoverAccount(c.Context, userID, privKey, recoveryCode, newPassphrase)
+	acct, err := recoverAccount(c, mlc, userID, privKey, recoveryCode, newPassphrase)
 	if err != nil {
 		log.Err(err).Msg("Failed to recover account")
 		return fmt.Errorf("failed to recover account: %w", err)
@@ -610,7 +743,10 @@ func RecoverAccountSecondLevel(c *cli.Context) error {
 		return err
 	}
 
-	err = mlc.LoginWithCredentials(c.Context, userID, newPassphrase)
+	// if MFA is enabled, the code used for recovery can't be reused for login
+	_ = c.Set("otp", "")
+
+	err = LoginWithCredentials(c, mlc, userID, newPassphrase)
 	if err != nil {
 		return err
 	}
@@ -641,13 +777,33 @@ func RecoverAccountSecondLevel(c *cli.Context) error {
 	return nil
 }
 
+// recoverAccount submits an account recovery request. If the account has multi-factor
+// authentication enabled, it asks for a one-time code and retries with a new recovery code.
+func recoverAccount(c *cli.Context, mlc *caller.MetaLockerHTTPCaller, userID string, privKey ed25519.PrivateKey, recoveryCode, newPassphrase string) (*account.Account, error) {
+	otp := c.String("otp")
+	acct, err := mlc.RecoverAccountWithSecondFactor(c.Context, userID, privKey, recoveryCode, newPassphrase, otp)
+	if !errors.Is(err, account.ErrSecondFactorRequired) || otp != "" {
+		return acct, err
+	}
+
+	otp = ReadCredential("", "Enter one-time code or backup code: ", false)
+
+	// the previous recovery code was used up by the failed attempt
+	recoveryCode, err = mlc.GetAccountRecoveryCode(c.Context, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recovery code from MetaLocker: %w", err)
+	}
+
+	return mlc.RecoverAccountWithSecondFactor(c.Context, userID, privKey, recoveryCode, newPassphrase, otp)
+}
+
 func DeleteAccount(c *cli.Context) error {
 	user := ReadCredential(c.String("user"), "Enter account email: ", false)
 	password := ReadCredential(c.String("password"), "Enter password: ", true)
 
 	mlc := CreateHTTPCaller(c)
 
-	err := mlc.LoginWithCredentials(c.Context, user, password)
+	err := LoginWithCredentials(c, mlc, user, password)
 	if err != nil {
 		log.Err(err).Str("user", user).Msg("Authentication failed")
 		return cli.Exit(err, AuthenticationFailed)
@@ -694,19 +850,83 @@ func ListLockers(c *cli.Context) error {
 			if p1 == p2 {
 				p2 = "Self"
 			}
+			if len(l.Participants) > 2 {
+				p2 += fmt.Sprintf(" (+%d)", len(l.Participants)-2)
+			}
 		}
-		var createdStr, expiresStr string
+		var createdStr, expiresStr, sealedStr string
 		if l.Created != nil {
 			createdStr = l.Created.Format(tf)
 		}
 		if l.Expires != nil {
 			expiresStr = l.Expires.Format(tf)
 		}
-		data = append(data, []string{l.ID, l.Name, p1, p2, createdStr, expiresStr})
+		if l.Sealed != nil {
+			sealedStr = fmt.Sprintf("%s (block %d)", l.Sealed.Format(tf), l.LastBlock)
+		}
+		data = append(data, []string{l.ID, l.Name, p1, p2, createdStr, expiresStr, sealedStr})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Name", "Identity1", "Identity2", "Created", "Expires", "Sealed"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.AppendBulk(data) // Add Bulk Data
+	table.Render()
+
+	return nil
+}
+
+func InviteLockerParticipant(c *cli.Context) error {
+	if c.Args().Len() != 2 {
+		return cli.Exit("please specify locker ID and participant DID", InvalidParameter)
+	}
+
+	dataWallet, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	locker, err := dataWallet.GetLocker(c.Context, c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	f := locker.Invite(c.Context, c.Args().Get(1), c.String("message"))
+	if err = f.Wait(time.Minute); err != nil {
+		log.Err(err).Msg("Failed to send locker invitation")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	println(f.ID())
+
+	return nil
+}
+
+func ListLockerInvitations(c *cli.Context) error {
+	dataWallet, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	invitations, err := dataWallet.LockerInvitations(c.Context)
+	if err != nil {
+		log.Err(err).Msg("Failed to read locker invitations")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	tf := "2006-01-02 15:04:05-07:00"
+	data := make([][]string, 0, len(invitations))
+	for _, inv := range invitations {
+		var createdStr string
+		if inv.Created != nil {
+			createdStr = inv.Created.Format(tf)
+		}
+		data = append(data, []string{inv.ID, inv.Locker.Name, inv.Sender, inv.Recipient, inv.Message, createdStr})
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ID", "Name", "Identity1", "Identity2", "Created", "Expires"})
+	table.SetHeader([]string{"ID", "Locker", "From", "To", "Message", "Created"})
 	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
 	table.SetCenterSeparator("|")
 	table.AppendBulk(data) // Add Bulk Data
@@ -715,6 +935,45 @@ func ListLockers(c *cli.Context) error {
 	return nil
 }
 
+func AcceptLockerInvitation(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify invitation ID", InvalidParameter)
+	}
+
+	dataWallet, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	locker, err := dataWallet.AcceptLockerInvitation(c.Context, c.Args().Get(0))
+	if err != nil {
+		log.Err(err).Msg("Failed to accept locker invitation")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	println(locker.ID())
+
+	return nil
+}
+
+func DeclineLockerInvitation(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify invitation ID", InvalidParameter)
+	}
+
+	dataWallet, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	if err = dataWallet.DeclineLockerInvitation(c.Context, c.Args().Get(0)); err != nil {
+		log.Err(err).Msg("Failed to decline locker invitation")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	return nil
+}
+
 func PurgeDeletedDataAssets(c *cli.Context) error {
 	locker := c.String("locker")
 	maxRecords := c.Uint64("max-records")
@@ -26,6 +26,7 @@ import (
 	"github.com/piprate/metalocker/cmd"
 	"github.com/piprate/metalocker/index"
 	"github.com/piprate/metalocker/index/bolt"
+	"github.com/piprate/metalocker/model/account"
 	"github.com/piprate/metalocker/remote"
 	"github.com/piprate/metalocker/remote/caller"
 	"github.com/piprate/metalocker/wallet"
@@ -91,10 +92,18 @@ func LoadRemoteDataWallet(c *cli.Context, syncIndexOnStart bool) (wallet.DataWal
 		// save the password value read from console for ChangePassphrase call
 		_ = c.Set("password", password)
 
-		dw, err = factory.GetWalletWithCredentials(c.Context, user, password)
+		if otp := c.String("otp"); otp != "" {
+			dw, err = factory.GetWalletWithSecondFactor(c.Context, user, password, otp)
+		} else {
+			dw, err = factory.GetWalletWithCredentials(c.Context, user, password)
+			if errors.Is(err, account.ErrSecondFactorRequired) {
+				otp = ReadCredential("", "Enter one-time code: ", false)
+				dw, err = factory.GetWalletWithSecondFactor(c.Context, user, password, otp)
+			}
+		}
 	}
 	if err != nil {
-		if errors.Is(err, caller.ErrLoginFailed) {
+		if errors.Is(err, caller.ErrLoginFailed) || errors.Is(err, account.ErrSecondFactorRequired) {
 			return nil, cli.Exit(err, AuthenticationFailed)
 		} else {
 			return nil, cli.Exit(err, OperationFailed)
@@ -127,6 +136,18 @@ func LoadRemoteDataWallet(c *cli.Context, syncIndexOnStart bool) (wallet.DataWal
 	return dw, nil
 }
 
+// LoginWithCredentials logs the caller in and asks for a one-time code, if the account
+// has multi-factor authentication enabled and the code wasn't provided via 'otp' flag.
+func LoginWithCredentials(c *cli.Context, mlc *caller.MetaLockerHTTPCaller, user, password string) error {
+	otp := c.String("otp")
+	err := mlc.LoginWithSecondFactor(c.Context, user, password, otp)
+	if errors.Is(err, account.ErrSecondFactorRequired) && otp == "" {
+		otp = ReadCredential("", "Enter one-time code: ", false)
+		err = mlc.LoginWithSecondFactor(c.Context, user, password, otp)
+	}
+	return err
+}
+
 func CreateAdminHTTPCaller(c *cli.Context) (*caller.MetaLockerHTTPCaller, error) {
 	mlc := CreateHTTPCaller(c)
 
@@ -0,0 +1,224 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	"github.com/piprate/metalocker/model/vc"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/piprate/metalocker/wallet"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v2"
+)
+
+func getSigningIdentity(c *cli.Context, dw wallet.DataWallet) (wallet.Identity, error) {
+	if iid := c.String("identity"); iid != "" {
+		return dw.GetIdentity(c.Context, iid)
+	} else {
+		return dw.GetRootIdentity(c.Context)
+	}
+}
+
+func writeOutput(c *cli.Context, b []byte) error {
+	if dest := c.String("output"); dest != "" {
+		return os.WriteFile(dest, b, 0o600)
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func IssueCredential(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify the path to the credential subject (JSON)", InvalidParameter)
+	}
+
+	lockerID := c.String("locker")
+	vaultName := c.String("vault")
+	leaseDuration := c.String("expiration")
+	waitForConfirmation := c.Bool("wait")
+
+	if err := checkLeaseDuration(leaseDuration); err != nil {
+		return err
+	}
+
+	subjectBytes, err := os.ReadFile(c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	var subject map[string]any
+	if err = jsonw.Unmarshal(subjectBytes, &subject); err != nil {
+		return cli.Exit(fmt.Errorf("bad credential subject: %w", err), InvalidParameter)
+	}
+
+	opts := []vc.Option{
+		vc.WithID(c.String("id")),
+		vc.WithType(c.StringSlice("type")...),
+	}
+	for _, ctx := range c.StringSlice("context") {
+		opts = append(opts, vc.WithContext(ctx))
+	}
+	if validFor := c.String("valid-for"); validFor != "" {
+		expiryDate, err := expiry.FromDateErr(time.Now(), validFor)
+		if err != nil {
+			return cli.Exit(err, InvalidParameter)
+		}
+		opts = append(opts, vc.WithExpirationDate(expiryDate))
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	idy, err := getSigningIdentity(c, dw)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	cred, err := vc.Issue(idy.DID(), subject, opts...)
+	if err != nil {
+		log.Err(err).Msg("Credential issuance failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	if lockerID == "" {
+		if err = writeOutput(c, cred.Bytes()); err != nil {
+			return cli.Exit(err, OperationFailed)
+		}
+		return nil
+	}
+
+	locker, err := dw.GetLocker(c.Context, lockerID)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	f := vc.Store(c.Context, locker, cred, expiry.FromNow(leaseDuration), dataset.WithVault(vaultName))
+	if waitForConfirmation {
+		err = f.Wait(60 * time.Second)
+	} else {
+		err = f.Error()
+	}
+	if err != nil {
+		log.Err(err).Msg("Credential upload failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	fmt.Println(f.ID())
+
+	return nil
+}
+
+func VerifyCredential(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify the path to the credential file or its record ID", InvalidParameter)
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	var credBytes []byte
+	if c.Bool("record") {
+		ds, err := dw.DataStore().Load(c.Context, extractRecordID(c.Args().Get(0)))
+		if err != nil {
+			return cli.Exit(err, OperationFailed)
+		}
+		credBytes, err = vc.LoadBytes(c.Context, ds)
+		if err != nil {
+			return cli.Exit(err, OperationFailed)
+		}
+	} else {
+		credBytes, err = os.ReadFile(c.Args().Get(0))
+		if err != nil {
+			return cli.Exit(err, OperationFailed)
+		}
+	}
+
+	cred, err := vc.VerifyCredential(c.Context, credBytes, dw.Services().DIDProvider())
+	if err != nil {
+		return cli.Exit(fmt.Errorf("credential verification failed: %w", err), OperationFailed)
+	}
+
+	fmt.Printf("Credential verified. Issuer: %s\n", cred.Issuer)
+
+	return nil
+}
+
+func PresentCredentials(c *cli.Context) error {
+	if c.Args().Len() == 0 {
+		return cli.Exit("please specify record IDs of the credentials to present", InvalidParameter)
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	idy, err := getSigningIdentity(c, dw)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	recordIDs := make([]string, 0, c.Args().Len())
+	for _, id := range c.Args().Slice() {
+		recordIDs = append(recordIDs, extractRecordID(id))
+	}
+
+	vp, err := vc.PresentDataSets(c.Context, dw.DataStore(), recordIDs, idy.DID(),
+		vc.WithChallenge(c.String("challenge")), vc.WithDomain(c.String("domain")))
+	if err != nil {
+		log.Err(err).Msg("Presentation failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	if err = writeOutput(c, vp.Bytes()); err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	return nil
+}
+
+func VerifyPresentation(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify the path to the presentation file", InvalidParameter)
+	}
+
+	vpBytes, err := os.ReadFile(c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	vp, err := vc.VerifyPresentation(c.Context, vpBytes, dw.Services().DIDProvider(), c.String("challenge"))
+	if err != nil {
+		return cli.Exit(fmt.Errorf("presentation verification failed: %w", err), OperationFailed)
+	}
+
+	fmt.Printf("Presentation verified. Holder: %s, credentials: %d\n", vp.Holder, len(vp.VerifiableCredential))
+
+	return nil
+}
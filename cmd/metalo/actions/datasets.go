@@ -15,13 +15,21 @@
 package actions
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/olekukonko/tablewriter"
 	"github.com/piprate/metalocker/cmd/metalo/datatypes"
 	"github.com/piprate/metalocker/cmd/metalo/operations"
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/model/expiry"
+	"github.com/piprate/metalocker/wallet"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 )
@@ -47,6 +55,7 @@ func StoreDataSet(c *cli.Context) error {
 	parentRecordID := extractRecordID(c.String("parent"))
 	metaType := c.String("type")
 	leaseDuration := c.String("expiration")
+	disclosable := c.Bool("disclosable")
 	waitForConfirmation := c.Bool("wait")
 
 	if lockerID == "" {
@@ -63,7 +72,7 @@ func StoreDataSet(c *cli.Context) error {
 	}
 
 	recID, impID, err := operations.StoreDataSet(c.Context, dw.DataStore(), c.Args().Get(0), metaType, vaultName, lockerID, provPath, provMapping,
-		parentRecordID, leaseDuration, waitForConfirmation)
+		parentRecordID, leaseDuration, disclosable, waitForConfirmation)
 	if err != nil {
 		log.Err(err).Msg("Data set upload failed")
 		return cli.Exit(err, OperationFailed)
@@ -155,6 +164,89 @@ func ShareDataSet(c *cli.Context) error {
 	return nil
 }
 
+func DiscloseDataSet(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify the record id to disclose", InvalidParameter)
+	}
+
+	properties := c.StringSlice("property")
+	vaultName := c.String("vault")
+	lockerID := c.String("locker")
+	leaseDuration := c.String("expiration")
+	waitForConfirmation := c.Bool("wait")
+
+	if len(properties) == 0 {
+		return cli.Exit("please specify at least one property to disclose", InvalidParameter)
+	}
+
+	if err := checkLeaseDuration(leaseDuration); err != nil {
+		return err
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	sourceDS, err := dw.DataStore().Load(c.Context, extractRecordID(c.Args().Get(0)))
+	if err != nil {
+		return err
+	}
+
+	locker, err := dw.GetLocker(c.Context, lockerID)
+	if err != nil {
+		return err
+	}
+
+	f := wallet.DiscloseDataSet(c.Context, sourceDS, properties, locker, vaultName, expiry.FromNow(leaseDuration))
+	if waitForConfirmation {
+		err = f.Wait(60 * time.Second)
+	} else {
+		err = f.Error()
+	}
+	if err != nil {
+		log.Err(err).Msg("Data set disclosure failed")
+		return cli.Exit(err, OperationFailed)
+	} else {
+		fmt.Printf("%s\n", f.ID())
+	}
+	return nil
+}
+
+func VerifyDisclosure(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify record id", InvalidParameter)
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	ds, err := dw.DataStore().Load(c.Context, extractRecordID(c.Args().Get(0)))
+	if err != nil {
+		return err
+	}
+
+	proof, err := wallet.VerifyDisclosure(c.Context, dw, ds)
+	if err != nil {
+		log.Err(err).Msg("Disclosure verification failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	names := make([]string, 0, len(proof.Properties))
+	for _, p := range proof.Properties {
+		names = append(names, p.Name)
+	}
+
+	fmt.Printf("Disclosure verified\n")
+	fmt.Printf("Source impression: %s\n", proof.Impression.ID)
+	fmt.Printf("Signed by: %s\n", proof.Impression.WasAttributedTo)
+	fmt.Printf("Disclosed properties: %s\n", strings.Join(names, ", "))
+
+	return nil
+}
+
 func RevokeLease(c *cli.Context) error {
 	if c.Args().Len() != 1 {
 		return cli.Exit("please specify record id", InvalidParameter)
@@ -183,6 +275,42 @@ func RevokeLease(c *cli.Context) error {
 	return nil
 }
 
+func RenewLease(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify record id", InvalidParameter)
+	}
+
+	leaseDuration := c.String("expiration")
+	waitForConfirmation := c.Bool("wait")
+
+	if err := checkLeaseDuration(leaseDuration); err != nil {
+		return err
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	recID := c.Args().Get(0)
+
+	f := dw.DataStore().Renew(c.Context, recID, expiry.FromNow(leaseDuration))
+
+	if waitForConfirmation {
+		err = f.Wait(60 * time.Second)
+	} else {
+		err = f.Error()
+	}
+	if err != nil {
+		log.Err(err).Msg("Lease renewal failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	fmt.Println(f.ID())
+
+	return nil
+}
+
 func GetDataSet(c *cli.Context) error {
 	if c.Args().Len() != 1 {
 		return cli.Exit("please specify record id", InvalidParameter)
@@ -206,6 +334,353 @@ func GetDataSet(c *cli.Context) error {
 	return nil
 }
 
+func ListDataSets(c *cli.Context) error {
+	order := index.SortOrder(c.String("order"))
+	if order != index.SortAscending && order != index.SortDescending {
+		return cli.Exit(fmt.Sprintf("bad sort order: %s. Expected 'asc' or 'desc'", order), InvalidParameter)
+	}
+
+	opts := index.PageOptions{
+		Cursor:   c.String("cursor"),
+		PageSize: c.Int("page-size"),
+	}
+	if opts.Cursor == "" || c.IsSet("order") {
+		// the cursor defines the order of subsequent pages
+		opts.Order = order
+	}
+
+	dw, err := LoadRemoteDataWallet(c, c.Bool("sync"))
+	if err != nil {
+		return err
+	}
+
+	rootIndex, err := dw.RootIndex(c.Context)
+	if err != nil {
+		return err
+	}
+
+	page, err := rootIndex.ListRecords(c.Context, c.String("locker"), "", opts)
+	if err != nil {
+		if errors.Is(err, index.ErrInvalidCursor) {
+			return cli.Exit(err, InvalidParameter)
+		}
+		log.Err(err).Msg("Data set listing failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	data := make([][]string, 0, len(page.Records))
+	for _, r := range page.Records {
+		data = append(data, []string{strconv.FormatInt(r.BlockNumber, 10), r.ID, r.LockerID, r.ImpressionID, r.ContentType, string(r.Status)})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Block", "ID", "Locker", "Impression", "Content Type", "Status"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.AppendBulk(data)
+	table.Render()
+
+	if page.NextCursor != "" {
+		fmt.Printf("\nNext page: --cursor %s\n", page.NextCursor)
+	}
+
+	return nil
+}
+
+func SearchDataSets(c *cli.Context) error {
+	q := &index.SearchQuery{
+		Text:        strings.Join(c.Args().Slice(), " "),
+		ContentType: c.String("type"),
+		LockerID:    c.String("locker"),
+		Limit:       c.Int("limit"),
+	}
+
+	for _, prop := range c.StringSlice("property") {
+		name, val, found := strings.Cut(prop, "=")
+		if !found {
+			return cli.Exit(fmt.Sprintf("bad property filter: %s. Expected format: name=value", prop), InvalidParameter)
+		}
+		if q.Properties == nil {
+			q.Properties = make(map[string]string)
+		}
+		q.Properties[name] = val
+	}
+
+	for _, bound := range []struct {
+		flag string
+		dest **time.Time
+	}{
+		{"after", &q.CreatedAfter},
+		{"before", &q.CreatedBefore},
+	} {
+		if val := c.String(bound.flag); val != "" {
+			ts, err := time.Parse(time.RFC3339, val)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("bad value of --%s (expected RFC3339 timestamp): %s", bound.flag, val), InvalidParameter)
+			}
+			*bound.dest = &ts
+		}
+	}
+
+	dw, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	searchIndex, err := openSearchIndex(c, dw)
+	if err != nil {
+		log.Err(err).Msg("Failed to open search index")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	results, err := searchIndex.Search(c.Context, q)
+	if err != nil {
+		log.Err(err).Msg("Data set search failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	data := make([][]string, 0, len(results))
+	for _, res := range results {
+		var createdAt string
+		if res.CreatedAt != nil {
+			createdAt = res.CreatedAt.Format(time.RFC3339)
+		}
+		data = append(data, []string{res.RecordID, res.LockerID, res.ContentType, createdAt, strconv.Itoa(res.Score)})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Locker", "Content Type", "Created At", "Score"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+// openSearchIndex returns the wallet's search index (creating it in the local index store,
+// if necessary) and syncs it with the ledger, unless --skip-sync flag is set.
+func openSearchIndex(c *cli.Context, dw wallet.DataWallet) (index.SearchIndex, error) {
+	searchIndex, err := dw.SearchIndex(c.Context)
+	if err != nil {
+		if !errors.Is(err, index.ErrIndexNotFound) {
+			return nil, err
+		}
+
+		var opts []index.Option
+		if props := c.StringSlice("index-property"); len(props) > 0 {
+			opts = append(opts, index.WithParameters(&index.SearchIndexParameters{Properties: props}))
+		}
+
+		if _, err = dw.CreateIndex(c.Context, LocalIndexStoreName, index.TypeSearch, opts...); err != nil {
+			return nil, err
+		}
+
+		if searchIndex, err = dw.SearchIndex(c.Context); err != nil {
+			return nil, err
+		}
+	}
+
+	if !c.Bool("skip-sync") {
+		ixUpdater, err := dw.IndexUpdater(c.Context, searchIndex)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = ixUpdater.Close() }()
+
+		if err = ixUpdater.Sync(c.Context); err != nil {
+			return nil, err
+		}
+	}
+
+	return searchIndex, nil
+}
+
+func parseArchiveKey(c *cli.Context) ([]wallet.ArchiveOption, error) {
+	keyStr := c.String("key")
+	if keyStr == "" {
+		return nil, nil
+	}
+	keyBytes, err := base64.StdEncoding.DecodeString(keyStr)
+	if err != nil || len(keyBytes) != 32 {
+		return nil, cli.Exit("bad archive key. Expected Base64-encoded 256-bit key", InvalidParameter)
+	}
+	return []wallet.ArchiveOption{wallet.WithArchiveKey(model.NewAESKey(keyBytes))}, nil
+}
+
+func ExportDataSets(c *cli.Context) error {
+	assetID := c.String("asset")
+	if c.Args().Len() == 0 && assetID == "" {
+		return cli.Exit("please specify record IDs or an asset ID to export", InvalidParameter)
+	}
+
+	dest := c.String("output")
+	if dest == "" {
+		return cli.Exit("please specify the output file", InvalidParameter)
+	}
+
+	opts, err := parseArchiveKey(c)
+	if err != nil {
+		return err
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	var idy wallet.Identity
+	if iid := c.String("identity"); iid != "" {
+		idy, err = dw.GetIdentity(c.Context, iid)
+	} else {
+		idy, err = dw.GetRootIdentity(c.Context)
+	}
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	recordIDs := make([]string, 0, c.Args().Len())
+	for _, id := range c.Args().Slice() {
+		recordIDs = append(recordIDs, extractRecordID(id))
+	}
+	if assetID != "" {
+		revisions, err := wallet.AssetRevisionChain(c.Context, dw, assetID)
+		if err != nil {
+			return cli.Exit(err, OperationFailed)
+		}
+		if len(revisions) == 0 {
+			return cli.Exit(fmt.Sprintf("no data sets found for asset %s", assetID), OperationFailed)
+		}
+		recordIDs = append(recordIDs, revisions...)
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+	defer f.Close()
+
+	manifest, err := wallet.ExportDataSets(c.Context, dw, f, idy.DID(), recordIDs, opts...)
+	if err != nil {
+		log.Err(err).Msg("Data set export failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	fmt.Printf("Exported %d data set(s) into %s\n", len(manifest.DataSets), dest)
+
+	return nil
+}
+
+func ImportDataSets(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify the path to archive", InvalidParameter)
+	}
+
+	vaultName := c.String("vault")
+	lockerID := c.String("locker")
+	leaseDuration := c.String("expiration")
+	waitForConfirmation := c.Bool("wait")
+
+	if err := checkLeaseDuration(leaseDuration); err != nil {
+		return err
+	}
+
+	opts, err := parseArchiveKey(c)
+	if err != nil {
+		return err
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(c.Args().Get(0))
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	opts = append(opts, wallet.WithKeyResolver(wallet.DataWalletKeyResolver(dw)))
+
+	archive, err := wallet.OpenArchive(c.Context, f, fi.Size(), opts...)
+	if err != nil {
+		log.Err(err).Msg("Failed to open archive")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	futures, err := wallet.ImportDataSets(c.Context, dw, archive, lockerID, vaultName, expiry.FromNow(leaseDuration))
+	if err != nil {
+		log.Err(err).Msg("Data set import failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	for _, rf := range futures {
+		if waitForConfirmation {
+			err = rf.Wait(60 * time.Second)
+		} else {
+			err = rf.Error()
+		}
+		if err != nil {
+			log.Err(err).Msg("Data set import failed")
+			return cli.Exit(err, OperationFailed)
+		}
+		fmt.Printf("%s\n", rf.ID())
+	}
+
+	return nil
+}
+
+func ExportProvenance(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify record id", InvalidParameter)
+	}
+
+	format := c.String("format")
+	switch format {
+	case wallet.ProvFormatJSONLD, wallet.ProvFormatProvN, wallet.ProvFormatDOT:
+	default:
+		return cli.Exit(fmt.Sprintf("bad provenance format: %s. Expected 'jsonld', 'provn' or 'dot'", format), InvalidParameter)
+	}
+
+	dw, err := LoadRemoteDataWallet(c, c.Bool("sync"))
+	if err != nil {
+		return err
+	}
+
+	graph, err := wallet.BuildProvenanceGraph(c.Context, dw, extractRecordID(c.Args().Get(0)))
+	if err != nil {
+		log.Err(err).Msg("Provenance graph assembly failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	var buf strings.Builder
+	if err = graph.Export(&buf, format); err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	if err = writeOutput(c, []byte(buf.String())); err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	if unverified := graph.Unverified(); len(unverified) > 0 {
+		for _, n := range unverified {
+			log.Warn().Str("id", n.ID).Str("kind", n.Kind).Str("error", n.VerificationError).
+				Msg("Provenance entity not verified")
+		}
+		if !c.Bool("allow-unverified") {
+			return cli.Exit(fmt.Sprintf("%d provenance entities failed verification", len(unverified)), OperationFailed)
+		}
+	}
+
+	return nil
+}
+
 func ListSupportedDataTypes(c *cli.Context) error {
 	for _, dt := range datatypes.SupportedDataTypes() {
 		println(dt)
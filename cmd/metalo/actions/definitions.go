@@ -171,6 +171,16 @@ var (
 							Name:  "unilocker",
 							Usage: "add unilocker to the new identity",
 						},
+						&cli.StringFlag{
+							Name:  "method",
+							Value: "",
+							Usage: "DID method (default: piprate). Supported values: piprate, key, web",
+						},
+						&cli.StringFlag{
+							Name:  "web-host",
+							Value: "",
+							Usage: "domain (with optional colon-separated path) for did:web identities, e.g. example.com:users:alice",
+						},
 					},
 				},
 				{
@@ -216,6 +226,10 @@ var (
 							Value: "",
 							Usage: "Their VerKey",
 						},
+						&cli.StringSliceFlag{
+							Name:  "participant",
+							Usage: "Additional participant in <DID>:<VerKey> format (can be repeated)",
+						},
 						&cli.StringFlag{
 							Name:  "name",
 							Value: "",
@@ -232,6 +246,54 @@ var (
 						},
 					},
 				},
+				{
+					Name:      "add-participant",
+					Usage:     "add a new participant to an existing locker",
+					ArgsUsage: "<locker ID> <DID>:<VerKey>",
+					Action:    AddLockerParticipant,
+				},
+				{
+					Name:      "rotate-keys",
+					Usage:     "replace your keys for the locker. Records created before the rotation remain readable",
+					ArgsUsage: "<locker ID>",
+					Action:    RotateLockerKeys,
+				},
+				{
+					Name:      "seal",
+					Usage:     "seal the locker. No new records can be added to a sealed locker",
+					ArgsUsage: "<locker ID>",
+					Action:    SealLocker,
+				},
+				{
+					Name:      "invite",
+					Usage:     "send an invitation to join the locker to one of its participants",
+					ArgsUsage: "<locker ID> <DID>",
+					Action:    InviteLockerParticipant,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "message",
+							Value: "",
+							Usage: "Message to the invited participant",
+						},
+					},
+				},
+				{
+					Name:   "invitations",
+					Usage:  "list pending locker invitations",
+					Action: ListLockerInvitations,
+				},
+				{
+					Name:      "accept",
+					Usage:     "accept locker invitation",
+					ArgsUsage: "<invitation ID>",
+					Action:    AcceptLockerInvitation,
+				},
+				{
+					Name:      "decline",
+					Usage:     "decline locker invitation",
+					ArgsUsage: "<invitation ID>",
+					Action:    DeclineLockerInvitation,
+				},
 				{
 					Name:   "import",
 					Usage:  "import locker from file",
@@ -285,6 +347,25 @@ var (
 					Name:   "new",
 					Usage:  "generate new access key",
 					Action: GenerateAccessKey,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "expiration",
+							Value: "1y",
+							Usage: "Key duration (i.e. 10y, 1y6m, 12d, 1h30min, 30s, never)",
+						},
+						&cli.BoolFlag{
+							Name:  "read-only",
+							Usage: "create a read-only access key",
+						},
+						&cli.StringSliceFlag{
+							Name:  "locker",
+							Usage: "restrict the key to the given locker(s)",
+						},
+						&cli.StringSliceFlag{
+							Name:  "identity",
+							Usage: "restrict the key to the given identity(ies)",
+						},
+					},
 				},
 				{
 					Name:   "ls",
@@ -303,6 +384,150 @@ var (
 				},
 			},
 		},
+		{
+			Name:  "mfa",
+			Usage: "commands for multi-factor authentication management",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "enrol",
+					Usage:  "enrol new TOTP second factor (authenticator app)",
+					Action: EnrolSecondFactor,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "name",
+							Value: "",
+							Usage: "Second factor name (i.e. 'phone')",
+						},
+						&cli.StringFlag{
+							Name:  "code",
+							Value: "",
+							Usage: "One-time code from the authenticator app to confirm enrolment",
+						},
+					},
+				},
+				{
+					Name:   "ls",
+					Usage:  "list second factors",
+					Action: ListSecondFactors,
+				},
+				{
+					Name:   "rm",
+					Usage:  "delete second factor",
+					Action: DeleteSecondFactor,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "code",
+							Value: "",
+							Usage: "One-time code or backup code to confirm the operation",
+						},
+					},
+				},
+				{
+					Name:   "backup-codes",
+					Usage:  "generate a new set of backup codes",
+					Action: RegenerateBackupCodes,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "code",
+							Value: "",
+							Usage: "One-time code or backup code to confirm the operation",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "social-recovery",
+			Usage: "commands for account recovery with the help of guardians",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "setup",
+					Usage:  "split the recovery phrase and send the shares to guardians",
+					Action: SetUpSocialRecovery,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "identity",
+							Value: "",
+							Usage: "identity that issues the shares (root identity, if not specified)",
+						},
+						&cli.StringSliceFlag{
+							Name:  "guardian",
+							Usage: "DID of a guardian (can be repeated)",
+						},
+						&cli.IntFlag{
+							Name:  "threshold",
+							Value: 2,
+							Usage: "number of guardians required to recover the account",
+						},
+						&cli.StringFlag{
+							Name:    "rec-phrase",
+							Value:   "",
+							Usage:   "Recovery phrase (received at account creation)",
+							EnvVars: []string{"RECPHRASE"},
+						},
+						&cli.StringFlag{
+							Name:  "vault",
+							Value: "local",
+							Usage: "vault name",
+						},
+						&cli.StringFlag{
+							Name:  "expiration",
+							Value: "10y",
+							Usage: "Share duration (i.e. 10y, 1y6m, 12d, 1h30min, 30s, never)",
+						},
+					},
+				},
+				{
+					Name:   "shares",
+					Usage:  "list recovery shares held as a guardian",
+					Action: ListRecoveryShares,
+				},
+				{
+					Name:      "release",
+					Usage:     "release a recovery share to the recovering user",
+					ArgsUsage: "<record ID>",
+					Action:    ReleaseRecoveryShare,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "request-key",
+							Value: "",
+							Usage: "request key received from the recovering user",
+						},
+					},
+				},
+				{
+					Name:   "request",
+					Usage:  "generate a request key to receive recovery shares from guardians",
+					Action: NewRecoveryRequest,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "key-file",
+							Value: "",
+							Usage: "file to save the request's private key to",
+						},
+					},
+				},
+				{
+					Name:      "recover",
+					Usage:     "recover account with shares released by guardians",
+					ArgsUsage: "<share> [<share>...]",
+					Action:    RecoverAccountWithShares,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "key-file",
+							Value: "",
+							Usage: "file with the request's private key",
+						},
+						&cli.StringFlag{
+							Name:    "new-password",
+							Value:   "",
+							Usage:   "New Password",
+							EnvVars: []string{"NEWPASSWD"},
+						},
+					},
+				},
+			},
+		},
 		{
 			Name:  "sub-account",
 			Usage: "commands for sub-account management",
@@ -420,6 +645,10 @@ var (
 							Value: "1y",
 							Usage: "Lease duration (i.e. 10y, 1y6m, 12d, 1h30min, 30s, never)",
 						},
+						&cli.BoolFlag{
+							Name:  "disclosable",
+							Usage: "If specified, properties of the data set's metadata can be selectively disclosed later",
+						},
 						&cli.BoolFlag{
 							Name:  "wait",
 							Usage: "If specified, wait until the data is published on the ledger",
@@ -492,6 +721,99 @@ var (
 						},
 					},
 				},
+				{
+					Name:      "disclose",
+					Usage:     "share a derived data set that reveals only the selected metadata properties",
+					ArgsUsage: "<record ID>",
+					Action:    DiscloseDataSet,
+					Flags: []cli.Flag{
+						&cli.StringSliceFlag{
+							Name:  "property",
+							Usage: "Top-level metadata property to disclose. May be repeated",
+						},
+						&cli.StringFlag{
+							Name:  "vault",
+							Value: "local",
+							Usage: "Vault Name (default: local)",
+						},
+						&cli.StringFlag{
+							Name:  "locker",
+							Value: "",
+							Usage: "Locker ID",
+						},
+						&cli.StringFlag{
+							Name:  "expiration",
+							Value: "1y",
+							Usage: "Lease duration (i.e. 10y, 1y6m, 12d, 1h30min, 30s, never)",
+						},
+						&cli.BoolFlag{
+							Name:  "wait",
+							Usage: "If specified, wait until the data is published on the ledger",
+						},
+					},
+				},
+				{
+					Name:      "verify-disclosure",
+					Usage:     "verify that the data set's metadata was disclosed from a signed data set",
+					ArgsUsage: "<record ID>",
+					Action:    VerifyDisclosure,
+				},
+				{
+					Name:      "export",
+					Usage:     "export data sets into a signed archive",
+					ArgsUsage: "[record IDs]",
+					Action:    ExportDataSets,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "asset",
+							Usage: "Asset ID. If specified, all revisions of the asset will be exported",
+						},
+						&cli.StringFlag{
+							Name:  "identity",
+							Usage: "ID of the identity that will sign the archive. If not specified, the root identity will be used",
+						},
+						&cli.StringFlag{
+							Name:  "key",
+							Usage: "Base64-encoded 256-bit key to encrypt the archive contents. If not specified, the contents will be stored in cleartext",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "path to the archive file",
+						},
+					},
+				},
+				{
+					Name:      "import",
+					Usage:     "verify a data set archive and import its data sets into a locker",
+					ArgsUsage: "<archive>",
+					Action:    ImportDataSets,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "vault",
+							Value: "local",
+							Usage: "Vault Name (default: local)",
+						},
+						&cli.StringFlag{
+							Name:  "locker",
+							Value: "",
+							Usage: "Locker ID",
+						},
+						&cli.StringFlag{
+							Name:  "key",
+							Usage: "Base64-encoded 256-bit key to decrypt the archive contents",
+						},
+						&cli.StringFlag{
+							Name:  "expiration",
+							Value: "1y",
+							Usage: "Lease duration (i.e. 10y, 1y6m, 12d, 1h30min, 30s, never)",
+						},
+						&cli.BoolFlag{
+							Name:  "wait",
+							Usage: "If specified, wait until the data is published on the ledger",
+						},
+					},
+				},
 				{
 					Name:   "get",
 					Usage:  "get data set from MetaLocker",
@@ -512,6 +834,104 @@ var (
 						},
 					},
 				},
+				{
+					Name:      "provenance",
+					Usage:     "export revision and sharing provenance of the data set's asset",
+					ArgsUsage: "<record ID>",
+					Action:    ExportProvenance,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Value: "jsonld",
+							Usage: "Output format: 'jsonld' (PROV-JSON-LD), 'provn' (PROV-N) or 'dot' (Graphviz)",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "path to the output file. If not specified, the result will be printed out on the console",
+						},
+						&cli.BoolFlag{
+							Name:  "allow-unverified",
+							Usage: "don't fail if some provenance signatures can't be verified",
+						},
+						&cli.BoolFlag{
+							Name:  "sync",
+							Usage: "sync data wallet before building the provenance graph",
+						},
+					},
+				},
+				{
+					Name:   "ls",
+					Usage:  "list data sets in the wallet, page by page",
+					Action: ListDataSets,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "locker",
+							Value: "",
+							Usage: "Locker ID. If not specified, data sets from all lockers will be displayed",
+						},
+						&cli.StringFlag{
+							Name:  "cursor",
+							Usage: "Cursor returned with the previous page",
+						},
+						&cli.IntFlag{
+							Name:  "page-size",
+							Value: 20,
+							Usage: "Number of data sets per page",
+						},
+						&cli.StringFlag{
+							Name:  "order",
+							Value: "asc",
+							Usage: "Sort order by block number: 'asc' or 'desc'",
+						},
+						&cli.BoolFlag{
+							Name:  "sync",
+							Usage: "sync data wallet before displaying its contents",
+						},
+					},
+				},
+				{
+					Name:      "search",
+					Usage:     "search data sets by their metadata",
+					ArgsUsage: "[free text query]",
+					Action:    SearchDataSets,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "type",
+							Usage: "Content type of the data set",
+						},
+						&cli.StringSliceFlag{
+							Name:  "property",
+							Usage: "Property value to match (format: name=value). May be repeated",
+						},
+						&cli.StringFlag{
+							Name:  "locker",
+							Value: "",
+							Usage: "Locker ID. If not specified, data sets from all lockers will be searched",
+						},
+						&cli.StringFlag{
+							Name:  "after",
+							Usage: "Only include data sets created after the given time (RFC3339)",
+						},
+						&cli.StringFlag{
+							Name:  "before",
+							Usage: "Only include data sets created before the given time (RFC3339)",
+						},
+						&cli.IntFlag{
+							Name:  "limit",
+							Value: 20,
+							Usage: "Maximum number of results to be displayed",
+						},
+						&cli.StringSliceFlag{
+							Name:  "index-property",
+							Usage: "Property to index as a searchable field, if the search index doesn't exist yet. May be repeated",
+						},
+						&cli.BoolFlag{
+							Name:  "skip-sync",
+							Usage: "don't sync the search index with the ledger before searching",
+						},
+					},
+				},
 				{
 					Name:   "revoke",
 					Usage:  "revoke data set's lease",
@@ -523,6 +943,128 @@ var (
 						},
 					},
 				},
+				{
+					Name:   "renew",
+					Usage:  "extend the expiry time of data set's lease",
+					Action: RenewLease,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "expiration",
+							Value: "1y",
+							Usage: "New lease duration, from now (i.e. 10y, 1y6m, 12d, 1h30min, 30s)",
+						},
+						&cli.BoolFlag{
+							Name:  "wait",
+							Usage: "If specified, wait until the data is published on the ledger",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:  "vc",
+			Usage: "commands for verifiable credentials",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "issue",
+					Usage:     "issue a verifiable credential for the given subject",
+					ArgsUsage: "<subject.json>",
+					Action:    IssueCredential,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "identity",
+							Usage: "ID of the issuer identity. If not specified, the root identity will be used",
+						},
+						&cli.StringFlag{
+							Name:  "id",
+							Usage: "Credential ID (optional)",
+						},
+						&cli.StringSliceFlag{
+							Name:  "type",
+							Usage: "Additional credential type (can be specified multiple times)",
+						},
+						&cli.StringSliceFlag{
+							Name:  "context",
+							Usage: "Additional JSON-LD context URL (can be specified multiple times)",
+						},
+						&cli.StringFlag{
+							Name:  "valid-for",
+							Usage: "Credential validity period (i.e. 10y, 1y6m, 12d). If not specified, the credential doesn't expire",
+						},
+						&cli.StringFlag{
+							Name:  "locker",
+							Usage: "Locker ID. If specified, the credential will be stored as a dataset in the locker",
+						},
+						&cli.StringFlag{
+							Name:  "vault",
+							Value: "local",
+							Usage: "Vault Name (default: local)",
+						},
+						&cli.StringFlag{
+							Name:  "expiration",
+							Value: "1y",
+							Usage: "Lease duration (i.e. 10y, 1y6m, 12d, 1h30min, 30s, never)",
+						},
+						&cli.BoolFlag{
+							Name:  "wait",
+							Usage: "If specified, wait until the data is published on the ledger",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "path to the credential file (if the credential isn't stored in a locker)",
+						},
+					},
+				},
+				{
+					Name:      "verify",
+					Usage:     "verify a verifiable credential",
+					ArgsUsage: "<credential.json|record ID>",
+					Action:    VerifyCredential,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "record",
+							Usage: "If specified, the argument is a record ID of the dataset with the credential",
+						},
+					},
+				},
+				{
+					Name:      "present",
+					Usage:     "build a verifiable presentation from the credentials stored in the given datasets",
+					ArgsUsage: "[record IDs]",
+					Action:    PresentCredentials,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "identity",
+							Usage: "ID of the holder identity. If not specified, the root identity will be used",
+						},
+						&cli.StringFlag{
+							Name:  "challenge",
+							Usage: "Challenge provided by the verifier",
+						},
+						&cli.StringFlag{
+							Name:  "domain",
+							Usage: "Domain of the verifier",
+						},
+						&cli.StringFlag{
+							Name:    "output",
+							Aliases: []string{"o"},
+							Usage:   "path to the presentation file",
+						},
+					},
+				},
+				{
+					Name:      "verify-presentation",
+					Usage:     "verify a verifiable presentation and all its credentials",
+					ArgsUsage: "<presentation.json>",
+					Action:    VerifyPresentation,
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "challenge",
+							Usage: "Expected challenge",
+						},
+					},
+				},
 			},
 		},
 		{
@@ -652,6 +1194,23 @@ var (
 				},
 			},
 		},
+		{
+			Name:  "ledger",
+			Usage: "ledger operations",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "verify",
+					Usage:  "verify integrity of the MetaLocker ledger",
+					Action: VerifyLedger,
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  "client-side",
+							Usage: "walk the ledger and verify it on the client side instead of the server",
+						},
+					},
+				},
+			},
+		},
 		{
 			Name:   "export-accounts",
 			Usage:  "export MetaLocker accounts into the given directory",
@@ -40,6 +40,11 @@ var (
 			Usage:   "account password",
 			EnvVars: []string{"METAPASS"},
 		},
+		&cli.StringFlag{
+			Name:  "otp",
+			Value: "",
+			Usage: "one-time code or backup code (for accounts with multi-factor authentication)",
+		},
 	}
 
 	APIKeyFlags = []cli.Flag{
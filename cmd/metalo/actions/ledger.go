@@ -15,9 +15,12 @@
 package actions
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/piprate/json-gold/ld"
 	"github.com/piprate/metalocker/cmd/metalo/operations"
+	"github.com/piprate/metalocker/ledger"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 )
@@ -67,3 +70,37 @@ func ImportLedger(c *cli.Context) error {
 	}
 	return nil
 }
+
+type ledgerVerifier interface {
+	VerifyLedger(ctx context.Context) (*ledger.VerificationReport, error)
+}
+
+func VerifyLedger(c *cli.Context) error {
+	dw, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	var report *ledger.VerificationReport
+	if c.Bool("client-side") {
+		report, err = ledger.VerifyLedger(c.Context, dw.Services().Ledger())
+	} else {
+		lv, ok := dw.Services().Ledger().(ledgerVerifier)
+		if !ok {
+			return cli.Exit("server-side ledger verification not supported", OperationFailed)
+		}
+		report, err = lv.VerifyLedger(c.Context)
+	}
+	if err != nil {
+		log.Err(err).Msg("Ledger verification failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	ld.PrintDocument("", report)
+
+	if !report.Valid {
+		return cli.Exit("ledger integrity check failed", OperationFailed)
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/remote/caller"
+	"github.com/urfave/cli/v2"
+)
+
+func loginForSecondFactorManagement(c *cli.Context) (*caller.MetaLockerHTTPCaller, error) {
+	user := ReadCredential(c.String("user"), "Enter account email: ", false)
+	password := ReadCredential(c.String("password"), "Enter password: ", true)
+
+	mlc := CreateHTTPCaller(c)
+
+	if err := LoginWithCredentials(c, mlc, user, password); err != nil {
+		return nil, cli.Exit(err, AuthenticationFailed)
+	}
+
+	return mlc, nil
+}
+
+func EnrolSecondFactor(c *cli.Context) error {
+	mlc, err := loginForSecondFactorManagement(c)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := mlc.EnrolSecondFactor(c.Context, c.String("name"))
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	fmt.Printf("Add this key to your authenticator app:\n\n")
+	fmt.Printf("Secret: %s\n", rsp.Secret)
+	fmt.Printf("URI:    %s\n\n", rsp.URI)
+
+	code := ReadCredential(c.String("code"), "Enter one-time code from the app to confirm: ", false)
+
+	backupCodes, err := mlc.ConfirmSecondFactor(c.Context, rsp.Factor.ID, code)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	fmt.Printf("Second factor %s confirmed.\n", rsp.Factor.ID)
+
+	printBackupCodes(backupCodes)
+
+	return nil
+}
+
+func ListSecondFactors(c *cli.Context) error {
+	mlc, err := loginForSecondFactorManagement(c)
+	if err != nil {
+		return err
+	}
+
+	factors, err := mlc.ListSecondFactors(c.Context)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	sort.Slice(factors, func(i, j int) bool {
+		return factors[i].ID < factors[j].ID
+	})
+
+	data := make([][]string, 0)
+	for _, f := range factors {
+		if f.Type == account.SecondFactorBackupCodes {
+			continue
+		}
+		created := ""
+		if f.CreatedAt != nil {
+			created = f.CreatedAt.Format(time.RFC3339)
+		}
+		status := "pending"
+		if f.Confirmed {
+			status = "active"
+		}
+		data = append(data, []string{
+			f.ID,
+			f.Type,
+			f.Name,
+			status,
+			created,
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "Type", "Name", "Status", "Created"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.AppendBulk(data) // Add Bulk Data
+	table.Render()
+
+	return nil
+}
+
+func DeleteSecondFactor(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify second factor ID", InvalidParameter)
+	}
+
+	mlc, err := loginForSecondFactorManagement(c)
+	if err != nil {
+		return err
+	}
+
+	// the code used for login can't be reused
+	code := ReadCredential(c.String("code"), "Enter one-time code or backup code: ", false)
+
+	err = mlc.DeleteSecondFactor(c.Context, c.Args().Get(0), code)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	return nil
+}
+
+func RegenerateBackupCodes(c *cli.Context) error {
+	mlc, err := loginForSecondFactorManagement(c)
+	if err != nil {
+		return err
+	}
+
+	// the code used for login can't be reused
+	code := ReadCredential(c.String("code"), "Enter one-time code or backup code: ", false)
+
+	backupCodes, err := mlc.RegenerateBackupCodes(c.Context, code)
+	if err != nil {
+		if errors.Is(err, account.ErrInvalidSecondFactor) {
+			return cli.Exit(err, AuthenticationFailed)
+		}
+		return cli.Exit(err, OperationFailed)
+	}
+
+	printBackupCodes(backupCodes)
+
+	return nil
+}
+
+func printBackupCodes(codes []string) {
+	if len(codes) == 0 {
+		return
+	}
+
+	fmt.Printf("\nBackup codes (each code can be used once, store them in a safe place):\n\n")
+	for _, code := range codes {
+		fmt.Printf("    %s\n", code)
+	}
+}
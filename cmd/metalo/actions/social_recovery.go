@@ -0,0 +1,209 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package actions
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/olekukonko/tablewriter"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	"github.com/piprate/metalocker/wallet"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v2"
+)
+
+func SetUpSocialRecovery(c *cli.Context) error {
+	guardians := c.StringSlice("guardian")
+	threshold := c.Int("threshold")
+	vaultName := c.String("vault")
+	leaseDuration := c.String("expiration")
+
+	if len(guardians) == 0 {
+		return cli.Exit("please specify at least one guardian", InvalidParameter)
+	}
+
+	if err := checkLeaseDuration(leaseDuration); err != nil {
+		return err
+	}
+
+	dw, err := LoadRemoteDataWallet(c, false)
+	if err != nil {
+		return err
+	}
+
+	issuer, err := getSigningIdentity(c, dw)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	recoveryPhrase := ReadCredential(c.String("rec-phrase"), "Enter recovery phrase: ", true)
+
+	setup, err := wallet.SetUpSocialRecovery(c.Context, dw, issuer, recoveryPhrase, guardians, threshold,
+		expiry.FromNow(leaseDuration), dataset.WithVault(vaultName))
+	if err != nil {
+		log.Err(err).Msg("Social recovery setup failed")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	for _, d := range setup.Deliveries {
+		if err = d.Share.Wait(time.Minute); err != nil {
+			return cli.Exit(err, OperationFailed)
+		}
+		if err = d.Invitation.Wait(time.Minute); err != nil {
+			return cli.Exit(err, OperationFailed)
+		}
+		fmt.Printf("Share delivered to %s (locker %s)\n", d.Guardian, d.LockerID)
+	}
+
+	fmt.Printf("Recovery set %s created. Any %d of %d guardians can help to recover the account.\n",
+		setup.SetID, setup.Threshold, len(setup.Deliveries))
+
+	return nil
+}
+
+func ListRecoveryShares(c *cli.Context) error {
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	shares, err := wallet.GuardianRecoveryShares(c.Context, dw)
+	if err != nil {
+		log.Err(err).Msg("Failed to read recovery shares")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	tf := "2006-01-02 15:04:05-07:00"
+	data := make([][]string, 0, len(shares))
+	for _, hs := range shares {
+		var createdStr string
+		if hs.Share.Created != nil {
+			createdStr = hs.Share.Created.Format(tf)
+		}
+		data = append(data, []string{
+			hs.RecordID,
+			hs.Share.UserID,
+			hs.Share.Issuer,
+			hs.Share.SetID,
+			strconv.Itoa(hs.Share.Threshold) + " of " + strconv.Itoa(hs.Share.Total),
+			createdStr,
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Record ID", "Account", "Issuer", "Set", "Threshold", "Created"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.AppendBulk(data) // Add Bulk Data
+	table.Render()
+
+	return nil
+}
+
+func ReleaseRecoveryShare(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("please specify the record ID of the recovery share", InvalidParameter)
+	}
+
+	requestKey := base58.Decode(c.String("request-key"))
+	if len(requestKey) != ed25519.PublicKeySize {
+		return cli.Exit("please specify a valid request key", InvalidParameter)
+	}
+
+	dw, err := LoadRemoteDataWallet(c, true)
+	if err != nil {
+		return err
+	}
+
+	sealedShare, err := wallet.ReleaseRecoveryShare(c.Context, dw, extractRecordID(c.Args().Get(0)), requestKey)
+	if err != nil {
+		log.Err(err).Msg("Failed to release recovery share")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	fmt.Println(base58.Encode(sealedShare))
+
+	return nil
+}
+
+func NewRecoveryRequest(c *cli.Context) error {
+	keyFile := c.String("key-file")
+	if keyFile == "" {
+		return cli.Exit("please specify the key file", InvalidParameter)
+	}
+
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	if err = os.WriteFile(keyFile, []byte(base58.Encode(privKey)), 0o600); err != nil {
+		return cli.Exit(err, OperationFailed)
+	}
+
+	fmt.Printf("Send this request key to your guardians:\n\n%s\n", base58.Encode(pubKey))
+
+	return nil
+}
+
+func RecoverAccountWithShares(c *cli.Context) error {
+	if c.Args().Len() == 0 {
+		return cli.Exit("please specify the recovery shares received from guardians", InvalidParameter)
+	}
+
+	keyFileContents, err := os.ReadFile(c.String("key-file"))
+	if err != nil {
+		return cli.Exit(err, InvalidParameter)
+	}
+	requestPrivKey := ed25519.PrivateKey(base58.Decode(strings.TrimSpace(string(keyFileContents))))
+	if len(requestPrivKey) != ed25519.PrivateKeySize {
+		return cli.Exit("invalid key file", InvalidParameter)
+	}
+
+	shares := make([]*account.RecoveryShare, 0, c.Args().Len())
+	for _, val := range c.Args().Slice() {
+		s, err := account.OpenRecoveryShare(base58.Decode(val), requestPrivKey)
+		if err != nil {
+			return cli.Exit(err, InvalidParameter)
+		}
+		shares = append(shares, s)
+	}
+
+	cryptoKey, _, privKey, err := account.RecoveryKeysFromShares(shares)
+	if err != nil {
+		log.Err(err).Msg("Error generating keys from recovery shares")
+		return cli.Exit(err, OperationFailed)
+	}
+
+	userID := c.String("user")
+	if userID == "" {
+		userID = shares[0].UserID
+	}
+
+	newPassphrase := ReadCredential(c.String("new-password"), "Enter new account password: ", true)
+
+	mlc := CreateHTTPCaller(c)
+
+	return completeAccountRecovery(c, mlc, userID, cryptoKey, privKey, newPassphrase)
+}
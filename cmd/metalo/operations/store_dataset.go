@@ -29,29 +29,27 @@ import (
 )
 
 func StoreDataSet(ctx context.Context, lib wallet.DataStore, path, metaType, vaultName, lockerID, provPath, provMapping, parentRecordID string,
-	durationString string, waitForConfirmation bool) (string, string, error) {
+	durationString string, disclosable, waitForConfirmation bool) (string, string, error) {
 
 	provMap, err := utils.BuildMapFromString(provMapping)
 	if err != nil {
 		return "", "", err
 	}
 
-	var builder dataset.Builder
+	opts := []dataset.BuilderOption{dataset.WithVault(vaultName)}
 	if parentRecordID != "" {
-		builder, err = lib.NewDataSetBuilder(ctx,
-			lockerID,
-			dataset.WithVault(vaultName),
-			dataset.WithParent(
-				parentRecordID,
-				"",
-				dataset.CopyModeNone,
-				nil,
-				false),
-		)
-	} else {
-		builder, err = lib.NewDataSetBuilder(ctx, lockerID, dataset.WithVault(vaultName))
+		opts = append(opts, dataset.WithParent(
+			parentRecordID,
+			"",
+			dataset.CopyModeNone,
+			nil,
+			false))
+	}
+	if disclosable {
+		opts = append(opts, dataset.WithSelectiveDisclosure())
 	}
 
+	builder, err := lib.NewDataSetBuilder(ctx, lockerID, opts...)
 	if err != nil {
 		return "", "", err
 	}
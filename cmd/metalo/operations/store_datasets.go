@@ -38,7 +38,7 @@ func StoreDataSets(ctx context.Context, lib wallet.DataStore, path, metaType, va
 			log.Debug().Str("path", f.Name()).Msg("Importing dataset")
 			dsPath := filepath.Join(path, f.Name())
 			recID, impID, err := StoreDataSet(ctx, lib, dsPath, metaType, vaultName, lockerID, provPath,
-				provMapping, "", durationString, waitForConfirmation)
+				provMapping, "", durationString, false, waitForConfirmation)
 			if err != nil {
 				return nil, err
 			}
@@ -39,6 +39,7 @@ func PreloadContextsIntoMemory() error {
 			"https://w3id.org/security/v1":                  "files/security-v1.jsonld",
 			"https://w3id.org/security/v2":                  "files/security-v2.jsonld",
 			"https://w3id.org/did/v1":                       "files/did-v1.jsonld",
+			"https://www.w3.org/2018/credentials/v1":        "files/credentials-v1.jsonld",
 		} {
 			b, err := contextFS.ReadFile(boxPath)
 			if err != nil {
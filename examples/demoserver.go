@@ -28,6 +28,7 @@ import (
 	_ "github.com/piprate/metalocker/ledger/local"
 	"github.com/piprate/metalocker/node"
 	"github.com/piprate/metalocker/remote/caller"
+	_ "github.com/piprate/metalocker/sdk/apibase/boltthrottle"
 	_ "github.com/piprate/metalocker/storage/memory"
 	_ "github.com/piprate/metalocker/vaults/fs"
 	_ "github.com/piprate/metalocker/vaults/memory"
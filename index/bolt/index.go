@@ -65,11 +65,15 @@ func (dwi *Index) Lock() {
 }
 
 func (dwi *Index) indexBucket(tx *bbolt.Tx, bucketID string) *bbolt.Bucket {
-	b := tx.Bucket([]byte(dwi.id))
+	return indexBucket(tx, dwi.id, bucketID)
+}
+
+func indexBucket(tx *bbolt.Tx, indexID, bucketID string) *bbolt.Bucket {
+	b := tx.Bucket([]byte(indexID))
 	if b != nil {
 		b = b.Bucket([]byte(bucketID))
 	} else {
-		log.Warn().Str("id", dwi.id).Msg("Index bucket not found in Bolt data wallet")
+		log.Warn().Str("id", indexID).Msg("Index bucket not found in Bolt data wallet")
 	}
 	return b
 }
@@ -130,6 +134,7 @@ func (dwi *Index) AddLease(ctx context.Context, ds model.DataSet, effectiveBlock
 			Index:         r.KeyIndex,
 			ImpressionID:  impID,
 			ContentType:   contentType,
+			ExpiresAt:     r.ExpiresAt,
 		}
 
 		as := &index.AssetState{
@@ -177,6 +182,10 @@ func (dwi *Index) AddLease(ctx context.Context, ds model.DataSet, effectiveBlock
 				return err
 			}
 
+			if err = putRecordOrder(tx, dwi.id, rs); err != nil {
+				return err
+			}
+
 			// update record lookup
 
 			rlb = dwi.indexBucket(tx, RecordLookupKey)
@@ -241,6 +250,10 @@ func (dwi *Index) AddLease(ctx context.Context, ds model.DataSet, effectiveBlock
 				return err
 			}
 
+			if err = putAssetRecordOrder(tx, dwi.id, as.AssetID, r.ID, blockNumber); err != nil {
+				return err
+			}
+
 			// update variant lookup
 
 			vb := dwi.indexBucket(tx, VariantsKey)
@@ -257,7 +270,7 @@ func (dwi *Index) AddLease(ctx context.Context, ds model.DataSet, effectiveBlock
 				return err
 			}
 
-			return nil
+			return updateVariantOrder(tx, dwi.id, lease.Impression.GetVariantID())
 		}); err != nil {
 			return err
 		}
@@ -387,6 +400,19 @@ func (dwi *Index) AddLeaseRevocation(ctx context.Context, ds model.DataSet) erro
 			if err != nil {
 				return err
 			}
+
+			aob := dwi.indexBucket(tx, AssetOrderKey)
+			if aob == nil {
+				return fmt.Errorf("bucket %s not found", AssetOrderKey)
+			}
+
+			subjKey := orderKey(subjRS.BlockNumber, r.SubjectRecord)
+			err = aob.ForEach(func(assetID, empty []byte) error {
+				return aob.Bucket(assetID).Delete(subjKey)
+			})
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -397,14 +423,92 @@ func (dwi *Index) AddLeaseRevocation(ctx context.Context, ds model.DataSet) erro
 	return nil
 }
 
+func (dwi *Index) AddLeaseRenewal(ctx context.Context, ds model.DataSet) error {
+	defer measure.ExecTime("index.AddLeaseRenewal")()
+
+	r := ds.Record()
+	lockerID := ds.LockerID()
+	participantID := ds.ParticipantID()
+	blockNumber := ds.BlockNumber()
+
+	rs := &index.RecordState{
+		ID:            r.ID,
+		Operation:     r.Operation,
+		Status:        model.StatusPublished,
+		LockerID:      lockerID,
+		ParticipantID: participantID,
+		BlockNumber:   blockNumber,
+		Index:         r.KeyIndex,
+		ExpiresAt:     r.ExpiresAt,
+	}
+
+	return dwi.client.DB.Update(func(tx *bbolt.Tx) error {
+
+		// add the renewal record to record lookup
+
+		rlb := dwi.indexBucket(tx, RecordLookupKey)
+		if rlb == nil {
+			return fmt.Errorf("bucket %s not found", RecordLookupKey)
+		}
+		err := rlb.Put([]byte(rs.ID), rs.Bytes())
+		if err != nil {
+			return err
+		}
+
+		// update subject record state (locker participant)
+
+		rb := dwi.indexBucket(tx, RecordsKey)
+		if rb == nil {
+			return fmt.Errorf("bucket %s not found", RecordsKey)
+		}
+		lb := rb.Bucket([]byte(lockerID))
+		if lb == nil {
+			return fmt.Errorf("lease renewal failed for %s: source locker not found: %s", r.SubjectRecord, lockerID)
+		}
+		lpb := lb.Bucket([]byte(participantID))
+		if lpb == nil {
+			return fmt.Errorf("lease renewal failed for %s: source participant not found: %s", r.SubjectRecord, participantID)
+		}
+
+		subj := lpb.Get([]byte(r.SubjectRecord))
+		if subj == nil {
+			// subject record wasn't saved in the wallet
+			return nil
+		}
+
+		var subjRS index.RecordState
+		if err = jsonw.Unmarshal(subj, &subjRS); err != nil {
+			return err
+		}
+
+		if subjRS.Status != model.StatusPublished || subjRS.ExpiresAt >= r.ExpiresAt {
+			return nil
+		}
+
+		subjRS.ExpiresAt = r.ExpiresAt
+
+		if err = lpb.Put([]byte(r.SubjectRecord), subjRS.Bytes()); err != nil {
+			return err
+		}
+
+		// update subject record lookup
+
+		return rlb.Put([]byte(r.SubjectRecord), subjRS.Bytes())
+	})
+}
+
 func (dwi *Index) UpdateTopBlock(ctx context.Context, blockNumber int64) error {
+	return updateTopBlock(dwi.client, dwi.id, blockNumber)
+}
+
+func updateTopBlock(client *utils.BoltClient, indexID string, blockNumber int64) error {
 	if blockNumber <= 0 {
 		return errors.New("no block ID provided when updating locker stats " +
 			"(maybe there were no new blocks processed?)")
 	}
 
-	return dwi.client.DB.Update(func(tx *bbolt.Tx) error {
-		b := dwi.indexBucket(tx, LockersKey)
+	return client.DB.Update(func(tx *bbolt.Tx) error {
+		b := indexBucket(tx, indexID, LockersKey)
 		if b == nil {
 			return fmt.Errorf("bucket %s not found", LockersKey)
 		}
@@ -544,36 +648,8 @@ func (dwi *Index) TraverseVariants(ctx context.Context, lockerFilter, participan
 		}
 
 		err := vb.ForEach(func(variantID, empty []byte) error {
-			var hist []*index.VariantRecordState
-			var masterRec *index.VariantRecordState
-			var maxRevision int64 = -1
-			var maxCreatedAt *time.Time = nil
-
-			if err := vb.Bucket(variantID).ForEach(func(recID, v []byte) error {
-				var rs index.VariantRecordState
-				err := jsonw.Unmarshal(v, &rs)
-				if err != nil {
-					return err
-				}
-
-				if lockerFilter != "" && rs.LockerID != lockerFilter {
-					return nil
-				}
-				if participantFilter != "" && rs.ParticipantID != participantFilter {
-					return nil
-				}
-
-				if rs.RevisionNumber > maxRevision || (maxCreatedAt != nil && rs.RevisionNumber == maxRevision && rs.CreatedAt.After(*maxCreatedAt)) {
-					masterRec = &rs
-					maxRevision = rs.RevisionNumber
-				}
-
-				if includeHistory {
-					hist = append(hist, &rs)
-				}
-
-				return nil
-			}); err != nil {
+			masterRec, hist, err := variantMaster(vb.Bucket(variantID), lockerFilter, participantFilter, includeHistory)
+			if err != nil {
 				return err
 			}
 
@@ -591,6 +667,47 @@ func (dwi *Index) TraverseVariants(ctx context.Context, lockerFilter, participan
 	return err
 }
 
+// variantMaster returns the variant's record with the highest revision number that
+// passes the filters and, if requested, all the variant's records that pass the filters.
+func variantMaster(vvb *bbolt.Bucket, lockerFilter, participantFilter string, includeHistory bool) (*index.VariantRecordState,
+	[]*index.VariantRecordState, error) {
+
+	var hist []*index.VariantRecordState
+	var masterRec *index.VariantRecordState
+	var maxRevision int64 = -1
+	var maxCreatedAt *time.Time = nil
+
+	if err := vvb.ForEach(func(recID, v []byte) error {
+		var rs index.VariantRecordState
+		err := jsonw.Unmarshal(v, &rs)
+		if err != nil {
+			return err
+		}
+
+		if lockerFilter != "" && rs.LockerID != lockerFilter {
+			return nil
+		}
+		if participantFilter != "" && rs.ParticipantID != participantFilter {
+			return nil
+		}
+
+		if rs.RevisionNumber > maxRevision || (maxCreatedAt != nil && rs.RevisionNumber == maxRevision && rs.CreatedAt.After(*maxCreatedAt)) {
+			masterRec = &rs
+			maxRevision = rs.RevisionNumber
+		}
+
+		if includeHistory {
+			hist = append(hist, &rs)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return masterRec, hist, nil
+}
+
 func (dwi *Index) GetVariant(ctx context.Context, variantID string, includeHistory bool) (*index.VariantRecordState,
 	[]*index.VariantRecordState, error) {
 
@@ -609,7 +726,7 @@ func (dwi *Index) GetVariant(ctx context.Context, variantID string, includeHisto
 		vvb := vb.Bucket([]byte(variantID))
 		if vvb == nil {
 			// no variants were inserted yet
-			return fmt.Errorf("variant %s not found in index", variantID)
+			return fmt.Errorf("%w: %s", index.ErrVariantNotFound, variantID)
 		}
 
 		var maxRevision int64 = -1
@@ -723,9 +840,25 @@ func (dwi *Index) Writer() (index.Writer, error) {
 func (dwi *Index) LockerStates(ctx context.Context) ([]index.LockerState, error) {
 	defer measure.ExecTime("index.LockerStates")()
 
+	return loadLockerStates(dwi.client, dwi.id)
+}
+
+func (dwi *Index) AddLockerState(ctx context.Context, accountID, lockerID string, firstBlock int64) error {
+	defer measure.ExecTime("index.AddLockerState")()
+
+	return addLockerState(dwi.client, dwi.id, accountID, lockerID, firstBlock)
+}
+
+func (dwi *Index) SealLockerState(ctx context.Context, lockerID string, lastBlock int64) error {
+	defer measure.ExecTime("index.SealLockerState")()
+
+	return sealLockerState(dwi.client, dwi.id, lockerID, lastBlock)
+}
+
+func loadLockerStates(client *utils.BoltClient, indexID string) ([]index.LockerState, error) {
 	states := make([]index.LockerState, 0)
-	err := dwi.client.DB.View(func(tx *bbolt.Tx) error {
-		b := dwi.indexBucket(tx, LockersKey)
+	err := client.DB.View(func(tx *bbolt.Tx) error {
+		b := indexBucket(tx, indexID, LockersKey)
 		if b == nil {
 			return fmt.Errorf("bucket %s not found", LockersKey)
 		}
@@ -749,12 +882,10 @@ func (dwi *Index) LockerStates(ctx context.Context) ([]index.LockerState, error)
 	return states, nil
 }
 
-func (dwi *Index) AddLockerState(ctx context.Context, accountID, lockerID string, firstBlock int64) error {
-	defer measure.ExecTime("index.AddLockerState")()
-
+func addLockerState(client *utils.BoltClient, indexID, accountID, lockerID string, firstBlock int64) error {
 	found := false
-	err := dwi.client.DB.View(func(tx *bbolt.Tx) error {
-		b := dwi.indexBucket(tx, LockersKey)
+	err := client.DB.View(func(tx *bbolt.Tx) error {
+		b := indexBucket(tx, indexID, LockersKey)
 		if b == nil {
 			return fmt.Errorf("bucket %s not found", LockersKey)
 		}
@@ -775,14 +906,14 @@ func (dwi *Index) AddLockerState(ctx context.Context, accountID, lockerID string
 
 	ls := &index.LockerState{
 		ID:         lockerID,
-		IndexID:    dwi.id,
+		IndexID:    indexID,
 		AccountID:  accountID,
 		FirstBlock: firstBlock,
 		TopBlock:   firstBlock,
 	}
 
-	if err := dwi.client.DB.Update(func(tx *bbolt.Tx) error {
-		b := dwi.indexBucket(tx, LockersKey)
+	if err := client.DB.Update(func(tx *bbolt.Tx) error {
+		b := indexBucket(tx, indexID, LockersKey)
 		if b == nil {
 			return fmt.Errorf("bucket %s not found", LockersKey)
 		}
@@ -800,6 +931,29 @@ func (dwi *Index) AddLockerState(ctx context.Context, accountID, lockerID string
 	return err
 }
 
+func sealLockerState(client *utils.BoltClient, indexID, lockerID string, lastBlock int64) error {
+	return client.DB.Update(func(tx *bbolt.Tx) error {
+		b := indexBucket(tx, indexID, LockersKey)
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", LockersKey)
+		}
+
+		val := b.Get([]byte(lockerID))
+		if val == nil {
+			return index.ErrLockerStateNotFound
+		}
+
+		var ls index.LockerState
+		if err := jsonw.Unmarshal(val, &ls); err != nil {
+			return err
+		}
+
+		ls.Seal(lastBlock)
+
+		return b.Put([]byte(lockerID), ls.Bytes())
+	})
+}
+
 func saveProperties(userID, indexID string, props *index.Properties, client *utils.BoltClient) error {
 	return client.DB.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(indexID))
@@ -819,6 +973,22 @@ func saveProperties(userID, indexID string, props *index.Properties, client *uti
 			return err
 		}
 
+		err = b.Put([]byte(IndexTypeKey), []byte(props.IndexType))
+		if err != nil {
+			return err
+		}
+
+		if props.Params != nil {
+			paramBytes, err := jsonw.Marshal(props.Params)
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(ParamsKey), paramBytes)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 }
@@ -845,6 +1015,23 @@ func loadProperties(indexID string, client *utils.BoltClient) (*index.Properties
 			props.AccessLevel = model.AccessLevel(utils.BytesToUint32(val))
 		}
 
+		// indexes created before search indexes were introduced don't have
+		// the index type property. These are always root indexes.
+		if val = b.Get([]byte(IndexTypeKey)); val != nil {
+			props.IndexType = string(val)
+			if props.IndexType == index.TypeSearch {
+				props.Algorithm = SearchAlgorithm
+			}
+		}
+
+		if val = b.Get([]byte(ParamsKey)); val != nil && props.IndexType == index.TypeSearch {
+			var params index.SearchIndexParameters
+			if err := jsonw.Unmarshal(val, &params); err != nil {
+				return err
+			}
+			props.Params = &params
+		}
+
 		return nil
 	})
 	if err != nil {
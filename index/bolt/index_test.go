@@ -18,12 +18,17 @@ import (
 	"context"
 	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/piprate/metalocker/index"
+	. "github.com/piprate/metalocker/index/bolt"
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
 )
 
 func TestIndex_AddLockerState(t *testing.T) {
@@ -53,3 +58,88 @@ func TestIndex_AddLockerState(t *testing.T) {
 	require.Error(t, err)
 	require.True(t, errors.Is(err, index.ErrLockerStateExists))
 }
+
+func TestIndex_UpgradePageBuckets(t *testing.T) {
+	store, dir := newTestIndexStore(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	userID := "did:piprate:QgH6CZvhjTUFvCbRUw4N6Z"
+
+	ctx := context.Background()
+
+	ix, err := store.CreateIndex(ctx, userID, index.TypeRoot, model.AccessLevelHosted)
+	require.NoError(t, err)
+
+	iw, _ := ix.Writer()
+
+	for id, block := range map[string]int64{"rec1": 3, "rec2": 1, "rec3": 2} {
+		createdAt := time.Date(2022, 1, 1, 0, 0, int(block), 0, time.UTC)
+		require.NoError(t, iw.AddLease(ctx, testbase.NewMockDataSet(&model.Record{
+			ID:        id,
+			Operation: model.OpTypeLease,
+			Status:    model.StatusPublished,
+		}, &model.Lease{
+			Impression: &model.Impression{
+				ID:              "imp-" + id,
+				Asset:           "asset1",
+				GeneratedAtTime: &createdAt,
+				MetaResource:    &model.MetaResource{},
+			},
+		}, block, "locker1", "party1", nil), block))
+	}
+
+	require.NoError(t, store.Close())
+
+	// remove page buckets to emulate an index created before cursor-based traversals
+
+	db, err := bbolt.Open(filepath.Join(dir, "wallet.bolt"), 0o600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(ix.ID()))
+		for _, bucket := range []string{RecordOrderKey, LockerRecordOrderKey, VariantOrderKey, VariantHeadsKey, AssetOrderKey} {
+			if err := b.DeleteBucket([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	store, err = NewIndexStore(
+		&index.StoreConfig{
+			ID:   testbase.IndexStoreID,
+			Name: testbase.IndexStoreName,
+			Type: Type,
+			Params: map[string]any{
+				ParameterFilePath: filepath.Join(dir, "wallet.bolt"),
+			},
+		}, nil)
+	require.NoError(t, err)
+	defer func() {
+		_ = store.Close()
+	}()
+
+	rootIndex, err := store.RootIndex(ctx, userID, model.AccessLevelHosted)
+	require.NoError(t, err)
+
+	recPage, err := rootIndex.ListRecords(ctx, "locker1", "", index.PageOptions{})
+	require.NoError(t, err)
+	require.Len(t, recPage.Records, 3)
+	assert.Equal(t, "rec2", recPage.Records[0].ID)
+	assert.Equal(t, "rec3", recPage.Records[1].ID)
+	assert.Equal(t, "rec1", recPage.Records[2].ID)
+
+	assetPage, err := rootIndex.ListAssetRecords(ctx, "asset1", index.PageOptions{Order: index.SortDescending})
+	require.NoError(t, err)
+	require.Len(t, assetPage.Records, 3)
+	assert.Equal(t, "rec1", assetPage.Records[0].RecordID)
+	assert.Equal(t, int64(3), assetPage.Records[0].BlockNumber)
+
+	varPage, err := rootIndex.ListVariants(ctx, "", "", false, index.PageOptions{PageSize: 1})
+	require.NoError(t, err)
+	require.Len(t, varPage.Variants, 1)
+	assert.Equal(t, "imp-rec2", varPage.Variants[0].VariantID)
+	assert.NotEmpty(t, varPage.NextCursor)
+}
@@ -0,0 +1,456 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/utils"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"go.etcd.io/bbolt"
+)
+
+// Page buckets contain empty values. Their keys are composed of a big-endian
+// block number and an item key, so that a bucket cursor can seek to the position
+// defined by a page cursor and visit the following items in block order.
+
+func orderKey(block int64, key string) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(block))
+	copy(k[8:], key)
+	return k
+}
+
+func parseOrderKey(k []byte) (int64, string) {
+	return int64(binary.BigEndian.Uint64(k[:8])), string(k[8:])
+}
+
+// recordPageKey identifies a record state. The same record may be indexed
+// for several participants.
+func recordPageKey(recordID, lockerID, participantID string) string {
+	return recordID + "\x00" + lockerID + "\x00" + participantID
+}
+
+func splitRecordPageKey(key string) (string, string, string) {
+	parts := strings.SplitN(key, "\x00", 3)
+	if len(parts) != 3 {
+		return key, "", ""
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+// collectPage visits the keys of a page bucket in the requested order, starting after
+// the request's cursor. add returns false if the item was skipped. collectPage stops after
+// Limit+1 added items and returns true if there are more items than fit into the page,
+// together with the cursor that points to the last item of the page.
+func collectPage(b *bbolt.Bucket, req *index.PageRequest, add func(block int64, key string) (bool, error)) (bool, string, error) {
+	c := b.Cursor()
+
+	var k []byte
+	switch {
+	case req.After == nil && req.Descending():
+		k, _ = c.Last()
+	case req.After == nil:
+		k, _ = c.First()
+	default:
+		after := orderKey(req.After.Block, req.After.Key)
+		k, _ = c.Seek(after)
+		if req.Descending() {
+			if k == nil {
+				k, _ = c.Last()
+			} else {
+				k, _ = c.Prev()
+			}
+		} else if bytes.Equal(k, after) {
+			k, _ = c.Next()
+		}
+	}
+
+	count := 0
+	var next string
+	for ; k != nil; k = step(c, req) {
+		block, key := parseOrderKey(k)
+		added, err := add(block, key)
+		if err != nil {
+			return false, "", err
+		}
+		if !added {
+			continue
+		}
+		count++
+		if count == req.Limit {
+			next = req.NextCursor(block, key)
+		} else if count > req.Limit {
+			return true, next, nil
+		}
+	}
+
+	return false, "", nil
+}
+
+func step(c *bbolt.Cursor, req *index.PageRequest) []byte {
+	var k []byte
+	if req.Descending() {
+		k, _ = c.Prev()
+	} else {
+		k, _ = c.Next()
+	}
+	return k
+}
+
+func (dwi *Index) ListRecords(ctx context.Context, lockerFilter, participantFilter string, opts index.PageOptions) (*index.RecordPage, error) {
+	req, err := index.NewPageRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &index.RecordPage{
+		Records: make([]*index.RecordState, 0),
+	}
+
+	err = dwi.client.DB.View(func(tx *bbolt.Tx) error {
+		rb := dwi.indexBucket(tx, RecordsKey)
+		if rb == nil {
+			return fmt.Errorf("bucket %s not found", RecordsKey)
+		}
+
+		var ob *bbolt.Bucket
+		if lockerFilter != "" {
+			lob := dwi.indexBucket(tx, LockerRecordOrderKey)
+			if lob == nil {
+				return fmt.Errorf("bucket %s not found", LockerRecordOrderKey)
+			}
+			if ob = lob.Bucket([]byte(lockerFilter)); ob == nil {
+				// no records were inserted yet
+				return nil
+			}
+		} else if ob = dwi.indexBucket(tx, RecordOrderKey); ob == nil {
+			return fmt.Errorf("bucket %s not found", RecordOrderKey)
+		}
+
+		more, next, err := collectPage(ob, req, func(block int64, key string) (bool, error) {
+			recordID, lockerID, participantID := splitRecordPageKey(key)
+			if participantFilter != "" && participantID != participantFilter {
+				return false, nil
+			}
+
+			lb := rb.Bucket([]byte(lockerID))
+			if lb == nil {
+				return false, nil
+			}
+			pb := lb.Bucket([]byte(participantID))
+			if pb == nil {
+				return false, nil
+			}
+			v := pb.Get([]byte(recordID))
+			if v == nil {
+				return false, nil
+			}
+
+			var rs index.RecordState
+			if err := jsonw.Unmarshal(v, &rs); err != nil {
+				return false, err
+			}
+			page.Records = append(page.Records, &rs)
+
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if more {
+			page.Records = page.Records[:req.Limit]
+			page.NextCursor = next
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (dwi *Index) ListVariants(ctx context.Context, lockerFilter, participantFilter string, includeHistory bool, opts index.PageOptions) (*index.VariantPage, error) {
+	req, err := index.NewPageRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &index.VariantPage{
+		Variants: make([]*index.VariantPageEntry, 0),
+	}
+
+	err = dwi.client.DB.View(func(tx *bbolt.Tx) error {
+		vb := dwi.indexBucket(tx, VariantsKey)
+		if vb == nil {
+			return fmt.Errorf("bucket %s not found", VariantsKey)
+		}
+		ob := dwi.indexBucket(tx, VariantOrderKey)
+		if ob == nil {
+			return fmt.Errorf("bucket %s not found", VariantOrderKey)
+		}
+
+		more, next, err := collectPage(ob, req, func(block int64, variantID string) (bool, error) {
+			vvb := vb.Bucket([]byte(variantID))
+			if vvb == nil {
+				return false, nil
+			}
+
+			masterRec, hist, err := variantMaster(vvb, lockerFilter, participantFilter, includeHistory)
+			if err != nil || masterRec == nil {
+				return false, err
+			}
+
+			page.Variants = append(page.Variants, &index.VariantPageEntry{
+				VariantID: variantID,
+				Master:    masterRec,
+				History:   hist,
+			})
+
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if more {
+			page.Variants = page.Variants[:req.Limit]
+			page.NextCursor = next
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (dwi *Index) ListAssetRecords(ctx context.Context, assetID string, opts index.PageOptions) (*index.AssetRecordPage, error) {
+	req, err := index.NewPageRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &index.AssetRecordPage{
+		Records: make([]*index.AssetRecordPageEntry, 0),
+	}
+
+	err = dwi.client.DB.View(func(tx *bbolt.Tx) error {
+		alb := dwi.indexBucket(tx, AssetLookupKey)
+		if alb == nil {
+			return fmt.Errorf("bucket %s not found", AssetLookupKey)
+		}
+		aob := dwi.indexBucket(tx, AssetOrderKey)
+		if aob == nil {
+			return fmt.Errorf("bucket %s not found", AssetOrderKey)
+		}
+
+		ab := alb.Bucket([]byte(assetID))
+		ob := aob.Bucket([]byte(assetID))
+		if ab == nil || ob == nil {
+			// no records were inserted yet
+			return nil
+		}
+
+		more, next, err := collectPage(ob, req, func(block int64, recordID string) (bool, error) {
+			v := ab.Get([]byte(recordID))
+			if v == nil {
+				return false, nil
+			}
+
+			var as index.AssetState
+			if err := jsonw.Unmarshal(v, &as); err != nil {
+				return false, err
+			}
+
+			page.Records = append(page.Records, &index.AssetRecordPageEntry{
+				RecordID:    recordID,
+				BlockNumber: block,
+				State:       &as,
+			})
+
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if more {
+			page.Records = page.Records[:req.Limit]
+			page.NextCursor = next
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// putRecordOrder adds the record state to the page buckets.
+func putRecordOrder(tx *bbolt.Tx, indexID string, rs *index.RecordState) error {
+	k := orderKey(rs.BlockNumber, recordPageKey(rs.ID, rs.LockerID, rs.ParticipantID))
+
+	ob := indexBucket(tx, indexID, RecordOrderKey)
+	if ob == nil {
+		return fmt.Errorf("bucket %s not found", RecordOrderKey)
+	}
+	if err := ob.Put(k, []byte{}); err != nil {
+		return err
+	}
+
+	lob := indexBucket(tx, indexID, LockerRecordOrderKey)
+	if lob == nil {
+		return fmt.Errorf("bucket %s not found", LockerRecordOrderKey)
+	}
+	lb, err := lob.CreateBucketIfNotExists([]byte(rs.LockerID))
+	if err != nil {
+		return err
+	}
+
+	return lb.Put(k, []byte{})
+}
+
+// putAssetRecordOrder adds the asset's record to the page buckets.
+func putAssetRecordOrder(tx *bbolt.Tx, indexID, assetID, recordID string, blockNumber int64) error {
+	aob := indexBucket(tx, indexID, AssetOrderKey)
+	if aob == nil {
+		return fmt.Errorf("bucket %s not found", AssetOrderKey)
+	}
+	ab, err := aob.CreateBucketIfNotExists([]byte(assetID))
+	if err != nil {
+		return err
+	}
+
+	return ab.Put(orderKey(blockNumber, recordID), []byte{})
+}
+
+// updateVariantOrder moves the variant to the position defined by the block number
+// of its current master record.
+func updateVariantOrder(tx *bbolt.Tx, indexID, variantID string) error {
+	vb := indexBucket(tx, indexID, VariantsKey)
+	if vb == nil {
+		return fmt.Errorf("bucket %s not found", VariantsKey)
+	}
+	ob := indexBucket(tx, indexID, VariantOrderKey)
+	if ob == nil {
+		return fmt.Errorf("bucket %s not found", VariantOrderKey)
+	}
+	hb := indexBucket(tx, indexID, VariantHeadsKey)
+	if hb == nil {
+		return fmt.Errorf("bucket %s not found", VariantHeadsKey)
+	}
+
+	vvb := vb.Bucket([]byte(variantID))
+	if vvb == nil {
+		return nil
+	}
+
+	masterRec, _, err := variantMaster(vvb, "", "", false)
+	if err != nil || masterRec == nil {
+		return err
+	}
+
+	k := orderKey(masterRec.BlockNumber, variantID)
+	if prev := hb.Get([]byte(variantID)); prev != nil {
+		if bytes.Equal(prev, k) {
+			return nil
+		}
+		if err = ob.Delete(prev); err != nil {
+			return err
+		}
+	}
+	if err = ob.Put(k, []byte{}); err != nil {
+		return err
+	}
+
+	return hb.Put([]byte(variantID), k)
+}
+
+// upgradePageBuckets creates and populates page buckets in indexes
+// that were created before cursor-based traversals were introduced.
+func upgradePageBuckets(client *utils.BoltClient, indexID string) error {
+	upgraded := true
+	if err := client.DB.View(func(tx *bbolt.Tx) error {
+		ib := tx.Bucket([]byte(indexID))
+		upgraded = ib == nil || ib.Bucket([]byte(AssetOrderKey)) != nil
+		return nil
+	}); err != nil || upgraded {
+		return err
+	}
+
+	return client.DB.Update(func(tx *bbolt.Tx) error {
+		ib := tx.Bucket([]byte(indexID))
+		if ib.Bucket([]byte(AssetOrderKey)) != nil {
+			return nil
+		}
+		for _, bucket := range pageBuckets {
+			if _, err := ib.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+
+		rb := ib.Bucket([]byte(RecordsKey))
+		err := rb.ForEach(func(lockerID, empty []byte) error {
+			lb := rb.Bucket(lockerID)
+			return lb.ForEach(func(pid, empty []byte) error {
+				return lb.Bucket(pid).ForEach(func(recID, v []byte) error {
+					var rs index.RecordState
+					if err := jsonw.Unmarshal(v, &rs); err != nil {
+						return err
+					}
+					return putRecordOrder(tx, indexID, &rs)
+				})
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		rlb := ib.Bucket([]byte(RecordLookupKey))
+		alb := ib.Bucket([]byte(AssetLookupKey))
+		err = alb.ForEach(func(assetID, empty []byte) error {
+			return alb.Bucket(assetID).ForEach(func(recID, v []byte) error {
+				var rs index.RecordState
+				if val := rlb.Get(recID); val != nil {
+					if err := jsonw.Unmarshal(val, &rs); err != nil {
+						return err
+					}
+				}
+				return putAssetRecordOrder(tx, indexID, string(assetID), string(recID), rs.BlockNumber)
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		vb := ib.Bucket([]byte(VariantsKey))
+		return vb.ForEach(func(variantID, empty []byte) error {
+			return updateVariantOrder(tx, indexID, string(variantID))
+		})
+	})
+}
@@ -32,6 +32,17 @@ const (
 	AssetLookupKey      = "asset_lookup"
 	PropertiesKey       = "properties"
 	ControlsKey         = "controls"
+	SearchTermsKey      = "search_terms"
+	SearchFieldsKey     = "search_fields"
+	SearchDocsKey       = "search_docs"
+
+	// page buckets keep index entries in (block number, key) order
+
+	RecordOrderKey       = "record_order"
+	LockerRecordOrderKey = "locker_record_order"
+	VariantOrderKey      = "variant_order"
+	VariantHeadsKey      = "variant_heads"
+	AssetOrderKey        = "asset_order"
 
 	// control variables
 
@@ -41,6 +52,8 @@ const (
 
 	AccessLevelKey = "access_level"
 	AccountKey     = "account"
+	IndexTypeKey   = "index_type"
+	ParamsKey      = "params"
 )
 
 var (
@@ -50,7 +63,16 @@ var (
 
 	indexBuckets = []string{
 		LockersKey, RecordsKey, ResourceLookupKey, ImpressionLookupKey, RecordLookupKey, AssetLookupKey,
-		VariantsKey, PropertiesKey,
+		VariantsKey, PropertiesKey, RecordOrderKey, LockerRecordOrderKey, VariantOrderKey, VariantHeadsKey,
+		AssetOrderKey,
+	}
+
+	pageBuckets = []string{
+		RecordOrderKey, LockerRecordOrderKey, VariantOrderKey, VariantHeadsKey, AssetOrderKey,
+	}
+
+	searchIndexBuckets = []string{
+		LockersKey, SearchTermsKey, SearchFieldsKey, SearchDocsKey, PropertiesKey,
 	}
 )
 
@@ -72,12 +94,20 @@ func InstallIndexStoreSchema(bc *utils.BoltClient) error {
 }
 
 func InstallIndexSchema(bc *utils.BoltClient, userID string) error {
+	return installBuckets(bc, userID, indexBuckets)
+}
+
+func InstallSearchIndexSchema(bc *utils.BoltClient, indexID string) error {
+	return installBuckets(bc, indexID, searchIndexBuckets)
+}
+
+func installBuckets(bc *utils.BoltClient, indexID string, buckets []string) error {
 	return bc.DB.Update(func(tx *bbolt.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([]byte(userID))
+		b, err := tx.CreateBucketIfNotExists([]byte(indexID))
 		if err != nil {
 			return err
 		}
-		for _, bucket := range indexBuckets {
+		for _, bucket := range buckets {
 			_, err := b.CreateBucketIfNotExists([]byte(bucket))
 			if err != nil {
 				return err
@@ -0,0 +1,377 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bolt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/piprate/metalocker/utils/measure"
+	"github.com/rs/zerolog/log"
+	"go.etcd.io/bbolt"
+)
+
+// SearchIndex is a full-text and metadata search index for datasets' meta resources.
+// It keeps an inverted index of terms and selected property values in Bolt buckets.
+type SearchIndex struct {
+	id     string
+	userID string
+	props  *index.Properties
+	params *index.SearchIndexParameters
+	client *utils.BoltClient
+}
+
+// searchDoc is a search index entry for a single dataset. It includes the terms
+// and fields of the dataset, so that the entry can be removed from posting lists.
+type searchDoc struct {
+	index.SearchResult
+
+	Terms  map[string]int `json:"terms,omitempty"`
+	Fields []string       `json:"fields,omitempty"`
+}
+
+// postingKey identifies a posting list (a list of records that contain
+// a term or a field value).
+type postingKey struct {
+	bucketID string
+	key      string
+}
+
+var _ index.SearchIndex = (*SearchIndex)(nil)
+var _ index.Writer = (*SearchIndex)(nil)
+
+func newSearchIndex(id, userID string, props *index.Properties, client *utils.BoltClient) (*SearchIndex, error) {
+	params, err := index.ReadSearchIndexParameters(props.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchIndex{
+		id:     id,
+		userID: userID,
+		props:  props,
+		params: params,
+		client: client,
+	}, nil
+}
+
+func fieldKey(name, value string) string {
+	return name + "\x00" + value
+}
+
+func (si *SearchIndex) ID() string {
+	return si.id
+}
+
+func (si *SearchIndex) Properties() *index.Properties {
+	return si.props
+}
+
+func (si *SearchIndex) Close() error {
+	log.Debug().Msg("Closing BoltDB search index")
+	return nil
+}
+
+func (si *SearchIndex) IsLocked() bool {
+	return false
+}
+
+func (si *SearchIndex) Unlock(key []byte) error {
+	return nil
+}
+
+func (si *SearchIndex) Lock() {
+	// do nothing
+}
+
+func (si *SearchIndex) IsWritable() bool {
+	return true
+}
+
+func (si *SearchIndex) Writer() (index.Writer, error) {
+	return si, nil
+}
+
+func (si *SearchIndex) LockerStates(ctx context.Context) ([]index.LockerState, error) {
+	defer measure.ExecTime("searchIndex.LockerStates")()
+
+	return loadLockerStates(si.client, si.id)
+}
+
+func (si *SearchIndex) AddLockerState(ctx context.Context, accountID, lockerID string, firstBlock int64) error {
+	defer measure.ExecTime("searchIndex.AddLockerState")()
+
+	return addLockerState(si.client, si.id, accountID, lockerID, firstBlock)
+}
+
+func (si *SearchIndex) SealLockerState(ctx context.Context, lockerID string, lastBlock int64) error {
+	defer measure.ExecTime("searchIndex.SealLockerState")()
+
+	return sealLockerState(si.client, si.id, lockerID, lastBlock)
+}
+
+func (si *SearchIndex) UpdateTopBlock(ctx context.Context, blockNumber int64) error {
+	return updateTopBlock(si.client, si.id, blockNumber)
+}
+
+func (si *SearchIndex) AddLease(ctx context.Context, ds model.DataSet, effectiveBlockNumber int64) error {
+	defer measure.ExecTime("searchIndex.AddLease")()
+
+	r := ds.Record()
+
+	if r.Status == model.StatusRevoked {
+		return si.client.DB.Update(func(tx *bbolt.Tx) error {
+			return si.removeDoc(tx, r.ID)
+		})
+	}
+
+	lease := ds.Lease()
+
+	doc := &searchDoc{
+		SearchResult: index.SearchResult{
+			RecordID:      r.ID,
+			ImpressionID:  lease.Impression.ID,
+			AssetID:       lease.Impression.Asset,
+			LockerID:      ds.LockerID(),
+			ParticipantID: ds.ParticipantID(),
+			BlockNumber:   ds.BlockNumber(),
+			CreatedAt:     lease.Impression.GeneratedAtTime,
+		},
+	}
+	if lease.Impression.MetaResource != nil {
+		doc.ContentType = lease.Impression.MetaResource.ContentType
+	}
+
+	var meta any
+	if err := ds.DecodeMetaResource(ctx, &meta); err != nil {
+		// the dataset will still be searchable by its content type and creation time
+		log.Warn().Err(err).Str("rid", r.ID).Msg("Failed to read meta resource for search index")
+	} else {
+		var fields map[string][]string
+		doc.Terms, fields = index.ExtractSearchTerms(meta, si.params.Properties)
+		for name, values := range fields {
+			for _, val := range values {
+				doc.Fields = append(doc.Fields, fieldKey(name, val))
+			}
+		}
+	}
+
+	return si.client.DB.Update(func(tx *bbolt.Tx) error {
+		// remove the previous version of the document, if any
+		if err := si.removeDoc(tx, r.ID); err != nil {
+			return err
+		}
+
+		docBytes, err := jsonw.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if err = si.bucket(tx, SearchDocsKey).Put([]byte(r.ID), docBytes); err != nil {
+			return err
+		}
+
+		tb := si.bucket(tx, SearchTermsKey)
+		for term, freq := range doc.Terms {
+			b, err := tb.CreateBucketIfNotExists([]byte(term))
+			if err != nil {
+				return err
+			}
+			if err = b.Put([]byte(r.ID), utils.Uint32ToBytes(uint32(freq))); err != nil {
+				return err
+			}
+		}
+
+		fb := si.bucket(tx, SearchFieldsKey)
+		for _, fk := range doc.Fields {
+			b, err := fb.CreateBucketIfNotExists([]byte(fk))
+			if err != nil {
+				return err
+			}
+			if err = b.Put([]byte(r.ID), []byte{}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (si *SearchIndex) AddLeaseRevocation(ctx context.Context, ds model.DataSet) error {
+	defer measure.ExecTime("searchIndex.AddLeaseRevocation")()
+
+	return si.client.DB.Update(func(tx *bbolt.Tx) error {
+		return si.removeDoc(tx, ds.Record().SubjectRecord)
+	})
+}
+
+func (si *SearchIndex) AddLeaseRenewal(ctx context.Context, ds model.DataSet) error {
+	// lease renewals don't change the content of search documents
+	return nil
+}
+
+func (si *SearchIndex) bucket(tx *bbolt.Tx, bucketID string) *bbolt.Bucket {
+	return indexBucket(tx, si.id, bucketID)
+}
+
+func (si *SearchIndex) removeDoc(tx *bbolt.Tx, recordID string) error {
+	db := si.bucket(tx, SearchDocsKey)
+	if db == nil {
+		return fmt.Errorf("bucket %s not found", SearchDocsKey)
+	}
+
+	val := db.Get([]byte(recordID))
+	if val == nil {
+		return nil
+	}
+
+	var doc searchDoc
+	if err := jsonw.Unmarshal(val, &doc); err != nil {
+		return err
+	}
+
+	for _, posting := range []struct {
+		bucketID string
+		keys     []string
+	}{
+		{SearchTermsKey, termKeys(doc.Terms)},
+		{SearchFieldsKey, doc.Fields},
+	} {
+		pb := si.bucket(tx, posting.bucketID)
+		for _, k := range posting.keys {
+			b := pb.Bucket([]byte(k))
+			if b == nil {
+				continue
+			}
+			if err := b.Delete([]byte(recordID)); err != nil {
+				return err
+			}
+			if first, _ := b.Cursor().First(); first == nil {
+				// drop empty posting lists
+				if err := pb.DeleteBucket([]byte(k)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return db.Delete([]byte(recordID))
+}
+
+func termKeys(terms map[string]int) []string {
+	keys := make([]string, 0, len(terms))
+	for t := range terms {
+		keys = append(keys, t)
+	}
+	return keys
+}
+
+// Search returns datasets that match the query. If the query contains free text,
+// results are ranked by the number of occurrences of the query terms.
+func (si *SearchIndex) Search(ctx context.Context, q *index.SearchQuery) ([]*index.SearchResult, error) {
+	defer measure.ExecTime("searchIndex.Search")()
+
+	var postingKeys []postingKey
+	for _, term := range index.Tokenize(q.Text) {
+		postingKeys = append(postingKeys, postingKey{SearchTermsKey, term})
+	}
+	for name, val := range q.Properties {
+		postingKeys = append(postingKeys, postingKey{SearchFieldsKey, fieldKey(name, index.NormaliseFieldValue(val))})
+	}
+
+	results := make([]*index.SearchResult, 0)
+	err := si.client.DB.View(func(tx *bbolt.Tx) error {
+		db := si.bucket(tx, SearchDocsKey)
+		if db == nil {
+			return fmt.Errorf("bucket %s not found", SearchDocsKey)
+		}
+
+		// candidates contains IDs of matching records with their scores.
+		// nil means all records match.
+		var candidates map[string]int
+		for _, pk := range postingKeys {
+			matches := make(map[string]int)
+			if b := si.bucket(tx, pk.bucketID).Bucket([]byte(pk.key)); b != nil {
+				err := b.ForEach(func(k, v []byte) error {
+					recordID := string(k)
+					if candidates != nil {
+						if _, found := candidates[recordID]; !found {
+							return nil
+						}
+					}
+					score := candidates[recordID]
+					if pk.bucketID == SearchTermsKey {
+						score += int(utils.BytesToUint32(v))
+					}
+					matches[recordID] = score
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			candidates = matches
+			if len(candidates) == 0 {
+				return nil
+			}
+		}
+
+		addResult := func(recordID string, score int, val []byte) error {
+			var doc searchDoc
+			if err := jsonw.Unmarshal(val, &doc); err != nil {
+				return err
+			}
+			if !q.Matches(&doc.SearchResult) {
+				return nil
+			}
+			res := doc.SearchResult
+			res.Score = score
+			results = append(results, &res)
+			return nil
+		}
+
+		if candidates == nil {
+			return db.ForEach(func(k, v []byte) error {
+				return addResult(string(k), 0, v)
+			})
+		}
+
+		for recordID, score := range candidates {
+			val := db.Get([]byte(recordID))
+			if val == nil {
+				continue
+			}
+			if err := addResult(recordID, score, val); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	index.SortSearchResults(results)
+
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+
+	return results, nil
+}
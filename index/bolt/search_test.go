@@ -0,0 +1,120 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bolt_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/index"
+	. "github.com/piprate/metalocker/index/bolt"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSearchDataSet(recordID, lockerID, contentType string, createdAt time.Time, meta any) model.DataSet {
+	r := &model.Record{
+		ID:        recordID,
+		Operation: model.OpTypeLease,
+		Status:    model.StatusPublished,
+	}
+	lease := &model.Lease{
+		Impression: &model.Impression{
+			ID:              "imp-" + recordID,
+			Asset:           "asset-" + recordID,
+			GeneratedAtTime: &createdAt,
+			MetaResource: &model.MetaResource{
+				ContentType: contentType,
+			},
+		},
+	}
+	return testbase.NewMockDataSet(r, lease, 1, lockerID, "party1", meta)
+}
+
+func TestSearchIndex(t *testing.T) {
+	store, dir := newTestIndexStore(t)
+	defer func() {
+		_ = store.Close()
+		_ = os.RemoveAll(dir)
+	}()
+
+	ctx := context.Background()
+
+	userID := "did:piprate:QgH6CZvhjTUFvCbRUw4N6Z"
+
+	newIdx, err := store.CreateIndex(ctx, userID, index.TypeSearch, model.AccessLevelHosted,
+		index.WithParameters(map[string]any{"properties": []string{"name"}}))
+	require.NoError(t, err)
+
+	_, err = store.CreateIndex(ctx, userID, index.TypeSearch, model.AccessLevelHosted)
+	require.ErrorIs(t, err, index.ErrIndexExists)
+
+	// check index properties were saved
+
+	ix, err := store.Index(ctx, userID, newIdx.ID())
+	require.NoError(t, err)
+
+	props := ix.Properties()
+	assert.Equal(t, index.TypeSearch, props.IndexType)
+	assert.Equal(t, SearchAlgorithm, props.Algorithm)
+	assert.Equal(t, &index.SearchIndexParameters{Properties: []string{"name"}}, props.Params)
+
+	si := ix.(index.SearchIndex)
+	iw, _ := si.Writer()
+
+	t1 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	require.NoError(t, iw.AddLease(ctx, newSearchDataSet("rec1", "locker1", "Person", t1, map[string]any{
+		"name": "Alice Smith",
+		"bio":  "Alice likes graphs",
+	}), 1))
+	require.NoError(t, iw.AddLease(ctx, newSearchDataSet("rec2", "locker2", "Person", t2, map[string]any{
+		"name": "Bob Smith",
+	}), 1))
+	require.NoError(t, iw.AddLease(ctx, newSearchDataSet("rec3", "locker1", "Document", t2, nil), 1))
+
+	search := func(q *index.SearchQuery) []string {
+		res, err := si.Search(ctx, q)
+		require.NoError(t, err)
+		ids := make([]string, len(res))
+		for i, r := range res {
+			ids[i] = r.RecordID
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"rec1"}, search(&index.SearchQuery{Text: "alice"}))
+	assert.Equal(t, []string{"rec2", "rec1"}, search(&index.SearchQuery{Text: "smith"}))
+	assert.Equal(t, []string{"rec1"}, search(&index.SearchQuery{Text: "smith", LockerID: "locker1"}))
+	assert.Equal(t, []string{"rec2"}, search(&index.SearchQuery{Properties: map[string]string{"name": "bob smith"}}))
+	assert.Empty(t, search(&index.SearchQuery{Properties: map[string]string{"bio": "alice likes graphs"}}))
+	assert.Equal(t, []string{"rec3"}, search(&index.SearchQuery{ContentType: "Document"}))
+	assert.Equal(t, []string{"rec2", "rec3"}, search(&index.SearchQuery{CreatedAfter: &t1}))
+	assert.Equal(t, []string{"rec2", "rec3", "rec1"}, search(&index.SearchQuery{}))
+
+	require.NoError(t, iw.AddLeaseRevocation(ctx, testbase.NewMockDataSet(&model.Record{
+		ID:            "rev1",
+		Operation:     model.OpTypeLeaseRevocation,
+		SubjectRecord: "rec2",
+	}, nil, 2, "locker2", "party1", nil)))
+
+	assert.Equal(t, []string{"rec1"}, search(&index.SearchQuery{Text: "smith"}))
+	assert.Empty(t, search(&index.SearchQuery{Text: "bob"}))
+}
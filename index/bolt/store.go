@@ -35,7 +35,8 @@ const (
 
 	ParameterFilePath = "file_path"
 
-	Algorithm = "metalocker:root:1"
+	Algorithm       = "metalocker:root:1"
+	SearchAlgorithm = "metalocker:search:1"
 )
 
 func init() {
@@ -104,7 +105,13 @@ func (s *IndexStore) Properties() *index.StoreProperties {
 
 func (s *IndexStore) CreateIndex(ctx context.Context, userID string, indexType string, accessLevel model.AccessLevel, opts ...index.Option) (index.Index, error) {
 
-	if indexType != index.TypeRoot {
+	var indexID string
+	switch indexType {
+	case index.TypeRoot:
+		indexID = index.RootIndexID(userID, accessLevel)
+	case index.TypeSearch:
+		indexID = index.SearchIndexID(userID, accessLevel)
+	default:
 		return nil, fmt.Errorf("index type not supported in index store %s (%s): %s", s.ID(), Type, indexType)
 	}
 
@@ -118,10 +125,6 @@ func (s *IndexStore) CreateIndex(ctx context.Context, userID string, indexType s
 		return nil, errors.New("index encryption not supported")
 	}
 
-	// the logic below is for root index creation only
-
-	indexID := index.RootIndexID(userID, accessLevel)
-
 	installed := false
 	err = s.client.DB.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(indexID))
@@ -146,19 +149,44 @@ func (s *IndexStore) CreateIndex(ctx context.Context, userID string, indexType s
 		Algorithm:   Algorithm,
 	}
 
-	if err := InstallIndexSchema(s.client, indexID); err != nil {
-		return nil, err
+	if indexType == index.TypeSearch {
+		params, err := index.ReadSearchIndexParameters(idxOptions.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		props.Algorithm = SearchAlgorithm
+		props.Params = params
+
+		if err = InstallSearchIndexSchema(s.client, indexID); err != nil {
+			return nil, err
+		}
+	} else {
+		if err = InstallIndexSchema(s.client, indexID); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := saveProperties(userID, indexID, props, s.client); err != nil {
 		return nil, err
 	}
 
+	return newIndex(indexID, userID, props, s.client)
+}
+
+func newIndex(id, userID string, props *index.Properties, client *utils.BoltClient) (index.Index, error) {
+	if props.IndexType == index.TypeSearch {
+		return newSearchIndex(id, userID, props, client)
+	}
+
+	if err := upgradePageBuckets(client, id); err != nil {
+		return nil, err
+	}
+
 	return &Index{
-		id:     indexID,
+		id:     id,
 		userID: userID,
 		props:  props,
-		client: s.client,
+		client: client,
 	}, nil
 }
 
@@ -168,12 +196,7 @@ func (s *IndexStore) Index(ctx context.Context, userID string, id string) (index
 		return nil, err
 	}
 
-	return &Index{
-		id:     id,
-		userID: userID,
-		props:  props,
-		client: s.client,
-	}, nil
+	return newIndex(id, userID, props, s.client)
 }
 
 func (s *IndexStore) ListIndexes(ctx context.Context, userID string) ([]*index.Properties, error) {
@@ -23,6 +23,7 @@ import (
 	"github.com/piprate/metalocker/contexts"
 	"github.com/piprate/metalocker/index"
 	. "github.com/piprate/metalocker/index/bolt"
+	"github.com/piprate/metalocker/index/indextest"
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/sdk/testbase"
 	"github.com/stretchr/testify/assert"
@@ -104,3 +105,28 @@ func TestIndexStore_RootIndex(t *testing.T) {
 	assert.Equal(t, index.TypeRoot, props.IndexType)
 	assert.Equal(t, newIdx.ID(), props.Asset)
 }
+
+func TestIndexStore_Conformance(t *testing.T) {
+	indextest.RunStoreTests(t, func(t *testing.T) index.Store {
+		t.Helper()
+
+		dir, err := os.MkdirTemp(".", "tempdir_")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = os.RemoveAll(dir)
+		})
+
+		indexStore, err := NewIndexStore(
+			&index.StoreConfig{
+				ID:   testbase.IndexStoreID,
+				Name: testbase.IndexStoreName,
+				Type: Type,
+				Params: map[string]any{
+					ParameterFilePath: filepath.Join(dir, "wallet.bolt"),
+				},
+			}, nil)
+		require.NoError(t, err)
+
+		return indexStore
+	})
+}
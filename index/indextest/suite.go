@@ -0,0 +1,844 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package indextest contains a conformance test suite for index store implementations.
+package indextest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	GenesisBlockHash = "abc"
+
+	testUserID = "did:piprate:QgH6CZvhjTUFvCbRUw4N6Z"
+)
+
+// StoreFactory returns a new empty index store. The suite takes care of closing it.
+type StoreFactory func(t *testing.T) index.Store
+
+// RunStoreTests runs the conformance test suite against index stores produced by
+// newStore. opts are passed to every CreateIndex call. If opts contain a client
+// encryption key, the suite uses it to unlock root indexes.
+func RunStoreTests(t *testing.T, newStore StoreFactory, opts ...index.Option) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, h *harness)
+	}{
+		{"Bind", testBind},
+		{"RootIndex", testRootIndex},
+		{"AddLockerState", testAddLockerState},
+		{"UpdateTopBlock", testUpdateTopBlock},
+		{"SealLockerState", testSealLockerState},
+		{"AddLease", testAddLease},
+		{"TraverseRecords", testTraverseRecords},
+		{"TraverseVariants", testTraverseVariants},
+		{"AddLeaseRevocation", testAddLeaseRevocation},
+		{"AddRevokedLease", testAddRevokedLease},
+		{"AddLeaseRenewal", testAddLeaseRenewal},
+		{"ListRecords", testListRecords},
+		{"ListVariants", testListVariants},
+		{"ListAssetRecords", testListAssetRecords},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newStore(t)
+			defer func() {
+				_ = store.Close()
+			}()
+
+			idxOpts, err := index.NewOptions(opts...)
+			require.NoError(t, err)
+
+			tc.fn(t, &harness{
+				ctx:   context.Background(),
+				store: store,
+				opts:  opts,
+				key:   idxOpts.ClientKey,
+			})
+		})
+	}
+}
+
+type harness struct {
+	ctx   context.Context
+	store index.Store
+	opts  []index.Option
+	key   []byte
+}
+
+func (h *harness) createRootIndex(t *testing.T) index.RootIndex {
+	t.Helper()
+
+	require.NoError(t, h.store.Bind(h.ctx, GenesisBlockHash))
+
+	ix, err := h.store.CreateIndex(h.ctx, testUserID, index.TypeRoot, model.AccessLevelHosted, h.opts...)
+	require.NoError(t, err)
+
+	return ix.(index.RootIndex)
+}
+
+func (h *harness) rootIndex(t *testing.T) index.RootIndex {
+	t.Helper()
+
+	ix, err := h.store.RootIndex(h.ctx, testUserID, model.AccessLevelHosted)
+	require.NoError(t, err)
+
+	if ix.IsLocked() {
+		require.NoError(t, ix.Unlock(h.key))
+	}
+
+	return ix
+}
+
+func (h *harness) writer(t *testing.T, ix index.Index) index.Writer {
+	t.Helper()
+
+	require.True(t, ix.IsWritable())
+	iw, err := ix.Writer()
+	require.NoError(t, err)
+
+	return iw
+}
+
+type leaseSpec struct {
+	recordID      string
+	lockerID      string
+	participantID string
+	block         int64
+	impressionID  string
+	assetID       string
+	variantOf     string
+	revision      int64
+	resources     []string
+	expiresAt     int64
+}
+
+func newLeaseDataSet(s leaseSpec) model.DataSet {
+	createdAt := time.Date(2022, 1, 1, 0, 0, int(s.block), 0, time.UTC)
+
+	var resources []*model.StoredResource
+	for _, res := range s.resources {
+		resources = append(resources, &model.StoredResource{
+			Type:  model.TypeResource,
+			Asset: res,
+		})
+	}
+
+	r := &model.Record{
+		ID:        s.recordID,
+		Operation: model.OpTypeLease,
+		KeyIndex:  uint32(s.block),
+		Status:    model.StatusPublished,
+		ExpiresAt: s.expiresAt,
+	}
+
+	lease := &model.Lease{
+		ID:        s.recordID,
+		Resources: resources,
+		Impression: &model.Impression{
+			ID:               s.impressionID,
+			Asset:            s.assetID,
+			GeneratedAtTime:  &createdAt,
+			RevisionNumber:   s.revision,
+			SpecializationOf: s.variantOf,
+			MetaResource: &model.MetaResource{
+				ContentType: "Entity",
+			},
+		},
+	}
+
+	return testbase.NewMockDataSet(r, lease, s.block, s.lockerID, s.participantID, nil)
+}
+
+func newRevocationDataSet(recordID, subjectID, lockerID, participantID string, block int64) model.DataSet {
+	r := &model.Record{
+		ID:            recordID,
+		Operation:     model.OpTypeLeaseRevocation,
+		SubjectRecord: subjectID,
+		Status:        model.StatusPublished,
+	}
+
+	return testbase.NewMockDataSet(r, nil, block, lockerID, participantID, nil)
+}
+
+func newRenewalDataSet(recordID, subjectID, lockerID, participantID string, block, expiresAt int64) model.DataSet {
+	r := &model.Record{
+		ID:            recordID,
+		Operation:     model.OpTypeLeaseRenewal,
+		SubjectRecord: subjectID,
+		ExpiresAt:     expiresAt,
+		Status:        model.StatusPublished,
+	}
+
+	return testbase.NewMockDataSet(r, nil, block, lockerID, participantID, nil)
+}
+
+func testBind(t *testing.T, h *harness) {
+	assert.Empty(t, h.store.GenesisBlockHash(h.ctx))
+
+	require.NoError(t, h.store.Bind(h.ctx, GenesisBlockHash))
+	assert.Equal(t, GenesisBlockHash, h.store.GenesisBlockHash(h.ctx))
+
+	// binding to the same ledger is allowed
+
+	require.NoError(t, h.store.Bind(h.ctx, GenesisBlockHash))
+
+	// binding to a different ledger fails
+
+	require.Error(t, h.store.Bind(h.ctx, "xyz"))
+}
+
+func testRootIndex(t *testing.T, h *harness) {
+	require.NoError(t, h.store.Bind(h.ctx, GenesisBlockHash))
+
+	_, err := h.store.RootIndex(h.ctx, testUserID, model.AccessLevelHosted)
+	require.ErrorIs(t, err, index.ErrIndexNotFound)
+
+	newIdx, err := h.store.CreateIndex(h.ctx, testUserID, index.TypeRoot, model.AccessLevelHosted, h.opts...)
+	require.NoError(t, err)
+	assert.False(t, newIdx.IsLocked())
+
+	props := newIdx.Properties()
+	assert.Equal(t, model.AccessLevelHosted, props.AccessLevel)
+	assert.NotEmpty(t, props.Algorithm)
+	assert.Equal(t, index.TypeRoot, props.IndexType)
+	assert.Equal(t, newIdx.ID(), props.Asset)
+
+	_, err = h.store.CreateIndex(h.ctx, testUserID, index.TypeRoot, model.AccessLevelHosted, h.opts...)
+	require.ErrorIs(t, err, index.ErrIndexExists)
+
+	idx := h.rootIndex(t)
+	assert.Equal(t, newIdx.ID(), idx.ID())
+	assert.Equal(t, props, idx.Properties())
+}
+
+func testAddLockerState(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	require.NoError(t, iw.AddLockerState(h.ctx, testUserID, "locker2", 10))
+	require.NoError(t, iw.AddLockerState(h.ctx, testUserID, "locker1", 5))
+
+	// try adding the same locker again
+
+	err := iw.AddLockerState(h.ctx, testUserID, "locker1", 5)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, index.ErrLockerStateExists))
+
+	states, err := h.writer(t, h.rootIndex(t)).LockerStates(h.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []index.LockerState{
+		{ID: "locker1", IndexID: ix.ID(), AccountID: testUserID, FirstBlock: 5, TopBlock: 5},
+		{ID: "locker2", IndexID: ix.ID(), AccountID: testUserID, FirstBlock: 10, TopBlock: 10},
+	}, states)
+}
+
+func testUpdateTopBlock(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	require.NoError(t, iw.AddLockerState(h.ctx, testUserID, "locker1", 5))
+	require.NoError(t, iw.AddLockerState(h.ctx, testUserID, "locker2", 10))
+
+	require.Error(t, iw.UpdateTopBlock(h.ctx, 0))
+	require.NoError(t, iw.UpdateTopBlock(h.ctx, 20))
+
+	states, err := iw.LockerStates(h.ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	for _, ls := range states {
+		assert.Equal(t, int64(20), ls.TopBlock)
+	}
+	assert.Equal(t, int64(5), states[0].FirstBlock)
+}
+
+func testSealLockerState(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	require.NoError(t, iw.AddLockerState(h.ctx, testUserID, "locker1", 5))
+	require.NoError(t, iw.AddLockerState(h.ctx, testUserID, "locker2", 10))
+
+	err := iw.SealLockerState(h.ctx, "locker3", 20)
+	require.True(t, errors.Is(err, index.ErrLockerStateNotFound))
+
+	require.NoError(t, iw.SealLockerState(h.ctx, "locker1", 20))
+
+	// the earliest seal block wins
+	require.NoError(t, iw.SealLockerState(h.ctx, "locker1", 25))
+
+	states, err := h.writer(t, h.rootIndex(t)).LockerStates(h.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []index.LockerState{
+		{ID: "locker1", IndexID: ix.ID(), AccountID: testUserID, FirstBlock: 5, TopBlock: 5, LastBlock: 20},
+		{ID: "locker2", IndexID: ix.ID(), AccountID: testUserID, FirstBlock: 10, TopBlock: 10},
+	}, states)
+	assert.True(t, states[0].IsSealed())
+	assert.False(t, states[1].IsSealed())
+}
+
+func testAddLease(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+		recordID:      "rec1",
+		lockerID:      "locker1",
+		participantID: "party1",
+		block:         3,
+		impressionID:  "imp1",
+		assetID:       "asset1",
+		resources:     []string{"res1", "res2"},
+	}), 3))
+	require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+		recordID:      "rec2",
+		lockerID:      "locker2",
+		participantID: "party1",
+		block:         4,
+		impressionID:  "imp1",
+		assetID:       "asset1",
+		resources:     []string{"res1"},
+	}), 4))
+
+	ix = h.rootIndex(t)
+
+	rs, err := ix.GetRecord(h.ctx, "rec1")
+	require.NoError(t, err)
+	assert.Equal(t, &index.RecordState{
+		ID:            "rec1",
+		Operation:     model.OpTypeLease,
+		Status:        model.StatusPublished,
+		LockerID:      "locker1",
+		ParticipantID: "party1",
+		BlockNumber:   3,
+		Index:         3,
+		ImpressionID:  "imp1",
+		ContentType:   "Entity",
+	}, rs)
+
+	rs, err = ix.GetRecord(h.ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, rs)
+
+	ids, err := ix.GetRecordsByImpressionID(h.ctx, "imp1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rec1", "rec2"}, ids)
+
+	ids, err = ix.GetRecordsByImpressionID(h.ctx, "imp1", map[string]bool{"locker2": true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rec2"}, ids)
+
+	ids, err = ix.GetRecordsByImpressionID(h.ctx, "unknown", nil)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	var assetRecords []string
+	err = ix.TraverseAssetRecords(h.ctx, "asset1", func(recordID string, as *index.AssetState) error {
+		assert.Equal(t, "asset1", as.AssetID)
+		assert.Equal(t, "imp1", as.ImpressionID)
+		assert.Equal(t, int64(1), as.RevisionNumber)
+		assetRecords = append(assetRecords, recordID)
+		return nil
+	}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rec1", "rec2"}, assetRecords)
+
+	err = ix.TraverseAssetRecords(h.ctx, "unknown", func(recordID string, as *index.AssetState) error {
+		return errors.New("unexpected asset record")
+	}, 0)
+	require.NoError(t, err)
+}
+
+func testTraverseRecords(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	specs := []leaseSpec{
+		{recordID: "rec3", lockerID: "lockerB", participantID: "party1"},
+		{recordID: "rec1", lockerID: "lockerB", participantID: "party2"},
+		{recordID: "rec4", lockerID: "lockerA", participantID: "party1"},
+		{recordID: "rec2", lockerID: "lockerB", participantID: "party1"},
+	}
+	for i, s := range specs {
+		s.block = int64(i + 1)
+		s.impressionID = "imp-" + s.recordID
+		s.assetID = "asset-" + s.recordID
+		require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(s), s.block))
+	}
+
+	ix = h.rootIndex(t)
+
+	collect := func(lockerFilter, participantFilter string) []string {
+		var ids []string
+		err := ix.TraverseRecords(h.ctx, lockerFilter, participantFilter, func(r *index.RecordState) error {
+			ids = append(ids, r.ID)
+			return nil
+		}, 0)
+		require.NoError(t, err)
+		return ids
+	}
+
+	// records are ordered by locker, participant and record ID
+
+	assert.Equal(t, []string{"rec4", "rec2", "rec3", "rec1"}, collect("", ""))
+	assert.Equal(t, []string{"rec2", "rec3", "rec1"}, collect("lockerB", ""))
+	assert.Equal(t, []string{"rec4", "rec2", "rec3"}, collect("", "party1"))
+	assert.Equal(t, []string{"rec1"}, collect("lockerB", "party2"))
+	assert.Empty(t, collect("lockerC", ""))
+
+	// visitor errors stop the traversal
+
+	expectedErr := errors.New("stop")
+	count := 0
+	err := ix.TraverseRecords(h.ctx, "", "", func(r *index.RecordState) error {
+		count++
+		return expectedErr
+	}, 0)
+	require.ErrorIs(t, err, expectedErr)
+	assert.Equal(t, 1, count)
+}
+
+func testTraverseVariants(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	specs := []leaseSpec{
+		{recordID: "rec1", lockerID: "locker1", impressionID: "varB", revision: 1},
+		{recordID: "rec2", lockerID: "locker1", impressionID: "impB2", variantOf: "varB", revision: 2},
+		{recordID: "rec3", lockerID: "locker2", impressionID: "impB3", variantOf: "varB", revision: 3},
+		{recordID: "rec4", lockerID: "locker1", impressionID: "varA", revision: 1},
+	}
+	for i, s := range specs {
+		s.block = int64(i + 1)
+		s.participantID = "party1"
+		s.assetID = "asset-" + s.impressionID
+		require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(s), s.block))
+	}
+
+	ix = h.rootIndex(t)
+
+	type visit struct {
+		variantID string
+		masterID  string
+		history   []string
+	}
+
+	collect := func(lockerFilter string, includeHistory bool) []visit {
+		var res []visit
+		err := ix.TraverseVariants(h.ctx, lockerFilter, "", func(variantID string, master *index.VariantRecordState, history []*index.VariantRecordState) error {
+			v := visit{variantID: variantID, masterID: master.ID}
+			for _, rs := range history {
+				v.history = append(v.history, rs.ID)
+			}
+			res = append(res, v)
+			return nil
+		}, includeHistory, 0)
+		require.NoError(t, err)
+		return res
+	}
+
+	assert.Equal(t, []visit{
+		{variantID: "varA", masterID: "rec4"},
+		{variantID: "varB", masterID: "rec3"},
+	}, collect("", false))
+
+	assert.Equal(t, []visit{
+		{variantID: "varA", masterID: "rec4", history: []string{"rec4"}},
+		{variantID: "varB", masterID: "rec3", history: []string{"rec1", "rec2", "rec3"}},
+	}, collect("", true))
+
+	assert.Equal(t, []visit{
+		{variantID: "varB", masterID: "rec3"},
+	}, collect("locker2", false))
+
+	master, hist, err := ix.GetVariant(h.ctx, "varB", true)
+	require.NoError(t, err)
+	assert.Equal(t, "rec3", master.ID)
+	assert.Equal(t, int64(3), master.RevisionNumber)
+	assert.Equal(t, "asset-impB3", master.AssetID)
+	require.Len(t, hist, 3)
+	assert.Equal(t, "rec1", hist[0].ID)
+
+	master, hist, err = ix.GetVariant(h.ctx, "varA", false)
+	require.NoError(t, err)
+	assert.Equal(t, "rec4", master.ID)
+	assert.Empty(t, hist)
+
+	_, _, err = ix.GetVariant(h.ctx, "unknown", false)
+	require.Error(t, err)
+}
+
+func testAddLeaseRevocation(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+		recordID:      "rec1",
+		lockerID:      "locker1",
+		participantID: "party1",
+		block:         1,
+		impressionID:  "imp1",
+		assetID:       "asset1",
+		resources:     []string{"res1"},
+	}), 1))
+	require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+		recordID:      "rec2",
+		lockerID:      "locker1",
+		participantID: "party1",
+		block:         2,
+		impressionID:  "imp2",
+		assetID:       "asset1",
+	}), 2))
+
+	// revocations for unknown lockers or participants fail
+
+	require.Error(t, iw.AddLeaseRevocation(h.ctx, newRevocationDataSet("rev0", "rec1", "locker2", "party1", 3)))
+	require.Error(t, iw.AddLeaseRevocation(h.ctx, newRevocationDataSet("rev0", "rec1", "locker1", "party2", 3)))
+
+	require.NoError(t, iw.AddLeaseRevocation(h.ctx, newRevocationDataSet("rev1", "rec1", "locker1", "party1", 3)))
+
+	// revocation of a record that isn't in the index is accepted
+
+	require.NoError(t, iw.AddLeaseRevocation(h.ctx, newRevocationDataSet("rev2", "rec9", "locker1", "party1", 4)))
+
+	ix = h.rootIndex(t)
+
+	rs, err := ix.GetRecord(h.ctx, "rec1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRevoked, rs.Status)
+	assert.Equal(t, "imp1", rs.ImpressionID)
+
+	rs, err = ix.GetRecord(h.ctx, "rev1")
+	require.NoError(t, err)
+	assert.Equal(t, model.OpTypeLeaseRevocation, rs.Operation)
+	assert.Equal(t, int64(3), rs.BlockNumber)
+
+	var statuses []model.RecordStatus
+	err = ix.TraverseRecords(h.ctx, "", "", func(r *index.RecordState) error {
+		statuses = append(statuses, r.Status)
+		return nil
+	}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []model.RecordStatus{model.StatusRevoked, model.StatusPublished}, statuses)
+
+	ids, err := ix.GetRecordsByImpressionID(h.ctx, "imp1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	var assetRecords []string
+	err = ix.TraverseAssetRecords(h.ctx, "asset1", func(recordID string, as *index.AssetState) error {
+		assetRecords = append(assetRecords, recordID)
+		return nil
+	}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rec2"}, assetRecords)
+}
+
+func testAddRevokedLease(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	ds := newLeaseDataSet(leaseSpec{
+		recordID:      "rec1",
+		lockerID:      "locker1",
+		participantID: "party1",
+		block:         1,
+		impressionID:  "imp1",
+		assetID:       "asset1",
+	})
+	ds.Record().Status = model.StatusRevoked
+
+	require.NoError(t, iw.AddLease(h.ctx, ds, 1))
+
+	ix = h.rootIndex(t)
+
+	rs, err := ix.GetRecord(h.ctx, "rec1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRevoked, rs.Status)
+	assert.Empty(t, rs.ImpressionID)
+
+	// revoked leases are only added to the record lookup
+
+	err = ix.TraverseRecords(h.ctx, "", "", func(r *index.RecordState) error {
+		return errors.New("unexpected record")
+	}, 0)
+	require.NoError(t, err)
+
+	ids, err := ix.GetRecordsByImpressionID(h.ctx, "imp1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func testListRecords(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	// record IDs are intentionally out of block order
+	blocks := map[string]int64{"rec1": 5, "rec2": 1, "rec3": 3, "rec4": 2, "rec5": 4}
+	for id, block := range blocks {
+		lockerID := "locker1"
+		if id == "rec5" {
+			lockerID = "locker2"
+		}
+		require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+			recordID:      id,
+			lockerID:      lockerID,
+			participantID: "party1",
+			block:         block,
+			impressionID:  "imp-" + id,
+			assetID:       "asset-" + id,
+		}), block))
+	}
+
+	ix = h.rootIndex(t)
+
+	collect := func(lockerFilter string, opts index.PageOptions) ([]string, int) {
+		var ids []string
+		pages := 0
+		for {
+			page, err := ix.ListRecords(h.ctx, lockerFilter, "", opts)
+			require.NoError(t, err)
+			pages++
+			for _, rs := range page.Records {
+				ids = append(ids, rs.ID)
+			}
+			if page.NextCursor == "" {
+				return ids, pages
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+
+	ids, pages := collect("", index.PageOptions{PageSize: 2})
+	assert.Equal(t, []string{"rec2", "rec4", "rec3", "rec5", "rec1"}, ids)
+	assert.Equal(t, 3, pages)
+
+	ids, pages = collect("", index.PageOptions{PageSize: 2, Order: index.SortDescending})
+	assert.Equal(t, []string{"rec1", "rec5", "rec3", "rec4", "rec2"}, ids)
+	assert.Equal(t, 3, pages)
+
+	ids, pages = collect("locker1", index.PageOptions{PageSize: 4})
+	assert.Equal(t, []string{"rec2", "rec4", "rec3", "rec1"}, ids)
+	assert.Equal(t, 1, pages)
+
+	// new records don't affect the following pages
+
+	page, err := ix.ListRecords(h.ctx, "", "", index.PageOptions{PageSize: 2})
+	require.NoError(t, err)
+	require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+		recordID:      "rec0",
+		lockerID:      "locker1",
+		participantID: "party1",
+		block:         1,
+		impressionID:  "imp-rec0",
+		assetID:       "asset-rec0",
+	}), 1))
+	page, err = ix.ListRecords(h.ctx, "", "", index.PageOptions{PageSize: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Records, 2)
+	assert.Equal(t, "rec3", page.Records[0].ID)
+
+	_, err = ix.ListRecords(h.ctx, "", "", index.PageOptions{Cursor: "bad cursor"})
+	require.ErrorIs(t, err, index.ErrInvalidCursor)
+}
+
+func testListVariants(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	specs := []leaseSpec{
+		{recordID: "rec1", block: 1, impressionID: "varA", revision: 1},
+		{recordID: "rec2", block: 2, impressionID: "varB", revision: 1},
+		{recordID: "rec3", block: 3, impressionID: "impA2", variantOf: "varA", revision: 2},
+		{recordID: "rec4", block: 4, impressionID: "varC", revision: 1},
+	}
+	for _, s := range specs {
+		s.lockerID = "locker1"
+		s.participantID = "party1"
+		s.assetID = "asset-" + s.impressionID
+		require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(s), s.block))
+	}
+
+	ix = h.rootIndex(t)
+
+	// variants are ordered by their master record's block
+
+	page, err := ix.ListVariants(h.ctx, "", "", true, index.PageOptions{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Variants, 2)
+	assert.Equal(t, "varB", page.Variants[0].VariantID)
+	assert.Equal(t, "varA", page.Variants[1].VariantID)
+	assert.Equal(t, "rec3", page.Variants[1].Master.ID)
+	assert.Len(t, page.Variants[1].History, 2)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = ix.ListVariants(h.ctx, "", "", true, index.PageOptions{PageSize: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Variants, 1)
+	assert.Equal(t, "varC", page.Variants[0].VariantID)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = ix.ListVariants(h.ctx, "", "", false, index.PageOptions{PageSize: 1, Order: index.SortDescending})
+	require.NoError(t, err)
+	require.Len(t, page.Variants, 1)
+	assert.Equal(t, "varC", page.Variants[0].VariantID)
+	assert.Empty(t, page.Variants[0].History)
+
+	// a cursor can't be used with a different sort order
+
+	_, err = ix.ListVariants(h.ctx, "", "", false, index.PageOptions{Cursor: page.NextCursor, Order: index.SortAscending})
+	require.ErrorIs(t, err, index.ErrInvalidCursor)
+
+	// filtered variants are paged without gaps
+
+	require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+		recordID:      "rec5",
+		lockerID:      "locker2",
+		participantID: "party1",
+		block:         5,
+		impressionID:  "varD",
+		assetID:       "asset-varD",
+		revision:      1,
+	}), 5))
+
+	ix = h.rootIndex(t)
+
+	var ids []string
+	opts := index.PageOptions{PageSize: 1}
+	for {
+		page, err = ix.ListVariants(h.ctx, "locker1", "", false, opts)
+		require.NoError(t, err)
+		for _, v := range page.Variants {
+			ids = append(ids, v.VariantID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"varB", "varA", "varC"}, ids)
+}
+
+func testListAssetRecords(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	for id, block := range map[string]int64{"rec1": 3, "rec2": 1, "rec3": 2} {
+		require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+			recordID:      id,
+			lockerID:      "locker1",
+			participantID: "party1",
+			block:         block,
+			impressionID:  "imp-" + id,
+			assetID:       "asset1",
+		}), block))
+	}
+
+	ix = h.rootIndex(t)
+
+	page, err := ix.ListAssetRecords(h.ctx, "asset1", index.PageOptions{Order: index.SortDescending})
+	require.NoError(t, err)
+	require.Len(t, page.Records, 3)
+	assert.Equal(t, "rec1", page.Records[0].RecordID)
+	assert.Equal(t, int64(3), page.Records[0].BlockNumber)
+	assert.Equal(t, "imp-rec1", page.Records[0].State.ImpressionID)
+	assert.Equal(t, "rec3", page.Records[1].RecordID)
+	assert.Equal(t, "rec2", page.Records[2].RecordID)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = ix.ListAssetRecords(h.ctx, "unknown", index.PageOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Records)
+
+	// revoked records are removed from asset record pages
+
+	require.NoError(t, iw.AddLeaseRevocation(h.ctx, newRevocationDataSet("rev1", "rec3", "locker1", "party1", 4)))
+
+	ix = h.rootIndex(t)
+
+	page, err = ix.ListAssetRecords(h.ctx, "asset1", index.PageOptions{PageSize: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Records, 1)
+	assert.Equal(t, "rec2", page.Records[0].RecordID)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = ix.ListAssetRecords(h.ctx, "asset1", index.PageOptions{PageSize: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Records, 1)
+	assert.Equal(t, "rec1", page.Records[0].RecordID)
+	assert.Empty(t, page.NextCursor)
+}
+
+func testAddLeaseRenewal(t *testing.T, h *harness) {
+	ix := h.createRootIndex(t)
+	iw := h.writer(t, ix)
+
+	require.NoError(t, iw.AddLease(h.ctx, newLeaseDataSet(leaseSpec{
+		recordID:      "rec1",
+		lockerID:      "locker1",
+		participantID: "party1",
+		block:         1,
+		impressionID:  "imp1",
+		assetID:       "asset1",
+		expiresAt:     1000,
+	}), 1))
+
+	// renewals for unknown lockers or participants fail
+
+	require.Error(t, iw.AddLeaseRenewal(h.ctx, newRenewalDataSet("ren0", "rec1", "locker2", "party1", 2, 2000)))
+	require.Error(t, iw.AddLeaseRenewal(h.ctx, newRenewalDataSet("ren0", "rec1", "locker1", "party2", 2, 2000)))
+
+	require.NoError(t, iw.AddLeaseRenewal(h.ctx, newRenewalDataSet("ren1", "rec1", "locker1", "party1", 2, 2000)))
+
+	// earlier expiry times are ignored
+
+	require.NoError(t, iw.AddLeaseRenewal(h.ctx, newRenewalDataSet("ren2", "rec1", "locker1", "party1", 3, 1500)))
+
+	// renewal of a record that isn't in the index is accepted
+
+	require.NoError(t, iw.AddLeaseRenewal(h.ctx, newRenewalDataSet("ren3", "rec9", "locker1", "party1", 4, 2000)))
+
+	ix = h.rootIndex(t)
+
+	rs, err := ix.GetRecord(h.ctx, "rec1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPublished, rs.Status)
+	assert.Equal(t, int64(2000), rs.ExpiresAt)
+
+	rs, err = ix.GetRecord(h.ctx, "ren1")
+	require.NoError(t, err)
+	assert.Equal(t, model.OpTypeLeaseRenewal, rs.Operation)
+	assert.Equal(t, int64(2), rs.BlockNumber)
+
+	var expiries []int64
+	err = ix.TraverseRecords(h.ctx, "locker1", "party1", func(r *index.RecordState) error {
+		expiries = append(expiries, r.ExpiresAt)
+		return nil
+	}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2000}, expiries)
+}
@@ -30,6 +30,7 @@ var (
 	ErrIndexStoreNotFound  = errors.New("index store not found")
 	ErrLockerStateNotFound = errors.New("locker state not found")
 	ErrLockerStateExists   = errors.New("locker state already exists")
+	ErrVariantNotFound     = errors.New("variant not found in index")
 )
 
 const (
@@ -47,6 +48,9 @@ type (
 		AccountID  string `json:"accountID"`
 		FirstBlock int64  `json:"firstBlock,omitempty"`
 		TopBlock   int64  `json:"topBlock,omitempty"`
+		// LastBlock is the number of the block that contains the locker's seal record.
+		// The index ignores any records for the locker in later blocks.
+		LastBlock int64 `json:"lastBlock,omitempty"`
 	}
 
 	Properties struct {
@@ -82,8 +86,12 @@ type (
 
 		LockerStates(ctx context.Context) ([]LockerState, error)
 		AddLockerState(ctx context.Context, accountID, lockerID string, firstBlock int64) error
+		// SealLockerState marks the locker as sealed at the given block. If the locker
+		// was already sealed, the earliest block is kept.
+		SealLockerState(ctx context.Context, lockerID string, lastBlock int64) error
 		AddLease(ctx context.Context, ds model.DataSet, effectiveBlockNumber int64) error
 		AddLeaseRevocation(ctx context.Context, ds model.DataSet) error
+		AddLeaseRenewal(ctx context.Context, ds model.DataSet) error
 		UpdateTopBlock(ctx context.Context, blockNumber int64) error
 	}
 
@@ -176,6 +184,13 @@ func WithEncryption(key []byte) Option {
 	}
 }
 
+func WithParameters(params any) Option {
+	return func(o *Options) error {
+		o.Parameters = params
+		return nil
+	}
+}
+
 func WithOptions(opts Options) Option {
 	return func(o *Options) error {
 		*o = opts
@@ -0,0 +1,156 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	SortAscending  SortOrder = "asc"
+	SortDescending SortOrder = "desc"
+
+	DefaultPageSize = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+type (
+	SortOrder string
+
+	// PageOptions controls cursor-based traversals. Items are ordered by block number
+	// and then by an index-specific key (record ID in unencrypted indexes). To get the next page, pass the NextCursor value returned with
+	// the previous page. The cursor remembers the sort order of the first page.
+	PageOptions struct {
+		Cursor   string    `json:"cursor,omitempty"`
+		PageSize int       `json:"pageSize,omitempty"`
+		Order    SortOrder `json:"order,omitempty"`
+	}
+
+	RecordPage struct {
+		Records    []*RecordState `json:"records"`
+		NextCursor string         `json:"nextCursor,omitempty"`
+	}
+
+	VariantPageEntry struct {
+		VariantID string                `json:"id"`
+		Master    *VariantRecordState   `json:"master"`
+		History   []*VariantRecordState `json:"history,omitempty"`
+	}
+
+	// VariantPage contains variants ordered by the block number of their master records.
+	// Locker and participant filters don't affect the order: if the filtered master
+	// differs from the variant's master, the variant keeps its position.
+	VariantPage struct {
+		Variants   []*VariantPageEntry `json:"variants"`
+		NextCursor string              `json:"nextCursor,omitempty"`
+	}
+
+	AssetRecordPageEntry struct {
+		RecordID    string      `json:"id"`
+		BlockNumber int64       `json:"blockNumber"`
+		State       *AssetState `json:"state"`
+	}
+
+	AssetRecordPage struct {
+		Records    []*AssetRecordPageEntry `json:"records"`
+		NextCursor string                  `json:"nextCursor,omitempty"`
+	}
+
+	// PageCursor is the content of an opaque cursor. It points to the last item
+	// of the previous page. Key is an implementation-specific tie-breaker
+	// for items that belong to the same block.
+	PageCursor struct {
+		Order SortOrder `json:"o"`
+		Block int64     `json:"b"`
+		Key   string    `json:"k"`
+	}
+
+	// PageRequest is the validated form of PageOptions. Index implementations
+	// should fetch up to Limit+1 items that follow After (if present) in the requested
+	// Order to find out if there is a next page.
+	PageRequest struct {
+		Order SortOrder
+		After *PageCursor
+		Limit int
+	}
+)
+
+func encodeCursor(c *PageCursor) string {
+	b, _ := jsonw.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*PageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c PageCursor
+	if err = jsonw.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Order != SortAscending && c.Order != SortDescending {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// NewPageRequest validates page options and decodes the cursor, if provided.
+func NewPageRequest(opts PageOptions) (*PageRequest, error) {
+	req := &PageRequest{
+		Order: opts.Order,
+		Limit: opts.PageSize,
+	}
+	if req.Order == "" {
+		req.Order = SortAscending
+	}
+	if req.Limit <= 0 {
+		req.Limit = DefaultPageSize
+	}
+
+	if opts.Cursor != "" {
+		after, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Order != "" && opts.Order != after.Order {
+			return nil, ErrInvalidCursor
+		}
+		req.Order = after.Order
+		req.After = after
+	}
+
+	if req.Order != SortAscending && req.Order != SortDescending {
+		return nil, errors.New("unsupported sort order: " + string(req.Order))
+	}
+
+	return req, nil
+}
+
+// Descending returns true if the items should be returned in descending order.
+func (r *PageRequest) Descending() bool {
+	return r.Order == SortDescending
+}
+
+// NextCursor returns the cursor that points to the item with the given block number and key.
+func (r *PageRequest) NextCursor(block int64, key string) string {
+	return encodeCursor(&PageCursor{Order: r.Order, Block: block, Key: key})
+}
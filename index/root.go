@@ -38,6 +38,7 @@ type (
 		Index         uint32             `json:"index"`
 		ImpressionID  string             `json:"impression,omitempty"`
 		ContentType   string             `json:"contentType,omitempty"`
+		ExpiresAt     int64              `json:"expiresAt,omitempty"`
 	}
 
 	AssetState struct {
@@ -79,6 +80,14 @@ type (
 		TraverseAssetRecords(ctx context.Context, assetID string, vFunc AssetRecordVisitor, maxRecords uint64) error
 		GetRecordsByImpressionID(ctx context.Context, impID string, lockerFilter map[string]bool) ([]string, error)
 		GetVariant(ctx context.Context, variantID string, includeHistory bool) (*VariantRecordState, []*VariantRecordState, error)
+
+		// ListRecords, ListVariants and ListAssetRecords are cursor-based counterparts
+		// of the traversal functions above. They return one page of results at a time,
+		// ordered by block number, and a cursor to resume the traversal.
+
+		ListRecords(ctx context.Context, lockerFilter, participantFilter string, opts PageOptions) (*RecordPage, error)
+		ListVariants(ctx context.Context, lockerFilter, participantFilter string, includeHistory bool, opts PageOptions) (*VariantPage, error)
+		ListAssetRecords(ctx context.Context, assetID string, opts PageOptions) (*AssetRecordPage, error)
 	}
 
 	RootIndexParameters struct {
@@ -86,6 +95,19 @@ type (
 	}
 )
 
+// IsSealed returns true if the locker was sealed.
+func (ls *LockerState) IsSealed() bool {
+	return ls.LastBlock != 0
+}
+
+// Seal marks the locker as sealed at the given block. If the locker was already sealed,
+// the earliest block is kept.
+func (ls *LockerState) Seal(lastBlock int64) {
+	if ls.LastBlock == 0 || lastBlock < ls.LastBlock {
+		ls.LastBlock = lastBlock
+	}
+}
+
 func (ls *LockerState) Bytes() []byte {
 	b, _ := jsonw.Marshal(ls)
 	return b
@@ -0,0 +1,221 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	TypeSearch = "idx:search"
+
+	// minTermLength is the minimum length of a term that gets included
+	// in a full-text index.
+	minTermLength = 2
+)
+
+// DefaultSearchProperties is the list of meta resource properties that are indexed
+// as searchable fields, if the search index parameters don't specify otherwise.
+var DefaultSearchProperties = []string{"@type", "type", "name", "description", "keywords"}
+
+type (
+	// SearchIndexParameters defines which meta resource properties are indexed
+	// as searchable fields. All string values in meta resources are indexed
+	// for full-text search, regardless of these parameters.
+	SearchIndexParameters struct {
+		Properties []string `json:"properties,omitempty"`
+	}
+
+	// SearchQuery defines search criteria. All criteria need to match for a dataset
+	// to be included into search results.
+	SearchQuery struct {
+		// Text is a free text query. All terms in the text must be present in the dataset's
+		// meta resource.
+		Text string `json:"text,omitempty"`
+		// ContentType is the dataset's content type (i.e. the meta resource's semantic type).
+		ContentType string `json:"contentType,omitempty"`
+		// Properties contains property values to match. Only properties included into
+		// the search index parameters are searchable. Values are matched case-insensitively.
+		Properties map[string]string `json:"properties,omitempty"`
+		// LockerID limits search results to the given locker.
+		LockerID      string     `json:"locker,omitempty"`
+		CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+		CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+		// Limit is the maximum number of results. Zero means no limit.
+		Limit int `json:"limit,omitempty"`
+	}
+
+	SearchResult struct {
+		RecordID      string     `json:"id"`
+		ImpressionID  string     `json:"impression"`
+		AssetID       string     `json:"asset,omitempty"`
+		LockerID      string     `json:"locker"`
+		ParticipantID string     `json:"participant"`
+		BlockNumber   int64      `json:"blockNumber"`
+		ContentType   string     `json:"contentType,omitempty"`
+		CreatedAt     *time.Time `json:"createdAt,omitempty"`
+		Score         int        `json:"score,omitempty"`
+	}
+
+	// SearchIndex is an index that supports full-text and metadata search over
+	// datasets' meta resources.
+	SearchIndex interface {
+		Index
+
+		Search(ctx context.Context, q *SearchQuery) ([]*SearchResult, error)
+	}
+)
+
+func SearchIndexID(userID string, accessLevel model.AccessLevel) string {
+	return fmt.Sprintf("%s#search#%d", userID, accessLevel)
+}
+
+// ReadSearchIndexParameters converts index parameters (as provided in Options.Parameters)
+// into SearchIndexParameters. If no properties are specified, DefaultSearchProperties are used.
+func ReadSearchIndexParameters(params any) (*SearchIndexParameters, error) {
+	res := &SearchIndexParameters{}
+	if params != nil {
+		b, err := jsonw.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		if err = jsonw.Unmarshal(b, res); err != nil {
+			return nil, fmt.Errorf("invalid search index parameters: %w", err)
+		}
+	}
+
+	if len(res.Properties) == 0 {
+		res.Properties = DefaultSearchProperties
+	}
+
+	return res, nil
+}
+
+// Tokenize splits text into lowercase terms, suitable for full-text indexing.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if len(f) >= minTermLength {
+			terms = append(terms, f)
+		}
+	}
+
+	return terms
+}
+
+// NormaliseFieldValue converts property values into the form used for field matching.
+func NormaliseFieldValue(val string) string {
+	return strings.ToLower(strings.TrimSpace(val))
+}
+
+// ExtractSearchTerms walks the given JSON document (decoded into generic maps and slices)
+// and returns frequencies of all terms found in its string values, and normalised
+// values of the given properties. Properties are matched by name at any level
+// of the document.
+func ExtractSearchTerms(doc any, properties []string) (map[string]int, map[string][]string) {
+	terms := make(map[string]int)
+	fields := make(map[string][]string)
+
+	propSet := make(map[string]bool, len(properties))
+	for _, p := range properties {
+		propSet[p] = true
+	}
+
+	var walk func(key string, val any)
+	walk = func(key string, val any) {
+		switch v := val.(type) {
+		case map[string]any:
+			for k, item := range v {
+				walk(k, item)
+			}
+		case []any:
+			for _, item := range v {
+				walk(key, item)
+			}
+		case string:
+			for _, t := range Tokenize(v) {
+				terms[t]++
+			}
+			if propSet[key] {
+				fields[key] = append(fields[key], NormaliseFieldValue(v))
+			}
+		case nil:
+		default:
+			if propSet[key] {
+				fields[key] = append(fields[key], NormaliseFieldValue(fmt.Sprint(v)))
+			}
+		}
+	}
+	walk("", doc)
+
+	for k, vals := range fields {
+		sort.Strings(vals)
+		fields[k] = vals
+	}
+
+	return terms, fields
+}
+
+// Matches returns true if the given search result satisfies the query's
+// content type, locker and creation time criteria.
+func (q *SearchQuery) Matches(res *SearchResult) bool {
+	if q.ContentType != "" && res.ContentType != q.ContentType {
+		return false
+	}
+	if q.LockerID != "" && res.LockerID != q.LockerID {
+		return false
+	}
+	if q.CreatedAfter != nil && (res.CreatedAt == nil || !res.CreatedAt.After(*q.CreatedAfter)) {
+		return false
+	}
+	if q.CreatedBefore != nil && (res.CreatedAt == nil || !res.CreatedAt.Before(*q.CreatedBefore)) {
+		return false
+	}
+	return true
+}
+
+// SortSearchResults orders search results by score (descending), then by creation
+// time (most recent first) and record ID.
+func SortSearchResults(results []*SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		switch {
+		case a.CreatedAt != nil && b.CreatedAt != nil:
+			if !a.CreatedAt.Equal(*b.CreatedAt) {
+				return a.CreatedAt.After(*b.CreatedAt)
+			}
+		case a.CreatedAt != nil:
+			return true
+		case b.CreatedAt != nil:
+			return false
+		}
+		return a.RecordID < b.RecordID
+	})
+}
@@ -0,0 +1,979 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/piprate/metalocker/utils/measure"
+	"github.com/rs/zerolog/log"
+)
+
+type (
+	Index struct {
+		id       string
+		userID   string
+		props    *index.Properties
+		mode     index.EncryptionMode
+		db       *sql.DB
+		keys     *indexKeys
+		keyCheck string
+	}
+
+	// assetEntry is the content of index_assets.state column.
+	assetEntry struct {
+		RecordID string            `json:"record"`
+		State    *index.AssetState `json:"state"`
+	}
+
+	// queryer is implemented by both *sql.DB and *sql.Tx.
+	queryer interface {
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	}
+
+	// variantEntry is the content of index_variants.state column.
+	variantEntry struct {
+		VariantID string                    `json:"variant"`
+		State     *index.VariantRecordState `json:"state"`
+	}
+)
+
+var _ index.RootIndex = (*Index)(nil)
+var _ index.Writer = (*Index)(nil)
+
+func (ix *Index) ID() string {
+	return ix.id
+}
+
+func (ix *Index) Properties() *index.Properties {
+	return ix.props
+}
+
+func (ix *Index) Close() error {
+	log.Debug().Msg("Closing SQL index")
+	return nil
+}
+
+func (ix *Index) IsLocked() bool {
+	return ix.mode == index.ModeClientEncryption && ix.keys == nil
+}
+
+func (ix *Index) Unlock(key []byte) error {
+	if ix.mode != index.ModeClientEncryption {
+		return nil
+	}
+
+	keys := newIndexKeys(key)
+	if !keys.matches(ix.keyCheck) {
+		return ErrInvalidKey
+	}
+
+	ix.keys = keys
+
+	return nil
+}
+
+func (ix *Index) Lock() {
+	if ix.mode == index.ModeClientEncryption {
+		ix.keys = nil
+	}
+}
+
+func (ix *Index) IsWritable() bool {
+	return true
+}
+
+func (ix *Index) Writer() (index.Writer, error) {
+	return ix, nil
+}
+
+func (ix *Index) checkUnlocked() error {
+	if ix.IsLocked() {
+		return ErrIndexLocked
+	}
+	return nil
+}
+
+func (ix *Index) seal(v any) ([]byte, error) {
+	b, err := jsonw.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return ix.keys.seal(b)
+}
+
+func (ix *Index) open(data []byte, v any) error {
+	b, err := ix.keys.open(data)
+	if err != nil {
+		return err
+	}
+	return jsonw.Unmarshal(b, v)
+}
+
+func (ix *Index) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := ix.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ix *Index) putRecordLookup(ctx context.Context, tx *sql.Tx, rs *index.RecordState) error {
+	state, err := ix.seal(rs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO index_record_lookup (index_id, record_key, state) VALUES ($1, $2, $3)
+		ON CONFLICT (index_id, record_key) DO UPDATE SET state = excluded.state`,
+		ix.id, ix.keys.lookup(rs.ID), state)
+	return err
+}
+
+func (ix *Index) AddLease(ctx context.Context, ds model.DataSet, effectiveBlockNumber int64) error {
+	defer measure.ExecTime("index.AddLease")()
+
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	r := ds.Record()
+	lockerID := ds.LockerID()
+	participantID := ds.ParticipantID()
+	blockNumber := ds.BlockNumber()
+
+	if r.Status == model.StatusRevoked {
+		rs := &index.RecordState{
+			ID:            r.ID,
+			Operation:     r.Operation,
+			Status:        model.StatusRevoked,
+			LockerID:      lockerID,
+			ParticipantID: participantID,
+			BlockNumber:   blockNumber,
+			Index:         r.KeyIndex,
+		}
+
+		return ix.withTx(ctx, func(tx *sql.Tx) error {
+			return ix.putRecordLookup(ctx, tx, rs)
+		})
+	}
+
+	lease := ds.Lease()
+
+	impID := lease.Impression.ID
+	contentType := lease.Impression.MetaResource.ContentType
+
+	rs := &index.RecordState{
+		ID:            r.ID,
+		Operation:     r.Operation,
+		Status:        model.StatusPublished,
+		LockerID:      lockerID,
+		ParticipantID: participantID,
+		BlockNumber:   blockNumber,
+		Index:         r.KeyIndex,
+		ImpressionID:  impID,
+		ContentType:   contentType,
+		ExpiresAt:     r.ExpiresAt,
+	}
+
+	as := &assetEntry{
+		RecordID: r.ID,
+		State: &index.AssetState{
+			ImpressionID:     impID,
+			AssetID:          lease.Impression.Asset,
+			ContentType:      contentType,
+			RevisionNumber:   lease.Impression.Revision(),
+			WasRevisionOf:    lease.Impression.WasRevisionOf,
+			SpecializationOf: lease.Impression.SpecializationOf,
+		},
+	}
+
+	vrs := &variantEntry{
+		VariantID: lease.Impression.GetVariantID(),
+		State: &index.VariantRecordState{
+			ID:             r.ID,
+			Operation:      r.Operation,
+			Status:         model.StatusPublished,
+			LockerID:       lockerID,
+			ParticipantID:  participantID,
+			BlockNumber:    blockNumber,
+			Index:          r.KeyIndex,
+			AssetID:        lease.Impression.Asset,
+			ImpressionID:   impID,
+			RevisionNumber: lease.Impression.Revision(),
+			ContentType:    contentType,
+			CreatedAt:      lease.Impression.GeneratedAtTime,
+		},
+	}
+
+	recordKey := ix.keys.lookup(r.ID)
+	lockerKey := ix.keys.lookup(lockerID)
+
+	return ix.withTx(ctx, func(tx *sql.Tx) error {
+
+		// add record state
+
+		state, err := ix.seal(rs)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO index_records (index_id, locker_key, participant_key, record_key, block_number, state) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (index_id, locker_key, participant_key, record_key) DO UPDATE SET block_number = excluded.block_number, state = excluded.state`,
+			ix.id, lockerKey, ix.keys.lookup(participantID), recordKey, blockNumber, state); err != nil {
+			return err
+		}
+
+		// update record lookup
+
+		if err = ix.putRecordLookup(ctx, tx, rs); err != nil {
+			return err
+		}
+
+		// update impression lookup
+
+		sealedRecordID, err := ix.seal(r.ID)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO index_impressions (index_id, impression_key, record_key, locker_key, record) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (index_id, impression_key, record_key) DO UPDATE SET locker_key = excluded.locker_key, record = excluded.record`,
+			ix.id, ix.keys.lookup(impID), recordKey, lockerKey, sealedRecordID); err != nil {
+			return err
+		}
+
+		// update resource lookup
+
+		for _, res := range lease.Resources {
+			if _, err = tx.ExecContext(ctx,
+				`INSERT INTO index_resources (index_id, resource_key, record_key, locker_key, record) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (index_id, resource_key, record_key) DO UPDATE SET locker_key = excluded.locker_key, record = excluded.record`,
+				ix.id, ix.keys.lookup(res.Asset), recordKey, lockerKey, sealedRecordID); err != nil {
+				return err
+			}
+		}
+
+		// update asset record lookup
+
+		if state, err = ix.seal(as); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO index_assets (index_id, asset_key, record_key, block_number, state) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (index_id, asset_key, record_key) DO UPDATE SET block_number = excluded.block_number, state = excluded.state`,
+			ix.id, ix.keys.lookup(as.State.AssetID), recordKey, blockNumber, state); err != nil {
+			return err
+		}
+
+		// update variant lookup
+
+		if state, err = ix.seal(vrs); err != nil {
+			return err
+		}
+		variantKey := ix.keys.lookup(vrs.VariantID)
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO index_variants (index_id, variant_key, record_key, state) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (index_id, variant_key, record_key) DO UPDATE SET state = excluded.state`,
+			ix.id, variantKey, recordKey, state); err != nil {
+			return err
+		}
+
+		// update variant's position in cursor-based traversals
+
+		entries, err := ix.loadVariantRecords(ctx, tx,
+			`SELECT state FROM index_variants WHERE index_id = $1 AND variant_key = $2`, ix.id, variantKey)
+		if err != nil {
+			return err
+		}
+		records := make([]*index.VariantRecordState, len(entries))
+		for i, e := range entries {
+			records[i] = e.State
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO index_variant_heads (index_id, variant_key, block_number) VALUES ($1, $2, $3)
+			ON CONFLICT (index_id, variant_key) DO UPDATE SET block_number = excluded.block_number`,
+			ix.id, variantKey, selectMaster(records).BlockNumber)
+
+		return err
+	})
+}
+
+func (ix *Index) AddLeaseRevocation(ctx context.Context, ds model.DataSet) error {
+	defer measure.ExecTime("index.AddLeaseRevocation")()
+
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	r := ds.Record()
+	lockerID := ds.LockerID()
+	participantID := ds.ParticipantID()
+	blockNumber := ds.BlockNumber()
+
+	rs := &index.RecordState{
+		ID:            r.ID,
+		Operation:     r.Operation,
+		Status:        model.StatusPublished,
+		LockerID:      lockerID,
+		ParticipantID: participantID,
+		BlockNumber:   blockNumber,
+		Index:         r.KeyIndex,
+	}
+
+	lockerKey := ix.keys.lookup(lockerID)
+	participantKey := ix.keys.lookup(participantID)
+	subjectKey := ix.keys.lookup(r.SubjectRecord)
+
+	return ix.withTx(ctx, func(tx *sql.Tx) error {
+
+		// add the revocation record to record lookup
+
+		if err := ix.putRecordLookup(ctx, tx, rs); err != nil {
+			return err
+		}
+
+		// update subject record state (locker participant)
+
+		var found bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM index_records WHERE index_id = $1 AND locker_key = $2)`,
+			ix.id, lockerKey).Scan(&found); err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("lease revocation failed for %s: source locker not found: %s", r.SubjectRecord, lockerID)
+		}
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM index_records WHERE index_id = $1 AND locker_key = $2 AND participant_key = $3)`,
+			ix.id, lockerKey, participantKey).Scan(&found); err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("lease revocation failed for %s: source participant not found: %s", r.SubjectRecord, participantID)
+		}
+
+		var subj []byte
+		err := tx.QueryRowContext(ctx,
+			`SELECT state FROM index_records WHERE index_id = $1 AND locker_key = $2 AND participant_key = $3 AND record_key = $4`,
+			ix.id, lockerKey, participantKey, subjectKey).Scan(&subj)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// subject record wasn't saved in the wallet
+				return nil
+			}
+			return err
+		}
+
+		var subjRS index.RecordState
+		if err = ix.open(subj, &subjRS); err != nil {
+			return err
+		}
+
+		subjRS.Status = model.StatusRevoked
+
+		state, err := ix.seal(&subjRS)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx,
+			`UPDATE index_records SET state = $1 WHERE index_id = $2 AND locker_key = $3 AND participant_key = $4 AND record_key = $5`,
+			state, ix.id, lockerKey, participantKey, subjectKey); err != nil {
+			return err
+		}
+
+		// update subject record lookup
+
+		if err = ix.putRecordLookup(ctx, tx, &subjRS); err != nil {
+			return err
+		}
+
+		// drop record from impression, resource and asset record lookups
+
+		if _, err = tx.ExecContext(ctx,
+			`DELETE FROM index_impressions WHERE index_id = $1 AND impression_key = $2 AND record_key = $3`,
+			ix.id, ix.keys.lookup(subjRS.ImpressionID), subjectKey); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx,
+			`DELETE FROM index_resources WHERE index_id = $1 AND record_key = $2`, ix.id, subjectKey); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM index_assets WHERE index_id = $1 AND record_key = $2`, ix.id, subjectKey)
+
+		return err
+	})
+}
+
+func (ix *Index) AddLeaseRenewal(ctx context.Context, ds model.DataSet) error {
+	defer measure.ExecTime("index.AddLeaseRenewal")()
+
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	r := ds.Record()
+	lockerID := ds.LockerID()
+	participantID := ds.ParticipantID()
+
+	rs := &index.RecordState{
+		ID:            r.ID,
+		Operation:     r.Operation,
+		Status:        model.StatusPublished,
+		LockerID:      lockerID,
+		ParticipantID: participantID,
+		BlockNumber:   ds.BlockNumber(),
+		Index:         r.KeyIndex,
+		ExpiresAt:     r.ExpiresAt,
+	}
+
+	lockerKey := ix.keys.lookup(lockerID)
+	participantKey := ix.keys.lookup(participantID)
+	subjectKey := ix.keys.lookup(r.SubjectRecord)
+
+	return ix.withTx(ctx, func(tx *sql.Tx) error {
+
+		// add the renewal record to record lookup
+
+		if err := ix.putRecordLookup(ctx, tx, rs); err != nil {
+			return err
+		}
+
+		// update subject record state (locker participant)
+
+		var found bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM index_records WHERE index_id = $1 AND locker_key = $2)`,
+			ix.id, lockerKey).Scan(&found); err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("lease renewal failed for %s: source locker not found: %s", r.SubjectRecord, lockerID)
+		}
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM index_records WHERE index_id = $1 AND locker_key = $2 AND participant_key = $3)`,
+			ix.id, lockerKey, participantKey).Scan(&found); err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("lease renewal failed for %s: source participant not found: %s", r.SubjectRecord, participantID)
+		}
+
+		var subj []byte
+		err := tx.QueryRowContext(ctx,
+			`SELECT state FROM index_records WHERE index_id = $1 AND locker_key = $2 AND participant_key = $3 AND record_key = $4`,
+			ix.id, lockerKey, participantKey, subjectKey).Scan(&subj)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// subject record wasn't saved in the wallet
+				return nil
+			}
+			return err
+		}
+
+		var subjRS index.RecordState
+		if err = ix.open(subj, &subjRS); err != nil {
+			return err
+		}
+
+		if subjRS.Status != model.StatusPublished || subjRS.ExpiresAt >= r.ExpiresAt {
+			return nil
+		}
+
+		subjRS.ExpiresAt = r.ExpiresAt
+
+		state, err := ix.seal(&subjRS)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx,
+			`UPDATE index_records SET state = $1 WHERE index_id = $2 AND locker_key = $3 AND participant_key = $4 AND record_key = $5`,
+			state, ix.id, lockerKey, participantKey, subjectKey); err != nil {
+			return err
+		}
+
+		// update subject record lookup
+
+		return ix.putRecordLookup(ctx, tx, &subjRS)
+	})
+}
+
+func (ix *Index) UpdateTopBlock(ctx context.Context, blockNumber int64) error {
+	if blockNumber <= 0 {
+		return errors.New("no block ID provided when updating locker stats " +
+			"(maybe there were no new blocks processed?)")
+	}
+
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	return ix.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT locker_key, state FROM index_lockers WHERE index_id = $1`, ix.id)
+		if err != nil {
+			return err
+		}
+
+		updates := make(map[string][]byte)
+		for rows.Next() {
+			var key string
+			var state []byte
+			if err = rows.Scan(&key, &state); err != nil {
+				_ = rows.Close()
+				return err
+			}
+
+			var ls index.LockerState
+			if err = ix.open(state, &ls); err != nil {
+				_ = rows.Close()
+				return err
+			}
+
+			ls.TopBlock = blockNumber
+
+			if updates[key], err = ix.seal(&ls); err != nil {
+				_ = rows.Close()
+				return err
+			}
+		}
+		_ = rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for key, state := range updates {
+			if _, err = tx.ExecContext(ctx,
+				`UPDATE index_lockers SET state = $1 WHERE index_id = $2 AND locker_key = $3`,
+				state, ix.id, key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (ix *Index) GetRecord(ctx context.Context, recordID string) (*index.RecordState, error) {
+	if err := ix.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	var state []byte
+	err := ix.db.QueryRowContext(ctx,
+		`SELECT state FROM index_record_lookup WHERE index_id = $1 AND record_key = $2`,
+		ix.id, ix.keys.lookup(recordID)).Scan(&state)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var rs index.RecordState
+	if err = ix.open(state, &rs); err != nil {
+		return nil, err
+	}
+
+	return &rs, nil
+}
+
+// queryStates runs the query and decodes the first column of every row
+// into a new value returned by newValue.
+func (ix *Index) queryStates(ctx context.Context, q queryer, newValue func() any, query string, args ...any) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var state []byte
+		if err = rows.Scan(&state); err != nil {
+			return err
+		}
+		if err = ix.open(state, newValue()); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// TraverseRecords visits records in the order of locker ID, participant ID and record ID.
+// Lookup keys of encrypted indexes don't preserve this order, so the records
+// are sorted after decryption.
+func (ix *Index) TraverseRecords(ctx context.Context, lockerFilter, participantFilter string, vFunc index.RecordVisitor, maxRecords uint64) error {
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	query := `SELECT state FROM index_records WHERE index_id = $1`
+	args := []any{ix.id}
+	if lockerFilter != "" {
+		args = append(args, ix.keys.lookup(lockerFilter))
+		query += ` AND locker_key = $` + strconv.Itoa(len(args))
+	}
+	if participantFilter != "" {
+		args = append(args, ix.keys.lookup(participantFilter))
+		query += ` AND participant_key = $` + strconv.Itoa(len(args))
+	}
+
+	var records []*index.RecordState
+	if err := ix.queryStates(ctx, ix.db, func() any {
+		rs := &index.RecordState{}
+		records = append(records, rs)
+		return rs
+	}, query, args...); err != nil {
+		return err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.LockerID != b.LockerID {
+			return a.LockerID < b.LockerID
+		}
+		if a.ParticipantID != b.ParticipantID {
+			return a.ParticipantID < b.ParticipantID
+		}
+		return a.ID < b.ID
+	})
+
+	for _, rs := range records {
+		if err := vFunc(rs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TraverseAssetRecords visits the asset's records in the order of record ID.
+func (ix *Index) TraverseAssetRecords(ctx context.Context, assetID string, vFunc index.AssetRecordVisitor, maxRecords uint64) error {
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	var entries []*assetEntry
+	if err := ix.queryStates(ctx, ix.db, func() any {
+		e := &assetEntry{}
+		entries = append(entries, e)
+		return e
+	}, `SELECT state FROM index_assets WHERE index_id = $1 AND asset_key = $2`, ix.id, ix.keys.lookup(assetID)); err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].RecordID < entries[j].RecordID
+	})
+
+	for _, e := range entries {
+		if err := vFunc(e.RecordID, e.State); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ix *Index) loadVariantRecords(ctx context.Context, q queryer, query string, args ...any) ([]*variantEntry, error) {
+	var entries []*variantEntry
+	if err := ix.queryStates(ctx, q, func() any {
+		e := &variantEntry{}
+		entries = append(entries, e)
+		return e
+	}, query, args...); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.VariantID != b.VariantID {
+			return a.VariantID < b.VariantID
+		}
+		return a.State.ID < b.State.ID
+	})
+
+	return entries, nil
+}
+
+// selectMaster returns the variant's record with the highest revision number.
+// records are expected to be sorted by record ID.
+func selectMaster(records []*index.VariantRecordState) *index.VariantRecordState {
+	var masterRec *index.VariantRecordState
+	var maxRevision int64 = -1
+	for _, rs := range records {
+		if rs.RevisionNumber > maxRevision {
+			masterRec = rs
+			maxRevision = rs.RevisionNumber
+		}
+	}
+	return masterRec
+}
+
+// TraverseVariants visits variants in the order of variant ID. Variant history
+// is ordered by record ID.
+func (ix *Index) TraverseVariants(ctx context.Context, lockerFilter, participantFilter string, vFunc index.VariantVisitor, includeHistory bool, maxVariants uint64) error {
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	entries, err := ix.loadVariantRecords(ctx, ix.db, `SELECT state FROM index_variants WHERE index_id = $1`, ix.id)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(entries); {
+		variantID := entries[i].VariantID
+
+		var records []*index.VariantRecordState
+		for ; i < len(entries) && entries[i].VariantID == variantID; i++ {
+			rs := entries[i].State
+			if lockerFilter != "" && rs.LockerID != lockerFilter {
+				continue
+			}
+			if participantFilter != "" && rs.ParticipantID != participantFilter {
+				continue
+			}
+			records = append(records, rs)
+		}
+
+		// masterRec may be nil if locker or participant filters are present
+		if masterRec := selectMaster(records); masterRec != nil {
+			var hist []*index.VariantRecordState
+			if includeHistory {
+				hist = records
+			}
+			if err = vFunc(variantID, masterRec, hist); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ix *Index) GetVariant(ctx context.Context, variantID string, includeHistory bool) (*index.VariantRecordState,
+	[]*index.VariantRecordState, error) {
+
+	// WARNING: if there are multiple instances of the same impression in the index, this function
+	// return a random master and ALL copies of all revisions.
+
+	if err := ix.checkUnlocked(); err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := ix.loadVariantRecords(ctx, ix.db,
+		`SELECT state FROM index_variants WHERE index_id = $1 AND variant_key = $2`, ix.id, ix.keys.lookup(variantID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s", index.ErrVariantNotFound, variantID)
+	}
+
+	records := make([]*index.VariantRecordState, len(entries))
+	for i, e := range entries {
+		records[i] = e.State
+	}
+
+	var hist []*index.VariantRecordState
+	if includeHistory {
+		hist = records
+	}
+
+	return selectMaster(records), hist, nil
+}
+
+// lookupRecordIDs returns sorted IDs of records that match the given lookup query.
+// The query should return locker_key and record columns.
+func (ix *Index) lookupRecordIDs(ctx context.Context, lockerFilter map[string]bool, query string, args ...any) ([]string, error) {
+	var lockerKeys map[string]bool
+	if lockerFilter != nil {
+		lockerKeys = make(map[string]bool, len(lockerFilter))
+		for lockerID := range lockerFilter {
+			lockerKeys[ix.keys.lookup(lockerID)] = true
+		}
+	}
+
+	rows, err := ix.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	recordIDs := make([]string, 0)
+	for rows.Next() {
+		var lockerKey string
+		var sealedRecordID []byte
+		if err = rows.Scan(&lockerKey, &sealedRecordID); err != nil {
+			return nil, err
+		}
+		if lockerKeys != nil {
+			if _, found := lockerKeys[lockerKey]; !found {
+				continue
+			}
+		}
+		var recordID string
+		if err = ix.open(sealedRecordID, &recordID); err != nil {
+			return nil, err
+		}
+		recordIDs = append(recordIDs, recordID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(recordIDs)
+
+	return recordIDs, nil
+}
+
+func (ix *Index) GetRecordsByResourceID(ctx context.Context, resourceID string, lockerFilter map[string]bool) ([]string, error) {
+	if err := ix.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	return ix.lookupRecordIDs(ctx, lockerFilter,
+		`SELECT locker_key, record FROM index_resources WHERE index_id = $1 AND resource_key = $2`,
+		ix.id, ix.keys.lookup(resourceID))
+}
+
+func (ix *Index) GetRecordsByImpressionID(ctx context.Context, impID string, lockerFilter map[string]bool) ([]string, error) {
+	if err := ix.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	return ix.lookupRecordIDs(ctx, lockerFilter,
+		`SELECT locker_key, record FROM index_impressions WHERE index_id = $1 AND impression_key = $2`,
+		ix.id, ix.keys.lookup(impID))
+}
+
+func (ix *Index) LockerStates(ctx context.Context) ([]index.LockerState, error) {
+	defer measure.ExecTime("index.LockerStates")()
+
+	if err := ix.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	states := make([]index.LockerState, 0)
+	var loaded []*index.LockerState
+	if err := ix.queryStates(ctx, ix.db, func() any {
+		ls := &index.LockerState{}
+		loaded = append(loaded, ls)
+		return ls
+	}, `SELECT state FROM index_lockers WHERE index_id = $1`, ix.id); err != nil {
+		return nil, err
+	}
+
+	for _, ls := range loaded {
+		states = append(states, *ls)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].ID < states[j].ID
+	})
+
+	return states, nil
+}
+
+func (ix *Index) AddLockerState(ctx context.Context, accountID, lockerID string, firstBlock int64) error {
+	defer measure.ExecTime("index.AddLockerState")()
+
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	ls := &index.LockerState{
+		ID:         lockerID,
+		IndexID:    ix.id,
+		AccountID:  accountID,
+		FirstBlock: firstBlock,
+		TopBlock:   firstBlock,
+	}
+
+	state, err := ix.seal(ls)
+	if err != nil {
+		return err
+	}
+
+	res, err := ix.db.ExecContext(ctx,
+		`INSERT INTO index_lockers (index_id, locker_key, state) VALUES ($1, $2, $3)
+		ON CONFLICT (index_id, locker_key) DO NOTHING`,
+		ix.id, ix.keys.lookup(lockerID), state)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return index.ErrLockerStateExists
+	}
+
+	return nil
+}
+
+func (ix *Index) SealLockerState(ctx context.Context, lockerID string, lastBlock int64) error {
+	defer measure.ExecTime("index.SealLockerState")()
+
+	if err := ix.checkUnlocked(); err != nil {
+		return err
+	}
+
+	return ix.withTx(ctx, func(tx *sql.Tx) error {
+		var state []byte
+		err := tx.QueryRowContext(ctx,
+			`SELECT state FROM index_lockers WHERE index_id = $1 AND locker_key = $2`,
+			ix.id, ix.keys.lookup(lockerID)).Scan(&state)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return index.ErrLockerStateNotFound
+			}
+			return err
+		}
+
+		var ls index.LockerState
+		if err = ix.open(state, &ls); err != nil {
+			return err
+		}
+
+		ls.Seal(lastBlock)
+
+		if state, err = ix.seal(&ls); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE index_lockers SET state = $1 WHERE index_id = $2 AND locker_key = $3`,
+			state, ix.id, ix.keys.lookup(lockerID))
+		return err
+	})
+}
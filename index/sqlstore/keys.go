@@ -0,0 +1,77 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"github.com/piprate/metalocker/model"
+)
+
+// indexKeys holds the keys used to protect index data. A nil *indexKeys
+// means the index isn't encrypted: lookup keys are plain IDs and all data
+// is stored as is.
+type indexKeys struct {
+	lookupKey []byte
+	dataKey   *model.AESKey
+	check     string
+}
+
+func newIndexKeys(secret []byte) *indexKeys {
+	return &indexKeys{
+		lookupKey: model.Hash("index lookup key", secret),
+		dataKey:   model.NewAESKey(model.Hash("index data key", secret)),
+		check:     base64.StdEncoding.EncodeToString(model.Hash("index key check", secret)),
+	}
+}
+
+// lookup returns a lookup key for the given ID. Lookup keys of encrypted indexes
+// are keyed hashes which don't disclose the original IDs.
+func (k *indexKeys) lookup(id string) string {
+	if k == nil {
+		return id
+	}
+	mac := hmac.New(sha256.New, k.lookupKey)
+	_, _ = mac.Write([]byte(id))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (k *indexKeys) seal(data []byte) ([]byte, error) {
+	if k == nil {
+		return data, nil
+	}
+	return model.EncryptAESCGM(data, k.dataKey)
+}
+
+func (k *indexKeys) open(data []byte) ([]byte, error) {
+	if k == nil {
+		return data, nil
+	}
+	return model.DecryptAESCGM(data, k.dataKey)
+}
+
+func (k *indexKeys) keyCheck() string {
+	if k == nil {
+		return ""
+	}
+	return k.check
+}
+
+func (k *indexKeys) matches(keyCheck string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.keyCheck()), []byte(keyCheck)) == 1
+}
@@ -0,0 +1,284 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/piprate/metalocker/index"
+)
+
+// pageQuery appends the keyset condition, ordering and limit of a cursor-based
+// traversal over the given columns to the query. after contains the column values
+// of the item that precedes the page, if any.
+func pageQuery(query string, args []any, req *index.PageRequest, after []any, columns ...string) (string, []any) {
+	cmp, dir := ">", "ASC"
+	if req.Descending() {
+		cmp, dir = "<", "DESC"
+	}
+
+	if after != nil {
+		params := make([]string, len(after))
+		for i, v := range after {
+			args = append(args, v)
+			params[i] = "$" + strconv.Itoa(len(args))
+		}
+		query += " AND (" + strings.Join(columns, ", ") + ") " + cmp + " (" + strings.Join(params, ", ") + ")"
+	}
+
+	order := make([]string, len(columns))
+	for i, col := range columns {
+		order[i] = col + " " + dir
+	}
+	args = append(args, req.Limit+1)
+	query += " ORDER BY " + strings.Join(order, ", ") + " LIMIT $" + strconv.Itoa(len(args))
+
+	return query, args
+}
+
+// recordPageKey identifies a record state. The same record may be indexed
+// for several participants.
+func recordPageKey(recordKey, lockerKey, participantKey string) string {
+	return recordKey + "\x00" + lockerKey + "\x00" + participantKey
+}
+
+func (ix *Index) ListRecords(ctx context.Context, lockerFilter, participantFilter string, opts index.PageOptions) (*index.RecordPage, error) {
+	req, err := index.NewPageRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ix.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT block_number, record_key, locker_key, participant_key, state FROM index_records WHERE index_id = $1`
+	args := []any{ix.id}
+	if lockerFilter != "" {
+		args = append(args, ix.keys.lookup(lockerFilter))
+		query += ` AND locker_key = $` + strconv.Itoa(len(args))
+	}
+	if participantFilter != "" {
+		args = append(args, ix.keys.lookup(participantFilter))
+		query += ` AND participant_key = $` + strconv.Itoa(len(args))
+	}
+
+	var after []any
+	if req.After != nil {
+		parts := strings.SplitN(req.After.Key, "\x00", 3)
+		if len(parts) != 3 {
+			return nil, index.ErrInvalidCursor
+		}
+		after = []any{req.After.Block, parts[0], parts[1], parts[2]}
+	}
+
+	query, args = pageQuery(query, args, req, after, "block_number", "record_key", "locker_key", "participant_key")
+
+	rows, err := ix.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	page := &index.RecordPage{
+		Records: make([]*index.RecordState, 0),
+	}
+	var lastBlock int64
+	var lastKey string
+	for rows.Next() {
+		var block int64
+		var recordKey, lockerKey, participantKey string
+		var state []byte
+		if err = rows.Scan(&block, &recordKey, &lockerKey, &participantKey, &state); err != nil {
+			return nil, err
+		}
+		if len(page.Records) == req.Limit {
+			page.NextCursor = req.NextCursor(lastBlock, lastKey)
+			break
+		}
+		var rs index.RecordState
+		if err = ix.open(state, &rs); err != nil {
+			return nil, err
+		}
+		page.Records = append(page.Records, &rs)
+		lastBlock, lastKey = block, recordPageKey(recordKey, lockerKey, participantKey)
+	}
+
+	return page, rows.Err()
+}
+
+type variantHead struct {
+	key   string
+	block int64
+}
+
+func (ix *Index) loadVariantHeads(ctx context.Context, req *index.PageRequest) ([]variantHead, error) {
+	var after []any
+	if req.After != nil {
+		after = []any{req.After.Block, req.After.Key}
+	}
+
+	query, args := pageQuery(`SELECT variant_key, block_number FROM index_variant_heads WHERE index_id = $1`,
+		[]any{ix.id}, req, after, "block_number", "variant_key")
+
+	rows, err := ix.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var heads []variantHead
+	for rows.Next() {
+		var h variantHead
+		if err = rows.Scan(&h.key, &h.block); err != nil {
+			return nil, err
+		}
+		heads = append(heads, h)
+	}
+
+	return heads, rows.Err()
+}
+
+func (ix *Index) ListVariants(ctx context.Context, lockerFilter, participantFilter string, includeHistory bool, opts index.PageOptions) (*index.VariantPage, error) {
+	req, err := index.NewPageRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ix.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	page := &index.VariantPage{
+		Variants: make([]*index.VariantPageEntry, 0),
+	}
+
+	// variant heads are read in batches, because locker and participant filters
+	// may exclude some of them from the page
+
+	var last variantHead
+	batchReq := *req
+	for {
+		heads, err := ix.loadVariantHeads(ctx, &batchReq)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, h := range heads {
+			entries, err := ix.loadVariantRecords(ctx, ix.db,
+				`SELECT state FROM index_variants WHERE index_id = $1 AND variant_key = $2`, ix.id, h.key)
+			if err != nil {
+				return nil, err
+			}
+
+			var records []*index.VariantRecordState
+			for _, e := range entries {
+				if lockerFilter != "" && e.State.LockerID != lockerFilter {
+					continue
+				}
+				if participantFilter != "" && e.State.ParticipantID != participantFilter {
+					continue
+				}
+				records = append(records, e.State)
+			}
+
+			// masterRec may be nil if locker or participant filters are present
+			masterRec := selectMaster(records)
+			if masterRec == nil {
+				continue
+			}
+
+			if len(page.Variants) == req.Limit {
+				page.NextCursor = req.NextCursor(last.block, last.key)
+				return page, nil
+			}
+
+			var hist []*index.VariantRecordState
+			if includeHistory {
+				hist = records
+			}
+			page.Variants = append(page.Variants, &index.VariantPageEntry{
+				VariantID: entries[0].VariantID,
+				Master:    masterRec,
+				History:   hist,
+			})
+			last = h
+		}
+
+		if len(heads) <= req.Limit {
+			return page, nil
+		}
+
+		tail := heads[len(heads)-1]
+		batchReq.After = &index.PageCursor{Order: req.Order, Block: tail.block, Key: tail.key}
+	}
+}
+
+func (ix *Index) ListAssetRecords(ctx context.Context, assetID string, opts index.PageOptions) (*index.AssetRecordPage, error) {
+	req, err := index.NewPageRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ix.checkUnlocked(); err != nil {
+		return nil, err
+	}
+
+	var after []any
+	if req.After != nil {
+		after = []any{req.After.Block, req.After.Key}
+	}
+
+	query, args := pageQuery(`SELECT block_number, record_key, state FROM index_assets WHERE index_id = $1 AND asset_key = $2`,
+		[]any{ix.id, ix.keys.lookup(assetID)}, req, after, "block_number", "record_key")
+
+	rows, err := ix.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	page := &index.AssetRecordPage{
+		Records: make([]*index.AssetRecordPageEntry, 0),
+	}
+	var lastBlock int64
+	var lastKey string
+	for rows.Next() {
+		var block int64
+		var recordKey string
+		var state []byte
+		if err = rows.Scan(&block, &recordKey, &state); err != nil {
+			return nil, err
+		}
+		if len(page.Records) == req.Limit {
+			page.NextCursor = req.NextCursor(lastBlock, lastKey)
+			break
+		}
+		var e assetEntry
+		if err = ix.open(state, &e); err != nil {
+			return nil, err
+		}
+		page.Records = append(page.Records, &index.AssetRecordPageEntry{
+			RecordID:    e.RecordID,
+			BlockNumber: block,
+			State:       e.State,
+		})
+		lastBlock, lastKey = block, recordKey
+	}
+
+	return page, rows.Err()
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"entgo.io/ent/dialect"
+)
+
+const (
+	// control variables
+
+	GenesisBlockHashKey = "genesis_block_hash"
+)
+
+// Every table is shared by all indexes in the store and is partitioned
+// by index_id. Columns with the _key suffix contain lookup keys: plain IDs
+// if the store doesn't use encryption, or keyed hashes of the IDs otherwise.
+// Block numbers are stored as is to support cursor-based traversals.
+// All other data is stored in the sealed (possibly encrypted) state columns.
+var schemaStatements = []string{
+	`CREATE TABLE IF NOT EXISTS index_controls (
+		name TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS index_properties (
+		index_id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		index_type TEXT NOT NULL,
+		access_level INTEGER NOT NULL,
+		algorithm TEXT NOT NULL,
+		key_check TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS index_properties_user_idx ON index_properties (user_id)`,
+	`CREATE TABLE IF NOT EXISTS index_lockers (
+		index_id TEXT NOT NULL,
+		locker_key TEXT NOT NULL,
+		state {{BLOB}} NOT NULL,
+		PRIMARY KEY (index_id, locker_key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS index_records (
+		index_id TEXT NOT NULL,
+		locker_key TEXT NOT NULL,
+		participant_key TEXT NOT NULL,
+		record_key TEXT NOT NULL,
+		block_number BIGINT NOT NULL,
+		state {{BLOB}} NOT NULL,
+		PRIMARY KEY (index_id, locker_key, participant_key, record_key)
+	)`,
+	`CREATE INDEX IF NOT EXISTS index_records_page_idx ON index_records (index_id, block_number, record_key)`,
+	`CREATE INDEX IF NOT EXISTS index_records_locker_page_idx ON index_records (index_id, locker_key, block_number, record_key)`,
+	`CREATE TABLE IF NOT EXISTS index_record_lookup (
+		index_id TEXT NOT NULL,
+		record_key TEXT NOT NULL,
+		state {{BLOB}} NOT NULL,
+		PRIMARY KEY (index_id, record_key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS index_impressions (
+		index_id TEXT NOT NULL,
+		impression_key TEXT NOT NULL,
+		record_key TEXT NOT NULL,
+		locker_key TEXT NOT NULL,
+		record {{BLOB}} NOT NULL,
+		PRIMARY KEY (index_id, impression_key, record_key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS index_resources (
+		index_id TEXT NOT NULL,
+		resource_key TEXT NOT NULL,
+		record_key TEXT NOT NULL,
+		locker_key TEXT NOT NULL,
+		record {{BLOB}} NOT NULL,
+		PRIMARY KEY (index_id, resource_key, record_key)
+	)`,
+	`CREATE INDEX IF NOT EXISTS index_resources_record_idx ON index_resources (index_id, record_key)`,
+	`CREATE TABLE IF NOT EXISTS index_assets (
+		index_id TEXT NOT NULL,
+		asset_key TEXT NOT NULL,
+		record_key TEXT NOT NULL,
+		block_number BIGINT NOT NULL,
+		state {{BLOB}} NOT NULL,
+		PRIMARY KEY (index_id, asset_key, record_key)
+	)`,
+	`CREATE INDEX IF NOT EXISTS index_assets_record_idx ON index_assets (index_id, record_key)`,
+	`CREATE INDEX IF NOT EXISTS index_assets_page_idx ON index_assets (index_id, asset_key, block_number, record_key)`,
+	`CREATE TABLE IF NOT EXISTS index_variants (
+		index_id TEXT NOT NULL,
+		variant_key TEXT NOT NULL,
+		record_key TEXT NOT NULL,
+		state {{BLOB}} NOT NULL,
+		PRIMARY KEY (index_id, variant_key, record_key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS index_variant_heads (
+		index_id TEXT NOT NULL,
+		variant_key TEXT NOT NULL,
+		block_number BIGINT NOT NULL,
+		PRIMARY KEY (index_id, variant_key)
+	)`,
+	`CREATE INDEX IF NOT EXISTS index_variant_heads_page_idx ON index_variant_heads (index_id, block_number, variant_key)`,
+}
+
+// indexTables lists all tables that contain per-index data.
+var indexTables = []string{
+	"index_lockers", "index_records", "index_record_lookup", "index_impressions",
+	"index_resources", "index_assets", "index_variants", "index_variant_heads", "index_properties",
+}
+
+// InstallIndexStoreSchema creates the index store tables, if they don't exist.
+func InstallIndexStoreSchema(ctx context.Context, db *sql.DB, dialectName string) error {
+	blobType := "BLOB"
+	if dialectName == dialect.Postgres {
+		blobType = "BYTEA"
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if dialectName == dialect.Postgres {
+		// serialise schema installation between several nodes
+		if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('metalocker_index_schema'))`); err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range schemaStatements {
+		if _, err = tx.ExecContext(ctx, strings.ReplaceAll(stmt, "{{BLOB}}", blobType)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,355 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/cmdbase"
+	"github.com/piprate/metalocker/storage/rdb"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	Type = "sql"
+
+	ParameterEncryptionKey = "encryption_key"
+
+	Algorithm = "metalocker:root:1"
+)
+
+var (
+	ErrIndexLocked = errors.New("index is locked")
+	ErrInvalidKey  = errors.New("invalid index encryption key")
+)
+
+func init() {
+	index.RegisterStoreType(Type, NewIndexStore)
+}
+
+// IndexStore is an index store that keeps indexes in a relational database
+// (PostgreSQL or SQLite). Depending on the store's encryption mode, index data
+// is stored in plain text, encrypted with a key from the store configuration
+// (managed encryption) or encrypted with a key provided by the index owner
+// (client encryption).
+type IndexStore struct {
+	cfg              *index.StoreConfig
+	props            *index.StoreProperties
+	db               *sql.DB
+	managedKey       *model.AESKey
+	genesisBlockHash string
+}
+
+var _ index.Store = (*IndexStore)(nil)
+
+func NewIndexStore(cfg *index.StoreConfig, resolver cmdbase.ParameterResolver) (index.Store, error) {
+	databaseURL, err := rdb.ReadDatabaseURL(cfg.Params, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	var logLevel int
+	if val, ok := cfg.Params[rdb.ParameterLogLevel].(float64); ok {
+		logLevel = int(val)
+	}
+
+	var managedKey *model.AESKey
+	switch cfg.EncryptionMode {
+	case "":
+		cfg.EncryptionMode = index.ModeNoEncryption
+	case index.ModeNoEncryption, index.ModeClientEncryption:
+	case index.ModeManagedEncryption:
+		managedKey, err = readEncryptionKey(cfg.Params, resolver)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encryption mode for index store %s: %s", cfg.ID, cfg.EncryptionMode)
+	}
+
+	db, dialectName, err := rdb.OpenDB(databaseURL, zerolog.Level(logLevel))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = InstallIndexStoreSchema(context.Background(), db, dialectName); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &IndexStore{
+		cfg:        cfg,
+		props:      index.NewStorePropertiesFromConfig(cfg),
+		db:         db,
+		managedKey: managedKey,
+	}, nil
+}
+
+// readEncryptionKey reads the managed encryption key (base64-encoded 256-bit key)
+// from the 'encryption_key' parameter. The parameter can be either a string
+// or a secure parameter definition.
+func readEncryptionKey(params map[string]any, resolver cmdbase.ParameterResolver) (*model.AESKey, error) {
+	val, found := params[ParameterEncryptionKey]
+	if !found {
+		return nil, errors.New("parameter not found: " + ParameterEncryptionKey +
+			". Can't start index store with managed encryption")
+	}
+
+	keyStr, isString := val.(string)
+	if !isString {
+		if resolver == nil {
+			return nil, errors.New("wrong type of parameter: " + ParameterEncryptionKey)
+		}
+		b, err := jsonw.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		var keyParam map[string]any
+		if err = jsonw.Unmarshal(b, &keyParam); err != nil {
+			return nil, err
+		}
+		if keyStr, err = resolver.ResolveString(keyParam); err != nil {
+			return nil, err
+		}
+	}
+
+	keyBytes, err := base64.StdEncoding.DecodeString(keyStr)
+	if err != nil {
+		return nil, fmt.Errorf("bad index store encryption key: %w", err)
+	}
+	if len(keyBytes) != model.KeySize {
+		return nil, fmt.Errorf("bad index store encryption key size: %d", len(keyBytes))
+	}
+
+	return model.NewAESKey(keyBytes), nil
+}
+
+func (s *IndexStore) ID() string {
+	return s.cfg.ID
+}
+
+func (s *IndexStore) Name() string {
+	return s.cfg.Name
+}
+
+func (s *IndexStore) Properties() *index.StoreProperties {
+	return s.props
+}
+
+func (s *IndexStore) CreateIndex(ctx context.Context, userID string, indexType string, accessLevel model.AccessLevel, opts ...index.Option) (index.Index, error) {
+
+	if indexType != index.TypeRoot {
+		return nil, fmt.Errorf("index type not supported in index store %s (%s): %s", s.ID(), Type, indexType)
+	}
+
+	idxOptions, err := index.NewOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.cfg.EncryptionMode {
+	case index.ModeClientEncryption:
+		if len(idxOptions.ClientKey) == 0 {
+			return nil, errors.New("index encryption key required for client encryption mode")
+		}
+	default:
+		if idxOptions.ClientKey != nil {
+			return nil, fmt.Errorf("client encryption key not supported in index store mode: %s", s.cfg.EncryptionMode)
+		}
+	}
+
+	// the logic below is for root index creation only
+
+	indexID := index.RootIndexID(userID, accessLevel)
+
+	keys := s.indexKeys(indexID, idxOptions.ClientKey)
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO index_properties (index_id, user_id, index_type, access_level, algorithm, key_check)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (index_id) DO NOTHING`,
+		indexID, userID, indexType, int(accessLevel), Algorithm, keys.keyCheck())
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, index.ErrIndexExists
+	}
+
+	return &Index{
+		id:     indexID,
+		userID: userID,
+		props: &index.Properties{
+			IndexType:   indexType,
+			Asset:       indexID,
+			AccessLevel: accessLevel,
+			Algorithm:   Algorithm,
+		},
+		mode:     s.cfg.EncryptionMode,
+		db:       s.db,
+		keys:     keys,
+		keyCheck: keys.keyCheck(),
+	}, nil
+}
+
+func (s *IndexStore) Index(ctx context.Context, userID string, id string) (index.Index, error) {
+	var props index.Properties
+	var accessLevel int
+	var keyCheck string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT index_type, access_level, algorithm, key_check FROM index_properties WHERE index_id = $1`, id).
+		Scan(&props.IndexType, &accessLevel, &props.Algorithm, &keyCheck)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, index.ErrIndexNotFound
+		}
+		return nil, err
+	}
+	props.Asset = id
+	props.AccessLevel = model.AccessLevel(accessLevel)
+
+	ix := &Index{
+		id:       id,
+		userID:   userID,
+		props:    &props,
+		mode:     s.cfg.EncryptionMode,
+		db:       s.db,
+		keyCheck: keyCheck,
+	}
+
+	if s.cfg.EncryptionMode != index.ModeClientEncryption {
+		// the index is never locked
+		ix.keys = s.indexKeys(id, nil)
+	}
+
+	return ix, nil
+}
+
+func (s *IndexStore) ListIndexes(ctx context.Context, userID string) ([]*index.Properties, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT index_id, index_type, access_level, algorithm FROM index_properties WHERE user_id = $1 ORDER BY index_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	res := make([]*index.Properties, 0)
+	for rows.Next() {
+		var props index.Properties
+		var accessLevel int
+		if err = rows.Scan(&props.Asset, &props.IndexType, &accessLevel, &props.Algorithm); err != nil {
+			return nil, err
+		}
+		props.AccessLevel = model.AccessLevel(accessLevel)
+		res = append(res, &props)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *IndexStore) DeleteIndex(ctx context.Context, userID, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM index_properties WHERE index_id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return index.ErrIndexNotFound
+	}
+
+	for _, table := range indexTables {
+		if _, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE index_id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *IndexStore) RootIndex(ctx context.Context, userID string, lvl model.AccessLevel) (index.RootIndex, error) {
+	indexID := index.RootIndexID(userID, lvl)
+	ix, err := s.Index(ctx, userID, indexID)
+	if err != nil {
+		return nil, err
+	}
+	return ix.(index.RootIndex), nil
+}
+
+func (s *IndexStore) Bind(ctx context.Context, gbHash string) error {
+	var storedHash string
+	err := s.db.QueryRowContext(ctx, `SELECT value FROM index_controls WHERE name = $1`, GenesisBlockHashKey).
+		Scan(&storedHash)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// new index store
+		if _, err = s.db.ExecContext(ctx,
+			`INSERT INTO index_controls (name, value) VALUES ($1, $2)`, GenesisBlockHashKey, gbHash); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case storedHash != gbHash:
+		return fmt.Errorf(
+			"genesis block hash mismatch between MetaLocker and index store: %s != %s",
+			gbHash, storedHash)
+	}
+
+	s.genesisBlockHash = gbHash
+
+	return nil
+}
+
+func (s *IndexStore) GenesisBlockHash(ctx context.Context) string {
+	return s.genesisBlockHash
+}
+
+// indexKeys returns the keys for the given index, depending on the store's
+// encryption mode. clientKey is only used in client encryption mode.
+func (s *IndexStore) indexKeys(indexID string, clientKey []byte) *indexKeys {
+	switch s.cfg.EncryptionMode {
+	case index.ModeManagedEncryption:
+		return newIndexKeys(model.Hash(indexID, s.managedKey[:]))
+	case index.ModeClientEncryption:
+		return newIndexKeys(clientKey)
+	default:
+		return nil
+	}
+}
+
+func (s *IndexStore) Close() error {
+	if s.db != nil {
+		log.Debug().Msg("Closing SQL index store")
+		err := s.db.Close()
+		s.db = nil
+		return err
+	}
+	return nil
+}
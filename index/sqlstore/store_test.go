@@ -0,0 +1,258 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlstore_test
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/index/indextest"
+	. "github.com/piprate/metalocker/index/sqlstore"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/piprate/metalocker/sdk/testbase/pgembed"
+	"github.com/piprate/metalocker/storage/rdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testbase.SetupLogFormat()
+}
+
+var testManagedKey = base64.StdEncoding.EncodeToString(model.NewEncryptionKey()[:])
+
+func newSQLiteDatabase(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp(".", "tempdir_")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	return "sqlite3://" + filepath.Join(dir, "index.db") + "?_fk=1"
+}
+
+func newTestIndexStore(t *testing.T, databaseURL string, mode index.EncryptionMode) index.Store {
+	t.Helper()
+
+	params := map[string]any{
+		rdb.ParameterURL: databaseURL,
+	}
+	if mode == index.ModeManagedEncryption {
+		params[ParameterEncryptionKey] = testManagedKey
+	}
+
+	indexStore, err := NewIndexStore(
+		&index.StoreConfig{
+			ID:             testbase.IndexStoreID,
+			Name:           testbase.IndexStoreName,
+			Type:           Type,
+			EncryptionMode: mode,
+			Params:         params,
+		}, nil)
+	require.NoError(t, err)
+
+	return indexStore
+}
+
+func sqliteStoreFactory(mode index.EncryptionMode) indextest.StoreFactory {
+	return func(t *testing.T) index.Store {
+		t.Helper()
+		return newTestIndexStore(t, newSQLiteDatabase(t), mode)
+	}
+}
+
+func TestIndexStore_Conformance_NoEncryption(t *testing.T) {
+	indextest.RunStoreTests(t, sqliteStoreFactory(index.ModeNoEncryption))
+}
+
+func TestIndexStore_Conformance_ManagedEncryption(t *testing.T) {
+	indextest.RunStoreTests(t, sqliteStoreFactory(index.ModeManagedEncryption))
+}
+
+func TestIndexStore_Conformance_ClientEncryption(t *testing.T) {
+	key := model.NewEncryptionKey()
+	indextest.RunStoreTests(t, sqliteStoreFactory(index.ModeClientEncryption), index.WithEncryption(key[:]))
+}
+
+func TestIndexStore_Conformance_Postgres(t *testing.T) {
+	databaseURL, edb, dir := pgembed.NewEmbeddedDatabase(t)
+	defer pgembed.StopEmbeddedDatabase(t, edb, dir)
+
+	indextest.RunStoreTests(t, func(t *testing.T) index.Store {
+		t.Helper()
+
+		store := newTestIndexStore(t, databaseURL, index.ModeNoEncryption)
+
+		// all subtests share the same database
+		db, _, err := rdb.OpenDB(databaseURL, 0)
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+		for _, table := range []string{
+			"index_controls", "index_properties", "index_lockers", "index_records", "index_record_lookup",
+			"index_impressions", "index_resources", "index_assets", "index_variants",
+		} {
+			_, err = db.Exec("DELETE FROM " + table)
+			require.NoError(t, err)
+		}
+
+		return store
+	})
+}
+
+func TestNewIndexStore_ManagedEncryptionKey(t *testing.T) {
+	cfg := &index.StoreConfig{
+		Type:           Type,
+		EncryptionMode: index.ModeManagedEncryption,
+		Params: map[string]any{
+			rdb.ParameterURL: newSQLiteDatabase(t),
+		},
+	}
+
+	_, err := NewIndexStore(cfg, nil)
+	require.Error(t, err)
+
+	cfg.Params[ParameterEncryptionKey] = base64.StdEncoding.EncodeToString([]byte("too short"))
+	_, err = NewIndexStore(cfg, nil)
+	require.Error(t, err)
+}
+
+func TestIndexStore_ClientEncryption(t *testing.T) {
+	ctx := context.Background()
+	databaseURL := newSQLiteDatabase(t)
+	store := newTestIndexStore(t, databaseURL, index.ModeClientEncryption)
+	defer func() { _ = store.Close() }()
+
+	userID := "did:piprate:QgH6CZvhjTUFvCbRUw4N6Z"
+
+	// an encryption key is required
+
+	_, err := store.CreateIndex(ctx, userID, index.TypeRoot, model.AccessLevelHosted)
+	require.Error(t, err)
+
+	key := model.NewEncryptionKey()
+	ix, err := store.CreateIndex(ctx, userID, index.TypeRoot, model.AccessLevelHosted, index.WithEncryption(key[:]))
+	require.NoError(t, err)
+
+	iw, _ := ix.Writer()
+	require.NoError(t, iw.AddLockerState(ctx, userID, "locker1", 1))
+
+	rootIndex, err := store.RootIndex(ctx, userID, model.AccessLevelHosted)
+	require.NoError(t, err)
+	assert.True(t, rootIndex.IsLocked())
+
+	_, err = rootIndex.GetRecord(ctx, "rec1")
+	require.ErrorIs(t, err, ErrIndexLocked)
+
+	wrongKey := model.NewEncryptionKey()
+	require.ErrorIs(t, rootIndex.Unlock(wrongKey[:]), ErrInvalidKey)
+	assert.True(t, rootIndex.IsLocked())
+
+	require.NoError(t, rootIndex.Unlock(key[:]))
+	assert.False(t, rootIndex.IsLocked())
+
+	iw, _ = rootIndex.Writer()
+	states, err := iw.LockerStates(ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, "locker1", states[0].ID)
+
+	rootIndex.Lock()
+	assert.True(t, rootIndex.IsLocked())
+}
+
+func TestIndexStore_ManagedEncryption(t *testing.T) {
+	ctx := context.Background()
+	databaseURL := newSQLiteDatabase(t)
+	store := newTestIndexStore(t, databaseURL, index.ModeManagedEncryption)
+	defer func() { _ = store.Close() }()
+
+	userID := "did:piprate:QgH6CZvhjTUFvCbRUw4N6Z"
+
+	// client keys aren't accepted in managed mode
+
+	key := model.NewEncryptionKey()
+	_, err := store.CreateIndex(ctx, userID, index.TypeRoot, model.AccessLevelHosted, index.WithEncryption(key[:]))
+	require.Error(t, err)
+
+	ix, err := store.CreateIndex(ctx, userID, index.TypeRoot, model.AccessLevelHosted)
+	require.NoError(t, err)
+	assert.False(t, ix.IsLocked())
+
+	iw, _ := ix.Writer()
+	require.NoError(t, iw.AddLockerState(ctx, userID, "secret-locker-id", 1))
+
+	// check the locker ID doesn't appear in the database
+
+	db, _, err := rdb.OpenDB(databaseURL, 0)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	var lockerKey string
+	var state []byte
+	err = db.QueryRow(`SELECT locker_key, state FROM index_lockers`).Scan(&lockerKey, &state)
+	require.NoError(t, err)
+	assert.NotEqual(t, "secret-locker-id", lockerKey)
+	assert.False(t, strings.Contains(string(state), "secret-locker-id"))
+
+	rootIndex, err := store.RootIndex(ctx, userID, model.AccessLevelHosted)
+	require.NoError(t, err)
+	assert.False(t, rootIndex.IsLocked())
+
+	iw, _ = rootIndex.Writer()
+	states, err := iw.LockerStates(ctx)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	assert.Equal(t, "secret-locker-id", states[0].ID)
+}
+
+func TestIndexStore_ListAndDeleteIndexes(t *testing.T) {
+	ctx := context.Background()
+	store := newTestIndexStore(t, newSQLiteDatabase(t), index.ModeNoEncryption)
+	defer func() { _ = store.Close() }()
+
+	userID := "did:piprate:QgH6CZvhjTUFvCbRUw4N6Z"
+
+	_, err := store.CreateIndex(ctx, userID, index.TypeRoot, model.AccessLevelHosted)
+	require.NoError(t, err)
+	_, err = store.CreateIndex(ctx, userID, index.TypeRoot, model.AccessLevelManaged)
+	require.NoError(t, err)
+
+	list, err := store.ListIndexes(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	list, err = store.ListIndexes(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	indexID := index.RootIndexID(userID, model.AccessLevelManaged)
+	require.NoError(t, store.DeleteIndex(ctx, userID, indexID))
+	require.ErrorIs(t, store.DeleteIndex(ctx, userID, indexID), index.ErrIndexNotFound)
+
+	_, err = store.Index(ctx, userID, indexID)
+	require.ErrorIs(t, err, index.ErrIndexNotFound)
+
+	list, err = store.ListIndexes(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils"
+)
+
+var _ model.LedgerCursorStore = (*BoltLedger)(nil)
+
+func (bl *BoltLedger) GetCursor(ctx context.Context, name string) (int64, error) {
+	v, err := bl.client.FetchString(ControlsKey, CursorKeyPrefix+name)
+	if err != nil {
+		return 0, err
+	}
+	if v == "" {
+		return -1, nil
+	}
+	return utils.StringToInt64(v), nil
+}
+
+func (bl *BoltLedger) SetCursor(ctx context.Context, name string, blockNumber int64) error {
+	return bl.client.Update(ControlsKey, CursorKeyPrefix+name, []byte(utils.Int64ToString(blockNumber)))
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/rs/zerolog/log"
+	"go.etcd.io/bbolt"
+)
+
+var _ model.LeaseExpiryEnforcer = (*BoltLedger)(nil)
+
+// expiryKey builds a key for the lease expiry bucket. Expiry time is encoded
+// in big endian format to keep the keys ordered by time.
+func expiryKey(expiresAt int64, rid string) []byte {
+	key := make([]byte, 8, 8+len(rid))
+	binary.BigEndian.PutUint64(key, uint64(expiresAt))
+	return append(key, rid...)
+}
+
+func (bl *BoltLedger) GetExpiredRecords(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	var result []string
+	err := bl.client.DB.View(func(tx *bbolt.Tx) error {
+		eb := tx.Bucket([]byte(LeaseExpiriesKey))
+		if eb == nil {
+			return fmt.Errorf("bucket %s not found", LeaseExpiriesKey)
+		}
+
+		threshold := expiryKey(now.Unix(), "")
+
+		c := eb.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], threshold) < 0; k, _ = c.Next() {
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+			result = append(result, string(k[8:]))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (bl *BoltLedger) ExpireRecord(ctx context.Context, rid string, now time.Time) (bool, error) {
+	expired := false
+	err := bl.client.DB.Update(func(tx *bbolt.Tx) error {
+		recordBytes := tx.Bucket([]byte(RecordsKey)).Get([]byte(rid))
+		if recordBytes == nil {
+			return model.ErrRecordNotFound
+		}
+
+		var rec model.Record
+		if err := jsonw.Unmarshal(recordBytes, &rec); err != nil {
+			return err
+		}
+
+		if rec.Operation != model.OpTypeLease || rec.ExpiresAt == 0 {
+			return nil
+		}
+
+		rs, err := getRecordState(tx, rid)
+		if err != nil {
+			return err
+		}
+
+		// the lease may have been renewed
+
+		expiresAt := effectiveExpiry(&rec, rs)
+		if expiresAt >= now.Unix() {
+			return nil
+		}
+
+		// the record will either be expired or was already revoked,
+		// so we no longer need to track it.
+
+		eb := tx.Bucket([]byte(LeaseExpiriesKey))
+		if err = eb.Delete(expiryKey(expiresAt, rec.ID)); err != nil {
+			return err
+		}
+
+		if rs == nil || rs.Status != model.StatusPublished {
+			return nil
+		}
+
+		if err := bl.updateRecordState(tx, rid, model.StatusExpired, -1); err != nil {
+			return err
+		}
+
+		if err := releaseDataAssets(tx, &rec); err != nil {
+			return err
+		}
+
+		expired = true
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if expired {
+		log.Debug().Str("rid", rid).Msg("Lease expired")
+	}
+
+	return expired, nil
+}
+
+func getRecordState(tx *bbolt.Tx, rid string) (*model.RecordState, error) {
+	rsBytes := tx.Bucket([]byte(RecordStatesKey)).Get([]byte(rid))
+	if rsBytes == nil {
+		return nil, nil
+	}
+	var rs model.RecordState
+	if err := jsonw.Unmarshal(rsBytes, &rs); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// effectiveExpiry returns the lease expiry time, taking into account any renewals.
+func effectiveExpiry(rec *model.Record, rs *model.RecordState) int64 {
+	if rs != nil && rs.ExpiresAt > rec.ExpiresAt {
+		return rs.ExpiresAt
+	}
+	return rec.ExpiresAt
+}
+
+// setRecordExpiry updates the effective expiry time of the lease in its record state.
+func setRecordExpiry(tx *bbolt.Tx, rid string, expiresAt int64) error {
+	rs, err := getRecordState(tx, rid)
+	if err != nil {
+		return err
+	}
+	if rs == nil {
+		return fmt.Errorf("record state not found for %s", rid)
+	}
+
+	rs.ExpiresAt = expiresAt
+
+	return tx.Bucket([]byte(RecordStatesKey)).Put([]byte(rid), rs.Bytes())
+}
+
+// renewLease extends the expiry time of the lease referenced by the lease renewal
+// record. Returns model.ErrLeaseRenewalInvalid if the renewal can't be applied.
+func renewLease(tx *bbolt.Tx, rec *model.Record) error {
+	subjBytes := tx.Bucket([]byte(RecordsKey)).Get([]byte(rec.SubjectRecord))
+	if subjBytes == nil {
+		return fmt.Errorf("%w: subject record %s not found", model.ErrLeaseRenewalInvalid, rec.SubjectRecord)
+	}
+
+	var subj model.Record
+	if err := jsonw.Unmarshal(subjBytes, &subj); err != nil {
+		return err
+	}
+
+	if err := model.VerifyLeaseRenewal(rec, &subj); err != nil {
+		return err
+	}
+
+	rs, err := getRecordState(tx, subj.ID)
+	if err != nil {
+		return err
+	}
+	if rs == nil || rs.Status != model.StatusPublished {
+		return fmt.Errorf("%w: subject record %s isn't active", model.ErrLeaseRenewalInvalid, subj.ID)
+	}
+
+	prevExpiresAt := effectiveExpiry(&subj, rs)
+	if rec.ExpiresAt <= prevExpiresAt {
+		return fmt.Errorf("%w: new expiry time should be after the current one", model.ErrLeaseRenewalInvalid)
+	}
+
+	eb := tx.Bucket([]byte(LeaseExpiriesKey))
+	if err = eb.Delete(expiryKey(prevExpiresAt, subj.ID)); err != nil {
+		return err
+	}
+	if err = eb.Put(expiryKey(rec.ExpiresAt, subj.ID), nil); err != nil {
+		return err
+	}
+
+	return setRecordExpiry(tx, subj.ID, rec.ExpiresAt)
+}
@@ -15,7 +15,6 @@
 package local
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
@@ -59,28 +58,21 @@ type BoltLedger struct {
 	pendingRecords []string
 }
 
-var _ model.Ledger = (*BoltLedger)(nil)
+var (
+	_ model.Ledger                = (*BoltLedger)(nil)
+	_ model.RecordInclusionProver = (*BoltLedger)(nil)
+)
 
 type LocalBlock struct {
 	Number     int64  `json:"number"`
 	Hash       string `json:"hash"`
 	ParentHash string `json:"parentHash,omitempty"`
 	Nonce      string `json:"nonce,omitempty"`
+	MerkleRoot string `json:"merkleRoot,omitempty"`
 }
 
 func (b *LocalBlock) Seal() error {
-
-	buf := bytes.NewBuffer(nil)
-	parentIDVal, _ := base64.StdEncoding.DecodeString(b.ParentHash)
-	buf.Write(parentIDVal)
-	nonceVal, _ := base64.StdEncoding.DecodeString(b.Nonce)
-	buf.Write(nonceVal)
-	buf.WriteString(strconv.Itoa(int(b.Number)))
-
-	hash := model.Hash("block construction", buf.Bytes())
-
-	b.Hash = base64.StdEncoding.EncodeToString(hash)
-
+	b.Hash = model.BlockHash(b.Number, b.ParentHash, b.Nonce, b.MerkleRoot)
 	return nil
 }
 
@@ -447,6 +439,10 @@ func (bl *BoltLedger) GetAssetHead(ctx context.Context, headID string) (*model.R
 	}
 }
 
+func (bl *BoltLedger) GetRecordInclusionProof(ctx context.Context, rid string) (*model.RecordInclusionProof, error) {
+	return ledger.BuildRecordInclusionProof(ctx, bl, rid)
+}
+
 func (bl *BoltLedger) Close() error {
 	if bl.scheduler != nil {
 		log.Info().Msg("Stopping block scheduler")
@@ -503,27 +499,35 @@ func (bl *BoltLedger) OpenNewBlockSession() (string, error) {
 }
 
 func (bl *BoltLedger) updateRecordState(tx *bbolt.Tx, rid string, status model.RecordStatus, blockNumber int64) error {
+	_, err := bl.switchRecordState(tx, rid, status, blockNumber)
+	return err
+}
+
+// switchRecordState updates the record's status and returns its previous status.
+func (bl *BoltLedger) switchRecordState(tx *bbolt.Tx, rid string, status model.RecordStatus, blockNumber int64) (model.RecordStatus, error) {
 	b := tx.Bucket([]byte(RecordStatesKey))
 	if b == nil {
-		return fmt.Errorf("bucket %s not found", RecordStatesKey)
+		return "", fmt.Errorf("bucket %s not found", RecordStatesKey)
 	}
 
 	val := b.Get([]byte(rid))
 
 	if val == nil {
-		return fmt.Errorf("record state not found for %s", rid)
+		return "", fmt.Errorf("record state not found for %s", rid)
 	}
 
 	var rs model.RecordState
 	err := jsonw.Unmarshal(val, &rs)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if rs.Status == model.StatusRevoked {
-		return errors.New("cannot change status of revoked record")
+		return "", errors.New("cannot change status of revoked record")
 	}
 
+	prevStatus := rs.Status
+
 	rs.Status = status
 	if blockNumber != -1 {
 		rs.BlockNumber = blockNumber
@@ -531,7 +535,31 @@ func (bl *BoltLedger) updateRecordState(tx *bbolt.Tx, rid string, status model.R
 
 	err = b.Put([]byte(rid), rs.Bytes())
 	if err != nil {
-		return err
+		return "", err
+	}
+	return prevStatus, nil
+}
+
+// releaseDataAssets decrements usage counters for the record's data assets
+// and its operation.
+func releaseDataAssets(tx *bbolt.Tx, rec *model.Record) error {
+	dasb := tx.Bucket([]byte(DataAssetStatesKey))
+	for _, id := range append(rec.DataAssets, rec.OperationAddress) {
+		var counter uint32
+		cntBytes := dasb.Get([]byte(id))
+		if cntBytes != nil {
+			counter = utils.BytesToUint32(cntBytes)
+		}
+
+		if counter > 0 {
+			// counter may go negative if the same record is revoked multiple times.
+			// For now, we allow multiple revocations.
+			counter--
+		}
+
+		if err := dasb.Put([]byte(id), utils.Uint32ToBytes(counter)); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -641,9 +669,20 @@ func (bl *BoltLedger) SubmitNewBlock(block *model.Block, records []*model.Record
 						return err
 					}
 				}
+				if rec.ExpiresAt > 0 {
+					eb := tx.Bucket([]byte(LeaseExpiriesKey))
+					if err = eb.Put(expiryKey(rec.ExpiresAt, rec.ID), nil); err != nil {
+						return err
+					}
+				}
 				if err = bl.updateRecordState(tx, rec.ID, model.StatusPublished, block.Number); err != nil {
 					return err
 				}
+				if rec.ExpiresAt > 0 {
+					if err = setRecordExpiry(tx, rec.ID, rec.ExpiresAt); err != nil {
+						return err
+					}
+				}
 			case model.OpTypeLeaseRevocation:
 				rb := tx.Bucket([]byte(RecordsKey))
 				if blockCompBucket == nil {
@@ -663,36 +702,36 @@ func (bl *BoltLedger) SubmitNewBlock(block *model.Block, records []*model.Record
 				}
 
 				// apply revocations
-				if err = bl.updateRecordState(tx, rec.SubjectRecord, model.StatusRevoked, -1); err != nil {
+				prevStatus, err := bl.switchRecordState(tx, rec.SubjectRecord, model.StatusRevoked, -1)
+				if err != nil {
 					if err := bl.updateRecordState(tx, rec.ID, model.StatusFailed, -1); err != nil {
 						log.Err(err).Str("rid", rec.ID).Msg("Error when setting record status as failed")
 					}
 					continue
 				}
 
-				// update data asset counters
+				// update data asset counters (unless they were released when the lease expired)
 
-				dasb := tx.Bucket([]byte(DataAssetStatesKey))
-				for _, id := range append(subj.DataAssets, subj.OperationAddress) {
-					var counter uint32
-					cntBytes := dasb.Get([]byte(id))
-					if cntBytes != nil {
-						counter = utils.BytesToUint32(cntBytes)
-					}
-
-					if counter > 0 {
-						// counter may go negative if the same record is revoked multiple times.
-						// For now, we allow multiple revocations.
-						counter--
-					}
-
-					if err = dasb.Put([]byte(id), utils.Uint32ToBytes(counter)); err != nil {
+				if prevStatus != model.StatusExpired {
+					if err = releaseDataAssets(tx, &subj); err != nil {
 						return err
 					}
 				}
 				if err = bl.updateRecordState(tx, rec.ID, model.StatusPublished, block.Number); err != nil {
 					return err
 				}
+			case model.OpTypeLeaseRenewal:
+				status := model.StatusPublished
+				if err = renewLease(tx, rec); err != nil {
+					if !errors.Is(err, model.ErrLeaseRenewalInvalid) {
+						return err
+					}
+					log.Warn().Err(err).Str("rid", rec.ID).Msg("Lease renewal failed")
+					status = model.StatusFailed
+				}
+				if err = bl.updateRecordState(tx, rec.ID, status, block.Number); err != nil {
+					return err
+				}
 			case model.OpTypeAssetHead:
 				hb := tx.Bucket([]byte(HeadsKey))
 
@@ -708,6 +747,10 @@ func (bl *BoltLedger) SubmitNewBlock(block *model.Block, records []*model.Record
 					}
 				}
 
+				if err = bl.updateRecordState(tx, rec.ID, model.StatusPublished, block.Number); err != nil {
+					return err
+				}
+			case model.OpTypeLockerInvitation, model.OpTypeLockerSeal:
 				if err = bl.updateRecordState(tx, rec.ID, model.StatusPublished, block.Number); err != nil {
 					return err
 				}
@@ -847,6 +890,7 @@ func generateNonce(seed string, size int) ([]byte, error) {
 func generateNewBlock(ctx context.Context, bl *BoltLedger, seed string) error {
 
 	records := make([]*model.Record, 0)
+	recordIDs := make([]string, 0, len(bl.pendingRecords))
 	for _, id := range bl.pendingRecords {
 		r, err := bl.GetRecord(ctx, id)
 		if err != nil {
@@ -873,6 +917,7 @@ func generateNewBlock(ctx context.Context, bl *BoltLedger, seed string) error {
 		}
 
 		records = append(records, r)
+		recordIDs = append(recordIDs, r.ID)
 	}
 
 	// find the top block
@@ -900,6 +945,7 @@ func generateNewBlock(ctx context.Context, bl *BoltLedger, seed string) error {
 		Number:     number,
 		ParentHash: prevBlockHash,
 		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		MerkleRoot: model.RecordMerkleRoot(recordIDs),
 	}
 
 	if err := newLocalBlock.Seal(); err != nil {
@@ -910,6 +956,9 @@ func generateNewBlock(ctx context.Context, bl *BoltLedger, seed string) error {
 		Number:     newLocalBlock.Number,
 		Hash:       newLocalBlock.Hash,
 		ParentHash: newLocalBlock.ParentHash,
+		Nonce:      newLocalBlock.Nonce,
+		MerkleRoot: newLocalBlock.MerkleRoot,
+		Timestamp:  time.Now().Unix(),
 	}
 
 	err = bl.SubmitNewBlock(newBlock, records)
@@ -19,7 +19,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/piprate/metalocker/contexts"
 	. "github.com/piprate/metalocker/ledger/local"
 	"github.com/piprate/metalocker/model"
@@ -102,3 +104,188 @@ func TestBoltLedger_SaveRecord(t *testing.T) {
 	assert.Equal(t, model.StatusPending, rs.Status)
 	assert.Equal(t, int64(0), rs.BlockNumber)
 }
+
+func TestBoltLedger_LeaseExpiry(t *testing.T) {
+	bl, _, dir := NewTestBoltLedger(t, 0)
+	defer os.RemoveAll(dir) // clean up
+	defer bl.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := bl.OpenNewBlockSession()
+	require.NoError(t, err)
+
+	lease1 := &model.Record{
+		ID:               "lease1",
+		Operation:        model.OpTypeLease,
+		OperationAddress: "op1",
+		DataAssets:       []string{"shared_asset"},
+		ExpiresAt:        now.Add(-time.Minute).Unix(),
+	}
+	lease2 := &model.Record{
+		ID:               "lease2",
+		Operation:        model.OpTypeLease,
+		OperationAddress: "op2",
+		DataAssets:       []string{"shared_asset"},
+		ExpiresAt:        now.Add(time.Hour).Unix(),
+	}
+	require.NoError(t, bl.SaveRecord(lease1))
+	require.NoError(t, bl.SaveRecord(lease2))
+	require.NoError(t, bl.SubmitNewBlock(&model.Block{Number: 1}, []*model.Record{lease1, lease2}))
+
+	ids, err := bl.GetExpiredRecords(ctx, now, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lease1"}, ids)
+
+	// lease2 hasn't expired yet
+
+	expired, err := bl.ExpireRecord(ctx, "lease2", now)
+	require.NoError(t, err)
+	assert.False(t, expired)
+
+	expired, err = bl.ExpireRecord(ctx, "lease1", now)
+	require.NoError(t, err)
+	assert.True(t, expired)
+
+	rs, err := bl.GetRecordState(ctx, "lease1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusExpired, rs.Status)
+
+	state, err := bl.GetDataAssetState(ctx, "op1")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateRemove, state)
+
+	// the shared asset is still used by lease2
+
+	state, err = bl.GetDataAssetState(ctx, "shared_asset")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateKeep, state)
+
+	ids, err = bl.GetExpiredRecords(ctx, now, 0)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	// revocation of an expired lease doesn't release its data assets twice
+
+	lease3 := &model.Record{
+		ID:               "lease3",
+		Operation:        model.OpTypeLease,
+		OperationAddress: "op3",
+		DataAssets:       []string{"shared_asset"},
+	}
+	revocation := &model.Record{
+		ID:            "revocation1",
+		Operation:     model.OpTypeLeaseRevocation,
+		SubjectRecord: "lease1",
+	}
+	require.NoError(t, bl.SaveRecord(lease3))
+	require.NoError(t, bl.SaveRecord(revocation))
+	require.NoError(t, bl.SubmitNewBlock(&model.Block{Number: 2}, []*model.Record{lease3, revocation}))
+
+	rs, err = bl.GetRecordState(ctx, "lease1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRevoked, rs.Status)
+
+	expired, err = bl.ExpireRecord(ctx, "lease2", now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, expired)
+
+	state, err = bl.GetDataAssetState(ctx, "shared_asset")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateKeep, state)
+}
+
+func TestBoltLedger_LeaseRenewal(t *testing.T) {
+	bl, _, dir := NewTestBoltLedger(t, 0)
+	defer os.RemoveAll(dir) // clean up
+	defer bl.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err := bl.OpenNewBlockSession()
+	require.NoError(t, err)
+
+	subjKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	subjRoutingKey, _ := model.BuildRoutingKey(subjKey.PubKey())
+
+	lease := &model.Record{
+		RoutingKey:       subjRoutingKey,
+		Operation:        model.OpTypeLease,
+		OperationAddress: "op1",
+		DataAssets:       []string{"asset1"},
+		ExpiresAt:        now.Add(time.Minute).Unix(),
+	}
+	require.NoError(t, lease.Seal(subjKey))
+	require.NoError(t, bl.SaveRecord(lease))
+	require.NoError(t, bl.SubmitNewBlock(&model.Block{Number: 1}, []*model.Record{lease}))
+
+	newRenewal := func(expiresAt int64, key *btcec.PrivateKey) *model.Record {
+		renewalKey, err := btcec.NewPrivateKey()
+		require.NoError(t, err)
+		routingKey, _ := model.BuildRoutingKey(renewalKey.PubKey())
+		rec := &model.Record{
+			RoutingKey:    routingKey,
+			Operation:     model.OpTypeLeaseRenewal,
+			SubjectRecord: lease.ID,
+			ExpiresAt:     expiresAt,
+		}
+		require.NoError(t, model.SignLeaseRenewal(rec, key))
+		require.NoError(t, rec.Seal(renewalKey))
+		require.NoError(t, bl.SaveRecord(rec))
+		return rec
+	}
+
+	otherKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	renewal := newRenewal(now.Add(time.Hour).Unix(), subjKey)
+	forged := newRenewal(now.Add(2*time.Hour).Unix(), otherKey)
+	require.NoError(t, bl.SubmitNewBlock(&model.Block{Number: 2}, []*model.Record{renewal, forged}))
+
+	rs, err := bl.GetRecordState(ctx, renewal.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPublished, rs.Status)
+
+	rs, err = bl.GetRecordState(ctx, forged.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusFailed, rs.Status)
+
+	rs, err = bl.GetRecordState(ctx, lease.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPublished, rs.Status)
+	assert.Equal(t, renewal.ExpiresAt, rs.ExpiresAt)
+
+	// renewals can't shorten the lease
+
+	shorter := newRenewal(now.Add(30*time.Minute).Unix(), subjKey)
+	require.NoError(t, bl.SubmitNewBlock(&model.Block{Number: 3}, []*model.Record{shorter}))
+
+	rs, err = bl.GetRecordState(ctx, shorter.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusFailed, rs.Status)
+
+	// the lease expires at the renewed time
+
+	ids, err := bl.GetExpiredRecords(ctx, now.Add(10*time.Minute), 0)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	expired, err := bl.ExpireRecord(ctx, lease.ID, now.Add(10*time.Minute))
+	require.NoError(t, err)
+	assert.False(t, expired)
+
+	ids, err = bl.GetExpiredRecords(ctx, now.Add(2*time.Hour), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{lease.ID}, ids)
+
+	expired, err = bl.ExpireRecord(ctx, lease.ID, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, expired)
+
+	state, err := bl.GetDataAssetState(ctx, "asset1")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateRemove, state)
+}
@@ -31,16 +31,18 @@ const (
 	UnconfirmedRecordsKey = "unconfirmed_records"
 	DataAssetStatesKey    = "data_asset_states"
 	HeadsKey              = "heads"
+	LeaseExpiriesKey      = "lease_expiries"
 
 	// control variables
 
 	TopBlockNumberKey   = "top_block_number"
 	CurrentSessionIDKey = "current_session_id"
+	CursorKeyPrefix     = "cursor:"
 )
 
 var (
 	buckets = []string{BlocksKey, BlockCompositionsKey, RecordsKey, RecordStatesKey, ControlsKey,
-		UnconfirmedRecordsKey, DataAssetStatesKey, HeadsKey}
+		UnconfirmedRecordsKey, DataAssetStatesKey, HeadsKey, LeaseExpiriesKey}
 )
 
 func InstallLedgerSchema(bc *utils.BoltClient) error {
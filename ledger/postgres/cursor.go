@@ -0,0 +1,45 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/piprate/metalocker/model"
+)
+
+var _ model.LedgerCursorStore = (*PostgresLedger)(nil)
+
+func (pl *PostgresLedger) GetCursor(ctx context.Context, name string) (int64, error) {
+	var blockNumber int64
+	err := pl.db.QueryRowContext(ctx,
+		`SELECT block_number FROM ledger_cursors WHERE name = $1`, name).Scan(&blockNumber)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return -1, nil
+		}
+		return 0, err
+	}
+	return blockNumber, nil
+}
+
+func (pl *PostgresLedger) SetCursor(ctx context.Context, name string, blockNumber int64) error {
+	_, err := pl.db.ExecContext(ctx,
+		`INSERT INTO ledger_cursors (name, block_number) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET block_number = EXCLUDED.block_number`, name, blockNumber)
+	return err
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/rs/zerolog/log"
+)
+
+var _ model.LeaseExpiryEnforcer = (*PostgresLedger)(nil)
+
+func (pl *PostgresLedger) GetExpiredRecords(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	query := `SELECT id FROM ledger_records
+		WHERE expires_at IS NOT NULL AND expires_at < $1 AND status = $2
+		ORDER BY expires_at`
+	args := []any{now.Unix(), string(model.StatusPublished)}
+	if limit > 0 {
+		query += " LIMIT $3"
+		args = append(args, limit)
+	}
+
+	rows, err := pl.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result = append(result, id)
+	}
+
+	return result, rows.Err()
+}
+
+func (pl *PostgresLedger) ExpireRecord(ctx context.Context, rid string, now time.Time) (bool, error) {
+	tx, err := pl.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rec, err := scanRecord(tx.QueryRowContext(ctx,
+		`UPDATE ledger_records SET status = $2
+		WHERE id = $1 AND status = $3 AND expires_at IS NOT NULL AND expires_at < $4
+		RETURNING body, status`,
+		rid, string(model.StatusExpired), string(model.StatusPublished), now.Unix()), nil)
+	if err != nil {
+		return false, err
+	}
+	if rec == nil {
+		return false, nil
+	}
+
+	if err = releaseDataAssets(ctx, tx, rec); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	log.Debug().Str("rid", rid).Msg("Lease expired")
+
+	return true, nil
+}
@@ -0,0 +1,662 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/piprate/metalocker/ledger"
+	"github.com/piprate/metalocker/ledger/local"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/cmdbase"
+	"github.com/piprate/metalocker/services/notification"
+	"github.com/piprate/metalocker/storage/rdb"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/piprate/metalocker/utils/measure"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	LedgerType = "postgres"
+
+	ParameterInterval           = "interval"
+	ParameterMaxRecordsPerBlock = "max_records_per_block"
+
+	defaultMaxRecordsPerBlock = 1000
+)
+
+func init() {
+	ledger.Register(LedgerType, CreateLedgerConnector)
+}
+
+// PostgresLedger is a MetaLocker ledger backed by a PostgreSQL database.
+// Unlike the local Bolt ledger, it can be shared by several MetaLocker nodes.
+// Submitted records are written to the database straight away. Blocks are
+// produced by whichever node grabs the ledger control lock first, so that
+// concurrent block producers can't fork the chain.
+type PostgresLedger struct {
+	db          *sql.DB
+	ns          notification.Service
+	instantMode bool
+	interval    time.Duration
+
+	maxRecordsPerBlock int
+
+	trigger chan struct{}
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+var (
+	_ model.Ledger                = (*PostgresLedger)(nil)
+	_ model.RecordInclusionProver = (*PostgresLedger)(nil)
+)
+
+func NewPostgresLedger(ctx context.Context, db *sql.DB, ns notification.Service, maxRecordsPerBlock int, blockCheckInterval uint64) (*PostgresLedger, error) {
+	log.Info().Uint64("interval", blockCheckInterval).Msg("Initialising Postgres ledger")
+
+	if err := InstallLedgerSchema(ctx, db); err != nil {
+		return nil, err
+	}
+
+	pl := &PostgresLedger{
+		db:                 db,
+		ns:                 ns,
+		instantMode:        blockCheckInterval == 0,
+		interval:           time.Duration(blockCheckInterval) * time.Second,
+		maxRecordsPerBlock: maxRecordsPerBlock,
+		trigger:            make(chan struct{}, 1),
+		stop:               make(chan struct{}),
+	}
+
+	// generate genesis block, if it doesn't exist
+	if _, err := pl.GetGenesisBlock(ctx); err != nil {
+		if errors.Is(err, model.ErrBlockNotFound) {
+			if _, err = pl.GenerateBlock(ctx); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	pl.startLoop() //nolint:contextcheck
+
+	return pl, nil
+}
+
+func (pl *PostgresLedger) SubmitRecord(ctx context.Context, r *model.Record) error {
+
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	// resubmission of the same record is a no-op
+	_, err := pl.db.ExecContext(ctx,
+		`INSERT INTO ledger_records (id, body, routing_key, key_index, status, expires_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO NOTHING`,
+		r.ID, r.Bytes(), r.RoutingKey, int64(r.KeyIndex), string(model.StatusPending),
+		sql.NullInt64{Int64: r.ExpiresAt, Valid: r.ExpiresAt > 0 && r.Operation == model.OpTypeLease})
+	if err != nil {
+		return err
+	}
+
+	if pl.instantMode {
+		pl.triggerBlockGeneration()
+	}
+
+	return nil
+}
+
+func (pl *PostgresLedger) GetRecord(ctx context.Context, rid string) (*model.Record, error) {
+	return scanRecord(pl.db.QueryRowContext(ctx,
+		`SELECT body, status FROM ledger_records WHERE id = $1`, rid), model.ErrRecordNotFound)
+}
+
+func (pl *PostgresLedger) GetRecordState(ctx context.Context, rid string) (*model.RecordState, error) {
+	var status string
+	var blockNumber, expiresAt sql.NullInt64
+	err := pl.db.QueryRowContext(ctx,
+		`SELECT status, block_number, expires_at FROM ledger_records WHERE id = $1`, rid).Scan(&status, &blockNumber, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// we don't know if the requested record doesn't exist or haven't yet reached the ledger
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &model.RecordState{
+		Status:      model.RecordStatus(status),
+		BlockNumber: blockNumber.Int64,
+		ExpiresAt:   expiresAt.Int64,
+	}, nil
+}
+
+func (pl *PostgresLedger) GetBlock(ctx context.Context, bn int64) (*model.Block, error) {
+	return scanBlock(pl.db.QueryRowContext(ctx,
+		`SELECT number, hash, parent_hash, nonce, merkle_root, created_at FROM ledger_blocks WHERE number = $1`, bn))
+}
+
+func (pl *PostgresLedger) GetBlockRecords(ctx context.Context, bn int64) ([][]string, error) {
+	if _, err := pl.GetBlock(ctx, bn); err != nil {
+		return nil, err
+	}
+
+	rows, err := pl.db.QueryContext(ctx,
+		`SELECT id, routing_key, key_index FROM ledger_records WHERE block_number = $1 ORDER BY block_position`, bn)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([][]string, 0)
+	for rows.Next() {
+		var id, routingKey string
+		var keyIndex int64
+		if err = rows.Scan(&id, &routingKey, &keyIndex); err != nil {
+			return nil, err
+		}
+		res = append(res, []string{id, routingKey, strconv.FormatInt(keyIndex, 10)})
+	}
+
+	return res, rows.Err()
+}
+
+func (pl *PostgresLedger) GetGenesisBlock(ctx context.Context) (*model.Block, error) {
+	return pl.GetBlock(ctx, 0)
+}
+
+func (pl *PostgresLedger) GetTopBlock(ctx context.Context) (*model.Block, error) {
+	return scanBlock(pl.db.QueryRowContext(ctx,
+		`SELECT number, hash, parent_hash, nonce, merkle_root, created_at FROM ledger_blocks ORDER BY number DESC LIMIT 1`))
+}
+
+func (pl *PostgresLedger) GetChain(ctx context.Context, startNumber int64, depth int) ([]*model.Block, error) {
+	rows, err := pl.db.QueryContext(ctx,
+		`SELECT number, hash, parent_hash, nonce, merkle_root, created_at FROM ledger_blocks WHERE number >= $1 ORDER BY number LIMIT $2`,
+		startNumber, depth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*model.Block, 0)
+	for rows.Next() {
+		var b model.Block
+		if err = rows.Scan(&b.Number, &b.Hash, &b.ParentHash, &b.Nonce, &b.MerkleRoot); err != nil {
+			return nil, err
+		}
+		result = append(result, &b)
+	}
+
+	return result, rows.Err()
+}
+
+func (pl *PostgresLedger) GetDataAssetState(ctx context.Context, id string) (model.DataAssetState, error) {
+	var counter int64
+	err := pl.db.QueryRowContext(ctx,
+		`SELECT counter FROM ledger_data_asset_states WHERE id = $1`, id).Scan(&counter)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.DataAssetStateNotFound, nil
+		}
+		return model.DataAssetStateKeep, err
+	}
+
+	if counter == 0 {
+		return model.DataAssetStateRemove, nil
+	}
+
+	return model.DataAssetStateKeep, nil
+}
+
+func (pl *PostgresLedger) GetAssetHead(ctx context.Context, headID string) (*model.Record, error) {
+	return scanRecord(pl.db.QueryRowContext(ctx,
+		`SELECT r.body, r.status FROM ledger_heads h JOIN ledger_records r ON r.id = h.record_id WHERE h.head_id = $1`,
+		headID), model.ErrAssetHeadNotFound)
+}
+
+func (pl *PostgresLedger) GetRecordInclusionProof(ctx context.Context, rid string) (*model.RecordInclusionProof, error) {
+	return ledger.BuildRecordInclusionProof(ctx, pl, rid)
+}
+
+func (pl *PostgresLedger) Close() error {
+	if pl.stop != nil {
+		log.Info().Msg("Stopping Postgres ledger block producer")
+		close(pl.stop)
+		pl.wg.Wait()
+		pl.stop = nil
+	}
+
+	log.Info().Msg("Closing Postgres ledger")
+
+	return pl.db.Close()
+}
+
+func (pl *PostgresLedger) triggerBlockGeneration() {
+	select {
+	case pl.trigger <- struct{}{}:
+	default:
+		// block generation is already scheduled
+	}
+}
+
+func (pl *PostgresLedger) startLoop() {
+	pl.wg.Add(1)
+	go func() {
+		defer pl.wg.Done()
+
+		var ticks <-chan time.Time
+		if !pl.instantMode {
+			log.Warn().Dur("interval", pl.interval).Msg("Postgres ledger block check interval")
+			ticker := time.NewTicker(pl.interval)
+			defer ticker.Stop()
+			ticks = ticker.C
+		}
+
+		ctx := context.Background()
+		sampledLog := log.Sample(&zerolog.BasicSampler{N: 10})
+		for {
+			select {
+			case <-pl.trigger:
+				pl.generatePendingBlocks(ctx)
+			case <-ticks:
+				sampledLog.Debug().Msg("Block generation check")
+				pl.generatePendingBlocks(ctx)
+			case <-pl.stop:
+				log.Info().Msg("Shutting down main loop for Postgres ledger")
+				return
+			}
+		}
+	}()
+}
+
+func (pl *PostgresLedger) generatePendingBlocks(ctx context.Context) {
+	for {
+		count, err := pl.GenerateBlock(ctx)
+		if err != nil {
+			log.Err(err).Msg("Failed to generate new block")
+			return
+		}
+		if count < pl.maxRecordsPerBlock {
+			return
+		}
+	}
+}
+
+// GenerateBlock produces a new block from the unconfirmed records. It's safe to call
+// GenerateBlock from several nodes at the same time: block production is serialised
+// by locking the ledger control row. If there are no unconfirmed records, no block
+// is produced (except for the genesis block). Returns the number of records
+// included into the new block.
+func (pl *PostgresLedger) GenerateBlock(ctx context.Context) (int, error) {
+	defer measure.ExecTime("postgres.GenerateBlock")()
+
+	tx, err := pl.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var topNumber int64
+	if err = tx.QueryRowContext(ctx,
+		`SELECT top_block FROM ledger_control WHERE id = 1 FOR UPDATE`).Scan(&topNumber); err != nil {
+		return 0, err
+	}
+
+	records, err := pl.loadUnconfirmedRecords(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(records) == 0 && topNumber >= 0 {
+		// nothing to do
+		return 0, nil
+	}
+
+	var prevBlockHash string
+	if topNumber >= 0 {
+		prevBlock, err := scanBlock(tx.QueryRowContext(ctx,
+			`SELECT number, hash, parent_hash, nonce, merkle_root, created_at FROM ledger_blocks WHERE number = $1`, topNumber))
+		if err != nil {
+			return 0, err
+		}
+		prevBlockHash = prevBlock.Hash
+	}
+
+	nonce := make([]byte, 32)
+	if _, err = rand.Read(nonce); err != nil {
+		return 0, err
+	}
+
+	recordIDs := make([]string, len(records))
+	for i, rec := range records {
+		recordIDs[i] = rec.ID
+	}
+
+	newBlock := &local.LocalBlock{
+		Number:     topNumber + 1,
+		ParentHash: prevBlockHash,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		MerkleRoot: model.RecordMerkleRoot(recordIDs),
+	}
+
+	if err = newBlock.Seal(); err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.ExecContext(ctx,
+		`INSERT INTO ledger_blocks (number, hash, parent_hash, nonce, merkle_root) VALUES ($1, $2, $3, $4, $5)`,
+		newBlock.Number, newBlock.Hash, newBlock.ParentHash, newBlock.Nonce, newBlock.MerkleRoot); err != nil {
+		return 0, err
+	}
+
+	for idx, rec := range records {
+		status, err := applyRecord(ctx, tx, rec)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err = tx.ExecContext(ctx,
+			`UPDATE ledger_records SET status = $2, block_number = $3, block_position = $4 WHERE id = $1`,
+			rec.ID, string(status), newBlock.Number, idx); err != nil {
+			return 0, err
+		}
+	}
+
+	// Consider this block published
+
+	if _, err = tx.ExecContext(ctx,
+		`UPDATE ledger_control SET top_block = $1 WHERE id = 1`, newBlock.Number); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	log.Info().Int64("number", newBlock.Number).Int("records", len(records)).Msg("----==== NEW BLOCK ====----")
+
+	if pl.ns != nil {
+		_ = pl.ns.Publish(&model.NewBlockMessage{
+			Type:   model.MessageTypeNewBlockNotification,
+			Number: newBlock.Number,
+		}, false, false, model.NTopicNewBlock)
+	}
+
+	return len(records), nil
+}
+
+func (pl *PostgresLedger) loadUnconfirmedRecords(ctx context.Context, tx *sql.Tx) ([]*model.Record, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT body, status FROM ledger_records WHERE block_number IS NULL ORDER BY seq LIMIT $1`,
+		pl.maxRecordsPerBlock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]*model.Record, 0)
+	for rows.Next() {
+		rec, err := scanRecord(rows, model.ErrRecordNotFound)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	return records, rows.Err()
+}
+
+// applyRecord applies the record's operation to the ledger state and returns
+// the resulting status of the record.
+func applyRecord(ctx context.Context, tx *sql.Tx, rec *model.Record) (model.RecordStatus, error) {
+	switch rec.Operation {
+	case model.OpTypeLease:
+		// update data asset counters
+		for _, id := range append(rec.DataAssets, rec.OperationAddress) {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO ledger_data_asset_states (id, counter) VALUES ($1, 1)
+				ON CONFLICT (id) DO UPDATE SET counter = ledger_data_asset_states.counter + 1`, id); err != nil {
+				return "", err
+			}
+		}
+		return model.StatusPublished, nil
+	case model.OpTypeLeaseRevocation:
+		subj, err := scanRecord(tx.QueryRowContext(ctx,
+			`SELECT body, status FROM ledger_records WHERE id = $1`, rec.SubjectRecord), model.ErrRecordNotFound)
+		if err != nil {
+			if errors.Is(err, model.ErrRecordNotFound) {
+				log.Warn().Str("rid", rec.ID).Msg("Subject record not found for revocation record")
+				return model.StatusFailed, nil
+			}
+			return "", err
+		}
+
+		if err = verifyRevocationProof(rec, subj); err != nil {
+			log.Warn().Err(err).Str("rid", rec.ID).Msg("Revocation failed")
+			return model.StatusFailed, nil
+		}
+
+		// apply revocation
+		updated, err := updateRecordStatus(ctx, tx, rec.SubjectRecord, model.StatusRevoked)
+		if err != nil {
+			return "", err
+		}
+		if !updated {
+			return model.StatusFailed, nil
+		}
+
+		// update data asset counters (unless they were released when the lease expired)
+		if subj.Status != model.StatusExpired {
+			if err = releaseDataAssets(ctx, tx, subj); err != nil {
+				return "", err
+			}
+		}
+		return model.StatusPublished, nil
+	case model.OpTypeLeaseRenewal:
+		subj, err := scanRecord(tx.QueryRowContext(ctx,
+			`SELECT body, status FROM ledger_records WHERE id = $1`, rec.SubjectRecord), model.ErrRecordNotFound)
+		if err != nil {
+			if errors.Is(err, model.ErrRecordNotFound) {
+				log.Warn().Str("rid", rec.ID).Msg("Subject record not found for lease renewal record")
+				return model.StatusFailed, nil
+			}
+			return "", err
+		}
+
+		if err = model.VerifyLeaseRenewal(rec, subj); err != nil {
+			log.Warn().Err(err).Str("rid", rec.ID).Msg("Lease renewal failed")
+			return model.StatusFailed, nil
+		}
+
+		// extend the lease, if it's still active and the new expiry time
+		// is after the current one
+		res, err := tx.ExecContext(ctx,
+			`UPDATE ledger_records SET expires_at = $2 WHERE id = $1 AND status = $3 AND expires_at < $2`,
+			rec.SubjectRecord, rec.ExpiresAt, string(model.StatusPublished))
+		if err != nil {
+			return "", err
+		}
+		cnt, err := res.RowsAffected()
+		if err != nil {
+			return "", err
+		}
+		if cnt == 0 {
+			log.Warn().Str("rid", rec.ID).Msg("Lease renewal failed: lease isn't active or new expiry time is too early")
+			return model.StatusFailed, nil
+		}
+		return model.StatusPublished, nil
+	case model.OpTypeAssetHead:
+		var prevHeadRecordID string
+		err := tx.QueryRowContext(ctx,
+			`SELECT record_id FROM ledger_heads WHERE head_id = $1`, rec.HeadID).Scan(&prevHeadRecordID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+
+		if _, err = tx.ExecContext(ctx,
+			`INSERT INTO ledger_heads (head_id, record_id) VALUES ($1, $2)
+			ON CONFLICT (head_id) DO UPDATE SET record_id = EXCLUDED.record_id`, rec.HeadID, rec.ID); err != nil {
+			return "", err
+		}
+
+		if prevHeadRecordID != "" {
+			if _, err = updateRecordStatus(ctx, tx, prevHeadRecordID, model.StatusRevoked); err != nil {
+				return "", err
+			}
+		}
+		return model.StatusPublished, nil
+	case model.OpTypeLockerInvitation, model.OpTypeLockerSeal:
+		return model.StatusPublished, nil
+	default:
+		return model.StatusFailed, nil
+	}
+}
+
+// releaseDataAssets decrements usage counters for the record's data assets
+// and its operation.
+func releaseDataAssets(ctx context.Context, tx *sql.Tx, rec *model.Record) error {
+	for _, id := range append(rec.DataAssets, rec.OperationAddress) {
+		// counter may go negative if the same record is revoked multiple times.
+		// For now, we allow multiple revocations.
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO ledger_data_asset_states (id, counter) VALUES ($1, 0)
+			ON CONFLICT (id) DO UPDATE SET counter = GREATEST(ledger_data_asset_states.counter - 1, 0)`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyRevocationProof(rec, subj *model.Record) error {
+	if len(rec.RevocationProof) != 1 {
+		return errors.New("bad revocation proof format")
+	}
+	proof, _ := base64.StdEncoding.DecodeString(rec.RevocationProof[0])
+	subjAC := sha256.Sum256(proof)
+
+	if base64.StdEncoding.EncodeToString(subjAC[:]) != subj.AuthorisingCommitment {
+		return errors.New("bad revocation proof")
+	}
+
+	return nil
+}
+
+// updateRecordStatus changes the status of the given record, unless it was revoked.
+// Returns false if the record wasn't found or was already revoked.
+func updateRecordStatus(ctx context.Context, tx *sql.Tx, rid string, status model.RecordStatus) (bool, error) {
+	res, err := tx.ExecContext(ctx,
+		`UPDATE ledger_records SET status = $2 WHERE id = $1 AND status <> $3`,
+		rid, string(status), string(model.StatusRevoked))
+	if err != nil {
+		return false, err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return cnt > 0, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRecord(row rowScanner, notFoundErr error) (*model.Record, error) {
+	var body []byte
+	var status string
+	if err := row.Scan(&body, &status); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, notFoundErr
+		}
+		return nil, err
+	}
+
+	var r model.Record
+	if err := jsonw.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+	r.Status = model.RecordStatus(status)
+
+	return &r, nil
+}
+
+func scanBlock(row rowScanner) (*model.Block, error) {
+	var b model.Block
+	var createdAt time.Time
+	if err := row.Scan(&b.Number, &b.Hash, &b.ParentHash, &b.Nonce, &b.MerkleRoot, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrBlockNotFound
+		}
+		return nil, err
+	}
+	b.Timestamp = createdAt.Unix()
+
+	return &b, nil
+}
+
+func CreateLedgerConnector(ctx context.Context, params ledger.Parameters, ns notification.Service, resolver cmdbase.ParameterResolver) (model.Ledger, error) {
+	databaseURL, err := rdb.ReadDatabaseURL(params, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := rdb.SchemeFromURL(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if schema != "postgres" {
+		return nil, fmt.Errorf("unsupported database schema for Postgres ledger: %s", schema)
+	}
+
+	blockCheckInterval, _ := params[ParameterInterval].(float64)
+
+	maxRecordsPerBlock := defaultMaxRecordsPerBlock
+	if val, ok := params[ParameterMaxRecordsPerBlock].(float64); ok && val > 0 {
+		maxRecordsPerBlock = int(val)
+	}
+
+	var logLevel int
+	if val, ok := params[rdb.ParameterLogLevel].(float64); ok {
+		logLevel = int(val)
+	}
+
+	db, _, err := rdb.OpenDB(databaseURL, zerolog.Level(logLevel))
+	if err != nil {
+		return nil, err
+	}
+
+	pl, err := NewPostgresLedger(ctx, db, ns, maxRecordsPerBlock, uint64(blockCheckInterval))
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return pl, nil
+}
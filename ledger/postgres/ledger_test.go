@@ -0,0 +1,440 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	. "github.com/piprate/metalocker/ledger/postgres"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/piprate/metalocker/sdk/testbase/pgembed"
+	"github.com/piprate/metalocker/storage/rdb"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testbase.SetupLogFormat()
+}
+
+// newTestLedger returns a Postgres ledger with a long block check interval,
+// so that the tests can control block production via GenerateBlock.
+func newTestLedger(t *testing.T, databaseURL string) *PostgresLedger {
+	t.Helper()
+
+	db, _, err := rdb.OpenDB(databaseURL, zerolog.InfoLevel)
+	require.NoError(t, err)
+
+	pl, err := NewPostgresLedger(context.Background(), db, nil, 1000, 3600)
+	require.NoError(t, err)
+
+	return pl
+}
+
+func newLease(id string, proof []byte, assets ...string) *model.Record {
+	ac := sha256.Sum256(proof)
+	return &model.Record{
+		ID:                    id,
+		RoutingKey:            "rk-" + id,
+		KeyIndex:              1,
+		Operation:             model.OpTypeLease,
+		OperationAddress:      "op-" + id,
+		AuthorisingCommitment: base64.StdEncoding.EncodeToString(ac[:]),
+		DataAssets:            assets,
+	}
+}
+
+func newRevocation(id string, subj string, proof []byte) *model.Record {
+	return &model.Record{
+		ID:              id,
+		RoutingKey:      "rk-" + id,
+		KeyIndex:        2,
+		Operation:       model.OpTypeLeaseRevocation,
+		SubjectRecord:   subj,
+		RevocationProof: []string{base64.StdEncoding.EncodeToString(proof)},
+	}
+}
+
+func TestPostgresLedger_GetGenesisBlock(t *testing.T) {
+	databaseURL, edb, dir := pgembed.NewEmbeddedDatabase(t)
+	defer pgembed.StopEmbeddedDatabase(t, edb, dir)
+
+	pl := newTestLedger(t, databaseURL)
+	defer pl.Close()
+
+	ctx := context.Background()
+
+	gb, err := pl.GetGenesisBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), gb.Number)
+	assert.Empty(t, gb.ParentHash)
+
+	tb, err := pl.GetTopBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, gb, tb)
+
+	// restarting the ledger doesn't produce a new genesis block
+
+	pl2 := newTestLedger(t, databaseURL)
+	defer pl2.Close()
+
+	gb2, err := pl2.GetGenesisBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, gb, gb2)
+}
+
+func TestPostgresLedger_LeaseAndRevocation(t *testing.T) {
+	databaseURL, edb, dir := pgembed.NewEmbeddedDatabase(t)
+	defer pgembed.StopEmbeddedDatabase(t, edb, dir)
+
+	pl := newTestLedger(t, databaseURL)
+	defer pl.Close()
+
+	ctx := context.Background()
+
+	proof := []byte("revocation proof")
+	lease := newLease("lease1", proof, "asset1")
+
+	err := pl.SubmitRecord(ctx, lease)
+	require.NoError(t, err)
+
+	rs, err := pl.GetRecordState(ctx, lease.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPending, rs.Status)
+
+	cnt, err := pl.GenerateBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	rec, err := pl.GetRecord(ctx, lease.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPublished, rec.Status)
+	assert.Equal(t, []string{"asset1"}, rec.DataAssets)
+
+	rs, err = pl.GetRecordState(ctx, lease.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rs.BlockNumber)
+
+	records, err := pl.GetBlockRecords(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"lease1", "rk-lease1", "1"}}, records)
+
+	inclusionProof, err := pl.GetRecordInclusionProof(ctx, lease.ID)
+	require.NoError(t, err)
+	header, err := pl.GetBlock(ctx, 1)
+	require.NoError(t, err)
+	require.NoError(t, inclusionProof.Verify(header))
+
+	state, err := pl.GetDataAssetState(ctx, "asset1")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateKeep, state)
+
+	state, err = pl.GetDataAssetState(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateNotFound, state)
+
+	// revocation with a bad proof fails
+
+	err = pl.SubmitRecord(ctx, newRevocation("bad_revocation", lease.ID, []byte("wrong proof")))
+	require.NoError(t, err)
+
+	// revocation with the correct proof succeeds
+
+	err = pl.SubmitRecord(ctx, newRevocation("revocation", lease.ID, proof))
+	require.NoError(t, err)
+
+	cnt, err = pl.GenerateBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+
+	rec, err = pl.GetRecord(ctx, "bad_revocation")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusFailed, rec.Status)
+
+	rec, err = pl.GetRecord(ctx, "revocation")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPublished, rec.Status)
+
+	rec, err = pl.GetRecord(ctx, lease.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRevoked, rec.Status)
+
+	state, err = pl.GetDataAssetState(ctx, "asset1")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateRemove, state)
+
+	// no pending records, no new block
+
+	cnt, err = pl.GenerateBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+
+	chain, err := pl.GetChain(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, chain, 3)
+	for i := 1; i < len(chain); i++ {
+		assert.Equal(t, chain[i-1].Hash, chain[i].ParentHash)
+	}
+
+	_, err = pl.GetRecord(ctx, "unknown")
+	assert.ErrorIs(t, err, model.ErrRecordNotFound)
+
+	_, err = pl.GetBlockRecords(ctx, 10)
+	assert.ErrorIs(t, err, model.ErrBlockNotFound)
+}
+
+func TestPostgresLedger_LeaseExpiry(t *testing.T) {
+	databaseURL, edb, dir := pgembed.NewEmbeddedDatabase(t)
+	defer pgembed.StopEmbeddedDatabase(t, edb, dir)
+
+	pl := newTestLedger(t, databaseURL)
+	defer pl.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	proof := []byte("revocation proof")
+	lease1 := newLease("lease1", proof, "shared_asset")
+	lease1.ExpiresAt = now.Add(-time.Minute).Unix()
+	lease2 := newLease("lease2", proof, "shared_asset")
+	lease2.ExpiresAt = now.Add(time.Hour).Unix()
+
+	require.NoError(t, pl.SubmitRecord(ctx, lease1))
+	require.NoError(t, pl.SubmitRecord(ctx, lease2))
+	_, err := pl.GenerateBlock(ctx)
+	require.NoError(t, err)
+
+	ids, err := pl.GetExpiredRecords(ctx, now, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lease1"}, ids)
+
+	expired, err := pl.ExpireRecord(ctx, "lease2", now)
+	require.NoError(t, err)
+	assert.False(t, expired)
+
+	expired, err = pl.ExpireRecord(ctx, "lease1", now)
+	require.NoError(t, err)
+	assert.True(t, expired)
+
+	rs, err := pl.GetRecordState(ctx, "lease1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusExpired, rs.Status)
+
+	state, err := pl.GetDataAssetState(ctx, "op-lease1")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateRemove, state)
+
+	state, err = pl.GetDataAssetState(ctx, "shared_asset")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateKeep, state)
+
+	// revocation of an expired lease doesn't release its data assets twice
+
+	require.NoError(t, pl.SubmitRecord(ctx, newRevocation("revocation1", "lease1", proof)))
+	_, err = pl.GenerateBlock(ctx)
+	require.NoError(t, err)
+
+	state, err = pl.GetDataAssetState(ctx, "shared_asset")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateKeep, state)
+
+	expired, err = pl.ExpireRecord(ctx, "lease2", now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, expired)
+
+	state, err = pl.GetDataAssetState(ctx, "shared_asset")
+	require.NoError(t, err)
+	assert.Equal(t, model.DataAssetStateRemove, state)
+}
+
+func TestPostgresLedger_GetAssetHead(t *testing.T) {
+	databaseURL, edb, dir := pgembed.NewEmbeddedDatabase(t)
+	defer pgembed.StopEmbeddedDatabase(t, edb, dir)
+
+	pl := newTestLedger(t, databaseURL)
+	defer pl.Close()
+
+	ctx := context.Background()
+
+	_, err := pl.GetAssetHead(ctx, "head1")
+	assert.ErrorIs(t, err, model.ErrAssetHeadNotFound)
+
+	for _, id := range []string{"head_rec1", "head_rec2"} {
+		err = pl.SubmitRecord(ctx, &model.Record{
+			ID:        id,
+			Operation: model.OpTypeAssetHead,
+			HeadID:    "head1",
+			HeadBody:  "body",
+		})
+		require.NoError(t, err)
+
+		_, err = pl.GenerateBlock(ctx)
+		require.NoError(t, err)
+	}
+
+	rec, err := pl.GetAssetHead(ctx, "head1")
+	require.NoError(t, err)
+	assert.Equal(t, "head_rec2", rec.ID)
+	assert.Equal(t, model.StatusPublished, rec.Status)
+
+	rec, err = pl.GetRecord(ctx, "head_rec1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRevoked, rec.Status)
+}
+
+func TestPostgresLedger_ConcurrentNodes(t *testing.T) {
+	databaseURL, edb, dir := pgembed.NewEmbeddedDatabase(t)
+	defer pgembed.StopEmbeddedDatabase(t, edb, dir)
+
+	ctx := context.Background()
+
+	nodes := []*PostgresLedger{
+		newTestLedger(t, databaseURL),
+		newTestLedger(t, databaseURL),
+		newTestLedger(t, databaseURL),
+	}
+	defer func() {
+		for _, pl := range nodes {
+			_ = pl.Close()
+		}
+	}()
+
+	const recordsPerNode = 20
+
+	var wg sync.WaitGroup
+	for i, pl := range nodes {
+		wg.Add(1)
+		go func(i int, pl *PostgresLedger) {
+			defer wg.Done()
+			for j := 0; j < recordsPerNode; j++ {
+				err := pl.SubmitRecord(ctx, newLease(fmt.Sprintf("node%d_rec%d", i, j), []byte("proof"), "shared_asset"))
+				assert.NoError(t, err)
+				_, err = pl.GenerateBlock(ctx)
+				assert.NoError(t, err)
+			}
+		}(i, pl)
+	}
+	wg.Wait()
+
+	// each record is included into exactly one block and the chain is intact
+
+	top, err := nodes[0].GetTopBlock(ctx)
+	require.NoError(t, err)
+
+	chain, err := nodes[1].GetChain(ctx, 0, int(top.Number)+1)
+	require.NoError(t, err)
+	require.Len(t, chain, int(top.Number)+1)
+
+	seen := make(map[string]bool)
+	for i, b := range chain {
+		assert.Equal(t, int64(i), b.Number)
+		if i > 0 {
+			assert.Equal(t, chain[i-1].Hash, b.ParentHash)
+		}
+
+		records, err := nodes[2].GetBlockRecords(ctx, b.Number)
+		require.NoError(t, err)
+		for _, r := range records {
+			assert.False(t, seen[r[0]], "record included twice: %s", r[0])
+			seen[r[0]] = true
+		}
+	}
+	assert.Len(t, seen, len(nodes)*recordsPerNode)
+
+	db, err := sql.Open("pgx", databaseURL)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var counter int64
+	err = db.QueryRow(`SELECT counter FROM ledger_data_asset_states WHERE id = 'shared_asset'`).Scan(&counter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(nodes)*recordsPerNode), counter)
+}
+
+func TestPostgresLedger_LeaseRenewal(t *testing.T) {
+	databaseURL, edb, dir := pgembed.NewEmbeddedDatabase(t)
+	defer pgembed.StopEmbeddedDatabase(t, edb, dir)
+
+	pl := newTestLedger(t, databaseURL)
+	defer pl.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	subjKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	lease := newLease("lease1", []byte("revocation proof"), "asset1")
+	lease.RoutingKey, _ = model.BuildRoutingKey(subjKey.PubKey())
+	lease.ExpiresAt = now.Add(time.Minute).Unix()
+
+	require.NoError(t, pl.SubmitRecord(ctx, lease))
+	_, err = pl.GenerateBlock(ctx)
+	require.NoError(t, err)
+
+	newRenewal := func(id string, expiresAt int64, key *btcec.PrivateKey) *model.Record {
+		rec := &model.Record{
+			ID:            id,
+			RoutingKey:    "rk-" + id,
+			KeyIndex:      2,
+			Operation:     model.OpTypeLeaseRenewal,
+			SubjectRecord: lease.ID,
+			ExpiresAt:     expiresAt,
+		}
+		require.NoError(t, model.SignLeaseRenewal(rec, key))
+		return rec
+	}
+
+	otherKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	require.NoError(t, pl.SubmitRecord(ctx, newRenewal("renewal1", now.Add(time.Hour).Unix(), subjKey)))
+	require.NoError(t, pl.SubmitRecord(ctx, newRenewal("forged", now.Add(2*time.Hour).Unix(), otherKey)))
+	require.NoError(t, pl.SubmitRecord(ctx, newRenewal("shorter", now.Add(30*time.Minute).Unix(), subjKey)))
+	_, err = pl.GenerateBlock(ctx)
+	require.NoError(t, err)
+
+	for id, status := range map[string]model.RecordStatus{
+		"renewal1": model.StatusPublished,
+		"forged":   model.StatusFailed,
+		"shorter":  model.StatusFailed,
+	} {
+		rs, err := pl.GetRecordState(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, status, rs.Status, id)
+	}
+
+	rs, err := pl.GetRecordState(ctx, lease.ID)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour).Unix(), rs.ExpiresAt)
+
+	ids, err := pl.GetExpiredRecords(ctx, now.Add(10*time.Minute), 0)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	expired, err := pl.ExpireRecord(ctx, lease.ID, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, expired)
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+)
+
+// The ledger_control table always contains a single row which is locked
+// during block production. This makes block production safe when several
+// nodes share the same database.
+var schemaStatements = []string{
+	`CREATE TABLE IF NOT EXISTS ledger_control (
+		id SMALLINT PRIMARY KEY,
+		top_block BIGINT NOT NULL
+	)`,
+	`INSERT INTO ledger_control (id, top_block) VALUES (1, -1) ON CONFLICT (id) DO NOTHING`,
+	`CREATE TABLE IF NOT EXISTS ledger_blocks (
+		number BIGINT PRIMARY KEY,
+		hash TEXT NOT NULL,
+		parent_hash TEXT NOT NULL,
+		nonce TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`ALTER TABLE ledger_blocks ADD COLUMN IF NOT EXISTS merkle_root TEXT NOT NULL DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS ledger_records (
+		seq BIGSERIAL UNIQUE,
+		id TEXT PRIMARY KEY,
+		body BYTEA NOT NULL,
+		routing_key TEXT NOT NULL,
+		key_index BIGINT NOT NULL,
+		status TEXT NOT NULL,
+		block_number BIGINT,
+		block_position INTEGER
+	)`,
+	`CREATE INDEX IF NOT EXISTS ledger_records_block_idx ON ledger_records (block_number, block_position)`,
+	`CREATE INDEX IF NOT EXISTS ledger_records_unconfirmed_idx ON ledger_records (seq) WHERE block_number IS NULL`,
+	`ALTER TABLE ledger_records ADD COLUMN IF NOT EXISTS expires_at BIGINT`,
+	`CREATE INDEX IF NOT EXISTS ledger_records_expiry_idx ON ledger_records (expires_at)
+		WHERE expires_at IS NOT NULL AND status = 'published'`,
+	`CREATE TABLE IF NOT EXISTS ledger_data_asset_states (
+		id TEXT PRIMARY KEY,
+		counter BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ledger_heads (
+		head_id TEXT PRIMARY KEY,
+		record_id TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ledger_cursors (
+		name TEXT PRIMARY KEY,
+		block_number BIGINT NOT NULL
+	)`,
+}
+
+// InstallLedgerSchema creates the ledger tables, if they don't exist.
+// It's safe to call it from several nodes at the same time.
+func InstallLedgerSchema(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// serialise concurrent schema installations
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('metalocker_ledger_schema'))"); err != nil {
+		return err
+	}
+
+	for _, stmt := range schemaStatements {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ledger
+
+import (
+	"context"
+
+	"github.com/piprate/metalocker/model"
+)
+
+// BuildRecordInclusionProof builds a Merkle inclusion proof for the given record
+// using the standard Ledger methods. Ledger implementations can use it to implement
+// model.RecordInclusionProver.
+func BuildRecordInclusionProof(ctx context.Context, l model.Ledger, rid string) (*model.RecordInclusionProof, error) {
+	state, err := l.GetRecordState(ctx, rid)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil || state.Status == model.StatusPending {
+		return nil, model.ErrRecordNotFound
+	}
+
+	records, err := l.GetBlockRecords(ctx, state.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	recordIDs := make([]string, len(records))
+	for i, entry := range records {
+		if len(entry) > 0 {
+			recordIDs[i] = entry[0]
+		}
+	}
+
+	return model.NewRecordInclusionProof(state.BlockNumber, recordIDs, rid)
+}
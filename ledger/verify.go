@@ -0,0 +1,338 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ledger
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	IssueMissingBlock        = "missing_block"
+	IssueBlockNumberMismatch = "block_number_mismatch"
+	IssueBlockHashMismatch   = "block_hash_mismatch"
+	IssueParentHashMismatch  = "parent_hash_mismatch"
+	IssueMerkleRootMismatch  = "merkle_root_mismatch"
+	IssueRecordNotFound      = "record_not_found"
+	IssueRecordIDMismatch    = "record_id_mismatch"
+	IssueRecordDuplicate     = "duplicate_record"
+	IssueOrphanedRecord      = "orphaned_record"
+	IssueRecordStateMismatch = "record_state_mismatch"
+	IssueBadBlockComposition = "bad_block_composition"
+
+	verificationBatchSize = 100
+)
+
+type (
+	// VerificationIssue describes a single problem found during ledger verification.
+	VerificationIssue struct {
+		Type     string `json:"type"`
+		Block    int64  `json:"block"`
+		RecordID string `json:"recordID,omitempty"`
+		Expected string `json:"expected,omitempty"`
+		Actual   string `json:"actual,omitempty"`
+		Message  string `json:"message,omitempty"`
+	}
+
+	// VerificationReport is the result of ledger verification (see VerifyLedger).
+	VerificationReport struct {
+		// Valid is true if no integrity issues were found. Unverifiable blocks
+		// (blocks without a nonce) don't make the ledger invalid.
+		Valid bool `json:"valid"`
+		// TopBlock is the number of the top block at the start of verification.
+		TopBlock int64 `json:"topBlock"`
+		// BlocksChecked is the number of blocks checked.
+		BlocksChecked int64 `json:"blocksChecked"`
+		// RecordsChecked is the number of records checked.
+		RecordsChecked int64 `json:"recordsChecked"`
+		// FirstBrokenBlock is the number of the first block with a broken hash
+		// or parent link, if any.
+		FirstBrokenBlock *int64 `json:"firstBrokenBlock,omitempty"`
+		// HashMismatches lists blocks and records whose hashes or IDs don't match
+		// their contents, as well as broken parent links.
+		HashMismatches []*VerificationIssue `json:"hashMismatches,omitempty"`
+		// OrphanedRecords lists records that are included into a block,
+		// but whose state doesn't point to this block.
+		OrphanedRecords []*VerificationIssue `json:"orphanedRecords,omitempty"`
+		// Issues lists all other problems found.
+		Issues []*VerificationIssue `json:"issues,omitempty"`
+		// UnverifiableBlocks lists blocks whose hashes can't be re-computed
+		// because the ledger doesn't provide block nonces.
+		UnverifiableBlocks []int64 `json:"unverifiableBlocks,omitempty"`
+	}
+)
+
+func (r *VerificationReport) brokenBlock(number int64) {
+	if r.FirstBrokenBlock == nil || *r.FirstBrokenBlock > number {
+		r.FirstBrokenBlock = &number
+	}
+	r.Valid = false
+}
+
+func (r *VerificationReport) addHashMismatch(issue *VerificationIssue) {
+	r.HashMismatches = append(r.HashMismatches, issue)
+	r.Valid = false
+}
+
+func (r *VerificationReport) addOrphan(issue *VerificationIssue) {
+	r.OrphanedRecords = append(r.OrphanedRecords, issue)
+	r.Valid = false
+}
+
+func (r *VerificationReport) addIssue(issue *VerificationIssue) {
+	r.Issues = append(r.Issues, issue)
+	r.Valid = false
+}
+
+// VerifyLedger walks the ledger from the genesis block to the top block and checks
+// its integrity:
+//
+//   - every block's hash matches its number, parent hash, nonce and Merkle root;
+//   - every block's Merkle root matches the block's records;
+//   - every block points to the previous block's hash;
+//   - every record's ID matches its body and signature;
+//   - every record's state points to the block that contains the record.
+//
+// It returns an error only if the ledger can't be read. All integrity problems
+// are listed in the returned report.
+func VerifyLedger(ctx context.Context, l model.Ledger) (*VerificationReport, error) {
+	report := &VerificationReport{
+		Valid: true,
+	}
+
+	genesis, err := l.GetGenesisBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	top, err := l.GetTopBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report.TopBlock = top.Number
+
+	log.Info().Int64("top", top.Number).Msg("Verifying ledger")
+
+	seenRecords := make(map[string]int64)
+	prevHash := ""
+	var number int64
+	for number <= top.Number {
+		var chain []*model.Block
+		if number == 0 {
+			chain = []*model.Block{genesis}
+		} else {
+			chain, err = l.GetChain(ctx, number, verificationBatchSize)
+			if err != nil {
+				return nil, err
+			}
+			if len(chain) == 0 {
+				report.brokenBlock(number)
+				report.addIssue(&VerificationIssue{
+					Type:    IssueMissingBlock,
+					Block:   number,
+					Message: "block not found",
+				})
+				break
+			}
+		}
+
+		for _, b := range chain {
+			if b.Number > top.Number {
+				break
+			}
+
+			verifyBlock(report, b, number, prevHash)
+
+			if err = verifyBlockRecords(ctx, l, report, b, seenRecords); err != nil {
+				return nil, err
+			}
+
+			report.BlocksChecked++
+			prevHash = b.Hash
+			number++
+		}
+	}
+
+	log.Info().Bool("valid", report.Valid).Int64("blocks", report.BlocksChecked).
+		Int64("records", report.RecordsChecked).Msg("Ledger verification complete")
+
+	return report, nil
+}
+
+func verifyBlock(report *VerificationReport, b *model.Block, expectedNumber int64, prevHash string) {
+	if b.Number != expectedNumber {
+		report.brokenBlock(expectedNumber)
+		report.addIssue(&VerificationIssue{
+			Type:     IssueBlockNumberMismatch,
+			Block:    expectedNumber,
+			Expected: strconv.FormatInt(expectedNumber, 10),
+			Actual:   strconv.FormatInt(b.Number, 10),
+		})
+	}
+
+	if b.ParentHash != prevHash {
+		report.brokenBlock(b.Number)
+		report.addHashMismatch(&VerificationIssue{
+			Type:     IssueParentHashMismatch,
+			Block:    b.Number,
+			Expected: prevHash,
+			Actual:   b.ParentHash,
+		})
+	}
+
+	if b.Nonce == "" {
+		report.UnverifiableBlocks = append(report.UnverifiableBlocks, b.Number)
+		return
+	}
+
+	if expectedHash := model.BlockHash(b.Number, b.ParentHash, b.Nonce, b.MerkleRoot); expectedHash != b.Hash {
+		report.brokenBlock(b.Number)
+		report.addHashMismatch(&VerificationIssue{
+			Type:     IssueBlockHashMismatch,
+			Block:    b.Number,
+			Expected: expectedHash,
+			Actual:   b.Hash,
+		})
+	}
+}
+
+func verifyBlockRecords(ctx context.Context, l model.Ledger, report *VerificationReport, b *model.Block, seenRecords map[string]int64) error {
+	number := b.Number
+	records, err := l.GetBlockRecords(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	// blocks produced by older ledgers have no Merkle root. If the root was
+	// removed from a newer block, its hash check fails.
+	if b.MerkleRoot != "" {
+		ids := make([]string, len(records))
+		for i, entry := range records {
+			if len(entry) > 0 {
+				ids[i] = entry[0]
+			}
+		}
+		if expectedRoot := model.RecordMerkleRoot(ids); expectedRoot != b.MerkleRoot {
+			report.brokenBlock(number)
+			report.addHashMismatch(&VerificationIssue{
+				Type:     IssueMerkleRootMismatch,
+				Block:    number,
+				Expected: expectedRoot,
+				Actual:   b.MerkleRoot,
+			})
+		}
+	}
+
+	for _, entry := range records {
+		if len(entry) == 0 {
+			report.addIssue(&VerificationIssue{
+				Type:    IssueBadBlockComposition,
+				Block:   number,
+				Message: "empty block composition entry",
+			})
+			continue
+		}
+
+		rid := entry[0]
+		report.RecordsChecked++
+
+		if prevNumber, seen := seenRecords[rid]; seen {
+			report.addIssue(&VerificationIssue{
+				Type:     IssueRecordDuplicate,
+				Block:    number,
+				RecordID: rid,
+				Message:  "record already included into block " + strconv.FormatInt(prevNumber, 10),
+			})
+			continue
+		}
+		seenRecords[rid] = number
+
+		rec, err := l.GetRecord(ctx, rid)
+		if err != nil {
+			if errors.Is(err, model.ErrRecordNotFound) {
+				report.addIssue(&VerificationIssue{
+					Type:     IssueRecordNotFound,
+					Block:    number,
+					RecordID: rid,
+				})
+				continue
+			}
+			return err
+		}
+
+		if len(entry) == 3 && (entry[1] != rec.RoutingKey || entry[2] != strconv.FormatUint(uint64(rec.KeyIndex), 10)) {
+			report.addIssue(&VerificationIssue{
+				Type:     IssueBadBlockComposition,
+				Block:    number,
+				RecordID: rid,
+				Expected: rec.RoutingKey + "," + strconv.FormatUint(uint64(rec.KeyIndex), 10),
+				Actual:   entry[1] + "," + entry[2],
+			})
+		}
+
+		if derivedID := rec.DeriveID(); derivedID != rec.ID || rid != rec.ID {
+			report.addHashMismatch(&VerificationIssue{
+				Type:     IssueRecordIDMismatch,
+				Block:    number,
+				RecordID: rid,
+				Expected: derivedID,
+				Actual:   rec.ID,
+			})
+		}
+
+		state, err := l.GetRecordState(ctx, rid)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case state == nil:
+			report.addOrphan(&VerificationIssue{
+				Type:     IssueOrphanedRecord,
+				Block:    number,
+				RecordID: rid,
+				Message:  "record state not found",
+			})
+		case state.Status == model.StatusPending:
+			report.addIssue(&VerificationIssue{
+				Type:     IssueRecordStateMismatch,
+				Block:    number,
+				RecordID: rid,
+				Expected: "not " + string(model.StatusPending),
+				Actual:   string(state.Status),
+			})
+		case state.BlockNumber != number:
+			if state.Status == model.StatusFailed && state.BlockNumber == 0 {
+				// failed revocations don't get a block number in the local ledger
+				continue
+			}
+			report.addOrphan(&VerificationIssue{
+				Type:     IssueOrphanedRecord,
+				Block:    number,
+				RecordID: rid,
+				Expected: strconv.FormatInt(number, 10),
+				Actual:   strconv.FormatInt(state.BlockNumber, 10),
+				Message:  "record state points to another block",
+			})
+		}
+	}
+
+	return nil
+}
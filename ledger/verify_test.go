@@ -0,0 +1,262 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ledger_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	. "github.com/piprate/metalocker/ledger"
+	"github.com/piprate/metalocker/ledger/local"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	testbase.SetupLogFormat()
+}
+
+// tamperedLedger allows modifying ledger data on the fly.
+type tamperedLedger struct {
+	model.Ledger
+	blockFn  func(b *model.Block)
+	recordFn func(r *model.Record)
+	stateFn  func(rid string, rs *model.RecordState)
+}
+
+func (tl *tamperedLedger) tamperBlock(b *model.Block) *model.Block {
+	if b != nil && tl.blockFn != nil {
+		cp := *b
+		tl.blockFn(&cp)
+		return &cp
+	}
+	return b
+}
+
+func (tl *tamperedLedger) GetGenesisBlock(ctx context.Context) (*model.Block, error) {
+	b, err := tl.Ledger.GetGenesisBlock(ctx)
+	return tl.tamperBlock(b), err
+}
+
+func (tl *tamperedLedger) GetChain(ctx context.Context, startNumber int64, depth int) ([]*model.Block, error) {
+	chain, err := tl.Ledger.GetChain(ctx, startNumber, depth)
+	for i, b := range chain {
+		chain[i] = tl.tamperBlock(b)
+	}
+	return chain, err
+}
+
+func (tl *tamperedLedger) GetRecord(ctx context.Context, rid string) (*model.Record, error) {
+	r, err := tl.Ledger.GetRecord(ctx, rid)
+	if r != nil && tl.recordFn != nil {
+		tl.recordFn(r)
+	}
+	return r, err
+}
+
+func (tl *tamperedLedger) GetRecordState(ctx context.Context, rid string) (*model.RecordState, error) {
+	rs, err := tl.Ledger.GetRecordState(ctx, rid)
+	if rs != nil && tl.stateFn != nil {
+		tl.stateFn(rid, rs)
+	}
+	return rs, err
+}
+
+func newTestLedger(t *testing.T) (*local.BoltLedger, []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	bl, err := local.NewBoltLedger(context.Background(), filepath.Join(dir, "ledger.bolt"), nil, 1000, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = bl.Close() })
+
+	pk, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	ids := make([]string, 0)
+	for _, addr := range []string{"op1", "op2", "op3"} {
+		ac := sha256.Sum256([]byte(addr))
+		rec := &model.Record{
+			RoutingKey:            "rk",
+			KeyIndex:              1,
+			Operation:             model.OpTypeLease,
+			OperationAddress:      addr,
+			AuthorisingCommitment: base64.StdEncoding.EncodeToString(ac[:]),
+		}
+		require.NoError(t, rec.Seal(pk))
+		require.NoError(t, bl.SubmitRecord(ctx, rec))
+
+		require.Eventually(t, func() bool {
+			rs, err := bl.GetRecordState(ctx, rec.ID)
+			return err == nil && rs != nil && rs.Status == model.StatusPublished
+		}, 5*time.Second, 10*time.Millisecond)
+
+		ids = append(ids, rec.ID)
+	}
+
+	return bl, ids
+}
+
+func TestVerifyLedger(t *testing.T) {
+	bl, _ := newTestLedger(t)
+
+	report, err := VerifyLedger(context.Background(), bl)
+	require.NoError(t, err)
+
+	assert.True(t, report.Valid)
+	assert.Equal(t, int64(3), report.TopBlock)
+	assert.Equal(t, int64(4), report.BlocksChecked)
+	assert.Equal(t, int64(3), report.RecordsChecked)
+	assert.Nil(t, report.FirstBrokenBlock)
+	assert.Empty(t, report.HashMismatches)
+	assert.Empty(t, report.OrphanedRecords)
+	assert.Empty(t, report.Issues)
+	assert.Empty(t, report.UnverifiableBlocks)
+}
+
+func TestVerifyLedger_TamperedBlock(t *testing.T) {
+	bl, _ := newTestLedger(t)
+
+	tl := &tamperedLedger{
+		Ledger: bl,
+		blockFn: func(b *model.Block) {
+			if b.Number == 2 {
+				b.Hash = base64.StdEncoding.EncodeToString([]byte("fake hash"))
+			}
+		},
+	}
+
+	report, err := VerifyLedger(context.Background(), tl)
+	require.NoError(t, err)
+
+	assert.False(t, report.Valid)
+	require.NotNil(t, report.FirstBrokenBlock)
+	assert.Equal(t, int64(2), *report.FirstBrokenBlock)
+	require.Len(t, report.HashMismatches, 2)
+	assert.Equal(t, IssueBlockHashMismatch, report.HashMismatches[0].Type)
+	assert.Equal(t, int64(2), report.HashMismatches[0].Block)
+	assert.Equal(t, IssueParentHashMismatch, report.HashMismatches[1].Type)
+	assert.Equal(t, int64(3), report.HashMismatches[1].Block)
+}
+
+func TestVerifyLedger_UnverifiableBlocks(t *testing.T) {
+	bl, _ := newTestLedger(t)
+
+	tl := &tamperedLedger{
+		Ledger: bl,
+		blockFn: func(b *model.Block) {
+			b.Nonce = ""
+		},
+	}
+
+	report, err := VerifyLedger(context.Background(), tl)
+	require.NoError(t, err)
+
+	assert.True(t, report.Valid)
+	assert.Equal(t, []int64{0, 1, 2, 3}, report.UnverifiableBlocks)
+}
+
+func TestVerifyLedger_TamperedRecord(t *testing.T) {
+	bl, ids := newTestLedger(t)
+
+	tl := &tamperedLedger{
+		Ledger: bl,
+		recordFn: func(r *model.Record) {
+			if r.ID == ids[0] {
+				r.OperationAddress = "another_op"
+			}
+		},
+		stateFn: func(rid string, rs *model.RecordState) {
+			if rid == ids[1] {
+				rs.BlockNumber = 3
+			}
+		},
+	}
+
+	report, err := VerifyLedger(context.Background(), tl)
+	require.NoError(t, err)
+
+	assert.False(t, report.Valid)
+	assert.Nil(t, report.FirstBrokenBlock)
+
+	require.Len(t, report.HashMismatches, 1)
+	assert.Equal(t, IssueRecordIDMismatch, report.HashMismatches[0].Type)
+	assert.Equal(t, ids[0], report.HashMismatches[0].RecordID)
+	assert.Equal(t, int64(1), report.HashMismatches[0].Block)
+
+	require.Len(t, report.OrphanedRecords, 1)
+	assert.Equal(t, IssueOrphanedRecord, report.OrphanedRecords[0].Type)
+	assert.Equal(t, ids[1], report.OrphanedRecords[0].RecordID)
+	assert.Equal(t, "2", report.OrphanedRecords[0].Expected)
+	assert.Equal(t, "3", report.OrphanedRecords[0].Actual)
+}
+
+func TestVerifyLedger_TamperedMerkleRoot(t *testing.T) {
+	bl, _ := newTestLedger(t)
+
+	tl := &tamperedLedger{
+		Ledger: bl,
+		blockFn: func(b *model.Block) {
+			if b.Number == 1 {
+				// re-seal the block to make its hash consistent with the wrong root
+				b.MerkleRoot = model.RecordMerkleRoot([]string{"another_record"})
+				b.Hash = model.BlockHash(b.Number, b.ParentHash, b.Nonce, b.MerkleRoot)
+			}
+		},
+	}
+
+	report, err := VerifyLedger(context.Background(), tl)
+	require.NoError(t, err)
+
+	assert.False(t, report.Valid)
+	require.NotNil(t, report.FirstBrokenBlock)
+	assert.Equal(t, int64(1), *report.FirstBrokenBlock)
+	require.Len(t, report.HashMismatches, 2)
+	assert.Equal(t, IssueMerkleRootMismatch, report.HashMismatches[0].Type)
+	assert.Equal(t, int64(1), report.HashMismatches[0].Block)
+}
+
+func TestBuildRecordInclusionProof(t *testing.T) {
+	bl, ids := newTestLedger(t)
+
+	ctx := context.Background()
+
+	proof, err := bl.GetRecordInclusionProof(ctx, ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), proof.BlockNumber)
+
+	header, err := bl.GetBlock(ctx, 2)
+	require.NoError(t, err)
+	require.NotEmpty(t, header.MerkleRoot)
+
+	require.NoError(t, proof.Verify(header))
+
+	otherHeader, err := bl.GetBlock(ctx, 3)
+	require.NoError(t, err)
+	assert.ErrorIs(t, proof.Verify(otherHeader), model.ErrInvalidInclusionProof)
+
+	_, err = bl.GetRecordInclusionProof(ctx, "unknown")
+	assert.ErrorIs(t, err, model.ErrRecordNotFound)
+}
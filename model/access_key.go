@@ -22,6 +22,7 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -40,13 +41,21 @@ const (
 	AccessKeyHeaderDate      = "X-Meta-Date"
 	AccessKeyHeaderClientKey = "X-Meta-Client-Key"
 	AccessKeyHeaderBodyHash  = "X-Meta-Body-Hash"
+
+	AccessKeyPermissionReadOnly  AccessKeyPermission = "ro"
+	AccessKeyPermissionReadWrite AccessKeyPermission = "rw"
 )
 
 var (
 	ErrMissingDateInHeader      = errors.New("missing " + AccessKeyHeaderDate + " in request header")
 	ErrMissingClientKeyInHeader = errors.New("missing " + AccessKeyHeaderClientKey + " in request header")
+	ErrAccessKeyExpired         = errors.New("access key expired")
+	ErrAccessKeyReadOnly        = errors.New("operation not permitted with read-only access key")
 )
 
+// AccessKeyPermission defines if the access key can be used to modify MetaLocker data.
+type AccessKeyPermission string
+
 // AccessKey defines a key that can be used to access MetaLocker.
 // Access keys are useful for programmatic or temporary access to MetaLocker data
 // without revealing its main encryption keys.
@@ -63,7 +72,23 @@ type AccessKey struct {
 
 	// See https://www.algolia.com/doc/api-reference/api-methods/generate-secured-api-key/
 	// for an example of key options
-	//ExpiresAt int    `json:"expires,omitempty"`
+
+	// ExpiresAt is the time when the key expires. If empty, the key never expires.
+	ExpiresAt *time.Time `json:"expires,omitempty"`
+	// Permission defines if the key grants read-only or read-write access. Empty value
+	// means read-write access.
+	Permission AccessKeyPermission `json:"permission,omitempty"`
+	// Lockers, if not empty, restricts the key to the lockers with the given IDs.
+	Lockers []string `json:"lockers,omitempty"`
+	// Identities, if not empty, restricts the key to the identities with the given IDs
+	// and the lockers where these identities participate.
+	// Locker and identity scopes are enforced by the data wallet, because
+	// MetaLocker nodes only see hashed locker and identity IDs. Nodes only
+	// enforce them for hosted accounts, where the node unlocks the data wallet
+	// on the caller's behalf (see the hosted dataset API). A scoped key still
+	// unwraps the account's managed key, so scopes don't restrict clients that
+	// bypass the data wallet.
+	Identities []string `json:"identities,omitempty"`
 
 	ManagementKeyPub ed25519.PublicKey  `json:"-"`
 	ManagementKeyPrv ed25519.PrivateKey `json:"-"`
@@ -71,6 +96,105 @@ type AccessKey struct {
 	ClientHMACKey    []byte             `json:"-"`
 }
 
+// AccessKeyOption is for defining optional parameters of access keys.
+type AccessKeyOption func(ak *AccessKey) error
+
+// WithExpiry sets the key's expiry time.
+func WithExpiry(expiresAt time.Time) AccessKeyOption {
+	return func(ak *AccessKey) error {
+		expiresAt = expiresAt.UTC()
+		ak.ExpiresAt = &expiresAt
+		return nil
+	}
+}
+
+// WithPermission sets the key's permission (read-only or read-write).
+func WithPermission(permission AccessKeyPermission) AccessKeyOption {
+	return func(ak *AccessKey) error {
+		switch permission {
+		case AccessKeyPermissionReadOnly, AccessKeyPermissionReadWrite:
+			ak.Permission = permission
+			return nil
+		default:
+			return fmt.Errorf("unsupported access key permission: %s", permission)
+		}
+	}
+}
+
+// WithLockerScope restricts the key to the given lockers.
+func WithLockerScope(lockerIDs ...string) AccessKeyOption {
+	return func(ak *AccessKey) error {
+		ak.Lockers = append(ak.Lockers, lockerIDs...)
+		return nil
+	}
+}
+
+// WithIdentityScope restricts the key to the given identities.
+func WithIdentityScope(identityIDs ...string) AccessKeyOption {
+	return func(ak *AccessKey) error {
+		ak.Identities = append(ak.Identities, identityIDs...)
+		return nil
+	}
+}
+
+// IsExpired returns true if the key has expired at the given time.
+func (ak *AccessKey) IsExpired(now time.Time) bool {
+	return ak.ExpiresAt != nil && !now.Before(*ak.ExpiresAt)
+}
+
+// IsReadOnly returns true if the key grants read-only access.
+func (ak *AccessKey) IsReadOnly() bool {
+	return ak.Permission == AccessKeyPermissionReadOnly
+}
+
+// IsRestricted returns true if the key has an expiry time, read-only permission or scopes.
+func (ak *AccessKey) IsRestricted() bool {
+	return ak.ExpiresAt != nil || ak.IsReadOnly() || len(ak.Lockers) > 0 || len(ak.Identities) > 0
+}
+
+// AllowsRequest returns true if an HTTP request with the given method and path
+// is permitted by the key's permission. Read-only keys can only send requests that
+// don't modify any data: GET, HEAD, OPTIONS and blob retrieval (POST .../serve).
+func (ak *AccessKey) AllowsRequest(method, path string) bool {
+	if !ak.IsReadOnly() {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return strings.HasSuffix(path, "/serve")
+	default:
+		return false
+	}
+}
+
+// AllowsIdentity returns true if the identity is within the key's scope.
+func (ak *AccessKey) AllowsIdentity(iid string) bool {
+	return len(ak.Identities) == 0 || contains(ak.Identities, iid)
+}
+
+// AllowsLocker returns true if the locker is within the key's scope.
+func (ak *AccessKey) AllowsLocker(locker *Locker) bool {
+	if len(ak.Lockers) > 0 && !contains(ak.Lockers, locker.ID) {
+		return false
+	}
+	if len(ak.Identities) > 0 {
+		us := locker.Us()
+		return us != nil && contains(ak.Identities, us.ID)
+	}
+	return true
+}
+
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
 func (ak *AccessKey) AddManagedKey(key *AESKey) {
 	cypherText := AnonEncrypt(key.Bytes(), ak.ManagementKeyPub)
 	ak.EncryptedManagedKey = base64.StdEncoding.EncodeToString(cypherText)
@@ -128,7 +252,7 @@ func DeriveClientAESKey(pk ed25519.PrivateKey) *AESKey {
 //
 // Client will use: keyID, management key (64-byte private Ed-25519 key), HMAC key (64 bytes)
 // Server will use: keyID, encrypted HMAC key
-func GenerateAccessKey(accountID string, accessLevel AccessLevel) (*AccessKey, error) {
+func GenerateAccessKey(accountID string, accessLevel AccessLevel, opts ...AccessKeyOption) (*AccessKey, error) {
 	publicKey, privateKey, err := ed25519.GenerateKey(nil)
 	if err != nil {
 		return nil, err
@@ -148,7 +272,7 @@ func GenerateAccessKey(accountID string, accessLevel AccessLevel) (*AccessKey, e
 
 	id := GenerateAccessKeyID()
 
-	return &AccessKey{
+	ak := &AccessKey{
 		ID:          id,
 		AccountID:   accountID,
 		Secret:      base64.StdEncoding.EncodeToString(encHmacKey),
@@ -161,7 +285,15 @@ func GenerateAccessKey(accountID string, accessLevel AccessLevel) (*AccessKey, e
 		ManagementKeyPrv: privateKey,
 		ClientSecret:     aesKey,
 		ClientHMACKey:    hmacKey,
-	}, nil
+	}
+
+	for _, fn := range opts {
+		if err := fn(ak); err != nil {
+			return nil, err
+		}
+	}
+
+	return ak, nil
 }
 
 func SplitClientSecret(secret string) (ed25519.PrivateKey, *AESKey, []byte, error) {
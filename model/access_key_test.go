@@ -41,6 +41,79 @@ func TestGenerateAccessKey(t *testing.T) {
 	assert.Equal(t, AccessLevelHosted, accessKey.AccessLevel)
 }
 
+func TestGenerateAccessKey_WithOptions(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	accessKey, err := GenerateAccessKey("did:piprate:xxx", AccessLevelHosted,
+		WithExpiry(expiresAt),
+		WithPermission(AccessKeyPermissionReadOnly),
+		WithLockerScope("locker1", "locker2"),
+		WithIdentityScope("did:piprate:abc"))
+	require.NoError(t, err)
+
+	require.NotNil(t, accessKey.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*accessKey.ExpiresAt))
+	assert.True(t, accessKey.IsReadOnly())
+	assert.True(t, accessKey.IsRestricted())
+	assert.Equal(t, []string{"locker1", "locker2"}, accessKey.Lockers)
+	assert.Equal(t, []string{"did:piprate:abc"}, accessKey.Identities)
+
+	assert.False(t, accessKey.IsExpired(time.Now()))
+	assert.True(t, accessKey.IsExpired(expiresAt))
+
+	_, err = GenerateAccessKey("did:piprate:xxx", AccessLevelHosted, WithPermission("bad"))
+	assert.Error(t, err)
+
+	accessKey, err = GenerateAccessKey("did:piprate:xxx", AccessLevelHosted)
+	require.NoError(t, err)
+	assert.False(t, accessKey.IsRestricted())
+	assert.False(t, accessKey.IsExpired(time.Now()))
+}
+
+func TestAccessKey_AllowsRequest(t *testing.T) {
+	rw := &AccessKey{}
+	ro := &AccessKey{Permission: AccessKeyPermissionReadOnly}
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		ro     bool
+	}{
+		{http.MethodGet, "/v1/account", true},
+		{http.MethodHead, "/v1/account", true},
+		{http.MethodPost, "/v1/vault/abc/serve", true},
+		{http.MethodPost, "/v1/ledger/record", false},
+		{http.MethodPut, "/v1/account", false},
+		{http.MethodPatch, "/v1/account", false},
+		{http.MethodDelete, "/v1/account/abc/access-key/123", false},
+	} {
+		assert.True(t, rw.AllowsRequest(tc.method, tc.path))
+		assert.Equal(t, tc.ro, ro.AllowsRequest(tc.method, tc.path), "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestAccessKey_AllowsLocker(t *testing.T) {
+	locker := &Locker{
+		ID: "locker1",
+		Participants: []*LockerParticipant{
+			{ID: "did:piprate:us", Self: true},
+			{ID: "did:piprate:them"},
+		},
+	}
+
+	assert.True(t, (&AccessKey{}).AllowsLocker(locker))
+	assert.True(t, (&AccessKey{Lockers: []string{"locker1"}}).AllowsLocker(locker))
+	assert.False(t, (&AccessKey{Lockers: []string{"locker2"}}).AllowsLocker(locker))
+	assert.True(t, (&AccessKey{Identities: []string{"did:piprate:us"}}).AllowsLocker(locker))
+	assert.False(t, (&AccessKey{Identities: []string{"did:piprate:them"}}).AllowsLocker(locker))
+	assert.False(t, (&AccessKey{
+		Lockers:    []string{"locker2"},
+		Identities: []string{"did:piprate:us"},
+	}).AllowsLocker(locker))
+
+	assert.True(t, (&AccessKey{Identities: []string{"did:piprate:us"}}).AllowsIdentity("did:piprate:us"))
+	assert.False(t, (&AccessKey{Identities: []string{"did:piprate:us"}}).AllowsIdentity("did:piprate:them"))
+}
+
 func TestSplitClientSecret(t *testing.T) {
 	_, _, _, err := SplitClientSecret("bad secret")
 	assert.Error(t, err)
@@ -80,13 +80,12 @@ func VerifyAccessToken(ctx context.Context, at, dataAssetID string, now, maxDist
 		return false
 	}
 
-	// verify that the lease hasn't expired yet
+	// check if the lease expiry time from the token has passed. The lease may
+	// have been renewed, so we'll check its effective expiry time on the ledger
+	// once the token is verified.
 
 	leaseExpiryTime := utils.StringToInt64(s[1])
-	if leaseExpiryTime != 0 && leaseExpiryTime < now {
-		log.Error().Str("rid", recordID).Msg("Lease expired")
-		return false
-	}
+	leaseExpired := leaseExpiryTime != 0 && leaseExpiryTime < now
 
 	// check the token signature
 
@@ -121,10 +120,27 @@ func VerifyAccessToken(ctx context.Context, at, dataAssetID string, now, maxDist
 		return false
 	}
 
-	if rec.Status == StatusRevoked {
+	if rec.Status == StatusRevoked || rec.Status == StatusExpired {
+		return false
+	}
+
+	if rec.ExpiresAt != 0 && rec.ExpiresAt != leaseExpiryTime {
+		log.Error().Str("rid", recordID).Msg("Lease expiry time mismatch")
 		return false
 	}
 
+	if leaseExpired {
+		rs, err := ledger.GetRecordState(ctx, recordID)
+		if err != nil {
+			log.Err(err).Str("rid", recordID).Msg("Error when reading record state from ledger")
+			return false
+		}
+		if rs == nil || rs.ExpiresAt < now {
+			log.Error().Str("rid", recordID).Msg("Lease expired")
+			return false
+		}
+	}
+
 	expectedRC, _ := base64.StdEncoding.DecodeString(rec.RequestingCommitment)
 
 	rcInput := pub
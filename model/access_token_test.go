@@ -32,6 +32,7 @@ type MockLedger struct {
 	dataAssetState           DataAssetState
 	errorAtGetDataAssetState error
 	status                   RecordStatus
+	expiresAt                int64
 	errorAtGetRecord         error
 }
 
@@ -53,7 +54,8 @@ func (ml *MockLedger) GetDataAssetState(ctx context.Context, id string) (DataAss
 
 func (ml *MockLedger) GetRecordState(ctx context.Context, rid string) (*RecordState, error) {
 	return &RecordState{
-		Status: ml.status,
+		Status:    ml.status,
+		ExpiresAt: ml.expiresAt,
 	}, nil
 }
 
@@ -178,6 +180,20 @@ func TestVerifyAccessToken(t *testing.T) {
 	res = VerifyAccessToken(ctx, at, dataAssetID, now, 30, verifier)
 	assert.False(t, res)
 
+	// verify token (lease renewed)
+
+	verifier.expiresAt = 1030
+	tokenTime = time.Unix(1020, 0).UTC().Unix()
+	renewedAt := GenerateAccessToken(recordID, leaseID, tokenTime, leaseExpiryTime.Unix())
+
+	res = VerifyAccessToken(ctx, renewedAt, dataAssetID, now, 30, verifier)
+	assert.True(t, res)
+
+	res = VerifyAccessToken(ctx, renewedAt, dataAssetID, 1031, 30, verifier)
+	assert.False(t, res)
+
+	verifier.expiresAt = 0
+
 	// verify token (status == revoked)
 
 	now = time.Unix(1020, 0).UTC().Unix()
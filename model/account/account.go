@@ -107,6 +107,15 @@ type Account struct {
 	HostedSecretStore  *SecretStore `json:"hostedSecretStore,omitempty"`
 
 	DerivationIndex uint32 `json:"derivationIndex,omitempty"`
+
+	ExternalIdentities []*ExternalIdentity `json:"externalIdentities,omitempty"`
+}
+
+// ExternalIdentity links the account to a subject in an external identity provider,
+// such as an OpenID Connect issuer.
+type ExternalIdentity struct {
+	Issuer  string `json:"iss"`
+	Subject string `json:"sub"`
 }
 
 type SecretStore struct {
@@ -250,9 +259,27 @@ func (a *Account) Copy() *Account {
 	if cp.ManagedSecretStore != nil {
 		cp.ManagedSecretStore = cp.ManagedSecretStore.Copy()
 	}
+	if cp.ExternalIdentities != nil {
+		cp.ExternalIdentities = make([]*ExternalIdentity, len(a.ExternalIdentities))
+		for i, ei := range a.ExternalIdentities {
+			eiCopy := *ei
+			cp.ExternalIdentities[i] = &eiCopy
+		}
+	}
 	return &cp
 }
 
+// FindExternalIdentity returns the account's link to the given external identity provider,
+// or nil if the account isn't linked to it.
+func (a *Account) FindExternalIdentity(issuer string) *ExternalIdentity {
+	for _, ei := range a.ExternalIdentities {
+		if ei.Issuer == issuer {
+			return ei
+		}
+	}
+	return nil
+}
+
 func (a *Account) Bytes() []byte {
 	b, _ := jsonw.Marshal(a)
 	return b
@@ -363,6 +390,8 @@ func IsCorrectIdentityType(val string) bool {
 
 type Identity struct {
 	// DID is the identity's full DID definition, including its keys.
+	// Besides the default method, it may hold did:key and did:web
+	// identifiers (see model.GenerateDID).
 	DID *model.DID `json:"did"`
 	// Created is the time when the identity was created.
 	Created *time.Time `json:"created"`
@@ -0,0 +1,330 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	// SecondFactorTOTP is a time-based one-time password factor as defined in RFC 6238.
+	SecondFactorTOTP = "totp"
+	// SecondFactorBackupCodes is a set of single-use codes that can replace
+	// any other second factor, if it gets lost.
+	SecondFactorBackupCodes = "backup"
+
+	// BackupCodeCount is the default number of backup codes issued to an account.
+	BackupCodeCount = 10
+
+	totpPeriod     = 30
+	totpDigits     = 6
+	totpModulus    = 1000000
+	totpSkew       = 1
+	totpSecretSize = 20
+	backupCodeSize = 5
+)
+
+var (
+	ErrSecondFactorRequired = errors.New("second factor required")
+	ErrInvalidSecondFactor  = errors.New("invalid second factor code")
+
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// SecondFactor is an additional authentication factor enrolled for an account.
+// Once a TOTP factor is confirmed, all logins and account recovery attempts
+// require a valid one-time code (or an unused backup code).
+type SecondFactor struct {
+	ID        string     `json:"id"`
+	AccountID string     `json:"account"`
+	Type      string     `json:"type"`
+	Name      string     `json:"name,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	// Confirmed is set when the owner proved possession of the factor
+	// by submitting a valid code. Unconfirmed factors aren't enforced.
+	Confirmed bool `json:"confirmed"`
+	// Secret is a base32 encoded TOTP secret.
+	Secret string `json:"secret,omitempty"`
+	// EncryptedSecret is the TOTP secret encrypted with a server-side key.
+	// Storage backends keep the secret in this form at rest.
+	EncryptedSecret string `json:"encryptedSecret,omitempty"`
+	// LastUsedStep is the last accepted TOTP time step. Codes from this
+	// or earlier steps are rejected to prevent replays.
+	LastUsedStep int64 `json:"lastUsedStep,omitempty"`
+	// BackupCodes contains hex encoded SHA-256 hashes of unused backup codes.
+	BackupCodes []string `json:"backupCodes,omitempty"`
+	// Revision is incremented by the storage backend on every update.
+	// It allows the factor state to be consumed atomically.
+	Revision int64 `json:"revision,omitempty"`
+}
+
+func (f *SecondFactor) Bytes() []byte {
+	b, _ := jsonw.Marshal(f)
+	return b
+}
+
+// Redacted returns a copy of the factor without any secret material.
+func (f *SecondFactor) Redacted() *SecondFactor {
+	return &SecondFactor{
+		ID:           f.ID,
+		AccountID:    f.AccountID,
+		Type:         f.Type,
+		Name:         f.Name,
+		CreatedAt:    f.CreatedAt,
+		Confirmed:    f.Confirmed,
+		LastUsedStep: f.LastUsedStep,
+	}
+}
+
+// EncryptSecret returns a copy of the factor with the TOTP secret encrypted
+// with the given key.
+func (f *SecondFactor) EncryptSecret(key *model.AESKey) (*SecondFactor, error) {
+	cpy := *f
+	if f.Secret == "" {
+		return &cpy, nil
+	}
+	encBytes, err := model.EncryptAESCGM([]byte(f.Secret), key)
+	if err != nil {
+		return nil, err
+	}
+	cpy.Secret = ""
+	cpy.EncryptedSecret = base64.StdEncoding.EncodeToString(encBytes)
+	return &cpy, nil
+}
+
+// DecryptSecret returns a copy of the factor with the TOTP secret decrypted
+// with the given key. Factors with a cleartext secret are returned as is.
+func (f *SecondFactor) DecryptSecret(key *model.AESKey) (*SecondFactor, error) {
+	cpy := *f
+	if f.EncryptedSecret == "" {
+		return &cpy, nil
+	}
+	encBytes, err := base64.StdEncoding.DecodeString(f.EncryptedSecret)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := model.DecryptAESCGM(encBytes, key)
+	if err != nil {
+		return nil, err
+	}
+	cpy.Secret = string(secret)
+	cpy.EncryptedSecret = ""
+	return &cpy, nil
+}
+
+// KeyURI returns an otpauth:// URI that can be imported into authenticator apps.
+func (f *SecondFactor) KeyURI(issuer, accountName string) string {
+	label := accountName
+	if issuer != "" {
+		label = issuer + ":" + accountName
+	}
+	params := url.Values{}
+	params.Set("secret", f.Secret)
+	if issuer != "" {
+		params.Set("issuer", issuer)
+	}
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+
+	return "otpauth://totp/" + url.PathEscape(label) + "?" + params.Encode()
+}
+
+// Verify checks the given code against the factor. If the code is accepted,
+// the factor's state is updated (the TOTP step is recorded or the backup code
+// is consumed) and the caller is expected to persist the factor.
+func (f *SecondFactor) Verify(code string, now time.Time) bool {
+	code = normaliseCode(code)
+	if code == "" {
+		return false
+	}
+
+	switch f.Type {
+	case SecondFactorTOTP:
+		step, ok := ValidateTOTP(f.Secret, code, now, f.LastUsedStep)
+		if !ok {
+			return false
+		}
+		f.LastUsedStep = step
+		return true
+	case SecondFactorBackupCodes:
+		hash := hashBackupCode(code)
+		for i, bc := range f.BackupCodes {
+			if subtle.ConstantTimeCompare([]byte(bc), []byte(hash)) == 1 {
+				f.BackupCodes = append(f.BackupCodes[:i:i], f.BackupCodes[i+1:]...)
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// NewTOTPFactor creates a new unconfirmed TOTP factor with a random secret.
+func NewTOTPFactor(accountID, name string) (*SecondFactor, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return newSecondFactor(accountID, SecondFactorTOTP, name, func(f *SecondFactor) {
+		f.Secret = totpEncoding.EncodeToString(secret)
+	})
+}
+
+// NewBackupCodes creates a set of backup codes for the given account. The codes
+// are returned in cleartext only once, the factor keeps their hashes.
+func NewBackupCodes(accountID string, count int) (*SecondFactor, []string, error) {
+	codes := make([]string, count)
+	hashes := make([]string, count)
+	for i := range codes {
+		buf := make([]byte, backupCodeSize)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		codes[i] = strings.ToLower(totpEncoding.EncodeToString(buf))
+		hashes[i] = hashBackupCode(codes[i])
+	}
+
+	f, err := newSecondFactor(accountID, SecondFactorBackupCodes, "", func(f *SecondFactor) {
+		// backup codes are only used alongside other factors, so they don't need confirmation
+		f.Confirmed = true
+		f.BackupCodes = hashes
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, codes, nil
+}
+
+func newSecondFactor(accountID, factorType, name string, initFn func(f *SecondFactor)) (*SecondFactor, error) {
+	id, err := utils.RandomID(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	f := &SecondFactor{
+		ID:        id,
+		AccountID: accountID,
+		Type:      factorType,
+		Name:      name,
+		CreatedAt: &now,
+	}
+	initFn(f)
+
+	return f, nil
+}
+
+// IsMFAEnabled returns true if the list contains at least one confirmed factor
+// that can be used on its own. Backup codes don't enable MFA.
+func IsMFAEnabled(factors []*SecondFactor) bool {
+	for _, f := range factors {
+		if f.Confirmed && f.Type != SecondFactorBackupCodes {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifySecondFactor checks the code against all confirmed factors and returns
+// the factor that accepted it. The returned factor needs to be persisted.
+func VerifySecondFactor(factors []*SecondFactor, code string, now time.Time) (*SecondFactor, error) {
+	if code == "" {
+		return nil, ErrSecondFactorRequired
+	}
+	for _, f := range factors {
+		if f.Confirmed && f.Verify(code, now) {
+			return f, nil
+		}
+	}
+	return nil, ErrInvalidSecondFactor
+}
+
+// GenerateTOTP returns the TOTP code for the given secret and time.
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpCode(key, t.Unix()/totpPeriod), nil
+}
+
+// ValidateTOTP checks the code against the secret, allowing for a small clock
+// skew. Codes from steps up to and including lastUsedStep are rejected.
+// It returns the matching time step.
+func ValidateTOTP(secret, code string, t time.Time, lastUsedStep int64) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := t.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step)) //nolint:gosec
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulus)
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	return totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+func hashBackupCode(code string) string {
+	hash := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(hash[:])
+}
+
+func normaliseCode(code string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(code), " ", ""), "-", "")
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/piprate/metalocker/model/account"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateTOTP(t *testing.T) {
+	// test vectors from RFC 6238, truncated to 6 digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	code, err := GenerateTOTP(secret, time.Unix(59, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "287082", code)
+
+	code, err = GenerateTOTP(secret, time.Unix(1111111109, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "081804", code)
+
+	_, err = GenerateTOTP("not base32!", time.Unix(59, 0))
+	require.Error(t, err)
+}
+
+func TestSecondFactor_Verify_TOTP(t *testing.T) {
+	f, err := NewTOTPFactor("did:piprate:test", "phone")
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := GenerateTOTP(f.Secret, now)
+	require.NoError(t, err)
+
+	assert.False(t, f.Verify("000000x", now))
+	assert.True(t, f.Verify(code, now))
+	assert.NotZero(t, f.LastUsedStep)
+
+	// the same code can't be used twice
+	assert.False(t, f.Verify(code, now))
+
+	// codes from the previous step are accepted to allow for clock skew
+	next := now.Add(30 * time.Second)
+	code, err = GenerateTOTP(f.Secret, next)
+	require.NoError(t, err)
+	assert.True(t, f.Verify(code, next.Add(30*time.Second)))
+
+	uri := f.KeyURI("MetaLocker", "test@example.com")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/MetaLocker:test@example.com?"))
+	assert.Contains(t, uri, "secret="+f.Secret)
+}
+
+func TestSecondFactor_Verify_BackupCodes(t *testing.T) {
+	f, codes, err := NewBackupCodes("did:piprate:test", 3)
+	require.NoError(t, err)
+	require.Len(t, codes, 3)
+	assert.True(t, f.Confirmed)
+	assert.NotContains(t, f.BackupCodes, codes[0])
+
+	assert.True(t, f.Verify(strings.ToUpper(codes[1]), time.Now()))
+	assert.Len(t, f.BackupCodes, 2)
+
+	// backup codes are single use
+	assert.False(t, f.Verify(codes[1], time.Now()))
+	assert.True(t, f.Verify(codes[0], time.Now()))
+}
+
+func TestVerifySecondFactor(t *testing.T) {
+	totp, err := NewTOTPFactor("did:piprate:test", "phone")
+	require.NoError(t, err)
+	backup, codes, err := NewBackupCodes("did:piprate:test", 2)
+	require.NoError(t, err)
+
+	factors := []*SecondFactor{totp, backup}
+
+	// backup codes alone don't enable MFA
+	assert.False(t, IsMFAEnabled(factors))
+
+	now := time.Now()
+	code, err := GenerateTOTP(totp.Secret, now)
+	require.NoError(t, err)
+
+	// unconfirmed factors are ignored
+	_, err = VerifySecondFactor(factors, code, now)
+	assert.ErrorIs(t, err, ErrInvalidSecondFactor)
+
+	totp.Confirmed = true
+	assert.True(t, IsMFAEnabled(factors))
+
+	_, err = VerifySecondFactor(factors, "", now)
+	assert.ErrorIs(t, err, ErrSecondFactorRequired)
+
+	f, err := VerifySecondFactor(factors, code, now)
+	require.NoError(t, err)
+	assert.Equal(t, totp.ID, f.ID)
+
+	f, err = VerifySecondFactor(factors, codes[0], now)
+	require.NoError(t, err)
+	assert.Equal(t, backup.ID, f.ID)
+}
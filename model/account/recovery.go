@@ -70,6 +70,9 @@ type RecoveryRequest struct {
 	VerificationSignature string `json:"signature"`
 	EncryptedPassword     string `json:"encryptedPassword"`
 	ManagedCryptoKey      string `json:"managedCryptoKey,omitempty"`
+	// OTP is a one-time code from one of the account's second factors
+	// or a backup code. It's required if the account has MFA enabled.
+	OTP string `json:"otp,omitempty"`
 }
 
 func (req *RecoveryRequest) Valid(recoveryPublicKey []byte) bool {
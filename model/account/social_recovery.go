@@ -0,0 +1,271 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/piprate/metalocker/utils/shamir"
+	"github.com/piprate/metalocker/utils/zero"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const (
+	RecoveryShareType = "RecoveryShare"
+)
+
+var (
+	// ErrInvalidRecoveryShare indicates the recovery share is malformed, has an invalid
+	// signature or doesn't belong to the same set as other shares.
+	ErrInvalidRecoveryShare = errors.New("invalid recovery share")
+	// ErrNotEnoughRecoveryShares indicates the number of recovery shares is below
+	// the threshold required to reconstruct the recovery phrase.
+	ErrNotEnoughRecoveryShares = errors.New("not enough recovery shares")
+	// ErrRecoveryPhraseMismatch indicates the recovery phrase doesn't belong to the account.
+	ErrRecoveryPhraseMismatch = errors.New("recovery phrase doesn't match the account")
+)
+
+// RecoveryShare is one of N-of-M Shamir shares of the account's recovery phrase,
+// issued to a guardian for social recovery. Shares are signed by the account's
+// identity that issued them and delivered to guardians encrypted with their
+// verification keys (see SealRecoveryShare). Once enough guardians return their
+// shares, the recovery phrase can be reconstructed (see CombineRecoveryShares).
+type RecoveryShare struct {
+	Type string `json:"type"`
+	// SetID identifies the set of shares produced by the same split.
+	SetID string `json:"setID"`
+	// UserID is the ID (email) of the account that can be recovered with the share.
+	UserID string `json:"userID"`
+	// Issuer is the DID of the account's identity that issued the share.
+	Issuer string `json:"issuer"`
+	// IssuerVerKey is the issuer's public Ed25519 key in base58 encoding.
+	IssuerVerKey string `json:"issuerVerKey"`
+	// Guardian is the DID of the share holder.
+	Guardian string `json:"guardian"`
+	// Threshold is the number of shares required to recover the account.
+	Threshold int `json:"threshold"`
+	// Total is the number of shares in the set.
+	Total int `json:"total"`
+	// Share is the base64-encoded Shamir share of the recovery phrase's entropy.
+	Share   string     `json:"share"`
+	Created *time.Time `json:"created"`
+	// Signature is a base58-encoded Ed25519 signature of the share by the issuer.
+	Signature string `json:"signature,omitempty"`
+}
+
+func (s *RecoveryShare) Bytes() []byte {
+	b, _ := jsonw.Marshal(s)
+	return b
+}
+
+func (s *RecoveryShare) signingBytes() []byte {
+	cp := *s
+	cp.Signature = ""
+	b, _ := jsonw.Marshal(&cp)
+	return b
+}
+
+// Verify checks the share's structure and signature. It doesn't check
+// that the issuer's verification key belongs to the issuer's DID.
+func (s *RecoveryShare) Verify() error {
+	if s.Type != RecoveryShareType || s.SetID == "" || s.UserID == "" || s.Guardian == "" ||
+		s.Threshold < 2 || s.Total < s.Threshold {
+		return ErrInvalidRecoveryShare
+	}
+
+	verKey := base58.Decode(s.IssuerVerKey)
+	if len(verKey) != ed25519.PublicKeySize {
+		return ErrInvalidRecoveryShare
+	}
+	if !ed25519.Verify(verKey, s.signingBytes(), base58.Decode(s.Signature)) {
+		return ErrInvalidRecoveryShare
+	}
+
+	return nil
+}
+
+// NewRecoveryShares splits the account's recovery phrase into Shamir shares, one
+// for each guardian, so that any threshold number of them can recover the account.
+// The shares are signed by the issuer, which should be one of the account's identities.
+func NewRecoveryShares(acct *Account, issuer *model.DID, recoveryPhrase string, guardians []string, threshold int) ([]*RecoveryShare, error) {
+	if acct.RecoveryPublicKey == "" {
+		return nil, errors.New("account doesn't support recovery")
+	}
+
+	seen := make(map[string]bool, len(guardians))
+	for _, g := range guardians {
+		if g == "" || g == issuer.ID || seen[g] {
+			return nil, fmt.Errorf("invalid guardian list: %s", g)
+		}
+		seen[g] = true
+	}
+
+	entropy, err := bip39.EntropyFromMnemonic(recoveryPhrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zero.Bytes(entropy)
+
+	// check the phrase restored from the entropy produces the account's recovery key
+
+	canonicalPhrase, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	_, pubKey, _, err := GenerateKeysFromRecoveryPhrase(canonicalPhrase)
+	if err != nil {
+		return nil, err
+	}
+	expectedPubKey, err := base64.StdEncoding.DecodeString(acct.RecoveryPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(pubKey, expectedPubKey) {
+		return nil, ErrRecoveryPhraseMismatch
+	}
+
+	parts, err := shamir.Split(entropy, len(guardians), threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	setID, err := utils.RandomID(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	res := make([]*RecoveryShare, len(guardians))
+	for i, g := range guardians {
+		s := &RecoveryShare{
+			Type:         RecoveryShareType,
+			SetID:        setID,
+			UserID:       acct.Email,
+			Issuer:       issuer.ID,
+			IssuerVerKey: issuer.VerKey,
+			Guardian:     g,
+			Threshold:    threshold,
+			Total:        len(guardians),
+			Share:        base64.StdEncoding.EncodeToString(parts[i]),
+			Created:      &now,
+		}
+		s.Signature = base58.Encode(ed25519.Sign(issuer.SignKeyValue(), s.signingBytes()))
+		zero.Bytes(parts[i])
+
+		res[i] = s
+	}
+
+	return res, nil
+}
+
+// CombineRecoveryShares reconstructs the recovery phrase from the given shares.
+// All shares should belong to the same set and their number should be equal
+// or above the set's threshold.
+func CombineRecoveryShares(shares []*RecoveryShare) (string, error) {
+	if len(shares) == 0 {
+		return "", ErrNotEnoughRecoveryShares
+	}
+
+	first := shares[0]
+	guardians := make(map[string]bool, len(shares))
+	parts := make([][]byte, 0, len(shares))
+	for _, s := range shares {
+		if err := s.Verify(); err != nil {
+			return "", err
+		}
+		if s.SetID != first.SetID || s.IssuerVerKey != first.IssuerVerKey || s.UserID != first.UserID ||
+			s.Threshold != first.Threshold || s.Total != first.Total {
+			return "", fmt.Errorf("%w: shares belong to different sets", ErrInvalidRecoveryShare)
+		}
+		if guardians[s.Guardian] {
+			// duplicate shares don't count towards the threshold
+			continue
+		}
+		guardians[s.Guardian] = true
+
+		part, err := base64.StdEncoding.DecodeString(s.Share)
+		if err != nil {
+			return "", ErrInvalidRecoveryShare
+		}
+		parts = append(parts, part)
+	}
+
+	if len(parts) < first.Threshold {
+		return "", ErrNotEnoughRecoveryShares
+	}
+
+	entropy, err := shamir.Combine(parts)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidRecoveryShare, err.Error())
+	}
+	defer zero.Bytes(entropy)
+
+	for _, part := range parts {
+		zero.Bytes(part)
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+// RecoveryKeysFromShares reconstructs the account's recovery keys from the given shares.
+// The returned crypto key can be passed to Recover to restore the account's secrets
+// and the private key can be used to sign a recovery request (see BuildRecoveryRequest).
+func RecoveryKeysFromShares(shares []*RecoveryShare) (*model.AESKey, ed25519.PublicKey, ed25519.PrivateKey, error) {
+	recoveryPhrase, err := CombineRecoveryShares(shares)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return GenerateKeysFromRecoveryPhrase(recoveryPhrase)
+}
+
+// SealRecoveryShare encrypts the share with the recipient's verification key.
+// Shares are sealed with the guardian's key for delivery and with the recovering
+// user's one-off key when the guardian returns them.
+func SealRecoveryShare(s *RecoveryShare, recipientVerKey ed25519.PublicKey) ([]byte, error) {
+	b, err := jsonw.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return model.AnonEncrypt(b, recipientVerKey), nil
+}
+
+// OpenRecoveryShare decrypts the share using the recipient's signing key and verifies it.
+func OpenRecoveryShare(data []byte, recipientSignKey ed25519.PrivateKey) (*RecoveryShare, error) {
+	b, err := model.AnonDecrypt(data, recipientSignKey)
+	if err != nil {
+		return nil, ErrInvalidRecoveryShare
+	}
+
+	var s RecoveryShare
+	if err = jsonw.Unmarshal(b, &s); err != nil {
+		return nil, ErrInvalidRecoveryShare
+	}
+
+	if err = s.Verify(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
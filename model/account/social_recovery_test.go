@@ -0,0 +1,183 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package account_test
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/piprate/metalocker/model"
+	. "github.com/piprate/metalocker/model/account"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocialRecoveryProcedure(t *testing.T) {
+	genResp, err := GenerateAccount(
+		&Account{
+			Email:       "test@example.com",
+			Name:        "Tester",
+			AccessLevel: model.AccessLevelManaged,
+		},
+		WithPassphraseAuth("pass"))
+	require.NoError(t, err)
+
+	acct := genResp.Account
+
+	issuer, err := model.GenerateDID()
+	require.NoError(t, err)
+
+	guardians := make([]*model.DID, 3)
+	guardianIDs := make([]string, 3)
+	for i := range guardians {
+		guardians[i], err = model.GenerateDID()
+		require.NoError(t, err)
+		guardianIDs[i] = guardians[i].ID
+	}
+
+	// the owner splits the recovery phrase and sends shares to the guardians
+
+	shares, err := NewRecoveryShares(acct, issuer, genResp.RecoveryPhrase, guardianIDs, 2)
+	require.NoError(t, err)
+	require.Len(t, shares, 3)
+
+	sealedShares := make([][]byte, 3)
+	for i, s := range shares {
+		assert.Equal(t, guardianIDs[i], s.Guardian)
+		assert.Equal(t, "test@example.com", s.UserID)
+		assert.Equal(t, 2, s.Threshold)
+		assert.Equal(t, 3, s.Total)
+		assert.Equal(t, shares[0].SetID, s.SetID)
+
+		sealedShares[i], err = SealRecoveryShare(s, guardians[i].VerKeyValue())
+		require.NoError(t, err)
+	}
+
+	// guardians can only open their own shares
+
+	_, err = OpenRecoveryShare(sealedShares[0], guardians[1].SignKeyValue())
+	require.ErrorIs(t, err, ErrInvalidRecoveryShare)
+
+	// the recovering user asks two guardians to return their shares
+
+	requestPubKey, requestPrivKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	returnedShares := make([]*RecoveryShare, 0, 2)
+	for _, i := range []int{0, 2} {
+		s, err := OpenRecoveryShare(sealedShares[i], guardians[i].SignKeyValue())
+		require.NoError(t, err)
+
+		returned, err := SealRecoveryShare(s, requestPubKey)
+		require.NoError(t, err)
+
+		s, err = OpenRecoveryShare(returned, requestPrivKey)
+		require.NoError(t, err)
+		returnedShares = append(returnedShares, s)
+	}
+
+	_, err = CombineRecoveryShares(returnedShares[:1])
+	require.ErrorIs(t, err, ErrNotEnoughRecoveryShares)
+
+	// duplicates don't count
+
+	_, err = CombineRecoveryShares([]*RecoveryShare{returnedShares[0], returnedShares[0]})
+	require.ErrorIs(t, err, ErrNotEnoughRecoveryShares)
+
+	recoveryPhrase, err := CombineRecoveryShares(returnedShares)
+	require.NoError(t, err)
+	assert.Equal(t, genResp.RecoveryPhrase, recoveryPhrase)
+
+	cryptoKey, _, _, err := RecoveryKeysFromShares(returnedShares)
+	require.NoError(t, err)
+
+	newPassphrase := "new_password"
+
+	recoveredAcct, err := Recover(acct, cryptoKey, newPassphrase)
+	require.NoError(t, err)
+
+	_, err = recoveredAcct.ExtractManagedKey(HashUserPassword(newPassphrase))
+	require.NoError(t, err)
+}
+
+func TestNewRecoveryShares_Errors(t *testing.T) {
+	genResp, err := GenerateAccount(
+		&Account{
+			Email:       "test@example.com",
+			Name:        "Tester",
+			AccessLevel: model.AccessLevelManaged,
+		},
+		WithPassphraseAuth("pass"))
+	require.NoError(t, err)
+
+	acct := genResp.Account
+
+	issuer, err := model.GenerateDID()
+	require.NoError(t, err)
+
+	guardians := []string{"did:piprate:1", "did:piprate:2", "did:piprate:3"}
+
+	// recovery phrase of another account
+
+	_, err = NewRecoveryShares(acct, issuer,
+		"book shed chapter large work worth record robot enough extend gadget major just entry umbrella icon stomach miss maid glance push debate pass first",
+		guardians, 2)
+	require.ErrorIs(t, err, ErrRecoveryPhraseMismatch)
+
+	_, err = NewRecoveryShares(acct, issuer, "not a recovery phrase", guardians, 2)
+	require.Error(t, err)
+
+	_, err = NewRecoveryShares(acct, issuer, genResp.RecoveryPhrase, guardians, 4)
+	require.Error(t, err)
+
+	_, err = NewRecoveryShares(acct, issuer, genResp.RecoveryPhrase, []string{"did:piprate:1", "did:piprate:1"}, 2)
+	require.Error(t, err)
+
+	_, err = NewRecoveryShares(acct, issuer, genResp.RecoveryPhrase, []string{issuer.ID, "did:piprate:1"}, 2)
+	require.Error(t, err)
+}
+
+func TestCombineRecoveryShares_InvalidShares(t *testing.T) {
+	genResp, err := GenerateAccount(
+		&Account{
+			Email:       "test@example.com",
+			Name:        "Tester",
+			AccessLevel: model.AccessLevelManaged,
+		},
+		WithPassphraseAuth("pass"))
+	require.NoError(t, err)
+
+	issuer, err := model.GenerateDID()
+	require.NoError(t, err)
+
+	guardians := []string{"did:piprate:1", "did:piprate:2", "did:piprate:3"}
+
+	set1, err := NewRecoveryShares(genResp.Account, issuer, genResp.RecoveryPhrase, guardians, 2)
+	require.NoError(t, err)
+	set2, err := NewRecoveryShares(genResp.Account, issuer, genResp.RecoveryPhrase, guardians, 2)
+	require.NoError(t, err)
+
+	// shares from different sets can't be combined
+
+	_, err = CombineRecoveryShares([]*RecoveryShare{set1[0], set2[1]})
+	require.ErrorIs(t, err, ErrInvalidRecoveryShare)
+
+	// tampered share
+
+	tampered := *set1[1]
+	tampered.Threshold = 3
+	_, err = CombineRecoveryShares([]*RecoveryShare{set1[0], &tampered})
+	require.ErrorIs(t, err, ErrInvalidRecoveryShare)
+}
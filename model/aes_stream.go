@@ -0,0 +1,271 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+/*
+  Chunked AES-GCM stream format (version 1):
+
+    noncePrefix | chunk_0 | chunk_1 | ... | chunk_n
+
+  noncePrefix is 7 random bytes. Each chunk is a separate AES-GCM ciphertext
+  (with its 16-byte tag) of up to StreamChunkSize bytes of plaintext. The nonce
+  for chunk i is noncePrefix | uint32_be(i) | lastFlag, where lastFlag is 1 for
+  the final chunk and 0 otherwise. This prevents chunk reordering, truncation
+  and extension (see the STREAM construction by Hoang, Reyhanitabar,
+  Rogaway and Vizár).
+*/
+
+const (
+	// StreamChunkSize is the maximum size of plaintext in each encrypted chunk.
+	StreamChunkSize = 64 * 1024
+
+	// StreamNoncePrefixSize is the size of the random nonce prefix at the start of the stream.
+	StreamNoncePrefixSize = 7
+	// StreamTagSize is the size of the authentication tag appended to each encrypted chunk.
+	StreamTagSize = 16
+)
+
+var (
+	ErrStreamTruncated  = errors.New("encrypted stream truncated")
+	ErrStreamMalformed  = errors.New("malformed encrypted stream")
+	ErrStreamChunkLimit = errors.New("encrypted stream exceeds maximum number of chunks")
+)
+
+func newStreamAEAD(key *AESKey) (cipher.AEAD, error) {
+	if key == nil {
+		return nil, errors.New("empty AES key")
+	}
+	block, err := aes.NewCipher(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func streamNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, StreamNoncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[StreamNoncePrefixSize:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+type aesStreamWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	out     []byte
+	closed  bool
+}
+
+// NewAESGCMStreamWriter returns a writer that encrypts everything written to it
+// using chunked 256-bit AES-GCM and writes the ciphertext to w. The caller
+// MUST call Close to flush the final chunk. Close doesn't close w.
+func NewAESGCMStreamWriter(w io.Writer, key *AESKey) (io.WriteCloser, error) {
+	gcm, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, StreamNoncePrefixSize)
+	if _, err = io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(prefix); err != nil {
+		return nil, err
+	}
+
+	return &aesStreamWriter{
+		w:      w,
+		gcm:    gcm,
+		prefix: prefix,
+		buf:    make([]byte, 0, StreamChunkSize),
+		out:    make([]byte, 0, StreamChunkSize+StreamTagSize),
+	}, nil
+}
+
+func (sw *aesStreamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errors.New("write to closed encrypted stream")
+	}
+
+	n := 0
+	for len(p) > 0 {
+		if len(sw.buf) == StreamChunkSize {
+			// we only flush a full chunk when we know more data follows,
+			// so that the final chunk can always be marked as such on Close.
+			if err := sw.flush(false); err != nil {
+				return n, err
+			}
+		}
+
+		toCopy := StreamChunkSize - len(sw.buf)
+		if toCopy > len(p) {
+			toCopy = len(p)
+		}
+		sw.buf = append(sw.buf, p[:toCopy]...)
+		p = p[toCopy:]
+		n += toCopy
+	}
+
+	return n, nil
+}
+
+func (sw *aesStreamWriter) flush(last bool) error {
+	if sw.counter == math.MaxUint32 {
+		return ErrStreamChunkLimit
+	}
+
+	sw.out = sw.gcm.Seal(sw.out[:0], streamNonce(sw.prefix, sw.counter, last), sw.buf, nil)
+	if _, err := sw.w.Write(sw.out); err != nil {
+		return err
+	}
+
+	sw.counter++
+	sw.buf = sw.buf[:0]
+
+	return nil
+}
+
+func (sw *aesStreamWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+
+	return sw.flush(true)
+}
+
+type aesStreamReader struct {
+	r       *bufio.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	in      []byte
+	out     []byte
+	buf     []byte
+	done    bool
+	err     error
+}
+
+// NewAESGCMStreamReader returns a reader that decrypts a stream produced
+// by NewAESGCMStreamWriter. Each chunk is authenticated before it's returned
+// to the caller. If the stream was truncated or tampered with, Read returns
+// an error.
+func NewAESGCMStreamReader(r io.Reader, key *AESKey) (io.Reader, error) {
+	gcm, err := newStreamAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, StreamNoncePrefixSize)
+	if _, err = io.ReadFull(r, prefix); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrStreamMalformed
+		}
+		return nil, err
+	}
+
+	return &aesStreamReader{
+		r:      bufio.NewReaderSize(r, StreamChunkSize+StreamTagSize),
+		gcm:    gcm,
+		prefix: prefix,
+		in:     make([]byte, StreamChunkSize+StreamTagSize),
+		out:    make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+func (sr *aesStreamReader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.done {
+			return 0, io.EOF
+		}
+		sr.err = sr.next()
+	}
+
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+
+	return n, nil
+}
+
+func (sr *aesStreamReader) next() error {
+	n, err := io.ReadFull(sr.r, sr.in)
+	switch {
+	case err == nil:
+	case errors.Is(err, io.ErrUnexpectedEOF):
+	case errors.Is(err, io.EOF):
+		// the stream ended without a chunk marked as final
+		return ErrStreamTruncated
+	default:
+		return err
+	}
+
+	last := n < len(sr.in)
+	if !last {
+		// a full chunk may still be the last one
+		if _, err = sr.r.Peek(1); err != nil {
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			last = true
+		}
+	}
+
+	if n < StreamTagSize {
+		return ErrStreamMalformed
+	}
+
+	if sr.counter == math.MaxUint32 {
+		return ErrStreamChunkLimit
+	}
+
+	sr.buf, err = sr.gcm.Open(sr.out[:0], streamNonce(sr.prefix, sr.counter, last), sr.in[:n], nil)
+	if err != nil {
+		if last {
+			// the chunk may have been authenticated as non-final, which means the stream
+			// was truncated at a chunk boundary.
+			if _, nerr := sr.gcm.Open(nil, streamNonce(sr.prefix, sr.counter, false), sr.in[:n], nil); nerr == nil {
+				return ErrStreamTruncated
+			}
+		}
+		return err
+	}
+
+	sr.counter++
+	sr.done = last
+
+	return nil
+}
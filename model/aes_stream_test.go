@@ -0,0 +1,111 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	. "github.com/piprate/metalocker/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encryptStream(t *testing.T, data []byte, key *AESKey) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewAESGCMStreamWriter(&buf, key)
+	require.NoError(t, err)
+	_, err = io.Copy(w, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func decryptStream(ciphertext []byte, key *AESKey) ([]byte, error) {
+	r, err := NewAESGCMStreamReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestAESGCMStream_RoundTrip(t *testing.T) {
+	key := NewEncryptionKey()
+
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 17} {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+
+		ciphertext := encryptStream(t, data, key)
+
+		plaintext, err := decryptStream(ciphertext, key)
+		require.NoError(t, err, "size=%d", size)
+		assert.Equal(t, data, plaintext, "size=%d", size)
+	}
+}
+
+func TestAESGCMStream_WrongKey(t *testing.T) {
+	ciphertext := encryptStream(t, []byte("test blob"), NewEncryptionKey())
+
+	_, err := decryptStream(ciphertext, NewEncryptionKey())
+	require.Error(t, err)
+}
+
+func TestAESGCMStream_Truncated(t *testing.T) {
+	key := NewEncryptionKey()
+
+	data := make([]byte, 2*StreamChunkSize+100)
+	_, _ = rand.Read(data)
+
+	ciphertext := encryptStream(t, data, key)
+
+	// truncate at the chunk boundary
+
+	_, err := decryptStream(ciphertext[:StreamNoncePrefixSize+StreamChunkSize+StreamTagSize], key)
+	assert.ErrorIs(t, err, ErrStreamTruncated)
+
+	// truncate in the middle of a chunk
+
+	_, err = decryptStream(ciphertext[:len(ciphertext)-10], key)
+	require.Error(t, err)
+
+	// nonce prefix only
+
+	_, err = decryptStream(ciphertext[:StreamNoncePrefixSize], key)
+	assert.ErrorIs(t, err, ErrStreamTruncated)
+
+	// incomplete nonce prefix
+
+	_, err = decryptStream(ciphertext[:StreamNoncePrefixSize-4], key)
+	assert.ErrorIs(t, err, ErrStreamMalformed)
+}
+
+func TestAESGCMStream_Tampered(t *testing.T) {
+	key := NewEncryptionKey()
+
+	data := make([]byte, StreamChunkSize+100)
+	_, _ = rand.Read(data)
+
+	ciphertext := encryptStream(t, data, key)
+	ciphertext[StreamChunkSize/2] ^= 0x01
+
+	_, err := decryptStream(ciphertext, key)
+	require.Error(t, err)
+}
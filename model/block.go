@@ -14,6 +14,12 @@
 
 package model
 
+import (
+	"bytes"
+	"encoding/base64"
+	"strconv"
+)
+
 // Block defines a block of the MetaLocker ledger.
 // Blocks are identified by their sequential numbers, starting with 0.
 // Hash and ParentHash fields allow connecting a specific block
@@ -22,4 +28,34 @@ type Block struct {
 	Number     int64  `json:"number"`
 	Hash       string `json:"hash"`
 	ParentHash string `json:"parentHash,omitempty"`
+	// Nonce is the random value used to seal the block. It allows verifying
+	// the block hash. Blocks produced by older ledgers may not have it.
+	Nonce string `json:"nonce,omitempty"`
+	// MerkleRoot is the root of the Merkle tree over the IDs of the block's
+	// records (see RecordMerkleRoot). It's empty for blocks without records
+	// and for blocks produced by older ledgers.
+	MerkleRoot string `json:"merkleRoot,omitempty"`
+	// Timestamp is the Unix time when the block was produced. It's not covered
+	// by the block hash and is zero for blocks produced by older ledgers.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// BlockHash computes the hash of a ledger block from its number, parent hash,
+// nonce and Merkle root of its records. Blocks without a Merkle root are hashed
+// the same way as before Merkle roots were introduced.
+func BlockHash(number int64, parentHash, nonce, merkleRoot string) string {
+	buf := bytes.NewBuffer(nil)
+	parentIDVal, _ := base64.StdEncoding.DecodeString(parentHash)
+	buf.Write(parentIDVal)
+	nonceVal, _ := base64.StdEncoding.DecodeString(nonce)
+	buf.Write(nonceVal)
+	buf.WriteString(strconv.Itoa(int(number)))
+	if merkleRoot != "" {
+		rootVal, _ := base64.StdEncoding.DecodeString(merkleRoot)
+		buf.Write(rootVal)
+	}
+
+	hash := Hash("block construction", buf.Bytes())
+
+	return base64.StdEncoding.EncodeToString(hash)
 }
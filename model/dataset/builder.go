@@ -79,6 +79,7 @@ type builderOptions struct {
 	contentType    string
 	heads          []string
 	timeStamp      *time.Time
+	disclosure     bool
 }
 
 // BuilderOption is for defining optional parameters for Builder
@@ -144,6 +145,17 @@ func SetHeads(headName ...string) BuilderOption {
 	}
 }
 
+// WithSelectiveDisclosure instructs AddMetaResource to commit the meta resource to
+// a disclosure root, so that its properties can be selectively disclosed later.
+// The meta resource should be a JSON object. If passed to the builder's constructor,
+// the option applies to any meta resource added to the dataset.
+func WithSelectiveDisclosure() BuilderOption {
+	return func(opts *builderOptions) error {
+		opts.disclosure = true
+		return nil
+	}
+}
+
 func AsCleartext() BuilderOption {
 	return func(opts *builderOptions) error {
 		opts.cleartext = true
@@ -156,19 +168,22 @@ type LeaseBuilder struct {
 	blobManager model.BlobManager
 
 	lockerID          string
+	lockerSealed      bool
 	senderParticipant *model.LockerParticipant
 	didMethod         string
 	vaultName         string
 	cleartext         bool
 	creator           *model.DID
 	sender            *model.DID
-	recipientID       string
+	recipientIDs      []string
 	imp               *model.Impression
 	timestampOverride *time.Time
 	resources         map[string]*model.StoredResource
 	sourceRecordID    string
 	sourceLease       *model.Lease
 	sharingMode       bool
+	disclosure        bool
+	disclosureSeed    string
 
 	headNames []string
 
@@ -207,6 +222,7 @@ func NewLeaseBuilder(ctx context.Context, c LeaseBuilderBackend, blobManager mod
 		backend:           c,
 		blobManager:       blobManager,
 		lockerID:          locker.ID,
+		lockerSealed:      locker.IsSealed(),
 		senderParticipant: locker.Us(),
 		creator:           creator,
 		didMethod:         options.didMethod,
@@ -219,6 +235,7 @@ func NewLeaseBuilder(ctx context.Context, c LeaseBuilderBackend, blobManager mod
 		resources:         make(map[string]*model.StoredResource),
 		headNames:         options.heads,
 		timestampOverride: options.timeStamp,
+		disclosure:        options.disclosure,
 		ctx:               ctx,
 	}
 
@@ -236,7 +253,11 @@ func NewLeaseBuilder(ctx context.Context, c LeaseBuilderBackend, blobManager mod
 
 	if !locker.IsUnilocker() {
 		// if it's not a 'private' locker with one identity, automatically add share provenance
-		err := b.AddShareProvenance(nil, locker.Them().ID)
+		var recipientIDs []string
+		for _, p := range locker.Counterparties() {
+			recipientIDs = append(recipientIDs, p.ID)
+		}
+		err := b.AddShareProvenance(nil, recipientIDs...)
 		if err != nil {
 			return nil, err
 		}
@@ -258,12 +279,13 @@ func NewLeaseBuilderForSharing(ctx context.Context, source model.DataSet, c Leas
 		vaultName:         vaultName,
 		creator:           creator,
 		sender:            sender,
-		recipientID:       recipientID,
+		recipientIDs:      []string{recipientID},
 		imp:               sourceLease.Impression,
 		sharingMode:       true,
 		resources:         make(map[string]*model.StoredResource),
 		sourceRecordID:    source.ID(),
 		sourceLease:       sourceLease,
+		disclosureSeed:    sourceLease.DisclosureSeed,
 		timestampOverride: timeStamp,
 		ctx:               ctx,
 	}
@@ -429,6 +451,20 @@ func (b *LeaseBuilder) AddMetaResource(meta any, opts ...BuilderOption) (string,
 		FingerprintAlgorithm: fp.AlgoSha256,
 	}
 
+	if options.disclosure || b.disclosure {
+		seed, err := model.NewDisclosureSeed()
+		if err != nil {
+			return "", err
+		}
+		b.imp.MetaResource.DisclosureRoot, err = model.DisclosureRoot(data, seed)
+		if err != nil {
+			return "", err
+		}
+		b.disclosureSeed = seed
+	} else {
+		b.disclosureSeed = ""
+	}
+
 	if b.metaProvTemplate != nil {
 		b.metaProvTemplate["id"] = assetID
 		b.provenance[assetID] = b.metaProvTemplate
@@ -570,16 +606,26 @@ func (b *LeaseBuilder) AddProvenance(id string, provenance any, override bool) e
 	return nil
 }
 
-func (b *LeaseBuilder) AddShareProvenance(sender *model.DID, recipientID string) error {
+// AddShareProvenance records that the dataset was shared by the sender with the given recipients.
+// If more than one recipient is specified (multi-party lockers), all of them will be listed
+// in the share provenance entity.
+func (b *LeaseBuilder) AddShareProvenance(sender *model.DID, recipientIDs ...string) error {
 	if sender == nil {
 		sender = b.creator
 	}
 	b.sender = sender
-	b.recipientID = recipientID
+	b.recipientIDs = recipientIDs
 
 	return nil
 }
 
+func (b *LeaseBuilder) wasAccessibleTo() any {
+	if len(b.recipientIDs) == 1 {
+		return b.recipientIDs[0]
+	}
+	return b.recipientIDs
+}
+
 func (b *LeaseBuilder) Build(expiryTime time.Time) (*model.Lease, error) {
 
 	now := time.Now().UTC()
@@ -609,7 +655,7 @@ func (b *LeaseBuilder) Build(expiryTime time.Time) (*model.Lease, error) {
 			Type:            model.ProvTypeEntity,
 			WasAttributedTo: b.sender.ID,
 			WasQuotedFrom:   wasQuotedFrom,
-			WasAccessibleTo: b.recipientID,
+			WasAccessibleTo: b.wasAccessibleTo(),
 			GeneratedAtTime: ts,
 		}
 
@@ -686,13 +732,13 @@ func (b *LeaseBuilder) Build(expiryTime time.Time) (*model.Lease, error) {
 			return nil, err
 		}
 
-		if b.recipientID != "" {
+		if len(b.recipientIDs) > 0 {
 			shareProvenance = &model.ProvEntity{
 				Context:         model.PiprateContextURL,
 				Type:            model.ProvTypeEntity,
 				WasAttributedTo: b.sender.ID,
 				WasQuotedFrom:   b.imp.ID,
-				WasAccessibleTo: b.recipientID,
+				WasAccessibleTo: b.wasAccessibleTo(),
 				GeneratedAtTime: ts,
 			}
 
@@ -734,6 +780,8 @@ func (b *LeaseBuilder) Build(expiryTime time.Time) (*model.Lease, error) {
 		DataSetType: "graph",
 		Impression:  b.imp,
 		Provenance:  shareProvenance,
+
+		DisclosureSeed: b.disclosureSeed,
 	}
 
 	return opRec, nil
@@ -745,6 +793,10 @@ func (b *LeaseBuilder) SetHeads(headName ...string) error {
 }
 
 func (b *LeaseBuilder) Submit(expiryTime time.Time) RecordFuture {
+	if b.lockerSealed {
+		return RecordFutureWithError(model.ErrLockerSealed)
+	}
+
 	if !expiryTime.IsZero() {
 		// check the expiry date is in the future and not too close to now
 		expiryDelta := time.Until(expiryTime)
@@ -0,0 +1,79 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dataset
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+)
+
+var (
+	ErrLeaseNotRenewable = errors.New("lease can't be renewed")
+)
+
+// LeaseRenewalBackend submits lease renewal records to the ledger.
+type LeaseRenewalBackend interface {
+	SubmitRenewal(ctx context.Context, ds model.DataSet, expiryTime time.Time) RecordFuture
+}
+
+// RenewalBuilder prepares a renewal of the lease behind the given dataset.
+// Renewals extend the lease's expiry time without creating a new revision
+// of the dataset.
+type RenewalBuilder struct {
+	backend LeaseRenewalBackend
+	ds      model.DataSet
+
+	ctx context.Context
+}
+
+// NewRenewalBuilder returns a builder for renewing the lease behind the dataset.
+// Only active leases with an expiry time can be renewed.
+func NewRenewalBuilder(ctx context.Context, backend LeaseRenewalBackend, ds model.DataSet) (*RenewalBuilder, error) {
+	rec := ds.Record()
+	if rec == nil || rec.Operation != model.OpTypeLease {
+		return nil, ErrLeaseNotRenewable
+	}
+	if rec.Status == model.StatusRevoked || rec.Status == model.StatusExpired {
+		return nil, ErrLeaseNotRenewable
+	}
+	if rec.ExpiresAt == 0 {
+		// leases without expiry time never expire
+		return nil, ErrLeaseNotRenewable
+	}
+
+	return &RenewalBuilder{
+		backend: backend,
+		ds:      ds,
+		ctx:     ctx,
+	}, nil
+}
+
+// Submit submits the lease renewal with the new expiry time. The new expiry time
+// should be in the future and after the lease's original expiry time. The ledger
+// will reject renewals that don't extend the lease's effective expiry time.
+func (b *RenewalBuilder) Submit(expiryTime time.Time) RecordFuture {
+	if expiryTime.IsZero() || time.Until(expiryTime) < time.Second {
+		return RecordFutureWithError(errors.New("invalid lease expiry time"))
+	}
+
+	if expiryTime.Unix() <= b.ds.Record().ExpiresAt {
+		return RecordFutureWithError(errors.New("new lease expiry time should be after the current one"))
+	}
+
+	return b.backend.SubmitRenewal(b.ctx, b.ds, expiryTime)
+}
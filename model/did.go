@@ -31,6 +31,7 @@ import (
 const (
 	piprateDIDPrefix               = "did:piprate:"
 	Ed25519VerificationKey2018Type = "Ed25519VerificationKey2018"
+	Ed25519VerificationKey2020Type = "Ed25519VerificationKey2020"
 )
 
 var ErrInvalidDID = errors.New("invalid DID identifier")
@@ -61,14 +62,17 @@ type (
 	}
 
 	DIDDocument struct {
-		Context        any        `json:"@context,omitempty"`
-		ID             string     `json:"id"`
-		PublicKey      []any      `json:"publicKey,omitempty"`
-		Authentication []any      `json:"authentication,omitempty"`
-		Service        []any      `json:"service,omitempty"`
-		Created        *time.Time `json:"created,omitempty"`
-		Updated        *time.Time `json:"updated,omitempty"`
-		Proof          *Proof     `json:"proof,omitempty"`
+		Context   any    `json:"@context,omitempty"`
+		ID        string `json:"id"`
+		PublicKey []any  `json:"publicKey,omitempty"`
+		// VerificationMethod is the DID Core replacement for PublicKey. It's only
+		// populated in DID documents resolved from external DID methods.
+		VerificationMethod []any      `json:"verificationMethod,omitempty"`
+		Authentication     []any      `json:"authentication,omitempty"`
+		Service            []any      `json:"service,omitempty"`
+		Created            *time.Time `json:"created,omitempty"`
+		Updated            *time.Time `json:"updated,omitempty"`
+		Proof              *Proof     `json:"proof,omitempty"`
 	}
 )
 
@@ -97,6 +101,21 @@ func (did *DID) Verify(message, signature []byte) bool {
 	return ed25519.Verify(did.VerKeyValue(), message, signature)
 }
 
+// Validate checks that the DID's keys are consistent with its identifier,
+// for the DID methods where the identifier is derived from the public key.
+func (did *DID) Validate() error {
+	if strings.HasPrefix(did.ID, didKeyPrefix) {
+		verKey, err := ExtractKeyDIDPublicKey(did.ID)
+		if err != nil {
+			return err
+		}
+		if !verKey.Equal(did.VerKeyValue()) {
+			return fmt.Errorf("%w: did:key identifier doesn't match the verification key", ErrInvalidDID)
+		}
+	}
+	return nil
+}
+
 func (did *DID) Bytes() []byte {
 	b, _ := jsonw.Marshal(did)
 	return b
@@ -122,8 +141,9 @@ func (did *DID) Copy() *DID {
 
 type (
 	didOptions struct {
-		seed   string
-		method string
+		seed    string
+		method  string
+		webHost string
 	}
 
 	DIDOption func(opts *didOptions)
@@ -141,6 +161,19 @@ func WithMethod(method string) DIDOption {
 	}
 }
 
+// WithWebHost sets the method-specific part of a did:web identifier
+// (domain name, optionally followed by colon-separated path segments,
+// e.g. 'example.com:users:alice'). Only used with WithMethod(DIDMethodWeb).
+func WithWebHost(host string) DIDOption {
+	return func(opts *didOptions) {
+		opts.webHost = host
+	}
+}
+
+// GenerateDID generates a new DID with a fresh Ed25519 key pair. By default,
+// it produces Indy-style identifiers. If DIDMethodKey method is specified,
+// the identifier is derived from the public key. If DIDMethodWeb method is
+// specified, the identifier is built from the host provided in WithWebHost.
 func GenerateDID(options ...DIDOption) (*DID, error) {
 	var opts didOptions
 	for _, o := range options {
@@ -158,14 +191,27 @@ func GenerateDID(options ...DIDOption) (*DID, error) {
 	publicKey, privateKey, err := ed25519.GenerateKey(randSeed)
 	if err != nil {
 		return nil, err
-	} else {
-		return &DID{
-			ID:      BuildDIDPrefix(opts.method) + base58.Encode(publicKey[0:16]),
-			VerKey:  base58.Encode(publicKey),
-			SignKey: base58.Encode(privateKey),
-			signKey: privateKey,
-		}, nil
 	}
+
+	var id string
+	switch opts.method {
+	case DIDMethodKey:
+		id = BuildKeyDID(publicKey)
+	case DIDMethodWeb:
+		id = didWebPrefix + opts.webHost
+		if _, err = WebDIDDocumentURL(id); err != nil {
+			return nil, err
+		}
+	default:
+		id = BuildDIDPrefix(opts.method) + base58.Encode(publicKey[0:16])
+	}
+
+	return &DID{
+		ID:      id,
+		VerKey:  base58.Encode(publicKey),
+		SignKey: base58.Encode(privateKey),
+		signKey: privateKey,
+	}, nil
 }
 
 var didPrefixCache = map[string]string{}
@@ -249,33 +295,44 @@ func (d *DIDDocument) Verify(key ed25519.PublicKey) (bool, error) {
 }
 
 func (d *DIDDocument) ExtractIndyStyleDID() (*DID, error) {
-	if len(d.PublicKey) > 0 {
-		for _, k := range d.PublicKey {
-			switch val := k.(type) {
-			case *Ed25519VerificationKey2018:
-				if val.Type == Ed25519VerificationKey2018Type {
-					if val.PublicKeyBase58 != "" {
-						return &DID{
-							ID:     d.ID,
-							VerKey: val.PublicKeyBase58,
-						}, nil
-					}
-				}
-			case map[string]any:
-				if val["type"] == Ed25519VerificationKey2018Type {
-					if verKey := val["publicKeyBase58"].(string); verKey != "" {
-						return &DID{
-							ID:     d.ID,
-							VerKey: verKey,
-						}, nil
-					}
-				}
+	for _, keys := range [][]any{d.PublicKey, d.VerificationMethod} {
+		for _, k := range keys {
+			if verKey := extractEd25519VerKey(k); verKey != "" {
+				return &DID{
+					ID:     d.ID,
+					VerKey: verKey,
+				}, nil
 			}
 		}
 	}
 	return nil, errors.New("no instances of Ed25519VerificationKey2018 found")
 }
 
+// extractEd25519VerKey returns the base58-encoded Ed25519 public key from the given
+// verification method, or an empty string if the method isn't an Ed25519 key.
+func extractEd25519VerKey(k any) string {
+	switch val := k.(type) {
+	case *Ed25519VerificationKey2018:
+		if val.Type == Ed25519VerificationKey2018Type {
+			return val.PublicKeyBase58
+		}
+	case map[string]any:
+		switch val["type"] {
+		case Ed25519VerificationKey2018Type:
+			if verKey, ok := val["publicKeyBase58"].(string); ok {
+				return verKey
+			}
+		case Ed25519VerificationKey2020Type:
+			if mbKey, ok := val["publicKeyMultibase"].(string); ok {
+				if key, err := decodeEd25519Multibase(mbKey); err == nil {
+					return base58.Encode(key)
+				}
+			}
+		}
+	}
+	return ""
+}
+
 func SimpleDIDDocument(did *DID, created *time.Time) (*DIDDocument, error) {
 	if created == nil {
 		now := time.Now().UTC()
@@ -0,0 +1,103 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+const (
+	DIDMethodKey = "key"
+
+	didKeyPrefix = "did:key:"
+
+	// multibaseBase58BTC is the multibase prefix for base58btc encoding
+	multibaseBase58BTC = 'z'
+)
+
+// ed25519PubMulticodec is the varint-encoded multicodec prefix for 'ed25519-pub' keys.
+var ed25519PubMulticodec = []byte{0xed, 0x01}
+
+// KeyDIDResolver resolves did:key identifiers (https://w3c-ccg.github.io/did-method-key/).
+// Only Ed25519 keys are supported. Resolution is deterministic and doesn't
+// require any network access.
+type KeyDIDResolver struct{}
+
+var _ DIDResolver = (*KeyDIDResolver)(nil)
+
+func init() {
+	RegisterDIDResolver(DIDMethodKey, &KeyDIDResolver{})
+}
+
+func (r *KeyDIDResolver) ResolveDIDDocument(_ context.Context, id string) (*DIDDocument, error) {
+	verKey, err := ExtractKeyDIDPublicKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := strings.TrimPrefix(id, didKeyPrefix)
+	keyID := id + "#" + fingerprint
+
+	return &DIDDocument{
+		Context: []string{"https://w3id.org/did/v1", "https://w3id.org/security/v1"},
+		ID:      id,
+		PublicKey: []any{
+			&Ed25519VerificationKey2018{
+				ID:              keyID,
+				Type:            Ed25519VerificationKey2018Type,
+				Controller:      id,
+				PublicKeyBase58: base58.Encode(verKey),
+			},
+		},
+		Authentication: []any{keyID},
+	}, nil
+}
+
+// BuildKeyDID returns a did:key identifier for the given Ed25519 public key.
+func BuildKeyDID(verKey ed25519.PublicKey) string {
+	return didKeyPrefix + encodeEd25519Multibase(verKey)
+}
+
+// ExtractKeyDIDPublicKey returns the Ed25519 public key encoded in the given did:key identifier.
+func ExtractKeyDIDPublicKey(id string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(id, didKeyPrefix) {
+		return nil, ErrInvalidDID
+	}
+
+	return decodeEd25519Multibase(strings.TrimPrefix(id, didKeyPrefix))
+}
+
+func encodeEd25519Multibase(key ed25519.PublicKey) string {
+	return string(multibaseBase58BTC) + base58.Encode(append(append([]byte{}, ed25519PubMulticodec...), key...))
+}
+
+func decodeEd25519Multibase(val string) (ed25519.PublicKey, error) {
+	if val == "" || val[0] != multibaseBase58BTC {
+		return nil, fmt.Errorf("%w: unsupported multibase encoding", ErrInvalidDID)
+	}
+
+	b := base58.Decode(val[1:])
+	if len(b) != len(ed25519PubMulticodec)+ed25519.PublicKeySize || !bytes.HasPrefix(b, ed25519PubMulticodec) {
+		return nil, fmt.Errorf("%w: not an Ed25519 public key", ErrInvalidDID)
+	}
+
+	return b[len(ed25519PubMulticodec):], nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrDIDMethodNotSupported = errors.New("DID method not supported")
+	ErrDIDDocumentNotFound   = errors.New("DID document not found")
+)
+
+type (
+	// DIDResolver resolves DIDs of a specific method into DID documents.
+	// Unlike DIDProvider, which is backed by MetaLocker's own DID store,
+	// resolvers follow the rules of their DID method (see https://www.w3.org/TR/did-core/#resolution).
+	DIDResolver interface {
+		ResolveDIDDocument(ctx context.Context, id string) (*DIDDocument, error)
+	}
+)
+
+var (
+	didResolvers   = make(map[string]DIDResolver)
+	didResolverMtx sync.RWMutex
+)
+
+// RegisterDIDResolver registers a resolver for the given DID method
+// (e.g. 'key' for did:key identifiers).
+func RegisterDIDResolver(method string, resolver DIDResolver) {
+	didResolverMtx.Lock()
+	defer didResolverMtx.Unlock()
+
+	if _, ok := didResolvers[method]; ok {
+		panic("DID resolver already registered for method: " + method)
+	}
+
+	didResolvers[method] = resolver
+}
+
+// GetDIDResolver returns the resolver registered for the given DID method.
+func GetDIDResolver(method string) (DIDResolver, bool) {
+	didResolverMtx.RLock()
+	defer didResolverMtx.RUnlock()
+
+	resolver, ok := didResolvers[method]
+	return resolver, ok
+}
+
+// ResolveDIDDocument resolves the given DID using the resolver registered
+// for its method. It returns ErrDIDMethodNotSupported if no resolver
+// is registered for the DID's method.
+func ResolveDIDDocument(ctx context.Context, id string) (*DIDDocument, error) {
+	method, err := ExtractDIDMethod(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver, ok := GetDIDResolver(method)
+	if !ok {
+		return nil, ErrDIDMethodNotSupported
+	}
+
+	return resolver.ResolveDIDDocument(ctx, id)
+}
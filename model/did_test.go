@@ -15,7 +15,10 @@
 package model_test
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -239,3 +242,141 @@ func TestDIDDocument_ExtractIndyStyleDID(t *testing.T) {
 	_, err = didDoc.ExtractIndyStyleDID()
 	assert.Error(t, err)
 }
+
+func TestGenerateDID_KeyMethod(t *testing.T) {
+	did, err := GenerateDID(WithSeed("Steward1"), WithMethod(DIDMethodKey))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(did.ID, "did:key:z6Mk"))
+	assert.Equal(t, "FYmoFw55GeQH7SRFa37dkx1d2dZ3zUF8ckg7wmL7ofN4", did.VerKey)
+	assert.NoError(t, did.Validate())
+
+	verKey, err := ExtractKeyDIDPublicKey(did.ID)
+	require.NoError(t, err)
+	assert.Equal(t, did.VerKey, base58.Encode(verKey))
+
+	// key mismatch
+
+	anotherDID, err := GenerateDID()
+	require.NoError(t, err)
+	assert.ErrorIs(t, NewDID(did.ID, anotherDID.VerKey, "").Validate(), ErrInvalidDID)
+}
+
+func TestGenerateDID_WebMethod(t *testing.T) {
+	did, err := GenerateDID(WithMethod(DIDMethodWeb), WithWebHost("example.com:users:alice"))
+	require.NoError(t, err)
+	assert.Equal(t, "did:web:example.com:users:alice", did.ID)
+	assert.NoError(t, did.Validate())
+
+	_, err = GenerateDID(WithMethod(DIDMethodWeb))
+	assert.ErrorIs(t, err, ErrInvalidDID)
+}
+
+func TestKeyDIDResolver_ResolveDIDDocument(t *testing.T) {
+	// test vector from https://w3c-ccg.github.io/did-method-key/
+	id := "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"
+
+	ddoc, err := ResolveDIDDocument(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, id, ddoc.ID)
+
+	did, err := ddoc.ExtractIndyStyleDID()
+	require.NoError(t, err)
+	assert.Equal(t, id, did.ID)
+
+	verKey, err := ExtractKeyDIDPublicKey(id)
+	require.NoError(t, err)
+	assert.Equal(t, base58.Encode(verKey), did.VerKey)
+	assert.Equal(t, id, BuildKeyDID(verKey))
+
+	_, err = ResolveDIDDocument(context.Background(), "did:key:xyz")
+	assert.ErrorIs(t, err, ErrInvalidDID)
+}
+
+func TestResolveDIDDocument_UnsupportedMethod(t *testing.T) {
+	_, err := ResolveDIDDocument(context.Background(), "did:unknown:xyz")
+	assert.ErrorIs(t, err, ErrDIDMethodNotSupported)
+
+	_, err = ResolveDIDDocument(context.Background(), "xyz")
+	assert.ErrorIs(t, err, ErrInvalidDID)
+}
+
+func TestRegisterDIDResolver_Duplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterDIDResolver(DIDMethodKey, &KeyDIDResolver{})
+	})
+}
+
+func TestWebDIDDocumentURL(t *testing.T) {
+	u, err := WebDIDDocumentURL("did:web:w3c-ccg.github.io")
+	require.NoError(t, err)
+	assert.Equal(t, "https://w3c-ccg.github.io/.well-known/did.json", u)
+
+	u, err = WebDIDDocumentURL("did:web:w3c-ccg.github.io:user:alice")
+	require.NoError(t, err)
+	assert.Equal(t, "https://w3c-ccg.github.io/user/alice/did.json", u)
+
+	u, err = WebDIDDocumentURL("did:web:example.com%3A3000:user:alice")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com:3000/user/alice/did.json", u)
+
+	_, err = WebDIDDocumentURL("did:web:")
+	assert.ErrorIs(t, err, ErrInvalidDID)
+
+	_, err = WebDIDDocumentURL("did:web:example.com:..")
+	assert.ErrorIs(t, err, ErrInvalidDID)
+
+	_, err = WebDIDDocumentURL("did:key:example.com")
+	assert.ErrorIs(t, err, ErrInvalidDID)
+}
+
+func TestWebDIDResolver_ResolveDIDDocument(t *testing.T) {
+	did, err := GenerateDID(WithSeed("Steward1"), WithMethod(DIDMethodKey))
+	require.NoError(t, err)
+	mbKey := strings.TrimPrefix(did.ID, "did:key:")
+
+	var docID string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/alice/did.json":
+			w.Header().Set("Content-Type", "application/did+json")
+			_, _ = fmt.Fprintf(w, `{
+  "@context": ["https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/ed25519-2020/v1"],
+  "id": %q,
+  "verificationMethod": [{
+    "id": "%s#key-1",
+    "type": "Ed25519VerificationKey2020",
+    "controller": %q,
+    "publicKeyMultibase": %q
+  }]
+}`, docID, docID, docID, mbKey)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	host := strings.ReplaceAll(strings.TrimPrefix(srv.URL, "https://"), ":", "%3A")
+	docID = "did:web:" + host + ":users:alice"
+
+	resolver := NewWebDIDResolver(srv.Client())
+
+	ddoc, err := resolver.ResolveDIDDocument(context.Background(), docID)
+	require.NoError(t, err)
+	assert.Equal(t, docID, ddoc.ID)
+
+	webDID, err := ddoc.ExtractIndyStyleDID()
+	require.NoError(t, err)
+	assert.Equal(t, docID, webDID.ID)
+	assert.Equal(t, did.VerKey, webDID.VerKey)
+
+	// document not found
+
+	_, err = resolver.ResolveDIDDocument(context.Background(), "did:web:"+host+":users:bob")
+	assert.ErrorIs(t, err, ErrDIDDocumentNotFound)
+
+	// ID mismatch
+
+	docID = "did:web:example.com"
+	_, err = resolver.ResolveDIDDocument(context.Background(), "did:web:"+host+":users:alice")
+	assert.Error(t, err)
+}
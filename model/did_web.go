@@ -0,0 +1,135 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	DIDMethodWeb = "web"
+
+	didWebPrefix = "did:web:"
+
+	// maxWebDIDDocumentSize limits the size of DID documents fetched by WebDIDResolver.
+	maxWebDIDDocumentSize = 1 << 20
+)
+
+// WebDIDResolver resolves did:web identifiers (https://w3c-ccg.github.io/did-method-web/)
+// by fetching DID documents over HTTPS.
+type WebDIDResolver struct {
+	client *http.Client
+}
+
+var _ DIDResolver = (*WebDIDResolver)(nil)
+
+func init() {
+	RegisterDIDResolver(DIDMethodWeb, NewWebDIDResolver(nil))
+}
+
+// NewWebDIDResolver returns a did:web resolver that uses the given HTTP client.
+// If client is nil, http.DefaultClient is used.
+func NewWebDIDResolver(client *http.Client) *WebDIDResolver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebDIDResolver{
+		client: client,
+	}
+}
+
+func (r *WebDIDResolver) ResolveDIDDocument(ctx context.Context, id string) (*DIDDocument, error) {
+	docURL, err := WebDIDDocumentURL(id)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, docURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/did+json, application/json")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, ErrDIDDocumentNotFound
+	default:
+		return nil, fmt.Errorf("DID document retrieval from %s failed with status code %d", docURL, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, maxWebDIDDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxWebDIDDocumentSize {
+		return nil, errors.New("DID document too large")
+	}
+
+	var ddoc DIDDocument
+	if err = jsonw.Unmarshal(b, &ddoc); err != nil {
+		return nil, err
+	}
+
+	if ddoc.ID != id {
+		return nil, fmt.Errorf("DID document ID mismatch: expected %s, found %s", id, ddoc.ID)
+	}
+
+	return &ddoc, nil
+}
+
+// WebDIDDocumentURL converts a did:web identifier into the URL of its DID document.
+// For example, did:web:example.com resolves to https://example.com/.well-known/did.json
+// and did:web:example.com%3A8443:users:alice resolves to https://example.com:8443/users/alice/did.json.
+func WebDIDDocumentURL(id string) (string, error) {
+	if !strings.HasPrefix(id, didWebPrefix) {
+		return "", ErrInvalidDID
+	}
+
+	segments := strings.Split(strings.TrimPrefix(id, didWebPrefix), ":")
+
+	host, err := url.PathUnescape(segments[0])
+	if err != nil || host == "" || strings.ContainsAny(host, "/?#@") {
+		return "", fmt.Errorf("%w: bad did:web domain", ErrInvalidDID)
+	}
+
+	path := "/.well-known"
+	if len(segments) > 1 {
+		path = ""
+		for _, s := range segments[1:] {
+			p, err := url.PathUnescape(s)
+			if err != nil || p == "" || p == "." || p == ".." || strings.Contains(p, "/") {
+				return "", fmt.Errorf("%w: bad did:web path", ErrInvalidDID)
+			}
+			path += "/" + url.PathEscape(p)
+		}
+	}
+
+	return "https://" + host + path + "/did.json", nil
+}
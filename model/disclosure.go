@@ -0,0 +1,270 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+const (
+	MetaDisclosureType = "MetaDisclosure"
+
+	disclosureSeedLength = 32
+	disclosureSaltTag    = "meta disclosure salt"
+	disclosureLeafTag    = "meta disclosure leaf"
+)
+
+var (
+	// ErrDisclosureNotSupported indicates the dataset's meta resource wasn't committed
+	// to a disclosure root when the dataset was created
+	ErrDisclosureNotSupported = errors.New("meta resource doesn't support selective disclosure")
+	// ErrInvalidDisclosure indicates the disclosed properties don't match the signed impression
+	ErrInvalidDisclosure = errors.New("invalid meta resource disclosure")
+)
+
+type (
+	// DisclosedProperty proves that a top-level property of the disclosed meta resource
+	// belongs to the original meta resource.
+	DisclosedProperty struct {
+		Name  string             `json:"name"`
+		Salt  string             `json:"salt"`
+		Index int                `json:"index"`
+		Path  []*MerkleProofStep `json:"path,omitempty"`
+	}
+
+	// MetaDisclosure is a proof that a subset of the meta resource's top-level properties
+	// belongs to the meta resource of the original signed impression. Every property
+	// of the meta resource is a salted leaf of a Merkle tree. The tree's root is
+	// included into the impression's MetaResource and signed along with it, so a subset
+	// of properties can be revealed without exposing the rest of the meta resource.
+	MetaDisclosure struct {
+		Type       string               `json:"type"`
+		Impression *Impression          `json:"impression"`
+		Properties []*DisclosedProperty `json:"properties"`
+	}
+)
+
+// NewDisclosureSeed generates a random seed for deriving property salts.
+func NewDisclosureSeed() (string, error) {
+	seed := make([]byte, disclosureSeedLength)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	return base58.Encode(seed), nil
+}
+
+// DisclosureRoot computes the Merkle root over the top-level properties of the given
+// meta resource (a JSON object), using salts derived from the seed.
+func DisclosureRoot(meta []byte, seed string) (string, error) {
+	props, err := canonicalProperties(meta)
+	if err != nil {
+		return "", err
+	}
+
+	names := sortedNames(props)
+	level := make([][]byte, len(names))
+	for i, name := range names {
+		level[i] = disclosureLeaf(name, props[name], disclosureSalt(seed, name))
+	}
+
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+
+	return base64.StdEncoding.EncodeToString(level[0]), nil
+}
+
+// NewMetaDisclosure builds a disclosure of the given properties of the meta resource
+// behind the impression. It returns the proof and the disclosed meta resource that contains
+// the requested properties only. The JSON-LD context is always disclosed.
+func NewMetaDisclosure(imp *Impression, meta []byte, seed string, properties []string) (*MetaDisclosure, []byte, error) {
+	if imp.MetaResource == nil || imp.MetaResource.DisclosureRoot == "" || seed == "" {
+		return nil, nil, ErrDisclosureNotSupported
+	}
+
+	props, err := canonicalProperties(meta)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := sortedNames(props)
+	level := make([][]byte, len(names))
+	indexes := make(map[string]int, len(names))
+	for i, name := range names {
+		level[i] = disclosureLeaf(name, props[name], disclosureSalt(seed, name))
+		indexes[name] = i
+	}
+
+	disclosed := make(map[string]json.RawMessage)
+	proof := &MetaDisclosure{
+		Type:       MetaDisclosureType,
+		Impression: imp,
+	}
+
+	if _, found := props["@context"]; found {
+		properties = append([]string{"@context"}, properties...)
+	}
+
+	for _, name := range properties {
+		if _, found := disclosed[name]; found {
+			continue
+		}
+		idx, found := indexes[name]
+		if !found {
+			return nil, nil, fmt.Errorf("property not found in meta resource: %s", name)
+		}
+
+		disclosed[name] = props[name]
+		proof.Properties = append(proof.Properties, &DisclosedProperty{
+			Name:  name,
+			Salt:  base64.StdEncoding.EncodeToString(disclosureSalt(seed, name)),
+			Index: idx,
+			Path:  merklePath(level, idx),
+		})
+	}
+
+	sort.Slice(proof.Properties, func(i, j int) bool {
+		return proof.Properties[i].Index < proof.Properties[j].Index
+	})
+
+	b, err := json.Marshal(disclosed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return proof, b, nil
+}
+
+// Verify checks the impression's signature with the given key and verifies that
+// every property of the disclosed meta resource belongs to the original meta resource.
+func (md *MetaDisclosure) Verify(disclosed []byte, key ed25519.PublicKey) error {
+	if md.Impression == nil {
+		return fmt.Errorf("%w: impression not found", ErrInvalidDisclosure)
+	}
+
+	verified, err := md.Impression.MerkleVerify(key)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return fmt.Errorf("%w: impression signature verification failed", ErrInvalidDisclosure)
+	}
+
+	if md.Impression.MetaResource == nil || md.Impression.MetaResource.DisclosureRoot == "" {
+		return ErrDisclosureNotSupported
+	}
+
+	props, err := canonicalProperties(disclosed)
+	if err != nil {
+		return err
+	}
+
+	if len(props) != len(md.Properties) {
+		return fmt.Errorf("%w: property count mismatch", ErrInvalidDisclosure)
+	}
+
+	for _, p := range md.Properties {
+		val, found := props[p.Name]
+		if !found {
+			return fmt.Errorf("%w: property %s not disclosed", ErrInvalidDisclosure, p.Name)
+		}
+
+		salt, err := base64.StdEncoding.DecodeString(p.Salt)
+		if err != nil {
+			return err
+		}
+
+		node := disclosureLeaf(p.Name, val, salt)
+		for _, step := range p.Path {
+			sibling, err := base64.StdEncoding.DecodeString(step.Hash)
+			if err != nil {
+				return err
+			}
+			if step.Left {
+				node = merkleNode(sibling, node)
+			} else {
+				node = merkleNode(node, sibling)
+			}
+		}
+
+		if base64.StdEncoding.EncodeToString(node) != md.Impression.MetaResource.DisclosureRoot {
+			return fmt.Errorf("%w: property %s doesn't match disclosure root", ErrInvalidDisclosure, p.Name)
+		}
+	}
+
+	return nil
+}
+
+func (md *MetaDisclosure) Bytes() []byte {
+	b, _ := json.Marshal(md)
+	return b
+}
+
+// canonicalProperties returns the top-level properties of the given JSON object
+// in compact form with sorted keys.
+func canonicalProperties(meta []byte) (map[string]json.RawMessage, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(meta, &raw); err != nil {
+		return nil, fmt.Errorf("meta resource is not a JSON object: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("meta resource has no properties")
+	}
+
+	props := make(map[string]json.RawMessage, len(raw))
+	for name, val := range raw {
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(val))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		props[name] = b
+	}
+
+	return props, nil
+}
+
+func sortedNames(props map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func disclosureSalt(seed, name string) []byte {
+	return Hash(disclosureSaltTag, append(base58.Decode(seed), name...))
+}
+
+func disclosureLeaf(name string, val json.RawMessage, salt []byte) []byte {
+	// name and value are encoded as a JSON array to keep the leaf unambiguous
+	b, _ := json.Marshal([]any{name, val})
+	return Hash(disclosureLeafTag, append(append([]byte{}, salt...), b...))
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/piprate/metalocker/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaDisclosure(t *testing.T) {
+	meta := []byte(`{
+		"@context": {"@vocab": "http://schema.org/"},
+		"type": "Person",
+		"name": "John Doe",
+		"birthDate": "1970-01-01",
+		"address": {"streetAddress": "1 Main St", "addressLocality": "Dublin"},
+		"height": 1.80
+	}`)
+
+	seed, err := NewDisclosureSeed()
+	require.NoError(t, err)
+
+	root, err := DisclosureRoot(meta, seed)
+	require.NoError(t, err)
+
+	did, err := GenerateDID(WithSeed("Test0001"))
+	require.NoError(t, err)
+
+	imp := NewBlankImpression()
+	imp.Asset = "did:piprate:test"
+	imp.MetaResource = &MetaResource{
+		Asset:          "meta",
+		ContentType:    "Person",
+		DisclosureRoot: root,
+	}
+	require.NoError(t, imp.MerkleSign(did.ID, did.SignKeyValue()))
+
+	proof, disclosed, err := NewMetaDisclosure(imp, meta, seed, []string{"type", "name", "height"})
+	require.NoError(t, err)
+
+	var props map[string]any
+	require.NoError(t, json.Unmarshal(disclosed, &props))
+	assert.Len(t, props, 4)
+	assert.Equal(t, "John Doe", props["name"])
+	assert.NotContains(t, props, "birthDate")
+	assert.Contains(t, props, "@context")
+
+	// the proof survives serialisation
+
+	var restored MetaDisclosure
+	require.NoError(t, json.Unmarshal(proof.Bytes(), &restored))
+	require.NoError(t, restored.Verify(disclosed, did.VerKeyValue()))
+
+	// the proof doesn't reveal undisclosed properties
+
+	assert.NotContains(t, string(proof.Bytes()), "1970-01-01")
+
+	// tampered value
+
+	tampered := []byte(`{"@context": {"@vocab": "http://schema.org/"}, "type": "Person", "name": "Jane Doe", "height": 1.80}`)
+	assert.ErrorIs(t, proof.Verify(tampered, did.VerKeyValue()), ErrInvalidDisclosure)
+
+	// property without proof
+
+	extra := []byte(`{"@context": {"@vocab": "http://schema.org/"}, "type": "Person", "name": "John Doe", "height": 1.80, "birthDate": "1970-01-01"}`)
+	assert.ErrorIs(t, proof.Verify(extra, did.VerKeyValue()), ErrInvalidDisclosure)
+
+	// wrong signer
+
+	otherDID, err := GenerateDID(WithSeed("Test0002"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, proof.Verify(disclosed, otherDID.VerKeyValue()), ErrInvalidDisclosure)
+
+	// the disclosure root is covered by the impression's signature
+
+	forged := imp.Copy()
+	forged.MetaResource.DisclosureRoot = "Zm9yZ2Vk"
+	ok, err := forged.MerkleVerify(did.VerKeyValue())
+	assert.False(t, ok && err == nil)
+
+	// unknown property
+
+	_, _, err = NewMetaDisclosure(imp, meta, seed, []string{"email"})
+	assert.Error(t, err)
+
+	// impression without disclosure root
+
+	imp2 := NewBlankImpression()
+	imp2.MetaResource = &MetaResource{Asset: "meta"}
+	_, _, err = NewMetaDisclosure(imp2, meta, seed, []string{"name"})
+	assert.ErrorIs(t, err, ErrDisclosureNotSupported)
+}
@@ -21,7 +21,7 @@ import (
 )
 
 func HeadID(assetID string, lockerID string, sender *LockerParticipant, headName string) string {
-	data := strings.Join([]string{assetID, lockerID, sender.SharedSecret}, "|")
+	data := strings.Join([]string{assetID, lockerID, sender.InitialSharedSecret()}, "|")
 	return base58.Encode(Hash(headName, []byte(data)))
 }
 
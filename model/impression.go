@@ -38,6 +38,10 @@ type MetaResource struct {
 	Fingerprint string `json:"fingerprint,omitempty"`
 	// FingerprintAlgorithm is the Fingerprint's algorithm.
 	FingerprintAlgorithm string `json:"fingerprintAlgorithm,omitempty"`
+	// DisclosureRoot is the Merkle root over salted top-level properties of the meta resource.
+	// If present, a subset of the meta resource's properties can be disclosed
+	// with a proof that they belong to this impression (see MetaDisclosure).
+	DisclosureRoot string `json:"disclosureRoot,omitempty"`
 }
 
 // Impression is a semantic definition of a dataset that contains verifiable information
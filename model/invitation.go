@@ -0,0 +1,170 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	LockerInvitationType = "LockerInvitation"
+)
+
+var (
+	// ErrLockerInvitationInvalid indicates the invitation is malformed, isn't addressed
+	// to the given DID or has an invalid signature.
+	ErrLockerInvitationInvalid = errors.New("invalid locker invitation")
+)
+
+// LockerInvitation is an offer to join a locker, sent by one of the locker's participants
+// to another participant. The invitation is encrypted with the recipient's verification key
+// (see SealLockerInvitation) and published on the ledger as an OpTypeLockerInvitation
+// record with a routing key derived from the recipient's invitation inbox key
+// (see BuildInvitationInboxKey).
+type LockerInvitation struct {
+	// ID is the ID of the ledger record that delivered the invitation. It isn't covered
+	// by the signature.
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+	// Sender is the DID of the participant who sent the invitation.
+	Sender string `json:"sender"`
+	// SenderVerKey is the sender's public Ed25519 key in base58 encoding.
+	SenderVerKey string `json:"senderVerKey"`
+	// Recipient is the DID of the invited participant.
+	Recipient string `json:"recipient"`
+	// Locker is the locker definition from the recipient's perspective.
+	Locker *Locker `json:"locker"`
+	// Message is an optional human-readable message from the sender.
+	Message string     `json:"message,omitempty"`
+	Created *time.Time `json:"created"`
+	// Signature is a base58-encoded Ed25519 signature of the invitation by the sender.
+	Signature string `json:"signature,omitempty"`
+}
+
+// NewLockerInvitation creates a signed invitation for the locker participant with
+// the given recipient ID. The sender should be a participant of the locker.
+func NewLockerInvitation(sender *DID, recipientID string, locker *Locker, message string) (*LockerInvitation, error) {
+	if locker.GetParticipant(sender.ID) == nil {
+		return nil, fmt.Errorf("%w: sender %s isn't a locker participant", ErrLockerInvitationInvalid, sender.ID)
+	}
+	if sender.ID == recipientID {
+		return nil, fmt.Errorf("%w: sender and recipient are the same", ErrLockerInvitationInvalid)
+	}
+	recipient := locker.GetParticipant(recipientID)
+	if recipient == nil {
+		return nil, fmt.Errorf("%w: recipient %s isn't a locker participant", ErrLockerInvitationInvalid, recipientID)
+	}
+	if recipient.RootPrivateKeyEnc == "" {
+		// only the participant who generated the recipient's keys can invite them
+		return nil, fmt.Errorf("%w: recipient's encrypted root key not available", ErrLockerInvitationInvalid)
+	}
+
+	now := time.Now().UTC()
+	inv := &LockerInvitation{
+		Type:         LockerInvitationType,
+		Sender:       sender.ID,
+		SenderVerKey: sender.VerKey,
+		Recipient:    recipientID,
+		Locker:       locker.Perspective(recipientID),
+		Message:      message,
+		Created:      &now,
+	}
+
+	inv.Signature = base58.Encode(ed25519.Sign(sender.SignKeyValue(), inv.signingBytes()))
+
+	return inv, nil
+}
+
+func (inv *LockerInvitation) signingBytes() []byte {
+	cp := *inv
+	cp.ID = ""
+	cp.Signature = ""
+	b, _ := jsonw.Marshal(&cp)
+	return b
+}
+
+// Verify checks the invitation's structure and signature. It doesn't check
+// that the sender's verification key belongs to the sender's DID.
+func (inv *LockerInvitation) Verify() error {
+	if inv.Type != LockerInvitationType || inv.Locker == nil || inv.Sender == inv.Recipient {
+		return ErrLockerInvitationInvalid
+	}
+
+	if s := inv.Locker.GetParticipant(inv.Sender); s == nil || s.Self {
+		return ErrLockerInvitationInvalid
+	}
+	if r := inv.Locker.GetParticipant(inv.Recipient); r == nil || !r.Self || r.RootPrivateKeyEnc == "" {
+		return ErrLockerInvitationInvalid
+	}
+
+	verKey := base58.Decode(inv.SenderVerKey)
+	if len(verKey) != ed25519.PublicKeySize {
+		return ErrLockerInvitationInvalid
+	}
+	if !ed25519.Verify(verKey, inv.signingBytes(), base58.Decode(inv.Signature)) {
+		return ErrLockerInvitationInvalid
+	}
+
+	return nil
+}
+
+// SealLockerInvitation encrypts the invitation with the recipient's verification key.
+func SealLockerInvitation(inv *LockerInvitation, recipientVerKey ed25519.PublicKey) ([]byte, error) {
+	b, err := jsonw.Marshal(inv)
+	if err != nil {
+		return nil, err
+	}
+	return AnonEncrypt(b, recipientVerKey), nil
+}
+
+// OpenLockerInvitation decrypts the invitation using the recipient's signing key
+// and verifies it.
+func OpenLockerInvitation(data []byte, recipientSignKey ed25519.PrivateKey) (*LockerInvitation, error) {
+	b, err := AnonDecrypt(data, recipientSignKey)
+	if err != nil {
+		return nil, ErrLockerInvitationInvalid
+	}
+
+	var inv LockerInvitation
+	if err = jsonw.Unmarshal(b, &inv); err != nil {
+		return nil, ErrLockerInvitationInvalid
+	}
+
+	if err = inv.Verify(); err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+// BuildInvitationInboxKey returns the root HD key of the given DID's invitation inbox.
+// The key is derived from the DID's verification key, so that any party that knows the DID
+// can send invitations to it and the DID owner can find them on the ledger.
+// Since the key is public, inbox records only provide delivery, not confidentiality
+// or authenticity. These are ensured by the invitation's encryption and signature.
+func BuildInvitationInboxKey(did *DID) (*hdkeychain.ExtendedKey, *hdkeychain.ExtendedKey, error) {
+	verKey := did.VerKeyValue()
+	if len(verKey) == 0 {
+		return nil, nil, fmt.Errorf("DID %s doesn't have a verkey", did.ID)
+	}
+	return GenerateNewHDKey(Hash("locker invitation inbox", verKey))
+}
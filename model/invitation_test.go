@@ -0,0 +1,95 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model_test
+
+import (
+	"testing"
+
+	. "github.com/piprate/metalocker/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockerInvitation(t *testing.T) {
+	did1, err := GenerateDID(WithSeed("Test0001"))
+	require.NoError(t, err)
+	did2, err := GenerateDID(WithSeed("Test0002"))
+	require.NoError(t, err)
+	did3, err := GenerateDID(WithSeed("Test0003"))
+	require.NoError(t, err)
+
+	locker, err := GenerateLocker(AccessLevelHosted, "Test Locker", nil, 123,
+		Us(did1, nil),
+		Them(did2, nil))
+	require.NoError(t, err)
+
+	_, err = NewLockerInvitation(did1, did3.ID, locker, "")
+	require.ErrorIs(t, err, ErrLockerInvitationInvalid)
+
+	_, err = NewLockerInvitation(did3, did2.ID, locker, "")
+	require.ErrorIs(t, err, ErrLockerInvitationInvalid)
+
+	// the recipient's perspective doesn't contain the sender's root key,
+	// so the recipient can't re-invite the sender.
+	_, err = NewLockerInvitation(did2, did1.ID, locker.Perspective(did2.ID), "")
+	require.ErrorIs(t, err, ErrLockerInvitationInvalid)
+
+	inv, err := NewLockerInvitation(did1, did2.ID, locker, "Hello")
+	require.NoError(t, err)
+	require.NoError(t, inv.Verify())
+
+	data, err := SealLockerInvitation(inv, did2.VerKeyValue())
+	require.NoError(t, err)
+
+	_, err = OpenLockerInvitation(data, did3.SignKeyValue())
+	require.ErrorIs(t, err, ErrLockerInvitationInvalid)
+
+	openedInv, err := OpenLockerInvitation(data, did2.SignKeyValue())
+	require.NoError(t, err)
+
+	assert.Equal(t, did1.ID, openedInv.Sender)
+	assert.Equal(t, did2.ID, openedInv.Recipient)
+	assert.Equal(t, "Hello", openedInv.Message)
+	assert.Equal(t, locker.ID, openedInv.Locker.ID)
+	assert.Equal(t, did2.ID, openedInv.Locker.Us().ID)
+
+	require.NoError(t, openedInv.Locker.Hydrate(did2.SignKeyValue()))
+	assert.True(t, openedInv.Locker.IsHydrated())
+
+	// tampered invitation
+
+	openedInv.Message = "Goodbye"
+	require.ErrorIs(t, openedInv.Verify(), ErrLockerInvitationInvalid)
+}
+
+func TestBuildInvitationInboxKey(t *testing.T) {
+	did1, err := GenerateDID(WithSeed("Test0001"))
+	require.NoError(t, err)
+	did2, err := GenerateDID(WithSeed("Test0002"))
+	require.NoError(t, err)
+
+	_, pub1, err := BuildInvitationInboxKey(did1)
+	require.NoError(t, err)
+	_, pub1Copy, err := BuildInvitationInboxKey(did1.NeuteredCopy())
+	require.NoError(t, err)
+	_, pub2, err := BuildInvitationInboxKey(did2)
+	require.NoError(t, err)
+
+	assert.Equal(t, pub1.String(), pub1Copy.String())
+	assert.NotEqual(t, pub1.String(), pub2.String())
+
+	_, _, err = BuildInvitationInboxKey(&DID{ID: "did:piprate:123"})
+	require.Error(t, err)
+}
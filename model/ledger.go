@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 )
 
 var (
@@ -94,6 +95,38 @@ type (
 		// GetAssetHead returns the record of type = head that defines the current asset head for the given ID.
 		GetAssetHead(ctx context.Context, headID string) (*Record, error)
 	}
+
+	// RecordInclusionProver is an optional Ledger extension that provides
+	// Merkle inclusion proofs for ledger records.
+	RecordInclusionProver interface {
+		// GetRecordInclusionProof returns a proof that the given record is included
+		// into the block it was published in. Returns ErrRecordNotFound error
+		// if the record was not found or hasn't been published yet.
+		GetRecordInclusionProof(ctx context.Context, rid string) (*RecordInclusionProof, error)
+	}
+
+	// LeaseExpiryEnforcer is an optional Ledger extension for ledgers that track
+	// lease expiry times published in ledger records (see Record.ExpiresAt).
+	LeaseExpiryEnforcer interface {
+		// GetExpiredRecords returns up to 'limit' IDs of lease records that expired
+		// before the given time, but haven't been marked as expired yet.
+		GetExpiredRecords(ctx context.Context, now time.Time, limit int) ([]string, error)
+		// ExpireRecord marks the given lease record as expired and releases
+		// its data assets, as if the lease was revoked. Returns false if the record
+		// wasn't expired (for example, if it was revoked before its expiry time).
+		ExpireRecord(ctx context.Context, rid string, now time.Time) (bool, error)
+	}
+
+	// LedgerCursorStore is an optional Ledger extension for ledgers that can persist
+	// positions of the background processes that scan the ledger (for example,
+	// the lease sweeper), so that they can resume after a restart.
+	LedgerCursorStore interface {
+		// GetCursor returns the number of the last block processed by the named
+		// process, or -1 if the cursor wasn't saved yet.
+		GetCursor(ctx context.Context, name string) (int64, error)
+		// SetCursor saves the number of the last block processed by the named process.
+		SetCursor(ctx context.Context, name string, blockNumber int64) error
+	}
 )
 
 const (
@@ -38,7 +38,40 @@ const (
 	RequestingCommitmentTag = "requesting commitment"
 )
 
+var (
+	ErrParticipantExists  = errors.New("locker participant already exists")
+	ErrParticipantInvalid = errors.New("invalid locker participant")
+	ErrKeyEpochNotFound   = errors.New("locker key epoch not found")
+	ErrLockerSealed       = errors.New("locker is sealed")
+)
+
 type (
+	// lockerKeys holds hydrated key material for one generation of participant's locker keys.
+	lockerKeys struct {
+		rootKeyPriv       *hdkeychain.ExtendedKey
+		rootKeyPub        *hdkeychain.ExtendedKey
+		sharedSecretBytes []byte
+	}
+
+	// LockerKeyEpoch is a retired generation of participant's locker keys. When a participant
+	// rotates its keys, the previous keys are kept as an epoch, so that the records created
+	// before the rotation remain readable.
+	LockerKeyEpoch struct {
+		// SharedSecret is a Base64-encoded secret used to encrypt operations during this epoch
+		SharedSecret string `json:"sharedSecret"`
+		// RootPublicKey is a Base64-encoded root public key for the records issued during this epoch.
+		RootPublicKey string `json:"rootPublicKey"`
+		// RootPrivateKeyEnc is a Base64-encoded, encrypted root HD key for this epoch.
+		RootPrivateKeyEnc string `json:"encryptedRootPrivateKey,omitempty"`
+		// StartBlock is the block number after which the epoch's keys came into use.
+		StartBlock int64 `json:"startBlock"`
+		// EndBlock is the last block that may contain records issued with the epoch's keys.
+		// Any records with these keys in later blocks are ignored.
+		EndBlock int64 `json:"endBlock"`
+
+		lockerKeys
+	}
+
 	// LockerParticipant is a definition of locker participant. It contains sensitive secrets, such as SharedSecret,
 	// and should be stored securely.
 	LockerParticipant struct {
@@ -55,14 +88,19 @@ type (
 		// AcceptedAtBlock is the number of the block when the locker was accepted by the party
 		// and registered in its root locker.
 		AcceptedAtBlock int64 `json:"acceptedAtBlock,omitempty"`
+		// EpochStartBlock is the block number after which the current keys (SharedSecret, RootPublicKey
+		// and RootPrivateKeyEnc) came into use. It's zero if the participant never rotated its keys.
+		EpochStartBlock int64 `json:"epochStartBlock,omitempty"`
+		// PreviousEpochs is a list of the participant's retired keys, from the oldest to the newest.
+		PreviousEpochs []*LockerKeyEpoch `json:"previousEpochs,omitempty"`
 
-		rootKeyPriv       *hdkeychain.ExtendedKey
-		rootKeyPub        *hdkeychain.ExtendedKey
-		sharedSecretBytes []byte
+		lockerKeys
 	}
 
 	// Locker is a secure, persistent, bidirectional communication channel between two or more participants.
-	// A special type of locker with just one participant is called a uni-locker.
+	// A special type of locker with just one participant is called a uni-locker. Lockers with more than
+	// two participants are called multi-party (group) lockers. Every participant has its own root HD key
+	// and shared secret, so any participant can read and write records in the locker.
 	Locker struct {
 		// ID is the unique locker ID.
 		ID string `json:"id"`
@@ -78,14 +116,14 @@ type (
 		Created *time.Time `json:"created"`
 		// Expires is the time when the locker will expire. NOT SUPPORTED.
 		Expires *time.Time `json:"expires,omitempty"`
-		// Sealed is the time when the locker was sealed (closed). NOT SUPPORTED.
+		// Sealed is the time when the locker was sealed (closed). No new records can be added
+		// to a sealed locker.
 		Sealed *time.Time `json:"sealed,omitempty"`
 		// FirstBlock is the block number that was the height of the chain when the locker was created.
 		// It is guaranteed that all records for this locker will be in blocks AFTER this block.
 		FirstBlock int64 `json:"firstBlock"`
-		// LastBlock is the block number that was the height of the chain when the locker was sealed.
-		// It is guaranteed that all records for this locker will be in blocks BEFORE this block.
-		// NOT SUPPORTED.
+		// LastBlock is the number of the block that contains the locker's seal record (see OpTypeLockerSeal).
+		// Any records for this locker in later blocks are ignored.
 		LastBlock int64 `json:"lastBlock,omitempty"`
 		// ThirdPartyAcceptedAtBlock is the number of the block when the locker was accepted by the owner
 		// when the owner acts as a third party (is not a participant on the locker)
@@ -93,36 +131,35 @@ type (
 	}
 )
 
-func (lp *LockerParticipant) Zero() {
-	if lp.rootKeyPriv != nil {
+func (k *lockerKeys) zero() {
+	if k.rootKeyPriv != nil {
 		// zero the key
-		lp.rootKeyPriv.Zero()
+		k.rootKeyPriv.Zero()
 		// delete the key
-		lp.rootKeyPriv = nil
+		k.rootKeyPriv = nil
 	}
-	if lp.rootKeyPub != nil {
+	if k.rootKeyPub != nil {
 		// zero the key
-		lp.rootKeyPub.Zero()
+		k.rootKeyPub.Zero()
 		// delete the key
-		lp.rootKeyPub = nil
+		k.rootKeyPub = nil
 	}
-	if lp.sharedSecretBytes != nil {
-		zero.Bytes(lp.sharedSecretBytes)
+	if k.sharedSecretBytes != nil {
+		zero.Bytes(k.sharedSecretBytes)
 	}
 }
 
-func (lp *LockerParticipant) IsHydrated() bool {
-	if lp.Self {
-		return lp.rootKeyPriv != nil && lp.rootKeyPub != nil
+func (k *lockerKeys) isHydrated(self bool) bool {
+	if self {
+		return k.rootKeyPriv != nil && k.rootKeyPub != nil
 	} else {
-		return lp.rootKeyPub != nil
+		return k.rootKeyPub != nil
 	}
 }
 
-// Hydrate decrypts (if needed) and instantiates ExtendedKey fields from Base64 encoded values
-func (lp *LockerParticipant) Hydrate(pk ed25519.PrivateKey) error {
-	if lp.Self && pk != nil {
-		b, err := base64.StdEncoding.DecodeString(lp.RootPrivateKeyEnc)
+func (k *lockerKeys) hydrate(pk ed25519.PrivateKey, self bool, rootPrivateKeyEnc, rootPublicKey, sharedSecret string) error {
+	if self && pk != nil {
+		b, err := base64.StdEncoding.DecodeString(rootPrivateKeyEnc)
 		if err != nil {
 			return err
 		}
@@ -134,69 +171,259 @@ func (lp *LockerParticipant) Hydrate(pk ed25519.PrivateKey) error {
 		if err != nil {
 			return err
 		}
-		lp.rootKeyPriv = privKey
-		lp.rootKeyPub, err = privKey.Neuter()
+		k.rootKeyPriv = privKey
+		k.rootKeyPub, err = privKey.Neuter()
 		if err != nil {
 			return err
 		}
 	} else {
 		// only process the public key
-		pubKey, err := hdkeychain.NewKeyFromString(lp.RootPublicKey)
+		pubKey, err := hdkeychain.NewKeyFromString(rootPublicKey)
 		if err != nil {
 			return err
 		}
-		lp.rootKeyPub = pubKey
+		k.rootKeyPub = pubKey
 	}
 
-	sharedSecretBytes, err := base64.StdEncoding.DecodeString(lp.SharedSecret)
+	sharedSecretBytes, err := base64.StdEncoding.DecodeString(sharedSecret)
 	if err != nil {
 		return err
 	}
 
-	lp.sharedSecretBytes = sharedSecretBytes
+	k.sharedSecretBytes = sharedSecretBytes
 
 	return nil
 }
 
-func (lp *LockerParticipant) GetRecordPublicKey(idx uint32) (*btcec.PublicKey, error) {
-	k, err := lp.rootKeyPub.Derive(idx)
+func (k *lockerKeys) recordPublicKey(idx uint32) (*btcec.PublicKey, error) {
+	key, err := k.rootKeyPub.Derive(idx)
+	if err != nil {
+		return nil, err
+	}
+	return key.ECPubKey()
+}
+
+func (k *lockerKeys) matchRoutingKey(routingKey string, idx uint32) (*btcec.PublicKey, error) {
+	pk, err := k.recordPublicKey(idx)
 	if err != nil {
 		return nil, err
 	}
-	return k.ECPubKey()
+
+	rk, _ := BuildRoutingKey(pk)
+	if rk == routingKey {
+		return pk, nil
+	} else {
+		return nil, nil
+	}
+}
+
+func (e *LockerKeyEpoch) copy(withPrivateKey bool) *LockerKeyEpoch {
+	res := &LockerKeyEpoch{
+		SharedSecret:  e.SharedSecret,
+		RootPublicKey: e.RootPublicKey,
+		StartBlock:    e.StartBlock,
+		EndBlock:      e.EndBlock,
+	}
+	if withPrivateKey {
+		res.RootPrivateKeyEnc = e.RootPrivateKeyEnc
+	}
+	return res
+}
+
+func copyEpochs(epochs []*LockerKeyEpoch, withPrivateKeys bool) []*LockerKeyEpoch {
+	if len(epochs) == 0 {
+		return nil
+	}
+	res := make([]*LockerKeyEpoch, len(epochs))
+	for i, e := range epochs {
+		res[i] = e.copy(withPrivateKeys)
+	}
+	return res
+}
+
+func (lp *LockerParticipant) Zero() {
+	lp.lockerKeys.zero()
+	for _, e := range lp.PreviousEpochs {
+		e.zero()
+	}
+}
+
+func (lp *LockerParticipant) IsHydrated() bool {
+	if !lp.isHydrated(lp.Self) {
+		return false
+	}
+	for _, e := range lp.PreviousEpochs {
+		if !e.isHydrated(lp.Self) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hydrate decrypts (if needed) and instantiates ExtendedKey fields from Base64 encoded values
+func (lp *LockerParticipant) Hydrate(pk ed25519.PrivateKey) error {
+	if err := lp.hydrate(pk, lp.Self, lp.RootPrivateKeyEnc, lp.RootPublicKey, lp.SharedSecret); err != nil {
+		return err
+	}
+
+	for _, e := range lp.PreviousEpochs {
+		if err := e.hydrate(pk, lp.Self, e.RootPrivateKeyEnc, e.RootPublicKey, e.SharedSecret); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Epoch returns the number of the participant's current key epoch, starting from zero.
+func (lp *LockerParticipant) Epoch() int {
+	return len(lp.PreviousEpochs)
+}
+
+// InitialSharedSecret returns the shared secret from the participant's first key epoch.
+// It's used to build identifiers that should remain stable after key rotation, such as head IDs.
+func (lp *LockerParticipant) InitialSharedSecret() string {
+	if len(lp.PreviousEpochs) > 0 {
+		return lp.PreviousEpochs[0].SharedSecret
+	}
+	return lp.SharedSecret
+}
+
+// SucceedsKeys returns true if the participant's keys are the same as the keys of the given
+// participant, or were rotated from them (see Locker.RotateKeys).
+func (lp *LockerParticipant) SucceedsKeys(other *LockerParticipant) bool {
+	n := other.Epoch()
+	if lp.Epoch() < n {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if lp.PreviousEpochs[i].RootPublicKey != other.PreviousEpochs[i].RootPublicKey ||
+			lp.PreviousEpochs[i].SharedSecret != other.PreviousEpochs[i].SharedSecret {
+			return false
+		}
+	}
+	if lp.Epoch() == n {
+		return lp.RootPublicKey == other.RootPublicKey && lp.SharedSecret == other.SharedSecret
+	} else {
+		return lp.PreviousEpochs[n].RootPublicKey == other.RootPublicKey &&
+			lp.PreviousEpochs[n].SharedSecret == other.SharedSecret
+	}
+}
+
+// keysAt returns the keys that were in use at the given block. If blockNumber is zero,
+// it returns the current keys.
+func (lp *LockerParticipant) keysAt(blockNumber int64) *lockerKeys {
+	if blockNumber == 0 || blockNumber > lp.EpochStartBlock {
+		return &lp.lockerKeys
+	}
+	for _, e := range lp.PreviousEpochs {
+		if blockNumber > e.StartBlock && blockNumber <= e.EndBlock {
+			return &e.lockerKeys
+		}
+	}
+	return nil
+}
+
+// allKeys returns all the participant's keys, starting from the current ones.
+func (lp *LockerParticipant) allKeys() []*lockerKeys {
+	res := []*lockerKeys{&lp.lockerKeys}
+	for i := len(lp.PreviousEpochs) - 1; i >= 0; i-- {
+		res = append(res, &lp.PreviousEpochs[i].lockerKeys)
+	}
+	return res
 }
 
+// GetRecordPublicKey returns the public key for the record with the given key index
+// that was issued in the given block. If blockNumber is zero, the current keys are used.
+func (lp *LockerParticipant) GetRecordPublicKey(idx uint32, blockNumber int64) (*btcec.PublicKey, error) {
+	keys := lp.keysAt(blockNumber)
+	if keys == nil {
+		return nil, ErrKeyEpochNotFound
+	}
+	return keys.recordPublicKey(idx)
+}
+
+// GetRecordPrivateKey returns the private key for a new record with the given key index.
+// It always uses the current keys.
 func (lp *LockerParticipant) GetRecordPrivateKey(idx uint32) (*hdkeychain.ExtendedKey, error) {
 	return lp.rootKeyPriv.Derive(idx)
 }
 
+// FindRecordPrivateKey returns the private key for an existing record with the given routing key
+// and key index. It looks through all participant's key epochs.
+func (lp *LockerParticipant) FindRecordPrivateKey(routingKey string, idx uint32) (*hdkeychain.ExtendedKey, error) {
+	for _, keys := range lp.allKeys() {
+		pk, err := keys.matchRoutingKey(routingKey, idx)
+		if err != nil {
+			return nil, err
+		}
+		if pk != nil {
+			if keys.rootKeyPriv == nil {
+				return nil, errors.New("locker participant's private key not available")
+			}
+			return keys.rootKeyPriv.Derive(idx)
+		}
+	}
+
+	return nil, ErrKeyEpochNotFound
+}
+
 func (lp *LockerParticipant) GetRootPrivateKey() string {
 	return lp.rootKeyPriv.String()
 }
 
-func (lp *LockerParticipant) IsRecordOwner(routingKey string, idx uint32) (*btcec.PublicKey, *AESKey, error) {
-	k, err := lp.rootKeyPub.Derive(idx)
-	if err != nil {
-		return nil, nil, err
+// IsRecordOwner checks if the record with the given routing key and key index was issued by
+// the participant in the given block. If blockNumber is zero (the block is unknown),
+// it checks all participant's key epochs.
+func (lp *LockerParticipant) IsRecordOwner(routingKey string, idx uint32, blockNumber int64) (*btcec.PublicKey, *AESKey, error) {
+	var candidates []*lockerKeys
+	if blockNumber == 0 {
+		candidates = lp.allKeys()
+	} else if keys := lp.keysAt(blockNumber); keys != nil {
+		candidates = []*lockerKeys{keys}
 	}
 
-	pk, err := k.ECPubKey()
-	if err != nil {
-		return nil, nil, err
+	for _, keys := range candidates {
+		pk, err := keys.matchRoutingKey(routingKey, idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pk != nil {
+			return pk, DeriveSymmetricalKey(keys.sharedSecretBytes, pk), nil
+		}
 	}
 
-	rk, _ := BuildRoutingKey(pk)
+	return nil, nil, nil
+}
 
-	if rk == routingKey {
-		return pk, DeriveSymmetricalKey(lp.sharedSecretBytes, pk), nil
-	} else {
-		return nil, nil, nil
+// GetOperationSymKey returns the symmetrical key for the operation of the record with the given key index
+// that was issued in the given block. If blockNumber is zero, the current keys are used.
+func (lp *LockerParticipant) GetOperationSymKey(idx uint32, blockNumber int64) *AESKey {
+	keys := lp.keysAt(blockNumber)
+	if keys == nil {
+		return nil
 	}
+	recordPubKey, _ := keys.recordPublicKey(idx)
+	return DeriveSymmetricalKey(keys.sharedSecretBytes, recordPubKey)
 }
 
-func (lp *LockerParticipant) GetOperationSymKey(idx uint32) *AESKey {
-	recordPubKey, _ := lp.GetRecordPublicKey(idx)
-	return DeriveSymmetricalKey(lp.sharedSecretBytes, recordPubKey)
+// rotateKeys retires the participant's current keys and replaces them with new ones,
+// starting from the block after atBlock.
+func (lp *LockerParticipant) rotateKeys(newKeys *LockerParticipant, atBlock int64) {
+	lp.PreviousEpochs = append(lp.PreviousEpochs, &LockerKeyEpoch{
+		SharedSecret:      lp.SharedSecret,
+		RootPublicKey:     lp.RootPublicKey,
+		RootPrivateKeyEnc: lp.RootPrivateKeyEnc,
+		StartBlock:        lp.EpochStartBlock,
+		EndBlock:          atBlock,
+		lockerKeys:        lp.lockerKeys,
+	})
+
+	lp.SharedSecret = newKeys.SharedSecret
+	lp.RootPublicKey = newKeys.RootPublicKey
+	lp.RootPrivateKeyEnc = newKeys.RootPrivateKeyEnc
+	lp.EpochStartBlock = atBlock
+	lp.lockerKeys = newKeys.lockerKeys
 }
 
 func (l *Locker) Bytes() []byte {
@@ -224,6 +451,8 @@ func (l *Locker) Us() *LockerParticipant {
 	return us
 }
 
+// Them returns the counterparty in a two-party locker. It returns nil for uni-lockers and
+// multi-party lockers. Use Counterparties to get all other participants.
 func (l *Locker) Them() *LockerParticipant {
 	var them *LockerParticipant
 	for _, p := range l.Participants {
@@ -243,10 +472,104 @@ func (l *Locker) Them() *LockerParticipant {
 	return them
 }
 
+// Counterparties returns all participants that aren't 'us', in the order they were added to the locker.
+func (l *Locker) Counterparties() []*LockerParticipant {
+	res := make([]*LockerParticipant, 0, len(l.Participants))
+	for _, p := range l.Participants {
+		if !p.Self {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
 func (l *Locker) IsUnilocker() bool {
 	return len(l.Participants) == 1
 }
 
+// IsMultiParty returns true if the locker has more than two participants.
+func (l *Locker) IsMultiParty() bool {
+	return len(l.Participants) > 2
+}
+
+// IsSealed returns true if the locker was sealed.
+func (l *Locker) IsSealed() bool {
+	return l.Sealed != nil
+}
+
+// Seal marks the locker as sealed at the given block (the block that contains the locker's
+// seal record). This method doesn't update the locker's record in the data wallet.
+func (l *Locker) Seal(lastBlock int64, sealedAt time.Time) error {
+	if l.IsSealed() {
+		return ErrLockerSealed
+	}
+
+	if lastBlock <= l.FirstBlock {
+		return fmt.Errorf("locker seal block %d doesn't follow the locker's first block %d", lastBlock, l.FirstBlock)
+	}
+
+	sealedAt = sealedAt.UTC()
+	l.Sealed = &sealedAt
+	l.LastBlock = lastBlock
+
+	return nil
+}
+
+// AddParticipant generates keys for a new participant and adds it to the locker.
+// The participant will only process records from the given block onwards. This method
+// doesn't update the locker's record in the data wallet.
+func (l *Locker) AddParticipant(party PartyOption, acceptedAtBlock int64) (*LockerParticipant, error) {
+	p, err := party()
+	if err != nil {
+		return nil, err
+	}
+
+	if l.GetParticipant(p.ID) != nil {
+		p.Zero()
+		return nil, ErrParticipantExists
+	}
+
+	if acceptedAtBlock < l.FirstBlock {
+		p.Zero()
+		return nil, fmt.Errorf("%w: accepted-at block %d precedes the locker's first block %d",
+			ErrParticipantInvalid, acceptedAtBlock, l.FirstBlock)
+	}
+
+	p.AcceptedAtBlock = acceptedAtBlock
+
+	l.Participants = append(l.Participants, p)
+
+	return p, nil
+}
+
+// RotateKeys generates new keys for the given participant and retires its current keys.
+// The new keys will be used for records in blocks after atBlock, while the records
+// up to (and including) atBlock remain readable with the old keys. This method doesn't
+// update the locker's record in the data wallet.
+func (l *Locker) RotateKeys(party PartyOption, atBlock int64) (*LockerParticipant, error) {
+	np, err := party()
+	if err != nil {
+		return nil, err
+	}
+	defer np.Zero()
+
+	p := l.GetParticipant(np.ID)
+	if p == nil {
+		return nil, fmt.Errorf("%w: participant %s not found in locker %s", ErrParticipantInvalid, np.ID, l.ID)
+	}
+
+	if atBlock < l.FirstBlock || atBlock <= p.EpochStartBlock {
+		return nil, fmt.Errorf("%w: can't start a new key epoch at block %d", ErrParticipantInvalid, atBlock)
+	}
+
+	p.rotateKeys(np, atBlock)
+
+	// the keys now belong to the participant
+	np.lockerKeys = lockerKeys{}
+
+	return p, nil
+}
+
 func (l *Locker) GetParticipant(participantID string) *LockerParticipant {
 	for _, p := range l.Participants {
 		if p.ID == participantID {
@@ -282,7 +605,9 @@ func (l *Locker) Perspective(iid string) *Locker {
 		AccessLevel: l.AccessLevel,
 		Created:     l.Created,
 		Expires:     l.Expires,
+		Sealed:      l.Sealed,
 		FirstBlock:  l.FirstBlock,
+		LastBlock:   l.LastBlock,
 	}
 
 	parties := make([]*LockerParticipant, len(l.Participants))
@@ -295,6 +620,8 @@ func (l *Locker) Perspective(iid string) *Locker {
 				AcceptedAtBlock:   party.AcceptedAtBlock,
 				RootPublicKey:     party.RootPublicKey,
 				RootPrivateKeyEnc: party.RootPrivateKeyEnc,
+				EpochStartBlock:   party.EpochStartBlock,
+				PreviousEpochs:    copyEpochs(party.PreviousEpochs, true),
 			}
 		} else {
 			parties[i] = &LockerParticipant{
@@ -303,6 +630,8 @@ func (l *Locker) Perspective(iid string) *Locker {
 				Self:            false,
 				AcceptedAtBlock: party.AcceptedAtBlock,
 				RootPublicKey:   party.RootPublicKey,
+				EpochStartBlock: party.EpochStartBlock,
+				PreviousEpochs:  copyEpochs(party.PreviousEpochs, false),
 			}
 		}
 	}
@@ -319,7 +648,9 @@ func (l *Locker) Copy() *Locker {
 		AccessLevel:               l.AccessLevel,
 		Created:                   l.Created,
 		Expires:                   l.Expires,
+		Sealed:                    l.Sealed,
 		FirstBlock:                l.FirstBlock,
+		LastBlock:                 l.LastBlock,
 		ThirdPartyAcceptedAtBlock: l.ThirdPartyAcceptedAtBlock,
 	}
 
@@ -332,6 +663,8 @@ func (l *Locker) Copy() *Locker {
 			AcceptedAtBlock:   party.AcceptedAtBlock,
 			RootPublicKey:     party.RootPublicKey,
 			RootPrivateKeyEnc: party.RootPrivateKeyEnc,
+			EpochStartBlock:   party.EpochStartBlock,
+			PreviousEpochs:    copyEpochs(party.PreviousEpochs, true),
 		})
 	}
 
@@ -502,14 +835,20 @@ func withParty(did *DID, seed []byte, us bool) PartyOption {
 		if verKeyVal == nil {
 			return nil, fmt.Errorf("participant %s doesn't have a verkey", did.ID)
 		}
+		sharedSecret := BuildSharedSecret(privHD)
+		sharedSecretBytes, _ := base64.StdEncoding.DecodeString(sharedSecret)
+
 		return &LockerParticipant{
 			ID:                did.ID,
-			SharedSecret:      BuildSharedSecret(privHD),
+			SharedSecret:      sharedSecret,
 			Self:              us,
 			RootPublicKey:     pubHD.String(),
 			RootPrivateKeyEnc: base64.StdEncoding.EncodeToString(AnonEncrypt([]byte(privHD.String()), verKeyVal)),
-			rootKeyPriv:       privHD,
-			rootKeyPub:        pubHD,
+			lockerKeys: lockerKeys{
+				rootKeyPriv:       privHD,
+				rootKeyPub:        pubHD,
+				sharedSecretBytes: sharedSecretBytes,
+			},
 		}, nil
 	}
 }
@@ -202,3 +202,213 @@ func TestLocker_Us_Them(t *testing.T) {
 	them = locker.Them()
 	require.Empty(t, them)
 }
+
+func TestLocker_MultiParty(t *testing.T) {
+	did1, err := GenerateDID(WithSeed("Test0001"))
+	require.NoError(t, err)
+	did2, err := GenerateDID(WithSeed("Test0002"))
+	require.NoError(t, err)
+	did3, err := GenerateDID(WithSeed("Test0003"))
+	require.NoError(t, err)
+
+	locker, err := GenerateLocker(AccessLevelHosted, "Group Locker", nil, 123,
+		Us(did1, nil),
+		Them(did2, nil),
+		Them(did3, nil))
+	require.NoError(t, err)
+
+	assert.True(t, locker.IsMultiParty())
+	assert.False(t, locker.IsUnilocker())
+	assert.Nil(t, locker.Them())
+
+	parties := locker.Counterparties()
+	require.Len(t, parties, 2)
+	assert.Equal(t, did2.ID, parties[0].ID)
+	assert.Equal(t, did3.ID, parties[1].ID)
+
+	// each participant can hydrate the locker from their perspective
+
+	for _, did := range []*DID{did1, did2, did3} {
+		l := locker.Perspective(did.ID)
+		err = l.Hydrate(did.SignKeyValue())
+		require.NoError(t, err)
+		assert.True(t, l.IsHydrated())
+		assert.Equal(t, did.ID, l.Us().ID)
+		assert.Len(t, l.Counterparties(), 2)
+	}
+
+	// the wrong key can't hydrate another participant's perspective
+
+	l := locker.Perspective(did3.ID)
+	err = l.Hydrate(did2.SignKeyValue())
+	require.Error(t, err)
+}
+
+func TestLocker_AddParticipant(t *testing.T) {
+	did1, err := GenerateDID(WithSeed("Test0001"))
+	require.NoError(t, err)
+	did2, err := GenerateDID(WithSeed("Test0002"))
+	require.NoError(t, err)
+	did3, err := GenerateDID(WithSeed("Test0003"))
+	require.NoError(t, err)
+
+	locker, err := GenerateLocker(AccessLevelHosted, "Test Locker", nil, 123,
+		Us(did1, nil),
+		Them(did2, nil))
+	require.NoError(t, err)
+
+	_, err = locker.AddParticipant(Them(did2, nil), 200)
+	require.ErrorIs(t, err, ErrParticipantExists)
+
+	_, err = locker.AddParticipant(Them(did3, nil), 100)
+	require.ErrorIs(t, err, ErrParticipantInvalid)
+
+	p, err := locker.AddParticipant(Them(did3, nil), 200)
+	require.NoError(t, err)
+
+	assert.Equal(t, did3.ID, p.ID)
+	assert.Equal(t, int64(200), p.AcceptedAtBlock)
+	assert.Len(t, locker.Participants, 3)
+	assert.True(t, locker.IsMultiParty())
+
+	l := locker.Perspective(did3.ID)
+	require.NoError(t, l.Hydrate(did3.SignKeyValue()))
+	assert.Equal(t, int64(200), l.AcceptedAtBlock())
+}
+
+func TestLocker_RotateKeys(t *testing.T) {
+	did1, err := GenerateDID(WithSeed("Test0001"))
+	require.NoError(t, err)
+	did2, err := GenerateDID(WithSeed("Test0002"))
+	require.NoError(t, err)
+	did3, err := GenerateDID(WithSeed("Test0003"))
+	require.NoError(t, err)
+
+	locker, err := GenerateLocker(AccessLevelHosted, "Test Locker", nil, 100,
+		Us(did1, []byte("Seed0001")),
+		Them(did2, nil))
+	require.NoError(t, err)
+	require.NoError(t, locker.Hydrate(did1.SignKeyValue()))
+
+	us := locker.Us()
+	headID := HeadID("asset", locker.ID, us, "head")
+
+	oldKey, err := us.GetRecordPrivateKey(123)
+	require.NoError(t, err)
+	oldPubKey, err := oldKey.ECPubKey()
+	require.NoError(t, err)
+	oldRoutingKey, _ := BuildRoutingKey(oldPubKey)
+	oldSymKey := us.GetOperationSymKey(123, 0)
+	oldState := locker.Copy().Us()
+
+	_, err = locker.RotateKeys(Us(did3, nil), 200)
+	require.ErrorIs(t, err, ErrParticipantInvalid)
+
+	_, err = locker.RotateKeys(Us(did1, []byte("Seed0002")), 50)
+	require.ErrorIs(t, err, ErrParticipantInvalid)
+
+	p, err := locker.RotateKeys(Us(did1, []byte("Seed0002")), 200)
+	require.NoError(t, err)
+	assert.Equal(t, us, p)
+	assert.True(t, p.Self)
+	assert.Equal(t, 1, p.Epoch())
+	assert.Equal(t, int64(200), p.EpochStartBlock)
+	require.Len(t, p.PreviousEpochs, 1)
+	assert.Equal(t, int64(0), p.PreviousEpochs[0].StartBlock)
+	assert.Equal(t, int64(200), p.PreviousEpochs[0].EndBlock)
+	assert.NotEqual(t, p.PreviousEpochs[0].RootPublicKey, p.RootPublicKey)
+	assert.True(t, p.SucceedsKeys(oldState))
+	assert.False(t, oldState.SucceedsKeys(p))
+
+	// head IDs don't change after rotation
+
+	assert.Equal(t, headID, HeadID("asset", locker.ID, p, "head"))
+
+	// new records use new keys
+
+	newKey, err := p.GetRecordPrivateKey(123)
+	require.NoError(t, err)
+	newPubKey, err := newKey.ECPubKey()
+	require.NoError(t, err)
+	newRoutingKey, _ := BuildRoutingKey(newPubKey)
+	assert.NotEqual(t, oldRoutingKey, newRoutingKey)
+
+	// old records remain readable within their epoch
+
+	pk, symKey, err := p.IsRecordOwner(oldRoutingKey, 123, 150)
+	require.NoError(t, err)
+	assert.Equal(t, oldPubKey, pk)
+	assert.Equal(t, oldSymKey, symKey)
+
+	pk, _, err = p.IsRecordOwner(oldRoutingKey, 123, 250)
+	require.NoError(t, err)
+	assert.Nil(t, pk)
+
+	pk, _, err = p.IsRecordOwner(oldRoutingKey, 123, 0)
+	require.NoError(t, err)
+	assert.Equal(t, oldPubKey, pk)
+
+	pk, _, err = p.IsRecordOwner(newRoutingKey, 123, 250)
+	require.NoError(t, err)
+	assert.Equal(t, newPubKey, pk)
+
+	pk, err = p.GetRecordPublicKey(123, 150)
+	require.NoError(t, err)
+	assert.Equal(t, oldPubKey, pk)
+	assert.Equal(t, oldSymKey, p.GetOperationSymKey(123, 150))
+
+	k, err := p.FindRecordPrivateKey(oldRoutingKey, 123)
+	require.NoError(t, err)
+	assert.Equal(t, oldKey.String(), k.String())
+
+	_, err = p.FindRecordPrivateKey(oldRoutingKey, 124)
+	require.ErrorIs(t, err, ErrKeyEpochNotFound)
+
+	// key epochs survive the change of perspective
+
+	l := locker.Perspective(did2.ID)
+	require.NoError(t, l.Hydrate(did2.SignKeyValue()))
+	them := l.GetParticipant(did1.ID)
+	assert.False(t, them.Self)
+	require.Len(t, them.PreviousEpochs, 1)
+	assert.Empty(t, them.PreviousEpochs[0].RootPrivateKeyEnc)
+
+	pk, _, err = them.IsRecordOwner(oldRoutingKey, 123, 150)
+	require.NoError(t, err)
+	assert.Equal(t, oldPubKey, pk)
+
+	l = locker.Perspective(did1.ID)
+	require.NoError(t, l.Hydrate(did1.SignKeyValue()))
+	k, err = l.Us().FindRecordPrivateKey(oldRoutingKey, 123)
+	require.NoError(t, err)
+	assert.Equal(t, oldKey.String(), k.String())
+}
+
+func TestLocker_Seal(t *testing.T) {
+	did1, err := GenerateDID(WithSeed("Test0001"))
+	require.NoError(t, err)
+	did2, err := GenerateDID(WithSeed("Test0002"))
+	require.NoError(t, err)
+
+	locker, err := GenerateLocker(AccessLevelHosted, "Test Locker", nil, 100,
+		Us(did1, nil),
+		Them(did2, nil))
+	require.NoError(t, err)
+	assert.False(t, locker.IsSealed())
+
+	require.Error(t, locker.Seal(100, time.Now()))
+
+	require.NoError(t, locker.Seal(150, time.Now()))
+	assert.True(t, locker.IsSealed())
+	assert.Equal(t, int64(150), locker.LastBlock)
+
+	require.ErrorIs(t, locker.Seal(160, time.Now()), ErrLockerSealed)
+
+	l := locker.Perspective(did2.ID)
+	assert.True(t, l.IsSealed())
+	assert.Equal(t, int64(150), l.LastBlock)
+
+	l = locker.Copy()
+	assert.True(t, l.IsSealed())
+	assert.Equal(t, int64(150), l.LastBlock)
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const (
+	merkleLeafTag = "ledger merkle leaf"
+	merkleNodeTag = "ledger merkle node"
+)
+
+var (
+	// ErrRecordNotInBlock indicates the record is not included into the block
+	ErrRecordNotInBlock = errors.New("record not included into the block")
+	// ErrInvalidInclusionProof indicates the inclusion proof doesn't match the block header
+	ErrInvalidInclusionProof = errors.New("invalid inclusion proof")
+)
+
+type (
+	// MerkleProofStep is a single step in a Merkle inclusion proof.
+	MerkleProofStep struct {
+		// Hash is the base64-encoded hash of the sibling node.
+		Hash string `json:"hash"`
+		// Left is true if the sibling node is on the left.
+		Left bool `json:"left,omitempty"`
+	}
+
+	// RecordInclusionProof proves that a ledger record is included into
+	// the given block. It can be verified offline against the block header
+	// (see Verify).
+	RecordInclusionProof struct {
+		RecordID    string             `json:"recordID"`
+		BlockNumber int64              `json:"blockNumber"`
+		Index       int                `json:"index"`
+		Path        []*MerkleProofStep `json:"path,omitempty"`
+	}
+)
+
+func merkleLeaf(rid string) []byte {
+	return Hash(merkleLeafTag, []byte(rid))
+}
+
+func merkleNode(left, right []byte) []byte {
+	buf := make([]byte, 0, len(left)+len(right))
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	return Hash(merkleNodeTag, buf)
+}
+
+// nextMerkleLevel combines pairs of nodes at the given level. If there is an odd
+// number of nodes, the last node is promoted to the next level as is
+// (it's never paired with itself).
+func nextMerkleLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 < len(level) {
+			next = append(next, merkleNode(level[i], level[i+1]))
+		} else {
+			next = append(next, level[i])
+		}
+	}
+	return next
+}
+
+// merklePath returns the sibling hashes needed to compute the Merkle root
+// from the leaf at the given position.
+func merklePath(level [][]byte, pos int) []*MerkleProofStep {
+	var path []*MerkleProofStep
+	for len(level) > 1 {
+		sibling := pos ^ 1
+		if sibling < len(level) {
+			path = append(path, &MerkleProofStep{
+				Hash: base64.StdEncoding.EncodeToString(level[sibling]),
+				Left: sibling < pos,
+			})
+		}
+		level = nextMerkleLevel(level)
+		pos /= 2
+	}
+	return path
+}
+
+// RecordMerkleRoot computes the Merkle root over the given record IDs, in the order
+// they appear in the block. Returns an empty string if there are no records.
+func RecordMerkleRoot(recordIDs []string) string {
+	if len(recordIDs) == 0 {
+		return ""
+	}
+
+	level := make([][]byte, len(recordIDs))
+	for i, rid := range recordIDs {
+		level[i] = merkleLeaf(rid)
+	}
+
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+
+	return base64.StdEncoding.EncodeToString(level[0])
+}
+
+// NewRecordInclusionProof builds an inclusion proof for the given record, using
+// the list of all record IDs in the block.
+func NewRecordInclusionProof(blockNumber int64, recordIDs []string, rid string) (*RecordInclusionProof, error) {
+	idx := -1
+	level := make([][]byte, len(recordIDs))
+	for i, id := range recordIDs {
+		if id == rid {
+			idx = i
+		}
+		level[i] = merkleLeaf(id)
+	}
+
+	if idx == -1 {
+		return nil, ErrRecordNotInBlock
+	}
+
+	proof := &RecordInclusionProof{
+		RecordID:    rid,
+		BlockNumber: blockNumber,
+		Index:       idx,
+	}
+
+	proof.Path = merklePath(level, idx)
+
+	return proof, nil
+}
+
+// Root computes the Merkle root from the proof.
+func (p *RecordInclusionProof) Root() (string, error) {
+	node := merkleLeaf(p.RecordID)
+	for _, step := range p.Path {
+		sibling, err := base64.StdEncoding.DecodeString(step.Hash)
+		if err != nil {
+			return "", err
+		}
+		if step.Left {
+			node = merkleNode(sibling, node)
+		} else {
+			node = merkleNode(node, sibling)
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(node), nil
+}
+
+// Verify checks the proof against the given block header. If the header
+// contains a nonce, Verify also checks that the block hash commits
+// to the Merkle root.
+func (p *RecordInclusionProof) Verify(header *Block) error {
+	if header == nil {
+		return fmt.Errorf("%w: empty block header", ErrInvalidInclusionProof)
+	}
+
+	if p.BlockNumber != header.Number {
+		return fmt.Errorf("%w: block number mismatch (%d != %d)", ErrInvalidInclusionProof, p.BlockNumber, header.Number)
+	}
+
+	if header.MerkleRoot == "" {
+		return fmt.Errorf("%w: block header has no Merkle root", ErrInvalidInclusionProof)
+	}
+
+	if header.Nonce != "" && header.Hash != BlockHash(header.Number, header.ParentHash, header.Nonce, header.MerkleRoot) {
+		return fmt.Errorf("%w: block hash doesn't match the block header", ErrInvalidInclusionProof)
+	}
+
+	root, err := p.Root()
+	if err != nil {
+		return err
+	}
+
+	if root != header.MerkleRoot {
+		return fmt.Errorf("%w: Merkle root mismatch", ErrInvalidInclusionProof)
+	}
+
+	return nil
+}
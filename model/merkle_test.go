@@ -0,0 +1,126 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	. "github.com/piprate/metalocker/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("record%d", i)
+	}
+	return ids
+}
+
+func sealedHeader(number int64, ids []string) *Block {
+	b := &Block{
+		Number:     number,
+		ParentHash: base64.StdEncoding.EncodeToString([]byte("parent")),
+		Nonce:      base64.StdEncoding.EncodeToString([]byte("nonce")),
+		MerkleRoot: RecordMerkleRoot(ids),
+	}
+	b.Hash = BlockHash(b.Number, b.ParentHash, b.Nonce, b.MerkleRoot)
+	return b
+}
+
+func TestRecordMerkleRoot(t *testing.T) {
+	assert.Empty(t, RecordMerkleRoot(nil))
+
+	root := RecordMerkleRoot(recordIDs(3))
+	assert.NotEmpty(t, root)
+	assert.Equal(t, root, RecordMerkleRoot(recordIDs(3)))
+
+	// the order of records matters
+	assert.NotEqual(t, root, RecordMerkleRoot([]string{"record1", "record0", "record2"}))
+
+	// the last record isn't paired with itself
+	assert.NotEqual(t, root, RecordMerkleRoot([]string{"record0", "record1", "record2", "record2"}))
+}
+
+func TestRecordInclusionProof_Verify(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		ids := recordIDs(n)
+		header := sealedHeader(10, ids)
+
+		for _, rid := range ids {
+			proof, err := NewRecordInclusionProof(10, ids, rid)
+			require.NoError(t, err)
+			assert.NoError(t, proof.Verify(header), "records: %d, record: %s", n, rid)
+		}
+	}
+
+	_, err := NewRecordInclusionProof(10, recordIDs(3), "unknown")
+	assert.ErrorIs(t, err, ErrRecordNotInBlock)
+}
+
+func TestRecordInclusionProof_Verify_Tampered(t *testing.T) {
+	ids := recordIDs(5)
+	header := sealedHeader(10, ids)
+
+	proof, err := NewRecordInclusionProof(10, ids, "record3")
+	require.NoError(t, err)
+	require.NoError(t, proof.Verify(header))
+
+	// another record
+
+	badProof := *proof
+	badProof.RecordID = "record4"
+	assert.ErrorIs(t, badProof.Verify(header), ErrInvalidInclusionProof)
+
+	// another block
+
+	badProof = *proof
+	badProof.BlockNumber = 11
+	assert.ErrorIs(t, badProof.Verify(header), ErrInvalidInclusionProof)
+
+	// tampered path
+
+	badProof = *proof
+	badProof.Path = append([]*MerkleProofStep{}, proof.Path...)
+	badProof.Path[0] = &MerkleProofStep{Hash: proof.Path[0].Hash, Left: !proof.Path[0].Left}
+	assert.ErrorIs(t, badProof.Verify(header), ErrInvalidInclusionProof)
+
+	// header with a different Merkle root, but the original hash
+
+	badHeader := *header
+	badHeader.MerkleRoot = RecordMerkleRoot(recordIDs(4))
+	assert.ErrorIs(t, proof.Verify(&badHeader), ErrInvalidInclusionProof)
+
+	// header without a Merkle root
+
+	badHeader = *header
+	badHeader.MerkleRoot = ""
+	assert.ErrorIs(t, proof.Verify(&badHeader), ErrInvalidInclusionProof)
+}
+
+func TestBlockHash(t *testing.T) {
+	parentHash := base64.StdEncoding.EncodeToString([]byte("parent"))
+	nonce := base64.StdEncoding.EncodeToString([]byte("nonce"))
+	root := RecordMerkleRoot(recordIDs(2))
+
+	h := BlockHash(5, parentHash, nonce, root)
+	assert.Equal(t, h, BlockHash(5, parentHash, nonce, root))
+	assert.NotEqual(t, h, BlockHash(6, parentHash, nonce, root))
+	assert.NotEqual(t, h, BlockHash(5, parentHash, nonce, ""))
+	assert.NotEqual(t, h, BlockHash(5, parentHash, nonce, RecordMerkleRoot(recordIDs(3))))
+}
@@ -47,6 +47,10 @@ type Lease struct {
 	Impression  *Impression       `json:"impression"`
 	Provenance  *ProvEntity       `json:"provenance,omitempty"`
 	Proof       *Proof            `json:"proof,omitempty"`
+
+	// DisclosureSeed is the secret seed for salts of the meta resource's disclosure tree
+	// (see MetaResource.DisclosureRoot).
+	DisclosureSeed string `json:"disclosureSeed,omitempty"`
 }
 
 func NewLease(body []byte) (*Lease, error) {
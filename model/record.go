@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
@@ -38,6 +39,13 @@ var (
 	OpTypeLease           OpType = 1
 	OpTypeLeaseRevocation OpType = 2
 	OpTypeAssetHead       OpType = 3
+	OpTypeLeaseRenewal    OpType = 4
+	// OpTypeLockerInvitation is an encrypted locker invitation addressed to a DID
+	// (see LockerInvitation).
+	OpTypeLockerInvitation OpType = 5
+	// OpTypeLockerSeal is a locker participant's notice that the locker is sealed.
+	// Records from the locker's participants in later blocks are ignored.
+	OpTypeLockerSeal OpType = 6
 )
 
 const (
@@ -96,7 +104,13 @@ type Record struct {
 	// DataAssets is a list of data assets (blobs) attached to the record
 	DataAssets []string `json:"dataAssets,omitempty"`
 
-	// Lease Revocation / Head fields
+	// ExpiresAt is the lease expiry time (Unix time in seconds). If set, the ledger
+	// will expire the lease and release its data assets after this time. Zero means
+	// the lease doesn't expire. For lease renewals, it's the new expiry time
+	// of the subject lease.
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+
+	// Lease Revocation / Renewal / Head fields
 
 	SubjectRecord   string   `json:"subjectRecord,omitempty"`
 	RevocationProof []string `json:"revocationProof,omitempty"`
@@ -123,11 +137,15 @@ const (
 	StatusPublished RecordStatus = "published"
 	StatusRevoked   RecordStatus = "revoked"
 	StatusFailed    RecordStatus = "failed"
+	StatusExpired   RecordStatus = "expired"
 )
 
 type RecordState struct {
 	Status      RecordStatus `json:"status"`
 	BlockNumber int64        `json:"number"`
+	// ExpiresAt is the effective expiry time of the lease, taking
+	// into account any renewals.
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
 }
 
 func (r *Record) Seal(pk *btcec.PrivateKey) error {
@@ -179,6 +197,16 @@ func (r *Record) Verify(publicKey *btcec.PublicKey) (bool, error) {
 	return validID, nil
 }
 
+// DeriveID re-computes the record ID from the record's body and signature.
+// It doesn't check the signature itself, as this requires the record's
+// public key (see Verify).
+func (r *Record) DeriveID() string {
+	buf := r.bodyBuffer()
+	buf.Write(base58.Decode(r.Signature))
+
+	return base58.Encode(Hash("ledger record construction", buf.Bytes()))
+}
+
 func (r *Record) bodyBuffer() *bytes.Buffer {
 	buf := bytes.NewBuffer(nil)
 	routingKeyVal := base58.Decode(r.RoutingKey)
@@ -216,6 +244,10 @@ func (r *Record) bodyBuffer() *bytes.Buffer {
 	headBody, _ := base64.StdEncoding.DecodeString(r.HeadBody)
 	buf.Write(headBody)
 
+	if r.ExpiresAt > 0 {
+		buf.Write(utils.Int64ToBytes(r.ExpiresAt))
+	}
+
 	return buf
 }
 
@@ -245,15 +277,42 @@ func (r *Record) ToSlice() []string {
 	}
 }
 
+// Expired returns true if the record has a lease expiry time that has passed.
+func (r *Record) Expired(now time.Time) bool {
+	return r.ExpiresAt != 0 && r.ExpiresAt < now.Unix()
+}
+
 func (r *Record) Copy() *Record {
 	cp := *r
 	return &cp
 }
 
 func (r *Record) Validate() error {
+	if r.ExpiresAt < 0 || (r.ExpiresAt != 0 && r.Operation != OpTypeLease && r.Operation != OpTypeLeaseRenewal) {
+		return errors.New("invalid lease expiry time")
+	}
+
 	switch r.Operation {
 	case OpTypeLease:
 	case OpTypeLeaseRevocation:
+	case OpTypeLeaseRenewal:
+		if r.SubjectRecord == "" {
+			return errors.New("empty lease renewal subject")
+		}
+		if r.ExpiresAt == 0 {
+			return errors.New("lease renewal without expiry time")
+		}
+		if len(r.RevocationProof) != 1 {
+			return errors.New("invalid lease renewal proof")
+		}
+	case OpTypeLockerInvitation:
+		if r.OperationAddress == "" {
+			return errors.New("empty locker invitation address")
+		}
+	case OpTypeLockerSeal:
+		if r.OperationAddress != "" || r.SubjectRecord != "" {
+			return errors.New("unexpected locker seal payload")
+		}
 	case OpTypeAssetHead:
 		if r.SubjectRecord != "" {
 			if len(r.RevocationProof) != 1 {
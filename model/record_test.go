@@ -17,6 +17,7 @@ package model_test
 import (
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/piprate/json-gold/ld"
 	. "github.com/piprate/metalocker/model"
@@ -159,3 +160,50 @@ func TestRandomKeyIndex(t *testing.T) {
 	idx := RandomKeyIndex()
 	assert.True(t, idx < uint32(0x80000000))
 }
+
+func TestVerifyLeaseRenewal(t *testing.T) {
+	subjKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	renewalKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	otherKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	subjRoutingKey, _ := BuildRoutingKey(subjKey.PubKey())
+	subj := &Record{
+		RoutingKey: subjRoutingKey,
+		Operation:  OpTypeLease,
+		ExpiresAt:  1000,
+	}
+	require.NoError(t, subj.Seal(subjKey))
+
+	routingKey, _ := BuildRoutingKey(renewalKey.PubKey())
+	rec := &Record{
+		RoutingKey:    routingKey,
+		Operation:     OpTypeLeaseRenewal,
+		SubjectRecord: subj.ID,
+		ExpiresAt:     2000,
+	}
+	require.NoError(t, SignLeaseRenewal(rec, subjKey))
+	require.NoError(t, rec.Seal(renewalKey))
+	require.NoError(t, rec.Validate())
+
+	assert.NoError(t, VerifyLeaseRenewal(rec, subj))
+
+	// the proof is bound to the new expiry time
+
+	tampered := rec.Copy()
+	tampered.ExpiresAt = 3000
+	assert.ErrorIs(t, VerifyLeaseRenewal(tampered, subj), ErrLeaseRenewalInvalid)
+
+	// the proof should be signed by the subject record's key
+
+	forged := rec.Copy()
+	require.NoError(t, SignLeaseRenewal(forged, otherKey))
+	assert.ErrorIs(t, VerifyLeaseRenewal(forged, subj), ErrLeaseRenewalInvalid)
+
+	// leases without expiry time can't be renewed
+
+	subj.ExpiresAt = 0
+	assert.ErrorIs(t, VerifyLeaseRenewal(rec, subj), ErrLeaseRenewalInvalid)
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/piprate/metalocker/utils"
+)
+
+var (
+	// ErrLeaseRenewalInvalid indicates the lease renewal record isn't
+	// authorised to extend the subject lease.
+	ErrLeaseRenewalInvalid = errors.New("invalid lease renewal")
+)
+
+// leaseRenewalHash returns the hash of the lease renewal statement that needs
+// to be signed by the subject record's key. The statement is bound to the renewal
+// record's routing key to prevent reuse of the proof in other records.
+func leaseRenewalHash(subjectRecordID string, expiresAt int64, routingKey string) []byte {
+	buf := base58.Decode(subjectRecordID)
+	buf = append(buf, utils.Int64ToBytes(expiresAt)...)
+	buf = append(buf, base58.Decode(routingKey)...)
+
+	return Hash("lease renewal", buf)
+}
+
+// SignLeaseRenewal generates a lease renewal proof for the given record. The proof
+// is a signature of the renewal statement, produced using the private key
+// of the subject (lease) record. Unlike revocation proofs, renewal proofs
+// don't disclose the authorising commitment input of the subject record.
+// The record's routing key, subject record and expiry time should be set
+// before calling this function.
+func SignLeaseRenewal(r *Record, subjPrivKey *btcec.PrivateKey) error {
+	if r.Operation != OpTypeLeaseRenewal {
+		return errors.New("record is not a lease renewal")
+	}
+
+	sig := ecdsa.Sign(subjPrivKey, leaseRenewalHash(r.SubjectRecord, r.ExpiresAt, r.RoutingKey))
+
+	r.RevocationProof = []string{
+		base64.StdEncoding.EncodeToString(sig.Serialize()),
+	}
+
+	return nil
+}
+
+// VerifyLeaseRenewal checks that the lease renewal record r was authorised
+// by the owner of the subject record. It doesn't check whether the new expiry
+// time extends the effective expiry time of the subject lease: this depends
+// on the ledger state.
+func VerifyLeaseRenewal(r, subj *Record) error {
+	if r.Operation != OpTypeLeaseRenewal || subj.Operation != OpTypeLease ||
+		r.SubjectRecord != subj.ID || len(r.RevocationProof) != 1 {
+		return ErrLeaseRenewalInvalid
+	}
+
+	if subj.ExpiresAt == 0 {
+		// leases without expiry time can't be renewed
+		return ErrLeaseRenewalInvalid
+	}
+
+	pubKey, err := btcec.ParsePubKey(base58.Decode(subj.RoutingKey))
+	if err != nil {
+		return ErrLeaseRenewalInvalid
+	}
+
+	sigBytes, err := base64.StdEncoding.DecodeString(r.RevocationProof[0])
+	if err != nil {
+		return ErrLeaseRenewalInvalid
+	}
+	sig, err := ecdsa.ParseDERSignature(sigBytes)
+	if err != nil {
+		return ErrLeaseRenewalInvalid
+	}
+
+	if !sig.Verify(leaseRenewalHash(r.SubjectRecord, r.ExpiresAt, r.RoutingKey), pubKey) {
+		return ErrLeaseRenewalInvalid
+	}
+
+	return nil
+}
@@ -82,6 +82,9 @@ type (
 		KeyID        int    `json:"key"`
 		LastBlock    int64  `json:"last"`
 		PublicKeyStr string `json:"pubk"`
+		// UntilBlock is the last block that may contain records for this key. It's set
+		// for retired locker keys (see model.LockerKeyEpoch). Zero means no limit.
+		UntilBlock int64 `json:"until,omitempty"`
 
 		Subscription Subscription `json:"-"`
 
@@ -154,6 +157,11 @@ AllBlocks:
 						continue
 					}
 
+					if cfg.UntilBlock > 0 && currentBlockNumber > cfg.UntilBlock {
+						// the key was retired
+						continue
+					}
+
 					k, err := cfg.publicKey.Derive(idx)
 					if err != nil {
 						return -1, false, err
@@ -436,6 +436,209 @@ func TestScanner_RemoveSubscription(t *testing.T) {
 	assert.True(t, consumer2.AllRecordsMatched(), "Received less matching records than expected")
 }
 
+func TestScanner_Scan_MultiParty(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer env.Close()
+
+	dw1 := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelManaged, model.WithSeed("Acct1"))
+	idy1, err := dw1.NewIdentity(env.Ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	dw2 := env.CreateCustomAccount(t, "test2@example.com", "John Doe 2", model.AccessLevelManaged, model.WithSeed("Acct2"))
+	idy2, err := dw2.NewIdentity(env.Ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	dw3 := env.CreateCustomAccount(t, "test3@example.com", "John Doe 3", model.AccessLevelManaged, model.WithSeed("Acct3"))
+	idy3, err := dw3.NewIdentity(env.Ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	// set up a group locker between the first two parties
+
+	locker1, err := idy1.NewLocker(env.Ctx, "Group Locker", wallet.Participant(idy2.DID(), nil))
+	require.NoError(t, err)
+
+	locker2, err := dw2.AddLocker(env.Ctx, locker1.Raw().Perspective(idy2.ID()))
+	require.NoError(t, err)
+
+	store := func(l wallet.Locker, name string) string {
+		f := l.Store(env.Ctx, map[string]any{"type": "TestDataset", "name": name}, expiry.FromNow("1h"),
+			dataset.WithVault(testbase.TestVaultName))
+		require.NoError(t, f.Wait(time.Second*2))
+		return f.ID()
+	}
+
+	rid1 := store(locker2, "Dataset 1")
+
+	consumer1 := &CheckingConsumer{
+		T: t,
+		ExpectedMatches: []map[string]any{
+			{
+				"userID":        dw1.ID(),
+				"rid":           rid1,
+				"t":             model.OpTypeLease,
+				"lockerID":      locker1.ID(),
+				"participantID": idy2.ID(),
+				"acceptedAt":    locker1.Raw().AcceptedAtBlock(),
+			},
+		},
+	}
+
+	sub1 := NewIndexSubscription(dw1.ID(), consumer1)
+	require.NoError(t, sub1.AddLockers(LockerEntry{Locker: locker1.Raw(), LastBlock: locker1.Raw().FirstBlock}))
+	require.Len(t, sub1.LockerConfigs(), 2)
+
+	// adding the same locker again doesn't duplicate key lookups
+	require.NoError(t, sub1.AddLockers(LockerEntry{Locker: locker1.Raw(), LastBlock: locker1.Raw().FirstBlock}))
+	require.Len(t, sub1.LockerConfigs(), 2)
+
+	ledgerScanner := NewScanner(env.Ledger)
+	_ = ledgerScanner.AddSubscription(sub1)
+
+	complete, err := ledgerScanner.Scan(env.Ctx)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	assert.True(t, consumer1.AllRecordsMatched(), "Received less matching records than expected")
+
+	// add the third party at a later block
+
+	locker1, err = locker1.AddParticipant(env.Ctx, idy3.DID(), nil)
+	require.NoError(t, err)
+
+	locker3, err := dw3.AddLocker(env.Ctx, locker1.Raw().Perspective(idy3.ID()))
+	require.NoError(t, err)
+
+	rid2 := store(locker3, "Dataset 2")
+
+	require.NoError(t, sub1.AddLockers(LockerEntry{Locker: locker1.Raw(), LastBlock: locker1.Raw().FirstBlock}))
+	configs := sub1.LockerConfigs()
+	require.Len(t, configs, 3)
+	assert.Equal(t, locker1.Raw().GetParticipant(idy3.ID()).AcceptedAtBlock, configs[2].LastBlock)
+
+	consumer1.ExpectedMatches = []map[string]any{
+		{
+			"userID":        dw1.ID(),
+			"rid":           rid2,
+			"t":             model.OpTypeLease,
+			"lockerID":      locker1.ID(),
+			"participantID": idy3.ID(),
+			"acceptedAt":    locker1.Raw().AcceptedAtBlock(),
+		},
+	}
+	consumer1.Reset()
+
+	complete, err = ledgerScanner.Scan(env.Ctx)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	assert.True(t, consumer1.AllRecordsMatched(), "Received less matching records than expected")
+}
+
+func TestScanner_Scan_KeyRotation(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer env.Close()
+
+	dw1 := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelManaged, model.WithSeed("Acct1"))
+	idy1, err := dw1.NewIdentity(env.Ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	dw2 := env.CreateCustomAccount(t, "test2@example.com", "John Doe 2", model.AccessLevelManaged, model.WithSeed("Acct2"))
+	idy2, err := dw2.NewIdentity(env.Ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	locker1, err := idy1.NewLocker(env.Ctx, "Test Locker", wallet.Participant(idy2.DID(), nil))
+	require.NoError(t, err)
+
+	locker2, err := dw2.AddLocker(env.Ctx, locker1.Raw().Perspective(idy2.ID()))
+	require.NoError(t, err)
+
+	store := func(l wallet.Locker, name string) string {
+		f := l.Store(env.Ctx, map[string]any{"type": "TestDataset", "name": name}, expiry.FromNow("1h"),
+			dataset.WithVault(testbase.TestVaultName))
+		require.NoError(t, f.Wait(time.Second*2))
+		return f.ID()
+	}
+
+	rid1 := store(locker2, "Dataset 1")
+
+	consumer1 := &CheckingConsumer{
+		T: t,
+		ExpectedMatches: []map[string]any{
+			{
+				"userID":        dw1.ID(),
+				"rid":           rid1,
+				"t":             model.OpTypeLease,
+				"lockerID":      locker1.ID(),
+				"participantID": idy2.ID(),
+				"acceptedAt":    locker1.Raw().AcceptedAtBlock(),
+			},
+		},
+	}
+
+	sub1 := NewIndexSubscription(dw1.ID(), consumer1)
+	require.NoError(t, sub1.AddLockers(LockerEntry{Locker: locker1.Raw(), LastBlock: locker1.Raw().FirstBlock}))
+	require.Len(t, sub1.LockerConfigs(), 2)
+
+	ledgerScanner := NewScanner(env.Ledger)
+	_ = ledgerScanner.AddSubscription(sub1)
+
+	complete, err := ledgerScanner.Scan(env.Ctx)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	assert.True(t, consumer1.AllRecordsMatched(), "Received less matching records than expected")
+
+	// the second party rotates its keys and shares the new keys with the first party
+
+	locker2, err = locker2.RotateKeys(env.Ctx, nil)
+	require.NoError(t, err)
+
+	locker1, err = dw1.UpdateLocker(env.Ctx, locker2.Raw().Perspective(idy1.ID()))
+	require.NoError(t, err)
+
+	rid2 := store(locker2, "Dataset 2")
+
+	them := locker1.Raw().GetParticipant(idy2.ID())
+	require.Len(t, them.PreviousEpochs, 1)
+
+	require.NoError(t, sub1.AddLockers(LockerEntry{Locker: locker1.Raw(), LastBlock: locker1.Raw().FirstBlock}))
+	configs := sub1.LockerConfigs()
+	require.Len(t, configs, 3)
+	for _, cfg := range configs {
+		switch cfg.PublicKeyStr {
+		case them.PreviousEpochs[0].RootPublicKey:
+			assert.Equal(t, them.EpochStartBlock, cfg.UntilBlock)
+		case them.RootPublicKey:
+			assert.Equal(t, them.EpochStartBlock, cfg.LastBlock)
+			assert.Equal(t, int64(0), cfg.UntilBlock)
+		}
+	}
+
+	consumer1.ExpectedMatches = []map[string]any{
+		{
+			"userID":        dw1.ID(),
+			"rid":           rid2,
+			"t":             model.OpTypeLease,
+			"lockerID":      locker1.ID(),
+			"participantID": idy2.ID(),
+			"acceptedAt":    locker1.Raw().AcceptedAtBlock(),
+		},
+	}
+	consumer1.Reset()
+
+	complete, err = ledgerScanner.Scan(env.Ctx)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	assert.True(t, consumer1.AllRecordsMatched(), "Received less matching records than expected")
+
+	// the first party can read records issued with both old and new keys
+
+	ds, err := dw1.DataStore().Load(env.Ctx, rid1)
+	require.NoError(t, err)
+	assert.Equal(t, idy2.ID(), ds.ParticipantID())
+
+	ds, err = dw1.DataStore().Load(env.Ctx, rid2)
+	require.NoError(t, err)
+	assert.Equal(t, idy2.ID(), ds.ParticipantID())
+}
+
 type CheckingConsumer struct {
 	T               *testing.T
 	ExpectedMatches []map[string]any
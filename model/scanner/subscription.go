@@ -54,6 +54,9 @@ type (
 	LockerEntry struct {
 		Locker    *model.Locker
 		LastBlock int64
+		// UntilBlock is the last block to scan for the locker's records. If it's zero,
+		// the locker's LastBlock is used (non-zero for sealed lockers).
+		UntilBlock int64
 	}
 )
 
@@ -125,36 +128,114 @@ func (w *IndexSubscription) SetStatus(status int) {
 	}
 }
 
+// AddLockers subscribes to records from all participants of the given lockers. Participants
+// that are already subscribed are skipped, so it's safe to call this method again after
+// a new participant was added to a locker (see model.Locker.AddParticipant). Scanning
+// for such participants starts from the block they were accepted at.
+//
+// Every key epoch of each participant (see model.Locker.RotateKeys) gets its own
+// subscription key. Retired keys are only matched against records up to the end
+// of their epoch, and new keys are matched from the start of their epoch.
 func (w *IndexSubscription) AddLockers(lockers ...LockerEntry) error {
 	for _, le := range lockers {
+		lockerUntilBlock := le.UntilBlock
+		if lockerUntilBlock == 0 {
+			lockerUntilBlock = le.Locker.LastBlock
+		}
+
+		knownKeys := w.lockerKeys(le.Locker.ID)
+		subscribed := len(knownKeys) > 0
 		for _, p := range le.Locker.Participants {
-			keyID := w.nextKeyID
-			w.keys[keyID] = &lockerParty{
-				LockerID:        le.Locker.ID,
-				ParticipantID:   p.ID,
-				SharedSecret:    p.SharedSecret,
-				PublicKeyStr:    p.RootPublicKey,
-				AcceptedAtBlock: le.Locker.AcceptedAtBlock(),
+			for _, epoch := range participantEpochs(p) {
+				untilBlock := minBlock(epoch.EndBlock, lockerUntilBlock)
+
+				if cfg, found := knownKeys[p.ID+"|"+epoch.RootPublicKey]; found {
+					// the key may have been retired, or the locker sealed, since we subscribed to it
+					cfg.UntilBlock = minBlock(cfg.UntilBlock, untilBlock)
+					continue
+				}
+
+				lastBlock := le.LastBlock
+				if subscribed && p.AcceptedAtBlock > lastBlock {
+					// the participant joined an existing locker. There can't be any records
+					// from this participant before the block it was accepted at.
+					lastBlock = p.AcceptedAtBlock
+				}
+				if epoch.StartBlock > lastBlock {
+					// there can't be any records with these keys before the epoch started
+					lastBlock = epoch.StartBlock
+				}
+
+				keyID := w.nextKeyID
+				w.keys[keyID] = &lockerParty{
+					LockerID:        le.Locker.ID,
+					ParticipantID:   p.ID,
+					SharedSecret:    epoch.SharedSecret,
+					PublicKeyStr:    epoch.RootPublicKey,
+					AcceptedAtBlock: le.Locker.AcceptedAtBlock(),
+				}
+
+				w.keyOrder = append(w.keyOrder, keyID)
+				sort.Ints(w.keyOrder)
+
+				cfg := &LockerConfig{
+					KeyID:        keyID,
+					PublicKeyStr: epoch.RootPublicKey,
+					LastBlock:    lastBlock,
+					UntilBlock:   untilBlock,
+					Subscription: w,
+				}
+				if err := cfg.Hydrate(); err != nil {
+					return err
+				}
+
+				w.configs = append(w.configs, cfg)
+				knownKeys[p.ID+"|"+epoch.RootPublicKey] = cfg
+
+				w.nextKeyID++
 			}
+		}
+	}
 
-			w.keyOrder = append(w.keyOrder, keyID)
-			sort.Ints(w.keyOrder)
+	return nil
+}
 
-			cfg := &LockerConfig{
-				KeyID:        keyID,
-				PublicKeyStr: p.RootPublicKey,
-				LastBlock:    le.LastBlock,
-				Subscription: w,
-			}
-			if err := cfg.Hydrate(); err != nil {
-				return err
-			}
+// SealLocker stops scanning for the given locker's records after the given block.
+func (w *IndexSubscription) SealLocker(lockerID string, lastBlock int64) {
+	for _, cfg := range w.lockerKeys(lockerID) {
+		cfg.UntilBlock = minBlock(cfg.UntilBlock, lastBlock)
+	}
+}
 
-			w.configs = append(w.configs, cfg)
+// minBlock returns the smallest of the given block numbers. Zero means no limit.
+func minBlock(a, b int64) int64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
 
-			w.nextKeyID++
+// lockerKeys returns subscribed locker configs for the given locker, indexed
+// by participant ID and root public key.
+func (w *IndexSubscription) lockerKeys(lockerID string) map[string]*LockerConfig {
+	res := make(map[string]*LockerConfig)
+	for _, cfg := range w.configs {
+		p := w.keys[cfg.KeyID]
+		if p != nil && p.LockerID == lockerID {
+			res[p.ParticipantID+"|"+p.PublicKeyStr] = cfg
 		}
 	}
+	return res
+}
 
-	return nil
+// participantEpochs returns all key epochs of the given participant, including the current one.
+func participantEpochs(p *model.LockerParticipant) []*model.LockerKeyEpoch {
+	res := make([]*model.LockerKeyEpoch, 0, len(p.PreviousEpochs)+1)
+	res = append(res, p.PreviousEpochs...)
+	res = append(res, &model.LockerKeyEpoch{
+		SharedSecret:  p.SharedSecret,
+		RootPublicKey: p.RootPublicKey,
+		StartBlock:    p.EpochStartBlock,
+	})
+	return res
 }
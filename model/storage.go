@@ -44,7 +44,9 @@ type (
 		// Method is the vault's method of storage. This field defines the meaning of Params field.
 		Method string `json:"method"`
 		// Params is key/value pairs that are specific to the selected Method. These parameters should be
-		// sufficient to locate the resource blob in the vault.
+		// sufficient to locate the resource blob in the vault. The key "cse" is reserved: it marks
+		// the client side encryption format (see vaults.ParamClientEncryption) and must be ignored
+		// by vaults and never used for vault-specific parameters.
 		Params map[string]any `json:"params,omitempty"`
 		// EncryptionKey is a Base64-encoded client side encryption key (if the asset was encrypted on the client side).
 		EncryptionKey string `json:"encryptionKey,omitempty"`
@@ -0,0 +1,259 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	CredentialsContextV1 = "https://www.w3.org/2018/credentials/v1"
+
+	TypeVerifiableCredential   = "VerifiableCredential"
+	TypeVerifiablePresentation = "VerifiablePresentation"
+
+	// issuerDependentVocab is used to map terms that aren't defined in any
+	// of the credential's contexts. Without it, such terms would be dropped
+	// during normalisation and left unsigned (same approach as in VC Data Model 2.0).
+	issuerDependentVocab = "https://www.w3.org/ns/credentials/issuer-dependent#"
+)
+
+type (
+	// Credential is a W3C Verifiable Credential (https://www.w3.org/TR/vc-data-model/).
+	Credential struct {
+		Context           []any          `json:"@context"`
+		ID                string         `json:"id,omitempty"`
+		Type              []string       `json:"type"`
+		Issuer            string         `json:"issuer"`
+		IssuanceDate      *time.Time     `json:"issuanceDate"`
+		ExpirationDate    *time.Time     `json:"expirationDate,omitempty"`
+		CredentialSubject map[string]any `json:"credentialSubject"`
+		Proof             *Proof         `json:"proof,omitempty"`
+	}
+
+	options struct {
+		id           string
+		types        []string
+		contexts     []any
+		issuanceDate time.Time
+		expiryDate   time.Time
+		challenge    string
+		domain       string
+	}
+
+	// Option is for defining parameters when issuing credentials or building presentations.
+	Option func(opts *options) error
+)
+
+// WithID sets the ID of the credential or presentation.
+func WithID(id string) Option {
+	return func(opts *options) error {
+		opts.id = id
+		return nil
+	}
+}
+
+// WithType adds the given types to the credential or presentation
+// (in addition to VerifiableCredential or VerifiablePresentation).
+func WithType(types ...string) Option {
+	return func(opts *options) error {
+		opts.types = append(opts.types, types...)
+		return nil
+	}
+}
+
+// WithContext adds the given JSON-LD contexts to the credential or presentation.
+// The contexts should define all the terms used in the credential subject.
+func WithContext(contexts ...any) Option {
+	return func(opts *options) error {
+		opts.contexts = append(opts.contexts, contexts...)
+		return nil
+	}
+}
+
+// WithIssuanceDate overrides the credential's issuance date (default: now).
+func WithIssuanceDate(ts time.Time) Option {
+	return func(opts *options) error {
+		opts.issuanceDate = ts
+		return nil
+	}
+}
+
+// WithExpirationDate sets the credential's expiration date.
+func WithExpirationDate(ts time.Time) Option {
+	return func(opts *options) error {
+		opts.expiryDate = ts
+		return nil
+	}
+}
+
+// WithChallenge sets the challenge of the presentation proof
+// (to prevent replay attacks).
+func WithChallenge(challenge string) Option {
+	return func(opts *options) error {
+		opts.challenge = challenge
+		return nil
+	}
+}
+
+// WithDomain sets the domain of the presentation proof.
+func WithDomain(domain string) Option {
+	return func(opts *options) error {
+		opts.domain = domain
+		return nil
+	}
+}
+
+func buildOptions(opts []Option) (*options, error) {
+	var options options
+	for _, fn := range opts {
+		if err := fn(&options); err != nil {
+			return nil, err
+		}
+	}
+	if options.issuanceDate.IsZero() {
+		options.issuanceDate = time.Now()
+	}
+	return &options, nil
+}
+
+func buildContext(extra []any) []any {
+	ctx := []any{CredentialsContextV1}
+	ctx = append(ctx, extra...)
+	return append(ctx, map[string]any{"@vocab": issuerDependentVocab})
+}
+
+// Issue creates a new verifiable credential for the given subject and signs it
+// with the issuer's key.
+func Issue(issuer *model.DID, subject map[string]any, opts ...Option) (*Credential, error) {
+	if len(subject) == 0 {
+		return nil, errors.New("empty credential subject")
+	}
+
+	options, err := buildOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	issuanceDate := options.issuanceDate.UTC().Truncate(time.Second)
+
+	cred := &Credential{
+		Context:           buildContext(options.contexts),
+		ID:                options.id,
+		Type:              append([]string{TypeVerifiableCredential}, options.types...),
+		Issuer:            issuer.ID,
+		IssuanceDate:      &issuanceDate,
+		CredentialSubject: subject,
+	}
+
+	if !options.expiryDate.IsZero() {
+		expiryDate := options.expiryDate.UTC().Truncate(time.Second)
+		if !expiryDate.After(issuanceDate) {
+			return nil, errors.New("credential expiration date should be after its issuance date")
+		}
+		cred.ExpirationDate = &expiryDate
+	}
+
+	doc, err := toMap(cred)
+	if err != nil {
+		return nil, err
+	}
+
+	cred.Proof, err = signDocument(doc, issuer, ProofPurposeAssertionMethod, "", "", time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return cred, nil
+}
+
+// ParseCredential unmarshals the credential from its JSON representation.
+// It doesn't verify the credential.
+func ParseCredential(b []byte) (*Credential, error) {
+	var cred Credential
+	if err := jsonw.Unmarshal(b, &cred); err != nil {
+		return nil, err
+	}
+	if !hasType(cred.Type, TypeVerifiableCredential) {
+		return nil, errors.New("not a verifiable credential")
+	}
+	return &cred, nil
+}
+
+// VerifyCredential verifies the credential's proof and validity period.
+// The verification is done over the given JSON representation, so that any
+// credential properties not defined in Credential are also covered.
+func VerifyCredential(ctx context.Context, b []byte, provider model.DIDProvider) (*Credential, error) {
+	cred, err := ParseCredential(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err = jsonw.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	if err = verifyCredentialDoc(ctx, cred, doc, provider); err != nil {
+		return nil, err
+	}
+
+	return cred, nil
+}
+
+func verifyCredentialDoc(ctx context.Context, cred *Credential, doc map[string]any, provider model.DIDProvider) error {
+	now := time.Now()
+	if cred.IssuanceDate != nil && cred.IssuanceDate.After(now) {
+		return ErrCredentialNotValid
+	}
+	if cred.ExpirationDate != nil && cred.ExpirationDate.Before(now) {
+		return ErrCredentialExpired
+	}
+
+	proof, err := verifyDocument(ctx, doc, cred.Issuer, provider)
+	if err != nil {
+		return err
+	}
+	if proof.ProofPurpose != ProofPurposeAssertionMethod {
+		return ErrProofPurpose
+	}
+
+	return nil
+}
+
+// Verify verifies the credential's proof and validity period.
+func (c *Credential) Verify(ctx context.Context, provider model.DIDProvider) error {
+	_, err := VerifyCredential(ctx, c.Bytes(), provider)
+	return err
+}
+
+func (c *Credential) Bytes() []byte {
+	b, _ := jsonw.Marshal(c)
+	return b
+}
+
+func hasType(types []string, t string) bool {
+	for _, val := range types {
+		if val == t {
+			return true
+		}
+	}
+	return false
+}
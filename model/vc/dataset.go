@@ -0,0 +1,94 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vc
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+)
+
+const (
+	// ContentTypeCredential is the content type of the meta resource of datasets
+	// that contain verifiable credentials.
+	ContentTypeCredential = TypeVerifiableCredential
+)
+
+type (
+	// DataSetStore is an interface for storing credentials as datasets (see wallet.Locker).
+	DataSetStore interface {
+		NewDataSetBuilder(ctx context.Context, opts ...dataset.BuilderOption) (dataset.Builder, error)
+	}
+
+	// DataSetLoader is an interface for loading credentials from datasets (see wallet.DataStore).
+	DataSetLoader interface {
+		Load(ctx context.Context, id string, opts ...dataset.LoadOption) (model.DataSet, error)
+	}
+)
+
+// Store saves the credential as a dataset with the credential as its meta resource.
+func Store(ctx context.Context, store DataSetStore, cred *Credential, expiryTime time.Time, opts ...dataset.BuilderOption) dataset.RecordFuture {
+	b, err := store.NewDataSetBuilder(ctx, opts...)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	if _, err = b.AddMetaResource(cred.Bytes(), dataset.WithContentType(ContentTypeCredential)); err != nil {
+		_ = b.Cancel()
+		return dataset.RecordFutureWithError(err)
+	}
+
+	return b.Submit(expiryTime)
+}
+
+// LoadBytes returns the JSON representation of the credential stored in the given dataset.
+func LoadBytes(ctx context.Context, ds model.DataSet) ([]byte, error) {
+	r, err := ds.MetaResource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// Load returns the credential stored in the given dataset. It doesn't verify the credential.
+func Load(ctx context.Context, ds model.DataSet) (*Credential, error) {
+	b, err := LoadBytes(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCredential(b)
+}
+
+// PresentDataSets builds a verifiable presentation from the credentials stored in
+// the datasets with the given record IDs.
+func PresentDataSets(ctx context.Context, loader DataSetLoader, recordIDs []string, holder *model.DID, opts ...Option) (*Presentation, error) {
+	credentials := make([][]byte, len(recordIDs))
+	for i, id := range recordIDs {
+		ds, err := loader.Load(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if credentials[i], err = LoadBytes(ctx, ds); err != nil {
+			return nil, err
+		}
+	}
+
+	return Present(holder, credentials, opts...)
+}
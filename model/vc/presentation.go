@@ -0,0 +1,143 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+// Presentation is a W3C Verifiable Presentation (https://www.w3.org/TR/vc-data-model/).
+// Credentials are kept in their original JSON form to preserve their proofs.
+type Presentation struct {
+	Context              []any             `json:"@context"`
+	ID                   string            `json:"id,omitempty"`
+	Type                 []string          `json:"type"`
+	Holder               string            `json:"holder"`
+	VerifiableCredential []json.RawMessage `json:"verifiableCredential,omitempty"`
+	Proof                *Proof            `json:"proof,omitempty"`
+}
+
+// Present builds a verifiable presentation of the given credentials (in their JSON form)
+// and signs it with the holder's key. Use WithChallenge and WithDomain options
+// to bind the presentation to a specific verifier's request.
+func Present(holder *model.DID, credentials [][]byte, opts ...Option) (*Presentation, error) {
+	options, err := buildOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	vp := &Presentation{
+		Context: buildContext(options.contexts),
+		ID:      options.id,
+		Type:    append([]string{TypeVerifiablePresentation}, options.types...),
+		Holder:  holder.ID,
+	}
+
+	for _, b := range credentials {
+		if _, err = ParseCredential(b); err != nil {
+			return nil, err
+		}
+		vp.VerifiableCredential = append(vp.VerifiableCredential, b)
+	}
+
+	doc, err := toMap(vp)
+	if err != nil {
+		return nil, err
+	}
+
+	vp.Proof, err = signDocument(doc, holder, ProofPurposeAuthentication, options.challenge, options.domain, options.issuanceDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return vp, nil
+}
+
+// ParsePresentation unmarshals the presentation from its JSON representation.
+// It doesn't verify the presentation.
+func ParsePresentation(b []byte) (*Presentation, error) {
+	var vp Presentation
+	if err := jsonw.Unmarshal(b, &vp); err != nil {
+		return nil, err
+	}
+	if !hasType(vp.Type, TypeVerifiablePresentation) {
+		return nil, errors.New("not a verifiable presentation")
+	}
+	return &vp, nil
+}
+
+// VerifyPresentation verifies the presentation's proof and all the credentials
+// included in the presentation. If the expected challenge isn't empty, the presentation's
+// proof should have the same challenge.
+func VerifyPresentation(ctx context.Context, b []byte, provider model.DIDProvider, challenge string) (*Presentation, error) {
+	vp, err := ParsePresentation(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]any
+	if err = jsonw.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	proof, err := verifyDocument(ctx, doc, vp.Holder, provider)
+	if err != nil {
+		return nil, err
+	}
+	if proof.ProofPurpose != ProofPurposeAuthentication {
+		return nil, ErrProofPurpose
+	}
+	if challenge != "" && proof.Challenge != challenge {
+		return nil, ErrChallengeMismatch
+	}
+
+	for i, credBytes := range vp.VerifiableCredential {
+		if _, err = VerifyCredential(ctx, credBytes, provider); err != nil {
+			return nil, fmt.Errorf("credential #%d: %w", i, err)
+		}
+	}
+
+	return vp, nil
+}
+
+// Credentials returns the credentials included in the presentation.
+func (vp *Presentation) Credentials() ([]*Credential, error) {
+	res := make([]*Credential, len(vp.VerifiableCredential))
+	for i, b := range vp.VerifiableCredential {
+		cred, err := ParseCredential(b)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = cred
+	}
+	return res, nil
+}
+
+// Verify verifies the presentation's proof and all the included credentials.
+func (vp *Presentation) Verify(ctx context.Context, provider model.DIDProvider, challenge string) error {
+	_, err := VerifyPresentation(ctx, vp.Bytes(), provider, challenge)
+	return err
+}
+
+func (vp *Presentation) Bytes() []byte {
+	b, _ := jsonw.Marshal(vp)
+	return b
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	ProofTypeEd25519Signature2018 = "Ed25519Signature2018"
+
+	ProofPurposeAssertionMethod = "assertionMethod"
+	ProofPurposeAuthentication  = "authentication"
+)
+
+var (
+	ErrProofNotFound      = errors.New("proof not found")
+	ErrInvalidProof       = errors.New("invalid proof")
+	ErrProofPurpose       = errors.New("unexpected proof purpose")
+	ErrChallengeMismatch  = errors.New("proof challenge mismatch")
+	ErrCredentialExpired  = errors.New("credential expired")
+	ErrCredentialNotValid = errors.New("credential not valid yet")
+)
+
+// Proof is a Linked Data proof attached to verifiable credentials and presentations.
+type Proof struct {
+	Type               string     `json:"type"`
+	Created            *time.Time `json:"created"`
+	VerificationMethod string     `json:"verificationMethod"`
+	ProofPurpose       string     `json:"proofPurpose"`
+	Challenge          string     `json:"challenge,omitempty"`
+	Domain             string     `json:"domain,omitempty"`
+	ProofValue         string     `json:"proofValue"`
+}
+
+// VerificationMethodID returns the ID of the default verification method
+// for the given DID.
+func VerificationMethodID(did string) string {
+	if strings.HasPrefix(did, "did:key:") {
+		return did + "#" + strings.TrimPrefix(did, "did:key:")
+	}
+	return did + "#key-1"
+}
+
+// signDocument attaches an Ed25519Signature2018 proof to the given JSON-LD document.
+// As per Linked Data Proofs specification, the signature covers both the proof
+// options (everything in the proof, except the signature value) and the document.
+func signDocument(doc map[string]any, signer *model.DID, purpose, challenge, domain string, created time.Time) (*Proof, error) {
+	if signer.SignKey == "" {
+		return nil, errors.New("signer's private key not provided")
+	}
+
+	created = created.UTC().Truncate(time.Second)
+	proof := &Proof{
+		Type:               ProofTypeEd25519Signature2018,
+		Created:            &created,
+		VerificationMethod: VerificationMethodID(signer.ID),
+		ProofPurpose:       purpose,
+		Challenge:          challenge,
+		Domain:             domain,
+	}
+
+	proofMap, err := toMap(proof)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(proofMap, "proofValue")
+	delete(doc, "proof")
+
+	hash, err := signatureHash(doc, proofMap)
+	if err != nil {
+		return nil, err
+	}
+
+	proof.ProofValue = base58.Encode(ed25519.Sign(signer.SignKeyValue(), hash))
+
+	return proof, nil
+}
+
+// verifyDocument verifies the proof attached to the given JSON-LD document and
+// returns the proof. The signer's DID is resolved using the given DID provider.
+func verifyDocument(ctx context.Context, doc map[string]any, signerID string, provider model.DIDProvider) (*Proof, error) {
+	proofVal, found := doc["proof"]
+	if !found {
+		return nil, ErrProofNotFound
+	}
+
+	proofMap, ok := proofVal.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: bad proof shape", ErrInvalidProof)
+	}
+
+	var proof Proof
+	if err := fromMap(proofMap, &proof); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProof, err.Error())
+	}
+
+	if proof.Type != ProofTypeEd25519Signature2018 {
+		return nil, fmt.Errorf("%w: unsupported proof type %s", ErrInvalidProof, proof.Type)
+	}
+
+	vmDID, _, _ := strings.Cut(proof.VerificationMethod, "#")
+	if vmDID != signerID {
+		return nil, fmt.Errorf("%w: verification method %s doesn't belong to %s", ErrInvalidProof,
+			proof.VerificationMethod, signerID)
+	}
+
+	signer, err := resolveDID(ctx, signerID, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	docCopy := make(map[string]any, len(doc))
+	for k, v := range doc {
+		if k != "proof" {
+			docCopy[k] = v
+		}
+	}
+	optionsCopy := make(map[string]any, len(proofMap))
+	for k, v := range proofMap {
+		if k != "proofValue" {
+			optionsCopy[k] = v
+		}
+	}
+
+	hash, err := signatureHash(docCopy, optionsCopy)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ed25519.Verify(signer.VerKeyValue(), hash, base58.Decode(proof.ProofValue)) {
+		return nil, fmt.Errorf("%w: signature verification failed", ErrInvalidProof)
+	}
+
+	return &proof, nil
+}
+
+// signatureHash returns the value to be signed: hash of the normalised proof options,
+// followed by the hash of the normalised document.
+func signatureHash(doc, proofOptions map[string]any) ([]byte, error) {
+	proofOptions["@context"] = doc["@context"]
+	defer delete(proofOptions, "@context")
+
+	optionsHash, err := hashMap(proofOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	docHash, err := hashMap(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(optionsHash, docHash...), nil
+}
+
+func hashMap(val map[string]any) ([]byte, error) {
+	b, err := jsonw.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := model.NewSignableDocument(b)
+	if err != nil {
+		return nil, err
+	}
+	return sd.Hash()
+}
+
+func resolveDID(ctx context.Context, id string, provider model.DIDProvider) (*model.DID, error) {
+	ddoc, err := provider.GetDIDDocument(ctx, id)
+	if err != nil {
+		// fall back to external DID methods, if supported
+		var resErr error
+		if ddoc, resErr = model.ResolveDIDDocument(ctx, id); resErr != nil {
+			return nil, err
+		}
+	}
+
+	return ddoc.ExtractIndyStyleDID()
+}
+
+func toMap(val any) (map[string]any, error) {
+	b, err := jsonw.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	var res map[string]any
+	if err = jsonw.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func fromMap(val map[string]any, dest any) error {
+	b, err := jsonw.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return jsonw.Unmarshal(b, dest)
+}
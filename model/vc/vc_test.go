@@ -0,0 +1,237 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vc_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/contexts"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	. "github.com/piprate/metalocker/model/vc"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registerDID(t *testing.T, env *testbase.TestMetaLockerEnvironment, seed string) *model.DID {
+	t.Helper()
+
+	did, err := model.GenerateDID(model.WithSeed(seed))
+	require.NoError(t, err)
+	ddoc, err := model.SimpleDIDDocument(did, nil)
+	require.NoError(t, err)
+	require.NoError(t, env.IdentityBackend.CreateDIDDocument(env.Ctx, ddoc))
+
+	return did
+}
+
+func testSubject() map[string]any {
+	return map[string]any{
+		"id":     "did:piprate:holder",
+		"degree": "Bachelor of Science",
+	}
+}
+
+func TestIssue(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	issuer := registerDID(t, env, "Issuer01")
+
+	cred, err := Issue(issuer, testSubject(), WithID("urn:uuid:1234"), WithType("UniversityDegreeCredential"))
+	require.NoError(t, err)
+	assert.Equal(t, issuer.ID, cred.Issuer)
+	assert.Equal(t, []string{TypeVerifiableCredential, "UniversityDegreeCredential"}, cred.Type)
+	require.NotNil(t, cred.Proof)
+	assert.Equal(t, ProofPurposeAssertionMethod, cred.Proof.ProofPurpose)
+	assert.Equal(t, issuer.ID+"#key-1", cred.Proof.VerificationMethod)
+
+	require.NoError(t, cred.Verify(env.Ctx, env.IdentityBackend))
+
+	verifiedCred, err := VerifyCredential(env.Ctx, cred.Bytes(), env.IdentityBackend)
+	require.NoError(t, err)
+	assert.Equal(t, cred.CredentialSubject, verifiedCred.CredentialSubject)
+
+	// tampered subject
+
+	cred.CredentialSubject["degree"] = "Master of Science"
+	assert.ErrorIs(t, cred.Verify(env.Ctx, env.IdentityBackend), ErrInvalidProof)
+
+	// tampered proof options
+
+	cred.CredentialSubject["degree"] = "Bachelor of Science"
+	require.NoError(t, cred.Verify(env.Ctx, env.IdentityBackend))
+	cred.Proof.ProofPurpose = ProofPurposeAuthentication
+	assert.ErrorIs(t, cred.Verify(env.Ctx, env.IdentityBackend), ErrInvalidProof)
+	cred.Proof.ProofPurpose = ProofPurposeAssertionMethod
+
+	// wrong issuer
+
+	anotherIssuer := registerDID(t, env, "Issuer02")
+	cred.Issuer = anotherIssuer.ID
+	assert.ErrorIs(t, cred.Verify(env.Ctx, env.IdentityBackend), ErrInvalidProof)
+
+	// no proof
+
+	cred.Issuer = issuer.ID
+	cred.Proof = nil
+	assert.ErrorIs(t, cred.Verify(env.Ctx, env.IdentityBackend), ErrProofNotFound)
+}
+
+func TestIssue_UndefinedTermsAreSigned(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	issuer := registerDID(t, env, "Issuer01")
+
+	cred, err := Issue(issuer, map[string]any{
+		"someUndefinedTerm": "value",
+	})
+	require.NoError(t, err)
+
+	b := strings.Replace(string(cred.Bytes()), `"value"`, `"another value"`, 1)
+	_, err = VerifyCredential(env.Ctx, []byte(b), env.IdentityBackend)
+	assert.ErrorIs(t, err, ErrInvalidProof)
+}
+
+func TestIssue_Expiry(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	issuer := registerDID(t, env, "Issuer01")
+
+	cred, err := Issue(issuer, testSubject(),
+		WithIssuanceDate(time.Now().Add(-2*time.Hour)),
+		WithExpirationDate(time.Now().Add(-time.Hour)))
+	require.NoError(t, err)
+	assert.ErrorIs(t, cred.Verify(env.Ctx, env.IdentityBackend), ErrCredentialExpired)
+
+	cred, err = Issue(issuer, testSubject(), WithIssuanceDate(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+	assert.ErrorIs(t, cred.Verify(env.Ctx, env.IdentityBackend), ErrCredentialNotValid)
+
+	_, err = Issue(issuer, testSubject(), WithExpirationDate(time.Now().Add(-time.Hour)))
+	assert.Error(t, err)
+}
+
+func TestIssue_KeyDID(t *testing.T) {
+	_ = contexts.PreloadContextsIntoMemory()
+
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	// did:key issuers don't need to be registered
+
+	issuer, err := model.GenerateDID(model.WithMethod(model.DIDMethodKey))
+	require.NoError(t, err)
+
+	cred, err := Issue(issuer, testSubject())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(cred.Proof.VerificationMethod, issuer.ID+"#z6Mk"))
+	assert.NoError(t, cred.Verify(env.Ctx, env.IdentityBackend))
+}
+
+func TestPresent(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	issuer := registerDID(t, env, "Issuer01")
+	holder := registerDID(t, env, "Holder01")
+
+	cred1, err := Issue(issuer, testSubject())
+	require.NoError(t, err)
+	cred2, err := Issue(issuer, map[string]any{"id": holder.ID, "name": "Alice"})
+	require.NoError(t, err)
+
+	vp, err := Present(holder, [][]byte{cred1.Bytes(), cred2.Bytes()}, WithChallenge("abc"), WithDomain("example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, holder.ID, vp.Holder)
+	assert.Equal(t, ProofPurposeAuthentication, vp.Proof.ProofPurpose)
+
+	verifiedVP, err := VerifyPresentation(env.Ctx, vp.Bytes(), env.IdentityBackend, "abc")
+	require.NoError(t, err)
+	creds, err := verifiedVP.Credentials()
+	require.NoError(t, err)
+	require.Len(t, creds, 2)
+	assert.Equal(t, "Alice", creds[1].CredentialSubject["name"])
+
+	// wrong challenge
+
+	assert.ErrorIs(t, vp.Verify(env.Ctx, env.IdentityBackend, "xyz"), ErrChallengeMismatch)
+
+	// tampered challenge
+
+	vp.Proof.Challenge = "xyz"
+	assert.ErrorIs(t, vp.Verify(env.Ctx, env.IdentityBackend, "xyz"), ErrInvalidProof)
+	vp.Proof.Challenge = "abc"
+
+	// tampered credential
+
+	cred2.CredentialSubject["name"] = "Mallory"
+	vp.VerifiableCredential[1] = cred2.Bytes()
+	assert.ErrorIs(t, vp.Verify(env.Ctx, env.IdentityBackend, "abc"), ErrInvalidProof)
+
+	// credentials must be valid
+
+	_, err = Present(holder, [][]byte{[]byte(`{"type":["Something"]}`)})
+	assert.Error(t, err)
+}
+
+func TestStore_PresentDataSets(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw := env.CreateCustomAccount(t, "test@example.com", "Test", model.AccessLevelHosted)
+	idy, err := dw.NewIdentity(ctx, model.AccessLevelHosted, "")
+	require.NoError(t, err)
+	locker, err := idy.NewLocker(ctx, "Credentials")
+	require.NoError(t, err)
+
+	ix, err := dw.CreateRootIndex(ctx, testbase.IndexStoreName)
+	require.NoError(t, err)
+	updater, err := dw.IndexUpdater(ctx, ix)
+	require.NoError(t, err)
+	defer updater.Close()
+
+	issuer := registerDID(t, env, "Issuer01")
+
+	cred, err := Issue(issuer, map[string]any{"id": idy.ID(), "name": "Alice"})
+	require.NoError(t, err)
+
+	f := Store(ctx, locker, cred, expiry.FromNow("1h"), dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	require.NoError(t, updater.Sync(ctx))
+
+	ds, err := dw.DataStore().Load(ctx, f.ID())
+	require.NoError(t, err)
+	assert.Equal(t, ContentTypeCredential, ds.Impression().MetaResource.ContentType)
+
+	storedCred, err := Load(ctx, ds)
+	require.NoError(t, err)
+	require.NoError(t, storedCred.Verify(ctx, dw.Services().DIDProvider()))
+
+	vp, err := PresentDataSets(ctx, dw.DataStore(), []string{f.ID()}, idy.DID(), WithChallenge("abc"))
+	require.NoError(t, err)
+
+	_, err = VerifyPresentation(ctx, vp.Bytes(), dw.Services().DIDProvider(), "abc")
+	require.NoError(t, err)
+}
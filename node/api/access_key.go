@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/piprate/metalocker/model"
@@ -106,6 +107,24 @@ func (h *AccountHandler) PostAccessKeyHandler(c *gin.Context) {
 		return
 	}
 
+	if parentKey := apibase.GetAccessKey(c); parentKey != nil && parentKey.IsRestricted() {
+		// restricted keys can't be used to mint keys with broader permissions
+		apibase.AbortWithError(c, http.StatusForbidden, "Restricted access keys can't create new access keys")
+		return
+	}
+
+	switch ak.Permission {
+	case "", model.AccessKeyPermissionReadOnly, model.AccessKeyPermissionReadWrite:
+	default:
+		apibase.AbortWithError(c, http.StatusBadRequest, "Unsupported access key permission")
+		return
+	}
+
+	if ak.IsExpired(time.Now()) {
+		apibase.AbortWithError(c, http.StatusBadRequest, "Access key already expired")
+		return
+	}
+
 	if ak.ID == "" {
 		ak.ID = model.GenerateAccessKeyID()
 	}
@@ -58,6 +58,11 @@ func InitAccountRoutes(rg *gin.RouterGroup, identityBackend storage.IdentityBack
 	rg.POST("/account/:aid/access-key", h.PostAccessKeyHandler)
 	rg.GET("/account/:aid/access-key/:id", h.GetAccessKeyHandler)
 	rg.DELETE("/account/:aid/access-key/:id", h.DeleteAccessKeyHandler)
+	rg.GET("/account/:aid/mfa", h.GetSecondFactorListHandler)
+	rg.POST("/account/:aid/mfa", h.PostSecondFactorHandler)
+	rg.POST("/account/:aid/mfa/backup-codes", h.PostBackupCodesHandler)
+	rg.POST("/account/:aid/mfa/:id/confirm", h.PostSecondFactorConfirmHandler)
+	rg.DELETE("/account/:aid/mfa/:id", h.DeleteSecondFactorHandler)
 
 	rg.GET("/account/:aid/identity", h.GetIdentityListHandler)
 	rg.POST("/account/:aid/identity", h.PostIdentityHandler)
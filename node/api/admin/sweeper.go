@@ -0,0 +1,56 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/node/sweeper"
+	"github.com/piprate/metalocker/sdk/apibase"
+)
+
+type SweeperHandler struct {
+	sweeper *sweeper.Sweeper
+}
+
+// InitSweeperRoutes adds admin routes to inspect and trigger the lease sweeper.
+func InitSweeperRoutes(r *gin.Engine, path string, adminAuthFunc gin.HandlerFunc, sw *sweeper.Sweeper) {
+	h := &SweeperHandler{
+		sweeper: sw,
+	}
+	adm := r.Group(path)
+	adm.Use(adminAuthFunc)
+	adm.Use(apibase.ContextLoggerHandler)
+	{
+		adm.GET("/sweeper", h.GetMetricsHandler)
+		adm.POST("/sweeper/run", h.PostRunHandler)
+	}
+}
+
+func (h *SweeperHandler) GetMetricsHandler(c *gin.Context) {
+	apibase.JSON(c, http.StatusOK, h.sweeper.Metrics())
+}
+
+func (h *SweeperHandler) PostRunHandler(c *gin.Context) {
+	report, err := h.sweeper.Sweep(c)
+	if err != nil {
+		log := apibase.CtxLogger(c)
+		log.Err(err).Msg("Lease sweep failed")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	apibase.JSON(c, http.StatusOK, report)
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/sdk/apibase"
+)
+
+type (
+	ThrottleHandler struct {
+		throttle *apibase.Throttle
+	}
+
+	LockoutResponse struct {
+		UserID      string     `json:"userID"`
+		Locked      bool       `json:"locked"`
+		LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+	}
+)
+
+// InitThrottleRoutes adds admin routes to inspect and remove account lockouts.
+func InitThrottleRoutes(r *gin.Engine, path string, adminAuthFunc gin.HandlerFunc, throttle *apibase.Throttle) {
+	h := &ThrottleHandler{
+		throttle: throttle,
+	}
+	adm := r.Group(path)
+	adm.Use(adminAuthFunc)
+	adm.Use(apibase.ContextLoggerHandler)
+	{
+		adm.GET("/lockout/:id", h.GetLockoutHandler)
+		adm.DELETE("/lockout/:id", h.DeleteLockoutHandler)
+	}
+}
+
+func (h *ThrottleHandler) GetLockoutHandler(c *gin.Context) {
+	userID := c.Params.ByName("id")
+
+	until, err := h.throttle.LockedUntil(c, userID)
+	if err != nil {
+		log := apibase.CtxLogger(c)
+		log.Err(err).Str("userID", userID).Msg("Failed to read account lockout")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	rsp := &LockoutResponse{
+		UserID: userID,
+	}
+	if !until.IsZero() {
+		rsp.Locked = true
+		rsp.LockedUntil = &until
+	}
+
+	apibase.JSON(c, http.StatusOK, rsp)
+}
+
+func (h *ThrottleHandler) DeleteLockoutHandler(c *gin.Context) {
+	userID := c.Params.ByName("id")
+
+	if err := h.throttle.Unlock(c, userID); err != nil {
+		log := apibase.CtxLogger(c)
+		log.Err(err).Str("userID", userID).Msg("Failed to unlock account")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,470 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/storage"
+	"github.com/piprate/metalocker/wallet"
+)
+
+type (
+	// DataSetHandler serves dataset listings for hosted accounts from a root index
+	// maintained by the server. The server unlocks the account's data wallet
+	// at the managed level, using the secret embedded in the caller's token,
+	// and brings the root index up to date before answering each request.
+	// The index is updated incrementally, starting from the top block recorded
+	// in the index, and locked again once the request is completed.
+	// If the request is authenticated with an access key restricted to specific
+	// lockers or identities, the responses only include records from the lockers
+	// within the key's scope.
+	DataSetHandler struct {
+		identityBackend storage.IdentityBackend
+		factory         *wallet.LocalFactory
+		indexClient     index.Client
+		indexStoreName  string
+
+		mu       sync.Mutex
+		accounts map[string]*accountIndexState
+	}
+
+	// accountIndexState tracks requests that use the account's root index.
+	accountIndexState struct {
+		users int
+		sync  sync.Mutex
+	}
+
+	// lockerScope is the set of lockers available to the caller. Nil scope
+	// means all the account's lockers are available.
+	lockerScope map[string]bool
+)
+
+func (s lockerScope) allows(lockerID string) bool {
+	return s == nil || s[lockerID]
+}
+
+func (s lockerScope) filterHistory(history []*index.VariantRecordState) []*index.VariantRecordState {
+	if s == nil {
+		return history
+	}
+	res := make([]*index.VariantRecordState, 0, len(history))
+	for _, rs := range history {
+		if s.allows(rs.LockerID) {
+			res = append(res, rs)
+		}
+	}
+	return res
+}
+
+func NewDataSetHandler(identityBackend storage.IdentityBackend, factory *wallet.LocalFactory, indexClient index.Client,
+	indexStoreName string) *DataSetHandler {
+	return &DataSetHandler{
+		identityBackend: identityBackend,
+		factory:         factory,
+		indexClient:     indexClient,
+		indexStoreName:  indexStoreName,
+		accounts:        make(map[string]*accountIndexState),
+	}
+}
+
+func InitDataSetRoutes(rg *gin.RouterGroup, identityBackend storage.IdentityBackend, factory *wallet.LocalFactory,
+	indexClient index.Client, indexStoreName string) {
+
+	h := NewDataSetHandler(identityBackend, factory, indexClient, indexStoreName)
+
+	rg.GET("/dataset", h.GetDataSetListHandler)
+	rg.GET("/dataset/:id", h.GetDataSetHandler)
+	rg.GET("/variant", h.GetVariantListHandler)
+	rg.GET("/variant/:id", h.GetVariantHandler)
+	rg.GET("/data-asset/:id/dataset", h.GetAssetDataSetListHandler)
+}
+
+func (h *DataSetHandler) GetDataSetListHandler(c *gin.Context) {
+	opts, ok := readPageOptions(c)
+	if !ok {
+		return
+	}
+
+	rootIndex, scope, release, ok := h.openRootIndex(c)
+	if !ok {
+		return
+	}
+	defer release()
+
+	lockerID := c.Query("locker")
+	if lockerID != "" && !scope.allows(lockerID) {
+		apibase.AbortWithError(c, http.StatusForbidden, "locker is outside of the access key's scope")
+		return
+	}
+
+	page, err := rootIndex.ListRecords(c, lockerID, c.Query("participant"), opts)
+	if err != nil {
+		handlePageError(c, err, "Error when listing data sets")
+		return
+	}
+
+	if scope != nil {
+		records := page.Records[:0]
+		for _, rs := range page.Records {
+			if scope.allows(rs.LockerID) {
+				records = append(records, rs)
+			}
+		}
+		page.Records = records
+	}
+
+	apibase.JSON(c, http.StatusOK, page)
+}
+
+func (h *DataSetHandler) GetDataSetHandler(c *gin.Context) {
+	rootIndex, scope, release, ok := h.openRootIndex(c)
+	if !ok {
+		return
+	}
+	defer release()
+
+	rs, err := rootIndex.GetRecord(c, c.Params.ByName("id"))
+	if err != nil {
+		log := apibase.CtxLogger(c)
+		log.Err(err).Msg("Error when reading data set state")
+		apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if rs == nil || !scope.allows(rs.LockerID) {
+		apibase.AbortWithError(c, http.StatusNotFound, "data set not found")
+		return
+	}
+
+	apibase.JSON(c, http.StatusOK, rs)
+}
+
+func (h *DataSetHandler) GetVariantListHandler(c *gin.Context) {
+	opts, ok := readPageOptions(c)
+	if !ok {
+		return
+	}
+
+	rootIndex, scope, release, ok := h.openRootIndex(c)
+	if !ok {
+		return
+	}
+	defer release()
+
+	lockerID := c.Query("locker")
+	if lockerID != "" && !scope.allows(lockerID) {
+		apibase.AbortWithError(c, http.StatusForbidden, "locker is outside of the access key's scope")
+		return
+	}
+
+	page, err := rootIndex.ListVariants(c, lockerID, c.Query("participant"), c.Query("history") == "true", opts)
+	if err != nil {
+		handlePageError(c, err, "Error when listing variants")
+		return
+	}
+
+	if scope != nil {
+		variants := page.Variants[:0]
+		for _, v := range page.Variants {
+			if scope.allows(v.Master.LockerID) {
+				v.History = scope.filterHistory(v.History)
+				variants = append(variants, v)
+			}
+		}
+		page.Variants = variants
+	}
+
+	apibase.JSON(c, http.StatusOK, page)
+}
+
+func (h *DataSetHandler) GetVariantHandler(c *gin.Context) {
+	rootIndex, scope, release, ok := h.openRootIndex(c)
+	if !ok {
+		return
+	}
+	defer release()
+
+	master, history, err := rootIndex.GetVariant(c, c.Params.ByName("id"), c.Query("history") == "true")
+	if err != nil {
+		if errors.Is(err, index.ErrVariantNotFound) {
+			apibase.AbortWithError(c, http.StatusNotFound, "variant not found")
+		} else {
+			log := apibase.CtxLogger(c)
+			log.Err(err).Msg("Error when reading variant")
+			apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	if !scope.allows(master.LockerID) {
+		apibase.AbortWithError(c, http.StatusNotFound, "variant not found")
+		return
+	}
+
+	apibase.JSON(c, http.StatusOK, &index.VariantPageEntry{
+		VariantID: c.Params.ByName("id"),
+		Master:    master,
+		History:   scope.filterHistory(history),
+	})
+}
+
+func (h *DataSetHandler) GetAssetDataSetListHandler(c *gin.Context) {
+	opts, ok := readPageOptions(c)
+	if !ok {
+		return
+	}
+
+	rootIndex, scope, release, ok := h.openRootIndex(c)
+	if !ok {
+		return
+	}
+	defer release()
+
+	page, err := rootIndex.ListAssetRecords(c, c.Params.ByName("id"), opts)
+	if err != nil {
+		handlePageError(c, err, "Error when listing asset data sets")
+		return
+	}
+
+	if scope != nil {
+		// asset index entries don't include lockers, so we have to look them up
+
+		records := page.Records[:0]
+		for _, e := range page.Records {
+			rs, err := rootIndex.GetRecord(c, e.RecordID)
+			if err != nil {
+				handlePageError(c, err, "Error when reading data set state")
+				return
+			}
+			if rs != nil && scope.allows(rs.LockerID) {
+				records = append(records, e)
+			}
+		}
+		page.Records = records
+	}
+
+	apibase.JSON(c, http.StatusOK, page)
+}
+
+// openRootIndex returns an up-to-date root index for the calling account, the scope
+// of the caller's access key and a function that must be called once the request
+// is done with the index. If it returns false, the request has already been aborted.
+func (h *DataSetHandler) openRootIndex(c *gin.Context) (index.RootIndex, lockerScope, func(), bool) {
+	log := apibase.CtxLogger(c)
+
+	acct, err := h.identityBackend.GetAccount(c, apibase.GetUserID(c))
+	if err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			apibase.AbortWithError(c, http.StatusNotFound, "account not found")
+		} else {
+			log.Err(err).Msg("Error when retrieving account")
+			apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+		}
+		return nil, nil, nil, false
+	}
+
+	if acct.AccessLevel != model.AccessLevelHosted {
+		apibase.AbortWithError(c, http.StatusForbidden, "data set listing is only available for hosted accounts")
+		return nil, nil, nil, false
+	}
+
+	managedKey := apibase.GetManagedKey(c)
+	if managedKey == nil {
+		apibase.AbortWithError(c, http.StatusForbidden, "client secret not provided")
+		return nil, nil, nil, false
+	}
+
+	dw, err := h.factory.CreateDataWallet(acct)
+	if err != nil {
+		log.Err(err).Msg("Error when creating data wallet")
+		apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+		return nil, nil, nil, false
+	}
+
+	if err = dw.UnlockAsManaged(c, managedKey); err != nil {
+		log.Err(err).Msg("Failed to unlock data wallet")
+		apibase.AbortWithError(c, http.StatusUnauthorized, "failed to unlock data wallet")
+		return nil, nil, nil, false
+	}
+	defer func() { _ = dw.Lock() }()
+
+	scope, err := accessKeyScope(c, dw)
+	if err != nil {
+		log.Err(err).Msg("Error when reading lockers")
+		apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+		return nil, nil, nil, false
+	}
+
+	st := h.acquire(acct.ID)
+
+	rootIndex, err := h.indexClient.RootIndex(c, acct.ID, model.AccessLevelManaged)
+	if err != nil {
+		if !errors.Is(err, index.ErrIndexNotFound) {
+			log.Err(err).Msg("Error when opening root index")
+			apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+			h.release(acct.ID, nil)
+			return nil, nil, nil, false
+		}
+
+		// serialise index creation for the same account
+
+		st.sync.Lock()
+		rootIndex, err = h.indexClient.RootIndex(c, acct.ID, model.AccessLevelManaged)
+		if errors.Is(err, index.ErrIndexNotFound) {
+			log.Info().Msg("Creating hosted root index")
+			rootIndex, err = dw.CreateRootIndex(c, h.indexStoreName)
+		}
+		st.sync.Unlock()
+		if err != nil {
+			log.Err(err).Msg("Error when creating root index")
+			apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+			h.release(acct.ID, nil)
+			return nil, nil, nil, false
+		}
+	}
+
+	if rootIndex.IsLocked() {
+		key, err := dw.EncryptionKey(acct.ID, model.AccessLevelManaged)
+		if err == nil {
+			err = rootIndex.Unlock(key[:])
+		}
+		if err != nil {
+			log.Err(err).Msg("Failed to unlock root index")
+			apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+			h.release(acct.ID, rootIndex)
+			return nil, nil, nil, false
+		}
+	}
+
+	// serialise index updates for the same account, but don't make concurrent
+	// requests wait: they are served from the current state of the index
+
+	if st.sync.TryLock() {
+		updater, err := dw.IndexUpdater(c, rootIndex)
+		if err == nil {
+			err = updater.Sync(c)
+			_ = updater.Close()
+		}
+		st.sync.Unlock()
+		if err != nil {
+			log.Err(err).Msg("Failed to update root index")
+			apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+			h.release(acct.ID, rootIndex)
+			return nil, nil, nil, false
+		}
+	}
+
+	return rootIndex, scope, func() { h.release(acct.ID, rootIndex) }, true
+}
+
+// accessKeyScope returns the lockers available to the request's access key, or nil
+// if the request isn't restricted to specific lockers or identities.
+func accessKeyScope(c *gin.Context, dw wallet.DataWallet) (lockerScope, error) {
+	ak := apibase.GetAccessKey(c)
+	if ak == nil || (len(ak.Lockers) == 0 && len(ak.Identities) == 0) {
+		return nil, nil
+	}
+
+	lockers, err := dw.GetLockers(c)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := make(lockerScope)
+	for _, l := range lockers {
+		if ak.AllowsLocker(l) {
+			scope[l.ID] = true
+		}
+	}
+
+	return scope, nil
+}
+
+// acquire registers a request that uses the account's root index.
+func (h *DataSetHandler) acquire(accountID string) *accountIndexState {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	st, found := h.accounts[accountID]
+	if !found {
+		st = &accountIndexState{}
+		h.accounts[accountID] = st
+	}
+	st.users++
+
+	return st
+}
+
+// release locks the root index, if provided, and unregisters the request.
+// The account's state is discarded when it has no more requests.
+func (h *DataSetHandler) release(accountID string, rootIndex index.RootIndex) {
+	if rootIndex != nil {
+		rootIndex.Lock()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if st, found := h.accounts[accountID]; found {
+		st.users--
+		if st.users == 0 {
+			delete(h.accounts, accountID)
+		}
+	}
+}
+
+func readPageOptions(c *gin.Context) (index.PageOptions, bool) {
+	opts := index.PageOptions{
+		Cursor: c.Query("cursor"),
+		Order:  index.SortOrder(c.Query("order")),
+	}
+
+	switch opts.Order {
+	case "", index.SortAscending, index.SortDescending:
+	default:
+		apibase.AbortWithError(c, http.StatusBadRequest, "bad sort order")
+		return opts, false
+	}
+
+	if sizeStr := c.Query("pageSize"); sizeStr != "" {
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil || size < 0 {
+			apibase.AbortWithError(c, http.StatusBadRequest, "bad page size")
+			return opts, false
+		}
+		opts.PageSize = size
+	}
+
+	return opts, true
+}
+
+func handlePageError(c *gin.Context, err error, msg string) {
+	if errors.Is(err, index.ErrInvalidCursor) {
+		apibase.AbortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log := apibase.CtxLogger(c)
+	log.Err(err).Msg(msg)
+	apibase.AbortWithError(c, http.StatusInternalServerError, "internal server error")
+}
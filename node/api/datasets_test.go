@@ -0,0 +1,248 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	. "github.com/piprate/metalocker/node/api"
+	"github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataSetHandler(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw := env.CreateCustomAccount(t, "hosted@example.com", "John Doe", model.AccessLevelHosted)
+	acct := dw.Account()
+
+	managedKey, err := acct.ExtractManagedKey(account.HashUserPassword(testbase.TestAccountPassword))
+	require.NoError(t, err)
+
+	lockers, err := dw.GetLockers(ctx)
+	require.NoError(t, err)
+
+	var lockerID string
+	for _, l := range lockers {
+		if l.AccessLevel == model.AccessLevelManaged {
+			lockerID = l.ID
+		}
+	}
+	require.NotEmpty(t, lockerID)
+
+	var recordIDs []string
+	for i := 0; i < 3; i++ {
+		lb, err := dw.DataStore().NewDataSetBuilder(ctx, lockerID, dataset.WithVault(testbase.TestVaultName))
+		require.NoError(t, err)
+		_, err = lb.AddMetaResource(map[string]any{"type": "Note", "n": i})
+		require.NoError(t, err)
+		f := lb.Submit(expiry.FromNow("1h"))
+		require.NoError(t, f.Wait(time.Second*5))
+		recordIDs = append(recordIDs, f.ID())
+	}
+
+	h := NewDataSetHandler(env.IdentityBackend, env.Factory, env.IndexClient, testbase.IndexStoreName)
+
+	var accessKey *model.AccessKey
+
+	invoke := func(fn gin.HandlerFunc, userID string, key *model.AESKey, query url.Values, params gin.Params) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/test-url?"+query.Encode(), http.NoBody)
+		c.Set(apibase.UserIDKey, userID)
+		if key != nil {
+			c.Set(apibase.ClientSecretKey, key)
+		}
+		if accessKey != nil {
+			c.Set(apibase.AccessKeyKey, accessKey)
+		}
+		c.Params = params
+
+		fn(c)
+
+		return rec
+	}
+
+	// no client secret
+
+	rec := invoke(h.GetDataSetListHandler, acct.ID, nil, nil, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// not a hosted account
+
+	managedAcct := createTestAccount(t, "managed@example.com", model.AccessLevelManaged, "", env)
+	rec = invoke(h.GetDataSetListHandler, managedAcct.ID, managedKey, nil, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// list data sets, page by page
+
+	rec = invoke(h.GetDataSetListHandler, acct.ID, managedKey, url.Values{"pageSize": {"2"}}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var page index.RecordPage
+	readBody(t, rec, &page)
+	require.Len(t, page.Records, 2)
+	require.NotEmpty(t, page.NextCursor)
+	assert.Equal(t, recordIDs[0], page.Records[0].ID)
+	assert.Equal(t, recordIDs[1], page.Records[1].ID)
+
+	rec = invoke(h.GetDataSetListHandler, acct.ID, managedKey, url.Values{"pageSize": {"2"}, "cursor": {page.NextCursor}}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	page = index.RecordPage{}
+	readBody(t, rec, &page)
+	require.Len(t, page.Records, 1)
+	assert.Equal(t, recordIDs[2], page.Records[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	// filter by locker
+
+	rec = invoke(h.GetDataSetListHandler, acct.ID, managedKey, url.Values{"locker": {"unknown"}}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	page = index.RecordPage{}
+	readBody(t, rec, &page)
+	assert.Empty(t, page.Records)
+
+	// bad parameters
+
+	rec = invoke(h.GetDataSetListHandler, acct.ID, managedKey, url.Values{"cursor": {"bad-cursor"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = invoke(h.GetDataSetListHandler, acct.ID, managedKey, url.Values{"order": {"sideways"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// fetch a data set
+
+	rec = invoke(h.GetDataSetHandler, acct.ID, managedKey, nil, gin.Params{{Key: "id", Value: recordIDs[1]}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var rs index.RecordState
+	readBody(t, rec, &rs)
+	assert.Equal(t, recordIDs[1], rs.ID)
+	assert.Equal(t, lockerID, rs.LockerID)
+
+	rec = invoke(h.GetDataSetHandler, acct.ID, managedKey, nil, gin.Params{{Key: "id", Value: "unknown"}})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// list and fetch variants
+
+	rec = invoke(h.GetVariantListHandler, acct.ID, managedKey, url.Values{"order": {"desc"}}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var variantPage index.VariantPage
+	readBody(t, rec, &variantPage)
+	require.Len(t, variantPage.Variants, 3)
+	assert.Equal(t, recordIDs[2], variantPage.Variants[0].Master.ID)
+
+	variantID := variantPage.Variants[0].VariantID
+
+	rec = invoke(h.GetVariantHandler, acct.ID, managedKey, nil, gin.Params{{Key: "id", Value: variantID}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var variant index.VariantPageEntry
+	readBody(t, rec, &variant)
+	assert.Equal(t, variantID, variant.VariantID)
+	assert.Equal(t, recordIDs[2], variant.Master.ID)
+
+	rec = invoke(h.GetVariantHandler, acct.ID, managedKey, nil, gin.Params{{Key: "id", Value: "unknown"}})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// access keys restricted to other lockers don't see the data sets
+
+	idy, err := dw.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+	otherLocker, err := idy.NewLocker(ctx, "Other Locker")
+	require.NoError(t, err)
+	f := otherLocker.Store(ctx, map[string]any{"type": "Note", "n": 3}, expiry.FromNow("1h"),
+		dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, f.Wait(time.Second*5))
+
+	rec = invoke(h.GetAssetDataSetListHandler, acct.ID, managedKey, nil, gin.Params{{Key: "id", Value: variant.Master.AssetID}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var assetPage index.AssetRecordPage
+	readBody(t, rec, &assetPage)
+	require.Len(t, assetPage.Records, 1)
+
+	accessKey = &model.AccessKey{AccountID: acct.ID, Lockers: []string{otherLocker.ID()}}
+
+	rec = invoke(h.GetDataSetListHandler, acct.ID, managedKey, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	page = index.RecordPage{}
+	readBody(t, rec, &page)
+	require.Len(t, page.Records, 1)
+	assert.Equal(t, f.ID(), page.Records[0].ID)
+
+	rec = invoke(h.GetDataSetListHandler, acct.ID, managedKey, url.Values{"locker": {lockerID}}, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = invoke(h.GetDataSetHandler, acct.ID, managedKey, nil, gin.Params{{Key: "id", Value: recordIDs[1]}})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = invoke(h.GetVariantHandler, acct.ID, managedKey, nil, gin.Params{{Key: "id", Value: variantID}})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = invoke(h.GetVariantListHandler, acct.ID, managedKey, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	variantPage = index.VariantPage{}
+	readBody(t, rec, &variantPage)
+	require.Len(t, variantPage.Variants, 1)
+	assert.Equal(t, f.ID(), variantPage.Variants[0].Master.ID)
+
+	rec = invoke(h.GetAssetDataSetListHandler, acct.ID, managedKey, nil, gin.Params{{Key: "id", Value: variant.Master.AssetID}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assetPage = index.AssetRecordPage{}
+	readBody(t, rec, &assetPage)
+	assert.Empty(t, assetPage.Records)
+
+	accessKey = nil
+
+	// concurrent requests don't wait for each other's index updates
+
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = invoke(h.GetDataSetListHandler, acct.ID, managedKey, nil, nil).Code
+		}(i)
+	}
+	wg.Wait()
+
+	for _, code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+}
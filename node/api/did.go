@@ -45,6 +45,14 @@ func (h *DIDHandler) GetDIDDocumentHandler(c *gin.Context) {
 	iid := c.Params.ByName("id")
 
 	ddoc, err := h.didStorage.GetDIDDocument(c, iid)
+	if errors.Is(err, storage.ErrDIDNotFound) {
+		// the DID may belong to an external DID method (did:key, did:web, etc.)
+		ddoc, err = model.ResolveDIDDocument(c, iid)
+		if errors.Is(err, model.ErrDIDMethodNotSupported) || errors.Is(err, model.ErrDIDDocumentNotFound) ||
+			errors.Is(err, model.ErrInvalidDID) {
+			err = storage.ErrDIDNotFound
+		}
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrDIDNotFound) {
 			c.AbortWithStatus(http.StatusNotFound)
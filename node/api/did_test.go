@@ -0,0 +1,80 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/model"
+	. "github.com/piprate/metalocker/node/api"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDIDHandler_GetDIDDocumentHandler(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	r := gin.New()
+	InitDIDRoutes(r.Group("/v1"), env.IdentityBackend)
+
+	invoke := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/v1/did/"+id, http.NoBody)
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// stored DID
+
+	did, err := model.GenerateDID()
+	require.NoError(t, err)
+	ddoc, err := model.SimpleDIDDocument(did, nil)
+	require.NoError(t, err)
+	require.NoError(t, env.IdentityBackend.CreateDIDDocument(env.Ctx, ddoc))
+
+	rec := invoke(did.ID)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var rsp model.DIDDocument
+	readBody(t, rec, &rsp)
+	assert.Equal(t, did.ID, rsp.ID)
+
+	// did:key DIDs are resolved without being stored
+
+	keyDID, err := model.GenerateDID(model.WithMethod(model.DIDMethodKey))
+	require.NoError(t, err)
+
+	rec = invoke(keyDID.ID)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rsp = model.DIDDocument{}
+	readBody(t, rec, &rsp)
+	extractedDID, err := rsp.ExtractIndyStyleDID()
+	require.NoError(t, err)
+	assert.Equal(t, keyDID.VerKey, extractedDID.VerKey)
+
+	// unknown DIDs
+
+	rec = invoke("did:piprate:unknown")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = invoke("did:unknown:xyz")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
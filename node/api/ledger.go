@@ -23,6 +23,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/ledger"
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/sdk/apibase"
 	"github.com/piprate/metalocker/vaults"
@@ -49,6 +50,7 @@ func InitLedgerRoutes(rg *gin.RouterGroup, ledger model.Ledger, offChainVault va
 	rg.POST("/lrec", h.PostLedgerRecordHandler)
 	rg.GET("/lrec/:id", h.GetLedgerRecordHandler)
 	rg.GET("/lrec/:id/state", h.GetLedgerRecordStateHandler)
+	rg.GET("/lrec/:id/proof", h.GetLedgerRecordProofHandler)
 
 	rg.GET("/head/:id", h.GetAssetHeadHandler)
 
@@ -58,6 +60,7 @@ func InitLedgerRoutes(rg *gin.RouterGroup, ledger model.Ledger, offChainVault va
 	rg.GET("/ledger/block/:number/records", h.GetLedgerBlockRecordsHandler)
 	rg.GET("/ledger/chain/:start/:depth", h.GetLedgerChainHandler)
 	rg.GET("/ledger/data-asset/:id/state", h.GetDataAssetStateHandler)
+	rg.GET("/ledger/verify", h.GetLedgerVerificationHandler)
 }
 
 func (h *LedgerHandler) GetLedgerGenesisHandler(c *gin.Context) {
@@ -197,3 +200,16 @@ func (h *LedgerHandler) GetLedgerBlockRecordsHandler(c *gin.Context) {
 		c.Data(http.StatusOK, "text/csv", buf.Bytes())
 	}
 }
+
+func (h *LedgerHandler) GetLedgerVerificationHandler(c *gin.Context) {
+	log := apibase.CtxLogger(c)
+
+	report, err := ledger.VerifyLedger(c, h.ledger)
+	if err != nil {
+		log.Err(err).Msg("Error when verifying the ledger")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	apibase.JSON(c, http.StatusOK, report)
+}
@@ -88,6 +88,31 @@ func (h *LedgerHandler) GetLedgerRecordStateHandler(c *gin.Context) {
 	}
 }
 
+func (h *LedgerHandler) GetLedgerRecordProofHandler(c *gin.Context) {
+	rid := c.Params.ByName("id")
+
+	prover, ok := h.ledger.(model.RecordInclusionProver)
+	if !ok {
+		apibase.AbortWithError(c, http.StatusNotImplemented, "ledger doesn't support inclusion proofs")
+		return
+	}
+
+	proof, err := prover.GetRecordInclusionProof(c, rid)
+	if err != nil {
+		if errors.Is(err, model.ErrRecordNotFound) || errors.Is(err, model.ErrRecordNotInBlock) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		} else {
+			log := apibase.CtxLogger(c)
+			log.Err(err).Msg("Error when building record inclusion proof")
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	apibase.JSON(c, http.StatusOK, proof)
+}
+
 func (h *LedgerHandler) GetAssetHeadHandler(c *gin.Context) {
 	rid := c.Params.ByName("id")
 
@@ -47,6 +47,11 @@ func GetRecoveryCodeHandler(identityBackend storage.IdentityBackend) gin.Handler
 			email = strings.ToLower(email)
 		}
 
+		if err := apibase.GetThrottle(c).CheckAccount(c, email); err != nil {
+			apibase.AbortWithError(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+
 		acct, err := identityBackend.GetAccount(c, email)
 		if err != nil {
 			log.Err(err).Msg("Error when retrieving account details")
@@ -101,9 +106,17 @@ func RecoverAccountHandler(identityBackend storage.IdentityBackend) gin.HandlerF
 			req.UserID = strings.ToLower(req.UserID)
 		}
 
+		throttle := apibase.GetThrottle(c)
+		if err = throttle.CheckAccount(c, req.UserID); err != nil {
+			apibase.AbortWithError(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+
 		rc, err := identityBackend.GetRecoveryCode(c, req.RecoveryCode)
 		if err != nil {
 			if errors.Is(err, storage.ErrRecoveryCodeNotFound) {
+				// count unknown codes as failures to prevent guessing
+				throttle.RecordFailure(c, req.UserID)
 				_ = c.AbortWithError(http.StatusBadRequest, err)
 			} else {
 				log.Err(err).Msg("Error when retrieving recovery code details")
@@ -112,9 +125,18 @@ func RecoverAccountHandler(identityBackend storage.IdentityBackend) gin.HandlerF
 			return
 		}
 
-		if err = identityBackend.DeleteRecoveryCode(c, req.RecoveryCode); err != nil {
-			log.Err(err).Msg("Error when deleting recovery code")
-		}
+		// recovery codes are single use, even if the attempt fails. The only exception
+		// is a failed second factor check: the code is kept to let the user retry
+		// with a valid one-time code.
+		keepCode := false
+		codeConsumed := false
+		defer func() {
+			if !keepCode && !codeConsumed {
+				if err := identityBackend.DeleteRecoveryCode(c, req.RecoveryCode); err != nil {
+					log.Err(err).Msg("Error when deleting recovery code")
+				}
+			}
+		}()
 
 		now := time.Now()
 		if now.After(*rc.ExpiresAt) {
@@ -147,10 +169,43 @@ func RecoverAccountHandler(identityBackend storage.IdentityBackend) gin.HandlerF
 		}
 		if !req.Valid(recPubKey) {
 			log.Error().Msg("Account recovery signature incorrect")
+			throttle.RecordFailure(c, req.UserID)
 			apibase.AbortWithError(c, http.StatusUnauthorized, "signature verification failed")
 			return
 		}
 
+		if err = apibase.CheckSecondFactor(c, identityBackend, acct.ID, req.OTP); err != nil {
+			switch {
+			case errors.Is(err, account.ErrSecondFactorRequired):
+				keepCode = true
+				apibase.AbortWithError(c, http.StatusUnauthorized, err.Error())
+			case errors.Is(err, account.ErrInvalidSecondFactor):
+				keepCode = true
+				log.Err(err).Msg("Account recovery second factor verification failed")
+				throttle.RecordFailure(c, req.UserID)
+				apibase.AbortWithError(c, http.StatusUnauthorized, "second factor verification failed")
+			default:
+				log.Err(err).Msg("Error when verifying second factor")
+				apibase.AbortWithError(c, http.StatusInternalServerError, "internal error")
+			}
+			return
+		}
+
+		// the code is consumed once the second factor is verified.
+		// If it's already gone, a concurrent request has used it.
+		codeConsumed = true
+		if err = identityBackend.DeleteRecoveryCode(c, req.RecoveryCode); err != nil {
+			if errors.Is(err, storage.ErrRecoveryCodeNotFound) {
+				apibase.AbortWithError(c, http.StatusUnauthorized, "Recovery code already used")
+			} else {
+				log.Err(err).Msg("Error when deleting recovery code")
+				_ = c.AbortWithError(http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		throttle.RecordSuccess(c, req.UserID)
+
 		if req.ManagedCryptoKey != "" {
 			// perform full managed account recovery. The account will return to 'active' state
 
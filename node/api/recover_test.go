@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/piprate/metalocker/model"
@@ -298,3 +299,120 @@ func TestRecoverAccountHandler_ManagedWorkflow(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(acct.EncryptedPassword), []byte(account.HashUserPassword(newPassphrase))))
 }
+
+func TestRecoverAccountHandler_SecondFactor(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	dw, recDetails, err := env.Factory.RegisterAccount(
+		env.Ctx,
+		&account.Account{
+			Email:        "test@example.com",
+			Name:         "John Doe",
+			AccessLevel:  model.AccessLevelHosted,
+			DefaultVault: testbase.TestVaultName,
+		},
+		account.WithPassphraseAuth("pass"))
+	require.NoError(t, err)
+
+	totp, err := account.NewTOTPFactor(dw.ID(), "phone")
+	require.NoError(t, err)
+	totp.Confirmed = true
+	err = env.IdentityBackend.StoreSecondFactor(env.Ctx, totp)
+	require.NoError(t, err)
+
+	_, _, privKey, err := account.GenerateKeysFromRecoveryPhrase(recDetails.RecoveryPhrase)
+	require.NoError(t, err)
+
+	rc, err := account.NewRecoveryCode("test@example.com", 5*60)
+	require.NoError(t, err)
+
+	err = env.IdentityBackend.CreateRecoveryCode(env.Ctx, rc)
+	require.NoError(t, err)
+
+	invoke := func(otp string) *httptest.ResponseRecorder {
+		req := account.BuildRecoveryRequest(rc.UserID, rc.Code, privKey, "pass2", nil)
+		req.OTP = otp
+		reqBytes, _ := jsonw.Marshal(req)
+
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest(http.MethodPost,
+			"test-url", bytes.NewReader(reqBytes))
+
+		RecoverAccountHandler(env.IdentityBackend)(c)
+
+		return rec
+	}
+
+	// code is missing
+
+	rec := invoke("")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	var msg apibase.Response
+	readBody(t, rec, &msg)
+	assert.Equal(t, "second factor required", msg.Message)
+
+	// wrong code
+
+	rec = invoke("00000a")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// the recovery code is kept until the second factor is verified
+	_, err = env.IdentityBackend.GetRecoveryCode(env.Ctx, rc.Code)
+	require.NoError(t, err)
+
+	// happy path
+
+	code, err := account.GenerateTOTP(totp.Secret, time.Now())
+	require.NoError(t, err)
+
+	rec = invoke(code)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// confirm the code is deleted
+	_, err = env.IdentityBackend.GetRecoveryCode(env.Ctx, rc.Code)
+	require.Error(t, err)
+}
+
+func TestRecoverAccountHandler_UnknownCodeThrottled(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	throttle, err := apibase.NewThrottle(&apibase.ThrottleConfig{
+		MaxFailures: 2,
+	}, apibase.NewMemoryThrottleStore())
+	require.NoError(t, err)
+
+	invoke := func(userID, code string) *httptest.ResponseRecorder {
+		reqBytes, _ := jsonw.Marshal(&account.RecoveryRequest{
+			UserID:       userID,
+			RecoveryCode: code,
+		})
+
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest(http.MethodPost,
+			"test-url", bytes.NewReader(reqBytes))
+		c.Set(apibase.ThrottleKey, throttle)
+
+		RecoverAccountHandler(env.IdentityBackend)(c)
+
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		rec := invoke("test@example.com", "bad-code")
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+
+	// each failed lookup counts towards the lockout
+
+	until, err := throttle.LockedUntil(env.Ctx, "test@example.com")
+	require.NoError(t, err)
+	assert.False(t, until.IsZero())
+
+	rec := invoke("test@example.com", "bad-code")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
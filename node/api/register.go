@@ -187,15 +187,19 @@ func RegisterHandler(registrationCodes []string, defaultVault string, secondLeve
 	}
 }
 
-func InitRegisterRoute(r *gin.Engine, path string, cfg *koanf.Koanf, jwtMW *apibase.GinJWTMiddleware, identityBackend storage.IdentityBackend, ledger model.Ledger) {
+// InitRegisterRoute adds the account registration route. Any given middleware
+// (i.e. rate limiting) is executed before the registration handler.
+func InitRegisterRoute(r *gin.Engine, path string, cfg *koanf.Koanf, jwtMW *apibase.GinJWTMiddleware, identityBackend storage.IdentityBackend, ledger model.Ledger, middleware ...gin.HandlerFunc) {
 	var slrKey []byte
 	slrKeyStr := cfg.String("secondLevelRecoveryKey")
 	if slrKeyStr != "" {
 		slrKey = base58.Decode(slrKeyStr)
 	}
 
-	r.POST(path, RegisterHandler(
+	handlers := append(middleware, RegisterHandler(
 		cfg.Strings("registrationCode"),
 		cfg.String("defaultVaultName"),
 		slrKey, nil, nil, nil, jwtMW, identityBackend, ledger))
+
+	r.POST(path, handlers...)
 }
@@ -0,0 +1,372 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/storage"
+)
+
+const secondFactorIssuer = "MetaLocker"
+
+type (
+	SecondFactorEnrolmentRequest struct {
+		Type string `json:"type"`
+		Name string `json:"name,omitempty"`
+	}
+
+	SecondFactorEnrolmentResponse struct {
+		Factor *account.SecondFactor `json:"factor"`
+		Secret string                `json:"secret"`
+		URI    string                `json:"uri"`
+	}
+
+	SecondFactorCodeRequest struct {
+		Code string `json:"code"`
+	}
+
+	BackupCodesResponse struct {
+		Codes []string `json:"codes,omitempty"`
+	}
+)
+
+func (h *AccountHandler) GetSecondFactorListHandler(c *gin.Context) {
+	accountID, ok := h.secondFactorAccount(c)
+	if !ok {
+		return
+	}
+
+	factors, err := h.identityBackend.ListSecondFactors(c, accountID)
+	if err != nil {
+		log := apibase.CtxLogger(c)
+		log.Err(err).Msg("Error when reading second factor list")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	res := make([]*account.SecondFactor, len(factors))
+	for i, f := range factors {
+		// we never return factor secrets after enrolment
+		res[i] = f.Redacted()
+	}
+	apibase.JSON(c, http.StatusOK, res)
+}
+
+func (h *AccountHandler) PostSecondFactorHandler(c *gin.Context) {
+	log := apibase.CtxLogger(c)
+
+	var req SecondFactorEnrolmentRequest
+	err := apibase.BindJSON(c, &req)
+	if err != nil {
+		apibase.AbortWithError(c, http.StatusBadRequest, "Bad request body")
+		return
+	}
+
+	accountID, ok := h.secondFactorAccount(c)
+	if !ok {
+		return
+	}
+
+	if req.Type != "" && req.Type != account.SecondFactorTOTP {
+		apibase.AbortWithError(c, http.StatusBadRequest, "Unsupported second factor type")
+		return
+	}
+
+	acct, err := h.identityBackend.GetAccount(c, accountID)
+	if err != nil {
+		log.Err(err).Msg("Error when retrieving account details")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	f, err := account.NewTOTPFactor(accountID, req.Name)
+	if err != nil {
+		log.Err(err).Msg("Error when generating second factor")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if err = h.identityBackend.StoreSecondFactor(c, f); err != nil {
+		log.Err(err).Msg("Error when saving second factor")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Info().Str("id", f.ID).Msg("Second factor enrolment started")
+
+	label := acct.Email
+	if label == "" {
+		label = acct.ID
+	}
+
+	c.Writer.Header().Add("Location", fmt.Sprintf("%s/%s", c.Request.URL.RequestURI(), f.ID))
+	apibase.JSON(c, http.StatusCreated, &SecondFactorEnrolmentResponse{
+		Factor: f.Redacted(),
+		Secret: f.Secret,
+		URI:    f.KeyURI(secondFactorIssuer, label),
+	})
+}
+
+// PostSecondFactorConfirmHandler completes factor enrolment. When the first factor
+// gets confirmed, the account is issued a set of backup codes.
+func (h *AccountHandler) PostSecondFactorConfirmHandler(c *gin.Context) {
+	log := apibase.CtxLogger(c)
+
+	var req SecondFactorCodeRequest
+	err := apibase.BindJSON(c, &req)
+	if err != nil {
+		apibase.AbortWithError(c, http.StatusBadRequest, "Bad request body")
+		return
+	}
+
+	accountID, ok := h.secondFactorAccount(c)
+	if !ok {
+		return
+	}
+
+	f, ok := h.getSecondFactor(c, accountID, c.Params.ByName("id"))
+	if !ok {
+		return
+	}
+
+	if f.Confirmed {
+		apibase.AbortWithError(c, http.StatusBadRequest, "Second factor already confirmed")
+		return
+	}
+
+	if !f.Verify(req.Code, time.Now()) {
+		apibase.AbortWithError(c, http.StatusUnauthorized, "second factor verification failed")
+		return
+	}
+
+	factors, err := h.identityBackend.ListSecondFactors(c, accountID)
+	if err != nil {
+		log.Err(err).Msg("Error when reading second factor list")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	f.Confirmed = true
+	if err = h.identityBackend.UpdateSecondFactor(c, f); err != nil {
+		if errors.Is(err, storage.ErrSecondFactorConflict) {
+			// the factor was confirmed (or its code used) by a concurrent request
+			apibase.AbortWithError(c, http.StatusUnauthorized, "second factor verification failed")
+		} else {
+			log.Err(err).Msg("Error when saving second factor")
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	log.Info().Str("id", f.ID).Msg("Second factor confirmed")
+
+	var rsp BackupCodesResponse
+	if !hasBackupCodes(factors) {
+		rsp.Codes, err = h.issueBackupCodes(c, accountID, factors)
+		if err != nil {
+			log.Err(err).Msg("Error when issuing backup codes")
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	apibase.JSON(c, http.StatusOK, &rsp)
+}
+
+// PostBackupCodesHandler replaces existing backup codes with a new set. It requires
+// a valid code from one of the account's second factors.
+func (h *AccountHandler) PostBackupCodesHandler(c *gin.Context) {
+	log := apibase.CtxLogger(c)
+
+	var req SecondFactorCodeRequest
+	err := apibase.BindJSON(c, &req)
+	if err != nil {
+		apibase.AbortWithError(c, http.StatusBadRequest, "Bad request body")
+		return
+	}
+
+	accountID, ok := h.secondFactorAccount(c)
+	if !ok {
+		return
+	}
+
+	factors, err := h.identityBackend.ListSecondFactors(c, accountID)
+	if err != nil {
+		log.Err(err).Msg("Error when reading second factor list")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if !account.IsMFAEnabled(factors) {
+		apibase.AbortWithError(c, http.StatusBadRequest, "No second factors enrolled")
+		return
+	}
+
+	if !h.checkSecondFactor(c, accountID, req.Code) {
+		return
+	}
+
+	codes, err := h.issueBackupCodes(c, accountID, factors)
+	if err != nil {
+		log.Err(err).Msg("Error when issuing backup codes")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	apibase.JSON(c, http.StatusOK, &BackupCodesResponse{Codes: codes})
+}
+
+// DeleteSecondFactorHandler removes a second factor. If MFA is enabled for the account,
+// a valid code needs to be provided in 'otp' query parameter. Removing the last factor
+// also removes any remaining backup codes.
+func (h *AccountHandler) DeleteSecondFactorHandler(c *gin.Context) {
+	log := apibase.CtxLogger(c)
+
+	accountID, ok := h.secondFactorAccount(c)
+	if !ok {
+		return
+	}
+
+	f, ok := h.getSecondFactor(c, accountID, c.Params.ByName("id"))
+	if !ok {
+		return
+	}
+
+	if !h.checkSecondFactor(c, accountID, c.Query("otp")) {
+		return
+	}
+
+	if err := h.identityBackend.DeleteSecondFactor(c, f.ID); err != nil {
+		log.Err(err).Msg("Error when deleting second factor")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	factors, err := h.identityBackend.ListSecondFactors(c, accountID)
+	if err != nil {
+		log.Err(err).Msg("Error when reading second factor list")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if !account.IsMFAEnabled(factors) {
+		for _, bf := range factors {
+			if bf.Type == account.SecondFactorBackupCodes {
+				if err = h.identityBackend.DeleteSecondFactor(c, bf.ID); err != nil {
+					log.Err(err).Msg("Error when deleting backup codes")
+				}
+			}
+		}
+	}
+
+	log.Info().Str("id", f.ID).Msg("Second factor deleted")
+
+	c.Status(http.StatusNoContent)
+}
+
+// secondFactorAccount checks that the caller can manage second factors for
+// the account in the request path and returns the account ID.
+func (h *AccountHandler) secondFactorAccount(c *gin.Context) (string, bool) {
+	masterAccountID := apibase.GetUserID(c)
+	accountID := c.Params.ByName("aid")
+
+	if !hasAccountPermissions(c, h.identityBackend, masterAccountID, accountID) {
+		return "", false
+	}
+
+	if apibase.GetAccessKey(c) != nil {
+		apibase.AbortWithError(c, http.StatusForbidden, "Access keys can't manage second factors")
+		return "", false
+	}
+
+	return accountID, true
+}
+
+func (h *AccountHandler) getSecondFactor(c *gin.Context, accountID, id string) (*account.SecondFactor, bool) {
+	f, err := h.identityBackend.GetSecondFactor(c, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrSecondFactorNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+		} else {
+			log := apibase.CtxLogger(c)
+			log.Err(err).Msg("Error when retrieving second factor")
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return nil, false
+	}
+
+	if f.AccountID != accountID {
+		log := apibase.CtxLogger(c)
+		log.Warn().Msg("Attempting to access a second factor for another account")
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+
+	return f, true
+}
+
+func (h *AccountHandler) checkSecondFactor(c *gin.Context, accountID, code string) bool {
+	err := apibase.CheckSecondFactor(c, h.identityBackend, accountID, code)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, account.ErrSecondFactorRequired):
+		apibase.AbortWithError(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, account.ErrInvalidSecondFactor):
+		apibase.AbortWithError(c, http.StatusUnauthorized, "second factor verification failed")
+	default:
+		log := apibase.CtxLogger(c)
+		log.Err(err).Msg("Error when verifying second factor")
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+	}
+	return false
+}
+
+func (h *AccountHandler) issueBackupCodes(c *gin.Context, accountID string, factors []*account.SecondFactor) ([]string, error) {
+	for _, f := range factors {
+		if f.Type == account.SecondFactorBackupCodes {
+			if err := h.identityBackend.DeleteSecondFactor(c, f.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	f, codes, err := account.NewBackupCodes(accountID, account.BackupCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.identityBackend.StoreSecondFactor(c, f); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func hasBackupCodes(factors []*account.SecondFactor) bool {
+	for _, f := range factors {
+		if f.Type == account.SecondFactorBackupCodes && len(f.BackupCodes) > 0 {
+			return true
+		}
+	}
+	return false
+}
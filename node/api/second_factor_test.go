@@ -0,0 +1,169 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/account"
+	. "github.com/piprate/metalocker/node/api"
+	"github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountHandler_SecondFactorLifecycle(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	handlerBase := NewAccountHandler(env.IdentityBackend)
+
+	acct := createTestAccount(t, "test@example.com", model.AccessLevelHosted, "", env)
+
+	invoke := func(handlerFn gin.HandlerFunc, method, url string, body any, factorID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		var bodyBytes []byte
+		if body != nil {
+			bodyBytes, _ = jsonw.Marshal(body)
+		}
+		c.Request, _ = http.NewRequest(method, url, bytes.NewReader(bodyBytes))
+		c.Set(apibase.UserIDKey, acct.ID)
+		c.AddParam("aid", acct.ID)
+		if factorID != "" {
+			c.AddParam("id", factorID)
+		}
+
+		handlerFn(c)
+		// flush headers for handlers that only set the status code
+		c.Writer.WriteHeaderNow()
+
+		return rec
+	}
+
+	// unsupported factor type
+
+	rec := invoke(handlerBase.PostSecondFactorHandler, http.MethodPost, "/test-url",
+		&SecondFactorEnrolmentRequest{Type: "sms"}, "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// start enrolment
+
+	rec = invoke(handlerBase.PostSecondFactorHandler, http.MethodPost, "/test-url",
+		&SecondFactorEnrolmentRequest{Type: account.SecondFactorTOTP, Name: "phone"}, "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var enrolRsp SecondFactorEnrolmentResponse
+	readBody(t, rec, &enrolRsp)
+	require.NotEmpty(t, enrolRsp.Secret)
+	assert.Empty(t, enrolRsp.Factor.Secret)
+	assert.Contains(t, enrolRsp.URI, "otpauth://totp/MetaLocker:test@example.com")
+
+	factorID := enrolRsp.Factor.ID
+
+	// unconfirmed factors aren't enforced
+
+	require.NoError(t, apibase.CheckSecondFactor(env.Ctx, env.IdentityBackend, acct.ID, ""))
+
+	// confirm with a wrong code
+
+	rec = invoke(handlerBase.PostSecondFactorConfirmHandler, http.MethodPost, "/test-url",
+		&SecondFactorCodeRequest{Code: "00000a"}, factorID)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// confirm enrolment
+
+	now := time.Now()
+	code, err := account.GenerateTOTP(enrolRsp.Secret, now)
+	require.NoError(t, err)
+
+	rec = invoke(handlerBase.PostSecondFactorConfirmHandler, http.MethodPost, "/test-url",
+		&SecondFactorCodeRequest{Code: code}, factorID)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var codesRsp BackupCodesResponse
+	readBody(t, rec, &codesRsp)
+	require.Len(t, codesRsp.Codes, account.BackupCodeCount)
+
+	require.ErrorIs(t, apibase.CheckSecondFactor(env.Ctx, env.IdentityBackend, acct.ID, ""),
+		account.ErrSecondFactorRequired)
+
+	// list factors
+
+	rec = invoke(handlerBase.GetSecondFactorListHandler, http.MethodGet, "/test-url", nil, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var listRsp []*account.SecondFactor
+	readBody(t, rec, &listRsp)
+	require.Len(t, listRsp, 2)
+	for _, f := range listRsp {
+		assert.Empty(t, f.Secret)
+		assert.Empty(t, f.BackupCodes)
+	}
+
+	// regenerate backup codes
+
+	rec = invoke(handlerBase.PostBackupCodesHandler, http.MethodPost, "/test-url",
+		&SecondFactorCodeRequest{}, "")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = invoke(handlerBase.PostBackupCodesHandler, http.MethodPost, "/test-url",
+		&SecondFactorCodeRequest{Code: codesRsp.Codes[0]}, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var newCodesRsp BackupCodesResponse
+	readBody(t, rec, &newCodesRsp)
+	require.Len(t, newCodesRsp.Codes, account.BackupCodeCount)
+
+	// old backup codes are no longer valid
+
+	rec = invoke(handlerBase.DeleteSecondFactorHandler, http.MethodDelete,
+		"/test-url?otp="+codesRsp.Codes[1], nil, factorID)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// access keys can't manage second factors
+
+	ak, err := model.GenerateAccessKey(acct.ID, acct.AccessLevel)
+	require.NoError(t, err)
+
+	rec = httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/test-url", http.NoBody)
+	c.Set(apibase.UserIDKey, acct.ID)
+	c.Set(apibase.AccessKeyKey, ak)
+	c.AddParam("aid", acct.ID)
+	handlerBase.GetSecondFactorListHandler(c)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	// delete the factor
+
+	rec = invoke(handlerBase.DeleteSecondFactorHandler, http.MethodDelete,
+		"/test-url?otp="+newCodesRsp.Codes[0], nil, factorID)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	// backup codes are removed with the last factor
+
+	factors, err := env.IdentityBackend.ListSecondFactors(env.Ctx, acct.ID)
+	require.NoError(t, err)
+	assert.Empty(t, factors)
+}
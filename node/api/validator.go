@@ -62,6 +62,12 @@ func ValidateRequestSignatureHandler(identityBackend storage.IdentityBackend) gi
 			return
 		}
 
+		if key.IsExpired(ts) {
+			log.Warn().Str("url", req.URL).Str("key", req.KeyID).Msg("Signature validation failed: key expired")
+			apibase.AbortWithError(c, http.StatusUnauthorized, "Access key expired")
+			return
+		}
+
 		encryptedHMACKey, err := base64.StdEncoding.DecodeString(key.Secret)
 		if err != nil {
 			log.Err(err).Msg("Error decoding access key secret")
@@ -82,7 +88,11 @@ func ValidateRequestSignatureHandler(identityBackend storage.IdentityBackend) gi
 		}
 
 		apibase.JSON(c, http.StatusOK, apibase.SignatureValidationResponse{
-			Account: key.AccountID,
+			Account:    key.AccountID,
+			ExpiresAt:  key.ExpiresAt,
+			Permission: key.Permission,
+			Lockers:    key.Lockers,
+			Identities: key.Identities,
 		})
 	}
 }
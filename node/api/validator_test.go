@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/piprate/metalocker/contexts"
@@ -144,6 +145,68 @@ func TestValidateRequestSignatureHandler_ExpiredSignature(t *testing.T) {
 }`, rec.Body.Bytes())
 }
 
+func TestValidateRequestSignatureHandler_ExpiredKey(t *testing.T) {
+	identityBackend, _ := memory.CreateIdentityBackend(nil, nil)
+
+	expiresAt := time.Unix(10000000, 0).UTC()
+	key := &model.AccessKey{
+		ID:          "HYJWc4FMvHt56o3K9RHCpt",
+		AccountID:   "did:piprate:test-account",
+		AccessLevel: model.AccessLevelManaged,
+		Secret:      "Kv2w9cS9rtMU88cGLwdqo3duNvvUhZC1Pk/KGodKVDS0O+5T5aD079m7FMNdra4BfX+UN0Ld2GfdrgVznu3KxjnSUWbm7pCLALYopnSZYfvEH0XFGwIIQ/qS0Ug=",
+		Type:        model.AccessKeyType,
+		ExpiresAt:   &expiresAt,
+		Permission:  model.AccessKeyPermissionReadOnly,
+	}
+
+	body := []byte("test")
+	bodyHash := model.HashRequestBody(body)
+
+	err := identityBackend.StoreAccessKey(context.Background(), key)
+	require.NoError(t, err)
+
+	req := &apibase.SignatureValidationRequest{
+		URL:       "/v1/account",
+		KeyID:     "HYJWc4FMvHt56o3K9RHCpt",
+		Signature: "Ea58gE9YL3Oo1hLyoi6BPHNWRPC6YP1nDo/3qRLjXas=",
+		Header: http.Header{
+			"X-Meta-Body-Hash": []string{
+				"IvZOgH9mrFaxDYqwGF5P4dpS+yZ5RuHeUfvTJHjXEAk=",
+			},
+			"X-Meta-Client-Key": []string{
+				"cCx+SYim/H/L26YXlWWz4InWWz7hbu0whvB/LSiBjiA=",
+			},
+			"X-Meta-Date": []string{
+				"19700426",
+			},
+		},
+		Timestamp: 10020000,
+		BodyHash:  base64.StdEncoding.EncodeToString(bodyHash),
+	}
+
+	rec := invokeValidateRequestSignatureHandler(t, req, identityBackend)
+
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	checkResponseBody(t, `{
+  "message": "Access key expired"
+}`, rec.Body.Bytes())
+
+	// the same request is valid before the key's expiry time
+
+	expiresAt = time.Unix(20000000, 0).UTC()
+	err = identityBackend.StoreAccessKey(context.Background(), key)
+	require.NoError(t, err)
+
+	rec = invokeValidateRequestSignatureHandler(t, req, identityBackend)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	checkResponseBody(t, `{
+  "acct": "did:piprate:test-account",
+  "expires": "1970-08-20T11:33:20Z",
+  "permission": "ro"
+}`, rec.Body.Bytes())
+}
+
 func TestValidateRequestSignatureHandler_BadKeyID(t *testing.T) {
 	identityBackend, _ := memory.CreateIdentityBackend(nil, nil)
 
@@ -67,6 +67,21 @@ vaults:
     params:
       root_dir: %s/state/fs_vault
 defaultVaultName: local
+sweeper:
+  interval: 1h
+  batchSize: 100
+  dryRun: false
+authThrottle:
+  window: 1m
+  ipLimit: 30
+  accountLimit: 10
+  maxFailures: 5
+  failureWindow: 15m
+  lockoutDuration: 15m
+  store:
+    type: bolt
+    params:
+      file_path: %s/state/throttle.bolt
 `
 
 func GenerateConfig(port int, baseDir string) ([]byte, []byte, error) {
@@ -92,6 +107,7 @@ func GenerateConfig(port int, baseDir string) ([]byte, []byte, error) {
 		baseDir,
 		randomBytes(32), // fs vault ID
 		baseDir,
+		baseDir,
 	)
 
 	return []byte(cfg), slrcPrivateKey, nil
@@ -0,0 +1,160 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"errors"
+
+	"github.com/knadh/koanf"
+	"github.com/piprate/metalocker/sdk/cmdbase"
+)
+
+var (
+	ErrBadConfiguration = errors.New("bad OIDC configuration")
+)
+
+type (
+	// Config defines the OpenID Connect relying party configuration.
+	Config struct {
+		// Issuer is the OIDC provider's issuer URL.
+		Issuer string
+		// ClientID is the relying party's client ID, registered with the provider.
+		ClientID string
+		// ClientSecret is the relying party's client secret. May be empty for public clients.
+		ClientSecret string
+		// RedirectURL is the URL of the relying party's callback endpoint.
+		RedirectURL string
+		// Scopes are requested from the provider. Defaults to openid, email and profile.
+		Scopes []string
+		// Audiences lists accepted values of ID token's 'aud' claim. Defaults to ClientID.
+		Audiences []string
+		// Claims defines how ID token claims map to MetaLocker account properties.
+		Claims ClaimsMapping
+		// RequireVerifiedEmail rejects ID tokens where the email isn't marked as verified.
+		RequireVerifiedEmail bool
+		// LinkExistingAccounts allows linking the external subject to an existing account
+		// with the same email on first login. Accounts are only linked if the provider
+		// marks the email as verified, regardless of RequireVerifiedEmail.
+		LinkExistingAccounts bool
+		// AutoProvision enables creation of new managed accounts for unknown subjects.
+		AutoProvision bool
+		// AccountSecret is a server-held secret that protects the managed keys
+		// of the accounts provisioned by the relying party. If it changes, these accounts
+		// can only be accessed using their recovery phrases.
+		AccountSecret string
+		// DefaultVault is the default vault for provisioned accounts.
+		DefaultVault string
+	}
+
+	// ClaimsMapping defines names of ID token claims that carry account properties.
+	ClaimsMapping struct {
+		Subject       string `koanf:"subject" json:"subject"`
+		Email         string `koanf:"email" json:"email"`
+		EmailVerified string `koanf:"emailVerified" json:"emailVerified"`
+		Name          string `koanf:"name" json:"name"`
+		GivenName     string `koanf:"givenName" json:"givenName"`
+		FamilyName    string `koanf:"familyName" json:"familyName"`
+	}
+
+	configStruct struct {
+		Issuer               string        `koanf:"issuer" json:"issuer"`
+		ClientID             string        `koanf:"clientID" json:"clientID"`
+		ClientSecret         any           `koanf:"clientSecret" json:"clientSecret"`
+		RedirectURL          string        `koanf:"redirectURL" json:"redirectURL"`
+		Scopes               []string      `koanf:"scopes" json:"scopes"`
+		Audiences            []string      `koanf:"audiences" json:"audiences"`
+		Claims               ClaimsMapping `koanf:"claims" json:"claims"`
+		RequireVerifiedEmail bool          `koanf:"requireVerifiedEmail" json:"requireVerifiedEmail"`
+		LinkExistingAccounts bool          `koanf:"linkExistingAccounts" json:"linkExistingAccounts"`
+		AutoProvision        bool          `koanf:"autoProvision" json:"autoProvision"`
+		AccountSecret        any           `koanf:"accountSecret" json:"accountSecret"`
+		DefaultVault         string        `koanf:"defaultVault" json:"defaultVault"`
+	}
+)
+
+// ReadConfig reads the relying party configuration from the given section of the node
+// configuration. Client and account secrets are resolved using the parameter resolver.
+// The configuration is validated when the relying party is created.
+func ReadConfig(cfg *koanf.Koanf, name string, resolver cmdbase.ParameterResolver) (*Config, error) {
+	var raw configStruct
+	if err := cfg.Unmarshal(name, &raw); err != nil {
+		return nil, err
+	}
+
+	clientSecret, err := resolver.ResolveString(raw.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	accountSecret, err := resolver.ResolveString(raw.AccountSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{
+		Issuer:               raw.Issuer,
+		ClientID:             raw.ClientID,
+		ClientSecret:         clientSecret,
+		RedirectURL:          raw.RedirectURL,
+		Scopes:               raw.Scopes,
+		Audiences:            raw.Audiences,
+		Claims:               raw.Claims,
+		RequireVerifiedEmail: raw.RequireVerifiedEmail,
+		LinkExistingAccounts: raw.LinkExistingAccounts,
+		AutoProvision:        raw.AutoProvision,
+		AccountSecret:        accountSecret,
+		DefaultVault:         raw.DefaultVault,
+	}
+
+	return c, nil
+}
+
+// Validate checks mandatory parameters and sets defaults for optional ones.
+func (c *Config) Validate() error {
+	if c.Issuer == "" || c.ClientID == "" || c.RedirectURL == "" {
+		return ErrBadConfiguration
+	}
+	if c.AutoProvision && (c.AccountSecret == "" || c.DefaultVault == "") {
+		return ErrBadConfiguration
+	}
+
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "email", "profile"}
+	}
+	if len(c.Audiences) == 0 {
+		c.Audiences = []string{c.ClientID}
+	}
+
+	if c.Claims.Subject == "" {
+		c.Claims.Subject = "sub"
+	}
+	if c.Claims.Email == "" {
+		c.Claims.Email = "email"
+	}
+	if c.Claims.EmailVerified == "" {
+		c.Claims.EmailVerified = "email_verified"
+	}
+	if c.Claims.Name == "" {
+		c.Claims.Name = "name"
+	}
+	if c.Claims.GivenName == "" {
+		c.Claims.GivenName = "given_name"
+	}
+	if c.Claims.FamilyName == "" {
+		c.Claims.FamilyName = "family_name"
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidctest provides a local mock OpenID Connect issuer for testing
+// relying party integrations.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const keyID = "test-key"
+
+type (
+	// Issuer is a mock OpenID Connect issuer that supports discovery, JWKS and
+	// the authorisation code flow with PKCE. The authorisation endpoint doesn't
+	// interact with the user. It immediately redirects back to the relying party
+	// with a code for the currently configured user claims.
+	Issuer struct {
+		server       *httptest.Server
+		key          *rsa.PrivateKey
+		clientID     string
+		clientSecret string
+
+		mtx    sync.Mutex
+		claims map[string]any
+		codes  map[string]*authRequest
+	}
+
+	authRequest struct {
+		redirectURI   string
+		nonce         string
+		codeChallenge string
+		claims        map[string]any
+	}
+)
+
+// NewIssuer starts a new mock issuer for the given client. If clientSecret is empty,
+// the client is treated as a public client.
+func NewIssuer(clientID, clientSecret string) (*Issuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	iss := &Issuer{
+		key:          key,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		claims:       map[string]any{},
+		codes:        map[string]*authRequest{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", iss.handleDiscovery)
+	mux.HandleFunc("/jwks", iss.handleJWKS)
+	mux.HandleFunc("/authorize", iss.handleAuthorize)
+	mux.HandleFunc("/token", iss.handleToken)
+
+	iss.server = httptest.NewServer(mux)
+
+	return iss, nil
+}
+
+// URL returns the issuer identifier.
+func (iss *Issuer) URL() string {
+	return iss.server.URL
+}
+
+// Close shuts down the issuer.
+func (iss *Issuer) Close() {
+	iss.server.Close()
+}
+
+// SetClaims sets the claims of the user who will be authenticated by subsequent
+// authorisation requests. These claims override the default ones (iss, aud, iat, exp).
+func (iss *Issuer) SetClaims(claims map[string]any) {
+	iss.mtx.Lock()
+	defer iss.mtx.Unlock()
+
+	iss.claims = claims
+}
+
+// IDToken returns an ID token with the given claims, signed by the issuer.
+func (iss *Issuer) IDToken(claims map[string]any) (string, error) {
+	now := time.Now()
+	tokenClaims := jwt.MapClaims{
+		"iss": iss.URL(),
+		"aud": iss.clientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		tokenClaims[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, tokenClaims)
+	token.Header["kid"] = keyID
+
+	return token.SignedString(iss.key)
+}
+
+func (iss *Issuer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                iss.URL(),
+		"authorization_endpoint":                iss.URL() + "/authorize",
+		"token_endpoint":                        iss.URL() + "/token",
+		"jwks_uri":                              iss.URL() + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (iss *Issuer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	pub := iss.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{
+			{
+				"kty": "RSA",
+				"kid": keyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			},
+		},
+	})
+}
+
+func (iss *Issuer) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != iss.clientID || q.Get("response_type") != "code" {
+		http.Error(w, "invalid authorisation request", http.StatusBadRequest)
+		return
+	}
+
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "PKCE required", http.StatusBadRequest)
+		return
+	}
+
+	code := base58.Encode(randomBytes(16))
+
+	iss.mtx.Lock()
+	iss.codes[code] = &authRequest{
+		redirectURI:   redirectURI.String(),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		claims:        iss.claims,
+	}
+	iss.mtx.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (iss *Issuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, hasBasicAuth := r.BasicAuth()
+	if hasBasicAuth {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != iss.clientID || clientSecret != iss.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostForm.Get("code")
+
+	iss.mtx.Lock()
+	req, found := iss.codes[code]
+	delete(iss.codes, code)
+	iss.mtx.Unlock()
+
+	if !found || req.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid_grant"})
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != req.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":             "invalid_grant",
+			"error_description": "PKCE verification failed",
+		})
+		return
+	}
+
+	claims := map[string]any{
+		"nonce": req.nonce,
+	}
+	for k, v := range req.claims {
+		claims[k] = v
+	}
+
+	idToken, err := iss.IDToken(claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": base58.Encode(randomBytes(16)),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, val any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = jsonw.Encode(val, w)
+}
+
+func randomBytes(n int) []byte {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
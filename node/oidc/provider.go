@@ -0,0 +1,422 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidc implements an OpenID Connect relying party that allows MetaLocker
+// users to authenticate with an external identity provider.
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	// minKeyRefreshInterval limits how often JWKS is re-fetched when an ID token
+	// is signed with an unknown key.
+	minKeyRefreshInterval = time.Minute
+)
+
+var (
+	ErrDiscoveryFailed    = errors.New("OIDC provider discovery failed")
+	ErrTokenExchange      = errors.New("OIDC token exchange failed")
+	ErrMissingIDToken     = errors.New("ID token not found in token response")
+	ErrInvalidIDToken     = errors.New("invalid ID token")
+	ErrUnknownSigningKey  = errors.New("ID token signed with unknown key")
+	ErrAudienceMismatch   = errors.New("ID token audience not accepted")
+	ErrNonceMismatch      = errors.New("ID token nonce mismatch")
+	ErrUnsupportedKeyType = errors.New("unsupported JWK key type")
+)
+
+type (
+	// Provider is a client for an OpenID Connect provider (issuer). It discovers provider
+	// endpoints, exchanges authorisation codes for tokens and verifies ID tokens.
+	Provider struct {
+		issuer     string
+		httpClient *http.Client
+		timeFunc   func() time.Time
+
+		mtx           sync.RWMutex
+		metadata      *providerMetadata
+		keys          map[string]any
+		keysFetchedAt time.Time
+	}
+
+	providerMetadata struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	jsonWebKey struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+
+	tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+)
+
+// NewProvider returns a client for the OpenID Connect provider with the given issuer URL.
+// Provider metadata is discovered lazily, on first use.
+func NewProvider(issuer string, httpClient *http.Client, timeFunc func() time.Time) *Provider {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if timeFunc == nil {
+		timeFunc = time.Now
+	}
+	return &Provider{
+		issuer:     strings.TrimSuffix(issuer, "/"),
+		httpClient: httpClient,
+		timeFunc:   timeFunc,
+	}
+}
+
+// Issuer returns the provider's issuer identifier.
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+func (p *Provider) discover(ctx context.Context) (*providerMetadata, error) {
+	p.mtx.RLock()
+	md := p.metadata
+	p.mtx.RUnlock()
+	if md != nil {
+		return md, nil
+	}
+
+	var newMD providerMetadata
+	if err := p.getJSON(ctx, p.issuer+discoveryPath, &newMD); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscoveryFailed, err)
+	}
+
+	// See https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	if strings.TrimSuffix(newMD.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch: %s", ErrDiscoveryFailed, newMD.Issuer)
+	}
+	if newMD.AuthorizationEndpoint == "" || newMD.TokenEndpoint == "" || newMD.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete provider metadata", ErrDiscoveryFailed)
+	}
+
+	p.mtx.Lock()
+	p.metadata = &newMD
+	p.mtx.Unlock()
+
+	return &newMD, nil
+}
+
+// AuthCodeURL returns the URL of the provider's authorisation endpoint for the authorisation
+// code flow with PKCE.
+func (p *Provider) AuthCodeURL(ctx context.Context, clientID, redirectURL string, scopes []string, state, nonce, codeChallenge string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return md.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange exchanges the authorisation code for tokens and returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, clientID, clientSecret, code, redirectURL, codeVerifier string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if clientSecret == "" {
+		form.Set("client_id", clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTokenExchange, err)
+	}
+	defer res.Body.Close()
+
+	var rsp tokenResponse
+	if err = jsonw.Decode(res.Body, &rsp); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTokenExchange, err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: status %d: %s %s", ErrTokenExchange, res.StatusCode, rsp.Error, rsp.Description)
+	}
+
+	if rsp.IDToken == "" {
+		return "", ErrMissingIDToken
+	}
+
+	return rsp.IDToken, nil
+}
+
+// VerifyIDToken verifies the ID token's signature, issuer, audience, expiry and nonce
+// and returns its claims. The token's audience should contain at least one of
+// the accepted audiences.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken string, audiences []string, nonce string) (jwt.MapClaims, error) {
+	if _, err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.signingKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+		jwt.WithTimeFunc(p.timeFunc),
+	)
+	if err != nil {
+		if errors.Is(err, ErrUnknownSigningKey) {
+			return nil, ErrUnknownSigningKey
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	tokenAudiences, err := claims.GetAudience()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	accepted := false
+	for _, aud := range tokenAudiences {
+		if slices.Contains(audiences, aud) {
+			accepted = true
+			break
+		}
+	}
+	if !accepted {
+		return nil, ErrAudienceMismatch
+	}
+
+	// See https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+	if len(tokenAudiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != "" && !slices.Contains(audiences, azp) {
+			return nil, ErrAudienceMismatch
+		}
+	}
+
+	if nonce != "" {
+		if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+			return nil, ErrNonceMismatch
+		}
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) signingKey(ctx context.Context, kid string) (any, error) {
+	p.mtx.RLock()
+	key, found := p.lookupKey(kid)
+	lastFetch := p.keysFetchedAt
+	p.mtx.RUnlock()
+
+	if found {
+		return key, nil
+	}
+
+	if !lastFetch.IsZero() && p.timeFunc().Sub(lastFetch) < minKeyRefreshInterval {
+		return nil, ErrUnknownSigningKey
+	}
+
+	if err := p.fetchKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mtx.RLock()
+	key, found = p.lookupKey(kid)
+	p.mtx.RUnlock()
+
+	if !found {
+		return nil, ErrUnknownSigningKey
+	}
+
+	return key, nil
+}
+
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, found := p.keys[kid]
+	return key, found
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) error {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err = p.getJSON(ctx, md.JWKSURI, &jwks); err != nil {
+		return err
+	}
+
+	keys := make(map[string]any, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			if errors.Is(err, ErrUnsupportedKeyType) {
+				continue
+			}
+			return err
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.mtx.Lock()
+	p.keys = keys
+	p.keysFetchedAt = p.timeFunc()
+	p.mtx.Unlock()
+
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, u)
+	}
+
+	return jsonw.Decode(res.Body, v)
+}
+
+func (jwk *jsonWebKey) publicKey() (any, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedKeyType
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/storage"
+	"github.com/piprate/metalocker/wallet"
+)
+
+const (
+	// pendingLoginTTL defines how long the relying party waits for the provider
+	// to redirect the user back to the callback endpoint.
+	pendingLoginTTL = 10 * time.Minute
+)
+
+var (
+	ErrMissingSubject    = errors.New("subject claim not found in ID token")
+	ErrMissingEmail      = errors.New("email claim not found in ID token")
+	ErrEmailNotVerified  = errors.New("email not verified by OIDC provider")
+	ErrAccountNotLinked  = errors.New("account not linked to OIDC provider")
+	ErrSubjectMismatch   = errors.New("account linked to a different OIDC subject")
+	ErrAccountDisabled   = errors.New("account suspended or deleted")
+	ErrUnknownLoginState = errors.New("unknown or expired login state")
+)
+
+type (
+	// RelyingParty implements the OpenID Connect authorisation code flow (with PKCE)
+	// and issues MetaLocker JWTs to users authenticated by the external identity provider.
+	//
+	// Accounts are looked up by the email claim. The account's link to the provider's subject
+	// (see account.ExternalIdentity) guards against email reassignment at the provider.
+	// New managed accounts can be provisioned automatically. Their managed keys are protected
+	// with a passphrase derived from the server-held account secret, so that they can be
+	// unlocked without user's passphrase.
+	RelyingParty struct {
+		cfg             *Config
+		provider        *Provider
+		jwtMW           *apibase.GinJWTMiddleware
+		audiencePolicy  *apibase.AudiencePolicy
+		identityBackend storage.IdentityBackend
+		factory         *wallet.LocalFactory
+		httpClient      *http.Client
+		timeFunc        func() time.Time
+
+		pendingMtx sync.Mutex
+		pending    map[string]*pendingLogin
+	}
+
+	pendingLogin struct {
+		nonce        string
+		codeVerifier string
+		audience     string
+		audienceKey  string
+		expiresAt    time.Time
+	}
+
+	// Option is for defining optional parameters of the relying party.
+	Option func(rp *RelyingParty) error
+)
+
+// WithHTTPClient sets the HTTP client for communicating with the OIDC provider.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(rp *RelyingParty) error {
+		rp.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithTimeFunc sets the function that provides the current time.
+func WithTimeFunc(fn func() time.Time) Option {
+	return func(rp *RelyingParty) error {
+		rp.timeFunc = fn
+		return nil
+	}
+}
+
+func NewRelyingParty(cfg *Config, jwtMW *apibase.GinJWTMiddleware, audiencePolicy *apibase.AudiencePolicy,
+	identityBackend storage.IdentityBackend, ledger model.Ledger, opts ...Option) (*RelyingParty, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	rp := &RelyingParty{
+		cfg:             cfg,
+		jwtMW:           jwtMW,
+		audiencePolicy:  audiencePolicy,
+		identityBackend: identityBackend,
+		timeFunc:        time.Now,
+		pending:         make(map[string]*pendingLogin),
+	}
+
+	for _, fn := range opts {
+		if err := fn(rp); err != nil {
+			return nil, err
+		}
+	}
+
+	rp.provider = NewProvider(cfg.Issuer, rp.httpClient, rp.timeFunc)
+
+	if cfg.AutoProvision {
+		// we don't need to provide all services for account registration
+		factory, err := wallet.NewLocalFactory(ledger, nil, nil, identityBackend, nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		rp.factory = factory
+	}
+
+	return rp, nil
+}
+
+// LoginHandler starts the authorisation code flow by redirecting the user to the OIDC provider.
+// Optional 'audience' and 'audienceKey' query parameters have the same meaning as in
+// the password-based login form.
+func (rp *RelyingParty) LoginHandler(c *gin.Context) {
+	log := apibase.CtxLogger(c)
+
+	state := randomToken()
+	login := &pendingLogin{
+		nonce:        randomToken(),
+		codeVerifier: randomToken(),
+		audience:     c.Query("audience"),
+		audienceKey:  c.Query("audienceKey"),
+		expiresAt:    rp.timeFunc().Add(pendingLoginTTL),
+	}
+
+	challenge := sha256.Sum256([]byte(login.codeVerifier))
+
+	authURL, err := rp.provider.AuthCodeURL(c, rp.cfg.ClientID, rp.cfg.RedirectURL, rp.cfg.Scopes,
+		state, login.nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		log.Err(err).Str("issuer", rp.cfg.Issuer).Msg("Error building OIDC authorisation URL")
+		apibase.AbortWithError(c, http.StatusBadGateway, "OIDC provider not available")
+		return
+	}
+
+	rp.putPendingLogin(state, login)
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// CallbackHandler completes the authorisation code flow. It exchanges the authorisation code
+// for an ID token, maps the token's subject to a MetaLocker account and responds with
+// a MetaLocker JWT.
+func (rp *RelyingParty) CallbackHandler(c *gin.Context) {
+	log := apibase.CtxLogger(c)
+
+	if errCode := c.Query("error"); errCode != "" {
+		log.Error().Str("ip", c.ClientIP()).Str("error", errCode).Str("description", c.Query("error_description")).
+			Msg("OIDC authentication failed")
+		apibase.AbortWithError(c, http.StatusUnauthorized, "OIDC authentication failed")
+		return
+	}
+
+	login := rp.takePendingLogin(c.Query("state"))
+	if login == nil {
+		log.Error().Str("ip", c.ClientIP()).Msg("OIDC callback with unknown state")
+		apibase.AbortWithError(c, http.StatusBadRequest, ErrUnknownLoginState.Error())
+		return
+	}
+
+	rawIDToken, err := rp.provider.Exchange(c, rp.cfg.ClientID, rp.cfg.ClientSecret, c.Query("code"),
+		rp.cfg.RedirectURL, login.codeVerifier)
+	if err != nil {
+		log.Err(err).Str("ip", c.ClientIP()).Msg("OIDC code exchange failed")
+		apibase.AbortWithError(c, http.StatusUnauthorized, "OIDC authentication failed")
+		return
+	}
+
+	claims, err := rp.provider.VerifyIDToken(c, rawIDToken, rp.cfg.Audiences, login.nonce)
+	if err != nil {
+		log.Err(err).Str("ip", c.ClientIP()).Msg("ID token verification failed")
+		apibase.AbortWithError(c, http.StatusUnauthorized, "OIDC authentication failed")
+		return
+	}
+
+	acct, managedKey, err := rp.resolveAccount(c, claims)
+	if err != nil {
+		log.Err(err).Str("ip", c.ClientIP()).Msg("Failed to map OIDC subject to account")
+		apibase.AbortWithError(c, http.StatusUnauthorized, "OIDC authentication failed")
+		return
+	}
+
+	payload, err := rp.audiencePolicy.Claims(acct, managedKey, login.audience, login.audienceKey)
+	if err != nil {
+		log.Err(err).Str("ip", c.ClientIP()).Str("userID", acct.ID).Str("aud", login.audience).
+			Msg("Token audience check failed")
+		apibase.AbortWithError(c, http.StatusUnauthorized, "OIDC authentication failed")
+		return
+	}
+
+	tokenString, expire, err := rp.jwtMW.TokenGenerator(payload)
+	if err != nil {
+		log.Err(err).Msg("Error when creating JWT token")
+		apibase.AbortWithError(c, http.StatusUnauthorized, "Create JWT Token failed")
+		return
+	}
+
+	log.Debug().Str("userID", acct.ID).Msg("OIDC authentication successful")
+
+	rp.jwtMW.LoginResponse(c, http.StatusOK, tokenString, expire)
+}
+
+func (rp *RelyingParty) resolveAccount(ctx context.Context, claims jwt.MapClaims) (*account.Account, *model.AESKey, error) {
+	subject := stringClaim(claims, rp.cfg.Claims.Subject)
+	if subject == "" {
+		return nil, nil, ErrMissingSubject
+	}
+
+	email := strings.ToLower(stringClaim(claims, rp.cfg.Claims.Email))
+	if email == "" {
+		return nil, nil, ErrMissingEmail
+	}
+
+	emailVerified, _ := claims[rp.cfg.Claims.EmailVerified].(bool)
+	if rp.cfg.RequireVerifiedEmail && !emailVerified {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	issuer := rp.provider.Issuer()
+
+	acct, err := rp.identityBackend.GetAccount(ctx, email)
+	switch {
+	case err == nil:
+		link := acct.FindExternalIdentity(issuer)
+		if link == nil {
+			if !rp.cfg.LinkExistingAccounts {
+				return nil, nil, ErrAccountNotLinked
+			}
+			// only the owner of the email address can link it to the account
+			if !emailVerified {
+				return nil, nil, ErrEmailNotVerified
+			}
+			acct.ExternalIdentities = append(acct.ExternalIdentities, &account.ExternalIdentity{
+				Issuer:  issuer,
+				Subject: subject,
+			})
+			if err = rp.identityBackend.UpdateAccount(ctx, acct); err != nil {
+				return nil, nil, err
+			}
+		} else if link.Subject != subject {
+			return nil, nil, ErrSubjectMismatch
+		}
+	case errors.Is(err, storage.ErrAccountNotFound) && rp.cfg.AutoProvision:
+		acct, err = rp.provisionAccount(ctx, issuer, subject, email, claims)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, err
+	}
+
+	if acct.State == account.StateSuspended || acct.State == account.StateDeleted {
+		return nil, nil, ErrAccountDisabled
+	}
+
+	if acct.State == account.StateRecovery || rp.cfg.AccountSecret == "" {
+		return acct, nil, nil
+	}
+
+	managedKey, err := acct.ExtractManagedKey(rp.accountPassphrase(issuer, subject))
+	if err != nil {
+		if errors.Is(err, account.ErrInvalidPassphrase) {
+			// the account is protected with the user's own passphrase. The issued token
+			// will not contain the managed key.
+			return acct, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return acct, managedKey, nil
+}
+
+func (rp *RelyingParty) provisionAccount(ctx context.Context, issuer, subject, email string, claims jwt.MapClaims) (*account.Account, error) {
+	name := stringClaim(claims, rp.cfg.Claims.Name)
+	if name == "" {
+		name = email
+	}
+
+	registeredAt := rp.timeFunc()
+
+	dw, _, err := rp.factory.RegisterAccount(ctx,
+		&account.Account{
+			Email:        email,
+			Name:         name,
+			GivenName:    stringClaim(claims, rp.cfg.Claims.GivenName),
+			FamilyName:   stringClaim(claims, rp.cfg.Claims.FamilyName),
+			AccessLevel:  model.AccessLevelManaged,
+			State:        account.StateActive,
+			RegisteredAt: &registeredAt,
+			DefaultVault: rp.cfg.DefaultVault,
+			ExternalIdentities: []*account.ExternalIdentity{
+				{
+					Issuer:  issuer,
+					Subject: subject,
+				},
+			},
+		},
+		account.WithHashedPassphraseAuth(rp.accountPassphrase(issuer, subject)))
+	if err != nil {
+		return nil, err
+	}
+
+	return dw.Account(), nil
+}
+
+// accountPassphrase derives the hashed passphrase that protects the managed key of
+// the account linked to the given subject.
+func (rp *RelyingParty) accountPassphrase(issuer, subject string) string {
+	mac := hmac.New(sha256.New, []byte(rp.cfg.AccountSecret))
+	_, _ = mac.Write([]byte(issuer + "\n" + subject))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (rp *RelyingParty) putPendingLogin(state string, login *pendingLogin) {
+	rp.pendingMtx.Lock()
+	defer rp.pendingMtx.Unlock()
+
+	now := rp.timeFunc()
+	for key, val := range rp.pending {
+		if now.After(val.expiresAt) {
+			delete(rp.pending, key)
+		}
+	}
+
+	rp.pending[state] = login
+}
+
+func (rp *RelyingParty) takePendingLogin(state string) *pendingLogin {
+	rp.pendingMtx.Lock()
+	defer rp.pendingMtx.Unlock()
+
+	login, found := rp.pending[state]
+	if !found {
+		return nil
+	}
+	delete(rp.pending, state)
+
+	if rp.timeFunc().After(login.expiresAt) {
+		return nil
+	}
+
+	return login
+}
+
+// InitRoutes registers the relying party's login and callback endpoints.
+func InitRoutes(r gin.IRouter, path string, rp *RelyingParty) {
+	r.GET(path+"/login", rp.LoginHandler)
+	r.GET(path+"/callback", rp.CallbackHandler)
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	val, _ := claims[name].(string)
+	return val
+}
+
+func randomToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base58.Encode(buf)
+}
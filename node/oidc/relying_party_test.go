@@ -0,0 +1,403 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/contexts"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/account"
+	. "github.com/piprate/metalocker/node/oidc"
+	"github.com/piprate/metalocker/node/oidc/oidctest"
+	"github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID     = "metalocker"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "http://localhost:4000/v1/oidc/callback"
+)
+
+type testSetup struct {
+	env         *testbase.TestMetaLockerEnvironment
+	issuer      *oidctest.Issuer
+	rp          *RelyingParty
+	jwtMW       *apibase.GinJWTMiddleware
+	audienceKey ed25519.PrivateKey
+}
+
+func init() {
+	_ = contexts.PreloadContextsIntoMemory()
+	testbase.SetupLogFormat()
+
+	gin.SetMode(gin.ReleaseMode)
+}
+
+func newTestSetup(t *testing.T, cfgFn func(cfg *Config)) *testSetup {
+	t.Helper()
+
+	env := testbase.SetUpTestEnvironment(t)
+	t.Cleanup(func() { _ = env.Close() })
+
+	iss, err := oidctest.NewIssuer(testClientID, testClientSecret)
+	require.NoError(t, err)
+	t.Cleanup(iss.Close)
+
+	jwtMW, err := apibase.New(&apibase.GinJWTMiddleware{
+		Realm:       "Test Realm",
+		Key:         []byte("test secret"),
+		Timeout:     time.Hour,
+		IdentityKey: apibase.UserIDKey,
+		PayloadFunc: apibase.Payload,
+	})
+	require.NoError(t, err)
+
+	audiencePub, audiencePriv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	cfg := &Config{
+		Issuer:        iss.URL(),
+		ClientID:      testClientID,
+		ClientSecret:  testClientSecret,
+		RedirectURL:   testRedirectURL,
+		AutoProvision: true,
+		AccountSecret: "server-held secret",
+		DefaultVault:  testbase.TestVaultName,
+	}
+	if cfgFn != nil {
+		cfgFn(cfg)
+	}
+
+	rp, err := NewRelyingParty(cfg, jwtMW,
+		apibase.NewAudiencePolicy([]string{"test"}, "test", base64.StdEncoding.EncodeToString(audiencePub)),
+		env.IdentityBackend, env.Ledger)
+	require.NoError(t, err)
+
+	return &testSetup{
+		env:         env,
+		issuer:      iss,
+		rp:          rp,
+		jwtMW:       jwtMW,
+		audienceKey: audiencePriv,
+	}
+}
+
+// login runs the authorisation code flow and returns the callback response.
+func (ts *testSetup) login(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/v1/oidc/login", http.NoBody)
+
+	ts.rp.LoginHandler(c)
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	authURL := rec.Header().Get("Location")
+	require.Contains(t, authURL, ts.issuer.URL()+"/authorize")
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := client.Get(authURL)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	require.Equal(t, http.StatusFound, res.StatusCode)
+
+	callbackURL, err := url.Parse(res.Header.Get("Location"))
+	require.NoError(t, err)
+
+	rec = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(rec)
+	c.Request, _ = http.NewRequest(http.MethodGet, callbackURL.RequestURI(), http.NoBody)
+
+	ts.rp.CallbackHandler(c)
+
+	return rec
+}
+
+func (ts *testSetup) tokenClaims(t *testing.T, rec *httptest.ResponseRecorder) apibase.MapClaims {
+	t.Helper()
+
+	var rsp struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, jsonw.Unmarshal(rec.Body.Bytes(), &rsp))
+
+	token, err := ts.jwtMW.ParseTokenString(rsp.Token)
+	require.NoError(t, err)
+
+	return apibase.ExtractClaimsFromToken(token)
+}
+
+func TestRelyingParty_AutoProvision(t *testing.T) {
+	ts := newTestSetup(t, nil)
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":            "user-1",
+		"email":          "Jane@Example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	})
+
+	rec := ts.login(t)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	claims := ts.tokenClaims(t, rec)
+	assert.Equal(t, "jane@example.com", claims[apibase.ClaimEmail])
+	assert.Equal(t, "test", claims[apibase.ClaimAudience])
+
+	managedKey, err := apibase.ExtractSecret(claims, ts.audienceKey)
+	require.NoError(t, err)
+	require.NotNil(t, managedKey)
+
+	acct, err := ts.env.IdentityBackend.GetAccount(ts.env.Ctx, "jane@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, claims[apibase.ClaimAccountID], acct.ID)
+	assert.Equal(t, model.AccessLevelManaged, acct.AccessLevel)
+	assert.Equal(t, "Jane Doe", acct.Name)
+	assert.Equal(t, []*account.ExternalIdentity{{Issuer: ts.issuer.URL(), Subject: "user-1"}}, acct.ExternalIdentities)
+
+	// the managed key from the token unlocks the account's data wallet
+
+	dw, err := ts.env.Factory.CreateDataWallet(acct)
+	require.NoError(t, err)
+	require.NoError(t, dw.UnlockAsManaged(ts.env.Ctx, managedKey))
+
+	// subsequent logins map to the same account
+
+	rec = ts.login(t)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, acct.ID, ts.tokenClaims(t, rec)[apibase.ClaimAccountID])
+
+	// a different subject with the same email is rejected
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":   "user-2",
+		"email": "jane@example.com",
+	})
+
+	rec = ts.login(t)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRelyingParty_ClaimsMapping(t *testing.T) {
+	ts := newTestSetup(t, func(cfg *Config) {
+		cfg.Claims.Subject = "oid"
+		cfg.Claims.Email = "upn"
+		cfg.RequireVerifiedEmail = true
+		cfg.Claims.EmailVerified = "upn_verified"
+	})
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub": "ignored",
+		"oid": "object-1",
+		"upn": "john@example.com",
+	})
+
+	rec := ts.login(t)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":          "ignored",
+		"oid":          "object-1",
+		"upn":          "john@example.com",
+		"upn_verified": true,
+	})
+
+	rec = ts.login(t)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	acct, err := ts.env.IdentityBackend.GetAccount(ts.env.Ctx, "john@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, acct.FindExternalIdentity(ts.issuer.URL()))
+	assert.Equal(t, "object-1", acct.FindExternalIdentity(ts.issuer.URL()).Subject)
+}
+
+func TestRelyingParty_Audience(t *testing.T) {
+	ts := newTestSetup(t, nil)
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":   "user-1",
+		"email": "jane@example.com",
+		"aud":   "another-client",
+	})
+
+	rec := ts.login(t)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	ts = newTestSetup(t, func(cfg *Config) {
+		cfg.Audiences = []string{testClientID, "another-client"}
+	})
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":   "user-1",
+		"email": "jane@example.com",
+		"aud":   "another-client",
+	})
+
+	rec = ts.login(t)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestRelyingParty_ExistingAccount(t *testing.T) {
+	ts := newTestSetup(t, func(cfg *Config) {
+		cfg.AutoProvision = false
+	})
+
+	ts.env.CreateCustomAccount(t, "jane@example.com", "Jane Doe", model.AccessLevelManaged)
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":   "user-1",
+		"email": "jane@example.com",
+	})
+
+	// not linked
+
+	rec := ts.login(t)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// unknown account
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":   "user-2",
+		"email": "john@example.com",
+	})
+
+	rec = ts.login(t)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// link on first login. The account is protected with the user's passphrase,
+	// so the token doesn't carry the managed key.
+
+	ts = newTestSetup(t, func(cfg *Config) {
+		cfg.AutoProvision = false
+		cfg.LinkExistingAccounts = true
+	})
+
+	dw := ts.env.CreateCustomAccount(t, "jane@example.com", "Jane Doe", model.AccessLevelManaged)
+
+	// unverified emails can't be linked to existing accounts
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":   "attacker",
+		"email": "jane@example.com",
+	})
+
+	rec = ts.login(t)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	acct, err := ts.env.IdentityBackend.GetAccount(ts.env.Ctx, dw.ID())
+	require.NoError(t, err)
+	assert.Nil(t, acct.FindExternalIdentity(ts.issuer.URL()))
+
+	ts.issuer.SetClaims(map[string]any{
+		"sub":            "user-1",
+		"email":          "jane@example.com",
+		"email_verified": true,
+	})
+
+	rec = ts.login(t)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	claims := ts.tokenClaims(t, rec)
+	assert.Equal(t, dw.ID(), claims[apibase.ClaimAccountID])
+	assert.NotContains(t, claims, apibase.ClaimEncryptedAudienceSecret)
+
+	acct, err = ts.env.IdentityBackend.GetAccount(ts.env.Ctx, dw.ID())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", acct.FindExternalIdentity(ts.issuer.URL()).Subject)
+}
+
+func TestRelyingParty_CallbackErrors(t *testing.T) {
+	ts := newTestSetup(t, nil)
+
+	invoke := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/v1/oidc/callback?"+query, http.NoBody)
+
+		ts.rp.CallbackHandler(c)
+
+		return rec
+	}
+
+	rec := invoke("code=abc&state=unknown")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = invoke("error=access_denied&state=unknown")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	iss, err := oidctest.NewIssuer(testClientID, "")
+	require.NoError(t, err)
+	defer iss.Close()
+
+	p := NewProvider(iss.URL(), nil, nil)
+
+	token, err := iss.IDToken(map[string]any{
+		"sub":   "user-1",
+		"nonce": "abc",
+	})
+	require.NoError(t, err)
+
+	claims, err := p.VerifyIDToken(context.Background(), token, []string{testClientID}, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims["sub"])
+
+	_, err = p.VerifyIDToken(context.Background(), token, []string{testClientID}, "xyz")
+	assert.ErrorIs(t, err, ErrNonceMismatch)
+
+	_, err = p.VerifyIDToken(context.Background(), token, []string{"another-client"}, "abc")
+	assert.ErrorIs(t, err, ErrAudienceMismatch)
+
+	// expired token
+
+	token, err = iss.IDToken(map[string]any{
+		"sub": "user-1",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+
+	_, err = p.VerifyIDToken(context.Background(), token, []string{testClientID}, "")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// wrong issuer
+
+	token, err = iss.IDToken(map[string]any{
+		"sub": "user-1",
+		"iss": "https://evil.example.com",
+	})
+	require.NoError(t, err)
+
+	_, err = p.VerifyIDToken(context.Background(), token, []string{testClientID}, "")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
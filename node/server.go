@@ -29,10 +29,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/knadh/koanf"
 	"github.com/piprate/metalocker/contexts"
+	"github.com/piprate/metalocker/index"
 	"github.com/piprate/metalocker/ledger"
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/node/api"
 	"github.com/piprate/metalocker/node/api/admin"
+	"github.com/piprate/metalocker/node/oidc"
+	"github.com/piprate/metalocker/node/sweeper"
 	"github.com/piprate/metalocker/node/vaultapi"
 	"github.com/piprate/metalocker/sdk/apibase"
 	"github.com/piprate/metalocker/sdk/cmdbase"
@@ -41,6 +44,7 @@ import (
 	"github.com/piprate/metalocker/utils"
 	"github.com/piprate/metalocker/utils/security"
 	"github.com/piprate/metalocker/vaults"
+	"github.com/piprate/metalocker/wallet"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 )
@@ -59,6 +63,10 @@ type (
 		OffChainVault   vaults.Vault
 		Ledger          model.Ledger
 		BlobManager     *vaults.LocalBlobManager
+		IndexClient     index.Client
+		IndexStoreName  string
+		Sweeper         *sweeper.Sweeper
+		Throttle        *apibase.Throttle
 		NS              notification.Service
 		Router          *gin.Engine
 
@@ -156,6 +164,36 @@ func (mls *MetaLockerServer) InitServices(ctx context.Context, cfg *koanf.Koanf,
 		return err
 	}
 
+	// initialise lease sweeper (optional)
+
+	if cfg.Exists("sweeper") {
+		mls.Sweeper, err = InitSweeper(cfg, mls.Ledger, mls.OffChainVault, mls.BlobManager)
+		if err != nil {
+			return err
+		}
+		mls.Warden.CloseOnShutdown(mls.Sweeper)
+	}
+
+	// initialise authentication throttle (optional)
+
+	if cfg.Exists("authThrottle") {
+		mls.Throttle, err = InitThrottle(cfg, mls.Resolver)
+		if err != nil {
+			return err
+		}
+		mls.Warden.CloseOnShutdown(mls.Throttle)
+	}
+
+	// initialise hosted index store (optional)
+
+	if cfg.Exists("indexStore") {
+		mls.IndexClient, mls.IndexStoreName, err = InitIndexClient(ctx, cfg, mls.Resolver, mls.Ledger)
+		if err != nil {
+			return err
+		}
+		mls.Warden.CloseOnShutdown(mls.IndexClient)
+	}
+
 	// initialise router
 
 	mls.Router = InitRouter(
@@ -200,16 +238,33 @@ func (mls *MetaLockerServer) InitStandardRoutes(ctx context.Context, cfg *koanf.
 			return cli.Exit(err, 1)
 		}
 		admin.InitRoutes(r, "/v1/admin", adminAuthFunc, mls.IdentityBackend)
+		if mls.Sweeper != nil {
+			admin.InitSweeperRoutes(r, "/v1/admin", adminAuthFunc, mls.Sweeper)
+		}
+		if mls.Throttle != nil {
+			admin.InitThrottleRoutes(r, "/v1/admin", adminAuthFunc, mls.Throttle)
+		}
 	}
 
-	api.InitRegisterRoute(r, "/v1/register", cfg, mls.JWTMiddleware, mls.IdentityBackend, mls.Ledger)
+	// authentication endpoints are rate limited if the throttle is configured
+
+	api.InitRegisterRoute(r, "/v1/register", cfg, mls.JWTMiddleware, mls.IdentityBackend, mls.Ledger,
+		mls.Throttle.Middleware())
 
-	r.POST("/v1/authenticate", mls.JWTMiddleware.LoginHandler)
+	r.POST("/v1/authenticate", mls.Throttle.Middleware(), mls.JWTMiddleware.LoginHandler)
 	r.GET("/v1/refresh-token", mls.JWTMiddleware.RefreshHandler)
+
+	if cfg.Exists("oidc") {
+		rp, err := InitOIDCRelyingParty(cfg, mls.Resolver, mls.JWTMiddleware, mls.IdentityBackend, mls.Ledger)
+		if err != nil {
+			return err
+		}
+		oidc.InitRoutes(r, "/v1/oidc", rp)
+	}
 	r.POST("/v1/validate-request", api.ValidateRequestSignatureHandler(mls.IdentityBackend))
 
-	r.GET("/v1/recovery-code", api.GetRecoveryCodeHandler(mls.IdentityBackend))
-	r.POST("/v1/recover-account", api.RecoverAccountHandler(mls.IdentityBackend))
+	r.GET("/v1/recovery-code", mls.Throttle.Middleware(), api.GetRecoveryCodeHandler(mls.IdentityBackend))
+	r.POST("/v1/recover-account", mls.Throttle.Middleware(), api.RecoverAccountHandler(mls.IdentityBackend))
 
 	r.GET("/v1/status", api.GetStatusHandler(&mls.ServerControls, mls.Ledger))
 
@@ -221,6 +276,15 @@ func (mls *MetaLockerServer) InitStandardRoutes(ctx context.Context, cfg *koanf.
 	api.InitLedgerRoutes(v1, mls.Ledger, mls.OffChainVault)
 	api.InitDIDRoutes(v1, mls.IdentityBackend)
 
+	if mls.IndexClient != nil {
+		factory, err := wallet.NewLocalFactory(mls.Ledger, NewOffChainStorageProxy(mls.OffChainVault), mls.BlobManager, mls.IdentityBackend,
+			mls.NS, mls.IndexClient, nil)
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+		api.InitDataSetRoutes(v1, mls.IdentityBackend, factory, mls.IndexClient, mls.IndexStoreName)
+	}
+
 	v1.GET("/notifications", api.NotificationChannelHandler(mls.NS))
 
 	// initialise vaults
@@ -241,6 +305,10 @@ func (mls *MetaLockerServer) InitStandardRoutes(ctx context.Context, cfg *koanf.
 func (mls *MetaLockerServer) Run(cfg *koanf.Koanf) error {
 	listenAddr := fmt.Sprintf(":%d", mls.port)
 
+	if mls.Sweeper != nil {
+		mls.Sweeper.Start()
+	}
+
 	log.Info().Str("addr", listenAddr).Bool("secure", cfg.Bool("https")).Msg("Starting HTTP server")
 
 	mls.httpServer = &http.Server{
@@ -400,6 +468,74 @@ func InitVaults(cfg *koanf.Koanf, resolver cmdbase.ParameterResolver, ledgerAPI
 	return lbm, nil
 }
 
+func InitIndexClient(ctx context.Context, cfg *koanf.Koanf, resolver cmdbase.ParameterResolver, ledgerAPI model.Ledger) (index.Client, string, error) {
+	var storeCfg index.StoreConfig
+	if err := cfg.Unmarshal("indexStore", &storeCfg); err != nil {
+		log.Err(err).Msg("Failed to read index store configuration")
+		return nil, "", cli.Exit(err, 1)
+	}
+
+	gb, err := ledgerAPI.GetGenesisBlock(ctx)
+	if err != nil {
+		log.Err(err).Msg("Failed to read genesis block")
+		return nil, "", cli.Exit(err, 1)
+	}
+
+	indexClient, err := index.NewLocalIndexClient(ctx, []*index.StoreConfig{&storeCfg}, resolver, gb.Hash)
+	if err != nil {
+		log.Err(err).Msg("Failed to create index client")
+		return nil, "", cli.Exit(err, 1)
+	}
+
+	return indexClient, storeCfg.Name, nil
+}
+
+func InitSweeper(cfg *koanf.Koanf, ledgerAPI model.Ledger, offChainVault vaults.Vault, blobManager *vaults.LocalBlobManager) (*sweeper.Sweeper, error) {
+	var sweeperCfg sweeper.Config
+	if err := cfg.Unmarshal("sweeper", &sweeperCfg); err != nil {
+		log.Err(err).Msg("Failed to read sweeper configuration")
+		return nil, cli.Exit(err, 1)
+	}
+
+	sw, err := sweeper.New(&sweeperCfg, ledgerAPI, offChainVault, blobManager.Vaults())
+	if err != nil {
+		log.Err(err).Msg("Failed to create lease sweeper")
+		return nil, cli.Exit(err, 1)
+	}
+
+	return sw, nil
+}
+
+func InitThrottle(cfg *koanf.Koanf, resolver cmdbase.ParameterResolver) (*apibase.Throttle, error) {
+	var throttleCfg apibase.ThrottleConfig
+	if err := cfg.Unmarshal("authThrottle", &throttleCfg); err != nil {
+		log.Err(err).Msg("Failed to read authentication throttle configuration")
+		return nil, cli.Exit(err, 1)
+	}
+
+	storeCfg := throttleCfg.Store
+	if storeCfg == nil {
+		storeCfg = &apibase.ThrottleStoreConfig{
+			Type: apibase.MemoryThrottleStoreType,
+		}
+	}
+
+	store, err := apibase.CreateThrottleStore(storeCfg, resolver)
+	if err != nil {
+		log.Err(err).Msg("Failed to create authentication throttle store")
+		return nil, cli.Exit(err, 1)
+	}
+
+	throttle, err := apibase.NewThrottle(&throttleCfg, store)
+	if err != nil {
+		_ = store.Close()
+		log.Err(err).Msg("Failed to create authentication throttle")
+		return nil, cli.Exit(err, 1)
+	}
+
+	return throttle, nil
+}
+
 func InitRouter(corsCfg *cors.Config) *gin.Engine {
 	r := gin.New()
 	_ = r.SetTrustedProxies(nil)
@@ -427,6 +563,39 @@ func InitAuthMiddleware(cfg *koanf.Koanf, realm string, resolver cmdbase.Paramet
 		jwtTimeout = 14 * 24 * 60
 	}
 
+	audCfg, err := readAudienceConfig(cfg, resolver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authMiddleware, err := apibase.JWTMiddlewareWithTokenIssuance(
+		realm,
+		cfg.String("issuer"),
+		apibase.AuthenticationHandler(identityBackend, audCfg.acceptedAudiences, audCfg.defaultAudience, audCfg.defaultAudiencePublicKey),
+		audCfg.defaultAudiencePrivateKey,
+		privateKeyPath, publicKeyPath, time.Duration(jwtTimeout)*time.Minute, time.Now)
+	if err != nil {
+		log.Err(err).Msg("Error when initialising JWT middleware")
+		return nil, nil, cli.Exit(err, 1)
+	}
+
+	publicKeyBytes, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		log.Err(err).Msg("Error when reading token public key")
+		return nil, nil, cli.Exit(err, 1)
+	}
+
+	return authMiddleware, publicKeyBytes, nil
+}
+
+type audienceConfig struct {
+	acceptedAudiences         []string
+	defaultAudience           string
+	defaultAudiencePublicKey  string
+	defaultAudiencePrivateKey string
+}
+
+func readAudienceConfig(cfg *koanf.Koanf, resolver cmdbase.ParameterResolver) (*audienceConfig, error) {
 	acceptedAudiences := cfg.Strings("acceptedAudiences")
 
 	defaultAudience := cfg.String("defaultAudience")
@@ -441,31 +610,49 @@ func InitAuthMiddleware(cfg *koanf.Koanf, realm string, resolver cmdbase.Paramet
 	defaultAudiencePublicKey, err := resolver.ResolveString(cfg.Get("defaultAudiencePublicKey"))
 	if err != nil {
 		log.Error().Msg("Error reading audience public key")
-		return nil, nil, cli.Exit(err, 1)
+		return nil, cli.Exit(err, 1)
 	}
 
 	defaultAudiencePrivateKey, err := resolver.ResolveString(cfg.Get("defaultAudiencePrivateKey"))
 	if err != nil {
 		log.Error().Msg("Error reading audience private key")
-		return nil, nil, cli.Exit(err, 1)
+		return nil, cli.Exit(err, 1)
 	}
 
-	authMiddleware, err := apibase.JWTMiddlewareWithTokenIssuance(
-		realm,
-		cfg.String("issuer"),
-		apibase.AuthenticationHandler(identityBackend, acceptedAudiences, defaultAudience, defaultAudiencePublicKey),
-		defaultAudiencePrivateKey,
-		privateKeyPath, publicKeyPath, time.Duration(jwtTimeout)*time.Minute, time.Now)
+	return &audienceConfig{
+		acceptedAudiences:         acceptedAudiences,
+		defaultAudience:           defaultAudience,
+		defaultAudiencePublicKey:  defaultAudiencePublicKey,
+		defaultAudiencePrivateKey: defaultAudiencePrivateKey,
+	}, nil
+}
+
+// InitOIDCRelyingParty initialises OpenID Connect login, as defined in the 'oidc' section
+// of the node configuration.
+func InitOIDCRelyingParty(cfg *koanf.Koanf, resolver cmdbase.ParameterResolver, jwtMW *apibase.GinJWTMiddleware,
+	identityBackend storage.IdentityBackend, ledger model.Ledger) (*oidc.RelyingParty, error) {
+	oidcCfg, err := oidc.ReadConfig(cfg, "oidc", resolver)
 	if err != nil {
-		log.Err(err).Msg("Error when initialising JWT middleware")
-		return nil, nil, cli.Exit(err, 1)
+		log.Err(err).Msg("Error reading OIDC configuration")
+		return nil, cli.Exit(err, 1)
 	}
 
-	publicKeyBytes, err := os.ReadFile(publicKeyPath)
+	if oidcCfg.DefaultVault == "" {
+		oidcCfg.DefaultVault = cfg.String("defaultVaultName")
+	}
+
+	audCfg, err := readAudienceConfig(cfg, resolver)
 	if err != nil {
-		log.Err(err).Msg("Error when reading token public key")
-		return nil, nil, cli.Exit(err, 1)
+		return nil, err
 	}
 
-	return authMiddleware, publicKeyBytes, nil
+	rp, err := oidc.NewRelyingParty(oidcCfg, jwtMW,
+		apibase.NewAudiencePolicy(audCfg.acceptedAudiences, audCfg.defaultAudience, audCfg.defaultAudiencePublicKey),
+		identityBackend, ledger)
+	if err != nil {
+		log.Err(err).Msg("Error when initialising OIDC relying party")
+		return nil, cli.Exit(err, 1)
+	}
+
+	return rp, nil
 }
@@ -0,0 +1,447 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sweeper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/measure"
+	"github.com/piprate/metalocker/vaults"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultInterval  = time.Hour
+	defaultBatchSize = 100
+
+	// cursorName identifies the sweeper's position in LedgerCursorStore.
+	cursorName = "sweeper"
+)
+
+type (
+	// Config defines the lease sweeper's configuration.
+	Config struct {
+		// Interval between sweeps (i.e. 30m, 1h). Default is 1 hour.
+		Interval string `koanf:"interval" json:"interval"`
+		// BatchSize is the maximum number of expired leases processed in one sweep.
+		BatchSize int `koanf:"batchSize" json:"batchSize"`
+		// DryRun is true if the sweeper should only report what it would purge.
+		DryRun bool `koanf:"dryRun" json:"dryRun"`
+		// StartBlock is the ledger block to start scanning for lease revocations from.
+		// If the ledger supports cursors, the sweeper resumes from the last block it
+		// processed, unless StartBlock is further ahead.
+		StartBlock int64 `koanf:"startBlock" json:"startBlock"`
+	}
+
+	// Report describes the outcome of one sweep.
+	Report struct {
+		DryRun bool `json:"dryRun"`
+		// ExpiredLeases contains IDs of expired lease records.
+		ExpiredLeases []string `json:"expiredLeases,omitempty"`
+		// RevokedLeases contains IDs of revoked lease records.
+		RevokedLeases []string `json:"revokedLeases,omitempty"`
+		// PurgedBlobs contains IDs of purged data assets (or data assets
+		// that would be purged in dry run mode).
+		PurgedBlobs []string `json:"purgedBlobs,omitempty"`
+		// PurgedOperations contains addresses of purged off-chain operations
+		// (or operations that would be purged in dry run mode).
+		PurgedOperations []string `json:"purgedOperations,omitempty"`
+		Errors           int      `json:"errors"`
+	}
+
+	// Metrics contains cumulative sweeper statistics.
+	Metrics struct {
+		DryRun            bool      `json:"dryRun"`
+		Runs              uint64    `json:"runs"`
+		LastRunAt         time.Time `json:"lastRunAt"`
+		LastRunDurationMs int64     `json:"lastRunDurationMs"`
+		LastBlock         int64     `json:"lastBlock"`
+		ExpiredLeases     uint64    `json:"expiredLeases"`
+		RevokedLeases     uint64    `json:"revokedLeases"`
+		PurgedBlobs       uint64    `json:"purgedBlobs"`
+		PurgedOperations  uint64    `json:"purgedOperations"`
+		Errors            uint64    `json:"errors"`
+	}
+
+	// Sweeper enforces lease expiry and purges blobs and off-chain operations
+	// of expired and revoked leases, once no other live lease references them.
+	Sweeper struct {
+		ledger        model.Ledger
+		offChainVault vaults.Vault
+		blobVaults    []vaults.Vault
+
+		interval  time.Duration
+		batchSize int
+		dryRun    bool
+		nowFn     func() time.Time
+
+		lastBlock    int64
+		cursorLoaded bool
+		metrics      Metrics
+
+		sweepMtx   sync.Mutex
+		metricsMtx sync.RWMutex
+		stop       chan struct{}
+		wg         sync.WaitGroup
+	}
+
+	Option func(s *Sweeper)
+)
+
+// WithClock sets the function that returns the current time. Useful for testing.
+func WithClock(nowFn func() time.Time) Option {
+	return func(s *Sweeper) {
+		s.nowFn = nowFn
+	}
+}
+
+// New creates a new lease sweeper. Blobs are purged from the given vaults and
+// off-chain operations are purged from the off-chain vault.
+func New(cfg *Config, ledger model.Ledger, offChainVault vaults.Vault, blobVaults []vaults.Vault, opts ...Option) (*Sweeper, error) {
+	s := &Sweeper{
+		ledger:        ledger,
+		offChainVault: offChainVault,
+		blobVaults:    blobVaults,
+		interval:      defaultInterval,
+		batchSize:     defaultBatchSize,
+		dryRun:        cfg.DryRun,
+		nowFn:         time.Now,
+		lastBlock:     cfg.StartBlock - 1,
+	}
+
+	if cfg.Interval != "" {
+		interval, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, err
+		}
+		if interval <= 0 {
+			return nil, errors.New("sweeper interval should be positive")
+		}
+		s.interval = interval
+	}
+	if cfg.BatchSize > 0 {
+		s.batchSize = cfg.BatchSize
+	}
+
+	for _, fn := range opts {
+		fn(s)
+	}
+
+	s.metrics.DryRun = s.dryRun
+	s.metrics.LastBlock = s.lastBlock
+
+	if _, ok := ledger.(model.LeaseExpiryEnforcer); !ok {
+		log.Warn().Msg("Ledger doesn't support lease expiry enforcement. Only revoked leases will be swept")
+	}
+
+	return s, nil
+}
+
+// Start runs sweeps in the background until the sweeper is closed.
+func (s *Sweeper) Start() {
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		log.Info().Dur("interval", s.interval).Bool("dry_run", s.dryRun).Msg("Starting lease sweeper")
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		ctx := context.Background()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Sweep(ctx); err != nil {
+					log.Err(err).Msg("Lease sweep failed")
+				}
+			case <-s.stop:
+				log.Info().Msg("Shutting down lease sweeper")
+				return
+			}
+		}
+	}()
+}
+
+func (s *Sweeper) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		s.wg.Wait()
+		s.stop = nil
+	}
+	return nil
+}
+
+// Metrics returns a snapshot of the sweeper's statistics.
+func (s *Sweeper) Metrics() Metrics {
+	s.metricsMtx.RLock()
+	defer s.metricsMtx.RUnlock()
+
+	return s.metrics
+}
+
+// Sweep runs one sweep. It expires leases that passed their expiry time, finds lease
+// revocations in new ledger blocks and purges data assets and operations that
+// are no longer referenced by any live lease. In dry run mode, the sweeper doesn't
+// modify the ledger or vaults and only reports what it would purge. Note that the data
+// assets of expired leases are only released after the leases are marked as expired
+// on the ledger, so dry run reports don't include them.
+func (s *Sweeper) Sweep(ctx context.Context) (*Report, error) {
+	defer measure.ExecTime("sweeper.Sweep")()
+
+	s.sweepMtx.Lock()
+	defer s.sweepMtx.Unlock()
+
+	startTime := s.nowFn()
+
+	report := &Report{
+		DryRun: s.dryRun,
+	}
+
+	err := s.loadCursor(ctx)
+	if err == nil {
+		err = s.sweepExpiredLeases(ctx, startTime, report)
+	}
+	if err == nil {
+		err = s.sweepRevokedLeases(ctx, report)
+	}
+	if err != nil {
+		report.Errors++
+	}
+
+	s.metricsMtx.Lock()
+	s.metrics.Runs++
+	s.metrics.LastRunAt = startTime
+	s.metrics.LastRunDurationMs = s.nowFn().Sub(startTime).Milliseconds()
+	s.metrics.LastBlock = s.lastBlock
+	s.metrics.ExpiredLeases += uint64(len(report.ExpiredLeases))
+	s.metrics.RevokedLeases += uint64(len(report.RevokedLeases))
+	s.metrics.PurgedBlobs += uint64(len(report.PurgedBlobs))
+	s.metrics.PurgedOperations += uint64(len(report.PurgedOperations))
+	s.metrics.Errors += uint64(report.Errors)
+	s.metricsMtx.Unlock()
+
+	log.Info().Bool("dry_run", s.dryRun).Int("expired", len(report.ExpiredLeases)).
+		Int("revoked", len(report.RevokedLeases)).Int("blobs", len(report.PurgedBlobs)).
+		Int("operations", len(report.PurgedOperations)).Int("errors", report.Errors).
+		Int64("last_block", s.lastBlock).Msg("Lease sweep completed")
+
+	return report, err
+}
+
+func (s *Sweeper) sweepExpiredLeases(ctx context.Context, now time.Time, report *Report) error {
+	enforcer, ok := s.ledger.(model.LeaseExpiryEnforcer)
+	if !ok {
+		return nil
+	}
+
+	ids, err := enforcer.GetExpiredRecords(ctx, now, s.batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, rid := range ids {
+		rec, err := s.ledger.GetRecord(ctx, rid)
+		if err != nil {
+			log.Err(err).Str("rid", rid).Msg("Failed to read expired lease record")
+			report.Errors++
+			continue
+		}
+
+		if s.dryRun {
+			if rec.Status == model.StatusPublished {
+				report.ExpiredLeases = append(report.ExpiredLeases, rid)
+			}
+			continue
+		}
+
+		expired, err := enforcer.ExpireRecord(ctx, rid, now)
+		if err != nil {
+			log.Err(err).Str("rid", rid).Msg("Failed to expire lease record")
+			report.Errors++
+			continue
+		}
+		if !expired {
+			continue
+		}
+
+		report.ExpiredLeases = append(report.ExpiredLeases, rid)
+
+		s.purgeRecordData(ctx, rec, report)
+	}
+
+	return nil
+}
+
+// loadCursor restores the last processed block from the ledger, if the ledger supports cursors.
+func (s *Sweeper) loadCursor(ctx context.Context) error {
+	if s.cursorLoaded {
+		return nil
+	}
+
+	if cs, ok := s.ledger.(model.LedgerCursorStore); ok {
+		lastBlock, err := cs.GetCursor(ctx, cursorName)
+		if err != nil {
+			return err
+		}
+		if lastBlock > s.lastBlock {
+			log.Info().Int64("last_block", lastBlock).Msg("Resuming lease sweeper")
+			s.lastBlock = lastBlock
+		}
+	}
+
+	s.cursorLoaded = true
+
+	return nil
+}
+
+// saveCursor persists the last processed block. In dry run mode, the cursor is
+// kept in memory only, so that a real sweep processes all the blocks again.
+func (s *Sweeper) saveCursor(ctx context.Context) error {
+	if s.dryRun {
+		return nil
+	}
+	if cs, ok := s.ledger.(model.LedgerCursorStore); ok {
+		return cs.SetCursor(ctx, cursorName, s.lastBlock)
+	}
+	return nil
+}
+
+func (s *Sweeper) sweepRevokedLeases(ctx context.Context, report *Report) (err error) {
+	topBlock, err := s.ledger.GetTopBlock(ctx)
+	if err != nil {
+		return err
+	}
+	if topBlock == nil {
+		return nil
+	}
+
+	startBlock := s.lastBlock
+	defer func() {
+		if s.lastBlock != startBlock {
+			if cursorErr := s.saveCursor(ctx); cursorErr != nil && err == nil {
+				err = cursorErr
+			}
+		}
+	}()
+
+	for bn := s.lastBlock + 1; bn <= topBlock.Number; bn++ {
+		recs, err := s.ledger.GetBlockRecords(ctx, bn)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range recs {
+			rec, err := s.ledger.GetRecord(ctx, r[0])
+			if err != nil {
+				return err
+			}
+
+			if rec.Operation != model.OpTypeLeaseRevocation || rec.Status != model.StatusPublished {
+				continue
+			}
+
+			subj, err := s.ledger.GetRecord(ctx, rec.SubjectRecord)
+			if err != nil {
+				log.Err(err).Str("rid", rec.SubjectRecord).Msg("Failed to read revoked lease record")
+				report.Errors++
+				continue
+			}
+
+			report.RevokedLeases = append(report.RevokedLeases, subj.ID)
+
+			s.purgeRecordData(ctx, subj, report)
+		}
+
+		s.lastBlock = bn
+	}
+
+	return nil
+}
+
+func (s *Sweeper) purgeRecordData(ctx context.Context, rec *model.Record, report *Report) {
+	for _, id := range rec.DataAssets {
+		purge, err := s.canPurge(ctx, id)
+		if err != nil {
+			log.Err(err).Str("id", id).Msg("Failed to read data asset state")
+			report.Errors++
+			continue
+		}
+		if !purge {
+			continue
+		}
+
+		if s.dryRun {
+			report.PurgedBlobs = append(report.PurgedBlobs, id)
+			continue
+		}
+
+		purged := false
+		for _, v := range s.blobVaults {
+			if err = v.PurgeBlob(ctx, id, nil); err != nil {
+				if !errors.Is(err, model.ErrBlobNotFound) {
+					log.Err(err).Str("id", id).Str("vault", v.Name()).Msg("Failed to purge blob")
+					report.Errors++
+				}
+				continue
+			}
+			purged = true
+		}
+		if purged {
+			log.Debug().Str("id", id).Str("rid", rec.ID).Msg("Purged blob")
+			report.PurgedBlobs = append(report.PurgedBlobs, id)
+		}
+	}
+
+	if rec.OperationAddress == "" || s.offChainVault == nil {
+		return
+	}
+
+	purge, err := s.canPurge(ctx, rec.OperationAddress)
+	if err != nil {
+		log.Err(err).Str("op_addr", rec.OperationAddress).Msg("Failed to read operation state")
+		report.Errors++
+		return
+	}
+	if !purge {
+		return
+	}
+
+	if !s.dryRun {
+		if err = s.offChainVault.PurgeBlob(ctx, rec.OperationAddress, nil); err != nil {
+			if !errors.Is(err, model.ErrBlobNotFound) {
+				log.Err(err).Str("op_addr", rec.OperationAddress).Msg("Failed to purge operation")
+				report.Errors++
+			}
+			return
+		}
+		log.Debug().Str("op_addr", rec.OperationAddress).Str("rid", rec.ID).Msg("Purged operation")
+	}
+
+	report.PurgedOperations = append(report.PurgedOperations, rec.OperationAddress)
+}
+
+func (s *Sweeper) canPurge(ctx context.Context, id string) (bool, error) {
+	state, err := s.ledger.GetDataAssetState(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return state == model.DataAssetStateRemove, nil
+}
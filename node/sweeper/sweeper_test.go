@@ -0,0 +1,217 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sweeper_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	. "github.com/piprate/metalocker/node/sweeper"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweeper_Sweep(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw := env.CreateTestManagedAccount(t)
+	idy, err := dw.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+	locker, err := idy.NewLocker(ctx, "Test Locker")
+	require.NoError(t, err)
+
+	submit := func(leaseDuration string, body string) dataset.RecordFuture {
+		lb, err := locker.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+		require.NoError(t, err)
+		_, err = lb.AddMetaResource(map[string]any{
+			"type": "TestDataset",
+			"body": body,
+		})
+		require.NoError(t, err)
+		_, err = lb.AddResource(strings.NewReader(body))
+		require.NoError(t, err)
+
+		f := lb.Submit(expiry.FromNow(leaseDuration))
+		require.NoError(t, f.Wait(time.Second*10))
+		return f
+	}
+
+	expiringFuture := submit("1h", "expiring dataset")
+	revokedFuture := submit("never", "revoked dataset")
+	liveFuture := submit("10y", "live dataset")
+
+	rootIndex, err := dw.CreateRootIndex(ctx, testbase.IndexStoreName)
+	require.NoError(t, err)
+	updater, err := dw.IndexUpdater(ctx, rootIndex)
+	require.NoError(t, err)
+	defer updater.Close()
+	require.NoError(t, updater.Sync(ctx))
+
+	rf := dw.DataStore().Revoke(ctx, revokedFuture.ID())
+	require.NoError(t, rf.Wait(time.Second*10))
+
+	expiringRecord, err := env.Ledger.GetRecord(ctx, expiringFuture.ID())
+	require.NoError(t, err)
+	assert.NotZero(t, expiringRecord.ExpiresAt)
+
+	revokedRecord, err := env.Ledger.GetRecord(ctx, revokedFuture.ID())
+	require.NoError(t, err)
+
+	now := time.Now().Add(2 * time.Hour)
+	clock := func() time.Time { return now }
+
+	// dry run
+
+	dryRunSweeper, err := New(&Config{DryRun: true}, env.Ledger, env.OffChainVault, env.BlobManager.Vaults(), WithClock(clock))
+	require.NoError(t, err)
+
+	report, err := dryRunSweeper.Sweep(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{expiringFuture.ID()}, report.ExpiredLeases)
+	assert.Equal(t, []string{revokedFuture.ID()}, report.RevokedLeases)
+	assert.ElementsMatch(t, revokedRecord.DataAssets, report.PurgedBlobs)
+	assert.Equal(t, []string{revokedRecord.OperationAddress}, report.PurgedOperations)
+
+	rs, err := env.Ledger.GetRecordState(ctx, expiringFuture.ID())
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPublished, rs.Status)
+
+	_, err = env.OffChainStorage.GetOperation(ctx, revokedRecord.OperationAddress)
+	require.NoError(t, err)
+
+	// real sweep
+
+	sw, err := New(&Config{}, env.Ledger, env.OffChainVault, env.BlobManager.Vaults(), WithClock(clock))
+	require.NoError(t, err)
+
+	report, err = sw.Sweep(ctx)
+	require.NoError(t, err)
+	assert.False(t, report.DryRun)
+	assert.Equal(t, 0, report.Errors)
+	assert.Equal(t, []string{expiringFuture.ID()}, report.ExpiredLeases)
+	assert.Equal(t, []string{revokedFuture.ID()}, report.RevokedLeases)
+	assert.ElementsMatch(t, append(expiringRecord.DataAssets, revokedRecord.DataAssets...), report.PurgedBlobs)
+	assert.ElementsMatch(t, []string{expiringRecord.OperationAddress, revokedRecord.OperationAddress}, report.PurgedOperations)
+
+	rs, err = env.Ledger.GetRecordState(ctx, expiringFuture.ID())
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusExpired, rs.Status)
+
+	_, err = env.OffChainStorage.GetOperation(ctx, expiringRecord.OperationAddress)
+	assert.ErrorIs(t, err, model.ErrOperationNotFound)
+
+	// the live dataset is intact
+
+	ds, err := dw.DataStore().Load(ctx, liveFuture.ID())
+	require.NoError(t, err)
+	var meta map[string]any
+	require.NoError(t, ds.DecodeMetaResource(ctx, &meta))
+	assert.Equal(t, "live dataset", meta["body"])
+
+	// next sweep has nothing to do
+
+	report, err = sw.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.ExpiredLeases)
+	assert.Empty(t, report.RevokedLeases)
+
+	metrics := sw.Metrics()
+	assert.Equal(t, uint64(2), metrics.Runs)
+	assert.Equal(t, uint64(1), metrics.ExpiredLeases)
+	assert.Equal(t, uint64(1), metrics.RevokedLeases)
+	assert.Equal(t, uint64(4), metrics.PurgedBlobs)
+	assert.Equal(t, uint64(2), metrics.PurgedOperations)
+	assert.Equal(t, uint64(0), metrics.Errors)
+}
+
+func TestSweeper_Resume(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw := env.CreateTestManagedAccount(t)
+	idy, err := dw.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+	locker, err := idy.NewLocker(ctx, "Test Locker")
+	require.NoError(t, err)
+
+	rootIndex, err := dw.CreateRootIndex(ctx, testbase.IndexStoreName)
+	require.NoError(t, err)
+	updater, err := dw.IndexUpdater(ctx, rootIndex)
+	require.NoError(t, err)
+	defer updater.Close()
+
+	submitAndRevoke := func(body string) string {
+		lb, err := locker.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+		require.NoError(t, err)
+		_, err = lb.AddMetaResource(map[string]any{
+			"type": "TestDataset",
+			"body": body,
+		})
+		require.NoError(t, err)
+
+		f := lb.Submit(expiry.FromNow("never"))
+		require.NoError(t, f.Wait(time.Second*10))
+		require.NoError(t, updater.Sync(ctx))
+
+		rf := dw.DataStore().Revoke(ctx, f.ID())
+		require.NoError(t, rf.Wait(time.Second*10))
+
+		return f.ID()
+	}
+
+	firstID := submitAndRevoke("first dataset")
+
+	sw, err := New(&Config{}, env.Ledger, env.OffChainVault, env.BlobManager.Vaults())
+	require.NoError(t, err)
+	report, err := sw.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{firstID}, report.RevokedLeases)
+
+	topBlock, err := env.Ledger.GetTopBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, topBlock.Number, sw.Metrics().LastBlock)
+
+	// a restarted sweeper doesn't rescan the processed blocks
+
+	secondID := submitAndRevoke("second dataset")
+
+	sw, err = New(&Config{}, env.Ledger, env.OffChainVault, env.BlobManager.Vaults())
+	require.NoError(t, err)
+	report, err = sw.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{secondID}, report.RevokedLeases)
+	assert.Equal(t, 0, report.Errors)
+
+	sw, err = New(&Config{}, env.Ledger, env.OffChainVault, env.BlobManager.Vaults())
+	require.NoError(t, err)
+	report, err = sw.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.RevokedLeases)
+
+	topBlock, err = env.Ledger.GetTopBlock(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, topBlock.Number, sw.Metrics().LastBlock)
+}
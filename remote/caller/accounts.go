@@ -229,8 +229,15 @@ func (c *MetaLockerHTTPCaller) GetAccountRecoveryCode(ctx context.Context, usern
 }
 
 func (c *MetaLockerHTTPCaller) RecoverAccount(ctx context.Context, userID string, privKey ed25519.PrivateKey, recoveryCode, newPassphrase string) (*account.Account, error) {
+	return c.RecoverAccountWithSecondFactor(ctx, userID, privKey, recoveryCode, newPassphrase, "")
+}
+
+// RecoverAccountWithSecondFactor recovers the account. If the account has MFA enabled,
+// secondFactorCode should contain a one-time code or a backup code.
+func (c *MetaLockerHTTPCaller) RecoverAccountWithSecondFactor(ctx context.Context, userID string, privKey ed25519.PrivateKey, recoveryCode, newPassphrase, secondFactorCode string) (*account.Account, error) {
 
 	req := account.BuildRecoveryRequest(userID, recoveryCode, privKey, newPassphrase, nil)
+	req.OTP = secondFactorCode
 
 	res, err := c.client.SendRequest(ctx, http.MethodPost, "/v1/recover-account",
 		httpsecure.WithJSONBody(req),
@@ -250,6 +257,9 @@ func (c *MetaLockerHTTPCaller) RecoverAccount(ctx context.Context, userID string
 		return rsp.Account, nil
 	case http.StatusUnauthorized:
 		msg := apibase.ParseResponseMessage(res)
+		if msg == account.ErrSecondFactorRequired.Error() {
+			return nil, account.ErrSecondFactorRequired
+		}
 		return nil, errors.New(msg)
 	default:
 		return nil, fmt.Errorf("account recovery failed with status code %d", res.StatusCode)
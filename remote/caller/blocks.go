@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/piprate/metalocker/ledger"
 	"github.com/piprate/metalocker/model"
 )
 
@@ -73,3 +74,14 @@ func (c *MetaLockerHTTPCaller) GetBlockRecords(ctx context.Context, bn int64) ([
 		return csv.NewReader(bytes.NewReader(recBytes)).ReadAll()
 	}
 }
+
+// VerifyLedger requests the server to verify the integrity of its ledger.
+func (c *MetaLockerHTTPCaller) VerifyLedger(ctx context.Context) (*ledger.VerificationReport, error) {
+	var report ledger.VerificationReport
+	err := c.client.LoadContents(ctx, http.MethodGet, "/v1/ledger/verify", nil, &report)
+	if err != nil {
+		return nil, err
+	} else {
+		return &report, nil
+	}
+}
@@ -54,6 +54,7 @@ type MetaLockerHTTPCaller struct {
 // check all interfaces the Caller should provide
 
 var _ model.Ledger = (*MetaLockerHTTPCaller)(nil)
+var _ model.RecordInclusionProver = (*MetaLockerHTTPCaller)(nil)
 var _ model.OffChainStorage = (*MetaLockerHTTPCaller)(nil)
 var _ model.BlobManager = (*MetaLockerHTTPCaller)(nil)
 var _ wallet.NodeClient = (*MetaLockerHTTPCaller)(nil)
@@ -168,12 +169,20 @@ func (c *MetaLockerHTTPCaller) LoginWithJWT(jwtToken string) error {
 }
 
 func (c *MetaLockerHTTPCaller) LoginWithCredentials(ctx context.Context, email string, password string) error {
+	return c.LoginWithSecondFactor(ctx, email, password, "")
+}
+
+// LoginWithSecondFactor logs in using account credentials and a one-time code from one of
+// the account's second factors (or a backup code). If the account has MFA enabled and
+// the code is empty, it returns account.ErrSecondFactorRequired.
+func (c *MetaLockerHTTPCaller) LoginWithSecondFactor(ctx context.Context, email, password, code string) error {
 	// logout before trying to log in
 	c.Logout()
 
 	loginForm := LoginForm{
 		Username: email,
 		Password: account.HashUserPassword(password),
+		OTP:      code,
 	}
 
 	res, err := c.client.SendRequest(ctx, http.MethodPost, "/v1/authenticate",
@@ -186,6 +195,9 @@ func (c *MetaLockerHTTPCaller) LoginWithCredentials(ctx context.Context, email s
 
 	if res.StatusCode != http.StatusOK {
 		if res.StatusCode == http.StatusUnauthorized {
+			if apibase.ParseResponseMessage(res) == account.ErrSecondFactorRequired.Error() {
+				return account.ErrSecondFactorRequired
+			}
 			return ErrLoginFailed
 		} else {
 			return fmt.Errorf("bad response status code: %d", res.StatusCode)
@@ -0,0 +1,148 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/httpsecure"
+)
+
+// ListDataSets returns a page of data sets from the hosted root index of the current account.
+func (c *MetaLockerHTTPCaller) ListDataSets(ctx context.Context, lockerFilter, participantFilter string, opts index.PageOptions) (*index.RecordPage, error) {
+	if !c.client.IsAuthenticated() {
+		return nil, errors.New("you need to log in before performing any operations")
+	}
+
+	q := pageQuery(opts)
+	setIfNotEmpty(q, "locker", lockerFilter)
+	setIfNotEmpty(q, "participant", participantFilter)
+
+	var page index.RecordPage
+	if err := c.client.LoadContents(ctx, http.MethodGet, withQuery("/v1/dataset", q), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// GetDataSetState returns the state of the given data set from the hosted root index
+// of the current account.
+func (c *MetaLockerHTTPCaller) GetDataSetState(ctx context.Context, id string) (*index.RecordState, error) {
+	if !c.client.IsAuthenticated() {
+		return nil, errors.New("you need to log in before performing any operations")
+	}
+
+	var rs index.RecordState
+	err := c.client.LoadContents(ctx, http.MethodGet, "/v1/dataset/"+url.PathEscape(id), nil, &rs)
+	if err != nil {
+		if errors.Is(err, httpsecure.ErrEntityNotFound) {
+			return nil, model.ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	return &rs, nil
+}
+
+// ListVariants returns a page of variants from the hosted root index of the current account.
+func (c *MetaLockerHTTPCaller) ListVariants(ctx context.Context, lockerFilter, participantFilter string, includeHistory bool, opts index.PageOptions) (*index.VariantPage, error) {
+	if !c.client.IsAuthenticated() {
+		return nil, errors.New("you need to log in before performing any operations")
+	}
+
+	q := pageQuery(opts)
+	setIfNotEmpty(q, "locker", lockerFilter)
+	setIfNotEmpty(q, "participant", participantFilter)
+	if includeHistory {
+		q.Set("history", "true")
+	}
+
+	var page index.VariantPage
+	if err := c.client.LoadContents(ctx, http.MethodGet, withQuery("/v1/variant", q), nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// GetVariant returns the master record (and, optionally, the history) of the given variant
+// from the hosted root index of the current account.
+func (c *MetaLockerHTTPCaller) GetVariant(ctx context.Context, variantID string, includeHistory bool) (*index.VariantPageEntry, error) {
+	if !c.client.IsAuthenticated() {
+		return nil, errors.New("you need to log in before performing any operations")
+	}
+
+	q := url.Values{}
+	if includeHistory {
+		q.Set("history", "true")
+	}
+
+	var entry index.VariantPageEntry
+	err := c.client.LoadContents(ctx, http.MethodGet, withQuery("/v1/variant/"+url.PathEscape(variantID), q), nil, &entry)
+	if err != nil {
+		if errors.Is(err, httpsecure.ErrEntityNotFound) {
+			return nil, index.ErrVariantNotFound
+		}
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// ListAssetDataSets returns a page of data sets that contain the given asset, from the hosted
+// root index of the current account.
+func (c *MetaLockerHTTPCaller) ListAssetDataSets(ctx context.Context, assetID string, opts index.PageOptions) (*index.AssetRecordPage, error) {
+	if !c.client.IsAuthenticated() {
+		return nil, errors.New("you need to log in before performing any operations")
+	}
+
+	var page index.AssetRecordPage
+	u := withQuery("/v1/data-asset/"+url.PathEscape(assetID)+"/dataset", pageQuery(opts))
+	if err := c.client.LoadContents(ctx, http.MethodGet, u, nil, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func pageQuery(opts index.PageOptions) url.Values {
+	q := url.Values{}
+	setIfNotEmpty(q, "cursor", opts.Cursor)
+	setIfNotEmpty(q, "order", string(opts.Order))
+	if opts.PageSize > 0 {
+		q.Set("pageSize", strconv.Itoa(opts.PageSize))
+	}
+	return q
+}
+
+func setIfNotEmpty(q url.Values, key, val string) {
+	if val != "" {
+		q.Set(key, val)
+	}
+}
+
+func withQuery(path string, q url.Values) string {
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/sdk/httpsecure"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+func (c *MetaLockerHTTPCaller) ListSecondFactors(ctx context.Context) ([]*account.SecondFactor, error) {
+	if !c.client.IsAuthenticated() {
+		return nil, errors.New("you need to log in before performing any operations")
+	}
+
+	var factorList []*account.SecondFactor
+	url := "/v1/account/" + c.currentAccountID + "/mfa"
+	err := c.client.LoadContents(ctx, http.MethodGet, url, nil, &factorList)
+	if err != nil {
+		return nil, err
+	}
+
+	return factorList, nil
+}
+
+// EnrolSecondFactor starts TOTP factor enrolment. The returned secret (or URI) needs
+// to be added to an authenticator app and confirmed using ConfirmSecondFactor.
+func (c *MetaLockerHTTPCaller) EnrolSecondFactor(ctx context.Context, name string) (*SecondFactorEnrolmentResponse, error) {
+	if !c.client.IsAuthenticated() {
+		return nil, errors.New("you need to log in before performing any operations")
+	}
+
+	req := &SecondFactorEnrolmentRequest{
+		Type: account.SecondFactorTOTP,
+		Name: name,
+	}
+
+	url := "/v1/account/" + c.currentAccountID + "/mfa"
+	res, err := c.client.SendRequest(ctx, http.MethodPost, url, httpsecure.WithJSONBody(req))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	switch res.StatusCode {
+	case http.StatusCreated:
+		var rsp SecondFactorEnrolmentResponse
+		if err := jsonw.Decode(res.Body, &rsp); err != nil {
+			return nil, err
+		}
+
+		return &rsp, nil
+	case http.StatusUnauthorized:
+		return nil, ErrNotAuthorised
+	default:
+		return nil, fmt.Errorf("second factor enrolment failed with status code %d", res.StatusCode)
+	}
+}
+
+// ConfirmSecondFactor completes second factor enrolment. If this is the first factor
+// for the account, it returns a set of backup codes.
+func (c *MetaLockerHTTPCaller) ConfirmSecondFactor(ctx context.Context, factorID, code string) ([]string, error) {
+	url := "/v1/account/" + c.currentAccountID + "/mfa/" + factorID + "/confirm"
+	return c.requestBackupCodes(ctx, url, code, "second factor confirmation")
+}
+
+// RegenerateBackupCodes replaces account's backup codes with a new set.
+func (c *MetaLockerHTTPCaller) RegenerateBackupCodes(ctx context.Context, code string) ([]string, error) {
+	url := "/v1/account/" + c.currentAccountID + "/mfa/backup-codes"
+	return c.requestBackupCodes(ctx, url, code, "backup code generation")
+}
+
+func (c *MetaLockerHTTPCaller) DeleteSecondFactor(ctx context.Context, factorID, code string) error {
+	if !c.client.IsAuthenticated() {
+		return errors.New("you need to log in before performing any operations")
+	}
+
+	path := "/v1/account/" + c.currentAccountID + "/mfa/" + factorID
+	if code != "" {
+		path += "?otp=" + url.QueryEscape(code)
+	}
+	res, err := c.client.SendRequest(ctx, http.MethodDelete, path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	switch res.StatusCode {
+	case http.StatusNoContent:
+	// do nothing
+	case http.StatusUnauthorized:
+		return secondFactorError(res)
+	default:
+		return fmt.Errorf("second factor deletion failed with status code %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *MetaLockerHTTPCaller) requestBackupCodes(ctx context.Context, url, code, operation string) ([]string, error) {
+	if !c.client.IsAuthenticated() {
+		return nil, errors.New("you need to log in before performing any operations")
+	}
+
+	res, err := c.client.SendRequest(ctx, http.MethodPost, url,
+		httpsecure.WithJSONBody(&SecondFactorCodeRequest{Code: code}))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		var rsp BackupCodesResponse
+		if err := jsonw.Decode(res.Body, &rsp); err != nil {
+			return nil, err
+		}
+
+		return rsp.Codes, nil
+	case http.StatusUnauthorized:
+		return nil, secondFactorError(res)
+	default:
+		return nil, fmt.Errorf("%s failed with status code %d", operation, res.StatusCode)
+	}
+}
+
+// secondFactorError converts an 'Unauthorized' response into a second factor error, if applicable.
+func secondFactorError(res *http.Response) error {
+	switch apibase.ParseResponseMessage(res) {
+	case account.ErrSecondFactorRequired.Error():
+		return account.ErrSecondFactorRequired
+	case "second factor verification failed":
+		return account.ErrInvalidSecondFactor
+	default:
+		return ErrNotAuthorised
+	}
+}
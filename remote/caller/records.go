@@ -114,3 +114,16 @@ func (c *MetaLockerHTTPCaller) GetAssetHead(ctx context.Context, headID string)
 		return &lr, nil
 	}
 }
+
+func (c *MetaLockerHTTPCaller) GetRecordInclusionProof(ctx context.Context, rid string) (*model.RecordInclusionProof, error) {
+	var proof model.RecordInclusionProof
+	err := c.client.LoadContents(ctx, http.MethodGet, fmt.Sprintf("/v1/lrec/%s/proof", rid), nil, &proof)
+	if err != nil {
+		if errors.Is(err, httpsecure.ErrEntityNotFound) {
+			return nil, model.ErrRecordNotFound
+		}
+		return nil, err
+	} else {
+		return &proof, nil
+	}
+}
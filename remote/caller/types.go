@@ -22,6 +22,7 @@ type (
 		Password          string `json:"password"`
 		Audience          string `json:"audience"`
 		AudiencePublicKey string `json:"audienceKey"`
+		OTP               string `json:"otp,omitempty"`
 	}
 
 	AccountPatch struct {
@@ -41,6 +42,25 @@ type (
 		Account *account.Account `json:"account"`
 	}
 
+	SecondFactorEnrolmentRequest struct {
+		Type string `json:"type"`
+		Name string `json:"name,omitempty"`
+	}
+
+	SecondFactorEnrolmentResponse struct {
+		Factor *account.SecondFactor `json:"factor"`
+		Secret string                `json:"secret"`
+		URI    string                `json:"uri"`
+	}
+
+	SecondFactorCodeRequest struct {
+		Code string `json:"code"`
+	}
+
+	BackupCodesResponse struct {
+		Codes []string `json:"codes,omitempty"`
+	}
+
 	ServerControls struct {
 		Status           string `json:"status"`
 		MaintenanceMode  bool   `json:"maintenanceMode"`
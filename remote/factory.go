@@ -208,6 +208,24 @@ func (rf *Factory) GetWalletWithCredentials(ctx context.Context, userID, secret
 	return dw, nil
 }
 
+// GetWalletWithSecondFactor returns a data wallet for an account with multi-factor
+// authentication enabled. The code can be a one-time code or a backup code.
+func (rf *Factory) GetWalletWithSecondFactor(ctx context.Context, userID, secret, code string) (wallet.DataWallet, error) {
+	dw, err := rf.loadRemoteWallet(ctx, func(mlc *caller.MetaLockerHTTPCaller) error {
+		return mlc.LoginWithSecondFactor(ctx, userID, secret, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = dw.Unlock(ctx, secret); err != nil {
+		_ = dw.Close()
+		return nil, err
+	}
+
+	return dw, nil
+}
+
 func (rf *Factory) GetWalletWithTokenAndKey(ctx context.Context, jwtToken string, managedKey *model.AESKey) (wallet.DataWallet, error) {
 	w, err := rf.loadRemoteWallet(ctx, func(mlc *caller.MetaLockerHTTPCaller) error {
 		return mlc.LoginWithJWT(jwtToken)
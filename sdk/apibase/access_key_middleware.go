@@ -53,6 +53,12 @@ func AccessKeyMiddleware(accessKeyStorage AccessKeyPersister, next gin.HandlerFu
 				return
 			}
 
+			if key.IsExpired(time.Now()) {
+				log.Warn().Str("key", keyID).Msg("Access key expired")
+				c.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+
 			var bodyHash []byte
 			if c.Request.Header.Get(model.AccessKeyHeaderBodyHash) != "" {
 				bodyBytes, err := io.ReadAll(req.Body)
@@ -88,7 +94,14 @@ func AccessKeyMiddleware(accessKeyStorage AccessKeyPersister, next gin.HandlerFu
 				return
 			}
 
+			if !key.AllowsRequest(req.Method, req.URL.Path) {
+				log.Warn().Str("key", keyID).Str("url", url).Msg("Operation not permitted with read-only access key")
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+
 			c.Set(UserIDKey, key.AccountID)
+			c.Set(AccessKeyKey, restrictionsOnly(key))
 			c.Next()
 		} else if errors.Is(err, model.ErrAuthorizationNotFound) {
 			next(c)
@@ -109,7 +122,25 @@ type SignatureValidationRequest struct {
 }
 
 type SignatureValidationResponse struct {
-	Account string `json:"acct"`
+	Account    string                    `json:"acct"`
+	ExpiresAt  *time.Time                `json:"expires,omitempty"`
+	Permission model.AccessKeyPermission `json:"permission,omitempty"`
+	Lockers    []string                  `json:"lockers,omitempty"`
+	Identities []string                  `json:"identities,omitempty"`
+}
+
+// restrictionsOnly returns a copy of the access key without any secrets.
+func restrictionsOnly(key *model.AccessKey) *model.AccessKey {
+	return &model.AccessKey{
+		ID:          key.ID,
+		AccountID:   key.AccountID,
+		AccessLevel: key.AccessLevel,
+		Type:        key.Type,
+		ExpiresAt:   key.ExpiresAt,
+		Permission:  key.Permission,
+		Lockers:     key.Lockers,
+		Identities:  key.Identities,
+	}
 }
 
 // DelegatedAccessKeyMiddleware call an external signature validation service to confirm
@@ -187,7 +218,30 @@ func DelegatedAccessKeyMiddleware(validatorURL string, next gin.HandlerFunc) (gi
 					return
 				}
 
+				key := &model.AccessKey{
+					ID:         keyID,
+					AccountID:  rspStruct.Account,
+					Type:       model.AccessKeyType,
+					ExpiresAt:  rspStruct.ExpiresAt,
+					Permission: rspStruct.Permission,
+					Lockers:    rspStruct.Lockers,
+					Identities: rspStruct.Identities,
+				}
+
+				if key.IsExpired(time.Now()) {
+					log.Warn().Str("key", keyID).Msg("Access key expired")
+					c.AbortWithStatus(http.StatusUnauthorized)
+					return
+				}
+
+				if !key.AllowsRequest(req.Method, req.URL.Path) {
+					log.Warn().Str("key", keyID).Str("url", reqBody.URL).Msg("Operation not permitted with read-only access key")
+					c.AbortWithStatus(http.StatusForbidden)
+					return
+				}
+
 				c.Set(UserIDKey, rspStruct.Account)
+				c.Set(AccessKeyKey, key)
 				c.Next()
 			case http.StatusUnauthorized:
 				log.Warn().Str("url", reqBody.URL).Msg("Invalid request signature")
@@ -15,6 +15,7 @@
 package apibase
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
@@ -39,6 +40,10 @@ const (
 	ClaimAudienceKeyID           = "akid"
 	ClaimEncryptedAudienceSecret = "eas"
 	ClaimLegacySecret            = "secret"
+
+	// maxSecondFactorAttempts limits the number of times CheckSecondFactor
+	// retries the verification when the factor is updated concurrently.
+	maxSecondFactorAttempts = 3
 )
 
 type AccountBackend interface {
@@ -53,6 +58,9 @@ type LoginForm struct {
 	Password          string `form:"password" json:"password" binding:"required"`
 	Audience          string `form:"audience" json:"audience"`
 	AudiencePublicKey string `form:"audienceKey" json:"audienceKey"`
+	// OTP is a one-time code from one of the account's second factors
+	// or a backup code. It's required if the account has MFA enabled.
+	OTP string `form:"otp" json:"otp,omitempty"`
 }
 
 func (lf LoginForm) Bytes() []byte {
@@ -60,12 +68,77 @@ func (lf LoginForm) Bytes() []byte {
 	return b
 }
 
-func AuthenticationHandler(accountBackend storage.IdentityBackend, acceptedAudiences []string, defaultAudience, defaultAudienceKey string) func(c *gin.Context) (any, error) {
+var (
+	ErrAudienceNotAccepted = errors.New("audience not accepted")
+	ErrMissingAudienceKey  = errors.New("missing audience key")
+	ErrInvalidAudienceKey  = errors.New("invalid audience key")
+)
+
+// AudiencePolicy defines which token audiences are accepted by the node and how
+// the account's managed key is passed to them.
+type AudiencePolicy struct {
+	acceptedAudiences  map[string]bool
+	defaultAudience    string
+	defaultAudienceKey string
+}
+
+func NewAudiencePolicy(acceptedAudiences []string, defaultAudience, defaultAudienceKey string) *AudiencePolicy {
 	acceptedAudiencesFilter := make(map[string]bool, len(acceptedAudiences))
 	for _, aud := range acceptedAudiences {
 		acceptedAudiencesFilter[aud] = true
 	}
 
+	return &AudiencePolicy{
+		acceptedAudiences:  acceptedAudiencesFilter,
+		defaultAudience:    defaultAudience,
+		defaultAudienceKey: defaultAudienceKey,
+	}
+}
+
+// Claims returns JWT payload for the given account. If managedKey is not nil, it gets encrypted
+// with the audience public key and included into the payload.
+func (ap *AudiencePolicy) Claims(acct *account.Account, managedKey *model.AESKey, audience, audiencePublicKey string) (map[string]any, error) {
+	if audience != "" {
+		if _, found := ap.acceptedAudiences[audience]; !found {
+			return nil, ErrAudienceNotAccepted
+		}
+	} else if ap.defaultAudience != "" {
+		audience = ap.defaultAudience
+	}
+
+	response := map[string]any{
+		ClaimAccountID: acct.ID,
+		ClaimEmail:     acct.Email,
+		ClaimAudience:  audience,
+	}
+
+	if managedKey != nil {
+		if audiencePublicKey == "" {
+			if audience == ap.defaultAudience {
+				audiencePublicKey = ap.defaultAudienceKey
+			} else {
+				return nil, ErrMissingAudienceKey
+			}
+		}
+		if audiencePublicKey != "" {
+			pubKey, err := base64.StdEncoding.DecodeString(audiencePublicKey)
+			if err != nil || len(pubKey) != 32 {
+				return nil, ErrInvalidAudienceKey
+			}
+
+			response[ClaimEncryptedAudienceSecret] = base64.StdEncoding.EncodeToString(
+				model.AnonEncrypt(managedKey.Bytes(), pubKey),
+			)
+			response[ClaimAudienceKeyID] = GetKeyID(pubKey)
+		}
+	}
+
+	return response, nil
+}
+
+func AuthenticationHandler(accountBackend storage.IdentityBackend, acceptedAudiences []string, defaultAudience, defaultAudienceKey string) func(c *gin.Context) (any, error) {
+	audiencePolicy := NewAudiencePolicy(acceptedAudiences, defaultAudience, defaultAudienceKey)
+
 	return func(c *gin.Context) (any, error) {
 		var loginVals LoginForm
 		if bindErr := c.BindJSON(&loginVals); bindErr != nil {
@@ -80,82 +153,111 @@ func AuthenticationHandler(accountBackend storage.IdentityBackend, acceptedAudie
 			userID = strings.ToLower(userID)
 		}
 
+		throttle := GetThrottle(c)
+		if err := throttle.CheckAccount(c, userID); err != nil {
+			log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).Msg("Authentication throttled")
+			if errors.Is(err, ErrAccountLocked) {
+				// locked accounts get the same response as any other failed login
+				return userID, ErrFailedAuthentication
+			}
+			return userID, err
+		}
+
 		acct, err := accountBackend.GetAccount(c, userID)
 		if err != nil {
 			log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).Msg("Error when retrieving account for authentication")
+			if errors.Is(err, storage.ErrAccountNotFound) {
+				// count failures for unknown accounts too, to avoid revealing which accounts exist
+				throttle.RecordFailure(c, userID)
+			}
 			return userID, ErrFailedAuthentication
 		}
 
 		if acct.State == account.StateSuspended {
 			log.Error().Str("ip", c.ClientIP()).Str("userID", userID).Msg("Authentication failed: account suspended")
+			throttle.RecordFailure(c, userID)
 			return userID, ErrFailedAuthentication
 		}
 
 		if acct.State == account.StateDeleted {
 			log.Error().Str("ip", c.ClientIP()).Str("userID", userID).Msg("Authentication failed: account deleted")
+			throttle.RecordFailure(c, userID)
 			return userID, ErrFailedAuthentication
 		}
 
 		err = bcrypt.CompareHashAndPassword([]byte(acct.EncryptedPassword), []byte(password))
 		if err != nil {
 			log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).Msg("Authentication failed")
+			throttle.RecordFailure(c, userID)
 			return userID, ErrFailedAuthentication
 		}
 
-		log.Debug().Str("userID", userID).Msg("Authentication successful")
-
-		var audience string
-		if loginVals.Audience != "" {
-			if _, found := acceptedAudiencesFilter[loginVals.Audience]; !found {
-				log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).
-					Str("aud", loginVals.Audience).Msg("Audience not accepted")
-				return userID, ErrFailedAuthentication
+		if err = CheckSecondFactor(c, accountBackend, acct.ID, loginVals.OTP); err != nil {
+			log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).Msg("Second factor verification failed")
+			if errors.Is(err, account.ErrSecondFactorRequired) {
+				return userID, err
+			}
+			if errors.Is(err, account.ErrInvalidSecondFactor) {
+				throttle.RecordFailure(c, userID)
 			}
-			audience = loginVals.Audience
-		} else if defaultAudience != "" {
-			audience = defaultAudience
+			return userID, ErrFailedAuthentication
 		}
 
-		response := map[string]any{
-			ClaimAccountID: acct.ID,
-			ClaimEmail:     acct.Email,
-			ClaimAudience:  audience,
-		}
+		throttle.RecordSuccess(c, userID)
+
+		log.Debug().Str("userID", userID).Msg("Authentication successful")
 
+		var managedKey *model.AESKey
 		if acct.State != account.StateRecovery {
-			managedKey, err := acct.ExtractManagedKey(password)
+			managedKey, err = acct.ExtractManagedKey(password)
 			if err != nil {
 				log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).Msg("Failed to extract managed key")
 				return userID, ErrFailedAuthentication
 			}
+		}
 
-			audiencePublicKey := loginVals.AudiencePublicKey
-			if audiencePublicKey == "" {
-				if audience == defaultAudience {
-					audiencePublicKey = defaultAudienceKey
-				} else {
-					log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).Str("aud", audience).Msg("Missing audience key")
-					return userID, ErrFailedAuthentication
-				}
-			}
-			if audiencePublicKey != "" {
-				pubKey, err := base64.StdEncoding.DecodeString(audiencePublicKey)
-				if err != nil || len(pubKey) != 32 {
-					log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).Msg("Invalid audience key")
-					return userID, ErrFailedAuthentication
-				}
-
-				response[ClaimEncryptedAudienceSecret] = base64.StdEncoding.EncodeToString(
-					model.AnonEncrypt(managedKey.Bytes(), pubKey),
-				)
-				response[ClaimAudienceKeyID] = GetKeyID(pubKey)
-			}
+		response, err := audiencePolicy.Claims(acct, managedKey, loginVals.Audience, loginVals.AudiencePublicKey)
+		if err != nil {
+			log.Err(err).Str("ip", c.ClientIP()).Str("userID", userID).
+				Str("aud", loginVals.Audience).Msg("Token audience check failed")
+			return userID, ErrFailedAuthentication
 		}
 
 		return response, nil
 	}
 }
 
+// CheckSecondFactor verifies the given one-time code if the account has any second factors
+// enrolled. It returns account.ErrSecondFactorRequired if the code is needed, but missing.
+// The factor that accepted the code gets updated atomically to prevent the code from
+// being reused, including by concurrent requests.
+func CheckSecondFactor(ctx context.Context, accountBackend storage.AccountBackend, accountID, code string) error {
+	for i := 0; i < maxSecondFactorAttempts; i++ {
+		factors, err := accountBackend.ListSecondFactors(ctx, accountID)
+		if err != nil {
+			return err
+		}
+
+		if !account.IsMFAEnabled(factors) {
+			return nil
+		}
+
+		f, err := account.VerifySecondFactor(factors, code, time.Now())
+		if err != nil {
+			return err
+		}
+
+		// if the factor was updated concurrently, re-read it and check
+		// if the code is still valid. A replayed code will be rejected.
+		err = accountBackend.UpdateSecondFactor(ctx, f)
+		if !errors.Is(err, storage.ErrSecondFactorConflict) {
+			return err
+		}
+	}
+
+	return account.ErrInvalidSecondFactor
+}
+
 func Payload(data any) MapClaims {
 	return data.(map[string]any)
 }
@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -371,3 +372,121 @@ func checkResponseBody(t *testing.T, expectedBody string, actualBody []byte) {
 		t.FailNow()
 	}
 }
+
+func TestAuthenticationHandler_SecondFactor(t *testing.T) {
+	identityBackend, _ := memory.CreateIdentityBackend(nil, nil)
+
+	err := identityBackend.CreateAccount(context.Background(), testAccountV3)
+	require.NoError(t, err)
+
+	totp, err := account.NewTOTPFactor(testAccountV3.ID, "phone")
+	require.NoError(t, err)
+	totp.Confirmed = true
+	err = identityBackend.StoreSecondFactor(context.Background(), totp)
+	require.NoError(t, err)
+
+	backup, backupCodes, err := account.NewBackupCodes(testAccountV3.ID, 2)
+	require.NoError(t, err)
+	err = identityBackend.StoreSecondFactor(context.Background(), backup)
+	require.NoError(t, err)
+
+	login := func(otp string) *httptest.ResponseRecorder {
+		return invokeLoginHandler(t,
+			string(LoginForm{
+				Username: "test@example.com",
+				Password: "gm7FhMFxFD01wGd8dE1RqUAxx7noD8LvPQyBzK+27LA=",
+				OTP:      otp,
+			}.Bytes()),
+			AuthenticationHandler(identityBackend, nil, "", ""),
+		)
+	}
+
+	// code is missing
+
+	rec := login("")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	checkResponseBody(t, `{
+  "message": "second factor required"
+}`, rec.Body.Bytes())
+
+	// wrong code
+
+	rec = login("00000a")
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	checkResponseBody(t, `{
+  "message": "incorrect Username or Password"
+}`, rec.Body.Bytes())
+
+	// happy path
+
+	code, err := account.GenerateTOTP(totp.Secret, time.Now())
+	require.NoError(t, err)
+
+	rec = login(code)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// codes can't be replayed
+
+	rec = login(code)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// backup code
+
+	rec = login(backupCodes[0])
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = login(backupCodes[0])
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestCheckSecondFactor_ConcurrentReplay(t *testing.T) {
+	ctx := context.Background()
+
+	identityBackend, _ := memory.CreateIdentityBackend(nil, nil)
+
+	err := identityBackend.CreateAccount(ctx, testAccountV3)
+	require.NoError(t, err)
+
+	totp, err := account.NewTOTPFactor(testAccountV3.ID, "phone")
+	require.NoError(t, err)
+	totp.Confirmed = true
+	err = identityBackend.StoreSecondFactor(ctx, totp)
+	require.NoError(t, err)
+
+	backup, backupCodes, err := account.NewBackupCodes(testAccountV3.ID, 2)
+	require.NoError(t, err)
+	err = identityBackend.StoreSecondFactor(ctx, backup)
+	require.NoError(t, err)
+
+	code, err := account.GenerateTOTP(totp.Secret, time.Now())
+	require.NoError(t, err)
+
+	// each code should be accepted exactly once, even if submitted concurrently
+
+	for _, c := range []string{code, backupCodes[0]} {
+		var wg sync.WaitGroup
+		results := make([]error, 10)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = CheckSecondFactor(ctx, identityBackend, testAccountV3.ID, c)
+			}(i)
+		}
+		wg.Wait()
+
+		accepted := 0
+		for _, res := range results {
+			if res == nil {
+				accepted++
+			} else {
+				assert.ErrorIs(t, res, account.ErrInvalidSecondFactor)
+			}
+		}
+		assert.Equal(t, 1, accepted)
+	}
+
+	f, err := identityBackend.GetSecondFactor(ctx, backup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(f.BackupCodes))
+}
@@ -458,7 +458,11 @@ func (mw *GinJWTMiddleware) LoginHandler(c *gin.Context) {
 	data, err := mw.Authenticator(c)
 
 	if err != nil {
-		mw.unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(err, c))
+		code := http.StatusUnauthorized
+		if errors.Is(err, ErrTooManyRequests) {
+			code = http.StatusTooManyRequests
+		}
+		mw.unauthorized(c, code, mw.HTTPStatusMessageFunc(err, c))
 		return
 	}
 
@@ -0,0 +1,163 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltthrottle
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/sdk/cmdbase"
+	"github.com/piprate/metalocker/utils"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	Type = "bolt"
+
+	ParameterFilePath = "file_path"
+
+	CountersBucket = "counters"
+	LocksBucket    = "locks"
+)
+
+func init() {
+	apibase.RegisterThrottleStore(Type, CreateThrottleStore)
+}
+
+// ThrottleStore is a persistent implementation of apibase.ThrottleStore
+// backed by BoltDB. It preserves account lockouts across node restarts.
+type ThrottleStore struct {
+	client *utils.BoltClient
+}
+
+var _ apibase.ThrottleStore = (*ThrottleStore)(nil)
+
+func CreateThrottleStore(params map[string]any, resolver cmdbase.ParameterResolver) (apibase.ThrottleStore, error) {
+	storeFilePath, ok := params[ParameterFilePath].(string)
+	if !ok {
+		return nil, errors.New("parameter not found: " + ParameterFilePath +
+			". Can't start throttle store")
+	}
+
+	return NewThrottleStore(storeFilePath)
+}
+
+func NewThrottleStore(storeFilePath string) (*ThrottleStore, error) {
+	storeFilePath = utils.AbsPathify(storeFilePath)
+
+	// validate path and create missing directories, if required
+	storeDir := filepath.Dir(storeFilePath)
+	if _, err := os.Stat(storeDir); err != nil {
+		if os.IsNotExist(err) {
+			if err = os.MkdirAll(storeDir, 0o700); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	bc, err := utils.NewBoltClient(storeFilePath, InstallThrottleStoreSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ThrottleStore{
+		client: bc,
+	}, nil
+}
+
+func InstallThrottleStoreSchema(bc *utils.BoltClient) error {
+	return bc.DB.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range []string{CountersBucket, LocksBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// counter values are stored as 8 bytes of the expiry time (Unix nanoseconds)
+// followed by 8 bytes of the counter value.
+
+func (s *ThrottleStore) Increment(ctx context.Context, key string, now time.Time, ttl time.Duration) (int, error) {
+	var val int
+	err := s.client.DB.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(CountersBucket))
+
+		var expiresAt int64
+		if buf := b.Get([]byte(key)); len(buf) == 16 {
+			expiresAt = int64(binary.BigEndian.Uint64(buf[:8]))
+			val = int(binary.BigEndian.Uint64(buf[8:]))
+		}
+		if now.UnixNano() >= expiresAt {
+			expiresAt = now.Add(ttl).UnixNano()
+			val = 0
+		}
+		val++
+
+		buf := make([]byte, 16)
+		binary.BigEndian.PutUint64(buf[:8], uint64(expiresAt))
+		binary.BigEndian.PutUint64(buf[8:], uint64(val))
+
+		return b.Put([]byte(key), buf)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return val, nil
+}
+
+func (s *ThrottleStore) Reset(ctx context.Context, key string) error {
+	return s.client.DB.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(CountersBucket)).Delete([]byte(key))
+	})
+}
+
+func (s *ThrottleStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.client.DB.Update(func(tx *bbolt.Tx) error {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(until.UnixNano()))
+		return tx.Bucket([]byte(LocksBucket)).Put([]byte(key), buf)
+	})
+}
+
+func (s *ThrottleStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	var until time.Time
+	err := s.client.DB.View(func(tx *bbolt.Tx) error {
+		if buf := tx.Bucket([]byte(LocksBucket)).Get([]byte(key)); len(buf) == 8 {
+			until = time.Unix(0, int64(binary.BigEndian.Uint64(buf)))
+		}
+		return nil
+	})
+	return until, err
+}
+
+func (s *ThrottleStore) Unlock(ctx context.Context, key string) error {
+	return s.client.DB.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(LocksBucket)).Delete([]byte(key))
+	})
+}
+
+func (s *ThrottleStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltthrottle_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/piprate/metalocker/sdk/apibase/boltthrottle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottleStore(t *testing.T) {
+	ctx := context.Background()
+	storeFilePath := filepath.Join(t.TempDir(), "throttle.bolt")
+
+	store, err := NewThrottleStore(storeFilePath)
+	require.NoError(t, err)
+
+	now := time.Unix(1000, 0).UTC()
+
+	// counters
+
+	for i := 1; i <= 3; i++ {
+		val, err := store.Increment(ctx, "key", now, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, i, val)
+	}
+
+	val, err := store.Increment(ctx, "key", now.Add(time.Minute), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	err = store.Reset(ctx, "key")
+	require.NoError(t, err)
+
+	val, err = store.Increment(ctx, "key", now, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	// locks
+
+	until, err := store.LockedUntil(ctx, "lock")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+
+	err = store.Lock(ctx, "lock", now.Add(time.Hour))
+	require.NoError(t, err)
+
+	// locks survive restarts
+
+	require.NoError(t, store.Close())
+	store, err = NewThrottleStore(storeFilePath)
+	require.NoError(t, err)
+	defer store.Close()
+
+	until, err = store.LockedUntil(ctx, "lock")
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Hour).Equal(until))
+
+	err = store.Unlock(ctx, "lock")
+	require.NoError(t, err)
+
+	until, err = store.LockedUntil(ctx, "lock")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+}
@@ -25,12 +25,25 @@ const (
 	UserIDKey        = "userID"
 	ClientSecretKey  = "clientSecret"
 	ContextLoggerKey = "logger"
+	AccessKeyKey     = "accessKey"
 )
 
 func GetUserID(c *gin.Context) string {
 	return c.GetString(UserIDKey)
 }
 
+// GetAccessKey returns the access key used to authenticate the request, or nil
+// if the request wasn't authenticated with an access key. The returned key
+// doesn't contain any secrets.
+func GetAccessKey(c *gin.Context) *model.AccessKey {
+	val, exists := c.Get(AccessKeyKey)
+	if exists {
+		return val.(*model.AccessKey)
+	} else {
+		return nil
+	}
+}
+
 func GetManagedKey(c *gin.Context) *model.AESKey {
 	val, exists := c.Get(ClientSecretKey)
 	if exists {
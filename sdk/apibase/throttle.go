@@ -0,0 +1,592 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apibase
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/piprate/metalocker/sdk/cmdbase"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ThrottleKey = "throttle"
+
+	MemoryThrottleStoreType = "memory"
+	// MemoryThrottleStoreMaxEntries is the parameter of the in-memory throttle store
+	// that limits the number of counters and locks it keeps.
+	MemoryThrottleStoreMaxEntries = "max_entries"
+
+	AuditRateLimited     = "rate_limited"
+	AuditAuthFailure     = "auth_failure"
+	AuditAuthSuccess     = "auth_success"
+	AuditAccountLocked   = "account_locked"
+	AuditLockedOut       = "locked_out"
+	AuditAccountUnlocked = "account_unlocked"
+
+	defaultThrottleWindow  = time.Minute
+	defaultIPLimit         = 30
+	defaultAccountLimit    = 10
+	defaultMaxFailures     = 5
+	defaultFailureWindow   = 15 * time.Minute
+	defaultLockoutDuration = 15 * time.Minute
+
+	defaultMemoryStoreMaxEntries = 100000
+)
+
+var (
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrAccountLocked   = errors.New("account temporarily locked")
+)
+
+type (
+	// ThrottleConfig defines rate limits and the lockout policy for authentication endpoints.
+	// Zero values are replaced with defaults. Negative limits disable the corresponding check.
+	ThrottleConfig struct {
+		// Window is the interval over which requests are counted (i.e. 1m). Default is 1 minute.
+		Window string `koanf:"window" json:"window"`
+		// IPLimit is the maximum number of requests to one endpoint from one IP address
+		// within the window. Default is 30.
+		IPLimit int `koanf:"ipLimit" json:"ipLimit"`
+		// AccountLimit is the maximum number of requests to one endpoint for one account
+		// within the window. Default is 10.
+		AccountLimit int `koanf:"accountLimit" json:"accountLimit"`
+		// MaxFailures is the number of failed attempts within the failure window
+		// that locks the account. Default is 5.
+		MaxFailures int `koanf:"maxFailures" json:"maxFailures"`
+		// FailureWindow is the interval over which failed attempts are counted. Default is 15 minutes.
+		FailureWindow string `koanf:"failureWindow" json:"failureWindow"`
+		// LockoutDuration defines how long a locked account stays locked. Default is 15 minutes.
+		LockoutDuration string `koanf:"lockoutDuration" json:"lockoutDuration"`
+		// Store defines where counters and locks are kept. Default is in-memory store.
+		Store *ThrottleStoreConfig `koanf:"store" json:"store"`
+	}
+
+	ThrottleStoreConfig struct {
+		Type   string         `koanf:"type" json:"type"`
+		Params map[string]any `koanf:"params" json:"params"`
+	}
+
+	// ThrottleStore keeps request counters and account locks. Persistent stores
+	// preserve lockouts across restarts and can be shared between nodes.
+	ThrottleStore interface {
+		// Increment increments the counter for the given key and returns its new value.
+		// If the counter doesn't exist or expired before now, it starts again from zero
+		// and expires after ttl.
+		Increment(ctx context.Context, key string, now time.Time, ttl time.Duration) (int, error)
+		// Reset deletes the counter for the given key.
+		Reset(ctx context.Context, key string) error
+		// Lock locks the given key until the given time.
+		Lock(ctx context.Context, key string, until time.Time) error
+		// LockedUntil returns the time the given key is locked until, or zero time
+		// if the key was never locked.
+		LockedUntil(ctx context.Context, key string) (time.Time, error)
+		// Unlock removes the lock for the given key.
+		Unlock(ctx context.Context, key string) error
+		// Close releases the resources held by the store.
+		Close() error
+	}
+
+	ThrottleStoreConstructor func(params map[string]any, resolver cmdbase.ParameterResolver) (ThrottleStore, error)
+
+	// AuditEvent describes a rate limiting or lockout event.
+	AuditEvent struct {
+		Type     string     `json:"type"`
+		Time     time.Time  `json:"time"`
+		Endpoint string     `json:"endpoint,omitempty"`
+		IP       string     `json:"ip,omitempty"`
+		UserID   string     `json:"userID,omitempty"`
+		Until    *time.Time `json:"until,omitempty"`
+	}
+
+	// AuditSink receives audit events emitted by the throttle.
+	AuditSink func(evt *AuditEvent)
+
+	// Throttle enforces per-IP and per-account rate limits and locks accounts
+	// after repeated authentication failures. All methods are safe to call on
+	// a nil Throttle, in which case they do nothing.
+	Throttle struct {
+		store           ThrottleStore
+		window          time.Duration
+		failureWindow   time.Duration
+		lockoutDuration time.Duration
+		ipLimit         int
+		accountLimit    int
+		maxFailures     int
+		auditSink       AuditSink
+		nowFn           func() time.Time
+	}
+
+	ThrottleOption func(t *Throttle)
+)
+
+var throttleStoreConstructors = make(map[string]ThrottleStoreConstructor)
+
+func init() {
+	RegisterThrottleStore(MemoryThrottleStoreType, func(params map[string]any, resolver cmdbase.ParameterResolver) (ThrottleStore, error) {
+		maxEntries, _ := params[MemoryThrottleStoreMaxEntries].(int)
+		return NewMemoryThrottleStoreWithLimit(maxEntries), nil
+	})
+}
+
+func RegisterThrottleStore(storeType string, ctor ThrottleStoreConstructor) {
+	if _, ok := throttleStoreConstructors[storeType]; ok {
+		panic("Throttle store constructor already registered for type: " + storeType)
+	}
+
+	throttleStoreConstructors[storeType] = ctor
+}
+
+func CreateThrottleStore(cfg *ThrottleStoreConfig, resolver cmdbase.ParameterResolver) (ThrottleStore, error) {
+
+	log.Info().Str("type", cfg.Type).Msg("Creating throttle store")
+
+	ctor, ok := throttleStoreConstructors[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("throttle store %s not known or loaded", cfg.Type)
+	}
+
+	return ctor(cfg.Params, resolver)
+}
+
+// WithAuditSink sets the function that receives audit events. By default,
+// events are written to the log.
+func WithAuditSink(sink AuditSink) ThrottleOption {
+	return func(t *Throttle) {
+		t.auditSink = sink
+	}
+}
+
+// WithThrottleClock sets the function that returns the current time. Useful for testing.
+func WithThrottleClock(nowFn func() time.Time) ThrottleOption {
+	return func(t *Throttle) {
+		t.nowFn = nowFn
+	}
+}
+
+// LogAuditSink writes audit events to the log.
+func LogAuditSink(evt *AuditEvent) {
+	e := log.Warn()
+	if evt.Type == AuditAuthSuccess || evt.Type == AuditAccountUnlocked {
+		e = log.Info()
+	}
+	e = e.Str("event", evt.Type).Str("ip", evt.IP).Str("userID", evt.UserID).Str("endpoint", evt.Endpoint)
+	if evt.Until != nil {
+		e = e.Time("until", *evt.Until)
+	}
+	e.Msg("Authentication audit event")
+}
+
+// NewThrottle creates a new throttle that keeps its state in the given store.
+func NewThrottle(cfg *ThrottleConfig, store ThrottleStore, opts ...ThrottleOption) (*Throttle, error) {
+	t := &Throttle{
+		store:           store,
+		window:          defaultThrottleWindow,
+		failureWindow:   defaultFailureWindow,
+		lockoutDuration: defaultLockoutDuration,
+		ipLimit:         defaultIPLimit,
+		accountLimit:    defaultAccountLimit,
+		maxFailures:     defaultMaxFailures,
+		auditSink:       LogAuditSink,
+		nowFn:           time.Now,
+	}
+
+	var err error
+	if t.window, err = parsePositiveDuration(cfg.Window, t.window, "throttle window"); err != nil {
+		return nil, err
+	}
+	if t.failureWindow, err = parsePositiveDuration(cfg.FailureWindow, t.failureWindow, "failure window"); err != nil {
+		return nil, err
+	}
+	if t.lockoutDuration, err = parsePositiveDuration(cfg.LockoutDuration, t.lockoutDuration, "lockout duration"); err != nil {
+		return nil, err
+	}
+	if cfg.IPLimit != 0 {
+		t.ipLimit = cfg.IPLimit
+	}
+	if cfg.AccountLimit != 0 {
+		t.accountLimit = cfg.AccountLimit
+	}
+	if cfg.MaxFailures != 0 {
+		t.maxFailures = cfg.MaxFailures
+	}
+
+	for _, fn := range opts {
+		fn(t)
+	}
+
+	return t, nil
+}
+
+func parsePositiveDuration(val string, defaultVal time.Duration, name string) (time.Duration, error) {
+	if val == "" {
+		return defaultVal, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s should be positive", name)
+	}
+	return d, nil
+}
+
+// GetThrottle returns the throttle that was attached to the request by
+// Throttle.Middleware, or nil if the request isn't throttled.
+func GetThrottle(c *gin.Context) *Throttle {
+	val, exists := c.Get(ThrottleKey)
+	if exists {
+		return val.(*Throttle)
+	} else {
+		return nil
+	}
+}
+
+// Middleware returns a handler that enforces the per-IP limit for the route
+// and makes the throttle available to the route's handler via GetThrottle.
+// If the store fails, the request is let through.
+func (t *Throttle) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if t == nil {
+			c.Next()
+			return
+		}
+
+		c.Set(ThrottleKey, t)
+
+		if t.ipLimit > 0 {
+			endpoint := requestEndpoint(c)
+			cnt, err := t.store.Increment(c, "ip|"+endpoint+"|"+c.ClientIP(), t.nowFn(), t.window)
+			if err != nil {
+				log.Err(err).Str("ip", c.ClientIP()).Msg("Failed to update request counter")
+			} else if cnt > t.ipLimit {
+				t.emit(c, AuditRateLimited, "", nil)
+				setRetryAfter(c, t.window)
+				AbortWithError(c, http.StatusTooManyRequests, ErrTooManyRequests.Error())
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// CheckAccount returns ErrAccountLocked if the account is locked, or
+// ErrTooManyRequests if the per-account limit for the route was exceeded.
+// In the latter case, it sets the Retry-After header. Callers should respond
+// to locked accounts the same way as to any other failed attempt, to avoid
+// revealing the account's state.
+func (t *Throttle) CheckAccount(c *gin.Context, userID string) error {
+	if t == nil {
+		return nil
+	}
+
+	userID = normaliseThrottleUserID(userID)
+	now := t.nowFn()
+
+	until, err := t.store.LockedUntil(c, "lock|"+userID)
+	if err != nil {
+		log.Err(err).Str("userID", userID).Msg("Failed to read account lock")
+	} else if now.Before(until) {
+		t.emit(c, AuditLockedOut, userID, &until)
+		return ErrAccountLocked
+	}
+
+	if t.accountLimit > 0 {
+		cnt, err := t.store.Increment(c, "acct|"+requestEndpoint(c)+"|"+userID, now, t.window)
+		if err != nil {
+			log.Err(err).Str("userID", userID).Msg("Failed to update request counter")
+		} else if cnt > t.accountLimit {
+			t.emit(c, AuditRateLimited, userID, nil)
+			setRetryAfter(c, t.window)
+			return ErrTooManyRequests
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure registers a failed authentication attempt for the given account
+// and locks the account once the number of failures reaches the limit.
+func (t *Throttle) RecordFailure(c *gin.Context, userID string) {
+	if t == nil {
+		return
+	}
+
+	userID = normaliseThrottleUserID(userID)
+	t.emit(c, AuditAuthFailure, userID, nil)
+
+	if t.maxFailures <= 0 {
+		return
+	}
+
+	now := t.nowFn()
+	cnt, err := t.store.Increment(c, "fail|"+userID, now, t.failureWindow)
+	if err != nil {
+		log.Err(err).Str("userID", userID).Msg("Failed to update failure counter")
+		return
+	}
+
+	if cnt >= t.maxFailures {
+		until := now.Add(t.lockoutDuration)
+		if err = t.store.Lock(c, "lock|"+userID, until); err != nil {
+			log.Err(err).Str("userID", userID).Msg("Failed to lock account")
+			return
+		}
+		if err = t.store.Reset(c, "fail|"+userID); err != nil {
+			log.Err(err).Str("userID", userID).Msg("Failed to reset failure counter")
+		}
+		t.emit(c, AuditAccountLocked, userID, &until)
+	}
+}
+
+// RecordSuccess registers a successful authentication attempt for the given
+// account and resets its failure counter.
+func (t *Throttle) RecordSuccess(c *gin.Context, userID string) {
+	if t == nil {
+		return
+	}
+
+	userID = normaliseThrottleUserID(userID)
+	if err := t.store.Reset(c, "fail|"+userID); err != nil {
+		log.Err(err).Str("userID", userID).Msg("Failed to reset failure counter")
+	}
+	t.emit(c, AuditAuthSuccess, userID, nil)
+}
+
+// LockedUntil returns the time the given account is locked until, or zero
+// time if the account isn't locked.
+func (t *Throttle) LockedUntil(ctx context.Context, userID string) (time.Time, error) {
+	if t == nil {
+		return time.Time{}, nil
+	}
+
+	until, err := t.store.LockedUntil(ctx, "lock|"+normaliseThrottleUserID(userID))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !t.nowFn().Before(until) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+// Unlock removes the lock from the given account and resets its failure counter.
+func (t *Throttle) Unlock(ctx context.Context, userID string) error {
+	if t == nil {
+		return nil
+	}
+
+	userID = normaliseThrottleUserID(userID)
+	if err := t.store.Unlock(ctx, "lock|"+userID); err != nil {
+		return err
+	}
+	if err := t.store.Reset(ctx, "fail|"+userID); err != nil {
+		return err
+	}
+
+	t.auditSink(&AuditEvent{
+		Type:   AuditAccountUnlocked,
+		Time:   t.nowFn(),
+		UserID: userID,
+	})
+
+	return nil
+}
+
+func (t *Throttle) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.store.Close()
+}
+
+func (t *Throttle) emit(c *gin.Context, eventType, userID string, until *time.Time) {
+	t.auditSink(&AuditEvent{
+		Type:     eventType,
+		Time:     t.nowFn(),
+		Endpoint: requestEndpoint(c),
+		IP:       c.ClientIP(),
+		UserID:   userID,
+		Until:    until,
+	})
+}
+
+func requestEndpoint(c *gin.Context) string {
+	if path := c.FullPath(); path != "" {
+		return path
+	}
+	return c.Request.URL.Path
+}
+
+func normaliseThrottleUserID(userID string) string {
+	if strings.Contains(userID, "@") {
+		// if the user id is an email, transform to lower case
+		return strings.ToLower(userID)
+	}
+	return userID
+}
+
+func setRetryAfter(c *gin.Context, d time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+type (
+	memoryCounter struct {
+		value     int
+		expiresAt time.Time
+	}
+
+	memoryEntry struct {
+		key     string
+		counter *memoryCounter
+		until   time.Time
+	}
+
+	// MemoryThrottleStore is an in-memory implementation of ThrottleStore.
+	// It keeps at most a fixed number of counters and locks, evicting
+	// the least recently used entries once the limit is reached.
+	MemoryThrottleStore struct {
+		maxEntries int
+		entries    map[string]*list.Element
+		lru        *list.List
+		mtx        sync.Mutex
+	}
+)
+
+var _ ThrottleStore = (*MemoryThrottleStore)(nil)
+
+// NewMemoryThrottleStore creates an in-memory throttle store with the default
+// entry limit.
+func NewMemoryThrottleStore() *MemoryThrottleStore {
+	return NewMemoryThrottleStoreWithLimit(defaultMemoryStoreMaxEntries)
+}
+
+// NewMemoryThrottleStoreWithLimit creates an in-memory throttle store that keeps
+// at most maxEntries counters and locks.
+func NewMemoryThrottleStoreWithLimit(maxEntries int) *MemoryThrottleStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultMemoryStoreMaxEntries
+	}
+	return &MemoryThrottleStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (s *MemoryThrottleStore) Increment(ctx context.Context, key string, now time.Time, ttl time.Duration) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	e := s.get("cnt|" + key)
+	if e.counter == nil || !now.Before(e.counter.expiresAt) {
+		e.counter = &memoryCounter{
+			expiresAt: now.Add(ttl),
+		}
+	}
+	e.counter.value++
+
+	return e.counter.value, nil
+}
+
+func (s *MemoryThrottleStore) Reset(ctx context.Context, key string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.remove("cnt|" + key)
+
+	return nil
+}
+
+func (s *MemoryThrottleStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.get("lock|" + key).until = until
+
+	return nil
+}
+
+func (s *MemoryThrottleStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if el, found := s.entries["lock|"+key]; found {
+		return el.Value.(*memoryEntry).until, nil
+	}
+
+	return time.Time{}, nil
+}
+
+func (s *MemoryThrottleStore) Unlock(ctx context.Context, key string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.remove("lock|" + key)
+
+	return nil
+}
+
+func (s *MemoryThrottleStore) Close() error {
+	return nil
+}
+
+// Len returns the number of counters and locks kept in the store.
+func (s *MemoryThrottleStore) Len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.lru.Len()
+}
+
+// get returns the entry for the given key, creating it if necessary, and marks
+// it as the most recently used one. If the store is full, the least recently used
+// entry gets evicted.
+func (s *MemoryThrottleStore) get(key string) *memoryEntry {
+	if el, found := s.entries[key]; found {
+		s.lru.MoveToFront(el)
+		return el.Value.(*memoryEntry)
+	}
+
+	for s.lru.Len() >= s.maxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	e := &memoryEntry{key: key}
+	s.entries[key] = s.lru.PushFront(e)
+
+	return e
+}
+
+func (s *MemoryThrottleStore) remove(key string) {
+	if el, found := s.entries[key]; found {
+		s.lru.Remove(el)
+		delete(s.entries, key)
+	}
+}
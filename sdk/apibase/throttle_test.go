@@ -0,0 +1,335 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apibase_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/piprate/metalocker/sdk/apibase"
+	"github.com/piprate/metalocker/storage"
+	"github.com/piprate/metalocker/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type throttleFixture struct {
+	now    time.Time
+	events []*AuditEvent
+}
+
+func (f *throttleFixture) newThrottle(t *testing.T, cfg *ThrottleConfig) *Throttle {
+	t.Helper()
+
+	throttle, err := NewThrottle(cfg, NewMemoryThrottleStore(),
+		WithThrottleClock(func() time.Time {
+			return f.now
+		}),
+		WithAuditSink(func(evt *AuditEvent) {
+			f.events = append(f.events, evt)
+		}),
+	)
+	require.NoError(t, err)
+
+	return throttle
+}
+
+func (f *throttleFixture) eventTypes() []string {
+	res := make([]string, len(f.events))
+	for i, evt := range f.events {
+		res[i] = evt.Type
+	}
+	return res
+}
+
+func newThrottledLoginRouter(t *testing.T, throttle *Throttle, identityBackend storage.IdentityBackend) *gin.Engine {
+	t.Helper()
+
+	dummyPrivateKey := "8L0x196HUEQMCzOg5Y23jK7OcVQLOesCqkiA6ZnvPfzAtKdyqLIFGUiAIv5zyB8xLVWt1eerphva3lq+cVh7jQ=="
+
+	mw, err := JWTMiddlewareWithTokenIssuance("Test Realm", "piprate.com",
+		AuthenticationHandler(identityBackend, nil, "", ""),
+		dummyPrivateKey, "testdata/token.rsa", "testdata/token.rsa.pub", time.Hour, func() time.Time {
+			return time.Unix(1000, 0).UTC()
+		})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.POST("/v1/authenticate", throttle.Middleware(), mw.LoginHandler)
+
+	return r
+}
+
+func invokeRouter(r *gin.Engine, method, path, ip, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.RemoteAddr = ip + ":12345"
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func loginBody(username, password string) string {
+	return string(LoginForm{
+		Username: username,
+		Password: password,
+	}.Bytes())
+}
+
+func TestNewThrottle_BadConfig(t *testing.T) {
+	_, err := NewThrottle(&ThrottleConfig{Window: "abc"}, NewMemoryThrottleStore())
+	require.Error(t, err)
+
+	_, err = NewThrottle(&ThrottleConfig{LockoutDuration: "-1m"}, NewMemoryThrottleStore())
+	require.Error(t, err)
+}
+
+func TestThrottle_IPLimit(t *testing.T) {
+	f := &throttleFixture{now: time.Unix(1000, 0).UTC()}
+	throttle := f.newThrottle(t, &ThrottleConfig{
+		IPLimit: 2,
+	})
+
+	r := gin.New()
+	r.GET("/v1/recovery-code", throttle.Middleware(), func(c *gin.Context) {
+		assert.Equal(t, throttle, GetThrottle(c))
+		c.Status(http.StatusOK)
+	})
+
+	for i := 0; i < 2; i++ {
+		rec := invokeRouter(r, http.MethodGet, "/v1/recovery-code", "10.0.0.1", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	rec := invokeRouter(r, http.MethodGet, "/v1/recovery-code", "10.0.0.1", "")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	checkResponseBody(t, `{
+  "message": "too many requests"
+}`, rec.Body.Bytes())
+
+	// other IP addresses aren't affected
+
+	rec = invokeRouter(r, http.MethodGet, "/v1/recovery-code", "10.0.0.2", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// the limit is reset after the window expires
+
+	f.now = f.now.Add(time.Minute)
+
+	rec = invokeRouter(r, http.MethodGet, "/v1/recovery-code", "10.0.0.1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, []string{AuditRateLimited}, f.eventTypes())
+	assert.Equal(t, "10.0.0.1", f.events[0].IP)
+	assert.Equal(t, "/v1/recovery-code", f.events[0].Endpoint)
+}
+
+func TestThrottle_AccountLimit(t *testing.T) {
+	f := &throttleFixture{now: time.Unix(1000, 0).UTC()}
+	throttle := f.newThrottle(t, &ThrottleConfig{
+		IPLimit:      -1,
+		AccountLimit: 2,
+		MaxFailures:  -1,
+	})
+
+	identityBackend, _ := memory.CreateIdentityBackend(nil, nil)
+	r := newThrottledLoginRouter(t, throttle, identityBackend)
+
+	// requests for the same account from different IP addresses are counted together
+
+	rec := invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.1", loginBody("Test@example.com", "pwd"))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.2", loginBody("test@example.com", "pwd"))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.3", loginBody("test@example.com", "pwd"))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	checkResponseBody(t, `{
+  "message": "too many requests"
+}`, rec.Body.Bytes())
+}
+
+func TestThrottle_LockoutAndUnlock(t *testing.T) {
+	ctx := context.Background()
+
+	identityBackend, _ := memory.CreateIdentityBackend(nil, nil)
+	err := identityBackend.CreateAccount(ctx, testAccountV3)
+	require.NoError(t, err)
+
+	f := &throttleFixture{now: time.Unix(1000, 0).UTC()}
+	throttle := f.newThrottle(t, &ThrottleConfig{
+		MaxFailures:     3,
+		LockoutDuration: "10m",
+	})
+
+	r := newThrottledLoginRouter(t, throttle, identityBackend)
+
+	goodLogin := loginBody("test@example.com", "gm7FhMFxFD01wGd8dE1RqUAxx7noD8LvPQyBzK+27LA=")
+	badLogin := loginBody("test@example.com", "wrong password")
+
+	for i := 0; i < 3; i++ {
+		rec := invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.1", badLogin)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	// the account is locked, even for the correct password. The response
+	// doesn't reveal the lock.
+
+	rec := invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.2", goodLogin)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+	checkResponseBody(t, `{
+  "message": "incorrect Username or Password"
+}`, rec.Body.Bytes())
+
+	until, err := throttle.LockedUntil(ctx, "test@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, f.now.Add(10*time.Minute), until)
+
+	assert.Equal(t, []string{
+		AuditAuthFailure,
+		AuditAuthFailure,
+		AuditAuthFailure,
+		AuditAccountLocked,
+		AuditLockedOut,
+	}, f.eventTypes())
+
+	// unlock the account
+
+	err = throttle.Unlock(ctx, "Test@example.com")
+	require.NoError(t, err)
+
+	until, err = throttle.LockedUntil(ctx, "test@example.com")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+
+	rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.2", goodLogin)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, AuditAccountUnlocked, f.events[5].Type)
+	assert.Equal(t, AuditAuthSuccess, f.events[6].Type)
+
+	// a successful login resets the failure counter
+
+	for i := 0; i < 2; i++ {
+		rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.1", badLogin)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.2", goodLogin)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.1", badLogin)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// the lock expires after the lockout duration
+
+	f.now = f.now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.1", badLogin)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.2", goodLogin)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	f.now = f.now.Add(10 * time.Minute)
+
+	rec = invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.2", goodLogin)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestThrottle_UnknownAccountLocked(t *testing.T) {
+	identityBackend, _ := memory.CreateIdentityBackend(nil, nil)
+
+	f := &throttleFixture{now: time.Unix(1000, 0).UTC()}
+	throttle := f.newThrottle(t, &ThrottleConfig{
+		MaxFailures: 2,
+	})
+
+	r := newThrottledLoginRouter(t, throttle, identityBackend)
+
+	for i := 0; i < 2; i++ {
+		rec := invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.1", loginBody("nobody@example.com", "pwd"))
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	rec := invokeRouter(r, http.MethodPost, "/v1/authenticate", "10.0.0.1", loginBody("nobody@example.com", "pwd"))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	checkResponseBody(t, `{
+  "message": "incorrect Username or Password"
+}`, rec.Body.Bytes())
+
+	until, err := throttle.LockedUntil(context.Background(), "nobody@example.com")
+	require.NoError(t, err)
+	assert.False(t, until.IsZero())
+}
+
+func TestMemoryThrottleStore_MaxEntries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0).UTC()
+
+	store := NewMemoryThrottleStoreWithLimit(3)
+
+	_, err := store.Increment(ctx, "a", now, time.Minute)
+	require.NoError(t, err)
+	_, err = store.Increment(ctx, "b", now, time.Minute)
+	require.NoError(t, err)
+	err = store.Lock(ctx, "c", now.Add(time.Minute))
+	require.NoError(t, err)
+
+	// touch 'a' to make 'b' the least recently used entry
+
+	cnt, err := store.Increment(ctx, "a", now, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cnt)
+
+	_, err = store.Increment(ctx, "d", now, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 3, store.Len())
+
+	cnt, err = store.Increment(ctx, "b", now, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+
+	// the store doesn't grow beyond the limit
+
+	for i := 0; i < 100; i++ {
+		_, err = store.Increment(ctx, fmt.Sprintf("key%d", i), now, time.Minute)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, store.Len())
+
+	until, err := store.LockedUntil(ctx, "c")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+}
+
+func TestThrottle_Nil(t *testing.T) {
+	var throttle *Throttle
+
+	r := gin.New()
+	r.GET("/ping", throttle.Middleware(), func(c *gin.Context) {
+		assert.Nil(t, GetThrottle(c))
+		assert.NoError(t, GetThrottle(c).CheckAccount(c, "test@example.com"))
+		GetThrottle(c).RecordFailure(c, "test@example.com")
+		c.Status(http.StatusOK)
+	})
+
+	rec := invokeRouter(r, http.MethodGet, "/ping", "10.0.0.1", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+}
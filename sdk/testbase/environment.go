@@ -51,6 +51,7 @@ type TestMetaLockerEnvironment struct {
 	IndexStore      index.Store
 	IndexClient     index.Client
 	OffChainStorage model.OffChainStorage
+	OffChainVault   vaults.Vault
 	NS              notification.Service
 	Ledger          model.Ledger
 	Factory         *wallet.LocalFactory
@@ -208,8 +209,8 @@ func SetUpTestEnvironment(t *testing.T) *TestMetaLockerEnvironment {
 
 	env.Ledger = ledgerAPI
 
-	ocVault, _ := NewInMemoryVault(t, TestOffChainStorageID, "offchain", false, true, nil)
-	env.OffChainStorage = node.NewOffChainStorageProxy(ocVault)
+	env.OffChainVault, _ = NewInMemoryVault(t, TestOffChainStorageID, "offchain", false, true, nil)
+	env.OffChainStorage = node.NewOffChainStorageProxy(env.OffChainVault)
 
 	env.IndexClient, err = index.NewLocalIndexClient(env.Ctx, []*index.StoreConfig{
 		{
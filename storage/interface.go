@@ -33,12 +33,14 @@ var (
 	ErrAccessKeyNotFound    = errors.New("access key not found")
 	ErrPropertyNotFound     = errors.New("property not found")
 	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+	ErrSecondFactorNotFound = errors.New("second factor not found")
+	ErrSecondFactorConflict = errors.New("second factor modified concurrently")
 )
 
 type (
 	// IdentityBackend stores MetaLocker accounts and related entities
-	// such as encrypted identities, lockers, access keys, properties,
-	// DID documents and recovery codes. This is the main node-specific
+	// such as encrypted identities, lockers, access keys, second
+	// authentication factors, properties, DID documents and recovery codes. This is the main node-specific
 	// storage layer.
 	IdentityBackend interface {
 		io.Closer
@@ -67,6 +69,15 @@ type (
 		GetAccessKey(ctx context.Context, keyID string) (*model.AccessKey, error)
 		DeleteAccessKey(ctx context.Context, keyID string) error
 
+		ListSecondFactors(ctx context.Context, accountID string) ([]*account.SecondFactor, error)
+		StoreSecondFactor(ctx context.Context, factor *account.SecondFactor) error
+		// UpdateSecondFactor replaces the factor only if its stored revision
+		// still matches factor.Revision, and bumps the revision. It returns
+		// ErrSecondFactorConflict if the factor was modified since it was read.
+		UpdateSecondFactor(ctx context.Context, factor *account.SecondFactor) error
+		GetSecondFactor(ctx context.Context, factorID string) (*account.SecondFactor, error)
+		DeleteSecondFactor(ctx context.Context, factorID string) error
+
 		StoreIdentity(ctx context.Context, accountID string, idy *account.DataEnvelope) error
 		GetIdentity(ctx context.Context, accountID string, hash string) (*account.DataEnvelope, error)
 		ListIdentities(ctx context.Context, accountID string, lvl model.AccessLevel) ([]*account.DataEnvelope, error)
@@ -16,6 +16,7 @@ package memory
 
 import (
 	"context"
+	"sync"
 
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/model/account"
@@ -38,6 +39,8 @@ type InMemoryBackend struct {
 	properties          map[string]map[string]*account.DataEnvelope
 	dids                map[string]*model.DIDDocument
 	recoveryCodes       map[string]*account.RecoveryCode
+	secondFactors       map[string]*account.SecondFactor
+	secondFactorLock    sync.Mutex
 }
 
 var _ storage.IdentityBackend = (*InMemoryBackend)(nil)
@@ -105,6 +108,13 @@ func (be *InMemoryBackend) DeleteAccount(ctx context.Context, id string) error {
 			delete(be.recoveryCodes, code)
 		}
 	}
+	be.secondFactorLock.Lock()
+	for factorID, f := range be.secondFactors {
+		if f.AccountID == id {
+			delete(be.secondFactors, factorID)
+		}
+	}
+	be.secondFactorLock.Unlock()
 
 	return nil
 }
@@ -152,6 +162,72 @@ func (be *InMemoryBackend) DeleteAccessKey(ctx context.Context, keyID string) er
 	}
 }
 
+func (be *InMemoryBackend) ListSecondFactors(ctx context.Context, accountID string) ([]*account.SecondFactor, error) {
+	be.secondFactorLock.Lock()
+	defer be.secondFactorLock.Unlock()
+
+	res := make([]*account.SecondFactor, 0)
+	for _, f := range be.secondFactors {
+		if f.AccountID == accountID {
+			res = append(res, copySecondFactor(f))
+		}
+	}
+	return res, nil
+}
+
+func (be *InMemoryBackend) StoreSecondFactor(ctx context.Context, factor *account.SecondFactor) error {
+	if _, found := be.accounts[factor.AccountID]; !found {
+		return storage.ErrAccountNotFound
+	}
+
+	be.secondFactorLock.Lock()
+	defer be.secondFactorLock.Unlock()
+
+	if existing, found := be.secondFactors[factor.ID]; found {
+		factor.Revision = existing.Revision + 1
+	}
+	be.secondFactors[factor.ID] = copySecondFactor(factor)
+	return nil
+}
+
+func (be *InMemoryBackend) UpdateSecondFactor(ctx context.Context, factor *account.SecondFactor) error {
+	be.secondFactorLock.Lock()
+	defer be.secondFactorLock.Unlock()
+
+	existing, found := be.secondFactors[factor.ID]
+	if !found {
+		return storage.ErrSecondFactorNotFound
+	}
+	if existing.Revision != factor.Revision {
+		return storage.ErrSecondFactorConflict
+	}
+	factor.Revision++
+	be.secondFactors[factor.ID] = copySecondFactor(factor)
+	return nil
+}
+
+func (be *InMemoryBackend) GetSecondFactor(ctx context.Context, factorID string) (*account.SecondFactor, error) {
+	be.secondFactorLock.Lock()
+	defer be.secondFactorLock.Unlock()
+
+	f, found := be.secondFactors[factorID]
+	if !found {
+		return nil, storage.ErrSecondFactorNotFound
+	}
+	return copySecondFactor(f), nil
+}
+
+func (be *InMemoryBackend) DeleteSecondFactor(ctx context.Context, factorID string) error {
+	be.secondFactorLock.Lock()
+	defer be.secondFactorLock.Unlock()
+
+	if _, found := be.secondFactors[factorID]; !found {
+		return storage.ErrSecondFactorNotFound
+	}
+	delete(be.secondFactors, factorID)
+	return nil
+}
+
 func (be *InMemoryBackend) StoreIdentity(ctx context.Context, accountID string, idy *account.DataEnvelope) error {
 	acctMap, found := be.identities[accountID]
 	if !found {
@@ -360,6 +436,14 @@ func copyAccount(acct *account.Account) *account.Account {
 	return &cpy
 }
 
+func copySecondFactor(f *account.SecondFactor) *account.SecondFactor {
+	var cpy account.SecondFactor
+	if err := utils.MarshalToType(f, &cpy, true); err != nil {
+		panic(err)
+	}
+	return &cpy
+}
+
 func CreateIdentityBackend(params storage.Parameters, resolver cmdbase.ParameterResolver) (storage.IdentityBackend, error) {
 	return &InMemoryBackend{
 		accounts:            make(map[string]*account.Account),
@@ -371,5 +455,6 @@ func CreateIdentityBackend(params storage.Parameters, resolver cmdbase.Parameter
 		properties:          make(map[string]map[string]*account.DataEnvelope),
 		dids:                make(map[string]*model.DIDDocument),
 		recoveryCodes:       make(map[string]*account.RecoveryCode),
+		secondFactors:       make(map[string]*account.SecondFactor),
 	}, nil
 }
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"strings"
 
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/sqljson"
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/model/account"
 	"github.com/piprate/metalocker/storage"
@@ -17,6 +19,7 @@ import (
 	"github.com/piprate/metalocker/storage/rdb/ent/predicate"
 	"github.com/piprate/metalocker/storage/rdb/ent/property"
 	"github.com/piprate/metalocker/storage/rdb/ent/recoverycode"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
 	"github.com/piprate/metalocker/utils/measure"
 	"github.com/rs/zerolog/log"
 )
@@ -25,16 +28,32 @@ const (
 	maxAccountDepth = 10
 )
 
-type RelationalBackend struct {
-	client *ent.Client
-}
+type (
+	RelationalBackend struct {
+		client    *ent.Client
+		secretKey *model.AESKey
+	}
+
+	Option func(rbe *RelationalBackend)
+)
 
 var _ storage.IdentityBackend = (*RelationalBackend)(nil)
 
-func NewRelationalBackend(client *ent.Client) *RelationalBackend {
-	return &RelationalBackend{
+// WithSecretKey sets the key used to encrypt second factor secrets at rest.
+func WithSecretKey(key *model.AESKey) Option {
+	return func(rbe *RelationalBackend) {
+		rbe.secretKey = key
+	}
+}
+
+func NewRelationalBackend(client *ent.Client, opts ...Option) *RelationalBackend {
+	rbe := &RelationalBackend{
 		client: client,
 	}
+	for _, fn := range opts {
+		fn(rbe)
+	}
+	return rbe
 }
 
 func (rbe *RelationalBackend) Close() error {
@@ -308,6 +327,173 @@ func (rbe *RelationalBackend) DeleteAccessKey(ctx context.Context, keyID string)
 	return nil
 }
 
+func (rbe *RelationalBackend) ListSecondFactors(ctx context.Context, accountID string) ([]*account.SecondFactor, error) {
+	defer measure.ExecTime("rdb.ListSecondFactors")()
+
+	rows, err := rbe.client.SecondFactor.Query().
+		Where(
+			secondfactor.HasAccountWith(entAccount.Did(accountID)),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*account.SecondFactor, len(rows))
+
+	for i, row := range rows {
+		result[i], err = rbe.openSecondFactor(row.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (rbe *RelationalBackend) StoreSecondFactor(ctx context.Context, factor *account.SecondFactor) error {
+	defer measure.ExecTime("rdb.StoreSecondFactor")()
+
+	id, err := rbe.client.Account.Query().Where(entAccount.Did(factor.AccountID)).OnlyID(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return storage.ErrAccountNotFound
+		} else {
+			return err
+		}
+	}
+
+	// factors get updated when confirmed or used (to prevent code replays),
+	// so we replace the body if the factor already exists.
+	existing, err := rbe.client.SecondFactor.Query().
+		Where(
+			secondfactor.HasAccountWith(entAccount.ID(id)),
+			secondfactor.Fid(factor.ID),
+		).
+		Only(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		return err
+	}
+
+	if existing != nil {
+		factor.Revision = existing.Body.Revision + 1
+		body, err := rbe.sealSecondFactor(factor)
+		if err != nil {
+			return err
+		}
+		return existing.Update().SetBody(body).Exec(ctx)
+	}
+
+	body, err := rbe.sealSecondFactor(factor)
+	if err != nil {
+		return err
+	}
+
+	_, err = rbe.client.SecondFactor.Create().
+		SetAccountID(id).
+		SetFid(factor.ID).
+		SetBody(body).
+		Save(ctx)
+
+	return err
+}
+
+func (rbe *RelationalBackend) UpdateSecondFactor(ctx context.Context, factor *account.SecondFactor) error {
+	defer measure.ExecTime("rdb.UpdateSecondFactor")()
+
+	next := *factor
+	next.Revision++
+	body, err := rbe.sealSecondFactor(&next)
+	if err != nil {
+		return err
+	}
+
+	// the update only succeeds if nobody changed the factor since it was read.
+	// Factors stored before revisions were introduced have no revision at all.
+	var revisionMatches *sql.Predicate
+	if factor.Revision == 0 {
+		revisionMatches = sql.Not(sqljson.HasKey(secondfactor.FieldBody, sqljson.Path("revision")))
+	} else {
+		revisionMatches = sqljson.ValueEQ(secondfactor.FieldBody, factor.Revision, sqljson.Path("revision"))
+	}
+
+	n, err := rbe.client.SecondFactor.Update().
+		Where(
+			secondfactor.Fid(factor.ID),
+			predicate.SecondFactor(func(s *sql.Selector) {
+				s.Where(revisionMatches)
+			}),
+		).
+		SetBody(body).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		exists, err := rbe.client.SecondFactor.Query().Where(secondfactor.Fid(factor.ID)).Exist(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrSecondFactorNotFound
+		}
+		return storage.ErrSecondFactorConflict
+	}
+
+	factor.Revision = next.Revision
+
+	return nil
+}
+
+func (rbe *RelationalBackend) GetSecondFactor(ctx context.Context, factorID string) (*account.SecondFactor, error) {
+	defer measure.ExecTime("rdb.GetSecondFactor")()
+
+	row, err := rbe.client.SecondFactor.Query().Where(secondfactor.Fid(factorID)).First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, storage.ErrSecondFactorNotFound
+		} else {
+			return nil, err
+		}
+	}
+	return rbe.openSecondFactor(row.Body)
+}
+
+func (rbe *RelationalBackend) DeleteSecondFactor(ctx context.Context, factorID string) error {
+	defer measure.ExecTime("rdb.DeleteSecondFactor")()
+
+	rowCount, err := rbe.client.SecondFactor.Delete().
+		Where(secondfactor.Fid(factorID)).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if rowCount == 0 {
+		return storage.ErrSecondFactorNotFound
+	}
+	return nil
+}
+
+// sealSecondFactor prepares the factor for storage by encrypting its secret,
+// if the backend has a secret key.
+func (rbe *RelationalBackend) sealSecondFactor(factor *account.SecondFactor) (*account.SecondFactor, error) {
+	if rbe.secretKey == nil {
+		return factor, nil
+	}
+	return factor.EncryptSecret(rbe.secretKey)
+}
+
+// openSecondFactor decrypts the secret of a stored factor. Factors stored
+// with a cleartext secret are returned as is.
+func (rbe *RelationalBackend) openSecondFactor(factor *account.SecondFactor) (*account.SecondFactor, error) {
+	if factor.EncryptedSecret == "" {
+		return factor, nil
+	}
+	if rbe.secretKey == nil {
+		return nil, errors.New("second factor secret is encrypted, but no secret key is configured")
+	}
+	return factor.DecryptSecret(rbe.secretKey)
+}
+
 func (rbe *RelationalBackend) StoreIdentity(ctx context.Context, accountID string, idy *account.DataEnvelope) error {
 	defer measure.ExecTime("rdb.StoreIdentity")()
 
@@ -392,6 +578,24 @@ func (rbe *RelationalBackend) StoreLocker(ctx context.Context, accountID string,
 		}
 	}
 
+	// lockers can be updated (for example, when a new participant is added),
+	// so we replace the envelope if it already exists.
+	n, err := rbe.client.Locker.Update().
+		Where(
+			locker.HasAccountWith(entAccount.ID(id)),
+			locker.Hash(l.Hash),
+		).
+		SetLevel(int32(l.AccessLevel)).
+		SetEncryptedID(l.EncryptedID).
+		SetEncryptedBody(l.EncryptedBody).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
 	_, err = rbe.client.Locker.Create().
 		SetAccountID(id).
 		SetHash(l.Hash).
@@ -1043,6 +1043,242 @@ func Test_RelationalBackend_ListAccessKeys(t *testing.T) {
 	assertFailOnDatabaseError(t, err)
 }
 
+func Test_RelationalBackend_StoreSecondFactor(t *testing.T) {
+	client := newClient(t)
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+
+	be := NewRelationalBackend(client)
+
+	testAcct := createAccount(t, "test@example.com", "Test User")
+
+	err := be.CreateAccount(ctx, testAcct)
+	require.NoError(t, err)
+
+	f, err := account.NewTOTPFactor(testAcct.ID, "phone")
+	require.NoError(t, err)
+
+	// happy path
+
+	err = be.StoreSecondFactor(ctx, f)
+	require.NoError(t, err)
+
+	// update existing factor
+
+	f.Confirmed = true
+	err = be.StoreSecondFactor(ctx, f)
+	require.NoError(t, err)
+
+	list, err := be.ListSecondFactors(ctx, testAcct.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(list))
+	assert.True(t, list[0].Confirmed)
+	assert.Equal(t, f.Secret, list[0].Secret)
+
+	// fail if account not found
+
+	badFactor, err := account.NewTOTPFactor("bad-account-id", "phone")
+	require.NoError(t, err)
+
+	err = be.StoreSecondFactor(ctx, badFactor)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, storage.ErrAccountNotFound))
+
+	_ = client.Close()
+	err = be.StoreSecondFactor(ctx, f)
+	assertFailOnDatabaseError(t, err)
+}
+
+func Test_RelationalBackend_UpdateSecondFactor(t *testing.T) {
+	client := newClient(t)
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+
+	be := NewRelationalBackend(client)
+
+	testAcct := createAccount(t, "test@example.com", "Test User")
+
+	err := be.CreateAccount(ctx, testAcct)
+	require.NoError(t, err)
+
+	f, err := account.NewTOTPFactor(testAcct.ID, "phone")
+	require.NoError(t, err)
+
+	err = be.StoreSecondFactor(ctx, f)
+	require.NoError(t, err)
+
+	// happy path
+
+	first, err := be.GetSecondFactor(ctx, f.ID)
+	require.NoError(t, err)
+	second, err := be.GetSecondFactor(ctx, f.ID)
+	require.NoError(t, err)
+
+	first.LastUsedStep = 100
+	err = be.UpdateSecondFactor(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), first.Revision)
+
+	// fail if the factor was updated since it was read
+
+	second.LastUsedStep = 100
+	err = be.UpdateSecondFactor(ctx, second)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, storage.ErrSecondFactorConflict))
+
+	res, err := be.GetSecondFactor(ctx, f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), res.LastUsedStep)
+
+	res.LastUsedStep = 101
+	err = be.UpdateSecondFactor(ctx, res)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), res.Revision)
+
+	// fail if factor not found
+
+	badFactor, err := account.NewTOTPFactor(testAcct.ID, "phone")
+	require.NoError(t, err)
+
+	err = be.UpdateSecondFactor(ctx, badFactor)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, storage.ErrSecondFactorNotFound))
+
+	_ = client.Close()
+	err = be.UpdateSecondFactor(ctx, res)
+	assertFailOnDatabaseError(t, err)
+}
+
+func Test_RelationalBackend_SecondFactorSecretEncryption(t *testing.T) {
+	client := newClient(t)
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+
+	key := model.NewEncryptionKey()
+	be := NewRelationalBackend(client, WithSecretKey(key))
+
+	testAcct := createAccount(t, "test@example.com", "Test User")
+
+	err := be.CreateAccount(ctx, testAcct)
+	require.NoError(t, err)
+
+	f, err := account.NewTOTPFactor(testAcct.ID, "phone")
+	require.NoError(t, err)
+
+	err = be.StoreSecondFactor(ctx, f)
+	require.NoError(t, err)
+
+	// the secret isn't stored in cleartext
+
+	row, err := client.SecondFactor.Query().Only(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, row.Body.Secret)
+	assert.NotEmpty(t, row.Body.EncryptedSecret)
+
+	res, err := be.GetSecondFactor(ctx, f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, f.Secret, res.Secret)
+	assert.Empty(t, res.EncryptedSecret)
+
+	// updates keep the secret encrypted
+
+	res.LastUsedStep = 100
+	err = be.UpdateSecondFactor(ctx, res)
+	require.NoError(t, err)
+
+	row, err = client.SecondFactor.Query().Only(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, row.Body.Secret)
+
+	list, err := be.ListSecondFactors(ctx, testAcct.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(list))
+	assert.Equal(t, f.Secret, list[0].Secret)
+
+	// fail if the key isn't available
+
+	_, err = NewRelationalBackend(client).GetSecondFactor(ctx, f.ID)
+	require.Error(t, err)
+}
+
+func Test_RelationalBackend_GetSecondFactor(t *testing.T) {
+	client := newClient(t)
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+
+	be := NewRelationalBackend(client)
+
+	testAcct := createAccount(t, "test@example.com", "Test User")
+
+	err := be.CreateAccount(ctx, testAcct)
+	require.NoError(t, err)
+
+	f, _, err := account.NewBackupCodes(testAcct.ID, account.BackupCodeCount)
+	require.NoError(t, err)
+
+	err = be.StoreSecondFactor(ctx, f)
+	require.NoError(t, err)
+
+	// happy path
+
+	res, err := be.GetSecondFactor(ctx, f.ID)
+	require.NoError(t, err)
+	assert.Equal(t, f.BackupCodes, res.BackupCodes)
+
+	// fail if factor not found
+
+	_, err = be.GetSecondFactor(ctx, "bad-id")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, storage.ErrSecondFactorNotFound))
+
+	_ = client.Close()
+	_, err = be.GetSecondFactor(ctx, f.ID)
+	assertFailOnDatabaseError(t, err)
+}
+
+func Test_RelationalBackend_DeleteSecondFactor(t *testing.T) {
+	client := newClient(t)
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+
+	be := NewRelationalBackend(client)
+
+	testAcct := createAccount(t, "test@example.com", "Test User")
+
+	err := be.CreateAccount(ctx, testAcct)
+	require.NoError(t, err)
+
+	f, err := account.NewTOTPFactor(testAcct.ID, "phone")
+	require.NoError(t, err)
+
+	err = be.StoreSecondFactor(ctx, f)
+	require.NoError(t, err)
+
+	// happy path
+
+	err = be.DeleteSecondFactor(ctx, f.ID)
+	require.NoError(t, err)
+
+	list, err := be.ListSecondFactors(ctx, testAcct.ID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// fail if factor not found
+
+	err = be.DeleteSecondFactor(ctx, f.ID)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, storage.ErrSecondFactorNotFound))
+
+	_ = client.Close()
+	err = be.DeleteSecondFactor(ctx, f.ID)
+	assertFailOnDatabaseError(t, err)
+}
+
 func createAccount(t *testing.T, email, name string) *account.Account { //nolint: thelper
 	acctTemplate := &account.Account{
 		Email:        email,
@@ -1,8 +1,10 @@
 package rdb
 
 import (
+	"encoding/base64"
 	"errors"
 
+	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/sdk/cmdbase"
 	"github.com/piprate/metalocker/storage"
 	"github.com/piprate/metalocker/utils/jsonw"
@@ -19,11 +21,12 @@ const (
 	ParameterSyncSchema     = "sync_schema"
 	ParameterMigrationsPath = "migrations_path"
 	ParameterLogLevel       = "log_level"
+	ParameterSecretKey      = "secret_key"
 )
 
 func CreateIdentityBackend(params storage.Parameters, resolver cmdbase.ParameterResolver) (storage.IdentityBackend, error) {
 
-	databaseURL, err := readDatabaseURL(params, resolver)
+	databaseURL, err := ReadDatabaseURL(params, resolver)
 	if err != nil {
 		return nil, err
 	}
@@ -63,39 +66,82 @@ func CreateIdentityBackend(params storage.Parameters, resolver cmdbase.Parameter
 		}
 	}
 
-	return NewRelationalBackend(entClient), nil
+	var opts []Option
+	if params[ParameterSecretKey] != nil {
+		secretKey, err := readSecretKey(params, resolver)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithSecretKey(secretKey))
+	} else {
+		log.Warn().Msg("RDB backend parameter not defined: " + ParameterSecretKey +
+			". Second factor secrets will be stored in cleartext")
+	}
+
+	return NewRelationalBackend(entClient, opts...), nil
+}
+
+// ReadDatabaseURL reads the database URL from the 'url' parameter. The parameter
+// can be either a string or a secure parameter definition.
+func ReadDatabaseURL(params map[string]any, resolver cmdbase.ParameterResolver) (string, error) {
+
+	dbURL, err := readStringParameter(params, ParameterURL, resolver)
+	if err != nil {
+		return "", err
+	}
+
+	if dbURL == "" {
+		return "", errors.New("parameter not defined: " + ParameterURL +
+			". Can't start RDB backend")
+	}
+
+	return dbURL, nil
+}
+
+// readSecretKey reads the base64 encoded AES key for encrypting second factor
+// secrets from the 'secret_key' parameter. The parameter can be either a string
+// or a secure parameter definition.
+func readSecretKey(params map[string]any, resolver cmdbase.ParameterResolver) (*model.AESKey, error) {
+
+	val, err := readStringParameter(params, ParameterSecretKey, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes, err := base64.StdEncoding.DecodeString(val)
+	if err != nil || len(keyBytes) != model.KeySize {
+		return nil, errors.New("bad RDB backend parameter: " + ParameterSecretKey +
+			". Expected a base64 encoded 256-bit key")
+	}
+
+	return model.NewAESKey(keyBytes), nil
 }
 
-func readDatabaseURL(params map[string]any, resolver cmdbase.ParameterResolver) (string, error) {
+func readStringParameter(params map[string]any, name string, resolver cmdbase.ParameterResolver) (string, error) {
 
-	var dbURL string
+	var res string
 	var err error
-	url := params[ParameterURL]
+	param := params[name]
 	var isString bool
-	if dbURL, isString = url.(string); !isString {
+	if res, isString = param.(string); !isString {
 		if resolver != nil {
 			var b []byte
-			b, err = jsonw.Marshal(url)
+			b, err = jsonw.Marshal(param)
 			if err == nil {
-				var urlParam map[string]any
-				if err = jsonw.Unmarshal(b, &urlParam); err == nil {
-					dbURL, err = resolver.ResolveString(urlParam)
+				var paramDef map[string]any
+				if err = jsonw.Unmarshal(b, &paramDef); err == nil {
+					res, err = resolver.ResolveString(paramDef)
 				}
 			}
 		} else {
-			err = errors.New("wrong type of url parameter")
+			err = errors.New("wrong type of " + name + " parameter")
 		}
 	}
 
 	if err != nil {
-		log.Err(err).Msg("Error reading RDB backend parameter:" + ParameterURL)
-		return "", errors.New("can't read RDB backend parameter: " + ParameterURL)
-	}
-
-	if dbURL == "" {
-		return "", errors.New("parameter not defined: " + ParameterURL +
-			". Can't start RDB backend")
+		log.Err(err).Msg("Error reading RDB backend parameter:" + name)
+		return "", errors.New("can't read RDB backend parameter: " + name)
 	}
 
-	return dbURL, nil
+	return res, nil
 }
@@ -18,6 +18,22 @@ import (
 
 func NewEntClient(databaseURL string, logLevel zerolog.Level) (*ent.Client, error) {
 
+	sqlDB, dialectName, err := OpenDB(databaseURL, logLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	drv := entsql.OpenDB(dialectName, sqlDB)
+
+	entClient := ent.NewClient(ent.Driver(drv))
+
+	return entClient, nil
+}
+
+// OpenDB opens a connection pool for the given database URL. It returns
+// the pool and the name of the matching SQL dialect.
+func OpenDB(databaseURL string, logLevel zerolog.Level) (*sql.DB, string, error) {
+
 	if logLevel < 1 {
 		// set log level to None
 		logLevel = 1
@@ -25,15 +41,14 @@ func NewEntClient(databaseURL string, logLevel zerolog.Level) (*ent.Client, erro
 
 	schema, err := SchemeFromURL(databaseURL)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	var drv *entsql.Driver
 	switch schema {
 	case "postgres":
 		connConfig, err := pgx.ParseConfig(databaseURL)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		connConfig.Tracer = utils.NewZerologQueryTracer(logLevel)
@@ -41,24 +56,20 @@ func NewEntClient(databaseURL string, logLevel zerolog.Level) (*ent.Client, erro
 
 		sqlDB, err := sql.Open("pgx", connStr)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
-		drv = entsql.OpenDB(dialect.Postgres, sqlDB)
+		return sqlDB, dialect.Postgres, nil
 	case "sqlite3":
 		str := strings.ReplaceAll(databaseURL, "sqlite3://", "file:")
 		sqlDB, err := sql.Open("sqlite3", str)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
-		drv = entsql.OpenDB(dialect.SQLite, sqlDB)
+		return sqlDB, dialect.SQLite, nil
 	default:
-		return nil, fmt.Errorf("unsupported database schema: %s", schema)
+		return nil, "", fmt.Errorf("unsupported database schema: %s", schema)
 	}
-
-	entClient := ent.NewClient(ent.Driver(drv))
-
-	return entClient, nil
 }
 
 // SchemeFromURL returns the scheme from a URL string
@@ -51,6 +51,8 @@ type AccountEdges struct {
 	RecoveryCodes []*RecoveryCode `json:"recovery_codes,omitempty"`
 	// AccessKeys holds the value of the access_keys edge.
 	AccessKeys []*AccessKey `json:"access_keys,omitempty"`
+	// SecondFactors holds the value of the second_factors edge.
+	SecondFactors []*SecondFactor `json:"second_factors,omitempty"`
 	// Identities holds the value of the identities edge.
 	Identities []*Identity `json:"identities,omitempty"`
 	// Lockers holds the value of the lockers edge.
@@ -59,7 +61,7 @@ type AccountEdges struct {
 	Properties []*Property `json:"properties,omitempty"`
 	// loadedTypes holds the information for reporting if a
 	// type was loaded (or requested) in eager-loading or not.
-	loadedTypes [5]bool
+	loadedTypes [6]bool
 }
 
 // RecoveryCodesOrErr returns the RecoveryCodes value or an error if the edge
@@ -80,10 +82,19 @@ func (e AccountEdges) AccessKeysOrErr() ([]*AccessKey, error) {
 	return nil, &NotLoadedError{edge: "access_keys"}
 }
 
+// SecondFactorsOrErr returns the SecondFactors value or an error if the edge
+// was not loaded in eager-loading.
+func (e AccountEdges) SecondFactorsOrErr() ([]*SecondFactor, error) {
+	if e.loadedTypes[2] {
+		return e.SecondFactors, nil
+	}
+	return nil, &NotLoadedError{edge: "second_factors"}
+}
+
 // IdentitiesOrErr returns the Identities value or an error if the edge
 // was not loaded in eager-loading.
 func (e AccountEdges) IdentitiesOrErr() ([]*Identity, error) {
-	if e.loadedTypes[2] {
+	if e.loadedTypes[3] {
 		return e.Identities, nil
 	}
 	return nil, &NotLoadedError{edge: "identities"}
@@ -92,7 +103,7 @@ func (e AccountEdges) IdentitiesOrErr() ([]*Identity, error) {
 // LockersOrErr returns the Lockers value or an error if the edge
 // was not loaded in eager-loading.
 func (e AccountEdges) LockersOrErr() ([]*Locker, error) {
-	if e.loadedTypes[3] {
+	if e.loadedTypes[4] {
 		return e.Lockers, nil
 	}
 	return nil, &NotLoadedError{edge: "lockers"}
@@ -101,7 +112,7 @@ func (e AccountEdges) LockersOrErr() ([]*Locker, error) {
 // PropertiesOrErr returns the Properties value or an error if the edge
 // was not loaded in eager-loading.
 func (e AccountEdges) PropertiesOrErr() ([]*Property, error) {
-	if e.loadedTypes[4] {
+	if e.loadedTypes[5] {
 		return e.Properties, nil
 	}
 	return nil, &NotLoadedError{edge: "properties"}
@@ -186,6 +197,11 @@ func (a *Account) QueryAccessKeys() *AccessKeyQuery {
 	return NewAccountClient(a.config).QueryAccessKeys(a)
 }
 
+// QuerySecondFactors queries the "second_factors" edge of the Account entity.
+func (a *Account) QuerySecondFactors() *SecondFactorQuery {
+	return NewAccountClient(a.config).QuerySecondFactors(a)
+}
+
 // QueryIdentities queries the "identities" edge of the Account entity.
 func (a *Account) QueryIdentities() *IdentityQuery {
 	return NewAccountClient(a.config).QueryIdentities(a)
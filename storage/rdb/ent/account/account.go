@@ -33,6 +33,8 @@ const (
 	EdgeRecoveryCodes = "recovery_codes"
 	// EdgeAccessKeys holds the string denoting the access_keys edge name in mutations.
 	EdgeAccessKeys = "access_keys"
+	// EdgeSecondFactors holds the string denoting the second_factors edge name in mutations.
+	EdgeSecondFactors = "second_factors"
 	// EdgeIdentities holds the string denoting the identities edge name in mutations.
 	EdgeIdentities = "identities"
 	// EdgeLockers holds the string denoting the lockers edge name in mutations.
@@ -55,6 +57,13 @@ const (
 	AccessKeysInverseTable = "access_keys"
 	// AccessKeysColumn is the table column denoting the access_keys relation/edge.
 	AccessKeysColumn = "account"
+	// SecondFactorsTable is the table that holds the second_factors relation/edge.
+	SecondFactorsTable = "second_factors"
+	// SecondFactorsInverseTable is the table name for the SecondFactor entity.
+	// It exists in this package in order to avoid circular dependency with the "secondfactor" package.
+	SecondFactorsInverseTable = "second_factors"
+	// SecondFactorsColumn is the table column denoting the second_factors relation/edge.
+	SecondFactorsColumn = "account"
 	// IdentitiesTable is the table that holds the identities relation/edge.
 	IdentitiesTable = "identities"
 	// IdentitiesInverseTable is the table name for the Identity entity.
@@ -419,6 +419,33 @@ func HasAccessKeysWith(preds ...predicate.AccessKey) predicate.Account {
 	})
 }
 
+// HasSecondFactors applies the HasEdge predicate on the "second_factors" edge.
+func HasSecondFactors() predicate.Account {
+	return predicate.Account(func(s *sql.Selector) {
+		step := sqlgraph.NewStep(
+			sqlgraph.From(Table, FieldID),
+			sqlgraph.Edge(sqlgraph.O2M, false, SecondFactorsTable, SecondFactorsColumn),
+		)
+		sqlgraph.HasNeighbors(s, step)
+	})
+}
+
+// HasSecondFactorsWith applies the HasEdge predicate on the "second_factors" edge with a given conditions (other predicates).
+func HasSecondFactorsWith(preds ...predicate.SecondFactor) predicate.Account {
+	return predicate.Account(func(s *sql.Selector) {
+		step := sqlgraph.NewStep(
+			sqlgraph.From(Table, FieldID),
+			sqlgraph.To(SecondFactorsInverseTable, FieldID),
+			sqlgraph.Edge(sqlgraph.O2M, false, SecondFactorsTable, SecondFactorsColumn),
+		)
+		sqlgraph.HasNeighborsWith(s, step, func(s *sql.Selector) {
+			for _, p := range preds {
+				p(s)
+			}
+		})
+	})
+}
+
 // HasIdentities applies the HasEdge predicate on the "identities" edge.
 func HasIdentities() predicate.Account {
 	return predicate.Account(func(s *sql.Selector) {
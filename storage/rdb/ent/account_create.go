@@ -24,11 +24,13 @@ import (
 	"github.com/piprate/metalocker/model/account"
 
 	"github.com/piprate/metalocker/storage/rdb/ent/accesskey"
+
 	entaccount "github.com/piprate/metalocker/storage/rdb/ent/account"
 	"github.com/piprate/metalocker/storage/rdb/ent/identity"
 	"github.com/piprate/metalocker/storage/rdb/ent/locker"
 	"github.com/piprate/metalocker/storage/rdb/ent/property"
 	"github.com/piprate/metalocker/storage/rdb/ent/recoverycode"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
 )
 
 // AccountCreate is the builder for creating a Account entity.
@@ -114,6 +116,21 @@ func (ac *AccountCreate) AddAccessKeys(a ...*AccessKey) *AccountCreate {
 	return ac.AddAccessKeyIDs(ids...)
 }
 
+// AddSecondFactorIDs adds the "second_factors" edge to the SecondFactor entity by IDs.
+func (ac *AccountCreate) AddSecondFactorIDs(ids ...int) *AccountCreate {
+	ac.mutation.AddSecondFactorIDs(ids...)
+	return ac
+}
+
+// AddSecondFactors adds the "second_factors" edges to the SecondFactor entity.
+func (ac *AccountCreate) AddSecondFactors(s ...*SecondFactor) *AccountCreate {
+	ids := make([]int, len(s))
+	for i := range s {
+		ids[i] = s[i].ID
+	}
+	return ac.AddSecondFactorIDs(ids...)
+}
+
 // AddIdentityIDs adds the "identities" edge to the Identity entity by IDs.
 func (ac *AccountCreate) AddIdentityIDs(ids ...int) *AccountCreate {
 	ac.mutation.AddIdentityIDs(ids...)
@@ -292,6 +309,25 @@ func (ac *AccountCreate) createSpec() (*Account, *sqlgraph.CreateSpec) {
 		}
 		_spec.Edges = append(_spec.Edges, edge)
 	}
+	if nodes := ac.mutation.SecondFactorsIDs(); len(nodes) > 0 {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.O2M,
+			Inverse: false,
+			Table:   entaccount.SecondFactorsTable,
+			Columns: []string{entaccount.SecondFactorsColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: secondfactor.FieldID,
+				},
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_spec.Edges = append(_spec.Edges, edge)
+	}
 	if nodes := ac.mutation.IdentitiesIDs(); len(nodes) > 0 {
 		edge := &sqlgraph.EdgeSpec{
 			Rel:     sqlgraph.O2M,
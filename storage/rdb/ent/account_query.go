@@ -26,11 +26,13 @@ import (
 	"github.com/piprate/metalocker/storage/rdb/ent/predicate"
 
 	"github.com/piprate/metalocker/storage/rdb/ent/accesskey"
+
 	entaccount "github.com/piprate/metalocker/storage/rdb/ent/account"
 	"github.com/piprate/metalocker/storage/rdb/ent/identity"
 	"github.com/piprate/metalocker/storage/rdb/ent/locker"
 	"github.com/piprate/metalocker/storage/rdb/ent/property"
 	"github.com/piprate/metalocker/storage/rdb/ent/recoverycode"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
 )
 
 // AccountQuery is the builder for querying Account entities.
@@ -42,6 +44,7 @@ type AccountQuery struct {
 	predicates        []predicate.Account
 	withRecoveryCodes *RecoveryCodeQuery
 	withAccessKeys    *AccessKeyQuery
+	withSecondFactors *SecondFactorQuery
 	withIdentities    *IdentityQuery
 	withLockers       *LockerQuery
 	withProperties    *PropertyQuery
@@ -125,6 +128,28 @@ func (aq *AccountQuery) QueryAccessKeys() *AccessKeyQuery {
 	return query
 }
 
+// QuerySecondFactors chains the current query on the "second_factors" edge.
+func (aq *AccountQuery) QuerySecondFactors() *SecondFactorQuery {
+	query := (&SecondFactorClient{config: aq.config}).Query()
+	query.path = func(ctx context.Context) (fromU *sql.Selector, err error) {
+		if err := aq.prepareQuery(ctx); err != nil {
+			return nil, err
+		}
+		selector := aq.sqlQuery(ctx)
+		if err := selector.Err(); err != nil {
+			return nil, err
+		}
+		step := sqlgraph.NewStep(
+			sqlgraph.From(entaccount.Table, entaccount.FieldID, selector),
+			sqlgraph.To(secondfactor.Table, secondfactor.FieldID),
+			sqlgraph.Edge(sqlgraph.O2M, false, entaccount.SecondFactorsTable, entaccount.SecondFactorsColumn),
+		)
+		fromU = sqlgraph.SetNeighbors(aq.driver.Dialect(), step)
+		return fromU, nil
+	}
+	return query
+}
+
 // QueryIdentities chains the current query on the "identities" edge.
 func (aq *AccountQuery) QueryIdentities() *IdentityQuery {
 	query := (&IdentityClient{config: aq.config}).Query()
@@ -383,6 +408,7 @@ func (aq *AccountQuery) Clone() *AccountQuery {
 		predicates:        append([]predicate.Account{}, aq.predicates...),
 		withRecoveryCodes: aq.withRecoveryCodes.Clone(),
 		withAccessKeys:    aq.withAccessKeys.Clone(),
+		withSecondFactors: aq.withSecondFactors.Clone(),
 		withIdentities:    aq.withIdentities.Clone(),
 		withLockers:       aq.withLockers.Clone(),
 		withProperties:    aq.withProperties.Clone(),
@@ -414,6 +440,17 @@ func (aq *AccountQuery) WithAccessKeys(opts ...func(*AccessKeyQuery)) *AccountQu
 	return aq
 }
 
+// WithSecondFactors tells the query-builder to eager-load the nodes that are connected to
+// the "second_factors" edge. The optional arguments are used to configure the query builder of the edge.
+func (aq *AccountQuery) WithSecondFactors(opts ...func(*SecondFactorQuery)) *AccountQuery {
+	query := (&SecondFactorClient{config: aq.config}).Query()
+	for _, opt := range opts {
+		opt(query)
+	}
+	aq.withSecondFactors = query
+	return aq
+}
+
 // WithIdentities tells the query-builder to eager-load the nodes that are connected to
 // the "identities" edge. The optional arguments are used to configure the query builder of the edge.
 func (aq *AccountQuery) WithIdentities(opts ...func(*IdentityQuery)) *AccountQuery {
@@ -525,9 +562,10 @@ func (aq *AccountQuery) sqlAll(ctx context.Context, hooks ...queryHook) ([]*Acco
 	var (
 		nodes       = []*Account{}
 		_spec       = aq.querySpec()
-		loadedTypes = [5]bool{
+		loadedTypes = [6]bool{
 			aq.withRecoveryCodes != nil,
 			aq.withAccessKeys != nil,
+			aq.withSecondFactors != nil,
 			aq.withIdentities != nil,
 			aq.withLockers != nil,
 			aq.withProperties != nil,
@@ -565,6 +603,13 @@ func (aq *AccountQuery) sqlAll(ctx context.Context, hooks ...queryHook) ([]*Acco
 			return nil, err
 		}
 	}
+	if query := aq.withSecondFactors; query != nil {
+		if err := aq.loadSecondFactors(ctx, query, nodes,
+			func(n *Account) { n.Edges.SecondFactors = []*SecondFactor{} },
+			func(n *Account, e *SecondFactor) { n.Edges.SecondFactors = append(n.Edges.SecondFactors, e) }); err != nil {
+			return nil, err
+		}
+	}
 	if query := aq.withIdentities; query != nil {
 		if err := aq.loadIdentities(ctx, query, nodes,
 			func(n *Account) { n.Edges.Identities = []*Identity{} },
@@ -651,6 +696,37 @@ func (aq *AccountQuery) loadAccessKeys(ctx context.Context, query *AccessKeyQuer
 	}
 	return nil
 }
+func (aq *AccountQuery) loadSecondFactors(ctx context.Context, query *SecondFactorQuery, nodes []*Account, init func(*Account), assign func(*Account, *SecondFactor)) error {
+	fks := make([]driver.Value, 0, len(nodes))
+	nodeids := make(map[int]*Account)
+	for i := range nodes {
+		fks = append(fks, nodes[i].ID)
+		nodeids[nodes[i].ID] = nodes[i]
+		if init != nil {
+			init(nodes[i])
+		}
+	}
+	query.withFKs = true
+	query.Where(predicate.SecondFactor(func(s *sql.Selector) {
+		s.Where(sql.InValues(entaccount.SecondFactorsColumn, fks...))
+	}))
+	neighbors, err := query.All(ctx)
+	if err != nil {
+		return err
+	}
+	for _, n := range neighbors {
+		fk := n.account
+		if fk == nil {
+			return fmt.Errorf(`foreign-key "account" is nil for node %v`, n.ID)
+		}
+		node, ok := nodeids[*fk]
+		if !ok {
+			return fmt.Errorf(`unexpected foreign-key "account" returned %v for node %v`, *fk, n.ID)
+		}
+		assign(node, n)
+	}
+	return nil
+}
 func (aq *AccountQuery) loadIdentities(ctx context.Context, query *IdentityQuery, nodes []*Account, init func(*Account), assign func(*Account, *Identity)) error {
 	fks := make([]driver.Value, 0, len(nodes))
 	nodeids := make(map[int]*Account)
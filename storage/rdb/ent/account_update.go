@@ -26,11 +26,13 @@ import (
 	"github.com/piprate/metalocker/storage/rdb/ent/predicate"
 
 	"github.com/piprate/metalocker/storage/rdb/ent/accesskey"
+
 	entaccount "github.com/piprate/metalocker/storage/rdb/ent/account"
 	"github.com/piprate/metalocker/storage/rdb/ent/identity"
 	"github.com/piprate/metalocker/storage/rdb/ent/locker"
 	"github.com/piprate/metalocker/storage/rdb/ent/property"
 	"github.com/piprate/metalocker/storage/rdb/ent/recoverycode"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
 )
 
 // AccountUpdate is the builder for updating Account entities.
@@ -134,6 +136,21 @@ func (au *AccountUpdate) AddAccessKeys(a ...*AccessKey) *AccountUpdate {
 	return au.AddAccessKeyIDs(ids...)
 }
 
+// AddSecondFactorIDs adds the "second_factors" edge to the SecondFactor entity by IDs.
+func (au *AccountUpdate) AddSecondFactorIDs(ids ...int) *AccountUpdate {
+	au.mutation.AddSecondFactorIDs(ids...)
+	return au
+}
+
+// AddSecondFactors adds the "second_factors" edges to the SecondFactor entity.
+func (au *AccountUpdate) AddSecondFactors(s ...*SecondFactor) *AccountUpdate {
+	ids := make([]int, len(s))
+	for i := range s {
+		ids[i] = s[i].ID
+	}
+	return au.AddSecondFactorIDs(ids...)
+}
+
 // AddIdentityIDs adds the "identities" edge to the Identity entity by IDs.
 func (au *AccountUpdate) AddIdentityIDs(ids ...int) *AccountUpdate {
 	au.mutation.AddIdentityIDs(ids...)
@@ -226,6 +243,27 @@ func (au *AccountUpdate) RemoveAccessKeys(a ...*AccessKey) *AccountUpdate {
 	return au.RemoveAccessKeyIDs(ids...)
 }
 
+// ClearSecondFactors clears all "second_factors" edges to the SecondFactor entity.
+func (au *AccountUpdate) ClearSecondFactors() *AccountUpdate {
+	au.mutation.ClearSecondFactors()
+	return au
+}
+
+// RemoveSecondFactorIDs removes the "second_factors" edge to SecondFactor entities by IDs.
+func (au *AccountUpdate) RemoveSecondFactorIDs(ids ...int) *AccountUpdate {
+	au.mutation.RemoveSecondFactorIDs(ids...)
+	return au
+}
+
+// RemoveSecondFactors removes "second_factors" edges to SecondFactor entities.
+func (au *AccountUpdate) RemoveSecondFactors(s ...*SecondFactor) *AccountUpdate {
+	ids := make([]int, len(s))
+	for i := range s {
+		ids[i] = s[i].ID
+	}
+	return au.RemoveSecondFactorIDs(ids...)
+}
+
 // ClearIdentities clears all "identities" edges to the Identity entity.
 func (au *AccountUpdate) ClearIdentities() *AccountUpdate {
 	au.mutation.ClearIdentities()
@@ -463,6 +501,60 @@ func (au *AccountUpdate) sqlSave(ctx context.Context) (n int, err error) {
 		}
 		_spec.Edges.Add = append(_spec.Edges.Add, edge)
 	}
+	if au.mutation.SecondFactorsCleared() {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.O2M,
+			Inverse: false,
+			Table:   entaccount.SecondFactorsTable,
+			Columns: []string{entaccount.SecondFactorsColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: secondfactor.FieldID,
+				},
+			},
+		}
+		_spec.Edges.Clear = append(_spec.Edges.Clear, edge)
+	}
+	if nodes := au.mutation.RemovedSecondFactorsIDs(); len(nodes) > 0 && !au.mutation.SecondFactorsCleared() {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.O2M,
+			Inverse: false,
+			Table:   entaccount.SecondFactorsTable,
+			Columns: []string{entaccount.SecondFactorsColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: secondfactor.FieldID,
+				},
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_spec.Edges.Clear = append(_spec.Edges.Clear, edge)
+	}
+	if nodes := au.mutation.SecondFactorsIDs(); len(nodes) > 0 {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.O2M,
+			Inverse: false,
+			Table:   entaccount.SecondFactorsTable,
+			Columns: []string{entaccount.SecondFactorsColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: secondfactor.FieldID,
+				},
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_spec.Edges.Add = append(_spec.Edges.Add, edge)
+	}
 	if au.mutation.IdentitiesCleared() {
 		edge := &sqlgraph.EdgeSpec{
 			Rel:     sqlgraph.O2M,
@@ -733,6 +825,21 @@ func (auo *AccountUpdateOne) AddAccessKeys(a ...*AccessKey) *AccountUpdateOne {
 	return auo.AddAccessKeyIDs(ids...)
 }
 
+// AddSecondFactorIDs adds the "second_factors" edge to the SecondFactor entity by IDs.
+func (auo *AccountUpdateOne) AddSecondFactorIDs(ids ...int) *AccountUpdateOne {
+	auo.mutation.AddSecondFactorIDs(ids...)
+	return auo
+}
+
+// AddSecondFactors adds the "second_factors" edges to the SecondFactor entity.
+func (auo *AccountUpdateOne) AddSecondFactors(s ...*SecondFactor) *AccountUpdateOne {
+	ids := make([]int, len(s))
+	for i := range s {
+		ids[i] = s[i].ID
+	}
+	return auo.AddSecondFactorIDs(ids...)
+}
+
 // AddIdentityIDs adds the "identities" edge to the Identity entity by IDs.
 func (auo *AccountUpdateOne) AddIdentityIDs(ids ...int) *AccountUpdateOne {
 	auo.mutation.AddIdentityIDs(ids...)
@@ -825,6 +932,27 @@ func (auo *AccountUpdateOne) RemoveAccessKeys(a ...*AccessKey) *AccountUpdateOne
 	return auo.RemoveAccessKeyIDs(ids...)
 }
 
+// ClearSecondFactors clears all "second_factors" edges to the SecondFactor entity.
+func (auo *AccountUpdateOne) ClearSecondFactors() *AccountUpdateOne {
+	auo.mutation.ClearSecondFactors()
+	return auo
+}
+
+// RemoveSecondFactorIDs removes the "second_factors" edge to SecondFactor entities by IDs.
+func (auo *AccountUpdateOne) RemoveSecondFactorIDs(ids ...int) *AccountUpdateOne {
+	auo.mutation.RemoveSecondFactorIDs(ids...)
+	return auo
+}
+
+// RemoveSecondFactors removes "second_factors" edges to SecondFactor entities.
+func (auo *AccountUpdateOne) RemoveSecondFactors(s ...*SecondFactor) *AccountUpdateOne {
+	ids := make([]int, len(s))
+	for i := range s {
+		ids[i] = s[i].ID
+	}
+	return auo.RemoveSecondFactorIDs(ids...)
+}
+
 // ClearIdentities clears all "identities" edges to the Identity entity.
 func (auo *AccountUpdateOne) ClearIdentities() *AccountUpdateOne {
 	auo.mutation.ClearIdentities()
@@ -1086,6 +1214,60 @@ func (auo *AccountUpdateOne) sqlSave(ctx context.Context) (_node *Account, err e
 		}
 		_spec.Edges.Add = append(_spec.Edges.Add, edge)
 	}
+	if auo.mutation.SecondFactorsCleared() {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.O2M,
+			Inverse: false,
+			Table:   entaccount.SecondFactorsTable,
+			Columns: []string{entaccount.SecondFactorsColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: secondfactor.FieldID,
+				},
+			},
+		}
+		_spec.Edges.Clear = append(_spec.Edges.Clear, edge)
+	}
+	if nodes := auo.mutation.RemovedSecondFactorsIDs(); len(nodes) > 0 && !auo.mutation.SecondFactorsCleared() {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.O2M,
+			Inverse: false,
+			Table:   entaccount.SecondFactorsTable,
+			Columns: []string{entaccount.SecondFactorsColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: secondfactor.FieldID,
+				},
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_spec.Edges.Clear = append(_spec.Edges.Clear, edge)
+	}
+	if nodes := auo.mutation.SecondFactorsIDs(); len(nodes) > 0 {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.O2M,
+			Inverse: false,
+			Table:   entaccount.SecondFactorsTable,
+			Columns: []string{entaccount.SecondFactorsColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: secondfactor.FieldID,
+				},
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_spec.Edges.Add = append(_spec.Edges.Add, edge)
+	}
 	if auo.mutation.IdentitiesCleared() {
 		edge := &sqlgraph.EdgeSpec{
 			Rel:     sqlgraph.O2M,
@@ -46,6 +46,7 @@ type (
 		Locker       []ent.Hook
 		Property     []ent.Hook
 		RecoveryCode []ent.Hook
+		SecondFactor []ent.Hook
 	}
 	inters struct {
 		AccessKey    []ent.Interceptor
@@ -55,6 +56,7 @@ type (
 		Locker       []ent.Interceptor
 		Property     []ent.Interceptor
 		RecoveryCode []ent.Interceptor
+		SecondFactor []ent.Interceptor
 	}
 )
 
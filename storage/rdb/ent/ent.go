@@ -31,6 +31,7 @@ import (
 	"github.com/piprate/metalocker/storage/rdb/ent/locker"
 	"github.com/piprate/metalocker/storage/rdb/ent/property"
 	"github.com/piprate/metalocker/storage/rdb/ent/recoverycode"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
 )
 
 // ent aliases to avoid import conflicts in user's code.
@@ -65,6 +66,7 @@ func columnChecker(table string) func(string) error {
 		locker.Table:       locker.ValidColumn,
 		property.Table:     property.ValidColumn,
 		recoverycode.Table: recoverycode.ValidColumn,
+		secondfactor.Table: secondfactor.ValidColumn,
 	}
 	check, ok := checks[table]
 	if !ok {
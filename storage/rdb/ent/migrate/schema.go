@@ -223,6 +223,34 @@ var (
 			},
 		},
 	}
+	// SecondFactorsColumns holds the columns for the "second_factors" table.
+	SecondFactorsColumns = []*schema.Column{
+		{Name: "id", Type: field.TypeInt, Increment: true},
+		{Name: "fid", Type: field.TypeString, Unique: true},
+		{Name: "body", Type: field.TypeJSON},
+		{Name: "account", Type: field.TypeInt, Nullable: true},
+	}
+	// SecondFactorsTable holds the schema information for the "second_factors" table.
+	SecondFactorsTable = &schema.Table{
+		Name:       "second_factors",
+		Columns:    SecondFactorsColumns,
+		PrimaryKey: []*schema.Column{SecondFactorsColumns[0]},
+		ForeignKeys: []*schema.ForeignKey{
+			{
+				Symbol:     "second_factors_accounts_second_factors",
+				Columns:    []*schema.Column{SecondFactorsColumns[3]},
+				RefColumns: []*schema.Column{AccountsColumns[0]},
+				OnDelete:   schema.SetNull,
+			},
+		},
+		Indexes: []*schema.Index{
+			{
+				Name:    "secondfactor_fid",
+				Unique:  true,
+				Columns: []*schema.Column{SecondFactorsColumns[1]},
+			},
+		},
+	}
 	// Tables holds all the tables in the schema.
 	Tables = []*schema.Table{
 		AccessKeysTable,
@@ -232,6 +260,7 @@ var (
 		LockersTable,
 		PropertiesTable,
 		RecoveryCodesTable,
+		SecondFactorsTable,
 	}
 )
 
@@ -244,4 +273,5 @@ func init() {
 	LockersTable.ForeignKeys[0].RefTable = AccountsTable
 	PropertiesTable.ForeignKeys[0].RefTable = AccountsTable
 	RecoveryCodesTable.ForeignKeys[0].RefTable = AccountsTable
+	SecondFactorsTable.ForeignKeys[0].RefTable = AccountsTable
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/model/account"
 	"github.com/piprate/metalocker/storage/rdb/ent/accesskey"
+
 	entaccount "github.com/piprate/metalocker/storage/rdb/ent/account"
 	"github.com/piprate/metalocker/storage/rdb/ent/did"
 	"github.com/piprate/metalocker/storage/rdb/ent/identity"
@@ -31,6 +32,7 @@ import (
 	"github.com/piprate/metalocker/storage/rdb/ent/predicate"
 	"github.com/piprate/metalocker/storage/rdb/ent/property"
 	"github.com/piprate/metalocker/storage/rdb/ent/recoverycode"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
 
 	"entgo.io/ent"
 	"entgo.io/ent/dialect/sql"
@@ -52,6 +54,7 @@ const (
 	TypeLocker       = "Locker"
 	TypeProperty     = "Property"
 	TypeRecoveryCode = "RecoveryCode"
+	TypeSecondFactor = "SecondFactor"
 )
 
 // AccessKeyMutation represents an operation that mutates the AccessKey nodes in the graph.
@@ -519,6 +522,9 @@ type AccountMutation struct {
 	access_keys           map[int]struct{}
 	removedaccess_keys    map[int]struct{}
 	clearedaccess_keys    bool
+	second_factors        map[int]struct{}
+	removedsecond_factors map[int]struct{}
+	clearedsecond_factors bool
 	identities            map[int]struct{}
 	removedidentities     map[int]struct{}
 	clearedidentities     bool
@@ -945,6 +951,60 @@ func (m *AccountMutation) ResetAccessKeys() {
 	m.removedaccess_keys = nil
 }
 
+// AddSecondFactorIDs adds the "second_factors" edge to the SecondFactor entity by ids.
+func (m *AccountMutation) AddSecondFactorIDs(ids ...int) {
+	if m.second_factors == nil {
+		m.second_factors = make(map[int]struct{})
+	}
+	for i := range ids {
+		m.second_factors[ids[i]] = struct{}{}
+	}
+}
+
+// ClearSecondFactors clears the "second_factors" edge to the SecondFactor entity.
+func (m *AccountMutation) ClearSecondFactors() {
+	m.clearedsecond_factors = true
+}
+
+// SecondFactorsCleared reports if the "second_factors" edge to the SecondFactor entity was cleared.
+func (m *AccountMutation) SecondFactorsCleared() bool {
+	return m.clearedsecond_factors
+}
+
+// RemoveSecondFactorIDs removes the "second_factors" edge to the SecondFactor entity by IDs.
+func (m *AccountMutation) RemoveSecondFactorIDs(ids ...int) {
+	if m.removedsecond_factors == nil {
+		m.removedsecond_factors = make(map[int]struct{})
+	}
+	for i := range ids {
+		delete(m.second_factors, ids[i])
+		m.removedsecond_factors[ids[i]] = struct{}{}
+	}
+}
+
+// RemovedSecondFactors returns the removed IDs of the "second_factors" edge to the SecondFactor entity.
+func (m *AccountMutation) RemovedSecondFactorsIDs() (ids []int) {
+	for id := range m.removedsecond_factors {
+		ids = append(ids, id)
+	}
+	return
+}
+
+// SecondFactorsIDs returns the "second_factors" edge IDs in the mutation.
+func (m *AccountMutation) SecondFactorsIDs() (ids []int) {
+	for id := range m.second_factors {
+		ids = append(ids, id)
+	}
+	return
+}
+
+// ResetSecondFactors resets all changes to the "second_factors" edge.
+func (m *AccountMutation) ResetSecondFactors() {
+	m.second_factors = nil
+	m.clearedsecond_factors = false
+	m.removedsecond_factors = nil
+}
+
 // AddIdentityIDs adds the "identities" edge to the Identity entity by ids.
 func (m *AccountMutation) AddIdentityIDs(ids ...int) {
 	if m.identities == nil {
@@ -1323,13 +1383,16 @@ func (m *AccountMutation) ResetField(name string) error {
 
 // AddedEdges returns all edge names that were set/added in this mutation.
 func (m *AccountMutation) AddedEdges() []string {
-	edges := make([]string, 0, 5)
+	edges := make([]string, 0, 6)
 	if m.recovery_codes != nil {
 		edges = append(edges, entaccount.EdgeRecoveryCodes)
 	}
 	if m.access_keys != nil {
 		edges = append(edges, entaccount.EdgeAccessKeys)
 	}
+	if m.second_factors != nil {
+		edges = append(edges, entaccount.EdgeSecondFactors)
+	}
 	if m.identities != nil {
 		edges = append(edges, entaccount.EdgeIdentities)
 	}
@@ -1358,6 +1421,12 @@ func (m *AccountMutation) AddedIDs(name string) []ent.Value {
 			ids = append(ids, id)
 		}
 		return ids
+	case entaccount.EdgeSecondFactors:
+		ids := make([]ent.Value, 0, len(m.second_factors))
+		for id := range m.second_factors {
+			ids = append(ids, id)
+		}
+		return ids
 	case entaccount.EdgeIdentities:
 		ids := make([]ent.Value, 0, len(m.identities))
 		for id := range m.identities {
@@ -1382,13 +1451,16 @@ func (m *AccountMutation) AddedIDs(name string) []ent.Value {
 
 // RemovedEdges returns all edge names that were removed in this mutation.
 func (m *AccountMutation) RemovedEdges() []string {
-	edges := make([]string, 0, 5)
+	edges := make([]string, 0, 6)
 	if m.removedrecovery_codes != nil {
 		edges = append(edges, entaccount.EdgeRecoveryCodes)
 	}
 	if m.removedaccess_keys != nil {
 		edges = append(edges, entaccount.EdgeAccessKeys)
 	}
+	if m.removedsecond_factors != nil {
+		edges = append(edges, entaccount.EdgeSecondFactors)
+	}
 	if m.removedidentities != nil {
 		edges = append(edges, entaccount.EdgeIdentities)
 	}
@@ -1417,6 +1489,12 @@ func (m *AccountMutation) RemovedIDs(name string) []ent.Value {
 			ids = append(ids, id)
 		}
 		return ids
+	case entaccount.EdgeSecondFactors:
+		ids := make([]ent.Value, 0, len(m.removedsecond_factors))
+		for id := range m.removedsecond_factors {
+			ids = append(ids, id)
+		}
+		return ids
 	case entaccount.EdgeIdentities:
 		ids := make([]ent.Value, 0, len(m.removedidentities))
 		for id := range m.removedidentities {
@@ -1441,13 +1519,16 @@ func (m *AccountMutation) RemovedIDs(name string) []ent.Value {
 
 // ClearedEdges returns all edge names that were cleared in this mutation.
 func (m *AccountMutation) ClearedEdges() []string {
-	edges := make([]string, 0, 5)
+	edges := make([]string, 0, 6)
 	if m.clearedrecovery_codes {
 		edges = append(edges, entaccount.EdgeRecoveryCodes)
 	}
 	if m.clearedaccess_keys {
 		edges = append(edges, entaccount.EdgeAccessKeys)
 	}
+	if m.clearedsecond_factors {
+		edges = append(edges, entaccount.EdgeSecondFactors)
+	}
 	if m.clearedidentities {
 		edges = append(edges, entaccount.EdgeIdentities)
 	}
@@ -1468,6 +1549,8 @@ func (m *AccountMutation) EdgeCleared(name string) bool {
 		return m.clearedrecovery_codes
 	case entaccount.EdgeAccessKeys:
 		return m.clearedaccess_keys
+	case entaccount.EdgeSecondFactors:
+		return m.clearedsecond_factors
 	case entaccount.EdgeIdentities:
 		return m.clearedidentities
 	case entaccount.EdgeLockers:
@@ -1496,6 +1579,9 @@ func (m *AccountMutation) ResetEdge(name string) error {
 	case entaccount.EdgeAccessKeys:
 		m.ResetAccessKeys()
 		return nil
+	case entaccount.EdgeSecondFactors:
+		m.ResetSecondFactors()
+		return nil
 	case entaccount.EdgeIdentities:
 		m.ResetIdentities()
 		return nil
@@ -4130,3 +4216,450 @@ func (m *RecoveryCodeMutation) ResetEdge(name string) error {
 	}
 	return fmt.Errorf("unknown RecoveryCode edge %s", name)
 }
+
+// SecondFactorMutation represents an operation that mutates the SecondFactor nodes in the graph.
+type SecondFactorMutation struct {
+	config
+	op             Op
+	typ            string
+	id             *int
+	fid            *string
+	body           **account.SecondFactor
+	clearedFields  map[string]struct{}
+	account        *int
+	clearedaccount bool
+	done           bool
+	oldValue       func(context.Context) (*SecondFactor, error)
+	predicates     []predicate.SecondFactor
+}
+
+var _ ent.Mutation = (*SecondFactorMutation)(nil)
+
+// secondfactorOption allows management of the mutation configuration using functional options.
+type secondfactorOption func(*SecondFactorMutation)
+
+// newSecondFactorMutation creates new mutation for the SecondFactor entity.
+func newSecondFactorMutation(c config, op Op, opts ...secondfactorOption) *SecondFactorMutation {
+	m := &SecondFactorMutation{
+		config:        c,
+		op:            op,
+		typ:           TypeSecondFactor,
+		clearedFields: make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// withSecondFactorID sets the ID field of the mutation.
+func withSecondFactorID(id int) secondfactorOption {
+	return func(m *SecondFactorMutation) {
+		var (
+			err   error
+			once  sync.Once
+			value *SecondFactor
+		)
+		m.oldValue = func(ctx context.Context) (*SecondFactor, error) {
+			once.Do(func() {
+				if m.done {
+					err = errors.New("querying old values post mutation is not allowed")
+				} else {
+					value, err = m.Client().SecondFactor.Get(ctx, id)
+				}
+			})
+			return value, err
+		}
+		m.id = &id
+	}
+}
+
+// withSecondFactor sets the old SecondFactor of the mutation.
+func withSecondFactor(node *SecondFactor) secondfactorOption {
+	return func(m *SecondFactorMutation) {
+		m.oldValue = func(context.Context) (*SecondFactor, error) {
+			return node, nil
+		}
+		m.id = &node.ID
+	}
+}
+
+// Client returns a new `ent.Client` from the mutation. If the mutation was
+// executed in a transaction (ent.Tx), a transactional client is returned.
+func (m SecondFactorMutation) Client() *Client {
+	client := &Client{config: m.config}
+	client.init()
+	return client
+}
+
+// Tx returns an `ent.Tx` for mutations that were executed in transactions;
+// it returns an error otherwise.
+func (m SecondFactorMutation) Tx() (*Tx, error) {
+	if _, ok := m.driver.(*txDriver); !ok {
+		return nil, errors.New("ent: mutation is not running in a transaction")
+	}
+	tx := &Tx{config: m.config}
+	tx.init()
+	return tx, nil
+}
+
+// ID returns the ID value in the mutation. Note that the ID is only available
+// if it was provided to the builder or after it was returned from the database.
+func (m *SecondFactorMutation) ID() (id int, exists bool) {
+	if m.id == nil {
+		return
+	}
+	return *m.id, true
+}
+
+// IDs queries the database and returns the entity ids that match the mutation's predicate.
+// That means, if the mutation is applied within a transaction with an isolation level such
+// as sql.LevelSerializable, the returned ids match the ids of the rows that will be updated
+// or updated by the mutation.
+func (m *SecondFactorMutation) IDs(ctx context.Context) ([]int, error) {
+	switch {
+	case m.op.Is(OpUpdateOne | OpDeleteOne):
+		id, exists := m.ID()
+		if exists {
+			return []int{id}, nil
+		}
+		fallthrough
+	case m.op.Is(OpUpdate | OpDelete):
+		return m.Client().SecondFactor.Query().Where(m.predicates...).IDs(ctx)
+	default:
+		return nil, fmt.Errorf("IDs is not allowed on %s operations", m.op)
+	}
+}
+
+// SetFid sets the "fid" field.
+func (m *SecondFactorMutation) SetFid(s string) {
+	m.fid = &s
+}
+
+// Fid returns the value of the "fid" field in the mutation.
+func (m *SecondFactorMutation) Fid() (r string, exists bool) {
+	v := m.fid
+	if v == nil {
+		return
+	}
+	return *v, true
+}
+
+// OldFid returns the old "fid" field's value of the SecondFactor entity.
+// If the SecondFactor object wasn't provided to the builder, the object is fetched from the database.
+// An error is returned if the mutation operation is not UpdateOne, or the database query fails.
+func (m *SecondFactorMutation) OldFid(ctx context.Context) (v string, err error) {
+	if !m.op.Is(OpUpdateOne) {
+		return v, errors.New("OldFid is only allowed on UpdateOne operations")
+	}
+	if m.id == nil || m.oldValue == nil {
+		return v, errors.New("OldFid requires an ID field in the mutation")
+	}
+	oldValue, err := m.oldValue(ctx)
+	if err != nil {
+		return v, fmt.Errorf("querying old value for OldFid: %w", err)
+	}
+	return oldValue.Fid, nil
+}
+
+// ResetFid resets all changes to the "fid" field.
+func (m *SecondFactorMutation) ResetFid() {
+	m.fid = nil
+}
+
+// SetBody sets the "body" field.
+func (m *SecondFactorMutation) SetBody(af *account.SecondFactor) {
+	m.body = &af
+}
+
+// Body returns the value of the "body" field in the mutation.
+func (m *SecondFactorMutation) Body() (r *account.SecondFactor, exists bool) {
+	v := m.body
+	if v == nil {
+		return
+	}
+	return *v, true
+}
+
+// OldBody returns the old "body" field's value of the SecondFactor entity.
+// If the SecondFactor object wasn't provided to the builder, the object is fetched from the database.
+// An error is returned if the mutation operation is not UpdateOne, or the database query fails.
+func (m *SecondFactorMutation) OldBody(ctx context.Context) (v *account.SecondFactor, err error) {
+	if !m.op.Is(OpUpdateOne) {
+		return v, errors.New("OldBody is only allowed on UpdateOne operations")
+	}
+	if m.id == nil || m.oldValue == nil {
+		return v, errors.New("OldBody requires an ID field in the mutation")
+	}
+	oldValue, err := m.oldValue(ctx)
+	if err != nil {
+		return v, fmt.Errorf("querying old value for OldBody: %w", err)
+	}
+	return oldValue.Body, nil
+}
+
+// ResetBody resets all changes to the "body" field.
+func (m *SecondFactorMutation) ResetBody() {
+	m.body = nil
+}
+
+// SetAccountID sets the "account" edge to the Account entity by id.
+func (m *SecondFactorMutation) SetAccountID(id int) {
+	m.account = &id
+}
+
+// ClearAccount clears the "account" edge to the Account entity.
+func (m *SecondFactorMutation) ClearAccount() {
+	m.clearedaccount = true
+}
+
+// AccountCleared reports if the "account" edge to the Account entity was cleared.
+func (m *SecondFactorMutation) AccountCleared() bool {
+	return m.clearedaccount
+}
+
+// AccountID returns the "account" edge ID in the mutation.
+func (m *SecondFactorMutation) AccountID() (id int, exists bool) {
+	if m.account != nil {
+		return *m.account, true
+	}
+	return
+}
+
+// AccountIDs returns the "account" edge IDs in the mutation.
+// Note that IDs always returns len(IDs) <= 1 for unique edges, and you should use
+// AccountID instead. It exists only for internal usage by the builders.
+func (m *SecondFactorMutation) AccountIDs() (ids []int) {
+	if id := m.account; id != nil {
+		ids = append(ids, *id)
+	}
+	return
+}
+
+// ResetAccount resets all changes to the "account" edge.
+func (m *SecondFactorMutation) ResetAccount() {
+	m.account = nil
+	m.clearedaccount = false
+}
+
+// Where appends a list predicates to the SecondFactorMutation builder.
+func (m *SecondFactorMutation) Where(ps ...predicate.SecondFactor) {
+	m.predicates = append(m.predicates, ps...)
+}
+
+// WhereP appends storage-level predicates to the SecondFactorMutation builder. Using this method,
+// users can use type-assertion to append predicates that do not depend on any generated package.
+func (m *SecondFactorMutation) WhereP(ps ...func(*sql.Selector)) {
+	p := make([]predicate.SecondFactor, len(ps))
+	for i := range ps {
+		p[i] = ps[i]
+	}
+	m.Where(p...)
+}
+
+// Op returns the operation name.
+func (m *SecondFactorMutation) Op() Op {
+	return m.op
+}
+
+// SetOp allows setting the mutation operation.
+func (m *SecondFactorMutation) SetOp(op Op) {
+	m.op = op
+}
+
+// Type returns the node type of this mutation (SecondFactor).
+func (m *SecondFactorMutation) Type() string {
+	return m.typ
+}
+
+// Fields returns all fields that were changed during this mutation. Note that in
+// order to get all numeric fields that were incremented/decremented, call
+// AddedFields().
+func (m *SecondFactorMutation) Fields() []string {
+	fields := make([]string, 0, 2)
+	if m.fid != nil {
+		fields = append(fields, secondfactor.FieldFid)
+	}
+	if m.body != nil {
+		fields = append(fields, secondfactor.FieldBody)
+	}
+	return fields
+}
+
+// Field returns the value of a field with the given name. The second boolean
+// return value indicates that this field was not set, or was not defined in the
+// schema.
+func (m *SecondFactorMutation) Field(name string) (ent.Value, bool) {
+	switch name {
+	case secondfactor.FieldFid:
+		return m.Fid()
+	case secondfactor.FieldBody:
+		return m.Body()
+	}
+	return nil, false
+}
+
+// OldField returns the old value of the field from the database. An error is
+// returned if the mutation operation is not UpdateOne, or the query to the
+// database failed.
+func (m *SecondFactorMutation) OldField(ctx context.Context, name string) (ent.Value, error) {
+	switch name {
+	case secondfactor.FieldFid:
+		return m.OldFid(ctx)
+	case secondfactor.FieldBody:
+		return m.OldBody(ctx)
+	}
+	return nil, fmt.Errorf("unknown SecondFactor field %s", name)
+}
+
+// SetField sets the value of a field with the given name. It returns an error if
+// the field is not defined in the schema, or if the type mismatched the field
+// type.
+func (m *SecondFactorMutation) SetField(name string, value ent.Value) error {
+	switch name {
+	case secondfactor.FieldFid:
+		v, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("unexpected type %T for field %s", value, name)
+		}
+		m.SetFid(v)
+		return nil
+	case secondfactor.FieldBody:
+		v, ok := value.(*account.SecondFactor)
+		if !ok {
+			return fmt.Errorf("unexpected type %T for field %s", value, name)
+		}
+		m.SetBody(v)
+		return nil
+	}
+	return fmt.Errorf("unknown SecondFactor field %s", name)
+}
+
+// AddedFields returns all numeric fields that were incremented/decremented during
+// this mutation.
+func (m *SecondFactorMutation) AddedFields() []string {
+	return nil
+}
+
+// AddedField returns the numeric value that was incremented/decremented on a field
+// with the given name. The second boolean return value indicates that this field
+// was not set, or was not defined in the schema.
+func (m *SecondFactorMutation) AddedField(name string) (ent.Value, bool) {
+	return nil, false
+}
+
+// AddField adds the value to the field with the given name. It returns an error if
+// the field is not defined in the schema, or if the type mismatched the field
+// type.
+func (m *SecondFactorMutation) AddField(name string, value ent.Value) error {
+	switch name {
+	}
+	return fmt.Errorf("unknown SecondFactor numeric field %s", name)
+}
+
+// ClearedFields returns all nullable fields that were cleared during this
+// mutation.
+func (m *SecondFactorMutation) ClearedFields() []string {
+	return nil
+}
+
+// FieldCleared returns a boolean indicating if a field with the given name was
+// cleared in this mutation.
+func (m *SecondFactorMutation) FieldCleared(name string) bool {
+	_, ok := m.clearedFields[name]
+	return ok
+}
+
+// ClearField clears the value of the field with the given name. It returns an
+// error if the field is not defined in the schema.
+func (m *SecondFactorMutation) ClearField(name string) error {
+	return fmt.Errorf("unknown SecondFactor nullable field %s", name)
+}
+
+// ResetField resets all changes in the mutation for the field with the given name.
+// It returns an error if the field is not defined in the schema.
+func (m *SecondFactorMutation) ResetField(name string) error {
+	switch name {
+	case secondfactor.FieldFid:
+		m.ResetFid()
+		return nil
+	case secondfactor.FieldBody:
+		m.ResetBody()
+		return nil
+	}
+	return fmt.Errorf("unknown SecondFactor field %s", name)
+}
+
+// AddedEdges returns all edge names that were set/added in this mutation.
+func (m *SecondFactorMutation) AddedEdges() []string {
+	edges := make([]string, 0, 1)
+	if m.account != nil {
+		edges = append(edges, secondfactor.EdgeAccount)
+	}
+	return edges
+}
+
+// AddedIDs returns all IDs (to other nodes) that were added for the given edge
+// name in this mutation.
+func (m *SecondFactorMutation) AddedIDs(name string) []ent.Value {
+	switch name {
+	case secondfactor.EdgeAccount:
+		if id := m.account; id != nil {
+			return []ent.Value{*id}
+		}
+	}
+	return nil
+}
+
+// RemovedEdges returns all edge names that were removed in this mutation.
+func (m *SecondFactorMutation) RemovedEdges() []string {
+	edges := make([]string, 0, 1)
+	return edges
+}
+
+// RemovedIDs returns all IDs (to other nodes) that were removed for the edge with
+// the given name in this mutation.
+func (m *SecondFactorMutation) RemovedIDs(name string) []ent.Value {
+	return nil
+}
+
+// ClearedEdges returns all edge names that were cleared in this mutation.
+func (m *SecondFactorMutation) ClearedEdges() []string {
+	edges := make([]string, 0, 1)
+	if m.clearedaccount {
+		edges = append(edges, secondfactor.EdgeAccount)
+	}
+	return edges
+}
+
+// EdgeCleared returns a boolean which indicates if the edge with the given name
+// was cleared in this mutation.
+func (m *SecondFactorMutation) EdgeCleared(name string) bool {
+	switch name {
+	case secondfactor.EdgeAccount:
+		return m.clearedaccount
+	}
+	return false
+}
+
+// ClearEdge clears the value of the edge with the given name. It returns an error
+// if that edge is not defined in the schema.
+func (m *SecondFactorMutation) ClearEdge(name string) error {
+	switch name {
+	case secondfactor.EdgeAccount:
+		m.ClearAccount()
+		return nil
+	}
+	return fmt.Errorf("unknown SecondFactor unique edge %s", name)
+}
+
+// ResetEdge resets all changes to the edge with the given name in this mutation.
+// It returns an error if the edge is not defined in the schema.
+func (m *SecondFactorMutation) ResetEdge(name string) error {
+	switch name {
+	case secondfactor.EdgeAccount:
+		m.ResetAccount()
+		return nil
+	}
+	return fmt.Errorf("unknown SecondFactor edge %s", name)
+}
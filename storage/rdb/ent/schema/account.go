@@ -29,6 +29,7 @@ func (Account) Edges() []ent.Edge {
 	return []ent.Edge{
 		edge.To("recovery_codes", RecoveryCode.Type).StorageKey(edge.Column("account")),
 		edge.To("access_keys", AccessKey.Type).StorageKey(edge.Column("account")),
+		edge.To("second_factors", SecondFactor.Type).StorageKey(edge.Column("account")),
 		edge.To("identities", Identity.Type).StorageKey(edge.Column("account")),
 		edge.To("lockers", Locker.Type).StorageKey(edge.Column("account")),
 		edge.To("properties", Property.Type).StorageKey(edge.Column("account")),
@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"github.com/piprate/metalocker/model/account"
+)
+
+// SecondFactor holds the schema definition for the SecondFactor entity.
+type SecondFactor struct {
+	ent.Schema
+}
+
+// Fields of the SecondFactor.
+func (SecondFactor) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("fid").Unique(),
+		field.JSON("body", &account.SecondFactor{}),
+	}
+}
+
+// Edges of the SecondFactor.
+func (SecondFactor) Edges() []ent.Edge {
+	return []ent.Edge{
+		edge.From("account", Account.Type).Ref("second_factors").Unique(),
+	}
+}
+
+func (SecondFactor) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("fid").Unique(),
+	}
+}
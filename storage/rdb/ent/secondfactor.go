@@ -0,0 +1,170 @@
+// Copyright 2024 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"entgo.io/ent/dialect/sql"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
+
+	entaccount "github.com/piprate/metalocker/storage/rdb/ent/account"
+)
+
+// SecondFactor is the model entity for the SecondFactor schema.
+type SecondFactor struct {
+	config `json:"-"`
+	// ID of the ent.
+	ID int `json:"id,omitempty"`
+	// Fid holds the value of the "fid" field.
+	Fid string `json:"fid,omitempty"`
+	// Body holds the value of the "body" field.
+	Body *account.SecondFactor `json:"body,omitempty"`
+	// Edges holds the relations/edges for other nodes in the graph.
+	// The values are being populated by the SecondFactorQuery when eager-loading is set.
+	Edges   SecondFactorEdges `json:"edges"`
+	account *int
+}
+
+// SecondFactorEdges holds the relations/edges for other nodes in the graph.
+type SecondFactorEdges struct {
+	// Account holds the value of the account edge.
+	Account *Account `json:"account,omitempty"`
+	// loadedTypes holds the information for reporting if a
+	// type was loaded (or requested) in eager-loading or not.
+	loadedTypes [1]bool
+}
+
+// AccountOrErr returns the Account value or an error if the edge
+// was not loaded in eager-loading, or loaded but was not found.
+func (e SecondFactorEdges) AccountOrErr() (*Account, error) {
+	if e.loadedTypes[0] {
+		if e.Account == nil {
+			// Edge was loaded but was not found.
+			return nil, &NotFoundError{label: entaccount.Label}
+		}
+		return e.Account, nil
+	}
+	return nil, &NotLoadedError{edge: "account"}
+}
+
+// scanValues returns the types for scanning values from sql.Rows.
+func (*SecondFactor) scanValues(columns []string) ([]any, error) {
+	values := make([]any, len(columns))
+	for i := range columns {
+		switch columns[i] {
+		case secondfactor.FieldBody:
+			values[i] = new([]byte)
+		case secondfactor.FieldID:
+			values[i] = new(sql.NullInt64)
+		case secondfactor.FieldFid:
+			values[i] = new(sql.NullString)
+		case secondfactor.ForeignKeys[0]: // account
+			values[i] = new(sql.NullInt64)
+		default:
+			return nil, fmt.Errorf("unexpected column %q for type SecondFactor", columns[i])
+		}
+	}
+	return values, nil
+}
+
+// assignValues assigns the values that were returned from sql.Rows (after scanning)
+// to the SecondFactor fields.
+func (sf *SecondFactor) assignValues(columns []string, values []any) error {
+	if m, n := len(values), len(columns); m < n {
+		return fmt.Errorf("mismatch number of scan values: %d != %d", m, n)
+	}
+	for i := range columns {
+		switch columns[i] {
+		case secondfactor.FieldID:
+			value, ok := values[i].(*sql.NullInt64)
+			if !ok {
+				return fmt.Errorf("unexpected type %T for field id", value)
+			}
+			sf.ID = int(value.Int64)
+		case secondfactor.FieldFid:
+			if value, ok := values[i].(*sql.NullString); !ok {
+				return fmt.Errorf("unexpected type %T for field fid", values[i])
+			} else if value.Valid {
+				sf.Fid = value.String
+			}
+		case secondfactor.FieldBody:
+			if value, ok := values[i].(*[]byte); !ok {
+				return fmt.Errorf("unexpected type %T for field body", values[i])
+			} else if value != nil && len(*value) > 0 {
+				if err := json.Unmarshal(*value, &sf.Body); err != nil {
+					return fmt.Errorf("unmarshal field body: %w", err)
+				}
+			}
+		case secondfactor.ForeignKeys[0]:
+			if value, ok := values[i].(*sql.NullInt64); !ok {
+				return fmt.Errorf("unexpected type %T for edge-field account", value)
+			} else if value.Valid {
+				sf.account = new(int)
+				*sf.account = int(value.Int64)
+			}
+		}
+	}
+	return nil
+}
+
+// QueryAccount queries the "account" edge of the SecondFactor entity.
+func (sf *SecondFactor) QueryAccount() *AccountQuery {
+	return NewSecondFactorClient(sf.config).QueryAccount(sf)
+}
+
+// Update returns a builder for updating this SecondFactor.
+// Note that you need to call SecondFactor.Unwrap() before calling this method if this SecondFactor
+// was returned from a transaction, and the transaction was committed or rolled back.
+func (sf *SecondFactor) Update() *SecondFactorUpdateOne {
+	return NewSecondFactorClient(sf.config).UpdateOne(sf)
+}
+
+// Unwrap unwraps the SecondFactor entity that was returned from a transaction after it was closed,
+// so that all future queries will be executed through the driver which created the transaction.
+func (sf *SecondFactor) Unwrap() *SecondFactor {
+	_tx, ok := sf.config.driver.(*txDriver)
+	if !ok {
+		panic("ent: SecondFactor is not a transactional entity")
+	}
+	sf.config.driver = _tx.drv
+	return sf
+}
+
+// String implements the fmt.Stringer.
+func (sf *SecondFactor) String() string {
+	var builder strings.Builder
+	builder.WriteString("SecondFactor(")
+	builder.WriteString(fmt.Sprintf("id=%v, ", sf.ID))
+	builder.WriteString("fid=")
+	builder.WriteString(sf.Fid)
+	builder.WriteString(", ")
+	builder.WriteString("body=")
+	builder.WriteString(fmt.Sprintf("%v", sf.Body))
+	builder.WriteByte(')')
+	return builder.String()
+}
+
+// SecondFactors is a parsable slice of SecondFactor.
+type SecondFactors []*SecondFactor
+
+func (sf SecondFactors) config(cfg config) {
+	for _i := range sf {
+		sf[_i].config = cfg
+	}
+}
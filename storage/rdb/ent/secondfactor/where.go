@@ -0,0 +1,195 @@
+// Copyright 2024 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secondfactor
+
+import (
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/sqlgraph"
+	"github.com/piprate/metalocker/storage/rdb/ent/predicate"
+)
+
+// ID filters vertices based on their ID field.
+func ID(id int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldEQ(FieldID, id))
+}
+
+// IDEQ applies the EQ predicate on the ID field.
+func IDEQ(id int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldEQ(FieldID, id))
+}
+
+// IDNEQ applies the NEQ predicate on the ID field.
+func IDNEQ(id int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldNEQ(FieldID, id))
+}
+
+// IDIn applies the In predicate on the ID field.
+func IDIn(ids ...int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldIn(FieldID, ids...))
+}
+
+// IDNotIn applies the NotIn predicate on the ID field.
+func IDNotIn(ids ...int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldNotIn(FieldID, ids...))
+}
+
+// IDGT applies the GT predicate on the ID field.
+func IDGT(id int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldGT(FieldID, id))
+}
+
+// IDGTE applies the GTE predicate on the ID field.
+func IDGTE(id int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldGTE(FieldID, id))
+}
+
+// IDLT applies the LT predicate on the ID field.
+func IDLT(id int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldLT(FieldID, id))
+}
+
+// IDLTE applies the LTE predicate on the ID field.
+func IDLTE(id int) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldLTE(FieldID, id))
+}
+
+// Fid applies equality check predicate on the "fid" field. It's identical to FidEQ.
+func Fid(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldEQ(FieldFid, v))
+}
+
+// FidEQ applies the EQ predicate on the "fid" field.
+func FidEQ(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldEQ(FieldFid, v))
+}
+
+// FidNEQ applies the NEQ predicate on the "fid" field.
+func FidNEQ(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldNEQ(FieldFid, v))
+}
+
+// FidIn applies the In predicate on the "fid" field.
+func FidIn(vs ...string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldIn(FieldFid, vs...))
+}
+
+// FidNotIn applies the NotIn predicate on the "fid" field.
+func FidNotIn(vs ...string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldNotIn(FieldFid, vs...))
+}
+
+// FidGT applies the GT predicate on the "fid" field.
+func FidGT(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldGT(FieldFid, v))
+}
+
+// FidGTE applies the GTE predicate on the "fid" field.
+func FidGTE(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldGTE(FieldFid, v))
+}
+
+// FidLT applies the LT predicate on the "fid" field.
+func FidLT(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldLT(FieldFid, v))
+}
+
+// FidLTE applies the LTE predicate on the "fid" field.
+func FidLTE(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldLTE(FieldFid, v))
+}
+
+// FidContains applies the Contains predicate on the "fid" field.
+func FidContains(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldContains(FieldFid, v))
+}
+
+// FidHasPrefix applies the HasPrefix predicate on the "fid" field.
+func FidHasPrefix(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldHasPrefix(FieldFid, v))
+}
+
+// FidHasSuffix applies the HasSuffix predicate on the "fid" field.
+func FidHasSuffix(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldHasSuffix(FieldFid, v))
+}
+
+// FidEqualFold applies the EqualFold predicate on the "fid" field.
+func FidEqualFold(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldEqualFold(FieldFid, v))
+}
+
+// FidContainsFold applies the ContainsFold predicate on the "fid" field.
+func FidContainsFold(v string) predicate.SecondFactor {
+	return predicate.SecondFactor(sql.FieldContainsFold(FieldFid, v))
+}
+
+// HasAccount applies the HasEdge predicate on the "account" edge.
+func HasAccount() predicate.SecondFactor {
+	return predicate.SecondFactor(func(s *sql.Selector) {
+		step := sqlgraph.NewStep(
+			sqlgraph.From(Table, FieldID),
+			sqlgraph.Edge(sqlgraph.M2O, true, AccountTable, AccountColumn),
+		)
+		sqlgraph.HasNeighbors(s, step)
+	})
+}
+
+// HasAccountWith applies the HasEdge predicate on the "account" edge with a given conditions (other predicates).
+func HasAccountWith(preds ...predicate.Account) predicate.SecondFactor {
+	return predicate.SecondFactor(func(s *sql.Selector) {
+		step := sqlgraph.NewStep(
+			sqlgraph.From(Table, FieldID),
+			sqlgraph.To(AccountInverseTable, FieldID),
+			sqlgraph.Edge(sqlgraph.M2O, true, AccountTable, AccountColumn),
+		)
+		sqlgraph.HasNeighborsWith(s, step, func(s *sql.Selector) {
+			for _, p := range preds {
+				p(s)
+			}
+		})
+	})
+}
+
+// And groups predicates with the AND operator between them.
+func And(predicates ...predicate.SecondFactor) predicate.SecondFactor {
+	return predicate.SecondFactor(func(s *sql.Selector) {
+		s1 := s.Clone().SetP(nil)
+		for _, p := range predicates {
+			p(s1)
+		}
+		s.Where(s1.P())
+	})
+}
+
+// Or groups predicates with the OR operator between them.
+func Or(predicates ...predicate.SecondFactor) predicate.SecondFactor {
+	return predicate.SecondFactor(func(s *sql.Selector) {
+		s1 := s.Clone().SetP(nil)
+		for i, p := range predicates {
+			if i > 0 {
+				s1.Or()
+			}
+			p(s1)
+		}
+		s.Where(s1.P())
+	})
+}
+
+// Not applies the not operator on the given predicate.
+func Not(p predicate.SecondFactor) predicate.SecondFactor {
+	return predicate.SecondFactor(func(s *sql.Selector) {
+		p(s.Not())
+	})
+}
@@ -0,0 +1,252 @@
+// Copyright 2024 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"entgo.io/ent/dialect/sql/sqlgraph"
+	"entgo.io/ent/schema/field"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
+
+	entaccount "github.com/piprate/metalocker/storage/rdb/ent/account"
+)
+
+// SecondFactorCreate is the builder for creating a SecondFactor entity.
+type SecondFactorCreate struct {
+	config
+	mutation *SecondFactorMutation
+	hooks    []Hook
+}
+
+// SetFid sets the "fid" field.
+func (sfc *SecondFactorCreate) SetFid(s string) *SecondFactorCreate {
+	sfc.mutation.SetFid(s)
+	return sfc
+}
+
+// SetBody sets the "body" field.
+func (sfc *SecondFactorCreate) SetBody(af *account.SecondFactor) *SecondFactorCreate {
+	sfc.mutation.SetBody(af)
+	return sfc
+}
+
+// SetAccountID sets the "account" edge to the Account entity by ID.
+func (sfc *SecondFactorCreate) SetAccountID(id int) *SecondFactorCreate {
+	sfc.mutation.SetAccountID(id)
+	return sfc
+}
+
+// SetNillableAccountID sets the "account" edge to the Account entity by ID if the given value is not nil.
+func (sfc *SecondFactorCreate) SetNillableAccountID(id *int) *SecondFactorCreate {
+	if id != nil {
+		sfc = sfc.SetAccountID(*id)
+	}
+	return sfc
+}
+
+// SetAccount sets the "account" edge to the Account entity.
+func (sfc *SecondFactorCreate) SetAccount(a *Account) *SecondFactorCreate {
+	return sfc.SetAccountID(a.ID)
+}
+
+// Mutation returns the SecondFactorMutation object of the builder.
+func (sfc *SecondFactorCreate) Mutation() *SecondFactorMutation {
+	return sfc.mutation
+}
+
+// Save creates the SecondFactor in the database.
+func (sfc *SecondFactorCreate) Save(ctx context.Context) (*SecondFactor, error) {
+	return withHooks[*SecondFactor, SecondFactorMutation](ctx, sfc.sqlSave, sfc.mutation, sfc.hooks)
+}
+
+// SaveX calls Save and panics if Save returns an error.
+func (sfc *SecondFactorCreate) SaveX(ctx context.Context) *SecondFactor {
+	v, err := sfc.Save(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Exec executes the query.
+func (sfc *SecondFactorCreate) Exec(ctx context.Context) error {
+	_, err := sfc.Save(ctx)
+	return err
+}
+
+// ExecX is like Exec, but panics if an error occurs.
+func (sfc *SecondFactorCreate) ExecX(ctx context.Context) {
+	if err := sfc.Exec(ctx); err != nil {
+		panic(err)
+	}
+}
+
+// check runs all checks and user-defined validators on the builder.
+func (sfc *SecondFactorCreate) check() error {
+	if _, ok := sfc.mutation.Fid(); !ok {
+		return &ValidationError{Name: "fid", err: errors.New(`ent: missing required field "SecondFactor.fid"`)}
+	}
+	if _, ok := sfc.mutation.Body(); !ok {
+		return &ValidationError{Name: "body", err: errors.New(`ent: missing required field "SecondFactor.body"`)}
+	}
+	return nil
+}
+
+func (sfc *SecondFactorCreate) sqlSave(ctx context.Context) (*SecondFactor, error) {
+	if err := sfc.check(); err != nil {
+		return nil, err
+	}
+	_node, _spec := sfc.createSpec()
+	if err := sqlgraph.CreateNode(ctx, sfc.driver, _spec); err != nil {
+		if sqlgraph.IsConstraintError(err) {
+			err = &ConstraintError{msg: err.Error(), wrap: err}
+		}
+		return nil, err
+	}
+	id := _spec.ID.Value.(int64)
+	_node.ID = int(id)
+	sfc.mutation.id = &_node.ID
+	sfc.mutation.done = true
+	return _node, nil
+}
+
+func (sfc *SecondFactorCreate) createSpec() (*SecondFactor, *sqlgraph.CreateSpec) {
+	var (
+		_node = &SecondFactor{config: sfc.config}
+		_spec = &sqlgraph.CreateSpec{
+			Table: secondfactor.Table,
+			ID: &sqlgraph.FieldSpec{
+				Type:   field.TypeInt,
+				Column: secondfactor.FieldID,
+			},
+		}
+	)
+	if value, ok := sfc.mutation.Fid(); ok {
+		_spec.SetField(secondfactor.FieldFid, field.TypeString, value)
+		_node.Fid = value
+	}
+	if value, ok := sfc.mutation.Body(); ok {
+		_spec.SetField(secondfactor.FieldBody, field.TypeJSON, value)
+		_node.Body = value
+	}
+	if nodes := sfc.mutation.AccountIDs(); len(nodes) > 0 {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.M2O,
+			Inverse: true,
+			Table:   secondfactor.AccountTable,
+			Columns: []string{secondfactor.AccountColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: entaccount.FieldID,
+				},
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_node.account = &nodes[0]
+		_spec.Edges = append(_spec.Edges, edge)
+	}
+	return _node, _spec
+}
+
+// SecondFactorCreateBulk is the builder for creating many SecondFactor entities in bulk.
+type SecondFactorCreateBulk struct {
+	config
+	builders []*SecondFactorCreate
+}
+
+// Save creates the SecondFactor entities in the database.
+func (sfcb *SecondFactorCreateBulk) Save(ctx context.Context) ([]*SecondFactor, error) {
+	specs := make([]*sqlgraph.CreateSpec, len(sfcb.builders))
+	nodes := make([]*SecondFactor, len(sfcb.builders))
+	mutators := make([]Mutator, len(sfcb.builders))
+	for i := range sfcb.builders {
+		func(i int, root context.Context) {
+			builder := sfcb.builders[i]
+			var mut Mutator = MutateFunc(func(ctx context.Context, m Mutation) (Value, error) {
+				mutation, ok := m.(*SecondFactorMutation)
+				if !ok {
+					return nil, fmt.Errorf("unexpected mutation type %T", m)
+				}
+				if err := builder.check(); err != nil {
+					return nil, err
+				}
+				builder.mutation = mutation
+				nodes[i], specs[i] = builder.createSpec()
+				var err error
+				if i < len(mutators)-1 {
+					_, err = mutators[i+1].Mutate(root, sfcb.builders[i+1].mutation)
+				} else {
+					spec := &sqlgraph.BatchCreateSpec{Nodes: specs}
+					// Invoke the actual operation on the latest mutation in the chain.
+					if err = sqlgraph.BatchCreate(ctx, sfcb.driver, spec); err != nil {
+						if sqlgraph.IsConstraintError(err) {
+							err = &ConstraintError{msg: err.Error(), wrap: err}
+						}
+					}
+				}
+				if err != nil {
+					return nil, err
+				}
+				mutation.id = &nodes[i].ID
+				if specs[i].ID.Value != nil {
+					id := specs[i].ID.Value.(int64)
+					nodes[i].ID = int(id)
+				}
+				mutation.done = true
+				return nodes[i], nil
+			})
+			for i := len(builder.hooks) - 1; i >= 0; i-- {
+				mut = builder.hooks[i](mut)
+			}
+			mutators[i] = mut
+		}(i, ctx)
+	}
+	if len(mutators) > 0 {
+		if _, err := mutators[0].Mutate(ctx, sfcb.builders[0].mutation); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// SaveX is like Save, but panics if an error occurs.
+func (sfcb *SecondFactorCreateBulk) SaveX(ctx context.Context) []*SecondFactor {
+	v, err := sfcb.Save(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Exec executes the query.
+func (sfcb *SecondFactorCreateBulk) Exec(ctx context.Context) error {
+	_, err := sfcb.Save(ctx)
+	return err
+}
+
+// ExecX is like Exec, but panics if an error occurs.
+func (sfcb *SecondFactorCreateBulk) ExecX(ctx context.Context) {
+	if err := sfcb.Exec(ctx); err != nil {
+		panic(err)
+	}
+}
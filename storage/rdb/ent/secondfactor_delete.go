@@ -0,0 +1,100 @@
+// Copyright 2024 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ent
+
+import (
+	"context"
+
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/sqlgraph"
+	"entgo.io/ent/schema/field"
+	"github.com/piprate/metalocker/storage/rdb/ent/predicate"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
+)
+
+// SecondFactorDelete is the builder for deleting a SecondFactor entity.
+type SecondFactorDelete struct {
+	config
+	hooks    []Hook
+	mutation *SecondFactorMutation
+}
+
+// Where appends a list predicates to the SecondFactorDelete builder.
+func (sfd *SecondFactorDelete) Where(ps ...predicate.SecondFactor) *SecondFactorDelete {
+	sfd.mutation.Where(ps...)
+	return sfd
+}
+
+// Exec executes the deletion query and returns how many vertices were deleted.
+func (sfd *SecondFactorDelete) Exec(ctx context.Context) (int, error) {
+	return withHooks[int, SecondFactorMutation](ctx, sfd.sqlExec, sfd.mutation, sfd.hooks)
+}
+
+// ExecX is like Exec, but panics if an error occurs.
+func (sfd *SecondFactorDelete) ExecX(ctx context.Context) int {
+	n, err := sfd.Exec(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func (sfd *SecondFactorDelete) sqlExec(ctx context.Context) (int, error) {
+	_spec := &sqlgraph.DeleteSpec{
+		Node: &sqlgraph.NodeSpec{
+			Table: secondfactor.Table,
+			ID: &sqlgraph.FieldSpec{
+				Type:   field.TypeInt,
+				Column: secondfactor.FieldID,
+			},
+		},
+	}
+	if ps := sfd.mutation.predicates; len(ps) > 0 {
+		_spec.Predicate = func(selector *sql.Selector) {
+			for i := range ps {
+				ps[i](selector)
+			}
+		}
+	}
+	affected, err := sqlgraph.DeleteNodes(ctx, sfd.driver, _spec)
+	if err != nil && sqlgraph.IsConstraintError(err) {
+		err = &ConstraintError{msg: err.Error(), wrap: err}
+	}
+	sfd.mutation.done = true
+	return affected, err
+}
+
+// SecondFactorDeleteOne is the builder for deleting a single SecondFactor entity.
+type SecondFactorDeleteOne struct {
+	sfd *SecondFactorDelete
+}
+
+// Exec executes the deletion query.
+func (sfdo *SecondFactorDeleteOne) Exec(ctx context.Context) error {
+	n, err := sfdo.sfd.Exec(ctx)
+	switch {
+	case err != nil:
+		return err
+	case n == 0:
+		return &NotFoundError{secondfactor.Label}
+	default:
+		return nil
+	}
+}
+
+// ExecX is like Exec, but panics if an error occurs.
+func (sfdo *SecondFactorDeleteOne) ExecX(ctx context.Context) {
+	sfdo.sfd.ExecX(ctx)
+}
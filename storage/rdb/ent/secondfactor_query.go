@@ -0,0 +1,632 @@
+// Copyright 2024 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ent
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/sqlgraph"
+	"entgo.io/ent/schema/field"
+	"github.com/piprate/metalocker/storage/rdb/ent/predicate"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
+
+	entaccount "github.com/piprate/metalocker/storage/rdb/ent/account"
+)
+
+// SecondFactorQuery is the builder for querying SecondFactor entities.
+type SecondFactorQuery struct {
+	config
+	ctx         *QueryContext
+	order       []OrderFunc
+	inters      []Interceptor
+	predicates  []predicate.SecondFactor
+	withAccount *AccountQuery
+	withFKs     bool
+	// intermediate query (i.e. traversal path).
+	sql  *sql.Selector
+	path func(context.Context) (*sql.Selector, error)
+}
+
+// Where adds a new predicate for the SecondFactorQuery builder.
+func (sfq *SecondFactorQuery) Where(ps ...predicate.SecondFactor) *SecondFactorQuery {
+	sfq.predicates = append(sfq.predicates, ps...)
+	return sfq
+}
+
+// Limit the number of records to be returned by this query.
+func (sfq *SecondFactorQuery) Limit(limit int) *SecondFactorQuery {
+	sfq.ctx.Limit = &limit
+	return sfq
+}
+
+// Offset to start from.
+func (sfq *SecondFactorQuery) Offset(offset int) *SecondFactorQuery {
+	sfq.ctx.Offset = &offset
+	return sfq
+}
+
+// Unique configures the query builder to filter duplicate records on query.
+// By default, unique is set to true, and can be disabled using this method.
+func (sfq *SecondFactorQuery) Unique(unique bool) *SecondFactorQuery {
+	sfq.ctx.Unique = &unique
+	return sfq
+}
+
+// Order specifies how the records should be ordered.
+func (sfq *SecondFactorQuery) Order(o ...OrderFunc) *SecondFactorQuery {
+	sfq.order = append(sfq.order, o...)
+	return sfq
+}
+
+// QueryAccount chains the current query on the "account" edge.
+func (sfq *SecondFactorQuery) QueryAccount() *AccountQuery {
+	query := (&AccountClient{config: sfq.config}).Query()
+	query.path = func(ctx context.Context) (fromU *sql.Selector, err error) {
+		if err := sfq.prepareQuery(ctx); err != nil {
+			return nil, err
+		}
+		selector := sfq.sqlQuery(ctx)
+		if err := selector.Err(); err != nil {
+			return nil, err
+		}
+		step := sqlgraph.NewStep(
+			sqlgraph.From(secondfactor.Table, secondfactor.FieldID, selector),
+			sqlgraph.To(entaccount.Table, entaccount.FieldID),
+			sqlgraph.Edge(sqlgraph.M2O, true, secondfactor.AccountTable, secondfactor.AccountColumn),
+		)
+		fromU = sqlgraph.SetNeighbors(sfq.driver.Dialect(), step)
+		return fromU, nil
+	}
+	return query
+}
+
+// First returns the first SecondFactor entity from the query.
+// Returns a *NotFoundError when no SecondFactor was found.
+func (sfq *SecondFactorQuery) First(ctx context.Context) (*SecondFactor, error) {
+	nodes, err := sfq.Limit(1).All(setContextOp(ctx, sfq.ctx, "First"))
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, &NotFoundError{secondfactor.Label}
+	}
+	return nodes[0], nil
+}
+
+// FirstX is like First, but panics if an error occurs.
+func (sfq *SecondFactorQuery) FirstX(ctx context.Context) *SecondFactor {
+	node, err := sfq.First(ctx)
+	if err != nil && !IsNotFound(err) {
+		panic(err)
+	}
+	return node
+}
+
+// FirstID returns the first SecondFactor ID from the query.
+// Returns a *NotFoundError when no SecondFactor ID was found.
+func (sfq *SecondFactorQuery) FirstID(ctx context.Context) (id int, err error) {
+	var ids []int
+	if ids, err = sfq.Limit(1).IDs(setContextOp(ctx, sfq.ctx, "FirstID")); err != nil {
+		return
+	}
+	if len(ids) == 0 {
+		err = &NotFoundError{secondfactor.Label}
+		return
+	}
+	return ids[0], nil
+}
+
+// FirstIDX is like FirstID, but panics if an error occurs.
+func (sfq *SecondFactorQuery) FirstIDX(ctx context.Context) int {
+	id, err := sfq.FirstID(ctx)
+	if err != nil && !IsNotFound(err) {
+		panic(err)
+	}
+	return id
+}
+
+// Only returns a single SecondFactor entity found by the query, ensuring it only returns one.
+// Returns a *NotSingularError when more than one SecondFactor entity is found.
+// Returns a *NotFoundError when no SecondFactor entities are found.
+func (sfq *SecondFactorQuery) Only(ctx context.Context) (*SecondFactor, error) {
+	nodes, err := sfq.Limit(2).All(setContextOp(ctx, sfq.ctx, "Only"))
+	if err != nil {
+		return nil, err
+	}
+	switch len(nodes) {
+	case 1:
+		return nodes[0], nil
+	case 0:
+		return nil, &NotFoundError{secondfactor.Label}
+	default:
+		return nil, &NotSingularError{secondfactor.Label}
+	}
+}
+
+// OnlyX is like Only, but panics if an error occurs.
+func (sfq *SecondFactorQuery) OnlyX(ctx context.Context) *SecondFactor {
+	node, err := sfq.Only(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+// OnlyID is like Only, but returns the only SecondFactor ID in the query.
+// Returns a *NotSingularError when more than one SecondFactor ID is found.
+// Returns a *NotFoundError when no entities are found.
+func (sfq *SecondFactorQuery) OnlyID(ctx context.Context) (id int, err error) {
+	var ids []int
+	if ids, err = sfq.Limit(2).IDs(setContextOp(ctx, sfq.ctx, "OnlyID")); err != nil {
+		return
+	}
+	switch len(ids) {
+	case 1:
+		id = ids[0]
+	case 0:
+		err = &NotFoundError{secondfactor.Label}
+	default:
+		err = &NotSingularError{secondfactor.Label}
+	}
+	return
+}
+
+// OnlyIDX is like OnlyID, but panics if an error occurs.
+func (sfq *SecondFactorQuery) OnlyIDX(ctx context.Context) int {
+	id, err := sfq.OnlyID(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// All executes the query and returns a list of SecondFactors.
+func (sfq *SecondFactorQuery) All(ctx context.Context) ([]*SecondFactor, error) {
+	ctx = setContextOp(ctx, sfq.ctx, "All")
+	if err := sfq.prepareQuery(ctx); err != nil {
+		return nil, err
+	}
+	qr := querierAll[[]*SecondFactor, *SecondFactorQuery]()
+	return withInterceptors[[]*SecondFactor](ctx, sfq, qr, sfq.inters)
+}
+
+// AllX is like All, but panics if an error occurs.
+func (sfq *SecondFactorQuery) AllX(ctx context.Context) []*SecondFactor {
+	nodes, err := sfq.All(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return nodes
+}
+
+// IDs executes the query and returns a list of SecondFactor IDs.
+func (sfq *SecondFactorQuery) IDs(ctx context.Context) ([]int, error) {
+	var ids []int
+	ctx = setContextOp(ctx, sfq.ctx, "IDs")
+	if err := sfq.Select(secondfactor.FieldID).Scan(ctx, &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// IDsX is like IDs, but panics if an error occurs.
+func (sfq *SecondFactorQuery) IDsX(ctx context.Context) []int {
+	ids, err := sfq.IDs(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return ids
+}
+
+// Count returns the count of the given query.
+func (sfq *SecondFactorQuery) Count(ctx context.Context) (int, error) {
+	ctx = setContextOp(ctx, sfq.ctx, "Count")
+	if err := sfq.prepareQuery(ctx); err != nil {
+		return 0, err
+	}
+	return withInterceptors[int](ctx, sfq, querierCount[*SecondFactorQuery](), sfq.inters)
+}
+
+// CountX is like Count, but panics if an error occurs.
+func (sfq *SecondFactorQuery) CountX(ctx context.Context) int {
+	count, err := sfq.Count(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return count
+}
+
+// Exist returns true if the query has elements in the graph.
+func (sfq *SecondFactorQuery) Exist(ctx context.Context) (bool, error) {
+	ctx = setContextOp(ctx, sfq.ctx, "Exist")
+	switch _, err := sfq.FirstID(ctx); {
+	case IsNotFound(err):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("ent: check existence: %w", err)
+	default:
+		return true, nil
+	}
+}
+
+// ExistX is like Exist, but panics if an error occurs.
+func (sfq *SecondFactorQuery) ExistX(ctx context.Context) bool {
+	exist, err := sfq.Exist(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return exist
+}
+
+// Clone returns a duplicate of the SecondFactorQuery builder, including all associated steps. It can be
+// used to prepare common query builders and use them differently after the clone is made.
+func (sfq *SecondFactorQuery) Clone() *SecondFactorQuery {
+	if sfq == nil {
+		return nil
+	}
+	return &SecondFactorQuery{
+		config:      sfq.config,
+		ctx:         sfq.ctx.Clone(),
+		order:       append([]OrderFunc{}, sfq.order...),
+		inters:      append([]Interceptor{}, sfq.inters...),
+		predicates:  append([]predicate.SecondFactor{}, sfq.predicates...),
+		withAccount: sfq.withAccount.Clone(),
+		// clone intermediate query.
+		sql:  sfq.sql.Clone(),
+		path: sfq.path,
+	}
+}
+
+// WithAccount tells the query-builder to eager-load the nodes that are connected to
+// the "account" edge. The optional arguments are used to configure the query builder of the edge.
+func (sfq *SecondFactorQuery) WithAccount(opts ...func(*AccountQuery)) *SecondFactorQuery {
+	query := (&AccountClient{config: sfq.config}).Query()
+	for _, opt := range opts {
+		opt(query)
+	}
+	sfq.withAccount = query
+	return sfq
+}
+
+// GroupBy is used to group vertices by one or more fields/columns.
+// It is often used with aggregate functions, like: count, max, mean, min, sum.
+//
+// Example:
+//
+//	var v []struct {
+//		Fid string `json:"fid,omitempty"`
+//		Count int `json:"count,omitempty"`
+//	}
+//
+//	client.SecondFactor.Query().
+//		GroupBy(secondfactor.FieldFid).
+//		Aggregate(ent.Count()).
+//		Scan(ctx, &v)
+func (sfq *SecondFactorQuery) GroupBy(field string, fields ...string) *SecondFactorGroupBy {
+	sfq.ctx.Fields = append([]string{field}, fields...)
+	grbuild := &SecondFactorGroupBy{build: sfq}
+	grbuild.flds = &sfq.ctx.Fields
+	grbuild.label = secondfactor.Label
+	grbuild.scan = grbuild.Scan
+	return grbuild
+}
+
+// Select allows the selection one or more fields/columns for the given query,
+// instead of selecting all fields in the entity.
+//
+// Example:
+//
+//	var v []struct {
+//		Fid string `json:"fid,omitempty"`
+//	}
+//
+//	client.SecondFactor.Query().
+//		Select(secondfactor.FieldFid).
+//		Scan(ctx, &v)
+func (sfq *SecondFactorQuery) Select(fields ...string) *SecondFactorSelect {
+	sfq.ctx.Fields = append(sfq.ctx.Fields, fields...)
+	sbuild := &SecondFactorSelect{SecondFactorQuery: sfq}
+	sbuild.label = secondfactor.Label
+	sbuild.flds, sbuild.scan = &sfq.ctx.Fields, sbuild.Scan
+	return sbuild
+}
+
+// Aggregate returns a SecondFactorSelect configured with the given aggregations.
+func (sfq *SecondFactorQuery) Aggregate(fns ...AggregateFunc) *SecondFactorSelect {
+	return sfq.Select().Aggregate(fns...)
+}
+
+func (sfq *SecondFactorQuery) prepareQuery(ctx context.Context) error {
+	for _, inter := range sfq.inters {
+		if inter == nil {
+			return fmt.Errorf("ent: uninitialized interceptor (forgotten import ent/runtime?)")
+		}
+		if trv, ok := inter.(Traverser); ok {
+			if err := trv.Traverse(ctx, sfq); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range sfq.ctx.Fields {
+		if !secondfactor.ValidColumn(f) {
+			return &ValidationError{Name: f, err: fmt.Errorf("ent: invalid field %q for query", f)}
+		}
+	}
+	if sfq.path != nil {
+		prev, err := sfq.path(ctx)
+		if err != nil {
+			return err
+		}
+		sfq.sql = prev
+	}
+	return nil
+}
+
+func (sfq *SecondFactorQuery) sqlAll(ctx context.Context, hooks ...queryHook) ([]*SecondFactor, error) {
+	var (
+		nodes       = []*SecondFactor{}
+		withFKs     = sfq.withFKs
+		_spec       = sfq.querySpec()
+		loadedTypes = [1]bool{
+			sfq.withAccount != nil,
+		}
+	)
+	if sfq.withAccount != nil {
+		withFKs = true
+	}
+	if withFKs {
+		_spec.Node.Columns = append(_spec.Node.Columns, secondfactor.ForeignKeys...)
+	}
+	_spec.ScanValues = func(columns []string) ([]any, error) {
+		return (*SecondFactor).scanValues(nil, columns)
+	}
+	_spec.Assign = func(columns []string, values []any) error {
+		node := &SecondFactor{config: sfq.config}
+		nodes = append(nodes, node)
+		node.Edges.loadedTypes = loadedTypes
+		return node.assignValues(columns, values)
+	}
+	for i := range hooks {
+		hooks[i](ctx, _spec)
+	}
+	if err := sqlgraph.QueryNodes(ctx, sfq.driver, _spec); err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nodes, nil
+	}
+	if query := sfq.withAccount; query != nil {
+		if err := sfq.loadAccount(ctx, query, nodes, nil,
+			func(n *SecondFactor, e *Account) { n.Edges.Account = e }); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+func (sfq *SecondFactorQuery) loadAccount(ctx context.Context, query *AccountQuery, nodes []*SecondFactor, init func(*SecondFactor), assign func(*SecondFactor, *Account)) error {
+	ids := make([]int, 0, len(nodes))
+	nodeids := make(map[int][]*SecondFactor)
+	for i := range nodes {
+		if nodes[i].account == nil {
+			continue
+		}
+		fk := *nodes[i].account
+		if _, ok := nodeids[fk]; !ok {
+			ids = append(ids, fk)
+		}
+		nodeids[fk] = append(nodeids[fk], nodes[i])
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	query.Where(entaccount.IDIn(ids...))
+	neighbors, err := query.All(ctx)
+	if err != nil {
+		return err
+	}
+	for _, n := range neighbors {
+		nodes, ok := nodeids[n.ID]
+		if !ok {
+			return fmt.Errorf(`unexpected foreign-key "account" returned %v`, n.ID)
+		}
+		for i := range nodes {
+			assign(nodes[i], n)
+		}
+	}
+	return nil
+}
+
+func (sfq *SecondFactorQuery) sqlCount(ctx context.Context) (int, error) {
+	_spec := sfq.querySpec()
+	_spec.Node.Columns = sfq.ctx.Fields
+	if len(sfq.ctx.Fields) > 0 {
+		_spec.Unique = sfq.ctx.Unique != nil && *sfq.ctx.Unique
+	}
+	return sqlgraph.CountNodes(ctx, sfq.driver, _spec)
+}
+
+func (sfq *SecondFactorQuery) querySpec() *sqlgraph.QuerySpec {
+	_spec := &sqlgraph.QuerySpec{
+		Node: &sqlgraph.NodeSpec{
+			Table:   secondfactor.Table,
+			Columns: secondfactor.Columns,
+			ID: &sqlgraph.FieldSpec{
+				Type:   field.TypeInt,
+				Column: secondfactor.FieldID,
+			},
+		},
+		From:   sfq.sql,
+		Unique: true,
+	}
+	if unique := sfq.ctx.Unique; unique != nil {
+		_spec.Unique = *unique
+	}
+	if fields := sfq.ctx.Fields; len(fields) > 0 {
+		_spec.Node.Columns = make([]string, 0, len(fields))
+		_spec.Node.Columns = append(_spec.Node.Columns, secondfactor.FieldID)
+		for i := range fields {
+			if fields[i] != secondfactor.FieldID {
+				_spec.Node.Columns = append(_spec.Node.Columns, fields[i])
+			}
+		}
+	}
+	if ps := sfq.predicates; len(ps) > 0 {
+		_spec.Predicate = func(selector *sql.Selector) {
+			for i := range ps {
+				ps[i](selector)
+			}
+		}
+	}
+	if limit := sfq.ctx.Limit; limit != nil {
+		_spec.Limit = *limit
+	}
+	if offset := sfq.ctx.Offset; offset != nil {
+		_spec.Offset = *offset
+	}
+	if ps := sfq.order; len(ps) > 0 {
+		_spec.Order = func(selector *sql.Selector) {
+			for i := range ps {
+				ps[i](selector)
+			}
+		}
+	}
+	return _spec
+}
+
+func (sfq *SecondFactorQuery) sqlQuery(ctx context.Context) *sql.Selector {
+	builder := sql.Dialect(sfq.driver.Dialect())
+	t1 := builder.Table(secondfactor.Table)
+	columns := sfq.ctx.Fields
+	if len(columns) == 0 {
+		columns = secondfactor.Columns
+	}
+	selector := builder.Select(t1.Columns(columns...)...).From(t1)
+	if sfq.sql != nil {
+		selector = sfq.sql
+		selector.Select(selector.Columns(columns...)...)
+	}
+	if sfq.ctx.Unique != nil && *sfq.ctx.Unique {
+		selector.Distinct()
+	}
+	for _, p := range sfq.predicates {
+		p(selector)
+	}
+	for _, p := range sfq.order {
+		p(selector)
+	}
+	if offset := sfq.ctx.Offset; offset != nil {
+		// limit is mandatory for offset clause. We start
+		// with default value, and override it below if needed.
+		selector.Offset(*offset).Limit(math.MaxInt32)
+	}
+	if limit := sfq.ctx.Limit; limit != nil {
+		selector.Limit(*limit)
+	}
+	return selector
+}
+
+// SecondFactorGroupBy is the group-by builder for SecondFactor entities.
+type SecondFactorGroupBy struct {
+	selector
+	build *SecondFactorQuery
+}
+
+// Aggregate adds the given aggregation functions to the group-by query.
+func (sfgb *SecondFactorGroupBy) Aggregate(fns ...AggregateFunc) *SecondFactorGroupBy {
+	sfgb.fns = append(sfgb.fns, fns...)
+	return sfgb
+}
+
+// Scan applies the selector query and scans the result into the given value.
+func (sfgb *SecondFactorGroupBy) Scan(ctx context.Context, v any) error {
+	ctx = setContextOp(ctx, sfgb.build.ctx, "GroupBy")
+	if err := sfgb.build.prepareQuery(ctx); err != nil {
+		return err
+	}
+	return scanWithInterceptors[*SecondFactorQuery, *SecondFactorGroupBy](ctx, sfgb.build, sfgb, sfgb.build.inters, v)
+}
+
+func (sfgb *SecondFactorGroupBy) sqlScan(ctx context.Context, root *SecondFactorQuery, v any) error {
+	selector := root.sqlQuery(ctx).Select()
+	aggregation := make([]string, 0, len(sfgb.fns))
+	for _, fn := range sfgb.fns {
+		aggregation = append(aggregation, fn(selector))
+	}
+	if len(selector.SelectedColumns()) == 0 {
+		columns := make([]string, 0, len(*sfgb.flds)+len(sfgb.fns))
+		for _, f := range *sfgb.flds {
+			columns = append(columns, selector.C(f))
+		}
+		columns = append(columns, aggregation...)
+		selector.Select(columns...)
+	}
+	selector.GroupBy(selector.Columns(*sfgb.flds...)...)
+	if err := selector.Err(); err != nil {
+		return err
+	}
+	rows := &sql.Rows{}
+	query, args := selector.Query()
+	if err := sfgb.build.driver.Query(ctx, query, args, rows); err != nil {
+		return err
+	}
+	defer rows.Close()
+	return sql.ScanSlice(rows, v)
+}
+
+// SecondFactorSelect is the builder for selecting fields of SecondFactor entities.
+type SecondFactorSelect struct {
+	*SecondFactorQuery
+	selector
+}
+
+// Aggregate adds the given aggregation functions to the selector query.
+func (sfs *SecondFactorSelect) Aggregate(fns ...AggregateFunc) *SecondFactorSelect {
+	sfs.fns = append(sfs.fns, fns...)
+	return sfs
+}
+
+// Scan applies the selector query and scans the result into the given value.
+func (sfs *SecondFactorSelect) Scan(ctx context.Context, v any) error {
+	ctx = setContextOp(ctx, sfs.ctx, "Select")
+	if err := sfs.prepareQuery(ctx); err != nil {
+		return err
+	}
+	return scanWithInterceptors[*SecondFactorQuery, *SecondFactorSelect](ctx, sfs.SecondFactorQuery, sfs, sfs.inters, v)
+}
+
+func (sfs *SecondFactorSelect) sqlScan(ctx context.Context, root *SecondFactorQuery, v any) error {
+	selector := root.sqlQuery(ctx)
+	aggregation := make([]string, 0, len(sfs.fns))
+	for _, fn := range sfs.fns {
+		aggregation = append(aggregation, fn(selector))
+	}
+	switch n := len(*sfs.selector.flds); {
+	case n == 0 && len(aggregation) > 0:
+		selector.Select(aggregation...)
+	case n != 0 && len(aggregation) > 0:
+		selector.AppendSelect(aggregation...)
+	}
+	rows := &sql.Rows{}
+	query, args := selector.Query()
+	if err := sfs.driver.Query(ctx, query, args, rows); err != nil {
+		return err
+	}
+	defer rows.Close()
+	return sql.ScanSlice(rows, v)
+}
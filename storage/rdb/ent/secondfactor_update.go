@@ -0,0 +1,358 @@
+// Copyright 2024 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/sqlgraph"
+	"entgo.io/ent/schema/field"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/storage/rdb/ent/predicate"
+	"github.com/piprate/metalocker/storage/rdb/ent/secondfactor"
+
+	entaccount "github.com/piprate/metalocker/storage/rdb/ent/account"
+)
+
+// SecondFactorUpdate is the builder for updating SecondFactor entities.
+type SecondFactorUpdate struct {
+	config
+	hooks    []Hook
+	mutation *SecondFactorMutation
+}
+
+// Where appends a list predicates to the SecondFactorUpdate builder.
+func (sfu *SecondFactorUpdate) Where(ps ...predicate.SecondFactor) *SecondFactorUpdate {
+	sfu.mutation.Where(ps...)
+	return sfu
+}
+
+// SetFid sets the "fid" field.
+func (sfu *SecondFactorUpdate) SetFid(s string) *SecondFactorUpdate {
+	sfu.mutation.SetFid(s)
+	return sfu
+}
+
+// SetBody sets the "body" field.
+func (sfu *SecondFactorUpdate) SetBody(af *account.SecondFactor) *SecondFactorUpdate {
+	sfu.mutation.SetBody(af)
+	return sfu
+}
+
+// SetAccountID sets the "account" edge to the Account entity by ID.
+func (sfu *SecondFactorUpdate) SetAccountID(id int) *SecondFactorUpdate {
+	sfu.mutation.SetAccountID(id)
+	return sfu
+}
+
+// SetNillableAccountID sets the "account" edge to the Account entity by ID if the given value is not nil.
+func (sfu *SecondFactorUpdate) SetNillableAccountID(id *int) *SecondFactorUpdate {
+	if id != nil {
+		sfu = sfu.SetAccountID(*id)
+	}
+	return sfu
+}
+
+// SetAccount sets the "account" edge to the Account entity.
+func (sfu *SecondFactorUpdate) SetAccount(a *Account) *SecondFactorUpdate {
+	return sfu.SetAccountID(a.ID)
+}
+
+// Mutation returns the SecondFactorMutation object of the builder.
+func (sfu *SecondFactorUpdate) Mutation() *SecondFactorMutation {
+	return sfu.mutation
+}
+
+// ClearAccount clears the "account" edge to the Account entity.
+func (sfu *SecondFactorUpdate) ClearAccount() *SecondFactorUpdate {
+	sfu.mutation.ClearAccount()
+	return sfu
+}
+
+// Save executes the query and returns the number of nodes affected by the update operation.
+func (sfu *SecondFactorUpdate) Save(ctx context.Context) (int, error) {
+	return withHooks[int, SecondFactorMutation](ctx, sfu.sqlSave, sfu.mutation, sfu.hooks)
+}
+
+// SaveX is like Save, but panics if an error occurs.
+func (sfu *SecondFactorUpdate) SaveX(ctx context.Context) int {
+	affected, err := sfu.Save(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return affected
+}
+
+// Exec executes the query.
+func (sfu *SecondFactorUpdate) Exec(ctx context.Context) error {
+	_, err := sfu.Save(ctx)
+	return err
+}
+
+// ExecX is like Exec, but panics if an error occurs.
+func (sfu *SecondFactorUpdate) ExecX(ctx context.Context) {
+	if err := sfu.Exec(ctx); err != nil {
+		panic(err)
+	}
+}
+
+func (sfu *SecondFactorUpdate) sqlSave(ctx context.Context) (n int, err error) {
+	_spec := &sqlgraph.UpdateSpec{
+		Node: &sqlgraph.NodeSpec{
+			Table:   secondfactor.Table,
+			Columns: secondfactor.Columns,
+			ID: &sqlgraph.FieldSpec{
+				Type:   field.TypeInt,
+				Column: secondfactor.FieldID,
+			},
+		},
+	}
+	if ps := sfu.mutation.predicates; len(ps) > 0 {
+		_spec.Predicate = func(selector *sql.Selector) {
+			for i := range ps {
+				ps[i](selector)
+			}
+		}
+	}
+	if value, ok := sfu.mutation.Fid(); ok {
+		_spec.SetField(secondfactor.FieldFid, field.TypeString, value)
+	}
+	if value, ok := sfu.mutation.Body(); ok {
+		_spec.SetField(secondfactor.FieldBody, field.TypeJSON, value)
+	}
+	if sfu.mutation.AccountCleared() {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.M2O,
+			Inverse: true,
+			Table:   secondfactor.AccountTable,
+			Columns: []string{secondfactor.AccountColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: entaccount.FieldID,
+				},
+			},
+		}
+		_spec.Edges.Clear = append(_spec.Edges.Clear, edge)
+	}
+	if nodes := sfu.mutation.AccountIDs(); len(nodes) > 0 {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.M2O,
+			Inverse: true,
+			Table:   secondfactor.AccountTable,
+			Columns: []string{secondfactor.AccountColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: entaccount.FieldID,
+				},
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_spec.Edges.Add = append(_spec.Edges.Add, edge)
+	}
+	if n, err = sqlgraph.UpdateNodes(ctx, sfu.driver, _spec); err != nil {
+		if _, ok := err.(*sqlgraph.NotFoundError); ok {
+			err = &NotFoundError{secondfactor.Label}
+		} else if sqlgraph.IsConstraintError(err) {
+			err = &ConstraintError{msg: err.Error(), wrap: err}
+		}
+		return 0, err
+	}
+	sfu.mutation.done = true
+	return n, nil
+}
+
+// SecondFactorUpdateOne is the builder for updating a single SecondFactor entity.
+type SecondFactorUpdateOne struct {
+	config
+	fields   []string
+	hooks    []Hook
+	mutation *SecondFactorMutation
+}
+
+// SetFid sets the "fid" field.
+func (sfuo *SecondFactorUpdateOne) SetFid(s string) *SecondFactorUpdateOne {
+	sfuo.mutation.SetFid(s)
+	return sfuo
+}
+
+// SetBody sets the "body" field.
+func (sfuo *SecondFactorUpdateOne) SetBody(af *account.SecondFactor) *SecondFactorUpdateOne {
+	sfuo.mutation.SetBody(af)
+	return sfuo
+}
+
+// SetAccountID sets the "account" edge to the Account entity by ID.
+func (sfuo *SecondFactorUpdateOne) SetAccountID(id int) *SecondFactorUpdateOne {
+	sfuo.mutation.SetAccountID(id)
+	return sfuo
+}
+
+// SetNillableAccountID sets the "account" edge to the Account entity by ID if the given value is not nil.
+func (sfuo *SecondFactorUpdateOne) SetNillableAccountID(id *int) *SecondFactorUpdateOne {
+	if id != nil {
+		sfuo = sfuo.SetAccountID(*id)
+	}
+	return sfuo
+}
+
+// SetAccount sets the "account" edge to the Account entity.
+func (sfuo *SecondFactorUpdateOne) SetAccount(a *Account) *SecondFactorUpdateOne {
+	return sfuo.SetAccountID(a.ID)
+}
+
+// Mutation returns the SecondFactorMutation object of the builder.
+func (sfuo *SecondFactorUpdateOne) Mutation() *SecondFactorMutation {
+	return sfuo.mutation
+}
+
+// ClearAccount clears the "account" edge to the Account entity.
+func (sfuo *SecondFactorUpdateOne) ClearAccount() *SecondFactorUpdateOne {
+	sfuo.mutation.ClearAccount()
+	return sfuo
+}
+
+// Select allows selecting one or more fields (columns) of the returned entity.
+// The default is selecting all fields defined in the entity schema.
+func (sfuo *SecondFactorUpdateOne) Select(field string, fields ...string) *SecondFactorUpdateOne {
+	sfuo.fields = append([]string{field}, fields...)
+	return sfuo
+}
+
+// Save executes the query and returns the updated SecondFactor entity.
+func (sfuo *SecondFactorUpdateOne) Save(ctx context.Context) (*SecondFactor, error) {
+	return withHooks[*SecondFactor, SecondFactorMutation](ctx, sfuo.sqlSave, sfuo.mutation, sfuo.hooks)
+}
+
+// SaveX is like Save, but panics if an error occurs.
+func (sfuo *SecondFactorUpdateOne) SaveX(ctx context.Context) *SecondFactor {
+	node, err := sfuo.Save(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+// Exec executes the query on the entity.
+func (sfuo *SecondFactorUpdateOne) Exec(ctx context.Context) error {
+	_, err := sfuo.Save(ctx)
+	return err
+}
+
+// ExecX is like Exec, but panics if an error occurs.
+func (sfuo *SecondFactorUpdateOne) ExecX(ctx context.Context) {
+	if err := sfuo.Exec(ctx); err != nil {
+		panic(err)
+	}
+}
+
+func (sfuo *SecondFactorUpdateOne) sqlSave(ctx context.Context) (_node *SecondFactor, err error) {
+	_spec := &sqlgraph.UpdateSpec{
+		Node: &sqlgraph.NodeSpec{
+			Table:   secondfactor.Table,
+			Columns: secondfactor.Columns,
+			ID: &sqlgraph.FieldSpec{
+				Type:   field.TypeInt,
+				Column: secondfactor.FieldID,
+			},
+		},
+	}
+	id, ok := sfuo.mutation.ID()
+	if !ok {
+		return nil, &ValidationError{Name: "id", err: errors.New(`ent: missing "SecondFactor.id" for update`)}
+	}
+	_spec.Node.ID.Value = id
+	if fields := sfuo.fields; len(fields) > 0 {
+		_spec.Node.Columns = make([]string, 0, len(fields))
+		_spec.Node.Columns = append(_spec.Node.Columns, secondfactor.FieldID)
+		for _, f := range fields {
+			if !secondfactor.ValidColumn(f) {
+				return nil, &ValidationError{Name: f, err: fmt.Errorf("ent: invalid field %q for query", f)}
+			}
+			if f != secondfactor.FieldID {
+				_spec.Node.Columns = append(_spec.Node.Columns, f)
+			}
+		}
+	}
+	if ps := sfuo.mutation.predicates; len(ps) > 0 {
+		_spec.Predicate = func(selector *sql.Selector) {
+			for i := range ps {
+				ps[i](selector)
+			}
+		}
+	}
+	if value, ok := sfuo.mutation.Fid(); ok {
+		_spec.SetField(secondfactor.FieldFid, field.TypeString, value)
+	}
+	if value, ok := sfuo.mutation.Body(); ok {
+		_spec.SetField(secondfactor.FieldBody, field.TypeJSON, value)
+	}
+	if sfuo.mutation.AccountCleared() {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.M2O,
+			Inverse: true,
+			Table:   secondfactor.AccountTable,
+			Columns: []string{secondfactor.AccountColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: entaccount.FieldID,
+				},
+			},
+		}
+		_spec.Edges.Clear = append(_spec.Edges.Clear, edge)
+	}
+	if nodes := sfuo.mutation.AccountIDs(); len(nodes) > 0 {
+		edge := &sqlgraph.EdgeSpec{
+			Rel:     sqlgraph.M2O,
+			Inverse: true,
+			Table:   secondfactor.AccountTable,
+			Columns: []string{secondfactor.AccountColumn},
+			Bidi:    false,
+			Target: &sqlgraph.EdgeTarget{
+				IDSpec: &sqlgraph.FieldSpec{
+					Type:   field.TypeInt,
+					Column: entaccount.FieldID,
+				},
+			},
+		}
+		for _, k := range nodes {
+			edge.Target.Nodes = append(edge.Target.Nodes, k)
+		}
+		_spec.Edges.Add = append(_spec.Edges.Add, edge)
+	}
+	_node = &SecondFactor{config: sfuo.config}
+	_spec.Assign = _node.assignValues
+	_spec.ScanValues = _node.scanValues
+	if err = sqlgraph.UpdateNode(ctx, sfuo.driver, _spec); err != nil {
+		if _, ok := err.(*sqlgraph.NotFoundError); ok {
+			err = &NotFoundError{secondfactor.Label}
+		} else if sqlgraph.IsConstraintError(err) {
+			err = &ConstraintError{msg: err.Error(), wrap: err}
+		}
+		return nil, err
+	}
+	sfuo.mutation.done = true
+	return _node, nil
+}
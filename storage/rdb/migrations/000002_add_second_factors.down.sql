@@ -0,0 +1 @@
+DROP TABLE second_factors;
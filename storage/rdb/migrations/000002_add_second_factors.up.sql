@@ -0,0 +1,7 @@
+BEGIN;
+
+CREATE TABLE "second_factors" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "fid" character varying NOT NULL, "body" jsonb NOT NULL, "account" bigint NULL, PRIMARY KEY ("id"), CONSTRAINT "second_factors_accounts_second_factors" FOREIGN KEY ("account") REFERENCES "accounts" ("id") ON DELETE SET NULL);
+CREATE UNIQUE INDEX "second_factors_fid_key" ON "second_factors" ("fid");
+CREATE UNIQUE INDEX "secondfactor_fid" ON "second_factors" ("fid");
+
+COMMIT;
@@ -0,0 +1,169 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shamir implements Shamir's secret sharing over GF(2^8).
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+var (
+	ErrInvalidParameters = errors.New("invalid secret sharing parameters")
+	ErrInvalidShares     = errors.New("invalid secret shares")
+)
+
+// Split splits the secret into the given number of shares. Any threshold
+// number of shares can reconstruct the secret (see Combine). Each share is
+// one byte longer than the secret: the last byte is the share's
+// x coordinate.
+func Split(secret []byte, shares, threshold int) ([][]byte, error) {
+	if len(secret) == 0 || threshold < 2 || shares < threshold || shares > 255 {
+		return nil, ErrInvalidParameters
+	}
+
+	// pick unique non-zero x coordinates
+
+	xs, err := randomCoordinates(shares)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([][]byte, shares)
+	for i := range res {
+		res[i] = make([]byte, len(secret)+1)
+		res[i][len(secret)] = xs[i]
+	}
+
+	coeffs := make([]byte, threshold)
+	for idx, b := range secret {
+		// random polynomial of degree threshold-1 with the secret byte as its intercept
+		coeffs[0] = b
+		if _, err = rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i, x := range xs {
+			res[i][idx] = evaluate(coeffs, x)
+		}
+	}
+
+	for i := range coeffs {
+		coeffs[i] = 0
+	}
+
+	return res, nil
+}
+
+// Combine reconstructs the secret from the given shares. It doesn't detect
+// if the number of shares is below the threshold used to split the secret:
+// in this case, the result is a random value.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrInvalidShares
+	}
+
+	size := len(shares[0])
+	if size < 2 {
+		return nil, ErrInvalidShares
+	}
+
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]bool, len(shares))
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, ErrInvalidShares
+		}
+		x := share[size-1]
+		if x == 0 || seen[x] {
+			return nil, ErrInvalidShares
+		}
+		seen[x] = true
+		xs[i] = x
+	}
+
+	// Lagrange interpolation at x = 0
+
+	secret := make([]byte, size-1)
+	for i, share := range shares {
+		basis := byte(1)
+		for j, xj := range xs {
+			if i != j {
+				basis = mul(basis, mul(xj, inverse(xj^xs[i])))
+			}
+		}
+		for idx := range secret {
+			secret[idx] ^= mul(share[idx], basis)
+		}
+	}
+
+	return secret, nil
+}
+
+func randomCoordinates(n int) ([]byte, error) {
+	xs := make([]byte, 255)
+	for i := range xs {
+		xs[i] = byte(i + 1)
+	}
+
+	// Fisher-Yates shuffle of the first n positions
+
+	buf := make([]byte, 2)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		j := i + int(uint16(buf[0])<<8|uint16(buf[1]))%(len(xs)-i)
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+
+	return xs[:n], nil
+}
+
+// evaluate returns the value of the polynomial with the given coefficients at x.
+func evaluate(coeffs []byte, x byte) byte {
+	res := coeffs[len(coeffs)-1]
+	for i := len(coeffs) - 2; i >= 0; i-- {
+		res = mul(res, x) ^ coeffs[i]
+	}
+	return res
+}
+
+// mul multiplies two elements of GF(2^8) with the AES reduction polynomial,
+// without data-dependent branches.
+func mul(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= -(b & 1) & a
+		hi := -(a >> 7)
+		a = (a << 1) ^ (0x1b & hi)
+		b >>= 1
+	}
+	return p
+}
+
+// inverse returns the multiplicative inverse of a non-zero element of GF(2^8), which is a^254.
+func inverse(a byte) byte {
+	b := mul(a, a)   // a^2
+	c := mul(a, b)   // a^3
+	b = mul(c, c)    // a^6
+	b = mul(b, b)    // a^12
+	c = mul(b, c)    // a^15
+	b = mul(b, b)    // a^24
+	b = mul(b, b)    // a^48
+	b = mul(b, c)    // a^63
+	b = mul(b, b)    // a^126
+	b = mul(a, b)    // a^127
+	return mul(b, b) // a^254
+}
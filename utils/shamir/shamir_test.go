@@ -0,0 +1,82 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shamir_test
+
+import (
+	"testing"
+
+	. "github.com/piprate/metalocker/utils/shamir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAndCombine(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	shares, err := Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+	for _, share := range shares {
+		assert.Len(t, share, len(secret)+1)
+	}
+
+	// any 3 shares reconstruct the secret
+
+	for i := 0; i < 5; i++ {
+		for j := i + 1; j < 5; j++ {
+			for k := j + 1; k < 5; k++ {
+				res, err := Combine([][]byte{shares[i], shares[j], shares[k]})
+				require.NoError(t, err)
+				assert.Equal(t, secret, res)
+			}
+		}
+	}
+
+	// more shares than needed also work
+
+	res, err := Combine(shares)
+	require.NoError(t, err)
+	assert.Equal(t, secret, res)
+
+	// fewer shares don't
+
+	res, err = Combine(shares[:2])
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, res)
+}
+
+func TestSplit_InvalidParameters(t *testing.T) {
+	for _, params := range [][2]int{{3, 1}, {2, 3}, {256, 3}} {
+		_, err := Split([]byte("secret"), params[0], params[1])
+		assert.ErrorIs(t, err, ErrInvalidParameters)
+	}
+
+	_, err := Split(nil, 3, 2)
+	assert.ErrorIs(t, err, ErrInvalidParameters)
+}
+
+func TestCombine_InvalidShares(t *testing.T) {
+	shares, err := Split([]byte("secret"), 3, 2)
+	require.NoError(t, err)
+
+	_, err = Combine(shares[:1])
+	assert.ErrorIs(t, err, ErrInvalidShares)
+
+	_, err = Combine([][]byte{shares[0], shares[0]})
+	assert.ErrorIs(t, err, ErrInvalidShares)
+
+	_, err = Combine([][]byte{shares[0], shares[1][1:]})
+	assert.ErrorIs(t, err, ErrInvalidShares)
+}
@@ -87,6 +87,15 @@ func (lbm *LocalBlobManager) GetVaultMap(ctx context.Context) (map[string]*model
 	return lbm.propMap, nil
 }
 
+// Vaults returns all the vaults registered with the blob manager.
+func (lbm *LocalBlobManager) Vaults() []Vault {
+	res := make([]Vault, 0, len(lbm.vaultMap))
+	for _, v := range lbm.vaultMap {
+		res = append(res, v)
+	}
+	return res
+}
+
 func (lbm *LocalBlobManager) GetVault(id string) (Vault, error) {
 	v, found := lbm.vaultMap[id]
 	if !found {
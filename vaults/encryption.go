@@ -16,8 +16,10 @@ package vaults
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"hash"
 	"io"
 	"sync"
 
@@ -27,70 +29,68 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// ParamClientEncryption is the key in StoredResource.Params that defines the format
+	// of client side encrypted blobs. If the key is absent, the blob was encrypted in one pass
+	// using model.EncryptAESCGM. This key is reserved: vault implementations must ignore it
+	// and must not use it for their own parameters.
+	ParamClientEncryption = "cse"
+
+	// ClientEncryptionStreamV1 is the chunked AES-GCM format produced by model.NewAESGCMStreamWriter.
+	ClientEncryptionStreamV1 = "aes-gcm-stream-v1"
+)
+
 type blobSenderFn func(data io.Reader, vaultID string) (*model.StoredResource, error)
 
 // SendBlob sends the given blob to a vault and takes care of building StoredResource and applying
-// encryption where necessary.
+// encryption where necessary. The blob is streamed to the vault in bounded memory.
 func SendBlob(r io.Reader, vaultID string, cleartext bool, senderFn blobSenderFn) (*model.StoredResource, error) {
 
 	pr, pw := io.Pipe()
 
 	ssw := streams.NewStreamStatsWriter()
 
-	mw := io.MultiWriter(ssw, pw)
+	var encKey *model.AESKey
+	if !cleartext {
+		// encrypt the blob on the client side
+		encKey = model.NewEncryptionKey()
+	}
 
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 
 	var copyErr error
 	go func() {
+		defer wg.Done()
 
-		defer pw.Close()
-
-		if _, copyErr = io.Copy(mw, r); copyErr != nil {
-			return
-		}
+		copyErr = copyBlob(pw, ssw, r, encKey)
 
-		wg.Done()
+		_ = pw.CloseWithError(copyErr)
 	}()
 
-	var res *model.StoredResource
-	if cleartext {
-		var err error
-		res, err = senderFn(pr, vaultID)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-
-		// encrypt the blob on the client side
+	res, err := senderFn(pr, vaultID)
 
-		encKey := model.NewEncryptionKey()
+	// unblock the copying goroutine, if the sender didn't consume the whole stream
+	_ = pr.CloseWithError(io.ErrClosedPipe)
 
-		b, err := io.ReadAll(pr)
-		if err != nil {
-			return nil, err
-		}
-
-		encryptedData, err := model.EncryptAESCGM(b, encKey)
-		if err != nil {
-			return nil, err
-		}
-
-		res, err = senderFn(bytes.NewReader(encryptedData), vaultID)
-		if err != nil {
-			return nil, err
-		}
+	wg.Wait()
 
-		res.EncryptionKey = base64.StdEncoding.EncodeToString(encKey[:])
+	if err != nil {
+		return nil, err
 	}
 
-	wg.Wait()
-
 	if copyErr != nil {
 		return nil, copyErr
 	}
 
+	if !cleartext {
+		res.EncryptionKey = base64.StdEncoding.EncodeToString(encKey[:])
+		if res.Params == nil {
+			res.Params = make(map[string]any)
+		}
+		res.Params[ParamClientEncryption] = ClientEncryptionStreamV1
+	}
+
 	stats := ssw.Stats()
 
 	res.Asset = model.BuildDigitalAssetIDWithFingerprint(stats.SHA256Hash, "")
@@ -100,19 +100,64 @@ func SendBlob(r io.Reader, vaultID string, cleartext bool, senderFn blobSenderFn
 	return res, nil
 }
 
+// copyBlob copies the blob from r to w, collecting its statistics and encrypting
+// the output if encKey is not nil.
+func copyBlob(w io.Writer, ssw *streams.StreamStatsWriter, r io.Reader, encKey *model.AESKey) error {
+	var encWriter io.WriteCloser
+	if encKey != nil {
+		var err error
+		encWriter, err = model.NewAESGCMStreamWriter(w, encKey)
+		if err != nil {
+			return err
+		}
+		w = encWriter
+	}
+
+	if _, err := io.Copy(io.MultiWriter(ssw, w), r); err != nil {
+		return err
+	}
+
+	if encWriter != nil {
+		return encWriter.Close()
+	}
+
+	return nil
+}
+
 type blobReceiverFn func(res *model.StoredResource, accessToken string) (io.ReadCloser, error)
 
-// ReceiveBlob returns a decrypted blob stream from the vault (either local or remote)
+// ReceiveBlob returns a decrypted blob stream from the vault (either local or remote).
+// For client side encrypted blobs, each chunk is authenticated before it's returned, but
+// the stream is checked against the resource's asset ID only when it's exhausted. This means
+// plaintext reaches the caller before the asset ID check completes: if the check fails,
+// the Read call that reaches EOF returns an error instead of io.EOF. Callers MUST read
+// the stream to EOF to get the integrity check; a caller that stops reading early never
+// sees a mismatch.
 func ReceiveBlob(res *model.StoredResource, accessToken string, receiverFn blobReceiverFn) (io.ReadCloser, error) {
 	if res.EncryptionKey == "" {
 		// server side encryption
 		return receiverFn(res, accessToken)
-	} else {
-		// client side encryption
-		r, err := receiverFn(res, accessToken)
+	}
+
+	// client side encryption
+
+	r, err := receiverFn(res, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	format, _ := res.Params[ParamClientEncryption].(string)
+	switch format {
+	case ClientEncryptionStreamV1:
+		dr, err := model.NewAESGCMStreamReader(r, res.GetEncryptionKey())
 		if err != nil {
+			_ = r.Close()
 			return nil, err
 		}
+
+		return newAssetVerifyingReader(dr, r, res.Asset)
+	case "":
+		// legacy format: the whole blob is encrypted in one pass
 		defer r.Close()
 
 		encryptedFileBytes, err := io.ReadAll(r)
@@ -136,5 +181,53 @@ func ReceiveBlob(res *model.StoredResource, accessToken string, receiverFn blobR
 		}
 
 		return io.NopCloser(bytes.NewReader(fileBytes)), nil
+	default:
+		_ = r.Close()
+		return nil, fmt.Errorf("unsupported client side encryption format: %s", format)
+	}
+}
+
+// assetVerifyingReader calculates the SHA256 fingerprint of the data that passes through it
+// and checks it against the expected asset ID when the underlying stream is exhausted.
+type assetVerifyingReader struct {
+	r      io.Reader
+	closer io.Closer
+	hasher hash.Hash
+	method string
+	asset  string
+}
+
+func newAssetVerifyingReader(r io.Reader, closer io.Closer, asset string) (io.ReadCloser, error) {
+	method, err := model.ExtractDIDMethod(asset)
+	if err != nil {
+		_ = closer.Close()
+		return nil, err
 	}
+
+	return &assetVerifyingReader{
+		r:      r,
+		closer: closer,
+		hasher: sha256.New(),
+		method: method,
+		asset:  asset,
+	}, nil
+}
+
+func (avr *assetVerifyingReader) Read(p []byte) (int, error) {
+	n, err := avr.r.Read(p)
+	if n > 0 {
+		_, _ = avr.hasher.Write(p[:n])
+	}
+	if err == io.EOF {
+		controlID := model.BuildDigitalAssetIDWithFingerprint(avr.hasher.Sum(nil), avr.method)
+		if controlID != avr.asset {
+			log.Warn().Str("id", avr.asset).Msg("Mismatch found between a blob and its asset ID")
+			return n, fmt.Errorf("mismatch found between a blob and its asset ID: %s", avr.asset)
+		}
+	}
+	return n, err
+}
+
+func (avr *assetVerifyingReader) Close() error {
+	return avr.closer.Close()
 }
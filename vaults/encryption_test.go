@@ -0,0 +1,147 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vaults_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/fingerprint"
+	. "github.com/piprate/metalocker/vaults"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type blobStore map[string][]byte
+
+func (bs blobStore) send(data io.Reader, vaultID string) (*model.StoredResource, error) {
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	id := model.NewAssetID("")
+	bs[id] = b
+	return &model.StoredResource{
+		ID:     id,
+		Type:   model.TypeResource,
+		Vault:  vaultID,
+		Method: "test",
+	}, nil
+}
+
+func (bs blobStore) receive(res *model.StoredResource, accessToken string) (io.ReadCloser, error) {
+	b, found := bs[res.ID]
+	if !found {
+		return nil, model.ErrBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func TestSendReceiveBlob(t *testing.T) {
+	bs := blobStore{}
+
+	data := make([]byte, 3*model.StreamChunkSize+5)
+	_, _ = rand.Read(data)
+
+	expectedAsset, err := model.BuildDigitalAssetID(data, fingerprint.AlgoSha256, "")
+	require.NoError(t, err)
+
+	// client side encryption
+
+	res, err := SendBlob(bytes.NewReader(data), "vault1", false, bs.send)
+	require.NoError(t, err)
+	assert.NotEmpty(t, res.EncryptionKey)
+	assert.Equal(t, ClientEncryptionStreamV1, res.Params[ParamClientEncryption])
+	assert.Equal(t, expectedAsset, res.Asset)
+	assert.Equal(t, int64(len(data)), res.Size)
+	assert.NotEqual(t, data, bs[res.ID])
+
+	r, err := ReceiveBlob(res, "", bs.receive)
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, data, b)
+
+	// cleartext
+
+	res, err = SendBlob(bytes.NewReader(data), "vault1", true, bs.send)
+	require.NoError(t, err)
+	assert.Empty(t, res.EncryptionKey)
+	assert.Nil(t, res.Params)
+	assert.Equal(t, expectedAsset, res.Asset)
+	assert.Equal(t, data, bs[res.ID])
+}
+
+func TestReceiveBlob_AssetMismatch(t *testing.T) {
+	bs := blobStore{}
+
+	res, err := SendBlob(bytes.NewReader([]byte("test blob")), "vault1", false, bs.send)
+	require.NoError(t, err)
+
+	res.Asset, err = model.BuildDigitalAssetID([]byte("another blob"), fingerprint.AlgoSha256, "")
+	require.NoError(t, err)
+
+	r, err := ReceiveBlob(res, "", bs.receive)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mismatch found between a blob and its asset ID")
+}
+
+func TestReceiveBlob_TamperedChunk(t *testing.T) {
+	bs := blobStore{}
+
+	data := make([]byte, 2*model.StreamChunkSize+5)
+	_, _ = rand.Read(data)
+
+	res, err := SendBlob(bytes.NewReader(data), "vault1", false, bs.send)
+	require.NoError(t, err)
+
+	// flip a bit inside the second chunk
+	bs[res.ID][model.StreamNoncePrefixSize+model.StreamChunkSize+model.StreamTagSize+10] ^= 0x01
+
+	r, err := ReceiveBlob(res, "", bs.receive)
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.Error(t, err)
+	// only the first, untouched chunk is returned
+	assert.Equal(t, data[:model.StreamChunkSize], b)
+}
+
+func TestReceiveBlob_LegacyFormat(t *testing.T) {
+	bs := blobStore{}
+
+	data := []byte("test blob")
+	encKey := model.NewEncryptionKey()
+	encryptedData, err := model.EncryptAESCGM(data, encKey)
+	require.NoError(t, err)
+
+	res, err := bs.send(bytes.NewReader(encryptedData), "vault1")
+	require.NoError(t, err)
+	res.EncryptionKey = base64.StdEncoding.EncodeToString(encKey[:])
+	res.Asset, err = model.BuildDigitalAssetID(data, fingerprint.AlgoSha256, "")
+	require.NoError(t, err)
+
+	r, err := ReceiveBlob(res, "", bs.receive)
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, b)
+}
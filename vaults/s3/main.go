@@ -0,0 +1,356 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/sdk/cmdbase"
+	"github.com/piprate/metalocker/utils"
+	"github.com/piprate/metalocker/vaults"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	VaultType = "s3"
+
+	// defaultPartSize is the size of multipart upload chunks. The vault never holds
+	// more than one part of a blob in memory.
+	defaultPartSize = 16 * 1024 * 1024
+)
+
+func init() {
+	vaults.Register(VaultType, CreateVault)
+}
+
+// S3Vault stores blobs in an S3-compatible object store (AWS S3, MinIO, etc.).
+//
+// Supported parameters:
+//
+//	bucket      - bucket name (required)
+//	endpoint    - object store endpoint, e.g. 's3.amazonaws.com' or 'localhost:9000' (required)
+//	prefix      - key prefix for all the blobs stored by the vault
+//	region      - bucket region
+//	secure      - use HTTPS to connect to the endpoint (default: true)
+//	path_style  - use path-style bucket addressing instead of virtual-host style
+//	access_key  - access key ID (can be a secure parameter)
+//	secret_key  - secret access key (can be a secure parameter)
+//	part_size   - size of multipart upload parts in bytes
+type S3Vault struct {
+	id        string
+	name      string
+	bucket    string
+	keyPrefix string
+	didPrefix string
+	partSize  uint64
+	sse       bool
+	cas       bool
+	client    *minio.Client
+	verifier  model.AccessVerifier
+}
+
+var _ vaults.Vault = (*S3Vault)(nil)
+
+func (v *S3Vault) CAS() bool {
+	return v.cas
+}
+
+func (v *S3Vault) SSE() bool {
+	return v.sse
+}
+
+func (v *S3Vault) objectKey(id string) string {
+	return v.keyPrefix + model.UnwrapDigitalAssetID(id)
+}
+
+func (v *S3Vault) CreateBlob(ctx context.Context, r io.Reader) (*model.StoredResource, error) {
+
+	res := &model.StoredResource{
+		Type:   model.TypeResource,
+		Vault:  v.id,
+		Method: VaultType,
+	}
+
+	// for CAS vaults, we only know the blob's ID once it's been fully read.
+	// To avoid buffering the blob, we upload it under a temporary key
+	// and then copy it to its content-addressable location.
+
+	fileName, err := utils.RandomID(32)
+	if err != nil {
+		return nil, err
+	}
+
+	var key string
+	if v.cas {
+		key = v.keyPrefix + "tmp/" + fileName
+	} else {
+		res.ID = v.didPrefix + fileName
+		key = v.objectKey(res.ID)
+	}
+
+	hasher := sha256.New()
+	r = io.TeeReader(r, hasher)
+
+	if v.sse {
+		encKey := model.NewEncryptionKey()
+
+		src := r
+		pr, pw := io.Pipe()
+		go func() {
+			ew, err := model.NewAESGCMStreamWriter(pw, encKey)
+			if err == nil {
+				if _, err = io.Copy(ew, src); err == nil {
+					err = ew.Close()
+				}
+			}
+			_ = pw.CloseWithError(err)
+		}()
+		defer pr.Close()
+
+		r = pr
+
+		res.Params = map[string]any{
+			"sseKey": base64.StdEncoding.EncodeToString(encKey[:]),
+		}
+	}
+
+	info, err := v.client.PutObject(ctx, v.bucket, key, r, -1, minio.PutObjectOptions{
+		PartSize:    v.partSize,
+		ContentType: "application/octet-stream",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if v.cas {
+		res.ID = model.BuildDigitalAssetIDWithFingerprint(hasher.Sum(nil), "")
+
+		_, err = v.client.ComposeObject(ctx,
+			minio.CopyDestOptions{Bucket: v.bucket, Object: v.objectKey(res.ID)},
+			minio.CopySrcOptions{Bucket: v.bucket, Object: key},
+		)
+		if rmErr := v.client.RemoveObject(ctx, v.bucket, key, minio.RemoveObjectOptions{}); rmErr != nil {
+			log.Err(rmErr).Str("key", key).Msg("Failed to remove temporary blob")
+		}
+		if err != nil {
+			return nil, err
+		}
+		key = v.objectKey(res.ID)
+	}
+
+	log.Info().Str("bucket", v.bucket).Str("key", key).Int64("size", info.Size).Msg("Saved blob object")
+
+	return res, nil
+}
+
+func (v *S3Vault) PurgeBlob(ctx context.Context, id string, params map[string]any) error {
+	if v.verifier != nil {
+		state, err := v.verifier.GetDataAssetState(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		switch state {
+		case model.DataAssetStateKeep:
+			return errors.New("data asset in use and can't be purged")
+		case model.DataAssetStateNotFound:
+			return model.ErrBlobNotFound
+		case model.DataAssetStateRemove:
+			// all fine
+		}
+	}
+
+	key := v.objectKey(id)
+
+	if _, err := v.client.StatObject(ctx, v.bucket, key, minio.StatObjectOptions{}); err != nil {
+		return translateError(err)
+	}
+
+	return v.client.RemoveObject(ctx, v.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (v *S3Vault) ServeBlob(ctx context.Context, id string, params map[string]any, accessToken string) (io.ReadCloser, error) {
+	if v.verifier != nil {
+		if !model.VerifyAccessToken(ctx, accessToken, id, time.Now().Unix(), model.DefaultMaxDistanceSeconds, v.verifier) {
+			return nil, model.ErrDataAssetAccessDenied
+		}
+	}
+
+	obj, err := v.client.GetObject(ctx, v.bucket, v.objectKey(id), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	// GetObject doesn't contact the server until the first read. Stat the object
+	// to report missing blobs early.
+	if _, err = obj.Stat(); err != nil {
+		_ = obj.Close()
+		return nil, translateError(err)
+	}
+
+	if !v.sse {
+		return obj, nil
+	}
+
+	sseKeyStr, hasSSEKey := params["sseKey"].(string)
+	if !hasSSEKey {
+		_ = obj.Close()
+		return nil, errors.New("missing SSE encryption key in requested storage parameters")
+	}
+
+	keyBytes, err := base64.StdEncoding.DecodeString(sseKeyStr)
+	if err != nil {
+		_ = obj.Close()
+		return nil, err
+	}
+
+	dr, err := model.NewAESGCMStreamReader(obj, model.NewAESKey(keyBytes))
+	if err != nil {
+		_ = obj.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{dr, obj}, nil
+}
+
+func (v *S3Vault) ID() string {
+	return v.id
+}
+
+func (v *S3Vault) Name() string {
+	return v.name
+}
+
+func (v *S3Vault) Close() error {
+	log.Info().Msg("Closing S3 vault")
+	return nil
+}
+
+func translateError(err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return model.ErrBlobNotFound
+	default:
+		return err
+	}
+}
+
+func CreateVault(cfg *vaults.Config, resolver cmdbase.ParameterResolver, verifier model.AccessVerifier) (vaults.Vault, error) {
+	return NewS3Vault(cfg, resolver, verifier, nil)
+}
+
+// NewS3Vault creates a new S3 vault. If transport is nil, the default HTTP transport is used.
+func NewS3Vault(cfg *vaults.Config, resolver cmdbase.ParameterResolver, verifier model.AccessVerifier, transport http.RoundTripper) (*S3Vault, error) {
+	bucket, _ := cfg.Params["bucket"].(string)
+	if bucket == "" {
+		return nil, fmt.Errorf("parameter not found: bucket. Can't start the vault")
+	}
+	endpoint, _ := cfg.Params["endpoint"].(string)
+	if endpoint == "" {
+		return nil, fmt.Errorf("parameter not found: endpoint. Can't start the vault")
+	}
+	keyPrefix, _ := cfg.Params["prefix"].(string)
+	region, _ := cfg.Params["region"].(string)
+	pathStyle, _ := cfg.Params["path_style"].(bool)
+
+	secure := true
+	if val, found := cfg.Params["secure"]; found {
+		secure, _ = val.(bool)
+	}
+
+	partSize := uint64(defaultPartSize)
+	if val, found := cfg.Params["part_size"].(float64); found && val > 0 {
+		partSize = uint64(val)
+	} else if val, found := cfg.Params["part_size"].(int); found && val > 0 {
+		partSize = uint64(val)
+	}
+
+	accessKey, secretKey, err := resolveCredentials(cfg.Params, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketLookup := minio.BucketLookupAuto
+	if pathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
+
+	log.Info().Str("endpoint", endpoint).Str("bucket", bucket).Str("prefix", keyPrefix).
+		Msg("Initialising S3 vault")
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure:       secure,
+		Region:       region,
+		BucketLookup: bucketLookup,
+		Transport:    transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(context.Background(), bucket)
+	if err != nil {
+		return nil, fmt.Errorf("error when checking bucket %s: %w", bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket not found: %s", bucket)
+	}
+
+	return &S3Vault{
+		id:        cfg.ID,
+		name:      cfg.Name,
+		bucket:    bucket,
+		keyPrefix: keyPrefix,
+		didPrefix: model.BuildDIDPrefix(""),
+		partSize:  partSize,
+		sse:       cfg.SSE,
+		cas:       cfg.CAS,
+		client:    client,
+		verifier:  verifier,
+	}, nil
+}
+
+func resolveCredentials(params vaults.Params, resolver cmdbase.ParameterResolver) (string, string, error) {
+	if resolver == nil {
+		accessKey, _ := params["access_key"].(string)
+		secretKey, _ := params["secret_key"].(string)
+		return accessKey, secretKey, nil
+	}
+
+	accessKey, err := resolver.ResolveString(params["access_key"])
+	if err != nil {
+		return "", "", err
+	}
+	secretKey, err := resolver.ResolveString(params["secret_key"])
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessKey, secretKey, nil
+}
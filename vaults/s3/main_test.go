@@ -0,0 +1,356 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/fingerprint"
+	"github.com/piprate/metalocker/vaults"
+	. "github.com/piprate/metalocker/vaults/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal in-process stand-in for an S3-compatible object store.
+// It supports just enough of the API to exercise S3Vault.
+type fakeS3 struct {
+	bucket  string
+	mtx     sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{
+		bucket:  bucket,
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+}
+
+func (f *fakeS3) object(key string) ([]byte, bool) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	b, found := f.objects[key]
+	return b, found
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func etag(b []byte) string {
+	sum := md5.Sum(b)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && r.URL.Query().Has("location"):
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><LocationConstraint>us-east-1</LocationConstraint>`)
+		default:
+			writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+		}
+		return
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if r.URL.Query().Has("uploads") || r.URL.Query().Has("uploadId") {
+		f.serveMultipart(w, r, key)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		f.objects[key] = b
+		w.Header().Set("ETag", etag(b))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		b, found := f.objects[key]
+		if !found {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", etag(b))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(b)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(b)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func newTestVault(t *testing.T, fake *fakeS3, sse, cas bool) *S3Vault {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := &vaults.Config{
+		ID:   "Z2kcCarCE47SDjtWD5ruyijsQyWMF5B1jjk6HHWngoe",
+		Name: "s3",
+		Type: VaultType,
+		SSE:  sse,
+		CAS:  cas,
+		Params: map[string]any{
+			"bucket":     fake.bucket,
+			"prefix":     "blobs/",
+			"endpoint":   strings.TrimPrefix(srv.URL, "https://"),
+			"region":     "us-east-1",
+			"path_style": true,
+			"access_key": "key",
+			"secret_key": "secret",
+		},
+	}
+
+	v, err := NewS3Vault(cfg, nil, nil, srv.Client().Transport)
+	require.NoError(t, err)
+
+	return v
+}
+
+func readBlob(t *testing.T, v vaults.Vault, res *model.StoredResource) []byte {
+	t.Helper()
+
+	r, err := v.ServeBlob(context.Background(), res.ID, res.Params, "")
+	require.NoError(t, err)
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return b
+}
+
+func TestS3Vault_CreateBlob(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3("metalocker")
+	v := newTestVault(t, fake, false, false)
+
+	data := []byte("test blob")
+
+	res, err := v.CreateBlob(ctx, bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, VaultType, res.Method)
+	assert.Equal(t, v.ID(), res.Vault)
+	assert.Empty(t, res.Params)
+
+	stored, found := fake.object("blobs/" + model.UnwrapDigitalAssetID(res.ID))
+	require.True(t, found)
+	assert.Equal(t, data, stored)
+
+	assert.Equal(t, data, readBlob(t, v, res))
+
+	// a second upload of the same blob gets a different ID
+
+	res2, err := v.CreateBlob(ctx, bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.NotEqual(t, res.ID, res2.ID)
+}
+
+func TestS3Vault_CreateBlob_CAS(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3("metalocker")
+	v := newTestVault(t, fake, false, true)
+
+	data := []byte("test blob")
+
+	expectedID, err := model.BuildDigitalAssetID(data, fingerprint.AlgoSha256, "")
+	require.NoError(t, err)
+
+	res, err := v.CreateBlob(ctx, bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, expectedID, res.ID)
+
+	res2, err := v.CreateBlob(ctx, bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, res.ID, res2.ID)
+
+	// temporary objects are removed
+	fake.mtx.Lock()
+	assert.Len(t, fake.objects, 1)
+	fake.mtx.Unlock()
+
+	assert.Equal(t, data, readBlob(t, v, res))
+}
+
+func TestS3Vault_CreateBlob_SSE(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3("metalocker")
+	v := newTestVault(t, fake, true, false)
+
+	data := []byte("test blob")
+
+	res, err := v.CreateBlob(ctx, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Params["sseKey"])
+
+	stored, found := fake.object("blobs/" + model.UnwrapDigitalAssetID(res.ID))
+	require.True(t, found)
+	assert.NotEqual(t, data, stored)
+
+	assert.Equal(t, data, readBlob(t, v, res))
+
+	_, err = v.ServeBlob(ctx, res.ID, nil, "")
+	require.Error(t, err)
+}
+
+func TestS3Vault_PurgeBlob(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeS3("metalocker")
+	v := newTestVault(t, fake, false, true)
+
+	res, err := v.CreateBlob(ctx, bytes.NewReader([]byte("test blob")))
+	require.NoError(t, err)
+
+	require.NoError(t, v.PurgeBlob(ctx, res.ID, res.Params))
+
+	_, err = v.ServeBlob(ctx, res.ID, res.Params, "")
+	assert.ErrorIs(t, err, model.ErrBlobNotFound)
+
+	err = v.PurgeBlob(ctx, res.ID, res.Params)
+	assert.ErrorIs(t, err, model.ErrBlobNotFound)
+}
+
+func TestNewS3Vault_BucketNotFound(t *testing.T) {
+	srv := httptest.NewTLSServer(newFakeS3("metalocker"))
+	defer srv.Close()
+
+	cfg := &vaults.Config{
+		ID:   "Z2kcCarCE47SDjtWD5ruyijsQyWMF5B1jjk6HHWngoe",
+		Name: "s3",
+		Type: VaultType,
+		Params: map[string]any{
+			"bucket":   "another-bucket",
+			"endpoint": strings.TrimPrefix(srv.URL, "https://"),
+			"region":   "us-east-1",
+		},
+	}
+
+	_, err := NewS3Vault(cfg, nil, nil, srv.Client().Transport)
+	require.Error(t, err)
+
+	_, err = vaults.CreateVault(&vaults.Config{Type: VaultType, Params: map[string]any{}}, nil, nil)
+	require.Error(t, err)
+}
+
+func (f *fakeS3) serveMultipart(w http.ResponseWriter, r *http.Request, key string) {
+	q := r.URL.Query()
+	uploadID := q.Get("uploadId")
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		uploadID = fmt.Sprintf("upload-%d", len(f.uploads)+1)
+		f.uploads[uploadID] = make(map[int][]byte)
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`,
+			f.bucket, key, uploadID)
+	case r.Method == http.MethodPut:
+		parts, found := f.uploads[uploadID]
+		if !found {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		partNumber, err := strconv.Atoi(q.Get("partNumber"))
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			// server-side copy (UploadPartCopy)
+			src, _ = url.PathUnescape(src)
+			_, srcKey, _ := strings.Cut(strings.TrimPrefix(src, "/"), "/")
+			b, found := f.objects[srcKey]
+			if !found {
+				writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			var start, end int
+			if _, err = fmt.Sscanf(r.Header.Get("X-Amz-Copy-Source-Range"), "bytes=%d-%d", &start, &end); err == nil {
+				b = b[start : end+1]
+			}
+			parts[partNumber] = b
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><CopyPartResult><ETag>%s</ETag><LastModified>%s</LastModified></CopyPartResult>`,
+				etag(b), time.Now().UTC().Format(time.RFC3339))
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		parts[partNumber] = b
+		w.Header().Set("ETag", etag(b))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost:
+		parts, found := f.uploads[uploadID]
+		if !found {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var b []byte
+		for i := 1; i <= len(parts); i++ {
+			b = append(b, parts[i]...)
+		}
+		delete(f.uploads, uploadID)
+		f.objects[key] = b
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag></CompleteMultipartUploadResult>`,
+			f.bucket, key, etag(b))
+	case r.Method == http.MethodDelete:
+		delete(f.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
@@ -0,0 +1,984 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/piprate/metalocker/index"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/utils/fingerprint"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+  Dataset archive format (version 1) is a ZIP file with the following structure:
+
+    manifest.json                   - signed ArchiveManifest
+    datasets/<record ID>/record.json - ledger record
+    datasets/<record ID>/block.json  - ledger block that contains the record
+    datasets/<record ID>/proof.json  - Merkle inclusion proof for the record (optional)
+    datasets/<record ID>/lease.json  - lease (with impression and provenance)
+    datasets/<record ID>/operation.bin - lease operation, as stored in off-chain storage
+    datasets/<record ID>/operation.key - operation decryption key (not present for public records)
+    blobs/<asset hash>              - cleartext resource contents
+
+  The manifest contains SHA-256 hashes of all the other files and the public keys
+  of all the parties that signed the archive, impressions and provenance entities.
+  If the archive is exported with an archive key, leases and blobs are encrypted
+  using chunked AES-GCM.
+
+  The operation file links the lease to the ledger record: its hash should match the record's
+  operation address. Since the operation includes the original blob encryption keys, only
+  export datasets from content-addressed off-chain storage to parties you trust with them.
+*/
+
+const (
+	ArchiveVersion = 1
+
+	archiveManifestFile = "manifest.json"
+)
+
+var (
+	ErrArchiveKeyRequired       = errors.New("archive is encrypted: archive key required")
+	ErrArchiveSignatureInvalid  = errors.New("archive signature verification failed")
+	ErrArchiveCorrupted         = errors.New("archive corrupted")
+	ErrArchiveDataSetNotFound   = errors.New("dataset not found in archive")
+	ErrArchiveUnsupportedFormat = errors.New("unsupported archive format")
+)
+
+type (
+	// ArchiveManifest describes the contents of a dataset archive.
+	ArchiveManifest struct {
+		Version   int       `json:"version"`
+		CreatedAt time.Time `json:"createdAt"`
+		// Creator is the DID of the identity that signed the archive.
+		Creator   string             `json:"creator"`
+		Encrypted bool               `json:"encrypted,omitempty"`
+		DataSets  []*ArchivedDataSet `json:"datasets"`
+		// Keys maps DIDs of all signing parties to their public keys (in base58 encoding).
+		Keys map[string]string `json:"keys"`
+		// Files maps archive file names to Base64-encoded SHA-256 hashes of their contents.
+		Files map[string]string `json:"files"`
+		// Signature is the creator's Ed25519 signature of the manifest (with the empty signature field).
+		Signature string `json:"signature,omitempty"`
+	}
+
+	// ArchivedDataSet describes a dataset included into an archive.
+	ArchivedDataSet struct {
+		RecordID      string `json:"id"`
+		LockerID      string `json:"locker"`
+		ParticipantID string `json:"participant"`
+		BlockNumber   int64  `json:"block"`
+		AssetID       string `json:"asset,omitempty"`
+		ImpressionID  string `json:"impression"`
+		ContentType   string `json:"contentType,omitempty"`
+		// Blobs maps asset IDs of the dataset's resources to the archive files with their contents.
+		Blobs map[string]string `json:"blobs"`
+	}
+
+	// KeyResolver returns the public key for the given DID.
+	KeyResolver func(ctx context.Context, did string) (ed25519.PublicKey, error)
+
+	archiveOptions struct {
+		key         *model.AESKey
+		keyResolver KeyResolver
+	}
+
+	ArchiveOption func(opts *archiveOptions) error
+
+	// Archive is a read-only view of a dataset archive.
+	Archive struct {
+		zr       *zip.Reader
+		files    map[string]*zip.File
+		manifest *ArchiveManifest
+		key      *model.AESKey
+		resolver KeyResolver
+		keys     map[string]ed25519.PublicKey
+	}
+)
+
+// WithArchiveKey sets the key to encrypt (on export) or decrypt (on import) archive contents.
+func WithArchiveKey(key *model.AESKey) ArchiveOption {
+	return func(opts *archiveOptions) error {
+		opts.key = key
+		return nil
+	}
+}
+
+// WithKeyResolver sets a resolver for public keys that can't be verified using
+// the information stored in the archive.
+func WithKeyResolver(resolver KeyResolver) ArchiveOption {
+	return func(opts *archiveOptions) error {
+		opts.keyResolver = resolver
+		return nil
+	}
+}
+
+// DataWalletKeyResolver returns a key resolver that uses the data wallet to retrieve public keys.
+func DataWalletKeyResolver(dw DataWallet) KeyResolver {
+	return func(ctx context.Context, did string) (ed25519.PublicKey, error) {
+		d, err := dw.GetDID(ctx, did)
+		if err != nil {
+			return nil, err
+		}
+		return d.VerKeyValue(), nil
+	}
+}
+
+func (m *ArchiveManifest) signingBytes() []byte {
+	cpy := *m
+	cpy.Signature = ""
+	b, _ := jsonw.Marshal(&cpy)
+	return b
+}
+
+func (m *ArchiveManifest) DataSet(recordID string) *ArchivedDataSet {
+	for _, ads := range m.DataSets {
+		if ads.RecordID == recordID {
+			return ads
+		}
+	}
+	return nil
+}
+
+// AssetRevisionChain returns IDs of all the records in the data wallet's root index that contain
+// revisions of the given asset, ordered by block number.
+func AssetRevisionChain(ctx context.Context, dw DataWallet, assetID string) ([]string, error) {
+	rootIndex, err := dw.RootIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []*index.VariantRecordState
+	err = rootIndex.TraverseVariants(ctx, "", "", func(variantID string, master *index.VariantRecordState, history []*index.VariantRecordState) error {
+		for _, rs := range history {
+			if rs.AssetID == assetID && rs.Status == model.StatusPublished {
+				revisions = append(revisions, rs)
+			}
+		}
+		return nil
+	}, true, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		if revisions[i].BlockNumber != revisions[j].BlockNumber {
+			return revisions[i].BlockNumber < revisions[j].BlockNumber
+		}
+		return revisions[i].RevisionNumber < revisions[j].RevisionNumber
+	})
+
+	ids := make([]string, 0, len(revisions))
+	seen := make(map[string]bool, len(revisions))
+	for _, rs := range revisions {
+		if !seen[rs.ID] {
+			seen[rs.ID] = true
+			ids = append(ids, rs.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+type archiveWriter struct {
+	zw       *zip.Writer
+	key      *model.AESKey
+	manifest *ArchiveManifest
+}
+
+func (aw *archiveWriter) writeFile(name string, r io.Reader, encrypt bool) error {
+	f, err := aw.zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	hasher := sha256.New()
+	var w io.Writer = io.MultiWriter(f, hasher)
+
+	var encWriter io.WriteCloser
+	if encrypt && aw.key != nil {
+		encWriter, err = model.NewAESGCMStreamWriter(w, aw.key)
+		if err != nil {
+			return err
+		}
+		w = encWriter
+	}
+
+	if _, err = io.Copy(w, r); err != nil {
+		return err
+	}
+
+	if encWriter != nil {
+		if err = encWriter.Close(); err != nil {
+			return err
+		}
+	}
+
+	aw.manifest.Files[name] = base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+
+	return nil
+}
+
+func (aw *archiveWriter) writeJSON(name string, obj any, encrypt bool) error {
+	b, err := jsonw.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	return aw.writeFile(name, bytes.NewReader(b), encrypt)
+}
+
+func (aw *archiveWriter) addKey(ctx context.Context, dw DataWallet, did string) error {
+	if did == "" {
+		return nil
+	}
+	if _, found := aw.manifest.Keys[did]; found {
+		return nil
+	}
+	d, err := dw.GetDID(ctx, did)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve public key for %s: %w", did, err)
+	}
+	aw.manifest.Keys[did] = d.VerKey
+	return nil
+}
+
+func datasetFile(recordID, name string) string {
+	return path.Join("datasets", recordID, name)
+}
+
+func blobFile(assetID string) string {
+	return path.Join("blobs", model.UnwrapDigitalAssetID(assetID))
+}
+
+// ExportDataSets writes the datasets behind the given record IDs into a portable archive,
+// signed by the given identity. The archive includes leases, impressions, provenance,
+// cleartext resource contents and ledger records with their block context.
+func ExportDataSets(ctx context.Context, dw DataWallet, w io.Writer, signer *model.DID, recordIDs []string, opts ...ArchiveOption) (*ArchiveManifest, error) {
+	var options archiveOptions
+	for _, fn := range opts {
+		if err := fn(&options); err != nil {
+			return nil, err
+		}
+	}
+
+	if signer == nil || signer.SignKey == "" {
+		return nil, errors.New("archive signer must have a private key")
+	}
+
+	ledger := dw.Services().Ledger()
+
+	aw := &archiveWriter{
+		zw:  zip.NewWriter(w),
+		key: options.key,
+		manifest: &ArchiveManifest{
+			Version:   ArchiveVersion,
+			CreatedAt: time.Now().UTC(),
+			Creator:   signer.ID,
+			Encrypted: options.key != nil,
+			Keys: map[string]string{
+				signer.ID: signer.VerKey,
+			},
+			Files: make(map[string]string),
+		},
+	}
+
+	exportedBlobs := make(map[string]bool)
+	for _, rid := range recordIDs {
+		if aw.manifest.DataSet(rid) != nil {
+			continue
+		}
+
+		ds, err := dw.DataStore().Load(ctx, rid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dataset %s: %w", rid, err)
+		}
+
+		lease := ds.Lease()
+		imp := lease.Impression
+
+		log.Debug().Str("rid", rid).Msg("Exporting dataset into archive")
+
+		ads := &ArchivedDataSet{
+			RecordID:      rid,
+			LockerID:      ds.LockerID(),
+			ParticipantID: ds.ParticipantID(),
+			BlockNumber:   ds.BlockNumber(),
+			AssetID:       imp.Asset,
+			ImpressionID:  imp.ID,
+			Blobs:         make(map[string]string, len(lease.Resources)),
+		}
+		if imp.MetaResource != nil {
+			ads.ContentType = imp.MetaResource.ContentType
+		}
+
+		// ledger context
+
+		rec := ds.Record()
+		if err = aw.writeJSON(datasetFile(rid, "record.json"), rec, false); err != nil {
+			return nil, err
+		}
+
+		opBytes, err := dw.Services().OffChainStorage().GetOperation(ctx, rec.OperationAddress)
+		if err != nil {
+			return nil, err
+		}
+		opAddr, err := model.BuildDigitalAssetID(opBytes, fingerprint.AlgoSha256, "")
+		if err != nil {
+			return nil, err
+		}
+		if opAddr != rec.OperationAddress {
+			return nil, fmt.Errorf("operation for dataset %s isn't content-addressed", rid)
+		}
+		if err = aw.writeFile(datasetFile(rid, "operation.bin"), bytes.NewReader(opBytes), true); err != nil {
+			return nil, err
+		}
+		if rec.Flags&model.RecordFlagPublic == 0 {
+			l, err := dw.GetLocker(ctx, ds.LockerID())
+			if err != nil {
+				return nil, err
+			}
+			p := l.Raw().GetParticipant(ds.ParticipantID())
+			if p == nil {
+				return nil, fmt.Errorf("participant not found for dataset %s", rid)
+			}
+			symKey := p.GetOperationSymKey(rec.KeyIndex, ds.BlockNumber())
+			if err = aw.writeFile(datasetFile(rid, "operation.key"), bytes.NewReader(symKey.Bytes()), true); err != nil {
+				return nil, err
+			}
+		}
+
+		block, err := ledger.GetBlock(ctx, ds.BlockNumber())
+		if err != nil {
+			return nil, err
+		}
+		if err = aw.writeJSON(datasetFile(rid, "block.json"), block, false); err != nil {
+			return nil, err
+		}
+
+		if prover, ok := ledger.(model.RecordInclusionProver); ok {
+			proof, err := prover.GetRecordInclusionProof(ctx, rid)
+			if err != nil {
+				return nil, err
+			}
+			if err = aw.writeJSON(datasetFile(rid, "proof.json"), proof, false); err != nil {
+				return nil, err
+			}
+		}
+
+		// lease, without the original blob encryption keys
+
+		leaseBytes, err := jsonw.Marshal(lease)
+		if err != nil {
+			return nil, err
+		}
+		leaseCopy, err := model.NewLease(leaseBytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range leaseCopy.Resources {
+			res.EncryptionKey = ""
+		}
+		if err = aw.writeJSON(datasetFile(rid, "lease.json"), leaseCopy, true); err != nil {
+			return nil, err
+		}
+
+		// signing parties
+
+		if imp.Proof != nil {
+			if err = aw.addKey(ctx, dw, imp.Proof.Creator); err != nil {
+				return nil, err
+			}
+		}
+		if lease.Provenance != nil && lease.Provenance.Proof != nil {
+			if err = aw.addKey(ctx, dw, lease.Provenance.Proof.Creator); err != nil {
+				return nil, err
+			}
+		}
+
+		// blobs
+
+		for _, res := range lease.Resources {
+			name := blobFile(res.Asset)
+			ads.Blobs[res.Asset] = name
+			if exportedBlobs[name] {
+				continue
+			}
+
+			r, err := ds.Resource(ctx, res.Asset)
+			if err != nil {
+				return nil, err
+			}
+			err = aw.writeFile(name, r, true)
+			_ = r.Close()
+			if err != nil {
+				return nil, err
+			}
+			exportedBlobs[name] = true
+		}
+
+		aw.manifest.DataSets = append(aw.manifest.DataSets, ads)
+	}
+
+	aw.manifest.Signature = base64.StdEncoding.EncodeToString(signer.Sign(aw.manifest.signingBytes()))
+
+	if err := aw.writeJSON(archiveManifestFile, aw.manifest, false); err != nil {
+		return nil, err
+	}
+
+	if err := aw.zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return aw.manifest, nil
+}
+
+// OpenArchive opens a dataset archive, checks its integrity and verifies the manifest signature.
+// Use Verify to check the archived datasets.
+func OpenArchive(ctx context.Context, r io.ReaderAt, size int64, opts ...ArchiveOption) (*Archive, error) {
+	var options archiveOptions
+	for _, fn := range opts {
+		if err := fn(&options); err != nil {
+			return nil, err
+		}
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrArchiveCorrupted, err.Error())
+	}
+
+	a := &Archive{
+		zr:       zr,
+		files:    make(map[string]*zip.File, len(zr.File)),
+		key:      options.key,
+		resolver: options.keyResolver,
+		keys:     make(map[string]ed25519.PublicKey),
+	}
+	for _, f := range zr.File {
+		a.files[f.Name] = f
+	}
+
+	mf, found := a.files[archiveManifestFile]
+	if !found {
+		return nil, fmt.Errorf("%w: manifest not found", ErrArchiveCorrupted)
+	}
+	mr, err := mf.Open()
+	if err != nil {
+		return nil, err
+	}
+	err = jsonw.Decode(mr, &a.manifest)
+	_ = mr.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad manifest", ErrArchiveCorrupted)
+	}
+
+	if a.manifest.Version != ArchiveVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrArchiveUnsupportedFormat, a.manifest.Version)
+	}
+
+	// verify manifest signature
+
+	creatorKey, err := a.publicKey(ctx, a.manifest.Creator)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(a.manifest.Signature)
+	if err != nil || !ed25519.Verify(creatorKey, a.manifest.signingBytes(), sig) {
+		return nil, ErrArchiveSignatureInvalid
+	}
+
+	// check file hashes
+
+	for name, expectedHash := range a.manifest.Files {
+		f, found := a.files[name]
+		if !found {
+			return nil, fmt.Errorf("%w: file %s not found", ErrArchiveCorrupted, name)
+		}
+		fr, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, fr)
+		_ = fr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrArchiveCorrupted, err.Error())
+		}
+		if base64.StdEncoding.EncodeToString(h.Sum(nil)) != expectedHash {
+			return nil, fmt.Errorf("%w: hash mismatch for file %s", ErrArchiveCorrupted, name)
+		}
+	}
+
+	return a, nil
+}
+
+// Manifest returns the archive's manifest.
+func (a *Archive) Manifest() *ArchiveManifest {
+	return a.manifest
+}
+
+// publicKey returns the public key for the given DID. Keys from the manifest are only
+// accepted if they match the DID (for DIDs derived from their keys). Otherwise, the key
+// resolver is used.
+func (a *Archive) publicKey(ctx context.Context, did string) (ed25519.PublicKey, error) {
+	if key, found := a.keys[did]; found {
+		return key, nil
+	}
+
+	var key ed25519.PublicKey
+	if keyStr, found := a.manifest.Keys[did]; found {
+		key = base58.Decode(keyStr)
+		if len(key) != ed25519.PublicKeySize || !strings.HasSuffix(did, ":"+base58.Encode(key[:16])) {
+			key = nil
+		}
+	}
+
+	if key == nil {
+		if a.resolver == nil {
+			return nil, fmt.Errorf("unable to verify public key for %s", did)
+		}
+		var err error
+		key, err = a.resolver(ctx, did)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a.keys[did] = key
+
+	return key, nil
+}
+
+func (a *Archive) open(name string, encrypted bool) (io.ReadCloser, error) {
+	if _, registered := a.manifest.Files[name]; !registered {
+		return nil, fmt.Errorf("%w: file %s not registered in manifest", ErrArchiveCorrupted, name)
+	}
+	f, found := a.files[name]
+	if !found {
+		return nil, fmt.Errorf("%w: file %s not found", ErrArchiveCorrupted, name)
+	}
+	fr, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	if !encrypted || !a.manifest.Encrypted {
+		return fr, nil
+	}
+
+	if a.key == nil {
+		_ = fr.Close()
+		return nil, ErrArchiveKeyRequired
+	}
+
+	dr, err := model.NewAESGCMStreamReader(fr, a.key)
+	if err != nil {
+		_ = fr.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{dr, fr}, nil
+}
+
+func (a *Archive) readJSON(name string, encrypted bool, obj any) error {
+	r, err := a.open(name, encrypted)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return jsonw.Decode(r, obj)
+}
+
+func (a *Archive) hasFile(name string) bool {
+	_, found := a.manifest.Files[name]
+	return found
+}
+
+// DataSet returns a read-only dataset from the archive. Its resources are served
+// from the archive.
+func (a *Archive) DataSet(recordID string) (model.DataSet, error) {
+	return a.dataSet(recordID, nil)
+}
+
+func (a *Archive) dataSet(recordID string, blobManager model.BlobManager) (model.DataSet, error) {
+	ads := a.manifest.DataSet(recordID)
+	if ads == nil {
+		return nil, ErrArchiveDataSetNotFound
+	}
+
+	var rec model.Record
+	if err := a.readJSON(datasetFile(recordID, "record.json"), false, &rec); err != nil {
+		return nil, err
+	}
+
+	var lease model.Lease
+	if err := a.readJSON(datasetFile(recordID, "lease.json"), true, &lease); err != nil {
+		return nil, err
+	}
+
+	return dataset.NewDataSetImpl(&rec, &lease, ads.BlockNumber, ads.LockerID, ads.ParticipantID,
+		a.blobManager(ads, blobManager)), nil
+}
+
+func (a *Archive) blobManager(ads *ArchivedDataSet, fallback model.BlobManager) model.BlobManager {
+	return &archiveBlobManager{archive: a, ads: ads, BlobManager: fallback}
+}
+
+// Verify checks all the datasets in the archive: ledger record signatures and their inclusion
+// into the archived blocks, leases against the operations referenced by the records, impression
+// and provenance signatures, and asset IDs and fingerprints of all resources.
+func (a *Archive) Verify(ctx context.Context) error {
+	for _, ads := range a.manifest.DataSets {
+		if err := a.verifyDataSet(ctx, ads); err != nil {
+			return fmt.Errorf("verification failed for dataset %s: %w", ads.RecordID, err)
+		}
+	}
+	return nil
+}
+
+func (a *Archive) verifyDataSet(ctx context.Context, ads *ArchivedDataSet) error {
+	rid := ads.RecordID
+
+	// ledger record and block
+
+	var rec model.Record
+	if err := a.readJSON(datasetFile(rid, "record.json"), false, &rec); err != nil {
+		return err
+	}
+	if rec.ID != rid || rec.DeriveID() != rid {
+		return errors.New("invalid ledger record ID")
+	}
+	if rec.Operation != model.OpTypeLease {
+		return errors.New("invalid ledger record operation")
+	}
+	routingKey, err := btcec.ParsePubKey(base58.Decode(rec.RoutingKey))
+	if err != nil {
+		return fmt.Errorf("invalid ledger record routing key: %w", err)
+	}
+	valid, err := rec.Verify(routingKey)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid ledger record signature")
+	}
+
+	var block model.Block
+	if err := a.readJSON(datasetFile(rid, "block.json"), false, &block); err != nil {
+		return err
+	}
+	if block.Number != ads.BlockNumber {
+		return errors.New("block number mismatch")
+	}
+	if block.Nonce != "" && model.BlockHash(block.Number, block.ParentHash, block.Nonce, block.MerkleRoot) != block.Hash {
+		return errors.New("invalid block hash")
+	}
+
+	if proofFile := datasetFile(rid, "proof.json"); a.hasFile(proofFile) {
+		var proof model.RecordInclusionProof
+		if err := a.readJSON(proofFile, false, &proof); err != nil {
+			return err
+		}
+		if proof.RecordID != rid {
+			return errors.New("inclusion proof doesn't match the record")
+		}
+		if err := proof.Verify(&block); err != nil {
+			return err
+		}
+	}
+
+	// lease
+
+	var lease model.Lease
+	if err := a.readJSON(datasetFile(rid, "lease.json"), true, &lease); err != nil {
+		return err
+	}
+
+	if err := a.verifyOperation(&rec, &lease); err != nil {
+		return err
+	}
+
+	imp := lease.Impression
+	if imp == nil || imp.Proof == nil {
+		return errors.New("impression not signed")
+	}
+	if imp.ID != ads.ImpressionID {
+		return errors.New("impression ID mismatch")
+	}
+	key, err := a.publicKey(ctx, imp.Proof.Creator)
+	if err != nil {
+		return err
+	}
+	valid, err = imp.MerkleVerify(key)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid impression signature")
+	}
+
+	if lease.Provenance != nil {
+		if lease.Provenance.Proof == nil {
+			return errors.New("provenance not signed")
+		}
+		key, err = a.publicKey(ctx, lease.Provenance.Proof.Creator)
+		if err != nil {
+			return err
+		}
+		valid, err = lease.Provenance.MerkleVerify(key)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return errors.New("invalid provenance signature")
+		}
+	}
+
+	// resources
+
+	for _, res := range lease.Resources {
+		name, found := ads.Blobs[res.Asset]
+		if !found {
+			return fmt.Errorf("resource %s not found in archive", res.Asset)
+		}
+		fp, err := a.fingerprint(name)
+		if err != nil {
+			return err
+		}
+
+		method, err := model.ExtractDIDMethod(res.Asset)
+		if err != nil {
+			return err
+		}
+		if model.BuildDigitalAssetIDWithFingerprint(fp, method) != res.Asset {
+			return fmt.Errorf("asset ID mismatch for resource %s", res.Asset)
+		}
+
+		if meta := imp.MetaResource; meta != nil && meta.Asset == res.Asset &&
+			meta.FingerprintAlgorithm == fingerprint.AlgoSha256 &&
+			meta.Fingerprint != base64.StdEncoding.EncodeToString(fp) {
+			return errors.New("meta resource fingerprint mismatch")
+		}
+	}
+
+	return nil
+}
+
+// verifyOperation checks that the archived operation is the one referenced by the ledger record
+// and that it contains the archived lease.
+func (a *Archive) verifyOperation(rec *model.Record, lease *model.Lease) error {
+	rid := rec.ID
+
+	opBytes, err := a.readAll(datasetFile(rid, "operation.bin"))
+	if err != nil {
+		return err
+	}
+	opAddr, err := model.BuildDigitalAssetID(opBytes, fingerprint.AlgoSha256, "")
+	if err != nil {
+		return err
+	}
+	if opAddr != rec.OperationAddress {
+		return errors.New("operation address mismatch")
+	}
+
+	if rec.Flags&model.RecordFlagPublic == 0 {
+		keyBytes, err := a.readAll(datasetFile(rid, "operation.key"))
+		if err != nil {
+			return err
+		}
+		if len(keyBytes) != model.KeySize {
+			return errors.New("invalid operation key")
+		}
+		opBytes, err = model.DecryptAESCGM(opBytes, model.NewAESKey(keyBytes))
+		if err != nil {
+			return err
+		}
+	}
+
+	opLease, err := model.NewLease(opBytes)
+	if err != nil {
+		return err
+	}
+	for _, res := range opLease.Resources {
+		res.EncryptionKey = ""
+	}
+
+	expected, err := jsonw.Marshal(opLease)
+	if err != nil {
+		return err
+	}
+	actual, err := jsonw.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, actual) {
+		return errors.New("lease doesn't match the ledger record operation")
+	}
+
+	return nil
+}
+
+func (a *Archive) readAll(name string) ([]byte, error) {
+	r, err := a.open(name, true)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func (a *Archive) fingerprint(name string) ([]byte, error) {
+	r, err := a.open(name, true)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return fingerprint.GetSha256Fingerprint(r)
+}
+
+// ImportDataSets verifies the archive and restores the given datasets (or all the datasets,
+// if no record IDs are provided) into the given locker. Resources are uploaded into the given vault.
+// The original impressions are preserved, and the provenance of each restored dataset quotes
+// the provenance of the archived one. Returns the IDs of the new records.
+func ImportDataSets(ctx context.Context, dw DataWallet, a *Archive, lockerID, vaultName string, expiryTime time.Time, recordIDs ...string) ([]dataset.RecordFuture, error) {
+	if err := a.Verify(ctx); err != nil {
+		return nil, err
+	}
+
+	if len(recordIDs) == 0 {
+		for _, ads := range a.manifest.DataSets {
+			recordIDs = append(recordIDs, ads.RecordID)
+		}
+	}
+
+	locker, err := dw.GetLocker(ctx, lockerID)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := locker.Us()
+	if sender == nil {
+		return nil, errors.New("read-only locker")
+	}
+	idy, err := dw.GetIdentity(ctx, sender.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	recipientIDs := shareRecipients(locker)
+
+	backend, ok := dw.DataStore().(dataset.LeaseBuilderBackend)
+	if !ok {
+		return nil, errors.New("data store doesn't support dataset import")
+	}
+
+	blobManager := dw.Services().BlobManager()
+
+	futures := make([]dataset.RecordFuture, 0, len(recordIDs))
+	for _, rid := range recordIDs {
+		ads := a.manifest.DataSet(rid)
+		if ads == nil {
+			return futures, ErrArchiveDataSetNotFound
+		}
+		ds, err := a.dataSet(rid, blobManager)
+		if err != nil {
+			return futures, err
+		}
+
+		builder, err := dataset.NewLeaseBuilderForSharing(ctx, ds, backend, a.blobManager(ads, blobManager),
+			dataset.CopyModeDeep, idy.DID(), nil, recipientIDs[0], vaultName, nil)
+		if err != nil {
+			return futures, err
+		}
+		if len(recipientIDs) > 1 {
+			_ = builder.AddShareProvenance(nil, recipientIDs...)
+		}
+
+		lease, err := builder.Build(expiryTime)
+		if err != nil {
+			return futures, err
+		}
+
+		log.Debug().Str("rid", rid).Str("lid", lockerID).Msg("Importing archived dataset")
+
+		futures = append(futures, backend.Submit(ctx, lease, false, lockerID, sender))
+	}
+
+	return futures, nil
+}
+
+// archiveBlobManager serves blobs for the archived dataset from the archive. All the other
+// operations are delegated to the underlying blob manager, if available.
+type archiveBlobManager struct {
+	model.BlobManager
+
+	archive *Archive
+	ads     *ArchivedDataSet
+}
+
+func (bm *archiveBlobManager) GetBlob(ctx context.Context, res *model.StoredResource, accessToken string) (io.ReadCloser, error) {
+	if name, found := bm.ads.Blobs[res.Asset]; found {
+		return bm.archive.open(name, true)
+	}
+	if bm.BlobManager == nil {
+		return nil, model.ErrBlobNotFound
+	}
+	return bm.BlobManager.GetBlob(ctx, res, accessToken)
+}
+
+func (bm *archiveBlobManager) SendBlob(ctx context.Context, data io.Reader, cleartext bool, vaultName string) (*model.StoredResource, error) {
+	if bm.BlobManager == nil {
+		return nil, errors.New("archived datasets are read-only")
+	}
+	return bm.BlobManager.SendBlob(ctx, data, cleartext, vaultName)
+}
+
+func (bm *archiveBlobManager) PurgeBlob(ctx context.Context, res *model.StoredResource) error {
+	if bm.BlobManager == nil {
+		return errors.New("archived datasets are read-only")
+	}
+	return bm.BlobManager.PurgeBlob(ctx, res)
+}
+
+func (bm *archiveBlobManager) GetVaultMap(ctx context.Context) (map[string]*model.VaultProperties, error) {
+	if bm.BlobManager == nil {
+		return map[string]*model.VaultProperties{}, nil
+	}
+	return bm.BlobManager.GetVaultMap(ctx)
+}
@@ -0,0 +1,301 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/piprate/metalocker/utils/jsonw"
+	. "github.com/piprate/metalocker/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportDataSets(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw1 := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelManaged)
+	idy1, err := dw1.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+	locker1, err := idy1.NewLocker(ctx, "Source Locker")
+	require.NoError(t, err)
+
+	dw2 := env.CreateCustomAccount(t, "test2@example.com", "John Doe 2", model.AccessLevelManaged)
+	idy2, err := dw2.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+	locker2, err := idy2.NewLocker(ctx, "Target Locker")
+	require.NoError(t, err)
+
+	// create a data set with an attachment
+
+	lb, err := locker1.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+
+	_, err = lb.AddMetaResource(map[string]any{
+		"type": "TestDataset",
+	})
+	require.NoError(t, err)
+	attachmentID, err := lb.AddResource(strings.NewReader("attachment body"))
+	require.NoError(t, err)
+
+	f := lb.Submit(expiry.FromNow("1h"))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	key := model.NewEncryptionKey()
+
+	for _, tc := range []struct {
+		name string
+		opts []ArchiveOption
+	}{
+		{"cleartext", nil},
+		{"encrypted", []ArchiveOption{WithArchiveKey(key)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// export
+
+			var buf bytes.Buffer
+			manifest, err := ExportDataSets(ctx, dw1, &buf, idy1.DID(), []string{f.ID()}, tc.opts...)
+			require.NoError(t, err)
+			require.Len(t, manifest.DataSets, 1)
+			assert.Equal(t, idy1.ID(), manifest.Creator)
+			assert.Equal(t, len(tc.opts) > 0, manifest.Encrypted)
+
+			// open and verify
+
+			archive, err := OpenArchive(ctx, bytes.NewReader(buf.Bytes()), int64(buf.Len()), tc.opts...)
+			require.NoError(t, err)
+			require.NoError(t, archive.Verify(ctx))
+
+			ds, err := archive.DataSet(f.ID())
+			require.NoError(t, err)
+			r, err := ds.Resource(ctx, attachmentID)
+			require.NoError(t, err)
+			body, err := io.ReadAll(r)
+			require.NoError(t, err)
+			_ = r.Close()
+			assert.Equal(t, "attachment body", string(body))
+
+			// import into another wallet
+
+			futures, err := ImportDataSets(ctx, dw2, archive, locker2.ID(), testbase.TestVaultName, expiry.FromNow("1h"))
+			require.NoError(t, err)
+			require.Len(t, futures, 1)
+			require.NoError(t, futures[0].Wait(time.Second*10))
+
+			imported, err := dw2.DataStore().Load(ctx, futures[0].ID())
+			require.NoError(t, err)
+			assert.Equal(t, f.DataSet().Impression().ID, imported.Impression().ID)
+			assert.Equal(t, locker2.ID(), imported.LockerID())
+
+			var meta map[string]any
+			require.NoError(t, imported.DecodeMetaResource(ctx, &meta))
+			assert.Equal(t, "TestDataset", meta["type"])
+		})
+	}
+
+	// encrypted archive can't be read without the key
+
+	var buf bytes.Buffer
+	_, err = ExportDataSets(ctx, dw1, &buf, idy1.DID(), []string{f.ID()}, WithArchiveKey(key))
+	require.NoError(t, err)
+	archive, err := OpenArchive(ctx, bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.ErrorIs(t, archive.Verify(ctx), ErrArchiveKeyRequired)
+}
+
+func TestOpenArchive_Tampered(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelManaged)
+	idy, err := dw.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+	locker, err := idy.NewLocker(ctx, "Test Locker")
+	require.NoError(t, err)
+
+	lb, err := locker.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+	_, err = lb.AddMetaResource(map[string]any{
+		"type": "TestDataset",
+	})
+	require.NoError(t, err)
+	f := lb.Submit(expiry.FromNow("1h"))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	var buf bytes.Buffer
+	_, err = ExportDataSets(ctx, dw, &buf, idy.DID(), []string{f.ID()})
+	require.NoError(t, err)
+
+	// replace the contents of all the blobs
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	var tampered bytes.Buffer
+	zw := zip.NewWriter(&tampered)
+	for _, zf := range zr.File {
+		w, err := zw.Create(zf.Name)
+		require.NoError(t, err)
+		if strings.HasPrefix(zf.Name, "blobs/") {
+			_, err = w.Write([]byte(`{"type":"Forged"}`))
+			require.NoError(t, err)
+			continue
+		}
+		r, err := zf.Open()
+		require.NoError(t, err)
+		_, err = io.Copy(w, r)
+		require.NoError(t, err)
+		_ = r.Close()
+	}
+	require.NoError(t, zw.Close())
+
+	_, err = OpenArchive(ctx, bytes.NewReader(tampered.Bytes()), int64(tampered.Len()))
+	assert.ErrorIs(t, err, ErrArchiveCorrupted)
+}
+
+func TestArchive_Verify_ForgedRecord(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelManaged)
+	idy, err := dw.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+	locker, err := idy.NewLocker(ctx, "Test Locker")
+	require.NoError(t, err)
+
+	lb, err := locker.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+	_, err = lb.AddMetaResource(map[string]any{
+		"type": "TestDataset",
+	})
+	require.NoError(t, err)
+	f := lb.Submit(expiry.FromNow("1h"))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	var buf bytes.Buffer
+	_, err = ExportDataSets(ctx, dw, &buf, idy.DID(), []string{f.ID()})
+	require.NoError(t, err)
+
+	recordFile := "datasets/" + f.ID() + "/record.json"
+	leaseFile := "datasets/" + f.ID() + "/lease.json"
+
+	// the lease doesn't match the operation referenced by the record
+
+	forged := rewriteArchive(t, buf.Bytes(), idy.DID(), func(m *ArchiveManifest, files map[string][]byte) {
+		var lease model.Lease
+		require.NoError(t, jsonw.Unmarshal(files[leaseFile], &lease))
+		expiresAt := lease.ExpiresAt.Add(time.Hour * 24 * 365)
+		lease.ExpiresAt = &expiresAt
+		files[leaseFile], err = jsonw.Marshal(&lease)
+		require.NoError(t, err)
+	})
+	archive, err := OpenArchive(ctx, bytes.NewReader(forged), int64(len(forged)))
+	require.NoError(t, err)
+	err = archive.Verify(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "lease doesn't match the ledger record operation")
+
+	// the record isn't signed by its routing key
+
+	forged = rewriteArchive(t, buf.Bytes(), idy.DID(), func(m *ArchiveManifest, files map[string][]byte) {
+		var rec model.Record
+		require.NoError(t, jsonw.Unmarshal(files[recordFile], &rec))
+		otherKey, err := btcec.NewPrivateKey()
+		require.NoError(t, err)
+		require.NoError(t, rec.Seal(otherKey))
+
+		for name, data := range files {
+			if strings.HasPrefix(name, "datasets/"+f.ID()+"/") {
+				delete(files, name)
+				files[strings.Replace(name, f.ID(), rec.ID, 1)] = data
+			}
+		}
+		files["datasets/"+rec.ID+"/record.json"], err = jsonw.Marshal(&rec)
+		require.NoError(t, err)
+		m.DataSets[0].RecordID = rec.ID
+	})
+	archive, err = OpenArchive(ctx, bytes.NewReader(forged), int64(len(forged)))
+	require.NoError(t, err)
+	err = archive.Verify(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid ledger record signature")
+}
+
+// rewriteArchive applies the given changes to an archive and signs the updated manifest
+// on behalf of the signer, as a malicious archive creator would.
+func rewriteArchive(t *testing.T, data []byte, signer *model.DID, fn func(m *ArchiveManifest, files map[string][]byte)) []byte {
+	t.Helper()
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte, len(zr.File))
+	for _, zf := range zr.File {
+		r, err := zf.Open()
+		require.NoError(t, err)
+		files[zf.Name], err = io.ReadAll(r)
+		require.NoError(t, err)
+		_ = r.Close()
+	}
+
+	var m ArchiveManifest
+	require.NoError(t, jsonw.Unmarshal(files["manifest.json"], &m))
+	delete(files, "manifest.json")
+
+	fn(&m, files)
+
+	m.Files = make(map[string]string, len(files))
+	for name, b := range files {
+		h := sha256.Sum256(b)
+		m.Files[name] = base64.StdEncoding.EncodeToString(h[:])
+	}
+	m.Signature = ""
+	b, err := jsonw.Marshal(&m)
+	require.NoError(t, err)
+	m.Signature = base64.StdEncoding.EncodeToString(signer.Sign(b))
+	files["manifest.json"], err = jsonw.Marshal(&m)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	for name, b := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(b)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	return out.Bytes()
+}
@@ -45,6 +45,7 @@ type (
 
 var _ DataStore = (*localStoreImpl)(nil)
 var _ dataset.LeaseBuilderBackend = (*localStoreImpl)(nil)
+var _ dataset.LeaseRenewalBackend = (*localStoreImpl)(nil)
 
 var (
 	ErrRecordNotFoundInRootIndex = errors.New("record not found in root index")
@@ -101,6 +102,14 @@ func (c *localStoreImpl) processRecord(ctx context.Context, lr *model.Record, su
 	)
 
 	if rec == nil {
+		// identify block number
+
+		state, err := c.ledger.GetRecordState(ctx, lr.ID)
+		if err != nil {
+			return err
+		}
+		blockNumber = state.BlockNumber
+
 		var lockers []*model.Locker
 		if suggestedLockerID != "" {
 			l, err := c.dataWallet.GetLocker(ctx, suggestedLockerID)
@@ -124,8 +133,12 @@ func (c *localStoreImpl) processRecord(ctx context.Context, lr *model.Record, su
 		}
 	OuterLoop:
 		for _, l := range lockers {
+			if l.IsSealed() && blockNumber > l.LastBlock {
+				// records submitted after the locker was sealed are ignored
+				continue
+			}
 			for _, p := range l.Participants {
-				publicKey, symKey, err = p.IsRecordOwner(lr.RoutingKey, lr.KeyIndex)
+				publicKey, symKey, err = p.IsRecordOwner(lr.RoutingKey, lr.KeyIndex, blockNumber)
 				if err != nil {
 					return err
 				}
@@ -140,14 +153,6 @@ func (c *localStoreImpl) processRecord(ctx context.Context, lr *model.Record, su
 			// the given record doesn't belong to any of the lockers
 			return model.ErrDataSetNotFound
 		}
-
-		// identify block number
-
-		state, err := c.ledger.GetRecordState(ctx, lr.ID)
-		if err != nil {
-			return err
-		}
-		blockNumber = state.BlockNumber
 	} else {
 		if lr.Flags&model.RecordFlagPublic == 0 {
 			// retrieve crypto material from the locker this record belongs to
@@ -157,7 +162,7 @@ func (c *localStoreImpl) processRecord(ctx context.Context, lr *model.Record, su
 			}
 
 			p := l.Raw().GetParticipant(rec.ParticipantID)
-			publicKey, err = p.GetRecordPublicKey(rec.Index)
+			publicKey, err = p.GetRecordPublicKey(rec.Index, rec.BlockNumber)
 			if err != nil {
 				return err
 			}
@@ -165,7 +170,7 @@ func (c *localStoreImpl) processRecord(ctx context.Context, lr *model.Record, su
 			lockerID = rec.LockerID
 			participantID = rec.ParticipantID
 
-			symKey = p.GetOperationSymKey(rec.Index)
+			symKey = p.GetOperationSymKey(rec.Index, rec.BlockNumber)
 		}
 
 		blockNumber = rec.BlockNumber
@@ -342,6 +347,10 @@ func (c *localStoreImpl) submitLease(ctx context.Context, lease *model.Lease, cl
 		rec.Flags |= model.RecordFlagPublic
 	}
 
+	if lease.ExpiresAt != nil {
+		rec.ExpiresAt = lease.ExpiresAt.Unix()
+	}
+
 	// seal the record
 
 	pk, err := recordPrivKey.ECPrivKey()
@@ -377,8 +386,12 @@ func (c *localStoreImpl) submitLeaseRevocation(ctx context.Context, recordID str
 		return "", err
 	}
 
-	subjPrivKey, err := p.GetRecordPrivateKey(subj.KeyIndex)
+	subjPrivKey, err := p.FindRecordPrivateKey(subj.RoutingKey, subj.KeyIndex)
 	if err != nil {
+		if errors.Is(err, model.ErrKeyEpochNotFound) {
+			return "", errors.New(
+				"authorising commitment check failed. You are not authorised to revoke this lease")
+		}
 		return "", err
 	}
 
@@ -423,11 +436,84 @@ func (c *localStoreImpl) submitLeaseRevocation(ctx context.Context, recordID str
 	return rec.ID, nil
 }
 
+func (c *localStoreImpl) submitLeaseRenewal(ctx context.Context, subj *model.Record, expiryTime time.Time, p *model.LockerParticipant) (string, error) {
+	keyIndex := model.RandomKeyIndex()
+
+	recordPrivKey, err := p.GetRecordPrivateKey(keyIndex)
+	if err != nil {
+		return "", err
+	}
+	recordPubKey, err := recordPrivKey.ECPubKey()
+	if err != nil {
+		return "", err
+	}
+
+	subjPrivKey, err := p.FindRecordPrivateKey(subj.RoutingKey, subj.KeyIndex)
+	if err != nil {
+		if errors.Is(err, model.ErrKeyEpochNotFound) {
+			return "", errors.New(
+				"authorising commitment check failed. You are not authorised to renew this lease")
+		}
+		return "", err
+	}
+
+	acInput := model.BuildAuthorisingCommitmentInput(subjPrivKey, subj.OperationAddress)
+	subjAC := sha256.Sum256(acInput)
+
+	if subj.AuthorisingCommitment != base64.StdEncoding.EncodeToString(subjAC[:]) {
+		return "", errors.New(
+			"authorising commitment check failed. You are not authorised to renew this lease")
+	}
+
+	// generate new record routing key
+
+	routingKey, _ := model.BuildRoutingKey(recordPubKey)
+
+	rec := &model.Record{
+		RoutingKey: routingKey,
+		KeyIndex:   keyIndex,
+		Operation:  model.OpTypeLeaseRenewal,
+
+		SubjectRecord: subj.ID,
+		ExpiresAt:     expiryTime.Unix(),
+	}
+
+	// sign the renewal proof with the subject record's key
+
+	subjPK, err := subjPrivKey.ECPrivKey()
+	if err != nil {
+		return "", err
+	}
+	if err = model.SignLeaseRenewal(rec, subjPK); err != nil {
+		return "", err
+	}
+
+	// seal the record
+
+	pk, err := recordPrivKey.ECPrivKey()
+	if err != nil {
+		return "", err
+	}
+	err = rec.Seal(pk)
+	if err != nil {
+		return "", err
+	}
+
+	if err = c.ledger.SubmitRecord(ctx, rec); err != nil {
+		return "", err
+	}
+
+	return rec.ID, nil
+}
+
 func (c *localStoreImpl) Share(ctx context.Context, ds model.DataSet, locker Locker, vaultName string, expiryTime time.Time) dataset.RecordFuture {
 	sender := locker.Us()
 	if sender == nil {
 		return dataset.RecordFutureWithError(fmt.Errorf("read-only locker"))
 	}
+	if locker.Raw().IsSealed() {
+		return dataset.RecordFutureWithError(model.ErrLockerSealed)
+	}
 	us := sender.ID
 	idy, err := c.dataWallet.GetIdentity(ctx, us)
 	if err != nil {
@@ -435,18 +521,16 @@ func (c *localStoreImpl) Share(ctx context.Context, ds model.DataSet, locker Loc
 	}
 	creator := idy.DID()
 
-	var recipient []*model.LockerParticipant
-	if locker.IsUniLocker() {
-		recipient = append(recipient, sender)
-	} else {
-		recipient = locker.Them()
-	}
+	recipientIDs := shareRecipients(locker)
 
 	builder, err := dataset.NewLeaseBuilderForSharing(ctx, ds, c, c.blobManager, dataset.CopyModeDeep, creator,
-		nil, recipient[0].ID, vaultName, nil)
+		nil, recipientIDs[0], vaultName, nil)
 	if err != nil {
 		return dataset.RecordFutureWithError(err)
 	}
+	if len(recipientIDs) > 1 {
+		_ = builder.AddShareProvenance(nil, recipientIDs...)
+	}
 
 	lease, err := builder.Build(expiryTime)
 	if err != nil {
@@ -502,6 +586,49 @@ func (c *localStoreImpl) Revoke(ctx context.Context, id string) dataset.RecordFu
 	return dataset.RecordFutureWithResult(ctx, c.ledger, c.ns, recID, nil, nil, []string{recID})
 }
 
+func (c *localStoreImpl) Renew(ctx context.Context, id string, expiryTime time.Time) dataset.RecordFuture {
+	defer measure.ExecTime("store.RenewLease")()
+
+	ds, err := c.Load(ctx, id)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	builder, err := dataset.NewRenewalBuilder(ctx, c, ds)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	return builder.Submit(expiryTime)
+}
+
+func (c *localStoreImpl) SubmitRenewal(ctx context.Context, ds model.DataSet, expiryTime time.Time) dataset.RecordFuture {
+	locker, err := c.dataWallet.GetLocker(ctx, ds.LockerID())
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	usParty := locker.Us()
+	if usParty == nil {
+		return dataset.RecordFutureWithError(ErrSenderNotFound)
+	}
+
+	if usParty.ID != ds.ParticipantID() {
+		log.Error().Str("rid", ds.ID()).Msg("Third party requested to renew a lease")
+		return dataset.RecordFutureWithError(errors.New("only lease owner can renew leases"))
+	}
+
+	log.Debug().Str("rid", ds.ID()).Msg("Record owner found. Renewing the lease...")
+
+	recID, err := c.submitLeaseRenewal(ctx, ds.Record(), expiryTime, usParty)
+	if err != nil {
+		log.Error().Str("rec", ds.ID()).Err(err).Msg("Failed to submit lease renewal")
+		return dataset.RecordFutureWithError(err)
+	}
+
+	return dataset.RecordFutureWithResult(ctx, c.ledger, c.ns, recID, nil, nil, []string{recID})
+}
+
 func (c *localStoreImpl) PurgeDataAssets(ctx context.Context, recordID string) error {
 	log.Debug().Str("rid", recordID).Msg("Purging data assets from record")
 
@@ -657,8 +784,12 @@ func (c *localStoreImpl) submitHead(ctx context.Context, assetID, lockerID strin
 			return "", fmt.Errorf("asset head record already revoked: %s", prevHead.ID)
 		}
 
-		prevHeadPrivKey, err := sender.GetRecordPrivateKey(prevHead.KeyIndex)
+		prevHeadPrivKey, err := sender.FindRecordPrivateKey(prevHead.RoutingKey, prevHead.KeyIndex)
 		if err != nil {
+			if errors.Is(err, model.ErrKeyEpochNotFound) {
+				return "", errors.New(
+					"authorising commitment check failed. You are not authorised to update the asset head")
+			}
 			return "", err
 		}
 
@@ -633,3 +633,88 @@ func TestLocalStoreImpl_Revoke(t *testing.T) {
 	checkAccess(dataWallet1, false)
 	checkAccess(dataWallet2, false)
 }
+
+func TestLocalStoreImpl_Renew(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dataWallet1 := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelManaged)
+
+	idy1, err := dataWallet1.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	dataWallet2 := env.CreateCustomAccount(t, "test2@example.com", "John Doe 2", model.AccessLevelManaged)
+
+	idy2, err := dataWallet2.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	sharedLocker, err := idy1.NewLocker(ctx, "Test Locker", Participant(idy2.DID(), nil))
+	require.NoError(t, err)
+
+	_, err = dataWallet2.AddLocker(ctx, sharedLocker.Raw().Perspective(idy2.ID()))
+	require.NoError(t, err)
+
+	rootIndex1, err := dataWallet1.CreateRootIndex(ctx, testbase.IndexStoreName)
+	require.NoError(t, err)
+
+	updater, err := dataWallet1.IndexUpdater(ctx, rootIndex1)
+	require.NoError(t, err)
+	defer updater.Close()
+
+	lb, err := sharedLocker.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+
+	_, err = lb.AddMetaResource(map[string]any{
+		"id":   "test1",
+		"type": "TestDataset1",
+	})
+	require.NoError(t, err)
+
+	f := lb.Submit(expiry.FromNow("1h"))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	recordID := f.ID()
+
+	require.NoError(t, updater.Sync(ctx))
+
+	// renewal of somebody else's lease should fail
+	f = dataWallet2.DataStore().Renew(ctx, recordID, expiry.FromNow("2h"))
+	require.Error(t, f.Wait(time.Second*10))
+
+	// renewal shouldn't shorten the lease
+	f = dataWallet1.DataStore().Renew(ctx, recordID, expiry.FromNow("30min"))
+	require.Error(t, f.Wait(time.Second*10))
+
+	// should succeed
+	newExpiry := expiry.FromNow("2h")
+	f = dataWallet1.DataStore().Renew(ctx, recordID, newExpiry)
+	require.NoError(t, f.Wait(time.Second*10))
+
+	rs, err := env.Ledger.GetRecordState(ctx, recordID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPublished, rs.Status)
+	assert.Equal(t, newExpiry.Unix(), rs.ExpiresAt)
+
+	// the ledger rejects renewals that don't extend the renewed lease
+	f = dataWallet1.DataStore().Renew(ctx, recordID, expiry.FromNow("90min"))
+	require.Error(t, f.Wait(time.Second*10))
+
+	require.NoError(t, updater.Sync(ctx))
+
+	irs, err := rootIndex1.GetRecord(ctx, recordID)
+	require.NoError(t, err)
+	assert.Equal(t, newExpiry.Unix(), irs.ExpiresAt)
+
+	// renewal doesn't create a new revision of the dataset
+	ds, err := dataWallet1.DataStore().Load(ctx, recordID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), ds.Impression().Revision())
+
+	// revoked leases can't be renewed
+	require.NoError(t, dataWallet1.DataStore().Revoke(ctx, recordID).Wait(time.Second*10))
+
+	f = dataWallet1.DataStore().Renew(ctx, recordID, expiry.FromNow("3h"))
+	require.ErrorIs(t, f.Wait(time.Second*10), dataset.ErrLeaseNotRenewable)
+}
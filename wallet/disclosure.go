@@ -0,0 +1,128 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+var ErrDisclosureProofNotFound = errors.New("disclosure proof not found in dataset")
+
+// DiscloseDataSet creates a derived dataset in the given locker that reveals only the given
+// top-level properties of the source dataset's meta resource. The derived dataset contains
+// a MetaDisclosure resource that proves the disclosed properties belong to the source
+// dataset's signed impression. The source dataset should be created with
+// dataset.WithSelectiveDisclosure option.
+func DiscloseDataSet(ctx context.Context, ds model.DataSet, properties []string, locker Locker, vaultName string, expiryTime time.Time) dataset.RecordFuture {
+	imp := ds.Impression()
+	if imp.MetaResource == nil || imp.MetaResource.DisclosureRoot == "" || ds.Lease().DisclosureSeed == "" {
+		return dataset.RecordFutureWithError(model.ErrDisclosureNotSupported)
+	}
+
+	r, err := ds.MetaResource(ctx)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+	defer r.Close()
+
+	meta, err := io.ReadAll(r)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	proof, disclosed, err := model.NewMetaDisclosure(imp, meta, ds.Lease().DisclosureSeed, properties)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	lb, err := locker.NewDataSetBuilder(ctx, dataset.WithVault(vaultName), dataset.WithAsset(imp.Asset))
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	if _, err = lb.AddMetaResource(disclosed, dataset.WithContentType(imp.MetaResource.ContentType)); err != nil {
+		_ = lb.Cancel()
+		return dataset.RecordFutureWithError(err)
+	}
+
+	if _, err = lb.AddResource(bytes.NewReader(proof.Bytes())); err != nil {
+		_ = lb.Cancel()
+		return dataset.RecordFutureWithError(err)
+	}
+
+	return lb.Submit(expiryTime)
+}
+
+// VerifyDisclosure verifies the dataset produced by DiscloseDataSet. It checks the signature
+// of the source dataset's impression and that every property of the dataset's meta resource
+// belongs to the source meta resource.
+func VerifyDisclosure(ctx context.Context, dw DataWallet, ds model.DataSet) (*model.MetaDisclosure, error) {
+	var proof *model.MetaDisclosure
+	for _, id := range ds.Resources() {
+		r, err := ds.Resource(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var md model.MetaDisclosure
+		if jsonw.Unmarshal(b, &md) == nil && md.Type == model.MetaDisclosureType {
+			proof = &md
+			break
+		}
+	}
+
+	if proof == nil {
+		return nil, ErrDisclosureProofNotFound
+	}
+
+	r, err := ds.MetaResource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	meta, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if proof.Impression == nil {
+		return nil, model.ErrInvalidDisclosure
+	}
+
+	signer, err := dw.GetDID(ctx, proof.Impression.WasAttributedTo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = proof.Verify(meta, signer.VerKeyValue()); err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	"github.com/piprate/metalocker/sdk/testbase"
+	. "github.com/piprate/metalocker/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscloseDataSet(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw1 := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelManaged)
+	idy1, err := dw1.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	dw2 := env.CreateCustomAccount(t, "test2@example.com", "John Doe 2", model.AccessLevelManaged)
+	idy2, err := dw2.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	uniLocker, err := idy1.NewLocker(ctx, "Uni-locker")
+	require.NoError(t, err)
+
+	sharedLocker, err := idy1.NewLocker(ctx, "Shared Locker", Participant(idy2.DID(), nil))
+	require.NoError(t, err)
+	_, err = dw2.AddLocker(ctx, sharedLocker.Raw().Perspective(idy2.ID()))
+	require.NoError(t, err)
+
+	// create a dataset with a disclosure root
+
+	lb, err := uniLocker.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+	_, err = lb.AddMetaResource(map[string]any{
+		"type":      "TestDataset",
+		"name":      "John Doe",
+		"birthDate": "1970-01-01",
+	}, dataset.WithSelectiveDisclosure())
+	require.NoError(t, err)
+	f := lb.Submit(expiry.FromNow("1h"))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	ds, err := dw1.DataStore().Load(ctx, f.ID())
+	require.NoError(t, err)
+	require.NotEmpty(t, ds.Impression().MetaResource.DisclosureRoot)
+	require.NotEmpty(t, ds.Lease().DisclosureSeed)
+
+	// disclose the name only
+
+	df := DiscloseDataSet(ctx, ds, []string{"type", "name"}, sharedLocker, testbase.TestVaultName, expiry.FromNow("1h"))
+	require.NoError(t, df.Wait(time.Second*10))
+
+	derived, err := dw2.DataStore().Load(ctx, df.ID())
+	require.NoError(t, err)
+
+	assert.Empty(t, derived.Lease().DisclosureSeed)
+
+	var meta map[string]any
+	require.NoError(t, derived.DecodeMetaResource(ctx, &meta))
+	assert.Equal(t, map[string]any{"type": "TestDataset", "name": "John Doe"}, meta)
+
+	proof, err := VerifyDisclosure(ctx, dw2, derived)
+	require.NoError(t, err)
+	assert.Equal(t, ds.Impression().ID, proof.Impression.ID)
+
+	// a regular dataset can't be disclosed
+
+	lb, err = uniLocker.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+	_, err = lb.AddMetaResource(map[string]any{
+		"type": "TestDataset",
+		"name": "John Doe",
+	})
+	require.NoError(t, err)
+	f = lb.Submit(expiry.FromNow("1h"))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	ds, err = dw1.DataStore().Load(ctx, f.ID())
+	require.NoError(t, err)
+
+	df = DiscloseDataSet(ctx, ds, []string{"name"}, sharedLocker, testbase.TestVaultName, expiry.FromNow("1h"))
+	assert.ErrorIs(t, df.Error(), model.ErrDisclosureNotSupported)
+
+	_, err = VerifyDisclosure(ctx, dw1, ds)
+	assert.ErrorIs(t, err, ErrDisclosureProofNotFound)
+}
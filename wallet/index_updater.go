@@ -75,6 +75,11 @@ func (ixf *IndexUpdater) AddIndexes(ctx context.Context, dw DataWallet, indexes
 			return err
 		}
 		for _, ls := range lockerStates {
+			if ls.IsSealed() && ls.TopBlock >= ls.LastBlock {
+				// the index already processed all records for the sealed locker
+				log.Debug().Str("lid", ls.ID).Msg("Skipping sealed locker")
+				continue
+			}
 
 			lockerDW, err := recordConsumer.getDataWallet(ctx, ls.AccountID)
 			if err != nil {
@@ -90,8 +95,9 @@ func (ixf *IndexUpdater) AddIndexes(ctx context.Context, dw DataWallet, indexes
 				}
 			} else {
 				err = sub.AddLockers(scanner.LockerEntry{
-					Locker:    l.Raw(),
-					LastBlock: ls.TopBlock,
+					Locker:     l.Raw(),
+					LastBlock:  ls.TopBlock,
+					UntilBlock: ls.LastBlock,
 				})
 				if err != nil {
 					return err
@@ -360,6 +366,15 @@ func (c *consumer) ConsumeBlock(ctx context.Context, indexID string, partyLookup
 			if err := iw.AddLeaseRevocation(ctx, ds); err != nil {
 				return err
 			}
+		} else if r.Operation == model.OpTypeLeaseRenewal && r.Status == model.StatusPublished {
+			ds := dataset.NewRevokedDataSetImpl(r, n.Block, lockerID, participantID)
+			if err := iw.AddLeaseRenewal(ctx, ds); err != nil {
+				return err
+			}
+		} else if r.Operation == model.OpTypeLockerSeal && r.Status == model.StatusPublished {
+			if err := c.sealLocker(ctx, lockerID, n.Block); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -386,6 +401,68 @@ func (c *consumer) addLocker(ctx context.Context, dw DataWallet, lockerID string
 	})
 }
 
+// sealLocker stops indexing the locker's records after the block with its seal record,
+// and marks the locker as sealed in the data wallet that owns it.
+func (c *consumer) sealLocker(ctx context.Context, lockerID string, lastBlock int64) error {
+	if err := c.index.SealLockerState(ctx, lockerID, lastBlock); err != nil {
+		return err
+	}
+
+	c.sub.SealLocker(lockerID, lastBlock)
+
+	lockerStates, err := c.index.LockerStates(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, ls := range lockerStates {
+		if ls.ID != lockerID {
+			continue
+		}
+
+		dw, err := c.getDataWallet(ctx, ls.AccountID)
+		if err != nil {
+			return err
+		}
+
+		l, err := dw.GetLocker(ctx, lockerID)
+		if err != nil {
+			return err
+		}
+
+		if !l.Raw().IsSealed() {
+			sealedAt, err := blockTime(ctx, c.ledger, lastBlock)
+			if err != nil {
+				return err
+			}
+			locker := l.Raw().Copy()
+			if err = locker.Seal(lastBlock, sealedAt); err != nil {
+				return err
+			}
+			if _, err = dw.UpdateLocker(ctx, locker); err != nil {
+				// the index will ignore any new records, even if the data wallet
+				// isn't aware of the seal
+				log.Warn().Err(err).Str("lid", lockerID).Msg("Failed to mark locker as sealed in data wallet")
+			}
+		}
+	}
+
+	return nil
+}
+
+// blockTime returns the time when the given block was produced. Blocks from older
+// ledgers don't have timestamps, in which case the current time is returned.
+func blockTime(ctx context.Context, ledger model.Ledger, blockNumber int64) (time.Time, error) {
+	b, err := ledger.GetBlock(ctx, blockNumber)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if b.Timestamp == 0 {
+		return time.Now(), nil
+	}
+	return time.Unix(b.Timestamp, 0), nil
+}
+
 func (c *consumer) getDataWallet(ctx context.Context, accountID string) (DataWallet, error) {
 	dw, found := c.dataWallets[accountID]
 	if !found {
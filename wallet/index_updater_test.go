@@ -606,3 +606,85 @@ func TestIndexUpdater_RemoveIndex(t *testing.T) {
 
 	assert.False(t, received, "received a record in the removed index")
 }
+
+func TestIndexUpdater_SearchIndex(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	dw := env.CreateTestManagedAccount(t)
+
+	ctx := env.Ctx
+
+	lockers, err := dw.GetLockers(ctx)
+	require.NoError(t, err)
+	require.True(t, len(lockers) > 0)
+
+	locker := lockers[0] // one of the root lockers
+
+	rootIndex, err := dw.CreateRootIndex(ctx, testbase.IndexStoreName)
+	require.NoError(t, err)
+
+	_, err = dw.SearchIndex(ctx)
+	require.ErrorIs(t, err, index.ErrIndexNotFound)
+
+	ix, err := dw.CreateIndex(ctx, testbase.IndexStoreName, index.TypeSearch,
+		index.WithParameters(&index.SearchIndexParameters{Properties: []string{"type", "colour"}}))
+	require.NoError(t, err)
+
+	searchIndex, err := dw.SearchIndex(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ix.ID(), searchIndex.ID())
+
+	submit := func(meta map[string]any) string {
+		lb, err := dw.DataStore().NewDataSetBuilder(ctx, locker.ID, dataset.WithVault(testbase.TestVaultName))
+		require.NoError(t, err)
+		_, err = lb.AddMetaResource(meta)
+		require.NoError(t, err)
+		f := lb.Submit(expiry.FromNow("1h"))
+		require.NoError(t, f.Wait(time.Second*5))
+		return f.ID()
+	}
+
+	rid1 := submit(map[string]any{
+		"type":        "Car",
+		"colour":      "Red",
+		"description": "A fast red sports car. Very fast.",
+	})
+	rid2 := submit(map[string]any{
+		"type":        "Car",
+		"colour":      "Blue",
+		"description": "A fast family car",
+	})
+
+	updater, err := dw.IndexUpdater(ctx, rootIndex, searchIndex)
+	require.NoError(t, err)
+	defer updater.Close()
+
+	require.NoError(t, updater.Sync(ctx))
+
+	search := func(q *index.SearchQuery) []string {
+		res, err := searchIndex.Search(ctx, q)
+		require.NoError(t, err)
+		ids := make([]string, len(res))
+		for i, r := range res {
+			ids[i] = r.RecordID
+		}
+		return ids
+	}
+
+	// results are ranked by term frequency
+	assert.Equal(t, []string{rid1, rid2}, search(&index.SearchQuery{Text: "fast"}))
+	assert.Equal(t, []string{rid2}, search(&index.SearchQuery{Text: "FAMILY car"}))
+	assert.Empty(t, search(&index.SearchQuery{Text: "fast truck"}))
+	assert.Equal(t, []string{rid1}, search(&index.SearchQuery{Properties: map[string]string{"colour": "red"}}))
+	assert.Equal(t, []string{rid1}, search(&index.SearchQuery{Text: "fast", Limit: 1}))
+	assert.Len(t, search(&index.SearchQuery{Properties: map[string]string{"type": "car"}}), 2)
+
+	// revoked datasets are removed from the search index
+
+	require.NoError(t, dw.DataStore().Revoke(ctx, rid1).Wait(time.Second*5))
+	require.NoError(t, updater.Sync(ctx))
+
+	assert.Equal(t, []string{rid2}, search(&index.SearchQuery{Text: "fast"}))
+	assert.Empty(t, search(&index.SearchQuery{Properties: map[string]string{"colour": "red"}}))
+}
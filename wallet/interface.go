@@ -87,6 +87,18 @@ type (
 		Us() *model.LockerParticipant
 		// Them returns a list of all locker participants that aren't controlled by the account.
 		Them() []*model.LockerParticipant
+		// AddParticipant adds a new participant to the locker. The participant will be able
+		// to read and write records in the locker from the current top block onwards. The returned
+		// locker definition needs to be passed to the new participant (see model.Locker.Perspective).
+		AddParticipant(ctx context.Context, did *model.DID, seed []byte) (Locker, error)
+		// Invite publishes an encrypted invitation to join the locker, addressed to the participant
+		// with the given ID. Only the participant who generated the recipient's keys can invite them.
+		Invite(ctx context.Context, participantID, message string) dataset.RecordFuture
+		// RotateKeys replaces the account controlled participant's keys with new ones, starting
+		// from the current top block. Records created before the rotation remain readable with
+		// the old keys. The returned locker definition needs to be passed to other participants
+		// (see model.Locker.Perspective), so that they can read the records issued with the new keys.
+		RotateKeys(ctx context.Context, seed []byte) (Locker, error)
 
 		// NewDataSetBuilder returns an instance of dataset.Builder that enables interactive construction
 		// of a dataset. This builder assumes the dataset will be stored in this locker.
@@ -102,7 +114,9 @@ type (
 		// SetAssetHead sets the record with the given ID as a head for the dataset with the given asset ID.
 		SetAssetHead(ctx context.Context, assetID, headName, recordID string) dataset.RecordFuture
 
-		// Seal closes the locker. NOT CURRENTLY SUPPORTED.
+		// Seal closes the locker by publishing a seal record on the ledger. No new records
+		// can be submitted to a sealed locker, and indexes ignore any records from the locker's
+		// participants after the seal's block.
 		Seal(ctx context.Context) error
 	}
 
@@ -127,6 +141,9 @@ type (
 		Load(ctx context.Context, id string, opts ...dataset.LoadOption) (model.DataSet, error)
 		// Revoke revokes for the lease for the dataset behind the given record ID.
 		Revoke(ctx context.Context, id string) dataset.RecordFuture
+		// Renew extends the expiry time of the lease for the dataset behind the given record ID.
+		// It doesn't create a new revision of the dataset.
+		Renew(ctx context.Context, id string, expiryTime time.Time) dataset.RecordFuture
 
 		// AssetHead returns the dataset that is a head with the given ID.
 		AssetHead(ctx context.Context, headID string, opts ...dataset.LoadOption) (model.DataSet, error)
@@ -191,7 +208,7 @@ type (
 		SubAccounts(ctx context.Context) ([]*account.Account, error)
 		GetSubAccountWallet(ctx context.Context, id string) (DataWallet, error)
 
-		CreateAccessKey(ctx context.Context, accessLevel model.AccessLevel, duration time.Duration) (*model.AccessKey, error)
+		CreateAccessKey(ctx context.Context, accessLevel model.AccessLevel, duration time.Duration, opts ...model.AccessKeyOption) (*model.AccessKey, error)
 		GetAccessKey(ctx context.Context, keyID string) (*model.AccessKey, error)
 		RevokeAccessKey(ctx context.Context, keyID string) error
 		AccessKeys(ctx context.Context) ([]*model.AccessKey, error)
@@ -206,7 +223,20 @@ type (
 		GetRootIdentity(ctx context.Context) (Identity, error)
 
 		AddLocker(ctx context.Context, l *model.Locker) (Locker, error)
+		// UpdateLocker replaces the definition of an existing locker. It's used to register
+		// participants added to the locker after its creation, rotated participant keys
+		// or the locker's seal. Existing participants can't be removed or modified otherwise,
+		// and sealed lockers can't be unsealed.
+		UpdateLocker(ctx context.Context, l *model.Locker) (Locker, error)
 		GetLockers(ctx context.Context) ([]*model.Locker, error)
+		// LockerInvitations returns pending invitations to join lockers, addressed
+		// to the account's identities.
+		LockerInvitations(ctx context.Context) ([]*model.LockerInvitation, error)
+		// AcceptLockerInvitation adds the locker from the invitation with the given ID
+		// to the account.
+		AcceptLockerInvitation(ctx context.Context, id string) (Locker, error)
+		// DeclineLockerInvitation declines the invitation with the given ID.
+		DeclineLockerInvitation(ctx context.Context, id string) error
 		GetLocker(ctx context.Context, lockerID string) (Locker, error)
 		GetRootLocker(ctx context.Context, level model.AccessLevel) (Locker, error)
 
@@ -221,6 +251,11 @@ type (
 		CreateIndex(ctx context.Context, indexStoreName, indexType string, opts ...index.Option) (index.Index, error)
 		Index(ctx context.Context, id string) (index.Index, error)
 
+		// SearchIndex returns the wallet's search index. If the index is not found,
+		// it will return index.ErrIndexNotFound. Use CreateIndex with index.TypeSearch
+		// to create a search index.
+		SearchIndex(ctx context.Context) (index.SearchIndex, error)
+
 		IndexUpdater(ctx context.Context, indexes ...index.Index) (*IndexUpdater, error)
 
 		DataStore() DataStore
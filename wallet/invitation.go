@@ -0,0 +1,360 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/scanner"
+	"github.com/piprate/metalocker/storage"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+  Locker invitation protocol:
+
+  1. The sender (a locker participant that generated the recipient's locker keys)
+     creates a model.LockerInvitation that contains the locker definition from
+     the recipient's perspective, signs it with the sender's DID key and encrypts
+     it with the recipient's verification key.
+  2. The encrypted invitation is stored in off-chain storage and published on the ledger
+     as an OpTypeLockerInvitation record. The record's routing key is derived
+     from the recipient's invitation inbox key (see model.BuildInvitationInboxKey).
+  3. The recipient scans the ledger for records in their inbox, decrypts and verifies
+     the invitations, and either accepts (the locker is added to the recipient's
+     wallet) or declines them. The decision is recorded as an account property.
+*/
+
+const (
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusDeclined = "declined"
+
+	lockerInvitationPropertyPrefix = "locker-invitation:"
+)
+
+var (
+	ErrLockerInvitationNotFound = errors.New("locker invitation not found")
+)
+
+type invitationConsumer struct {
+	records []invitationRecord
+}
+
+type invitationRecord struct {
+	recordID    string
+	recipientID string
+}
+
+var _ scanner.IndexBlockConsumer = (*invitationConsumer)(nil)
+
+func (ic *invitationConsumer) SetSubscription(sub scanner.Subscription) {}
+
+func (ic *invitationConsumer) ConsumeBlock(ctx context.Context, indexID string, partyLookup scanner.PartyLookup, n scanner.BlockNotification) error {
+	for _, dsn := range n.Datasets {
+		if dsn.Record.Operation != model.OpTypeLockerInvitation || dsn.Record.Status != model.StatusPublished {
+			continue
+		}
+		_, recipientID, _, _ := partyLookup(dsn.KeyID)
+		ic.records = append(ic.records, invitationRecord{
+			recordID:    dsn.RecordID,
+			recipientID: recipientID,
+		})
+	}
+	return nil
+}
+
+func (ic *invitationConsumer) NotifyScanCompleted(block int64) error {
+	return nil
+}
+
+func (dw *LocalDataWallet) sendLockerInvitation(ctx context.Context, sender *model.DID, recipientID string, locker *model.Locker, message string) dataset.RecordFuture {
+	recipient, err := dw.GetDID(ctx, recipientID)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	inv, err := model.NewLockerInvitation(sender, recipientID, locker, message)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	data, err := model.SealLockerInvitation(inv, recipient.VerKeyValue())
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	services := dw.Services()
+
+	opAddr, err := services.OffChainStorage().SendOperation(ctx, data)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	inboxKey, _, err := model.BuildInvitationInboxKey(recipient)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	keyIndex := model.RandomKeyIndex()
+	recordPrivKey, err := inboxKey.Derive(keyIndex)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+	recordPubKey, err := recordPrivKey.ECPubKey()
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	routingKey, _ := model.BuildRoutingKey(recordPubKey)
+
+	rec := &model.Record{
+		RoutingKey:       routingKey,
+		KeyIndex:         keyIndex,
+		Operation:        model.OpTypeLockerInvitation,
+		OperationAddress: opAddr,
+	}
+
+	pk, err := recordPrivKey.ECPrivKey()
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+	if err = rec.Seal(pk); err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	if err = services.Ledger().SubmitRecord(ctx, rec); err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	ns, err := services.NotificationService()
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	return dataset.RecordFutureWithResult(ctx, services.Ledger(), ns, rec.ID, nil, nil, []string{rec.ID})
+}
+
+// LockerInvitations returns pending locker invitations for all the account's identities,
+// in the order they were published on the ledger.
+func (dw *LocalDataWallet) LockerInvitations(ctx context.Context) ([]*model.LockerInvitation, error) {
+	if dw.lockLevel == model.AccessLevelNone {
+		return nil, ErrWalletLocked
+	}
+
+	identities, err := dw.GetIdentities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer := &invitationConsumer{}
+	sub := scanner.NewIndexSubscription("invitations:"+dw.ID(), consumer)
+
+	iids := make([]string, 0, len(identities))
+	for iid := range identities {
+		iids = append(iids, iid)
+	}
+	sort.Strings(iids)
+
+	for _, iid := range iids {
+		_, inboxPubKey, err := model.BuildInvitationInboxKey(identities[iid].DID())
+		if err != nil {
+			return nil, err
+		}
+		// a pseudo-locker that allows us to scan the ledger for records in the inbox
+		inbox := &model.Locker{
+			ID: "inbox:" + iid,
+			Participants: []*model.LockerParticipant{
+				{
+					ID:            iid,
+					RootPublicKey: inboxPubKey.String(),
+				},
+			},
+		}
+		if err = sub.AddLockers(scanner.LockerEntry{Locker: inbox}); err != nil {
+			return nil, err
+		}
+	}
+
+	ledgerScanner := scanner.NewScanner(dw.Services().Ledger())
+	if err = ledgerScanner.AddSubscription(sub); err != nil {
+		return nil, err
+	}
+	if _, err = ledgerScanner.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	props, err := dw.GetProperties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*model.LockerInvitation, 0, len(consumer.records))
+	for _, r := range consumer.records {
+		if _, processed := props[lockerInvitationPropertyPrefix+r.recordID]; processed {
+			continue
+		}
+
+		rec, err := dw.Services().Ledger().GetRecord(ctx, r.recordID)
+		if err != nil {
+			return nil, err
+		}
+
+		inv, err := dw.openLockerInvitation(ctx, rec, identities[r.recipientID])
+		if err != nil {
+			log.Warn().Err(err).Str("rid", r.recordID).Msg("Skipping invalid locker invitation")
+			continue
+		}
+
+		res = append(res, inv)
+	}
+
+	return res, nil
+}
+
+// AcceptLockerInvitation adds the locker from the invitation with the given ID
+// to the wallet. If the locker already exists in the wallet, any new participants
+// from the invitation will be added to it.
+func (dw *LocalDataWallet) AcceptLockerInvitation(ctx context.Context, id string) (Locker, error) {
+	inv, err := dw.getLockerInvitation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	locker := inv.Locker
+	if locker.AccessLevel > dw.acct.AccessLevel {
+		// the recipient decides how to store the locker
+		locker.AccessLevel = dw.acct.AccessLevel
+	}
+
+	var l Locker
+	if _, err = dw.GetLocker(ctx, locker.ID); err == nil {
+		l, err = dw.UpdateLocker(ctx, locker)
+	} else if errors.Is(err, storage.ErrLockerNotFound) {
+		// AddLocker sets the recipient's accepted-at block
+		l, err = dw.AddLocker(ctx, locker)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = dw.setLockerInvitationStatus(ctx, id, InvitationStatusAccepted); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// DeclineLockerInvitation marks the invitation with the given ID as declined.
+// Declined invitations aren't returned by LockerInvitations. The sender isn't notified.
+func (dw *LocalDataWallet) DeclineLockerInvitation(ctx context.Context, id string) error {
+	if _, err := dw.getLockerInvitation(ctx, id); err != nil {
+		return err
+	}
+
+	return dw.setLockerInvitationStatus(ctx, id, InvitationStatusDeclined)
+}
+
+func (dw *LocalDataWallet) setLockerInvitationStatus(ctx context.Context, id, status string) error {
+	key := lockerInvitationPropertyPrefix + id
+	if err := dw.DeleteProperty(ctx, key, model.AccessLevelManaged); err != nil &&
+		!errors.Is(err, storage.ErrPropertyNotFound) {
+		return err
+	}
+	return dw.SetProperty(ctx, key, status, model.AccessLevelManaged)
+}
+
+func (dw *LocalDataWallet) getLockerInvitation(ctx context.Context, id string) (*model.LockerInvitation, error) {
+	if dw.lockLevel == model.AccessLevelNone {
+		return nil, ErrWalletLocked
+	}
+
+	rec, err := dw.Services().Ledger().GetRecord(ctx, id)
+	if err != nil {
+		if errors.Is(err, model.ErrRecordNotFound) {
+			return nil, ErrLockerInvitationNotFound
+		}
+		return nil, err
+	}
+
+	if rec.Operation != model.OpTypeLockerInvitation || rec.Status != model.StatusPublished {
+		return nil, ErrLockerInvitationNotFound
+	}
+
+	identities, err := dw.GetIdentities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// find the identity whose inbox the record belongs to
+
+	for _, idy := range identities {
+		inboxKey, _, err := model.BuildInvitationInboxKey(idy.DID())
+		if err != nil {
+			return nil, err
+		}
+		k, err := inboxKey.Derive(rec.KeyIndex)
+		if err != nil {
+			return nil, err
+		}
+		pubKey, err := k.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		if rk, _ := model.BuildRoutingKey(pubKey); rk == rec.RoutingKey {
+			return dw.openLockerInvitation(ctx, rec, idy)
+		}
+	}
+
+	return nil, ErrLockerInvitationNotFound
+}
+
+func (dw *LocalDataWallet) openLockerInvitation(ctx context.Context, rec *model.Record, recipient Identity) (*model.LockerInvitation, error) {
+	if recipient == nil {
+		return nil, ErrLockerInvitationNotFound
+	}
+
+	data, err := dw.Services().OffChainStorage().GetOperation(ctx, rec.OperationAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	inv, err := model.OpenLockerInvitation(data, recipient.DID().SignKeyValue())
+	if err != nil {
+		return nil, err
+	}
+
+	if inv.Recipient != recipient.ID() {
+		return nil, model.ErrLockerInvitationInvalid
+	}
+
+	// check the sender's verification key belongs to the sender's DID
+
+	senderDID, err := dw.GetDID(ctx, inv.Sender)
+	if err != nil {
+		return nil, err
+	}
+	if senderDID.VerKey != inv.SenderVerKey {
+		return nil, fmt.Errorf("%w: sender's verification key doesn't match their DID", model.ErrLockerInvitationInvalid)
+	}
+
+	inv.ID = rec.ID
+
+	return inv, nil
+}
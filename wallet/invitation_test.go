@@ -0,0 +1,137 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	"github.com/piprate/metalocker/sdk/testbase"
+	. "github.com/piprate/metalocker/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalDataWallet_LockerInvitations(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw1 := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelHosted)
+	idy1, err := dw1.NewIdentity(ctx, model.AccessLevelHosted, "")
+	require.NoError(t, err)
+
+	dw2 := env.CreateCustomAccount(t, "test2@example.com", "John Doe 2", model.AccessLevelHosted)
+	idy2, err := dw2.NewIdentity(ctx, model.AccessLevelHosted, "")
+	require.NoError(t, err)
+
+	dw3 := env.CreateCustomAccount(t, "test3@example.com", "John Doe 3", model.AccessLevelHosted)
+
+	invitations, err := dw2.LockerInvitations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, invitations)
+
+	locker1, err := idy1.NewLocker(ctx, "Test Locker", Participant(idy2.DID(), nil))
+	require.NoError(t, err)
+
+	// participants can't invite themselves
+
+	f := locker1.Invite(ctx, idy1.ID(), "")
+	require.Error(t, f.Error())
+
+	f = locker1.Invite(ctx, idy2.ID(), "Please join")
+	require.NoError(t, f.Wait(time.Second*5))
+
+	invID := f.ID()
+
+	invitations, err = dw2.LockerInvitations(ctx)
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+
+	inv := invitations[0]
+	assert.Equal(t, invID, inv.ID)
+	assert.Equal(t, idy1.ID(), inv.Sender)
+	assert.Equal(t, idy2.ID(), inv.Recipient)
+	assert.Equal(t, "Please join", inv.Message)
+	assert.Equal(t, locker1.ID(), inv.Locker.ID)
+
+	// other accounts don't see the invitation
+
+	invitations, err = dw3.LockerInvitations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, invitations)
+
+	_, err = dw3.AcceptLockerInvitation(ctx, invID)
+	require.ErrorIs(t, err, ErrLockerInvitationNotFound)
+
+	_, err = dw2.AcceptLockerInvitation(ctx, "bad-id")
+	require.ErrorIs(t, err, ErrLockerInvitationNotFound)
+
+	// accept the invitation
+
+	rs, err := env.Ledger.GetRecordState(ctx, invID)
+	require.NoError(t, err)
+
+	locker2, err := dw2.AcceptLockerInvitation(ctx, invID)
+	require.NoError(t, err)
+	assert.Equal(t, locker1.ID(), locker2.ID())
+	assert.Equal(t, idy2.ID(), locker2.Us().ID)
+	assert.True(t, locker2.Raw().AcceptedAtBlock() > rs.BlockNumber)
+
+	invitations, err = dw2.LockerInvitations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, invitations)
+
+	// both parties can use the locker
+
+	f = locker2.Store(ctx, map[string]string{"type": "Map", "name": "Test Dataset"}, expiry.FromNow("1h"),
+		dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	ix, err := dw1.CreateRootIndex(ctx, testbase.IndexStoreName)
+	require.NoError(t, err)
+	updater, err := dw1.IndexUpdater(ctx, ix)
+	require.NoError(t, err)
+	require.NoError(t, updater.Sync(ctx))
+
+	ds, err := dw1.DataStore().Load(ctx, f.ID())
+	require.NoError(t, err)
+	assert.Equal(t, idy2.ID(), ds.ParticipantID())
+
+	// decline another invitation
+
+	anotherLocker, err := idy1.NewLocker(ctx, "Another Locker", Participant(idy2.DID(), nil))
+	require.NoError(t, err)
+
+	f = anotherLocker.Invite(ctx, idy2.ID(), "")
+	require.NoError(t, f.Wait(time.Second*5))
+
+	invitations, err = dw2.LockerInvitations(ctx)
+	require.NoError(t, err)
+	require.Len(t, invitations, 1)
+
+	require.NoError(t, dw2.DeclineLockerInvitation(ctx, f.ID()))
+
+	invitations, err = dw2.LockerInvitations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, invitations)
+
+	_, err = dw2.GetLocker(ctx, anotherLocker.ID())
+	require.Error(t, err)
+}
@@ -17,6 +17,7 @@ package wallet
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/piprate/metalocker/index"
 	"github.com/piprate/metalocker/model"
@@ -326,6 +327,10 @@ func (lf *LocalFactory) GetWalletWithAccessKey(ctx context.Context, apiKey, apiS
 		return nil, err
 	}
 
+	if ak.IsExpired(time.Now()) {
+		return nil, model.ErrAccessKeyExpired
+	}
+
 	localBackend := NewLocalNodeClient(ak.AccountID, lf.identityBackend, lf.ledger, lf.offChainStorage,
 		lf.blobManager, lf.notificationService)
 
@@ -16,6 +16,7 @@ package wallet
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/piprate/metalocker/model"
@@ -99,7 +100,7 @@ func (lw *lockerWrapper) Raw() *model.Locker {
 }
 
 func (lw *lockerWrapper) IsUniLocker() bool {
-	return len(lw.them) == 0
+	return len(lw.Them()) == 0
 }
 
 func (lw *lockerWrapper) IsThirdParty() bool {
@@ -119,6 +120,20 @@ func (lw *lockerWrapper) Them() []*model.LockerParticipant {
 	return lw.them
 }
 
+// shareRecipients returns IDs of all participants that will receive datasets shared through
+// the given locker. For uni-lockers, it's the locker owner.
+func shareRecipients(locker Locker) []string {
+	if locker.IsUniLocker() {
+		return []string{locker.Us().ID}
+	}
+	them := locker.Them()
+	ids := make([]string, len(them))
+	for i, p := range them {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
 func (lw *lockerWrapper) extractThem() []*model.LockerParticipant {
 	var them []*model.LockerParticipant
 	for _, p := range lw.raw.Participants {
@@ -129,6 +144,60 @@ func (lw *lockerWrapper) extractThem() []*model.LockerParticipant {
 	return them
 }
 
+func (lw *lockerWrapper) AddParticipant(ctx context.Context, did *model.DID, seed []byte) (Locker, error) {
+	if lw.us == nil {
+		return nil, errors.New("read-only locker")
+	}
+
+	topBlock, err := lw.wallet.Services().Ledger().GetTopBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	locker := lw.raw.Copy()
+	if _, err = locker.AddParticipant(model.Them(did, seed), topBlock.Number); err != nil {
+		return nil, err
+	}
+
+	return lw.wallet.UpdateLocker(ctx, locker)
+}
+
+func (lw *lockerWrapper) RotateKeys(ctx context.Context, seed []byte) (Locker, error) {
+	if lw.us == nil {
+		return nil, errors.New("read-only locker")
+	}
+
+	idy, err := lw.wallet.GetIdentity(ctx, lw.us.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	topBlock, err := lw.wallet.Services().Ledger().GetTopBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	locker := lw.raw.Copy()
+	if _, err = locker.RotateKeys(model.Us(idy.DID(), seed), topBlock.Number); err != nil {
+		return nil, err
+	}
+
+	return lw.wallet.UpdateLocker(ctx, locker)
+}
+
+func (lw *lockerWrapper) Invite(ctx context.Context, participantID, message string) dataset.RecordFuture {
+	if lw.us == nil {
+		return dataset.RecordFutureWithError(errors.New("read-only locker"))
+	}
+
+	idy, err := lw.wallet.GetIdentity(ctx, lw.us.ID)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	return lw.wallet.sendLockerInvitation(ctx, idy.DID(), participantID, lw.raw, message)
+}
+
 func (lw *lockerWrapper) NewDataSetBuilder(ctx context.Context, opts ...dataset.BuilderOption) (dataset.Builder, error) {
 	return lw.wallet.DataStore().NewDataSetBuilder(ctx, lw.ID(), opts...)
 }
@@ -163,5 +232,78 @@ func (lw *lockerWrapper) SetAssetHead(ctx context.Context, assetID, headName, re
 }
 
 func (lw *lockerWrapper) Seal(ctx context.Context) error {
-	panic("operation not implemented")
+	if lw.us == nil {
+		return errors.New("read-only locker")
+	}
+
+	if lw.raw.IsSealed() {
+		return model.ErrLockerSealed
+	}
+
+	f := lw.wallet.submitLockerSeal(ctx, lw.us)
+	if err := f.Wait(time.Minute); err != nil {
+		return err
+	}
+
+	rs, err := lw.wallet.Services().Ledger().GetRecordState(ctx, f.ID())
+	if err != nil {
+		return err
+	}
+
+	sealedAt, err := blockTime(ctx, lw.wallet.Services().Ledger(), rs.BlockNumber)
+	if err != nil {
+		return err
+	}
+
+	locker := lw.raw.Copy()
+	if err = locker.Seal(rs.BlockNumber, sealedAt); err != nil {
+		return err
+	}
+
+	_, err = lw.wallet.UpdateLocker(ctx, locker)
+	return err
+}
+
+// submitLockerSeal publishes the locker's seal record. The record's routing key is derived
+// from the participant's keys, so that all locker participants can see it.
+func (dw *LocalDataWallet) submitLockerSeal(ctx context.Context, p *model.LockerParticipant) dataset.RecordFuture {
+	keyIndex := model.RandomKeyIndex()
+
+	recordPrivKey, err := p.GetRecordPrivateKey(keyIndex)
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+	recordPubKey, err := recordPrivKey.ECPubKey()
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	routingKey, _ := model.BuildRoutingKey(recordPubKey)
+
+	rec := &model.Record{
+		RoutingKey: routingKey,
+		KeyIndex:   keyIndex,
+		Operation:  model.OpTypeLockerSeal,
+	}
+
+	pk, err := recordPrivKey.ECPrivKey()
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+	if err = rec.Seal(pk); err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	services := dw.Services()
+
+	if err = services.Ledger().SubmitRecord(ctx, rec); err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	ns, err := services.NotificationService()
+	if err != nil {
+		return dataset.RecordFutureWithError(err)
+	}
+
+	return dataset.RecordFutureWithResult(ctx, services.Ledger(), ns, rec.ID, nil, nil, []string{rec.ID})
 }
@@ -22,6 +22,7 @@ import (
 	"github.com/piprate/metalocker/model/dataset"
 	"github.com/piprate/metalocker/model/expiry"
 	"github.com/piprate/metalocker/sdk/testbase"
+	"github.com/piprate/metalocker/wallet"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -51,3 +52,335 @@ func TestLockerWrapper_Store(t *testing.T) {
 	require.NoError(t, f.DataSet().DecodeMetaResource(ctx, &res))
 	assert.Equal(t, "Test Dataset", res["name"])
 }
+
+func TestLockerWrapper_MultiParty(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	type party struct {
+		dw      wallet.DataWallet
+		idy     wallet.Identity
+		locker  wallet.Locker
+		updater *wallet.IndexUpdater
+	}
+
+	newParty := func(email string) *party {
+		dw := env.CreateCustomAccount(t, email, email, model.AccessLevelHosted)
+		idy, err := dw.NewIdentity(ctx, model.AccessLevelHosted, "")
+		require.NoError(t, err)
+		ix, err := dw.CreateRootIndex(ctx, testbase.IndexStoreName)
+		require.NoError(t, err)
+		updater, err := dw.IndexUpdater(ctx, ix)
+		require.NoError(t, err)
+		return &party{dw: dw, idy: idy, updater: updater}
+	}
+
+	p1 := newParty("test1@example.com")
+	p2 := newParty("test2@example.com")
+	p3 := newParty("test3@example.com")
+
+	var err error
+	p1.locker, err = p1.idy.NewLocker(ctx, "Group Locker",
+		wallet.Participant(p2.idy.DID(), nil),
+		wallet.Participant(p3.idy.DID(), nil))
+	require.NoError(t, err)
+
+	assert.False(t, p1.locker.IsUniLocker())
+	assert.True(t, p1.locker.Raw().IsMultiParty())
+	assert.Len(t, p1.locker.Them(), 2)
+
+	p2.locker, err = p2.dw.AddLocker(ctx, p1.locker.Raw().Perspective(p2.idy.ID()))
+	require.NoError(t, err)
+	p3.locker, err = p3.dw.AddLocker(ctx, p1.locker.Raw().Perspective(p3.idy.ID()))
+	require.NoError(t, err)
+
+	store := func(p *party, name string) string {
+		f := p.locker.Store(ctx, map[string]string{
+			"type": "Map",
+			"name": name,
+		}, expiry.FromNow("1h"), dataset.WithVault(testbase.TestVaultName))
+		require.NoError(t, f.Wait(time.Second*10))
+		return f.ID()
+	}
+
+	checkAccess := func(p *party, ids ...string) {
+		require.NoError(t, p.updater.Sync(ctx))
+		for _, id := range ids {
+			ds, err := p.dw.DataStore().Load(ctx, id)
+			require.NoError(t, err)
+			var res map[string]string
+			require.NoError(t, ds.DecodeMetaResource(ctx, &res))
+		}
+	}
+
+	// every participant can write to the locker and read records from others
+
+	rid1 := store(p1, "Dataset 1")
+	rid2 := store(p2, "Dataset 2")
+	rid3 := store(p3, "Dataset 3")
+
+	for _, p := range []*party{p1, p2, p3} {
+		checkAccess(p, rid1, rid2, rid3)
+	}
+
+	// add a new participant at a later block
+
+	p4 := newParty("test4@example.com")
+
+	p1.locker, err = p1.locker.AddParticipant(ctx, p4.idy.DID(), nil)
+	require.NoError(t, err)
+	assert.Len(t, p1.locker.Them(), 3)
+
+	p4Party := p1.locker.Raw().GetParticipant(p4.idy.ID())
+	require.NotNil(t, p4Party)
+	assert.True(t, p4Party.AcceptedAtBlock > p1.locker.Raw().FirstBlock)
+
+	_, err = p1.locker.AddParticipant(ctx, p4.idy.DID(), nil)
+	require.ErrorIs(t, err, model.ErrParticipantExists)
+
+	// existing participants can't be removed when updating the locker
+
+	p2.locker, err = p2.dw.UpdateLocker(ctx, p1.locker.Raw().Perspective(p2.idy.ID()))
+	require.NoError(t, err)
+	require.NotNil(t, p2.locker.Us())
+
+	badLocker := p1.locker.Raw().Perspective(p3.idy.ID())
+	badLocker.Participants = badLocker.Participants[1:]
+	_, err = p3.dw.UpdateLocker(ctx, badLocker)
+	require.ErrorIs(t, err, model.ErrParticipantInvalid)
+
+	p3.locker, err = p3.dw.UpdateLocker(ctx, p1.locker.Raw().Perspective(p3.idy.ID()))
+	require.NoError(t, err)
+	assert.Len(t, p3.locker.Them(), 3)
+
+	p4.locker, err = p4.dw.AddLocker(ctx, p1.locker.Raw().Perspective(p4.idy.ID()))
+	require.NoError(t, err)
+
+	rid4 := store(p4, "Dataset 4")
+
+	for _, p := range []*party{p1, p2, p3, p4} {
+		checkAccess(p, rid4)
+	}
+	checkAccess(p4, rid1, rid2, rid3)
+}
+
+func TestLockerWrapper_RotateKeys(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	type party struct {
+		dw      wallet.DataWallet
+		idy     wallet.Identity
+		locker  wallet.Locker
+		updater *wallet.IndexUpdater
+	}
+
+	newParty := func(email string) *party {
+		dw := env.CreateCustomAccount(t, email, email, model.AccessLevelHosted)
+		idy, err := dw.NewIdentity(ctx, model.AccessLevelHosted, "")
+		require.NoError(t, err)
+		ix, err := dw.CreateRootIndex(ctx, testbase.IndexStoreName)
+		require.NoError(t, err)
+		updater, err := dw.IndexUpdater(ctx, ix)
+		require.NoError(t, err)
+		return &party{dw: dw, idy: idy, updater: updater}
+	}
+
+	p1 := newParty("test1@example.com")
+	p2 := newParty("test2@example.com")
+
+	var err error
+	p1.locker, err = p1.idy.NewLocker(ctx, "Test Locker", wallet.Participant(p2.idy.DID(), nil))
+	require.NoError(t, err)
+
+	p2.locker, err = p2.dw.AddLocker(ctx, p1.locker.Raw().Perspective(p2.idy.ID()))
+	require.NoError(t, err)
+
+	assetID := "test1"
+
+	store := func(p *party, name string) string {
+		f := p.locker.Store(ctx, map[string]string{
+			"id":   assetID,
+			"type": "Map",
+			"name": name,
+		}, expiry.FromNow("1h"), dataset.WithVault(testbase.TestVaultName))
+		require.NoError(t, f.Wait(time.Second*10))
+		return f.ID()
+	}
+
+	checkAccess := func(p *party, ids ...string) {
+		require.NoError(t, p.updater.Sync(ctx))
+		for _, id := range ids {
+			ds, err := p.dw.DataStore().Load(ctx, id)
+			require.NoError(t, err)
+			var res map[string]string
+			require.NoError(t, ds.DecodeMetaResource(ctx, &res))
+		}
+	}
+
+	rid1 := store(p2, "Dataset 1")
+	rid2 := store(p2, "Dataset 2")
+
+	headID := p2.locker.HeadID(ctx, assetID, "test")
+	require.NoError(t, p2.locker.SetAssetHead(ctx, assetID, "test", rid1).Wait(time.Second*10))
+
+	checkAccess(p1, rid1, rid2)
+
+	// the second party rotates its keys
+
+	oldKey := p2.locker.Us().RootPublicKey
+
+	p2.locker, err = p2.locker.RotateKeys(ctx, nil)
+	require.NoError(t, err)
+
+	us := p2.locker.Us()
+	assert.NotEqual(t, oldKey, us.RootPublicKey)
+	require.Len(t, us.PreviousEpochs, 1)
+	assert.Equal(t, oldKey, us.PreviousEpochs[0].RootPublicKey)
+
+	// the first party can't modify the keys in any other way
+
+	forged := p2.locker.Raw().Perspective(p1.idy.ID())
+	forged.GetParticipant(p2.idy.ID()).PreviousEpochs = nil
+	_, err = p1.dw.UpdateLocker(ctx, forged)
+	require.ErrorIs(t, err, model.ErrParticipantInvalid)
+
+	p1.locker, err = p1.dw.UpdateLocker(ctx, p2.locker.Raw().Perspective(p1.idy.ID()))
+	require.NoError(t, err)
+
+	rid3 := store(p2, "Dataset 3")
+
+	// records issued with both old and new keys remain accessible
+
+	checkAccess(p1, rid1, rid2, rid3)
+	checkAccess(p2, rid1, rid2, rid3)
+
+	// the asset head ID doesn't change and can be updated with the new keys
+
+	assert.Equal(t, headID, p2.locker.HeadID(ctx, assetID, "test"))
+	require.NoError(t, p2.locker.SetAssetHead(ctx, assetID, "test", rid3).Wait(time.Second*10))
+
+	ds, err := p1.dw.DataStore().AssetHead(ctx, headID)
+	require.NoError(t, err)
+	assert.Equal(t, rid3, ds.ID())
+
+	// records issued with the old keys can still be revoked
+
+	require.NoError(t, p2.dw.DataStore().Revoke(ctx, rid2).Wait(time.Second*10))
+
+	rs, err := env.Ledger.GetRecordState(ctx, rid2)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRevoked, rs.Status)
+}
+
+func TestLockerWrapper_Seal(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	type party struct {
+		dw      wallet.DataWallet
+		idy     wallet.Identity
+		locker  wallet.Locker
+		updater *wallet.IndexUpdater
+	}
+
+	newParty := func(email string) *party {
+		dw := env.CreateCustomAccount(t, email, email, model.AccessLevelHosted)
+		idy, err := dw.NewIdentity(ctx, model.AccessLevelHosted, "")
+		require.NoError(t, err)
+		ix, err := dw.CreateRootIndex(ctx, testbase.IndexStoreName)
+		require.NoError(t, err)
+		updater, err := dw.IndexUpdater(ctx, ix)
+		require.NoError(t, err)
+		return &party{dw: dw, idy: idy, updater: updater}
+	}
+
+	p1 := newParty("test1@example.com")
+	p2 := newParty("test2@example.com")
+
+	var err error
+	p1.locker, err = p1.idy.NewLocker(ctx, "Test Locker", wallet.Participant(p2.idy.DID(), nil))
+	require.NoError(t, err)
+
+	p2.locker, err = p2.dw.AddLocker(ctx, p1.locker.Raw().Perspective(p2.idy.ID()))
+	require.NoError(t, err)
+
+	store := func(l wallet.Locker, name string) dataset.RecordFuture {
+		return l.Store(ctx, map[string]string{
+			"type": "Map",
+			"name": name,
+		}, expiry.FromNow("1h"), dataset.WithVault(testbase.TestVaultName))
+	}
+
+	f := store(p2.locker, "Dataset 1")
+	require.NoError(t, f.Wait(time.Second*10))
+	rid1 := f.ID()
+
+	// the first party seals the locker
+
+	require.NoError(t, p1.locker.Seal(ctx))
+
+	p1.locker, err = p1.dw.GetLocker(ctx, p1.locker.ID())
+	require.NoError(t, err)
+	assert.True(t, p1.locker.Raw().IsSealed())
+	assert.NotZero(t, p1.locker.Raw().LastBlock)
+
+	require.ErrorIs(t, p1.locker.Seal(ctx), model.ErrLockerSealed)
+
+	f = store(p1.locker, "Dataset 2")
+	require.ErrorIs(t, f.Wait(time.Second*10), model.ErrLockerSealed)
+
+	ds, err := p1.dw.DataStore().Load(ctx, rid1)
+	require.NoError(t, err)
+	f = p1.dw.DataStore().Share(ctx, ds, p1.locker, testbase.TestVaultName, expiry.FromNow("1h"))
+	require.ErrorIs(t, f.Wait(time.Second*10), model.ErrLockerSealed)
+
+	// the second party isn't yet aware of the seal. Its records are ignored by all indexes
+
+	f = store(p2.locker, "Dataset 3")
+	require.NoError(t, f.Wait(time.Second*10))
+	rid3 := f.ID()
+
+	require.NoError(t, p1.updater.Sync(ctx))
+	require.NoError(t, p2.updater.Sync(ctx))
+
+	_, err = p1.dw.DataStore().Load(ctx, rid1)
+	require.NoError(t, err)
+	_, err = p1.dw.DataStore().Load(ctx, rid3)
+	require.ErrorIs(t, err, model.ErrDataSetNotFound)
+
+	for _, p := range []*party{p1, p2} {
+		ix, err := p.dw.RootIndex(ctx)
+		require.NoError(t, err)
+		rs, err := ix.GetRecord(ctx, rid1)
+		require.NoError(t, err)
+		assert.NotNil(t, rs)
+		rs, err = ix.GetRecord(ctx, rid3)
+		require.NoError(t, err)
+		assert.Nil(t, rs)
+	}
+
+	// the second party's data wallet learns about the seal from its index
+
+	p2.locker, err = p2.dw.GetLocker(ctx, p2.locker.ID())
+	require.NoError(t, err)
+	assert.True(t, p2.locker.Raw().IsSealed())
+	assert.Equal(t, p1.locker.Raw().LastBlock, p2.locker.Raw().LastBlock)
+
+	// both parties record the time of the block with the seal record
+
+	sealBlock, err := env.Ledger.GetBlock(ctx, p1.locker.Raw().LastBlock)
+	require.NoError(t, err)
+	require.NotZero(t, sealBlock.Timestamp)
+	assert.Equal(t, sealBlock.Timestamp, p1.locker.Raw().Sealed.Unix())
+	assert.Equal(t, sealBlock.Timestamp, p2.locker.Raw().Sealed.Unix())
+
+	f = store(p2.locker, "Dataset 4")
+	require.ErrorIs(t, f.Wait(time.Second*10), model.ErrLockerSealed)
+}
@@ -0,0 +1,356 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/jsonw"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ProvRelationWasRevisionOf    = "wasRevisionOf"
+	ProvRelationSpecializationOf = "specializationOf"
+	ProvRelationWasAttributedTo  = "wasAttributedTo"
+	ProvRelationWasQuotedFrom    = "wasQuotedFrom"
+	ProvRelationWasAccessibleTo  = "wasAccessibleTo"
+
+	ProvKindImpression = "Impression"
+	ProvKindShare      = "Share"
+)
+
+var ErrProvenanceNotVerified = errors.New("provenance signature verification failed")
+
+type (
+	// ProvenanceRecord is a ledger record where the given provenance entity appeared.
+	ProvenanceRecord struct {
+		RecordID    string `json:"record"`
+		LockerID    string `json:"locker"`
+		BlockNumber int64  `json:"block"`
+	}
+
+	// ProvenanceNode is a node (entity or agent) in the provenance graph.
+	ProvenanceNode struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		// Kind specifies the kind of entity: an impression or a share. Empty for agents.
+		Kind            string              `json:"kind,omitempty"`
+		AssetID         string              `json:"asset,omitempty"`
+		ContentType     string              `json:"contentType,omitempty"`
+		RevisionNumber  int64               `json:"revisionNumber,omitempty"`
+		GeneratedAtTime *time.Time          `json:"generatedAtTime,omitempty"`
+		Records         []*ProvenanceRecord `json:"records,omitempty"`
+		// Resolved is false for entities that are referenced by other entities,
+		// but can't be read by the data wallet.
+		Resolved bool `json:"resolved"`
+		// Verified is true if the entity's signature was successfully verified.
+		Verified          bool   `json:"verified"`
+		VerificationError string `json:"verificationError,omitempty"`
+	}
+
+	// ProvenanceEdge is a PROV relation between two nodes of the provenance graph.
+	ProvenanceEdge struct {
+		Source   string `json:"source"`
+		Target   string `json:"target"`
+		Relation string `json:"relation"`
+	}
+
+	// ProvenanceGraph is the revision and sharing provenance of an asset, assembled from
+	// impressions and share provenance entities of all the datasets the data wallet can read.
+	ProvenanceGraph struct {
+		AssetID string            `json:"asset"`
+		Nodes   []*ProvenanceNode `json:"nodes"`
+		Edges   []*ProvenanceEdge `json:"edges"`
+
+		nodes map[string]*ProvenanceNode
+		edges map[ProvenanceEdge]bool
+	}
+
+	provenanceBuilder struct {
+		dw    DataWallet
+		graph *ProvenanceGraph
+		keys  map[string]*model.DID
+	}
+)
+
+// BuildProvenanceGraph assembles the provenance graph of the asset behind the dataset
+// with the given record ID. It walks all the revisions and shares of the asset across
+// all the lockers the data wallet can read (as per its root index) and verifies the
+// signatures of all impressions and share provenance entities along the way.
+func BuildProvenanceGraph(ctx context.Context, dw DataWallet, recordID string) (*ProvenanceGraph, error) {
+	ds, err := dw.DataStore().Load(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+
+	assetID := ds.Impression().Asset
+
+	recordIDs, err := AssetRevisionChain(ctx, dw, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	pb := &provenanceBuilder{
+		dw: dw,
+		graph: &ProvenanceGraph{
+			AssetID: assetID,
+			nodes:   make(map[string]*ProvenanceNode),
+			edges:   make(map[ProvenanceEdge]bool),
+		},
+		keys: make(map[string]*model.DID),
+	}
+
+	if err = pb.addDataSet(ctx, ds); err != nil {
+		return nil, err
+	}
+
+	for _, rid := range recordIDs {
+		if rid == recordID {
+			continue
+		}
+
+		ds, err = dw.DataStore().Load(ctx, rid)
+		if err != nil {
+			if errors.Is(err, model.ErrDataSetNotFound) {
+				log.Warn().Str("rid", rid).Msg("Dataset not accessible, skipping it in provenance graph")
+				continue
+			}
+			return nil, err
+		}
+
+		if err = pb.addDataSet(ctx, ds); err != nil {
+			return nil, err
+		}
+	}
+
+	pb.graph.sort()
+
+	return pb.graph, nil
+}
+
+func (pb *provenanceBuilder) addDataSet(ctx context.Context, ds model.DataSet) error {
+	imp := ds.Impression()
+	rec := &ProvenanceRecord{
+		RecordID:    ds.ID(),
+		LockerID:    ds.LockerID(),
+		BlockNumber: ds.BlockNumber(),
+	}
+
+	node := pb.graph.node(imp.ID, model.ProvTypeEntity)
+	if !node.Resolved {
+		node.Resolved = true
+		node.Kind = ProvKindImpression
+		node.AssetID = imp.Asset
+		node.RevisionNumber = imp.Revision()
+		node.GeneratedAtTime = imp.GeneratedAtTime
+		if imp.MetaResource != nil {
+			node.ContentType = imp.MetaResource.ContentType
+		}
+
+		pb.verify(ctx, node, imp.WasAttributedTo, imp.MerkleVerify)
+
+		if imp.WasRevisionOf != "" {
+			pb.graph.node(imp.WasRevisionOf, model.ProvTypeEntity)
+			pb.graph.addEdge(imp.ID, imp.WasRevisionOf, ProvRelationWasRevisionOf)
+		}
+		if imp.SpecializationOf != "" {
+			pb.graph.node(imp.SpecializationOf, model.ProvTypeEntity)
+			pb.graph.addEdge(imp.ID, imp.SpecializationOf, ProvRelationSpecializationOf)
+		}
+		pb.addAttribution(imp.ID, imp.WasAttributedTo)
+	}
+
+	if prov := ds.Lease().Provenance; prov != nil {
+		shareNode := pb.addShareEntity(ctx, prov)
+		shareNode.Records = append(shareNode.Records, rec)
+	} else {
+		node.Records = append(node.Records, rec)
+	}
+
+	return nil
+}
+
+func (pb *provenanceBuilder) addShareEntity(ctx context.Context, pe *model.ProvEntity) *ProvenanceNode {
+	node := pb.graph.node(pe.ID, model.ProvTypeEntity)
+	if node.Resolved {
+		return node
+	}
+
+	node.Resolved = true
+	node.Kind = ProvKindShare
+	node.GeneratedAtTime = pe.GeneratedAtTime
+
+	// nested share entities are stored without JSON-LD context
+	if pe.Context == nil {
+		pe = pe.Copy()
+		pe.Context = model.PiprateContextURL
+	}
+	pb.verify(ctx, node, pe.WasAttributedTo, pe.MerkleVerify)
+
+	pb.addAttribution(pe.ID, pe.WasAttributedTo)
+
+	switch src := pe.WasQuotedFrom.(type) {
+	case string:
+		pb.graph.node(src, model.ProvTypeEntity)
+		pb.graph.addEdge(pe.ID, src, ProvRelationWasQuotedFrom)
+	case map[string]any:
+		var nested *model.ProvEntity
+		if b, err := jsonw.Marshal(src); err == nil && jsonw.Unmarshal(b, &nested) == nil && nested.ID != "" {
+			pb.addShareEntity(ctx, nested)
+			pb.graph.addEdge(pe.ID, nested.ID, ProvRelationWasQuotedFrom)
+		}
+	}
+
+	switch recipients := pe.WasAccessibleTo.(type) {
+	case string:
+		pb.graph.node(recipients, model.ProvTypeAgent)
+		pb.graph.addEdge(pe.ID, recipients, ProvRelationWasAccessibleTo)
+	case []any:
+		for _, r := range recipients {
+			if rid, ok := r.(string); ok {
+				pb.graph.node(rid, model.ProvTypeAgent)
+				pb.graph.addEdge(pe.ID, rid, ProvRelationWasAccessibleTo)
+			}
+		}
+	}
+
+	return node
+}
+
+func (pb *provenanceBuilder) addAttribution(entityID, agentID string) {
+	if agentID == "" {
+		return
+	}
+	pb.graph.node(agentID, model.ProvTypeAgent).Resolved = true
+	pb.graph.addEdge(entityID, agentID, ProvRelationWasAttributedTo)
+}
+
+func (pb *provenanceBuilder) verify(ctx context.Context, node *ProvenanceNode, signerID string, verifyFn func(key ed25519.PublicKey) (bool, error)) {
+	did, found := pb.keys[signerID]
+	if !found {
+		var err error
+		did, err = pb.dw.GetDID(ctx, signerID)
+		if err != nil {
+			node.VerificationError = fmt.Sprintf("unable to resolve signer %s: %s", signerID, err.Error())
+			return
+		}
+		pb.keys[signerID] = did
+	}
+
+	verified, err := verifyFn(did.VerKeyValue())
+	if err != nil {
+		node.VerificationError = err.Error()
+	} else if !verified {
+		node.VerificationError = ErrProvenanceNotVerified.Error()
+	}
+	node.Verified = verified && err == nil
+}
+
+func (g *ProvenanceGraph) node(id, nodeType string) *ProvenanceNode {
+	n, found := g.nodes[id]
+	if !found {
+		n = &ProvenanceNode{
+			ID:   id,
+			Type: nodeType,
+		}
+		g.nodes[id] = n
+		g.Nodes = append(g.Nodes, n)
+	}
+	return n
+}
+
+func (g *ProvenanceGraph) addEdge(source, target, relation string) {
+	e := ProvenanceEdge{
+		Source:   source,
+		Target:   target,
+		Relation: relation,
+	}
+	if !g.edges[e] {
+		g.edges[e] = true
+		g.Edges = append(g.Edges, &e)
+	}
+}
+
+func (g *ProvenanceGraph) sort() {
+	sort.SliceStable(g.Nodes, func(i, j int) bool {
+		ni, nj := g.Nodes[i], g.Nodes[j]
+		if ni.Type != nj.Type {
+			return ni.Type == model.ProvTypeEntity
+		}
+		if ni.GeneratedAtTime != nil && nj.GeneratedAtTime != nil && !ni.GeneratedAtTime.Equal(*nj.GeneratedAtTime) {
+			return ni.GeneratedAtTime.Before(*nj.GeneratedAtTime)
+		}
+		return ni.ID < nj.ID
+	})
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		ei, ej := g.Edges[i], g.Edges[j]
+		if ei.Source != ej.Source {
+			return ei.Source < ej.Source
+		}
+		if ei.Relation != ej.Relation {
+			return ei.Relation < ej.Relation
+		}
+		return ei.Target < ej.Target
+	})
+}
+
+// Node returns the graph node with the given ID or nil, if not found.
+func (g *ProvenanceGraph) Node(id string) *ProvenanceNode {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// Unverified returns all the resolved entities whose signatures couldn't be verified.
+func (g *ProvenanceGraph) Unverified() []*ProvenanceNode {
+	var res []*ProvenanceNode
+	for _, n := range g.Nodes {
+		if n.Type == model.ProvTypeEntity && n.Resolved && !n.Verified {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func (g *ProvenanceGraph) outgoing(id string) []*ProvenanceEdge {
+	var res []*ProvenanceEdge
+	for _, e := range g.Edges {
+		if e.Source == id {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func shortID(id string) string {
+	if idx := strings.LastIndex(id, ":"); idx >= 0 {
+		id = id[idx+1:]
+	}
+	if len(id) > 12 {
+		return id[:12] + "…"
+	}
+	return id
+}
@@ -0,0 +1,275 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/utils/jsonw"
+)
+
+const (
+	ProvFormatJSONLD = "jsonld"
+	ProvFormatProvN  = "provn"
+	ProvFormatDOT    = "dot"
+
+	// provBaseURI is the base for relative entity IDs (as used in impression signatures).
+	provBaseURI = "http://crvy.org/"
+	provCrvyURI = "http://crvy.org/schema/v1#"
+	provContext = "https://piprate.org/context/prov.jsonld"
+)
+
+// Export writes the provenance graph in the given format (ProvFormatJSONLD,
+// ProvFormatProvN or ProvFormatDOT).
+func (g *ProvenanceGraph) Export(w io.Writer, format string) error {
+	var b []byte
+	switch format {
+	case ProvFormatJSONLD:
+		var err error
+		if b, err = g.JSONLD(); err != nil {
+			return err
+		}
+	case ProvFormatProvN:
+		b = []byte(g.ProvN())
+	case ProvFormatDOT:
+		b = []byte(g.DOT())
+	default:
+		return fmt.Errorf("unsupported provenance format: %s", format)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// JSONLD returns the provenance graph as a PROV-JSON-LD document.
+func (g *ProvenanceGraph) JSONLD() ([]byte, error) {
+	graph := make([]map[string]any, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		node := map[string]any{
+			"id":   n.ID,
+			"type": n.Type,
+		}
+		if n.Kind != "" {
+			node["type"] = []string{n.Type, "crvy:" + n.Kind}
+		}
+		if n.AssetID != "" {
+			node["asset"] = n.AssetID
+		}
+		if n.ContentType != "" {
+			node["contentType"] = n.ContentType
+		}
+		if n.RevisionNumber != 0 {
+			node["revisionNumber"] = n.RevisionNumber
+		}
+		if n.GeneratedAtTime != nil {
+			node["generatedAtTime"] = n.GeneratedAtTime.UTC().Format(time.RFC3339Nano)
+		}
+		if n.Type == model.ProvTypeEntity && n.Resolved {
+			node["verified"] = n.Verified
+		}
+
+		targets := make(map[string][]string)
+		for _, e := range g.outgoing(n.ID) {
+			targets[e.Relation] = append(targets[e.Relation], e.Target)
+		}
+		for rel, ids := range targets {
+			if len(ids) == 1 {
+				node[rel] = ids[0]
+			} else {
+				node[rel] = ids
+			}
+		}
+
+		graph = append(graph, node)
+	}
+
+	doc := map[string]any{
+		"@context": []any{
+			provContext,
+			map[string]any{
+				"@base": provBaseURI,
+				"id":    "@id",
+				"type":  "@type",
+				"crvy":  provCrvyURI,
+				"asset": map[string]any{
+					"@id":   "crvy:asset",
+					"@type": "@id",
+				},
+				"contentType": "crvy:contentType",
+				"revisionNumber": map[string]any{
+					"@id":   "crvy:revisionNumber",
+					"@type": "xsd:integer",
+				},
+				"verified": map[string]any{
+					"@id":   "crvy:verified",
+					"@type": "xsd:boolean",
+				},
+				"wasAccessibleTo": map[string]any{
+					"@id":   "crvy:wasAccessibleTo",
+					"@type": "@id",
+				},
+			},
+		},
+		"@graph": graph,
+	}
+
+	return jsonw.MarshalIndent(doc, "", "  ")
+}
+
+// ProvN returns the provenance graph in PROV-N notation (https://www.w3.org/TR/prov-n/).
+func (g *ProvenanceGraph) ProvN() string {
+	prefixes := map[string]string{
+		"crvy": provCrvyURI,
+		"ml":   provBaseURI,
+	}
+	qn := func(id string) string {
+		scheme, local, found := strings.Cut(id, ":")
+		if !found || strings.HasPrefix(local, "//") {
+			return "ml:" + escapeProvNLocal(id)
+		}
+		prefixes[scheme] = scheme + ":"
+		return scheme + ":" + escapeProvNLocal(local)
+	}
+
+	var body strings.Builder
+	for _, n := range g.Nodes {
+		if n.Type == model.ProvTypeAgent {
+			fmt.Fprintf(&body, "  agent(%s)\n", qn(n.ID))
+			continue
+		}
+
+		var attrs []string
+		if n.Kind != "" {
+			attrs = append(attrs, fmt.Sprintf("prov:type='crvy:%s'", n.Kind))
+		}
+		if n.AssetID != "" {
+			attrs = append(attrs, fmt.Sprintf("crvy:asset='%s'", qn(n.AssetID)))
+		}
+		if n.ContentType != "" {
+			attrs = append(attrs, fmt.Sprintf("crvy:contentType=%q", n.ContentType))
+		}
+		if n.RevisionNumber != 0 {
+			attrs = append(attrs, fmt.Sprintf("crvy:revisionNumber=%d", n.RevisionNumber))
+		}
+		if n.Resolved {
+			attrs = append(attrs, fmt.Sprintf("crvy:verified=\"%t\" %%%% xsd:boolean", n.Verified))
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&body, "  entity(%s, [%s])\n", qn(n.ID), strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(&body, "  entity(%s)\n", qn(n.ID))
+		}
+		if n.GeneratedAtTime != nil {
+			fmt.Fprintf(&body, "  wasGeneratedBy(%s, -, %s)\n", qn(n.ID), n.GeneratedAtTime.UTC().Format(time.RFC3339Nano))
+		}
+	}
+
+	for _, e := range g.Edges {
+		src, tgt := qn(e.Source), qn(e.Target)
+		switch e.Relation {
+		case ProvRelationWasRevisionOf:
+			fmt.Fprintf(&body, "  wasDerivedFrom(%s, %s, -, -, -, [prov:type='prov:Revision'])\n", src, tgt)
+		case ProvRelationWasQuotedFrom:
+			fmt.Fprintf(&body, "  wasDerivedFrom(%s, %s, -, -, -, [prov:type='prov:Quotation'])\n", src, tgt)
+		case ProvRelationSpecializationOf:
+			fmt.Fprintf(&body, "  specializationOf(%s, %s)\n", src, tgt)
+		case ProvRelationWasAttributedTo:
+			fmt.Fprintf(&body, "  wasAttributedTo(%s, %s)\n", src, tgt)
+		case ProvRelationWasAccessibleTo:
+			// PROV doesn't have a relation for access grants; we express it as influence
+			fmt.Fprintf(&body, "  wasInfluencedBy(%s, %s, [prov:type='crvy:wasAccessibleTo'])\n", tgt, src)
+		}
+	}
+
+	names := make([]string, 0, len(prefixes))
+	for name := range prefixes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("document\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  prefix %s <%s>\n", name, prefixes[name])
+	}
+	sb.WriteString("\n")
+	sb.WriteString(body.String())
+	sb.WriteString("endDocument\n")
+
+	return sb.String()
+}
+
+// escapeProvNLocal escapes the characters that aren't allowed in PROV-N local names.
+func escapeProvNLocal(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`='(),;[]`, r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// DOT returns the provenance graph in Graphviz DOT format. It uses the conventional
+// PROV colours and shapes: entities are yellow ellipses, agents are orange houses.
+// Entities with unverified signatures are outlined in red.
+func (g *ProvenanceGraph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph provenance {\n")
+	sb.WriteString("  rankdir=\"BT\";\n")
+
+	for _, n := range g.Nodes {
+		var attrs string
+		if n.Type == model.ProvTypeAgent {
+			attrs = fmt.Sprintf("label=%s, shape=house, style=filled, fillcolor=\"#FED37F\"", dotQuote(shortID(n.ID)))
+		} else {
+			label := shortID(n.ID)
+			if n.Kind != "" {
+				label = n.Kind + "\n" + label
+			}
+			if n.RevisionNumber != 0 {
+				label += fmt.Sprintf("\nrev %d", n.RevisionNumber)
+			}
+			color := "black"
+			if !n.Resolved {
+				color = "grey"
+			} else if !n.Verified {
+				color = "red"
+			}
+			attrs = fmt.Sprintf("label=%s, shape=ellipse, style=filled, fillcolor=\"#FFFC87\", color=%s", dotQuote(label), color)
+		}
+		fmt.Fprintf(&sb, "  %s [%s];\n", dotQuote(n.ID), attrs)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "  %s -> %s [label=%s];\n", dotQuote(e.Source), dotQuote(e.Target), dotQuote(e.Relation))
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
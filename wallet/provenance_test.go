@@ -0,0 +1,175 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	"github.com/piprate/metalocker/sdk/testbase"
+	. "github.com/piprate/metalocker/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildProvenanceGraph(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw1 := env.CreateCustomAccount(t, "test1@example.com", "John Doe 1", model.AccessLevelManaged)
+	idy1, err := dw1.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	dw2 := env.CreateCustomAccount(t, "test2@example.com", "John Doe 2", model.AccessLevelManaged)
+	idy2, err := dw2.NewIdentity(ctx, model.AccessLevelManaged, "")
+	require.NoError(t, err)
+
+	uniLocker, err := idy1.NewLocker(ctx, "Uni-locker")
+	require.NoError(t, err)
+
+	sharedLocker, err := idy1.NewLocker(ctx, "Shared Locker", Participant(idy2.DID(), nil))
+	require.NoError(t, err)
+
+	rootIndex, err := dw1.CreateRootIndex(ctx, testbase.IndexStoreName)
+	require.NoError(t, err)
+
+	updater, err := dw1.IndexUpdater(ctx, rootIndex)
+	require.NoError(t, err)
+	defer updater.Close()
+
+	// create a data set and its revision
+
+	lb, err := uniLocker.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+	_, err = lb.AddMetaResource(map[string]any{
+		"type":  "TestDataset",
+		"value": 1,
+	})
+	require.NoError(t, err)
+	f := lb.Submit(expiry.FromNow("1h"))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	firstRev := f.DataSet().Impression()
+
+	lb, err = uniLocker.NewDataSetBuilder(ctx,
+		dataset.WithVault(testbase.TestVaultName),
+		dataset.WithParent(f.ID(), "", dataset.CopyModeNone, nil, false))
+	require.NoError(t, err)
+	_, err = lb.AddMetaResource(map[string]any{
+		"type":  "TestDataset",
+		"value": 2,
+	})
+	require.NoError(t, err)
+	f = lb.Submit(expiry.FromNow("1h"))
+	require.NoError(t, f.Wait(time.Second*10))
+
+	secondRecordID := f.ID()
+	secondRev := f.DataSet().Impression()
+
+	require.NoError(t, updater.Sync(ctx))
+
+	// share the latest revision into the shared locker
+
+	sf := sharedLocker.Share(ctx, secondRecordID, testbase.TestVaultName, expiry.FromNow("1h"))
+	require.NoError(t, sf.Wait(time.Second*10))
+
+	require.NoError(t, updater.Sync(ctx))
+
+	graph, err := BuildProvenanceGraph(ctx, dw1, sf.ID())
+	require.NoError(t, err)
+
+	assert.Equal(t, firstRev.Asset, graph.AssetID)
+	assert.Empty(t, graph.Unverified())
+
+	// check impressions
+
+	n := graph.Node(firstRev.ID)
+	require.NotNil(t, n)
+	assert.Equal(t, ProvKindImpression, n.Kind)
+	assert.True(t, n.Verified)
+
+	n = graph.Node(secondRev.ID)
+	require.NotNil(t, n)
+	assert.Equal(t, ProvKindImpression, n.Kind)
+	assert.True(t, n.Verified)
+	require.Len(t, n.Records, 1)
+	assert.Equal(t, secondRecordID, n.Records[0].RecordID)
+
+	require.NotNil(t, graph.Node(idy1.ID()))
+	assert.Equal(t, model.ProvTypeAgent, graph.Node(idy1.ID()).Type)
+
+	hasEdge := func(source, target, relation string) bool {
+		for _, e := range graph.Edges {
+			if e.Source == source && e.Target == target && e.Relation == relation {
+				return true
+			}
+		}
+		return false
+	}
+
+	assert.True(t, hasEdge(secondRev.ID, firstRev.ID, ProvRelationWasRevisionOf))
+	assert.True(t, hasEdge(secondRev.ID, firstRev.ID, ProvRelationSpecializationOf))
+	assert.True(t, hasEdge(firstRev.ID, idy1.ID(), ProvRelationWasAttributedTo))
+
+	// check the share
+
+	var share *ProvenanceNode
+	for _, node := range graph.Nodes {
+		if node.Kind == ProvKindShare {
+			share = node
+		}
+	}
+	require.NotNil(t, share)
+	assert.True(t, share.Verified)
+	require.Len(t, share.Records, 1)
+	assert.Equal(t, sf.ID(), share.Records[0].RecordID)
+	assert.Equal(t, sharedLocker.ID(), share.Records[0].LockerID)
+	assert.True(t, hasEdge(share.ID, secondRev.ID, ProvRelationWasQuotedFrom))
+	assert.True(t, hasEdge(share.ID, idy2.ID(), ProvRelationWasAccessibleTo))
+
+	// exports
+
+	b, err := graph.JSONLD()
+	require.NoError(t, err)
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(b, &doc))
+	assert.Len(t, doc["@graph"], len(graph.Nodes))
+
+	// the document should be valid JSON-LD
+	sd, err := model.NewSignableDocument(b)
+	require.NoError(t, err)
+	_, err = sd.Hash()
+	require.NoError(t, err)
+
+	provN := graph.ProvN()
+	assert.True(t, strings.HasPrefix(provN, "document\n"))
+	assert.True(t, strings.HasSuffix(provN, "endDocument\n"))
+	assert.Contains(t, provN, "wasDerivedFrom(ml:"+secondRev.ID+", ml:"+firstRev.ID+", -, -, -, [prov:type='prov:Revision'])")
+
+	dot := graph.DOT()
+	assert.True(t, strings.HasPrefix(dot, "digraph provenance {"))
+	assert.Contains(t, dot, `"`+secondRev.ID+`" -> "`+firstRev.ID+`" [label="wasRevisionOf"]`)
+
+	var buf bytes.Buffer
+	require.Error(t, graph.Export(&buf, "xml"))
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"context"
+	"io"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/account"
+)
+
+type (
+	// readOnlyNodeClient wraps a node client for data wallets that were unlocked
+	// with a read-only access key. All operations that modify the account,
+	// the ledger or the stored blobs are rejected with model.ErrAccessKeyReadOnly.
+	readOnlyNodeClient struct {
+		NodeClient
+	}
+
+	readOnlyLedger struct {
+		model.Ledger
+	}
+
+	readOnlyOffChainStorage struct {
+		model.OffChainStorage
+	}
+
+	readOnlyBlobManager struct {
+		model.BlobManager
+	}
+)
+
+var _ NodeClient = (*readOnlyNodeClient)(nil)
+
+func newReadOnlyNodeClient(nodeClient NodeClient) NodeClient {
+	if _, isReadOnly := nodeClient.(*readOnlyNodeClient); isReadOnly {
+		return nodeClient
+	}
+	return &readOnlyNodeClient{NodeClient: nodeClient}
+}
+
+func (r *readOnlyNodeClient) CreateAccount(ctx context.Context, acct *account.Account, registrationCode string) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) UpdateAccount(ctx context.Context, acct *account.Account) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) PatchAccount(ctx context.Context, email, oldEncryptedPassword, newEncryptedPassword, name, givenName, familyName string) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) DeleteAccount(ctx context.Context, id string) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) CreateSubAccount(ctx context.Context, acct *account.Account) (*account.Account, error) {
+	return nil, model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) CreateAccessKey(ctx context.Context, key *model.AccessKey) (*model.AccessKey, error) {
+	return nil, model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) DeleteAccessKey(ctx context.Context, keyID string) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) StoreIdentity(ctx context.Context, idy *account.DataEnvelope) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) StoreLocker(ctx context.Context, l *account.DataEnvelope) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) StoreProperty(ctx context.Context, prop *account.DataEnvelope) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) DeleteProperty(ctx context.Context, hash string) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (r *readOnlyNodeClient) DIDProvider() model.DIDProvider {
+	return &restrictedDIDProvider{
+		backend: r.NodeClient.DIDProvider(),
+	}
+}
+
+func (r *readOnlyNodeClient) OffChainStorage() model.OffChainStorage {
+	return &readOnlyOffChainStorage{r.NodeClient.OffChainStorage()}
+}
+
+func (r *readOnlyNodeClient) Ledger() model.Ledger {
+	return &readOnlyLedger{r.NodeClient.Ledger()}
+}
+
+func (r *readOnlyNodeClient) BlobManager() model.BlobManager {
+	return &readOnlyBlobManager{r.NodeClient.BlobManager()}
+}
+
+func (r *readOnlyNodeClient) NewInstance(ctx context.Context, email, passphrase string, isHash bool) (NodeClient, error) {
+	nc, err := r.NodeClient.NewInstance(ctx, email, passphrase, isHash)
+	if err != nil {
+		return nil, err
+	}
+	return newReadOnlyNodeClient(nc), nil
+}
+
+func (r *readOnlyNodeClient) SubAccountInstance(subAccountID string) (NodeClient, error) {
+	nc, err := r.NodeClient.SubAccountInstance(subAccountID)
+	if err != nil {
+		return nil, err
+	}
+	return newReadOnlyNodeClient(nc), nil
+}
+
+func (l *readOnlyLedger) SubmitRecord(ctx context.Context, r *model.Record) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (s *readOnlyOffChainStorage) SendOperation(ctx context.Context, opData []byte) (string, error) {
+	return "", model.ErrAccessKeyReadOnly
+}
+
+func (s *readOnlyOffChainStorage) PurgeOperation(ctx context.Context, opAddr string) error {
+	return model.ErrAccessKeyReadOnly
+}
+
+func (bm *readOnlyBlobManager) SendBlob(ctx context.Context, data io.Reader, cleartext bool, vaultID string) (*model.StoredResource, error) {
+	return nil, model.ErrAccessKeyReadOnly
+}
+
+func (bm *readOnlyBlobManager) PurgeBlob(ctx context.Context, res *model.StoredResource) error {
+	return model.ErrAccessKeyReadOnly
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/scanner"
+	"github.com/rs/zerolog/log"
+)
+
+/*
+  Social recovery protocol:
+
+  1. The account owner splits the account's recovery phrase into N-of-M Shamir shares
+     (see account.NewRecoveryShares), one for each guardian DID.
+  2. For each guardian, the owner creates a locker between one of the account's identities
+     and the guardian, stores the share, encrypted with the guardian's verification key,
+     in the locker as a dataset with RecoveryShareEnvelope metadata, and invites
+     the guardian to join the locker.
+  3. Once the guardian accepts the invitation, they can find the share in the locker
+     (see GuardianRecoveryShares).
+  4. If the owner loses access to the account, they generate a one-off key pair and
+     pass its public key to the guardians. Each guardian re-encrypts their share
+     with this key (see ReleaseRecoveryShare) and returns it to the owner.
+  5. When the owner collects enough shares, they reconstruct the recovery phrase
+     (see account.CombineRecoveryShares) and follow the standard recovery procedure.
+*/
+
+const (
+	RecoveryShareEnvelopeType = "RecoveryShareEnvelope"
+)
+
+var (
+	ErrRecoveryShareNotFound = errors.New("recovery share not found")
+)
+
+type (
+	// RecoveryShareEnvelope is the metadata of the dataset that delivers a recovery share
+	// to a guardian through a locker.
+	RecoveryShareEnvelope struct {
+		Type     string `json:"type"`
+		SetID    string `json:"setID"`
+		Guardian string `json:"guardian"`
+		// Data is the base64-encoded recovery share, sealed with the guardian's verification key.
+		Data string `json:"data"`
+	}
+
+	// GuardianDelivery describes the delivery of a recovery share to one guardian.
+	GuardianDelivery struct {
+		Guardian string
+		LockerID string
+		// Share is the future of the dataset that contains the share.
+		Share dataset.RecordFuture
+		// Invitation is the future of the guardian's invitation to the locker.
+		Invitation dataset.RecordFuture
+	}
+
+	// SocialRecoverySetup describes the outcome of SetUpSocialRecovery.
+	SocialRecoverySetup struct {
+		SetID      string
+		Threshold  int
+		Deliveries []*GuardianDelivery
+	}
+
+	// HeldRecoveryShare is a recovery share that the account holds as a guardian.
+	HeldRecoveryShare struct {
+		RecordID string
+		LockerID string
+		Share    *account.RecoveryShare
+	}
+)
+
+type shareRecordConsumer struct {
+	records []shareRecord
+}
+
+type shareRecord struct {
+	recordID      string
+	lockerID      string
+	participantID string
+}
+
+var _ scanner.IndexBlockConsumer = (*shareRecordConsumer)(nil)
+
+func (sc *shareRecordConsumer) SetSubscription(sub scanner.Subscription) {}
+
+func (sc *shareRecordConsumer) ConsumeBlock(ctx context.Context, indexID string, partyLookup scanner.PartyLookup, n scanner.BlockNotification) error {
+	for _, dsn := range n.Datasets {
+		if dsn.Record.Operation != model.OpTypeLease || dsn.Record.Status != model.StatusPublished {
+			continue
+		}
+		lockerID, participantID, _, _ := partyLookup(dsn.KeyID)
+		sc.records = append(sc.records, shareRecord{
+			recordID:      dsn.RecordID,
+			lockerID:      lockerID,
+			participantID: participantID,
+		})
+	}
+	return nil
+}
+
+func (sc *shareRecordConsumer) NotifyScanCompleted(block int64) error {
+	return nil
+}
+
+// SetUpSocialRecovery splits the account's recovery phrase into shares, so that any threshold
+// number of the given guardians can help to recover the account, and delivers the shares
+// to the guardians through new lockers created for the issuer identity.
+func SetUpSocialRecovery(ctx context.Context, dw DataWallet, issuer Identity, recoveryPhrase string, guardians []string,
+	threshold int, expiryTime time.Time, opts ...dataset.BuilderOption) (*SocialRecoverySetup, error) {
+
+	shares, err := account.NewRecoveryShares(dw.Account(), issuer.DID(), recoveryPhrase, guardians, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &SocialRecoverySetup{
+		SetID:      shares[0].SetID,
+		Threshold:  threshold,
+		Deliveries: make([]*GuardianDelivery, len(shares)),
+	}
+
+	for i, share := range shares {
+		guardian, err := dw.GetDID(ctx, share.Guardian)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve guardian's DID %s: %w", share.Guardian, err)
+		}
+
+		sealedShare, err := account.SealRecoveryShare(share, guardian.VerKeyValue())
+		if err != nil {
+			return nil, err
+		}
+
+		locker, err := issuer.NewLocker(ctx, "Recovery guardian "+guardian.ID,
+			Participant(guardian, nil), ExpiresAt(expiryTime))
+		if err != nil {
+			return nil, err
+		}
+
+		envelope := &RecoveryShareEnvelope{
+			Type:     RecoveryShareEnvelopeType,
+			SetID:    share.SetID,
+			Guardian: share.Guardian,
+			Data:     base64.StdEncoding.EncodeToString(sealedShare),
+		}
+
+		res.Deliveries[i] = &GuardianDelivery{
+			Guardian:   guardian.ID,
+			LockerID:   locker.ID(),
+			Share:      locker.Store(ctx, envelope, expiryTime, opts...),
+			Invitation: locker.Invite(ctx, guardian.ID, "Please become my recovery guardian"),
+		}
+	}
+
+	return res, nil
+}
+
+// GuardianRecoveryShares returns recovery shares held by the account's identities
+// as guardians, in the order they were published on the ledger. Only lockers
+// that were accepted by the account are searched.
+func GuardianRecoveryShares(ctx context.Context, dw DataWallet) ([]*HeldRecoveryShare, error) {
+	identities, err := dw.GetIdentities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockers, err := dw.GetLockers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(lockers, func(i, j int) bool {
+		return lockers[i].ID < lockers[j].ID
+	})
+
+	consumer := &shareRecordConsumer{}
+	sub := scanner.NewIndexSubscription("recovery-shares:"+dw.ID(), consumer)
+
+	for _, l := range lockers {
+		us := l.Us()
+		if us == nil || len(l.Participants) < 2 {
+			continue
+		}
+		if err = sub.AddLockers(scanner.LockerEntry{Locker: l, LastBlock: l.FirstBlock}); err != nil {
+			return nil, err
+		}
+	}
+
+	ledgerScanner := scanner.NewScanner(dw.Services().Ledger())
+	if err = ledgerScanner.AddSubscription(sub); err != nil {
+		return nil, err
+	}
+	if _, err = ledgerScanner.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	res := make([]*HeldRecoveryShare, 0)
+	for _, r := range consumer.records {
+		if _, isOurs := identities[r.participantID]; isOurs {
+			// shares are submitted by the account owner, not the guardian
+			continue
+		}
+
+		hs, err := openHeldRecoveryShare(ctx, dw, identities, r.recordID, r.lockerID)
+		if err != nil {
+			if !errors.Is(err, ErrRecoveryShareNotFound) {
+				log.Warn().Err(err).Str("rid", r.recordID).Msg("Skipping invalid recovery share")
+			}
+			continue
+		}
+
+		res = append(res, hs)
+	}
+
+	return res, nil
+}
+
+// ReleaseRecoveryShare returns the recovery share from the dataset with the given record ID,
+// encrypted with the recovering user's one-off key.
+func ReleaseRecoveryShare(ctx context.Context, dw DataWallet, recordID string, requestKey ed25519.PublicKey) ([]byte, error) {
+	if len(requestKey) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid recovery request key")
+	}
+
+	identities, err := dw.GetIdentities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hs, err := openHeldRecoveryShare(ctx, dw, identities, recordID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return account.SealRecoveryShare(hs.Share, requestKey)
+}
+
+func openHeldRecoveryShare(ctx context.Context, dw DataWallet, identities map[string]Identity, recordID, lockerID string) (*HeldRecoveryShare, error) {
+	var loadOpts []dataset.LoadOption
+	if lockerID != "" {
+		loadOpts = append(loadOpts, dataset.FromLocker(lockerID))
+	}
+
+	ds, err := dw.DataStore().Load(ctx, recordID, loadOpts...)
+	if err != nil {
+		if errors.Is(err, model.ErrDataSetNotFound) {
+			return nil, ErrRecoveryShareNotFound
+		}
+		return nil, err
+	}
+
+	var envelope RecoveryShareEnvelope
+	if err = ds.DecodeMetaResource(ctx, &envelope); err != nil || envelope.Type != RecoveryShareEnvelopeType {
+		return nil, ErrRecoveryShareNotFound
+	}
+
+	guardian, found := identities[envelope.Guardian]
+	if !found {
+		return nil, ErrRecoveryShareNotFound
+	}
+
+	data, err := base64.StdEncoding.DecodeString(envelope.Data)
+	if err != nil {
+		return nil, account.ErrInvalidRecoveryShare
+	}
+
+	share, err := account.OpenRecoveryShare(data, guardian.DID().SignKeyValue())
+	if err != nil {
+		return nil, err
+	}
+
+	if share.Guardian != guardian.ID() || share.SetID != envelope.SetID || share.Issuer != ds.ParticipantID() {
+		return nil, account.ErrInvalidRecoveryShare
+	}
+
+	// check the issuer's verification key belongs to the issuer's DID
+
+	issuer, err := dw.GetDID(ctx, share.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if issuer.VerKey != share.IssuerVerKey {
+		return nil, fmt.Errorf("%w: issuer's verification key doesn't match their DID", account.ErrInvalidRecoveryShare)
+	}
+
+	return &HeldRecoveryShare{
+		RecordID: ds.ID(),
+		LockerID: ds.LockerID(),
+		Share:    share,
+	}, nil
+}
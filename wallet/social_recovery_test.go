@@ -0,0 +1,149 @@
+// Copyright 2022 Piprate Limited
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wallet_test
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/piprate/metalocker/model"
+	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/model/dataset"
+	"github.com/piprate/metalocker/model/expiry"
+	"github.com/piprate/metalocker/sdk/testbase"
+	. "github.com/piprate/metalocker/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocialRecovery(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw, recDetails, err := env.Factory.RegisterAccount(
+		ctx,
+		&account.Account{
+			Email:        "owner@example.com",
+			Name:         "John Doe",
+			AccessLevel:  model.AccessLevelHosted,
+			DefaultVault: testbase.TestVaultName,
+		},
+		account.WithPassphraseAuth(TestPassphrase))
+	require.NoError(t, err)
+
+	err = dw.Unlock(ctx, TestPassphrase)
+	require.NoError(t, err)
+
+	issuer, err := dw.NewIdentity(ctx, model.AccessLevelHosted, "")
+	require.NoError(t, err)
+
+	guardianWallets := make([]DataWallet, 3)
+	guardianIDs := make([]string, 3)
+	for i := range guardianWallets {
+		guardianWallets[i] = env.CreateCustomAccount(t, fmt.Sprintf("guardian%d@example.com", i+1),
+			"Guardian", model.AccessLevelHosted)
+		idy, err := guardianWallets[i].NewIdentity(ctx, model.AccessLevelHosted, "")
+		require.NoError(t, err)
+		guardianIDs[i] = idy.ID()
+	}
+
+	_, err = SetUpSocialRecovery(ctx, dw, issuer, "bad phrase", guardianIDs, 2, expiry.FromNow("1h"),
+		dataset.WithVault(testbase.TestVaultName))
+	require.Error(t, err)
+
+	setup, err := SetUpSocialRecovery(ctx, dw, issuer, recDetails.RecoveryPhrase, guardianIDs, 2, expiry.FromNow("1h"),
+		dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+	assert.Equal(t, 2, setup.Threshold)
+	require.Len(t, setup.Deliveries, 3)
+
+	for i, d := range setup.Deliveries {
+		assert.Equal(t, guardianIDs[i], d.Guardian)
+		require.NoError(t, d.Share.Wait(time.Second*10))
+		require.NoError(t, d.Invitation.Wait(time.Second*10))
+	}
+
+	// the owner doesn't hold any shares as a guardian
+
+	shares, err := GuardianRecoveryShares(ctx, dw)
+	require.NoError(t, err)
+	assert.Empty(t, shares)
+
+	// the first and the third guardians accept the invitations
+
+	for _, i := range []int{0, 2} {
+		gw := guardianWallets[i]
+
+		invitations, err := gw.LockerInvitations(ctx)
+		require.NoError(t, err)
+		require.Len(t, invitations, 1)
+		assert.Equal(t, setup.Deliveries[i].LockerID, invitations[0].Locker.ID)
+
+		_, err = gw.AcceptLockerInvitation(ctx, invitations[0].ID)
+		require.NoError(t, err)
+	}
+
+	shares, err = GuardianRecoveryShares(ctx, guardianWallets[1])
+	require.NoError(t, err)
+	assert.Empty(t, shares)
+
+	// the owner lost the password and asks the guardians to return their shares
+
+	err = dw.Lock()
+	require.NoError(t, err)
+
+	requestPubKey, requestPrivKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	returnedShares := make([]*account.RecoveryShare, 0, 2)
+	for _, i := range []int{0, 2} {
+		shares, err = GuardianRecoveryShares(ctx, guardianWallets[i])
+		require.NoError(t, err)
+		require.Len(t, shares, 1)
+
+		hs := shares[0]
+		assert.Equal(t, setup.Deliveries[i].Share.ID(), hs.RecordID)
+		assert.Equal(t, setup.Deliveries[i].LockerID, hs.LockerID)
+		assert.Equal(t, setup.SetID, hs.Share.SetID)
+		assert.Equal(t, "owner@example.com", hs.Share.UserID)
+		assert.Equal(t, issuer.ID(), hs.Share.Issuer)
+		assert.Equal(t, guardianIDs[i], hs.Share.Guardian)
+
+		// guardians can't release shares they don't hold
+
+		_, err = ReleaseRecoveryShare(ctx, guardianWallets[1], hs.RecordID, requestPubKey)
+		require.Error(t, err)
+
+		data, err := ReleaseRecoveryShare(ctx, guardianWallets[i], hs.RecordID, requestPubKey)
+		require.NoError(t, err)
+
+		s, err := account.OpenRecoveryShare(data, requestPrivKey)
+		require.NoError(t, err)
+		returnedShares = append(returnedShares, s)
+	}
+
+	cryptoKey, _, _, err := account.RecoveryKeysFromShares(returnedShares)
+	require.NoError(t, err)
+
+	newDataWallet, err := dw.Recover(ctx, cryptoKey, "newpass")
+	require.NoError(t, err)
+
+	_, err = newDataWallet.GetIdentity(ctx, issuer.ID())
+	require.NoError(t, err)
+}
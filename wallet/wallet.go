@@ -83,6 +83,11 @@ type (
 		datasetStore DataStore
 
 		confirmAccountUpdates bool
+
+		// accessKey defines restrictions (permission and scope) of the access key
+		// that was used to unlock the wallet. Nil if the wallet was unlocked
+		// with an unrestricted key or by other means.
+		accessKey *model.AccessKey
 	}
 )
 
@@ -412,6 +417,9 @@ func (dw *LocalDataWallet) UnlockWithAccessKey(ctx context.Context, apiKey, apiS
 	if err != nil {
 		return err
 	}
+	if accessKey.IsExpired(time.Now()) {
+		return model.ErrAccessKeyExpired
+	}
 	err = accessKey.Hydrate(apiSecret)
 	if err != nil {
 		return err
@@ -457,6 +465,23 @@ func (dw *LocalDataWallet) UnlockWithAccessKey(ctx context.Context, apiKey, apiS
 		return errors.New("access key doesn't have sufficient permissions to unlock the wallet")
 	}
 
+	if accessKey.IsRestricted() {
+		dw.accessKey = &model.AccessKey{
+			ID:         accessKey.ID,
+			ExpiresAt:  accessKey.ExpiresAt,
+			Permission: accessKey.Permission,
+			Lockers:    accessKey.Lockers,
+			Identities: accessKey.Identities,
+		}
+
+		if accessKey.IsReadOnly() {
+			dw.nodeClient = newReadOnlyNodeClient(dw.nodeClient)
+			if dw.datasetStore, err = dw.dataStoreFn(dw, dw.nodeClient); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -504,7 +529,8 @@ func (dw *LocalDataWallet) addLocker(ctx context.Context, locker Locker) error {
 		for _, party := range locker.Raw().Participants {
 			var signKey ed25519.PrivateKey
 			if party.Self {
-				idy, err := dw.GetIdentity(ctx, party.ID)
+				// lockers are hydrated regardless of the access key's identity scope
+				idy, err := dw.getIdentity(ctx, party.ID)
 				if err != nil {
 					return err
 				}
@@ -628,35 +654,127 @@ func (dw *LocalDataWallet) AddLocker(ctx context.Context, locker *model.Locker)
 		return nil, err
 	}
 
-	switch locker.AccessLevel {
-	case model.AccessLevelManaged:
-		envelope, err := dw.encryptLocker(locker)
-		if err != nil {
-			return nil, err
+	if err = dw.storeLocker(ctx, locker); err != nil {
+		return nil, err
+	}
+
+	return wrapper, nil
+}
+
+func (dw *LocalDataWallet) UpdateLocker(ctx context.Context, locker *model.Locker) (Locker, error) {
+	existing, err := dw.GetLocker(ctx, locker.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := existing.Raw()
+
+	if locker.AccessLevel != current.AccessLevel {
+		return nil, errors.New("locker access level can't be changed")
+	}
+
+	if locker.AccessLevel == model.AccessLevelLocal {
+		if dw.lockLevel < model.AccessLevelHosted {
+			return nil, ErrInsufficientLockLevel
 		}
-		if err = dw.nodeClient.StoreLocker(ctx, envelope); err != nil {
+	} else if dw.lockLevel < locker.AccessLevel {
+		return nil, ErrInsufficientLockLevel
+	}
+
+	// only new participants can be added. Existing participants' keys can only be rotated
+
+	rotated := false
+	for _, p := range current.Participants {
+		np := locker.GetParticipant(p.ID)
+		if np == nil || !np.SucceedsKeys(p) {
+			return nil, fmt.Errorf("%w: participant %s can't be removed or modified",
+				model.ErrParticipantInvalid, p.ID)
+		}
+		if np.Epoch() > p.Epoch() {
+			if p.Self && np.RootPrivateKeyEnc == "" {
+				return nil, fmt.Errorf("%w: rotated keys for participant %s don't include the private key",
+					model.ErrParticipantInvalid, p.ID)
+			}
+			rotated = true
+		}
+	}
+	sealed := locker.IsSealed() && !current.IsSealed()
+	participantsChanged := rotated || len(locker.Participants) != len(current.Participants)
+
+	if !participantsChanged && !sealed {
+		return existing, nil
+	}
+
+	newLocker := current.Copy()
+	for _, p := range locker.Copy().Participants {
+		ep := newLocker.GetParticipant(p.ID)
+		if ep == nil {
+			newLocker.Participants = append(newLocker.Participants, p)
+		} else if p.Epoch() > ep.Epoch() {
+			ep.SharedSecret = p.SharedSecret
+			ep.RootPublicKey = p.RootPublicKey
+			ep.RootPrivateKeyEnc = p.RootPrivateKeyEnc
+			ep.EpochStartBlock = p.EpochStartBlock
+			ep.PreviousEpochs = p.PreviousEpochs
+		}
+	}
+	if sealed {
+		// a sealed locker can't be unsealed
+		newLocker.Sealed = locker.Sealed
+		newLocker.LastBlock = locker.LastBlock
+	}
+	locker = newLocker
+
+	if participantsChanged {
+		// notify the account's indexes, so that they start scanning for new participants' records
+		// and new key epochs. Indexes learn about sealed lockers from the ledger.
+
+		if _, err = dw.sendAccountUpdate(ctx,
+			&AccountUpdate{
+				Type:          AccountUpdateType,
+				AccountID:     dw.acct.ID,
+				AccessLevel:   locker.AccessLevel,
+				LockersOpened: []string{locker.ID},
+			}, true); err != nil {
+			log.Err(err).Msg("Error when sending account update message")
 			return nil, err
 		}
-	case model.AccessLevelHosted:
+	}
+
+	if err = dw.storeLocker(ctx, locker); err != nil {
+		return nil, err
+	}
+
+	wrapper := newLockerWrapper(dw, locker)
+	if err = dw.addLocker(ctx, wrapper); err != nil {
+		return nil, err
+	}
+
+	return wrapper, nil
+}
+
+func (dw *LocalDataWallet) storeLocker(ctx context.Context, locker *model.Locker) error {
+	switch locker.AccessLevel {
+	case model.AccessLevelManaged, model.AccessLevelHosted:
 		envelope, err := dw.encryptLocker(locker)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if err = dw.nodeClient.StoreLocker(ctx, envelope); err != nil {
-			return nil, err
+			return err
 		}
 	case model.AccessLevelLocal:
 		if err := dw.flushToHostedSecretStore(); err != nil {
-			return nil, errors.New("failed to build encrypted payload")
+			return errors.New("failed to build encrypted payload")
 		}
 		if err := dw.nodeClient.UpdateAccount(ctx, dw.acct); err != nil {
-			return nil, err
+			return err
 		}
 	default:
-		return nil, fmt.Errorf("locker access level not supported: %d", locker.AccessLevel)
+		return fmt.Errorf("locker access level not supported: %d", locker.AccessLevel)
 	}
 
-	return wrapper, nil
+	return nil
 }
 
 func (dw *LocalDataWallet) AddIdentity(ctx context.Context, idy *account.Identity) error {
@@ -680,6 +798,10 @@ func (dw *LocalDataWallet) AddIdentity(ctx context.Context, idy *account.Identit
 		return errors.New("DID sign key is not provided")
 	}
 
+	if err := idy.DID.Validate(); err != nil {
+		return err
+	}
+
 	if idy.AccessLevel == model.AccessLevelNone {
 		return errors.New("identity access level is not provided")
 	}
@@ -807,6 +929,16 @@ func (dw *LocalDataWallet) GetIdentities(ctx context.Context) (map[string]Identi
 		}
 	}
 
+	if dw.accessKey != nil {
+		res := make(map[string]Identity, len(dw.identities))
+		for iid, idy := range dw.identities {
+			if dw.accessKey.AllowsIdentity(iid) {
+				res[iid] = idy
+			}
+		}
+		return res, nil
+	}
+
 	return dw.identities, nil
 }
 
@@ -815,6 +947,14 @@ func (dw *LocalDataWallet) GetIdentity(ctx context.Context, iid string) (Identit
 		return nil, ErrWalletLocked
 	}
 
+	if dw.accessKey != nil && !dw.accessKey.AllowsIdentity(iid) {
+		return nil, storage.ErrIdentityNotFound
+	}
+
+	return dw.getIdentity(ctx, iid)
+}
+
+func (dw *LocalDataWallet) getIdentity(ctx context.Context, iid string) (Identity, error) {
 	dw.dataMtx.RLock()
 	idy, found := dw.identities[iid]
 	dw.dataMtx.RUnlock()
@@ -854,7 +994,19 @@ func (dw *LocalDataWallet) GetDID(ctx context.Context, iid string) (*model.DID,
 	} else {
 		didDoc, err := dw.nodeClient.DIDProvider().GetDIDDocument(ctx, iid)
 		if err != nil {
-			return nil, err
+			if !errors.Is(err, storage.ErrDIDNotFound) {
+				return nil, err
+			}
+
+			// try external DID methods (did:key, did:web, etc.)
+			var resErr error
+			didDoc, resErr = model.ResolveDIDDocument(ctx, iid)
+			if resErr != nil {
+				if errors.Is(resErr, model.ErrDIDMethodNotSupported) || errors.Is(resErr, model.ErrDIDDocumentNotFound) {
+					return nil, err
+				}
+				return nil, resErr
+			}
 		}
 
 		return didDoc.ExtractIndyStyleDID()
@@ -883,7 +1035,9 @@ func (dw *LocalDataWallet) GetLockers(ctx context.Context) ([]*model.Locker, err
 	dw.dataMtx.RLock()
 	list := make([]*model.Locker, 0, len(dw.lockers))
 	for _, l := range dw.lockers {
-		list = append(list, l.Raw())
+		if dw.accessKey == nil || dw.accessKey.AllowsLocker(l.Raw()) {
+			list = append(list, l.Raw())
+		}
 	}
 	dw.dataMtx.RUnlock()
 
@@ -923,6 +1077,10 @@ func (dw *LocalDataWallet) GetLocker(ctx context.Context, lockerID string) (Lock
 		}
 	}
 
+	if dw.accessKey != nil && !dw.accessKey.AllowsLocker(locker.Raw()) {
+		return nil, storage.ErrLockerNotFound
+	}
+
 	return locker, nil
 }
 
@@ -1233,14 +1391,23 @@ func (dw *LocalDataWallet) SubAccounts(ctx context.Context) ([]*account.Account,
 	return dw.nodeClient.ListSubAccounts(ctx, dw.acct.ID)
 }
 
-func (dw *LocalDataWallet) CreateAccessKey(ctx context.Context, accessLevel model.AccessLevel, duration time.Duration) (*model.AccessKey, error) {
+func (dw *LocalDataWallet) CreateAccessKey(ctx context.Context, accessLevel model.AccessLevel, duration time.Duration, opts ...model.AccessKeyOption) (*model.AccessKey, error) {
 
 	// we allow to create a key with managed secrets only for hosted accounts
 	if dw.lockLevel < accessLevel {
 		return nil, ErrInsufficientLockLevel
 	}
 
-	key, err := model.GenerateAccessKey(dw.acct.ID, accessLevel)
+	if dw.accessKey != nil {
+		// restricted keys can't be used to mint keys with broader permissions
+		return nil, ErrForbiddenOperation
+	}
+
+	if duration > 0 {
+		opts = append([]model.AccessKeyOption{model.WithExpiry(time.Now().Add(duration))}, opts...)
+	}
+
+	key, err := model.GenerateAccessKey(dw.acct.ID, accessLevel, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1580,6 +1747,20 @@ func (dw *LocalDataWallet) Index(ctx context.Context, id string) (index.Index, e
 	return ix, nil
 }
 
+func (dw *LocalDataWallet) SearchIndex(ctx context.Context) (index.SearchIndex, error) {
+	ix, err := dw.Index(ctx, index.SearchIndexID(dw.acct.ID, dw.acct.AccessLevel))
+	if err != nil {
+		return nil, err
+	}
+
+	si, ok := ix.(index.SearchIndex)
+	if !ok {
+		return nil, fmt.Errorf("index %s is not a search index", ix.ID())
+	}
+
+	return si, nil
+}
+
 func (dw *LocalDataWallet) IndexUpdater(ctx context.Context, indexes ...index.Index) (*IndexUpdater, error) {
 	updater := NewIndexUpdater(dw.nodeClient.Ledger())
 	err := updater.AddIndexes(ctx, dw, indexes...)
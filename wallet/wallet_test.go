@@ -23,6 +23,7 @@ import (
 	"github.com/btcsuite/btcd/btcutil/base58"
 	"github.com/piprate/metalocker/model"
 	"github.com/piprate/metalocker/model/account"
+	"github.com/piprate/metalocker/model/dataset"
 	"github.com/piprate/metalocker/sdk/testbase"
 	"github.com/piprate/metalocker/storage"
 	. "github.com/piprate/metalocker/wallet"
@@ -208,6 +209,97 @@ func TestLocalDataWallet_UnlockWithAccessKey(t *testing.T) {
 	assert.NotNil(t, idyList)
 }
 
+func TestLocalDataWallet_UnlockWithAccessKey_Expired(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	dw := testHostedAccount(t, env, true)
+
+	key, err := dw.CreateAccessKey(env.Ctx, model.AccessLevelHosted, 0,
+		model.WithExpiry(time.Now().Add(-time.Minute)))
+	require.NoError(t, err)
+
+	apiKey, apiSecret := key.ClientKeys()
+
+	_, err = env.Factory.GetWalletWithAccessKey(env.Ctx, apiKey, apiSecret)
+	assert.ErrorIs(t, err, model.ErrAccessKeyExpired)
+
+	err = dw.Lock()
+	require.NoError(t, err)
+
+	err = dw.UnlockWithAccessKey(env.Ctx, apiKey, apiSecret)
+	assert.ErrorIs(t, err, model.ErrAccessKeyExpired)
+}
+
+func TestLocalDataWallet_UnlockWithAccessKey_Restricted(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	ctx := env.Ctx
+
+	dw := testHostedAccount(t, env, true)
+
+	idy1, err := dw.NewIdentity(ctx, model.AccessLevelHosted, "Identity 1")
+	require.NoError(t, err)
+	locker1, err := idy1.NewLocker(ctx, "Locker 1")
+	require.NoError(t, err)
+
+	idy2, err := dw.NewIdentity(ctx, model.AccessLevelHosted, "Identity 2")
+	require.NoError(t, err)
+	locker2, err := idy2.NewLocker(ctx, "Locker 2")
+	require.NoError(t, err)
+
+	key, err := dw.CreateAccessKey(ctx, model.AccessLevelHosted, time.Hour,
+		model.WithPermission(model.AccessKeyPermissionReadOnly),
+		model.WithIdentityScope(idy1.ID()))
+	require.NoError(t, err)
+	require.NotNil(t, key.ExpiresAt)
+
+	apiKey, apiSecret := key.ClientKeys()
+
+	rdw, err := env.Factory.GetWalletWithAccessKey(ctx, apiKey, apiSecret)
+	require.NoError(t, err)
+
+	// identity scope
+
+	_, err = rdw.GetIdentity(ctx, idy1.ID())
+	require.NoError(t, err)
+
+	_, err = rdw.GetIdentity(ctx, idy2.ID())
+	assert.ErrorIs(t, err, storage.ErrIdentityNotFound)
+
+	idyMap, err := rdw.GetIdentities(ctx)
+	require.NoError(t, err)
+	assert.Len(t, idyMap, 1)
+	assert.Contains(t, idyMap, idy1.ID())
+
+	// locker scope
+
+	l, err := rdw.GetLocker(ctx, locker1.ID())
+	require.NoError(t, err)
+
+	_, err = rdw.GetLocker(ctx, locker2.ID())
+	assert.ErrorIs(t, err, storage.ErrLockerNotFound)
+
+	lockers, err := rdw.GetLockers(ctx)
+	require.NoError(t, err)
+	require.Len(t, lockers, 1)
+	assert.Equal(t, locker1.ID(), lockers[0].ID)
+
+	// read-only permission
+
+	_, err = rdw.CreateAccessKey(ctx, model.AccessLevelHosted, time.Hour)
+	assert.ErrorIs(t, err, ErrForbiddenOperation)
+
+	lb, err := l.NewDataSetBuilder(ctx, dataset.WithVault(testbase.TestVaultName))
+	require.NoError(t, err)
+
+	_, err = lb.AddMetaResource(map[string]any{
+		"type": "TestDataset",
+	})
+	assert.ErrorIs(t, err, model.ErrAccessKeyReadOnly)
+}
+
 func TestLocalDataWallet_AddLocker_Hosted(t *testing.T) {
 	env := testbase.SetUpTestEnvironment(t)
 	defer func() { _ = env.Close() }()
@@ -584,6 +676,42 @@ func TestLocalDataWallet_GetRootIdentity(t *testing.T) {
 	assert.Equal(t, dw.ID(), rootIdy.ID())
 }
 
+func TestLocalDataWallet_GetDID_KeyMethod(t *testing.T) {
+	env := testbase.SetUpTestEnvironment(t)
+	defer func() { _ = env.Close() }()
+
+	dw := testHostedAccount(t, env, true)
+
+	// identities can use did:key DIDs
+
+	did, err := model.GenerateDID(model.WithMethod(model.DIDMethodKey))
+	require.NoError(t, err)
+
+	idy, err := dw.NewIdentity(env.Ctx, model.AccessLevelHosted, "", WithDID(did))
+	require.NoError(t, err)
+	assert.Equal(t, did.ID, idy.ID())
+
+	// inconsistent did:key DIDs are rejected
+
+	anotherDID, err := model.GenerateDID()
+	require.NoError(t, err)
+	badDID := model.NewDID(model.BuildKeyDID(base58.Decode(anotherDID.VerKey)), did.VerKey, did.SignKey)
+	_, err = dw.NewIdentity(env.Ctx, model.AccessLevelHosted, "", WithDID(badDID))
+	assert.ErrorIs(t, err, model.ErrInvalidDID)
+
+	// external did:key DIDs are resolved without being registered
+
+	extDID, err := model.GenerateDID(model.WithMethod(model.DIDMethodKey))
+	require.NoError(t, err)
+
+	resolvedDID, err := dw.GetDID(env.Ctx, extDID.ID)
+	require.NoError(t, err)
+	assert.Equal(t, extDID.VerKey, resolvedDID.VerKey)
+
+	_, err = dw.GetDID(env.Ctx, "did:unknown:xyz")
+	assert.ErrorIs(t, err, storage.ErrDIDNotFound)
+}
+
 func TestLocalDataWallet_ChangePassphrase_Hosted(t *testing.T) {
 	env := testbase.SetUpTestEnvironment(t)
 	defer func() { _ = env.Close() }()